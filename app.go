@@ -1,3 +1,5 @@
+//go:build !nogui
+
 package main
 
 import (
@@ -17,9 +19,38 @@ type Application struct {
 	toolbar     *Toolbar
 	imageViewer *ImageViewer
 	parameters  *ParameterPanel
+	components  *ComponentPanel
+	touchUp     *TouchUpPanel
+	metadata    *MetadataPanel
+	inspector   *InspectorPanel
+	notes       *NotesPanel
+	logs        *LogPanel
+	warnings    *WarningStrip
 	processing  *ProcessingEngine
+	dock        *PanelDock
+	statusBar   *StatusBar
+	toaster     *Toaster
+
+	// suggestedProfile is the profile last offered for a loaded image.
+	suggestedProfile string
+
+	// sourceName is the file or device the loaded image came from;
+	// appliedProfile is the last profile applied to the parameters.
+	sourceName     string
+	appliedProfile *Profile
 
 	debugSystem *DebugSystem
+
+	// master marks the first window; closing it quits the application and
+	// releases process-wide resources such as the debug system.
+	master bool
+
+	// closing is set once shutdown has started.
+	closing bool
+
+	// previewCalibration describes the preview quality measured at startup,
+	// empty until the measurement is in or when it is not automatic.
+	previewCalibration string
 }
 
 func NewApplication(fyneApp fyne.App, window fyne.Window, ctx context.Context, cancel context.CancelFunc) *Application {
@@ -28,6 +59,7 @@ func NewApplication(fyneApp fyne.App, window fyne.Window, ctx context.Context, c
 		window:  window,
 		ctx:     ctx,
 		cancel:  cancel,
+		master:  true,
 	}
 
 	app.debugSystem = InitDebugSystem(DebugConfig{
@@ -35,18 +67,20 @@ func NewApplication(fyneApp fyne.App, window fyne.Window, ctx context.Context, c
 		EnableTracing: true,
 		EnableMonitor: true,
 		ConsoleOutput: true,
+		Capture:       GetLogBuffer(),
 	})
 
 	// Apply custom theme before creating UI components
 	fyneApp.Settings().SetTheme(NewOtsuTheme())
 
-	app.processing = NewProcessingEngine()
-	app.imageViewer = NewImageViewer()
-	app.parameters = NewParameterPanel(app)
-	app.toolbar = NewToolbar(app)
+	SetExternalStagesAllowed(fyneApp.Preferences().BoolWithFallback(prefAllowExternalStages, false))
+	SetColorManagement(fyneApp.Preferences().BoolWithFallback(prefColorManagement, true))
+	app.applyCachePreference()
+	app.applyPreviewQualityPreference()
+	app.applyBatterySaverPreference()
+	app.applyEncodePreference()
 
-	app.setupWindow()
-	app.setupMenu()
+	app.buildDocument()
 
 	app.debugSystem.logger.Info("application initialized",
 		"debug_enabled", true,
@@ -57,16 +91,66 @@ func NewApplication(fyneApp fyne.App, window fyne.Window, ctx context.Context, c
 	return app
 }
 
+// buildDocument creates the per-window processing context and widgets.
+func (a *Application) buildDocument() {
+	a.statusBar = NewStatusBar()
+	a.toaster = NewToaster(a)
+	a.processing = NewProcessingEngine()
+	a.imageViewer = NewImageViewer()
+	a.parameters = NewParameterPanel(a)
+	a.components = NewComponentPanel(a)
+	a.touchUp = NewTouchUpPanel(a)
+	a.metadata = NewMetadataPanel(a)
+	a.inspector = NewInspectorPanel(a)
+	a.notes = NewNotesPanel(a)
+	a.logs = NewLogPanel()
+	a.warnings = NewWarningStrip()
+	a.imageViewer.OnProcessedTapped = a.touchUp.HandleTap
+	a.imageViewer.OnProcessedDragged = a.touchUp.HandleDrag
+	a.imageViewer.OnProcessedDragEnd = a.touchUp.HandleDragEnd
+	a.imageViewer.OnProcessedHovered = a.inspector.Inspect
+	a.toolbar = NewToolbar(a)
+	a.dock = NewPanelDock(a)
+
+	a.setupWindow()
+	a.setupMenu()
+
+	if a.master {
+		a.dock.RestoreLayout()
+	}
+
+	go a.statusBar.Run(a.ctx)
+	go a.logs.Run(a.ctx)
+	go a.imageViewer.Run(a.ctx)
+}
+
 func (a *Application) setupWindow() {
 	a.window.Resize(fyne.NewSize(1360, 768))
 	a.window.CenterOnScreen()
-	a.window.SetMaster()
+	if a.master {
+		a.window.SetMaster()
+	}
 
 	// Direct split container - no wrapper needed
-	content := container.NewVBox(
-		a.imageViewer.GetContainer(),
-		a.toolbar.GetContainer(),
-		a.parameters.GetContainer(),
+	content := container.NewBorder(
+		nil,
+		a.statusBar.GetContainer(),
+		nil, nil,
+		container.NewVBox(
+			a.imageViewer.GetContainer(),
+			a.toolbar.GetContainer(),
+			a.warnings.GetContainer(),
+			container.NewHBox(
+				a.dock.AddPanel("parameters", "Parameters", a.parameters.GetContainer()),
+				a.dock.AddPanel("metrics", "Metrics", a.parameters.GetMetricsContainer()),
+				a.dock.AddPanel("components", "Components", a.components.GetContainer()),
+				a.dock.AddPanel("touchup", "Touch-Up", a.touchUp.GetContainer()),
+				a.dock.AddPanel("inspector", "Inspector", a.inspector.GetContainer()),
+				a.dock.AddPanel("metadata", "Metadata", a.metadata.GetContainer()),
+				a.dock.AddPanel("notes", "Notes", a.notes.GetContainer()),
+				a.dock.AddPanel("log", "Log", a.logs.GetContainer()),
+			),
+		),
 	)
 
 	a.window.SetContent(content)
@@ -78,31 +162,78 @@ func (a *Application) setupWindow() {
 	DebugLogUILayout(debugSystem.logger, "image_viewer_in_window", a.imageViewer.GetContainer())
 
 	a.window.SetCloseIntercept(func() {
-		a.cleanup()
-		a.window.Close()
+		a.window.Hide()
+		a.shutdown(a.window.Close)
 	})
 }
 
-func (a *Application) cleanup() {
-	if a.toolbar != nil {
-		a.toolbar.CancelCurrentProcessing()
+// cleanup saves the window's state and releases what it holds. drained
+// reports whether every run finished; otherwise the engine's Mats are left
+// to the process exit, since a run may still be using them.
+func (a *Application) cleanup(drained bool) {
+	if a.components != nil {
+		a.components.Clear()
+	}
+
+	if a.dock != nil {
+		a.dock.SaveLayout()
+		a.dock.CloseFloating()
+	}
+
+	if a.processing != nil {
+		if drained {
+			a.processing.Close()
+		} else {
+			a.debugSystem.logger.Warn("processing still running at shutdown", "timeout", shutdownDrainTimeout.String())
+		}
+	}
+
+	if !a.master {
+		a.debugSystem.logger.Info("document window closed")
+		return
 	}
 
 	if a.debugSystem != nil {
 		a.debugSystem.DumpSystemState()
+		a.debugSystem.logger.Info("application cleanup completed")
 		a.debugSystem.Close()
 	}
-
-	a.cancel()
-
-	a.debugSystem.logger.Info("application cleanup completed")
 }
 
 func (a *Application) setupMenu() {
-	fileMenu := fyne.NewMenu("File")
+	fileMenu := fyne.NewMenu("File",
+		fyne.NewMenuItem("New Window", a.openNewWindow),
+		fyne.NewMenuItem("Acquire from Scanner...", a.showScannerDialog),
+		fyne.NewMenuItem("Live Capture...", a.showLiveCapture),
+		fyne.NewMenuItem("Binarize Video or Sequence...", a.showFrameSequenceDialog),
+		fyne.NewMenuItem("Inspect Channels...", a.showChannelView),
+		fyne.NewMenuItem("Convert Image...", a.showConvertDialog),
+		fyne.NewMenuItem("Compare Runs...", a.showRunComparisonDialog),
+		fyne.NewMenuItem("Import External Result...", a.showImportExternalResult),
+		fyne.NewMenuItem("Load Ground Truth...", a.showLoadGroundTruth),
+		fyne.NewMenuItem("Clear Ground Truth", a.clearGroundTruth),
+		fyne.NewMenuItem("Export Region Audit...", a.showExportRegionAudit),
+		fyne.NewMenuItem("Print Report...", a.showPrintReport),
+		fyne.NewMenuItem("Export Report PDF...", a.showExportReport),
+		fyne.NewMenuItem("Export Vector Outlines...", a.showExportVector),
+		fyne.NewMenuItem("Export Text Regions...", a.showExportLayout),
+		fyne.NewMenuItem("Benchmark Dashboard...", a.showBenchmarkDashboard),
+		fyne.NewMenuItem("Models...", a.showModelManager),
+		fyne.NewMenuItemSeparator(),
+		fyne.NewMenuItem("Working Size Limit...", a.showWorkingLimitDialog),
+		fyne.NewMenuItem("Preferences...", a.showPreferences),
+	)
+	editMenu := fyne.NewMenu("Edit", a.buildRunMenu()...)
+	viewMenu := a.dock.buildViewMenu()
+	viewMenu.Items = append(viewMenu.Items, fyne.NewMenuItemSeparator())
+	viewMenu.Items = append(viewMenu.Items, a.buildCompareMenu()...)
+	viewMenu.Items = append(viewMenu.Items,
+		fyne.NewMenuItemSeparator(),
+		a.toaster.buildMenuItem(),
+	)
 	helpMenu := a.buildHelpMenu()
 
-	mainMenu := fyne.NewMainMenu(fileMenu, helpMenu)
+	mainMenu := fyne.NewMainMenu(fileMenu, editMenu, viewMenu, helpMenu)
 	a.window.SetMainMenu(mainMenu)
 }
 
@@ -0,0 +1,229 @@
+//go:build !nogui
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/storage"
+)
+
+// remoteCommand is the first argument that turns a launch into an automation
+// client for the running instance instead of starting the GUI.
+const remoteCommand = "remote"
+
+// automationTimeout bounds how long a client waits for a command to finish;
+// processing large scans with the multi-scale method can take minutes.
+const automationTimeout = 10 * time.Minute
+
+// Automation commands act on the window that owns the instance socket, so a
+// script can run load, process, save and export-metrics in sequence.
+func (a *Application) automationLoad(path string) InstanceResponse {
+	if path == "" {
+		return InstanceResponse{Error: "load requires a path"}
+	}
+
+	data, extension, err := readImageFile(path)
+	if err != nil {
+		return InstanceResponse{Error: err.Error()}
+	}
+
+	imageData, err := a.decodeImageAutomatically(data, extension)
+	if err != nil {
+		return InstanceResponse{Error: fmt.Sprintf("load %s: %v", path, err)}
+	}
+
+	fyne.DoAndWait(func() {
+		a.toolbar.applyLoadedImage(path, imageData)
+	})
+	return InstanceResponse{OK: true}
+}
+
+func (a *Application) automationProcess(rawParams json.RawMessage) InstanceResponse {
+	result := make(chan error, 1)
+
+	fyne.DoAndWait(func() {
+		if a.toolbar.processingInProgress {
+			result <- fmt.Errorf("processing already in progress")
+			return
+		}
+
+		params := a.parameters.GetCurrentParameters()
+		if len(rawParams) > 0 {
+			decoded, err := DecodeParametersJSON(string(rawParams), params)
+			if err != nil {
+				result <- fmt.Errorf("invalid parameters: %w", err)
+				return
+			}
+			a.parameters.SetParameters(decoded)
+			a.parameters.cancelPendingProcessing()
+			params = decoded
+		}
+
+		a.toolbar.runProcessing(params, func(err error) {
+			result <- err
+		})
+	})
+
+	select {
+	case err := <-result:
+		if err != nil {
+			return InstanceResponse{Error: err.Error()}
+		}
+	case <-a.ctx.Done():
+		return InstanceResponse{Error: "application shutting down"}
+	}
+
+	return InstanceResponse{OK: true, Metrics: NewMetricsReport(a.processing.GetProcessedMetrics())}
+}
+
+func (a *Application) automationSave(path string) InstanceResponse {
+	if path == "" {
+		return InstanceResponse{Error: "save requires a path"}
+	}
+
+	processedData := a.processing.GetProcessedImage()
+	if processedData == nil {
+		return InstanceResponse{Error: "no processed image to save"}
+	}
+
+	processedData, crop, release, err := a.croppedForSave(processedData)
+	if err != nil {
+		return InstanceResponse{Error: err.Error()}
+	}
+	defer release()
+
+	writer, err := storage.Writer(storage.NewFileURI(path))
+	if err != nil {
+		return InstanceResponse{Error: fmt.Sprintf("create %s: %v", path, err)}
+	}
+
+	saveErr := SaveImageToWriter(writer, processedData, a.metadata.Metadata())
+	if closeErr := writer.Close(); saveErr == nil && closeErr != nil {
+		saveErr = fmt.Errorf("close %s: %w", path, closeErr)
+	}
+	if saveErr != nil {
+		return InstanceResponse{Error: saveErr.Error()}
+	}
+
+	if sidecar := a.outputSidecar(crop); sidecar != nil {
+		if err := sidecar.Write(path); err != nil {
+			return InstanceResponse{Error: err.Error()}
+		}
+	}
+
+	fyne.Do(func() {
+		a.statusBar.SetStatus(fmt.Sprintf("Saved %s", filepath.Base(path)))
+	})
+	return InstanceResponse{OK: true}
+}
+
+func (a *Application) automationExportMetrics(path string) InstanceResponse {
+	report := NewMetricsReport(a.processing.GetProcessedMetrics())
+	if report == nil {
+		return InstanceResponse{Error: "no metrics available, process an image first"}
+	}
+
+	// Without a path the metrics are only returned in the response.
+	if path != "" {
+		if err := writeMetricsReport(path, report); err != nil {
+			return InstanceResponse{Error: err.Error()}
+		}
+	}
+
+	return InstanceResponse{OK: true, Metrics: report}
+}
+
+// automationRender writes the annotated images of the latest run as
+// <prefix>_<kind>.png.
+func (a *Application) automationRender(prefix string) InstanceResponse {
+	if prefix == "" {
+		return InstanceResponse{Error: "render requires a path prefix"}
+	}
+
+	visuals, err := a.processing.RenderVisuals(nil)
+	if err != nil {
+		return InstanceResponse{Error: err.Error()}
+	}
+	if err := WriteRenderedVisuals(filepath.Dir(prefix), filepath.Base(prefix), visuals); err != nil {
+		return InstanceResponse{Error: err.Error()}
+	}
+	return InstanceResponse{OK: true}
+}
+
+// runRemoteCommand implements `otsu-obliterator remote <command> [args]` and
+// returns the process exit code.
+func runRemoteCommand(args []string) int {
+	if len(args) == 0 {
+		printRemoteUsage()
+		return 2
+	}
+
+	request := InstanceRequest{Command: args[0]}
+	operand := ""
+	if len(args) > 1 {
+		operand = args[1]
+	}
+
+	switch request.Command {
+	case "open":
+		request.Paths = openFileArguments(args[1:])
+	case "load", "save", "export-metrics", "render":
+		if operand != "" {
+			absolute, err := filepath.Abs(operand)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "resolve %s: %v\n", operand, err)
+				return 1
+			}
+			request.Path = absolute
+		}
+	case "process":
+		if operand != "" {
+			data, err := os.ReadFile(operand)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "read parameters: %v\n", err)
+				return 1
+			}
+			request.Parameters = json.RawMessage(data)
+		}
+	default:
+		printRemoteUsage()
+		return 2
+	}
+
+	response, err := sendInstanceRequestWithTimeout(request, automationTimeout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+
+	if !response.OK {
+		fmt.Fprintf(os.Stderr, "%s failed: %s\n", request.Command, response.Error)
+		return 1
+	}
+
+	if response.Metrics != nil {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(response.Metrics)
+	}
+	return 0
+}
+
+func printRemoteUsage() {
+	fmt.Fprintf(os.Stderr, `Usage: otsu-obliterator remote <command> [argument]
+
+Commands (sent to the running instance):
+  open <file>...             Open files in new document windows
+  load <file>                Load an image into the main window
+  process [params.json]      Process the loaded image, optionally with parameters
+  save <file>                Save the processed image (.png, .jpg or .tif)
+  export-metrics [file]      Print metrics as JSON, optionally writing them to file
+  render <prefix>            Write the comparison, decision and density images as <prefix>_<kind>.png
+`)
+}
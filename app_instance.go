@@ -0,0 +1,279 @@
+//go:build !nogui
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"fyne.io/fyne/v2"
+)
+
+const (
+	instanceDialTimeout = 500 * time.Millisecond
+	instanceIOTimeout   = 5 * time.Second
+
+	// newInstanceFlag bypasses forwarding and starts an independent process.
+	newInstanceFlag = "--new-instance"
+)
+
+// InstanceRequest is one line of JSON sent over the instance socket.
+type InstanceRequest struct {
+	Command    string          `json:"command"`
+	Paths      []string        `json:"paths,omitempty"`
+	Path       string          `json:"path,omitempty"`
+	Parameters json.RawMessage `json:"parameters,omitempty"`
+}
+
+// InstanceResponse acknowledges an InstanceRequest.
+type InstanceResponse struct {
+	OK      bool           `json:"ok"`
+	Error   string         `json:"error,omitempty"`
+	Metrics *MetricsReport `json:"metrics,omitempty"`
+}
+
+// instanceSocketDir returns the directory holding the instance socket,
+// otsu-obliterator under $XDG_RUNTIME_DIR or otsu-obliterator-instance in
+// the user cache directory, next to the artifact cache so clearing the
+// cache keeps the socket. It is created mode 0700, so only its owner can
+// reach the socket.
+func instanceSocketDir() (string, error) {
+	var dir string
+	if runtimeDir := os.Getenv("XDG_RUNTIME_DIR"); runtimeDir != "" {
+		dir = filepath.Join(runtimeDir, "otsu-obliterator")
+	} else {
+		cacheDir, err := os.UserCacheDir()
+		if err != nil {
+			return "", fmt.Errorf("locate instance socket directory: %w", err)
+		}
+		dir = filepath.Join(cacheDir, "otsu-obliterator-instance")
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("create instance socket directory: %w", err)
+	}
+
+	// MkdirAll keeps the mode of a directory that already exists.
+	info, err := checkInstancePathOwner(dir)
+	if err != nil {
+		return "", err
+	}
+	if info.Mode().Perm()&0077 != 0 {
+		if err := os.Chmod(dir, 0700); err != nil {
+			return "", fmt.Errorf("restrict instance socket directory: %w", err)
+		}
+	}
+	return dir, nil
+}
+
+func instanceSocketPath() (string, error) {
+	dir, err := instanceSocketDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "instance.sock"), nil
+}
+
+func hasArgument(args []string, flag string) bool {
+	for _, arg := range args {
+		if arg == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardToRunningInstance hands paths to an already running instance. It
+// returns false when no instance answers, in which case the caller should
+// start normally.
+func forwardToRunningInstance(paths []string) bool {
+	response, err := sendInstanceRequest(InstanceRequest{Command: "open", Paths: paths})
+	if err != nil {
+		return false
+	}
+
+	if !response.OK {
+		fmt.Fprintf(os.Stderr, "running instance rejected request: %s\n", response.Error)
+	}
+	return true
+}
+
+func sendInstanceRequest(request InstanceRequest) (*InstanceResponse, error) {
+	return sendInstanceRequestWithTimeout(request, instanceIOTimeout)
+}
+
+func sendInstanceRequestWithTimeout(request InstanceRequest, timeout time.Duration) (*InstanceResponse, error) {
+	socketPath, err := instanceSocketPath()
+	if err != nil {
+		return nil, fmt.Errorf("connect to running instance: %w", err)
+	}
+	if _, err := checkInstancePathOwner(socketPath); err != nil {
+		return nil, fmt.Errorf("connect to running instance: %w", err)
+	}
+
+	conn, err := net.DialTimeout("unix", socketPath, instanceDialTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("connect to running instance: %w", err)
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	if err := json.NewEncoder(conn).Encode(request); err != nil {
+		return nil, fmt.Errorf("send instance request: %w", err)
+	}
+
+	var response InstanceResponse
+	if err := json.NewDecoder(conn).Decode(&response); err != nil {
+		return nil, fmt.Errorf("read instance response: %w", err)
+	}
+
+	return &response, nil
+}
+
+// startInstanceListener accepts requests from later launches until ctx is
+// cancelled. A socket file left behind by a crashed process is replaced.
+func (a *Application) startInstanceListener() error {
+	socketPath, err := instanceSocketPath()
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(socketPath); err == nil {
+		if conn, dialErr := net.DialTimeout("unix", socketPath, instanceDialTimeout); dialErr == nil {
+			conn.Close()
+			return fmt.Errorf("instance socket %s already in use", socketPath)
+		}
+		os.Remove(socketPath)
+	}
+
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return fmt.Errorf("listen on instance socket: %w", err)
+	}
+
+	go func() {
+		<-a.ctx.Done()
+		listener.Close()
+		os.Remove(socketPath)
+	}()
+
+	go a.acceptInstanceRequests(a.ctx, listener)
+
+	a.debugSystem.logger.Info("instance listener started", "socket", socketPath)
+	return nil
+}
+
+func (a *Application) acceptInstanceRequests(ctx context.Context, listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) || ctx.Err() != nil {
+				return
+			}
+			a.debugSystem.logger.Warn("instance accept failed", "error", err.Error())
+			continue
+		}
+
+		go a.serveInstanceConnection(conn)
+	}
+}
+
+func (a *Application) serveInstanceConnection(conn net.Conn) {
+	defer conn.Close()
+
+	// Commands load, save and render files with this process's rights, so
+	// only processes of the same user are served.
+	if err := checkInstancePeer(conn); err != nil {
+		a.debugSystem.logger.Warn("instance request refused", "error", err.Error())
+		conn.SetWriteDeadline(time.Now().Add(instanceIOTimeout))
+		json.NewEncoder(conn).Encode(InstanceResponse{Error: err.Error()})
+		return
+	}
+
+	conn.SetReadDeadline(time.Now().Add(instanceIOTimeout))
+
+	var request InstanceRequest
+	if err := json.NewDecoder(bufio.NewReader(conn)).Decode(&request); err != nil {
+		conn.SetWriteDeadline(time.Now().Add(instanceIOTimeout))
+		json.NewEncoder(conn).Encode(InstanceResponse{Error: fmt.Sprintf("decode request: %v", err)})
+		return
+	}
+
+	// Commands such as process can outlast the I/O timeout, so the write
+	// deadline starts once the command has finished.
+	response := a.handleInstanceRequest(request)
+	conn.SetWriteDeadline(time.Now().Add(instanceIOTimeout))
+	json.NewEncoder(conn).Encode(response)
+}
+
+// errPeerCredentialsUnsupported is returned by instancePeerUID on platforms
+// whose sockets do not report the peer's user.
+var errPeerCredentialsUnsupported = errors.New("peer credentials are not supported on this platform")
+
+// checkInstancePeer refuses a connection from a process of another user.
+// Where the platform cannot tell, the private socket directory is the only
+// guard.
+func checkInstancePeer(conn net.Conn) error {
+	uid, err := instancePeerUID(conn)
+	if errors.Is(err, errPeerCredentialsUnsupported) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if uid != os.Getuid() {
+		return fmt.Errorf("client runs as uid %d, not as the instance's user", uid)
+	}
+	return nil
+}
+
+// instanceSyscallConn exposes the descriptor of a unix socket connection
+// for reading its peer credentials.
+func instanceSyscallConn(conn net.Conn) (syscall.RawConn, error) {
+	unixConn, ok := conn.(*net.UnixConn)
+	if !ok {
+		return nil, fmt.Errorf("peer credentials need a unix socket, got %T", conn)
+	}
+	raw, err := unixConn.SyscallConn()
+	if err != nil {
+		return nil, fmt.Errorf("read peer credentials: %w", err)
+	}
+	return raw, nil
+}
+
+func (a *Application) handleInstanceRequest(request InstanceRequest) InstanceResponse {
+	a.debugSystem.logger.Info("instance request received",
+		"command", request.Command,
+		"paths", len(request.Paths),
+	)
+
+	switch request.Command {
+	case "open":
+		fyne.Do(func() {
+			a.window.RequestFocus()
+			a.OpenFiles(request.Paths)
+		})
+		return InstanceResponse{OK: true}
+	case "load":
+		return a.automationLoad(request.Path)
+	case "process":
+		return a.automationProcess(request.Parameters)
+	case "save":
+		return a.automationSave(request.Path)
+	case "export-metrics":
+		return a.automationExportMetrics(request.Path)
+	case "render":
+		return a.automationRender(request.Path)
+	default:
+		return InstanceResponse{Error: fmt.Sprintf("unknown command %q", request.Command)}
+	}
+}
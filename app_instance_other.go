@@ -0,0 +1,12 @@
+//go:build !nogui && !unix
+
+package main
+
+import "os"
+
+// checkInstancePathOwner returns the file information of path. Without unix
+// ownership the socket relies on the user cache directory being private to
+// its user, as it is under the Windows profile.
+func checkInstancePathOwner(path string) (os.FileInfo, error) {
+	return os.Lstat(path)
+}
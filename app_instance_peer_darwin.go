@@ -0,0 +1,31 @@
+//go:build !nogui
+
+package main
+
+import (
+	"fmt"
+	"net"
+
+	"golang.org/x/sys/unix"
+)
+
+// instancePeerUID returns the effective user id of the process at the other
+// end of conn, as the kernel recorded it when the process connected.
+func instancePeerUID(conn net.Conn) (int, error) {
+	raw, err := instanceSyscallConn(conn)
+	if err != nil {
+		return -1, err
+	}
+
+	var cred *unix.Xucred
+	var credErr error
+	if err := raw.Control(func(fd uintptr) {
+		cred, credErr = unix.GetsockoptXucred(int(fd), unix.SOL_LOCAL, unix.LOCAL_PEERCRED)
+	}); err != nil {
+		return -1, fmt.Errorf("read peer credentials: %w", err)
+	}
+	if credErr != nil {
+		return -1, fmt.Errorf("read peer credentials: %w", credErr)
+	}
+	return int(cred.Uid), nil
+}
@@ -0,0 +1,30 @@
+//go:build !nogui
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+)
+
+// instancePeerUID returns the user id of the process at the other end of
+// conn, as the kernel recorded it when the process connected.
+func instancePeerUID(conn net.Conn) (int, error) {
+	raw, err := instanceSyscallConn(conn)
+	if err != nil {
+		return -1, err
+	}
+
+	var cred *syscall.Ucred
+	var credErr error
+	if err := raw.Control(func(fd uintptr) {
+		cred, credErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	}); err != nil {
+		return -1, fmt.Errorf("read peer credentials: %w", err)
+	}
+	if credErr != nil {
+		return -1, fmt.Errorf("read peer credentials: %w", credErr)
+	}
+	return int(cred.Uid), nil
+}
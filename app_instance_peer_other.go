@@ -0,0 +1,11 @@
+//go:build !nogui && !linux && !darwin
+
+package main
+
+import "net"
+
+// instancePeerUID cannot ask the kernel for the peer on this platform, so
+// the socket relies on its directory being private to the user.
+func instancePeerUID(conn net.Conn) (int, error) {
+	return -1, errPeerCredentialsUnsupported
+}
@@ -0,0 +1,28 @@
+//go:build !nogui && unix
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// checkInstancePathOwner returns the file information of path after making
+// sure the current user owns it, so the instance never talks through a
+// socket or directory another user planted.
+func checkInstancePathOwner(path string) (os.FileInfo, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil, fmt.Errorf("%s: owner unknown", path)
+	}
+	if int(stat.Uid) != os.Getuid() {
+		return nil, fmt.Errorf("%s is owned by uid %d, not by the current user", path, stat.Uid)
+	}
+	return info, nil
+}
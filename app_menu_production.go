@@ -1,4 +1,4 @@
-//go:build !debug
+//go:build !debug && !nogui
 
 package main
 
@@ -7,5 +7,7 @@ import "fyne.io/fyne/v2"
 func (a *Application) buildHelpMenu() *fyne.Menu {
 	return fyne.NewMenu("Help",
 		fyne.NewMenuItem("About", a.showAbout),
+		fyne.NewMenuItem("Take the Tour", a.showTour),
+		a.buildSampleMenuItem(),
 	)
 }
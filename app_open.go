@@ -0,0 +1,98 @@
+//go:build !nogui
+
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+)
+
+// openFileArguments returns the image paths passed on the command line.
+// Flags, including the -psn_ process serial number older macOS versions
+// append for Finder launches, are skipped.
+func openFileArguments(args []string) []string {
+	var paths []string
+	for _, arg := range args {
+		if arg == "" || strings.HasPrefix(arg, "-") {
+			continue
+		}
+		if absolute, err := filepath.Abs(arg); err == nil {
+			arg = absolute
+		}
+		paths = append(paths, arg)
+	}
+	return paths
+}
+
+// OpenFiles loads each path into a document window and processes it with the
+// default parameters. The current window is reused while it is still empty;
+// every other file gets a window of its own.
+func (a *Application) OpenFiles(paths []string) {
+	for i, path := range paths {
+		target := a
+		if i > 0 || a.processing.GetOriginalImage() != nil {
+			target = a.newDocumentWindow()
+		}
+		target.openFile(path)
+	}
+}
+
+func (a *Application) openFile(path string) {
+	a.statusBar.SetStatus(fmt.Sprintf("Loading %s...", filepath.Base(path)))
+
+	showError := func(err error) {
+		debugSystem := GetDebugSystem()
+		debugSystem.logger.Error("open file failed", "path", path, "error", err.Error())
+
+		dialog.ShowError(err, a.window)
+		a.statusBar.SetStatus("Load failed")
+	}
+
+	go func() {
+		data, extension, err := readImageFile(path)
+		if err != nil {
+			fyne.Do(func() {
+				showError(err)
+			})
+			return
+		}
+
+		fyne.Do(func() {
+			a.openImageData(path, data, extension)
+		})
+	}()
+}
+
+// openImageData decodes an image read from name into this window with the
+// parameters reset to their defaults.
+func (a *Application) openImageData(name string, data []byte, extension string) {
+	a.decodeImage(data, extension, func(imageData *ImageData, err error) {
+		if err != nil {
+			GetDebugSystem().logger.Error("open file failed", "path", name, "error", err.Error())
+			dialog.ShowError(fmt.Errorf("load %s: %w", name, err), a.window)
+			a.statusBar.SetStatus("Load failed")
+			return
+		}
+		if imageData == nil {
+			return
+		}
+
+		a.toolbar.applyLoadedImage(name, imageData)
+		a.parameters.SetParameters(DefaultOtsuParameters())
+	})
+}
+
+func readImageFile(path string) ([]byte, string, error) {
+	reader, err := storage.Reader(storage.NewFileURI(path))
+	if err != nil {
+		return nil, "", fmt.Errorf("open %s: %w", path, err)
+	}
+	defer reader.Close()
+
+	return ReadImageBytes(reader)
+}
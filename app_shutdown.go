@@ -0,0 +1,38 @@
+//go:build !nogui
+
+package main
+
+import "fyne.io/fyne/v2"
+
+// shutdown cancels the window's work, then waits in the background for
+// running processing to finish, up to shutdownDrainTimeout, before cleaning
+// up and calling done on the UI thread. Later calls do nothing.
+func (a *Application) shutdown(done func()) {
+	if a.closing {
+		return
+	}
+	a.closing = true
+
+	if a.toolbar != nil {
+		a.toolbar.CancelCurrentProcessing()
+	}
+	if a.parameters != nil {
+		a.parameters.cancelPendingProcessing()
+	}
+	a.cancel()
+
+	go func() {
+		drained := waitForProcessingWorkers(shutdownDrainTimeout)
+		fyne.Do(func() {
+			a.cleanup(drained)
+			done()
+		})
+	}()
+}
+
+// Quit shuts down like closing the window does and then quits the app, as
+// on SIGTERM.
+func (a *Application) Quit() {
+	a.window.Hide()
+	a.shutdown(a.fyneApp.Quit)
+}
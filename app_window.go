@@ -0,0 +1,43 @@
+//go:build !nogui
+
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// windowSequence numbers document windows for their titles. It is only
+// touched from menu callbacks, which Fyne runs on the UI goroutine.
+var windowSequence = 1
+
+func (a *Application) openNewWindow() {
+	a.newDocumentWindow()
+}
+
+// newDocumentWindow creates an independent document window with its own
+// image, parameters and processing engine. Theme, preferences and the debug
+// system stay shared through the Fyne app and the master window.
+func (a *Application) newDocumentWindow() *Application {
+	windowSequence++
+
+	window := a.fyneApp.NewWindow(fmt.Sprintf("%s (%d)", AppName, windowSequence))
+	ctx, cancel := context.WithCancel(a.ctx)
+
+	document := &Application{
+		fyneApp:     a.fyneApp,
+		window:      window,
+		ctx:         ctx,
+		cancel:      cancel,
+		debugSystem: a.debugSystem,
+	}
+	document.buildDocument()
+
+	a.debugSystem.logger.Info("document window opened",
+		"window", windowSequence,
+	)
+
+	window.Show()
+
+	return document
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// BatchCheckpoint records the outcome of every finished batch item so an
+// interrupted run can be resumed. It is rewritten after each item.
+type BatchCheckpoint struct {
+	AppVersion    string          `json:"app_version"`
+	ParameterHash string          `json:"parameter_hash"`
+	Parameters    *OtsuParameters `json:"parameters"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+
+	// Entries are keyed by input path as given to the batch.
+	Entries map[string]*ManifestEntry `json:"entries"`
+
+	path string
+}
+
+func NewBatchCheckpoint(path string, params *OtsuParameters, parameterHash string) *BatchCheckpoint {
+	return &BatchCheckpoint{
+		AppVersion:    AppVersion,
+		ParameterHash: parameterHash,
+		Parameters:    params,
+		Entries:       make(map[string]*ManifestEntry),
+		path:          path,
+	}
+}
+
+// LoadBatchCheckpoint reads the checkpoint at path and checks it was written
+// for the same parameter set, since outputs of other parameters cannot be
+// reused.
+func LoadBatchCheckpoint(path, parameterHash string) (*BatchCheckpoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read checkpoint: %w", err)
+	}
+
+	checkpoint := &BatchCheckpoint{}
+	if err := json.Unmarshal(data, checkpoint); err != nil {
+		return nil, fmt.Errorf("decode checkpoint %s: %w", path, err)
+	}
+	if checkpoint.ParameterHash != parameterHash {
+		return nil, fmt.Errorf("checkpoint %s was written with different parameters (hash %s, now %s)",
+			path, checkpoint.ParameterHash, parameterHash)
+	}
+
+	if checkpoint.Entries == nil {
+		checkpoint.Entries = make(map[string]*ManifestEntry)
+	}
+	checkpoint.path = path
+	return checkpoint, nil
+}
+
+// Completed returns the recorded entry for item when it succeeded and both
+// its source and its output are unchanged on disk.
+func (bc *BatchCheckpoint) Completed(item BatchItem) (*ManifestEntry, bool) {
+	entry, ok := bc.Entries[item.Input]
+	if !ok || entry.Status != manifestStatusOK || !matchesPlannedOutput(item.Output, entry.Output) {
+		return nil, false
+	}
+
+	if !fileHasSHA256(item.Input, entry.SourceSHA256) || !fileHasSHA256(entry.Output, entry.OutputSHA256) {
+		return nil, false
+	}
+	return entry, true
+}
+
+// Record stores the outcome of an item and saves the checkpoint.
+func (bc *BatchCheckpoint) Record(entry *ManifestEntry) error {
+	bc.Entries[entry.Source] = entry
+	bc.UpdatedAt = time.Now().UTC()
+	return bc.save()
+}
+
+// save writes through a temporary file so an interruption mid-write leaves
+// the previous checkpoint intact.
+func (bc *BatchCheckpoint) save() error {
+	data, err := json.MarshalIndent(bc, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode checkpoint: %w", err)
+	}
+
+	temporary := bc.path + ".tmp"
+	if err := os.WriteFile(temporary, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("write checkpoint: %w", err)
+	}
+	if err := os.Rename(temporary, bc.path); err != nil {
+		return fmt.Errorf("write checkpoint: %w", err)
+	}
+	return nil
+}
+
+func fileHasSHA256(path, expected string) bool {
+	if expected == "" {
+		return false
+	}
+
+	sum, err := fileSHA256(path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			GetDebugSystem().logger.Warn("checkpoint hash check failed", "path", path, "error", err.Error())
+		}
+		return false
+	}
+	return sum == expected
+}
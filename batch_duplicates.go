@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Duplicate policies decide what a batch does with inputs that look like an
+// earlier input.
+const (
+	DuplicatesWarn   = "warn"
+	DuplicatesUnique = "unique"
+	DuplicatesOff    = "off"
+)
+
+const manifestStatusDuplicate = "duplicate"
+
+func validateDuplicatePolicy(policy string) error {
+	switch policy {
+	case DuplicatesWarn, DuplicatesUnique, DuplicatesOff:
+		return nil
+	}
+	return fmt.Errorf("unknown duplicate policy %q: expected %s, %s or %s",
+		policy, DuplicatesWarn, DuplicatesUnique, DuplicatesOff)
+}
+
+// DuplicateGroup is an input and the later inputs that repeat it, byte for
+// byte (distance 0 with Identical set) or to within NearDuplicateDistance
+// perceptual hash bits.
+type DuplicateGroup struct {
+	Original   BatchItem
+	Duplicates []DuplicateItem
+}
+
+type DuplicateItem struct {
+	Item      BatchItem
+	Distance  int
+	Identical bool
+}
+
+// FindDuplicates hashes every item and groups the repeats under the first
+// input they look like. With the unique policy, Run then processes only
+// that first input and maps the repeats to its output. Inputs that cannot
+// be hashed are left to fail when they are processed.
+func (br *BatchRunner) FindDuplicates(ctx context.Context, items []BatchItem) []DuplicateGroup {
+	hashes := make([]*ImageHashes, len(items))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for worker := 0; worker < workerLimit(0); worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range jobs {
+				hashed, err := HashImageFile(items[index].Input)
+				if err != nil {
+					GetDebugSystem().logger.Debug("batch input not hashed", "input", items[index].Input, "error", err.Error())
+					continue
+				}
+				hashes[index] = hashed
+			}
+		}()
+	}
+	for index := range items {
+		if ctx.Err() != nil {
+			break
+		}
+		jobs <- index
+	}
+	close(jobs)
+	wg.Wait()
+
+	var groups []DuplicateGroup
+	var originals []*ImageHashes
+	matches := make(map[string]duplicateMatch)
+	for index, hashed := range hashes {
+		if hashed == nil {
+			continue
+		}
+		matched := false
+		for g, original := range originals {
+			duplicate := DuplicateItem{Item: items[index], Identical: original.SHA256 == hashed.SHA256}
+			duplicate.Distance = original.Perceptual.Distance(hashed.Perceptual)
+			if duplicate.Identical || duplicate.Distance <= NearDuplicateDistance {
+				groups[g].Duplicates = append(groups[g].Duplicates, duplicate)
+				matches[items[index].Input] = duplicateMatch{original: groups[g].Original.Input, hashes: hashed}
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			groups = append(groups, DuplicateGroup{Original: items[index]})
+			originals = append(originals, hashed)
+		}
+	}
+
+	if br.config.Duplicates == DuplicatesUnique {
+		br.duplicateOf = matches
+	}
+
+	var repeated []DuplicateGroup
+	for _, group := range groups {
+		if len(group.Duplicates) > 0 {
+			repeated = append(repeated, group)
+		}
+	}
+	return repeated
+}
+
+// duplicateMatch is the input an item repeats, with the item's own hashes.
+type duplicateMatch struct {
+	original string
+	hashes   *ImageHashes
+}
+
+// duplicateResult records item as a repeat of the input whose result is
+// original, pointing its entry at the same output.
+func duplicateResult(item BatchItem, match duplicateMatch, original *BatchResult) *BatchResult {
+	entry := &ManifestEntry{
+		Source:        item.Input,
+		Output:        original.Entry.Output,
+		OutputSHA256:  original.Entry.OutputSHA256,
+		OutputPHash:   original.Entry.OutputPHash,
+		Algorithm:     original.Entry.Algorithm,
+		ParameterHash: original.Entry.ParameterHash,
+		AppVersion:    AppVersion,
+		StartedAt:     original.Entry.FinishedAt,
+		FinishedAt:    original.Entry.FinishedAt,
+		SourceSHA256:  match.hashes.SHA256,
+		SourcePHash:   match.hashes.Perceptual.String(),
+		Status:        manifestStatusDuplicate,
+		DuplicateOf:   original.Item.Input,
+	}
+	if original.Err != nil {
+		entry.Error = "the input it repeats failed: " + original.Err.Error()
+	}
+	return &BatchResult{Item: item, Entry: entry, DuplicateOf: original.Item.Input}
+}
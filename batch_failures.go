@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Failure categories recorded for batch items.
+const (
+	FailureRead            = "read"
+	FailureDecode          = "decode"
+	FailureDimensions      = "dimensions"
+	FailureParameters      = "parameters"
+	FailureMemory          = "memory"
+	FailureTimeout         = "timeout"
+	FailurePageNotDetected = "page_not_detected"
+	FailureWrite           = "write"
+	FailureCancelled       = "cancelled"
+	FailureUncategorized   = "other"
+)
+
+// Retry actions a BatchRetryPolicy can apply to a failure category.
+const (
+	RetryAgain     = "retry"
+	RetryTiled     = "tiled"
+	RetryDownscale = "downscale"
+)
+
+// DefaultRetryRules retries out-of-memory and timed-out images with the
+// per-region method, and dimension errors at half the working size.
+const DefaultRetryRules = "memory=tiled,timeout=tiled,dimensions=downscale"
+
+var failureRemediations = map[string]string{
+	FailureRead:            "check that the file exists and is readable",
+	FailureDecode:          "the file is damaged or not a PNG, JPEG or TIFF image; re-export it from the source",
+	FailureDimensions:      "the image is too large or too small for the parameters; lower -max-megapixels or the window size",
+	FailureParameters:      "fix the parameter set; see the error for the invalid field",
+	FailureMemory:          "lower -jobs or -memory-budget, lower -max-megapixels, or use the region-adaptive method",
+	FailureTimeout:         "raise -timeout or use a faster method",
+	FailurePageNotDetected: "place the page corners by hand in the GUI or turn off perspective correction",
+	FailureWrite:           "check free disk space and permissions of the output directory",
+	FailureCancelled:       "the run was interrupted; rerun with -resume",
+	FailureUncategorized:   "see the error message and the log",
+}
+
+// batchStageError tags an error with the category of the stage it came
+// from, used when nothing more specific matches.
+type batchStageError struct {
+	category string
+	err      error
+}
+
+func (e *batchStageError) Error() string {
+	return e.err.Error()
+}
+
+func (e *batchStageError) Unwrap() error {
+	return e.err
+}
+
+func stageError(category string, err error) error {
+	return &batchStageError{category: category, err: err}
+}
+
+// classifyBatchError picks the failure category of err.
+func classifyBatchError(err error) string {
+	var timeoutErr *TimeoutError
+	var validationErr *ValidationError
+	var diagnosticErr *ImageDiagnosticError
+	var stageErr *batchStageError
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		return FailureCancelled
+	case errors.As(err, &timeoutErr), errors.Is(err, context.DeadlineExceeded):
+		return FailureTimeout
+	case errors.Is(err, ErrPageNotDetected):
+		return FailurePageNotDetected
+	case isOutOfMemory(err):
+		return FailureMemory
+	case errors.As(err, &validationErr):
+		if validationErr.Field == "dimensions" || validationErr.Field == "WindowSize" {
+			return FailureDimensions
+		}
+		return FailureParameters
+	case errors.As(err, &diagnosticErr):
+		return FailureDecode
+	case errors.As(err, &stageErr):
+		return stageErr.category
+	}
+	return FailureUncategorized
+}
+
+// isOutOfMemory recognizes allocation failures, which OpenCV reports as
+// errors or panics carrying its own messages.
+func isOutOfMemory(err error) bool {
+	message := strings.ToLower(err.Error())
+	for _, marker := range []string{"insufficient memory", "out of memory", "bad_alloc", "failed to allocate"} {
+		if strings.Contains(message, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// BatchRetryPolicy decides whether and how a failed item is tried again.
+type BatchRetryPolicy struct {
+	// MaxRetries is the number of extra attempts per item.
+	MaxRetries int
+
+	// Actions maps failure categories to the retry action applied to them;
+	// other categories are not retried.
+	Actions map[string]string
+}
+
+// ParseRetryRules reads rules such as "memory=tiled,write=retry".
+func ParseRetryRules(rules string) (map[string]string, error) {
+	actions := make(map[string]string)
+	for _, rule := range strings.Split(rules, ",") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+
+		category, action, ok := strings.Cut(rule, "=")
+		if !ok {
+			return nil, fmt.Errorf("retry rule %q: expected category=action", rule)
+		}
+		if _, known := failureRemediations[category]; !known || category == FailureCancelled {
+			return nil, fmt.Errorf("retry rule %q: unknown failure category %q", rule, category)
+		}
+		switch action {
+		case RetryAgain, RetryTiled, RetryDownscale:
+		default:
+			return nil, fmt.Errorf("retry rule %q: action must be %s, %s or %s", rule, RetryAgain, RetryTiled, RetryDownscale)
+		}
+
+		actions[category] = action
+	}
+	return actions, nil
+}
+
+// batchAttempt holds what one attempt at an item runs with.
+type batchAttempt struct {
+	params        *OtsuParameters
+	maxMegapixels float64
+}
+
+// next returns the attempt to make after a failure of category, or false
+// when the policy gives up.
+func (rp *BatchRetryPolicy) next(attempt batchAttempt, retries int, category string) (batchAttempt, string, bool) {
+	if rp == nil || retries >= rp.MaxRetries {
+		return attempt, "", false
+	}
+
+	action, ok := rp.Actions[category]
+	if !ok {
+		return attempt, "", false
+	}
+
+	switch action {
+	case RetryTiled:
+		if attempt.params.RegionAdaptiveThresholding {
+			return attempt, "", false
+		}
+		params := *attempt.params
+		params.RegionAdaptiveThresholding = true
+		params.MultiScaleProcessing = false
+		params.NeuralBinarization = false
+		params.Otsu3D = false
+		params.EntropyMethod = ""
+		attempt.params = &params
+	case RetryDownscale:
+		if attempt.maxMegapixels <= 0 {
+			attempt.maxMegapixels = DefaultMaxWorkingMegapixels
+		}
+		attempt.maxMegapixels /= 2
+	}
+	return attempt, action, true
+}
+
+// BatchFailureReport groups the failed items of a run by category.
+type BatchFailureReport struct {
+	Failed     int                     `json:"failed"`
+	Total      int                     `json:"total"`
+	Categories []*BatchFailureCategory `json:"categories"`
+}
+
+type BatchFailureCategory struct {
+	Category    string          `json:"category"`
+	Remediation string          `json:"remediation"`
+	Items       []*BatchFailure `json:"items"`
+}
+
+type BatchFailure struct {
+	Source   string `json:"source"`
+	Error    string `json:"error"`
+	Attempts int    `json:"attempts"`
+}
+
+func NewBatchFailureReport(results []*BatchResult, total int) *BatchFailureReport {
+	report := &BatchFailureReport{Total: total}
+	byCategory := make(map[string]*BatchFailureCategory)
+
+	for _, result := range results {
+		if result.Err == nil {
+			continue
+		}
+		report.Failed++
+
+		category := result.Entry.FailureCategory
+		group, ok := byCategory[category]
+		if !ok {
+			group = &BatchFailureCategory{Category: category, Remediation: failureRemediations[category]}
+			byCategory[category] = group
+			report.Categories = append(report.Categories, group)
+		}
+		group.Items = append(group.Items, &BatchFailure{
+			Source:   result.Item.Input,
+			Error:    result.Err.Error(),
+			Attempts: result.Entry.Attempts,
+		})
+	}
+
+	sort.Slice(report.Categories, func(i, j int) bool {
+		if len(report.Categories[i].Items) != len(report.Categories[j].Items) {
+			return len(report.Categories[i].Items) > len(report.Categories[j].Items)
+		}
+		return report.Categories[i].Category < report.Categories[j].Category
+	})
+	return report
+}
+
+func (fr *BatchFailureReport) Write(path string) error {
+	data, err := json.MarshalIndent(fr, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode failure report: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("write failure report %s: %w", path, err)
+	}
+	return nil
+}
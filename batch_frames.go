@@ -0,0 +1,417 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gocv.io/x/gocv"
+)
+
+// DefaultSequenceFPS is used for image sequences, which carry no frame rate.
+const DefaultSequenceFPS = 24.0
+
+// FrameSequenceConfig describes a frame-by-frame binarization of a video file
+// or an image sequence.
+type FrameSequenceConfig struct {
+	// Input is a video file, a multi-page TIFF file, a directory of
+	// images, or a glob pattern.
+	Input string
+	// Output is a video file when it has a video extension, a multi-page
+	// Group 4 TIFF file when it has a TIFF extension, otherwise a directory
+	// that receives numbered PNG frames.
+	Output string
+	Params *OtsuParameters
+	FPS    float64
+}
+
+// FrameProgress reports a finished frame; total is 0 when the source does not
+// know its length up front.
+type FrameProgress func(done, total int)
+
+type frameSource interface {
+	Next(frame *gocv.Mat) (bool, error)
+	Total() int
+	FPS() float64
+	Close()
+}
+
+type frameSink interface {
+	Write(index int, frame gocv.Mat) error
+	Close() error
+}
+
+func isTIFFPath(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".tif", ".tiff":
+		return true
+	}
+	return false
+}
+
+func isVideoPath(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".mp4", ".avi", ".mov", ".mkv", ".m4v":
+		return true
+	}
+	return false
+}
+
+// ProcessFrameSequence binarizes every frame with config.Params. It returns
+// the number of frames written; on cancellation the output holds the frames
+// finished so far.
+func ProcessFrameSequence(ctx context.Context, config *FrameSequenceConfig, progress FrameProgress) (int, error) {
+	source, err := openFrameSource(config.Input)
+	if err != nil {
+		return 0, err
+	}
+	defer source.Close()
+
+	fps := config.FPS
+	if fps <= 0 {
+		fps = source.FPS()
+	}
+
+	debugSystem := GetDebugSystem()
+	debugSystem.logger.Info("frame sequence started",
+		"input", config.Input,
+		"output", config.Output,
+		"frames", source.Total(),
+		"fps", fps,
+	)
+
+	frame := gocv.NewMat()
+	defer frame.Close()
+
+	var sink frameSink
+	defer func() {
+		if sink != nil {
+			sink.Close()
+		}
+	}()
+
+	written := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return written, err
+		}
+
+		ok, err := source.Next(&frame)
+		if err != nil {
+			return written, err
+		}
+		if !ok {
+			break
+		}
+
+		binary, err := binarizeFrame(ctx, frame, config.Params)
+		if err != nil {
+			return written, fmt.Errorf("frame %d: %w", written+1, err)
+		}
+
+		if sink == nil {
+			sink, err = openFrameSink(config.Output, fps, binary.Cols(), binary.Rows())
+			if err != nil {
+				binary.Close()
+				return written, err
+			}
+		}
+
+		err = sink.Write(written, binary)
+		binary.Close()
+		if err != nil {
+			return written, fmt.Errorf("frame %d: %w", written+1, err)
+		}
+
+		written++
+		if progress != nil {
+			progress(written, source.Total())
+		}
+	}
+
+	if sink == nil {
+		return 0, fmt.Errorf("frame sequence: %s contains no frames", config.Input)
+	}
+
+	err = sink.Close()
+	sink = nil
+	if err != nil {
+		return written, err
+	}
+
+	debugSystem.logger.Info("frame sequence complete", "frames", written)
+	return written, nil
+}
+
+func binarizeFrame(ctx context.Context, frame gocv.Mat, params *OtsuParameters) (gocv.Mat, error) {
+	engine := NewProcessingEngine()
+	defer engine.Close()
+
+	engine.SetOriginalImage(&ImageData{
+		Mat:         frame.Clone(),
+		Width:       frame.Cols(),
+		Height:      frame.Rows(),
+		Channels:    frame.Channels(),
+		Format:      "frame",
+		ScaleFactor: 1,
+	})
+
+	processed, _, err := engine.ProcessImageWithTimeout(ctx, params)
+	if err != nil {
+		return gocv.NewMat(), err
+	}
+
+	return processed.Mat.Clone(), nil
+}
+
+func openFrameSource(input string) (frameSource, error) {
+	if strings.ContainsAny(input, "*?[") {
+		matches, err := filepath.Glob(input)
+		if err != nil {
+			return nil, fmt.Errorf("frame pattern %q: %w", input, err)
+		}
+		return newImageSequenceSource(matches, input)
+	}
+
+	info, err := os.Stat(input)
+	if err != nil {
+		return nil, fmt.Errorf("frame input: %w", err)
+	}
+
+	if info.IsDir() {
+		entries, err := os.ReadDir(input)
+		if err != nil {
+			return nil, fmt.Errorf("frame input: %w", err)
+		}
+		var paths []string
+		for _, entry := range entries {
+			if !entry.IsDir() && isSupportedImagePath(entry.Name()) {
+				paths = append(paths, filepath.Join(input, entry.Name()))
+			}
+		}
+		return newImageSequenceSource(paths, input)
+	}
+
+	if isTIFFPath(input) {
+		return newTIFFPageSource(input)
+	}
+
+	capture, err := gocv.VideoCaptureFile(input)
+	if err != nil || !capture.IsOpened() {
+		if err == nil {
+			capture.Close()
+			err = fmt.Errorf("unsupported or unreadable video")
+		}
+		return nil, fmt.Errorf("open video %s: %w", input, err)
+	}
+	return &videoSource{capture: capture}, nil
+}
+
+type videoSource struct {
+	capture *gocv.VideoCapture
+}
+
+func (vs *videoSource) Next(frame *gocv.Mat) (bool, error) {
+	if !vs.capture.Read(frame) || frame.Empty() {
+		return false, nil
+	}
+	return true, nil
+}
+
+func (vs *videoSource) Total() int {
+	return max(0, int(vs.capture.Get(gocv.VideoCaptureFrameCount)))
+}
+
+func (vs *videoSource) FPS() float64 {
+	if fps := vs.capture.Get(gocv.VideoCaptureFPS); fps > 0 {
+		return fps
+	}
+	return DefaultSequenceFPS
+}
+
+func (vs *videoSource) Close() {
+	vs.capture.Close()
+}
+
+type imageSequenceSource struct {
+	paths []string
+	next  int
+}
+
+func newImageSequenceSource(paths []string, input string) (*imageSequenceSource, error) {
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("frame input %s: no images found", input)
+	}
+	sort.Strings(paths)
+	return &imageSequenceSource{paths: paths}, nil
+}
+
+func (is *imageSequenceSource) Next(frame *gocv.Mat) (bool, error) {
+	if is.next >= len(is.paths) {
+		return false, nil
+	}
+
+	path := is.paths[is.next]
+	is.next++
+
+	loaded := gocv.IMRead(path, gocv.IMReadColor)
+	defer loaded.Close()
+	if loaded.Empty() {
+		return false, fmt.Errorf("read frame %s: unreadable image", path)
+	}
+
+	loaded.CopyTo(frame)
+	return true, nil
+}
+
+func (is *imageSequenceSource) Total() int {
+	return len(is.paths)
+}
+
+func (is *imageSequenceSource) FPS() float64 {
+	return DefaultSequenceFPS
+}
+
+func (is *imageSequenceSource) Close() {}
+
+// tiffPageSource reads the pages of a multi-page TIFF file in order.
+type tiffPageSource struct {
+	data  []byte
+	pages int
+	next  int
+}
+
+func newTIFFPageSource(input string) (*tiffPageSource, error) {
+	data, err := os.ReadFile(input)
+	if err != nil {
+		return nil, fmt.Errorf("frame input: %w", err)
+	}
+	header, err := InspectImageData(data)
+	if err != nil {
+		return nil, fmt.Errorf("frame input %s: %w", input, err)
+	}
+	return &tiffPageSource{data: data, pages: header.Pages}, nil
+}
+
+func (ts *tiffPageSource) Next(frame *gocv.Mat) (bool, error) {
+	if ts.next >= ts.pages {
+		return false, nil
+	}
+	ts.next++
+
+	page, err := SelectTIFFPage(ts.data, ts.next)
+	if err != nil {
+		return false, err
+	}
+	imageData, err := DecodeImageData(page, ".tif")
+	if err != nil {
+		return false, fmt.Errorf("read page %d: %w", ts.next, err)
+	}
+	defer imageData.Mat.Close()
+
+	imageData.Mat.CopyTo(frame)
+	return true, nil
+}
+
+func (ts *tiffPageSource) Total() int {
+	return ts.pages
+}
+
+func (ts *tiffPageSource) FPS() float64 {
+	return DefaultSequenceFPS
+}
+
+func (ts *tiffPageSource) Close() {}
+
+func openFrameSink(output string, fps float64, width, height int) (frameSink, error) {
+	if isTIFFPath(output) {
+		return &group4Sink{path: output}, nil
+	}
+	if isVideoPath(output) {
+		codec := "mp4v"
+		if strings.EqualFold(filepath.Ext(output), ".avi") {
+			codec = "MJPG"
+		}
+
+		writer, err := gocv.VideoWriterFile(output, codec, fps, width, height, true)
+		if err != nil || !writer.IsOpened() {
+			if err == nil {
+				writer.Close()
+				err = fmt.Errorf("codec %s is not available", codec)
+			}
+			return nil, fmt.Errorf("create video %s: %w", output, err)
+		}
+		return &videoSink{writer: writer}, nil
+	}
+
+	if err := os.MkdirAll(output, 0755); err != nil {
+		return nil, fmt.Errorf("create frame directory: %w", err)
+	}
+	return &frameDirectorySink{dir: output}, nil
+}
+
+type videoSink struct {
+	writer *gocv.VideoWriter
+}
+
+// Write expands the single-channel result to BGR, which every container
+// codec accepts.
+func (vs *videoSink) Write(index int, frame gocv.Mat) error {
+	bgr := gocv.NewMat()
+	defer bgr.Close()
+	gocv.CvtColor(frame, &bgr, gocv.ColorGrayToBGR)
+	return vs.writer.Write(bgr)
+}
+
+func (vs *videoSink) Close() error {
+	return vs.writer.Close()
+}
+
+type frameDirectorySink struct {
+	dir string
+}
+
+func (fs *frameDirectorySink) Write(index int, frame gocv.Mat) error {
+	path := filepath.Join(fs.dir, fmt.Sprintf("frame_%06d.png", index+1))
+	if !gocv.IMWrite(path, frame) {
+		return fmt.Errorf("write %s", path)
+	}
+	return nil
+}
+
+func (fs *frameDirectorySink) Close() error {
+	return nil
+}
+
+// group4Sink collects the frames as the pages of one Group 4 TIFF file,
+// written on Close.
+type group4Sink struct {
+	path    string
+	archive Group4TIFF
+}
+
+func (gs *group4Sink) Write(index int, frame gocv.Mat) error {
+	img, err := frame.ToImage()
+	if err != nil {
+		return err
+	}
+	return gs.archive.AddPage(img)
+}
+
+func (gs *group4Sink) Close() error {
+	if gs.archive.Pages() == 0 {
+		return nil
+	}
+	file, err := os.Create(gs.path)
+	if err != nil {
+		return fmt.Errorf("create %s: %w", gs.path, err)
+	}
+	if _, err := gs.archive.WriteTo(file); err != nil {
+		file.Close()
+		return fmt.Errorf("write %s: %w", gs.path, err)
+	}
+	return file.Close()
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ManifestFormatJSON = "json"
+	ManifestFormatCSV  = "csv"
+)
+
+// ManifestEntry records where one batch output came from and how it was
+// produced, for archival provenance.
+type ManifestEntry struct {
+	Source        string    `json:"source"`
+	SourceSHA256  string    `json:"source_sha256"`
+	Output        string    `json:"output,omitempty"`
+	OutputSHA256  string    `json:"output_sha256,omitempty"`
+	SourcePHash   string    `json:"source_phash,omitempty"`
+	OutputPHash   string    `json:"output_phash,omitempty"`
+	Algorithm     string    `json:"algorithm"`
+	ParameterHash string    `json:"parameter_hash"`
+	ScaleFactor   float64   `json:"scale_factor,omitempty"`
+	AppVersion    string    `json:"app_version"`
+	StartedAt     time.Time `json:"started_at"`
+	FinishedAt    time.Time `json:"finished_at"`
+	Status        string    `json:"status"`
+	Error         string    `json:"error,omitempty"`
+
+	// Attempts counts tries including retries; RetryAction names the last
+	// fallback applied. Failed entries carry a category and remediation.
+	Attempts        int    `json:"attempts,omitempty"`
+	RetryAction     string `json:"retry_action,omitempty"`
+	FailureCategory string `json:"failure_category,omitempty"`
+	Remediation     string `json:"remediation,omitempty"`
+
+	// Collision records what happened to an output that already existed:
+	// overwritten, skipped or versioned.
+	Collision string `json:"collision,omitempty"`
+
+	// DuplicateOf names the earlier input a duplicate entry repeats; its
+	// output is that input's.
+	DuplicateOf string `json:"duplicate_of,omitempty"`
+}
+
+type ProvenanceManifest struct {
+	AppName    string           `json:"app_name"`
+	AppVersion string           `json:"app_version"`
+	CreatedAt  time.Time        `json:"created_at"`
+	Parameters *OtsuParameters  `json:"parameters"`
+	OnExists   string           `json:"on_exists,omitempty"`
+	Entries    []*ManifestEntry `json:"entries"`
+}
+
+func NewProvenanceManifest(params *OtsuParameters) *ProvenanceManifest {
+	return &ProvenanceManifest{
+		AppName:    AppName,
+		AppVersion: AppVersion,
+		CreatedAt:  time.Now().UTC(),
+		Parameters: params,
+	}
+}
+
+func (pm *ProvenanceManifest) Add(entry *ManifestEntry) {
+	pm.Entries = append(pm.Entries, entry)
+}
+
+// manifestFormatForPath picks the format from the file extension when none
+// was requested explicitly.
+func manifestFormatForPath(path, format string) (string, error) {
+	if format == "" {
+		if strings.EqualFold(filepath.Ext(path), ".csv") {
+			return ManifestFormatCSV, nil
+		}
+		return ManifestFormatJSON, nil
+	}
+
+	switch format {
+	case ManifestFormatJSON, ManifestFormatCSV:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unknown manifest format %q: expected %s or %s", format, ManifestFormatJSON, ManifestFormatCSV)
+	}
+}
+
+func (pm *ProvenanceManifest) Write(path, format string) error {
+	format, err := manifestFormatForPath(path, format)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("create manifest: %w", err)
+	}
+
+	if format == ManifestFormatCSV {
+		err = pm.writeCSV(file)
+	} else {
+		encoder := json.NewEncoder(file)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(pm)
+	}
+
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("write manifest %s: %w", path, err)
+	}
+	return nil
+}
+
+func (pm *ProvenanceManifest) writeCSV(file *os.File) error {
+	writer := csv.NewWriter(file)
+
+	writer.Write([]string{
+		"source", "source_sha256", "output", "output_sha256", "algorithm",
+		"parameter_hash", "scale_factor", "app_version", "started_at", "finished_at", "status", "error",
+		"attempts", "retry_action", "failure_category", "remediation", "collision",
+		"source_phash", "output_phash", "duplicate_of",
+	})
+
+	for _, entry := range pm.Entries {
+		writer.Write([]string{
+			entry.Source,
+			entry.SourceSHA256,
+			entry.Output,
+			entry.OutputSHA256,
+			entry.Algorithm,
+			entry.ParameterHash,
+			strconv.FormatFloat(entry.ScaleFactor, 'f', -1, 64),
+			entry.AppVersion,
+			entry.StartedAt.Format(time.RFC3339Nano),
+			entry.FinishedAt.Format(time.RFC3339Nano),
+			entry.Status,
+			entry.Error,
+			strconv.Itoa(entry.Attempts),
+			entry.RetryAction,
+			entry.FailureCategory,
+			entry.Remediation,
+			entry.Collision,
+			entry.SourcePHash,
+			entry.OutputPHash,
+			entry.DuplicateOf,
+		})
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
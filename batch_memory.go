@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"image"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// Working memory per pixel: the color original, grayscale and working
+	// copies, the shared local statistics, the result and the metrics buffers.
+	batchBytesPerPixel = 32
+
+	// The pyramid and per-region methods keep extra full-size buffers.
+	batchExtraBytesPerPixel = 16
+
+	// Images processed in strips keep only the color original, grayscale,
+	// working, result and inspection copies at full size; the rest is
+	// sized to a strip.
+	batchStripBytesPerPixel = 8
+
+	// Estimate used when an image header cannot be read; such items usually
+	// fail on load anyway.
+	batchFallbackItemBytes = 64 << 20
+
+	// Budget used when free memory cannot be determined.
+	batchDefaultMemoryBudget = 2 << 30
+
+	// Share of the free memory at start the batch may claim.
+	batchMemoryShare = 0.75
+)
+
+// memoryBudget admits batch items while their estimated working memory
+// fits the budget, up to maxRunning at a time. One item is always admitted
+// when nothing runs, however large, so oversized images still progress.
+type memoryBudget struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+
+	limit      uint64
+	inUse      uint64
+	running    int
+	maxRunning int
+}
+
+func newMemoryBudget(limit uint64, maxRunning int) *memoryBudget {
+	mb := &memoryBudget{limit: limit, maxRunning: max(1, maxRunning)}
+	mb.cond = sync.NewCond(&mb.mu)
+	return mb
+}
+
+// acquire blocks until need bytes can be admitted or ctx is cancelled. It
+// also waits while the system reports less free memory than need, which
+// throttles the batch when other processes claim memory mid-run.
+func (mb *memoryBudget) acquire(ctx context.Context, need uint64) bool {
+	stop := context.AfterFunc(ctx, func() {
+		mb.mu.Lock()
+		mb.cond.Broadcast()
+		mb.mu.Unlock()
+	})
+	defer stop()
+
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+
+	for ctx.Err() == nil && mb.running > 0 && !mb.fits(need) {
+		mb.cond.Wait()
+	}
+	if ctx.Err() != nil {
+		return false
+	}
+
+	mb.inUse += need
+	mb.running++
+	return true
+}
+
+func (mb *memoryBudget) fits(need uint64) bool {
+	if mb.running >= mb.maxRunning || mb.inUse+need > mb.limit {
+		return false
+	}
+
+	available := systemAvailableMemory()
+	return available == 0 || need <= available
+}
+
+func (mb *memoryBudget) release(amount uint64) {
+	mb.mu.Lock()
+	mb.inUse -= amount
+	mb.running--
+	mb.mu.Unlock()
+	mb.cond.Broadcast()
+}
+
+// batchMemoryLimit returns budgetMB in bytes, or when it is 0 a share of the
+// memory free now.
+func batchMemoryLimit(budgetMB int) uint64 {
+	if budgetMB > 0 {
+		return uint64(budgetMB) << 20
+	}
+	if available := systemAvailableMemory(); available > 0 {
+		return uint64(float64(available) * batchMemoryShare)
+	}
+	return batchDefaultMemoryBudget
+}
+
+// estimateItemMemory estimates the working memory for processing path from
+// its dimensions, read from the image header without decoding it.
+func estimateItemMemory(path string, params *OtsuParameters, maxMegapixels float64) uint64 {
+	file, err := os.Open(path)
+	if err != nil {
+		return batchFallbackItemBytes
+	}
+	defer file.Close()
+
+	config, _, err := image.DecodeConfig(bufio.NewReader(file))
+	if err != nil {
+		return batchFallbackItemBytes
+	}
+
+	pixels := float64(config.Width) * float64(config.Height)
+	if maxMegapixels > 0 {
+		pixels = math.Min(pixels, maxMegapixels*1e6)
+	}
+
+	perPixel := float64(batchBytesPerPixel)
+	if params.MultiScaleProcessing || params.RegionAdaptiveThresholding {
+		perPixel += batchExtraBytesPerPixel
+	}
+	if usesStrips(pixels, params) {
+		// Allow for a strip with its overlap and the outputs of its methods.
+		stripPixels := math.Min(pixels, tiledStripMegapixels*1e6*2)
+		return uint64(pixels*batchStripBytesPerPixel + stripPixels*perPixel)
+	}
+	return uint64(pixels * perPixel)
+}
+
+// systemAvailableMemory reports the memory the system can hand out without
+// swapping, or 0 where it cannot be read. Only Linux exposes it here.
+func systemAvailableMemory() uint64 {
+	file, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "MemAvailable:" {
+			kilobytes, err := strconv.ParseUint(fields[1], 10, 64)
+			if err != nil {
+				return 0
+			}
+			return kilobytes << 10
+		}
+	}
+	return 0
+}
@@ -0,0 +1,588 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	manifestStatusOK      = "ok"
+	manifestStatusFailed  = "failed"
+	manifestStatusSkipped = "skipped"
+)
+
+// BatchConfig describes a run over many images with one parameter set.
+type BatchConfig struct {
+	ProcessingConfig
+	Inputs          []string
+	OutputDir       string
+	OutputFormat    string
+	ManifestPath    string
+	ManifestFormat  string
+	EmbedProvenance bool
+
+	// TIFFCompression is one of TIFFCompressions for tif outputs; empty
+	// is deflate.
+	TIFFCompression string
+
+	// NameTemplate names the outputs, see RenderOutputName; empty keeps
+	// the name of the source.
+	NameTemplate string
+
+	// OnExists is the collision policy for outputs that already exist;
+	// empty overwrites them.
+	OnExists string
+
+	// Layout, LayoutPAGE or LayoutALTO, writes the text regions of every
+	// output next to it; empty writes none.
+	Layout string
+
+	// Crop, when set, trims every output to its content.
+	Crop *CropOptions
+
+	// ProvenanceKey, when set, signs a provenance record embedded in every
+	// output.
+	ProvenanceKey ed25519.PrivateKey
+
+	// CheckpointPath, when set, receives the outcome of every item. With
+	// Resume, items it records as done with unchanged files are skipped.
+	CheckpointPath string
+	Resume         bool
+
+	// Jobs caps the images processed at once, 0 for one per CPU.
+	// MemoryBudgetMB caps their estimated working memory, 0 for a share of
+	// the memory free at start.
+	Jobs           int
+	MemoryBudgetMB int
+
+	Retry BatchRetryPolicy
+
+	// FailureReport is where the command writes failures grouped by
+	// category when any image failed.
+	FailureReport string
+
+	// Duplicates is the duplicate policy: with DuplicatesUnique, inputs that
+	// FindDuplicates matched to an earlier input are not processed.
+	Duplicates string
+
+	// BatterySaver is the battery saver mode the command resolves before the
+	// run; see ResolveBatterySaver.
+	BatterySaver string
+}
+
+type BatchItem struct {
+	Input  string
+	Output string
+}
+
+type BatchResult struct {
+	Item     BatchItem
+	Entry    *ManifestEntry
+	Metrics  *MetricsReport
+	Duration time.Duration
+	Err      error
+
+	// Skipped is set for items a resumed run found already done.
+	Skipped bool
+
+	// DuplicateOf is set for items mapped to the result of an earlier
+	// input they repeat.
+	DuplicateOf string
+}
+
+// BatchRunner processes BatchItems in order and records a provenance entry
+// for each of them, whether it succeeded or not.
+type BatchRunner struct {
+	config        *BatchConfig
+	manifest      *ProvenanceManifest
+	checkpoint    *BatchCheckpoint
+	algorithm     string
+	parameterHash string
+	duplicateOf   map[string]duplicateMatch
+
+	// OnItemDone, when set, is called after every item, one call at a time
+	// but in completion order.
+	OnItemDone func(index, total int, result *BatchResult)
+}
+
+func NewBatchRunner(config *BatchConfig) (*BatchRunner, error) {
+	parameterHash, err := ParameterHash(config.Params)
+	if err != nil {
+		return nil, err
+	}
+
+	runner := &BatchRunner{
+		config:        config,
+		manifest:      NewProvenanceManifest(config.Params),
+		algorithm:     processingMethodName(config.Params),
+		parameterHash: parameterHash,
+	}
+	runner.manifest.OnExists = config.OnExists
+
+	if config.CheckpointPath != "" {
+		if config.Resume {
+			runner.checkpoint, err = LoadBatchCheckpoint(config.CheckpointPath, parameterHash)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			runner.checkpoint = NewBatchCheckpoint(config.CheckpointPath, config.Params, parameterHash)
+		}
+	}
+
+	return runner, nil
+}
+
+func (br *BatchRunner) Manifest() *ProvenanceManifest {
+	return br.manifest
+}
+
+// Plan expands directories among the inputs into the images they contain and
+// assigns each input its output path. A {fmeasure} token in the name
+// template stays in the path until the image has been processed.
+func (br *BatchRunner) Plan() ([]BatchItem, error) {
+	inputs, err := expandImageInputs(br.config.Inputs)
+	if err != nil {
+		return nil, fmt.Errorf("batch input: %w", err)
+	}
+
+	items := make([]BatchItem, 0, len(inputs))
+	claimed := make(map[string]string, len(inputs))
+	for _, input := range inputs {
+		absolute, err := filepath.Abs(input)
+		if err != nil {
+			return nil, fmt.Errorf("batch input: %w", err)
+		}
+		claimed[absolute] = input
+	}
+
+	template := br.config.NameTemplate
+	if template == "" {
+		template = DefaultOutputNameTemplate
+	}
+	started := time.Now()
+	for _, input := range inputs {
+		name := RenderOutputName(template, OutputNameFields{
+			Name:      strings.TrimSuffix(filepath.Base(input), filepath.Ext(input)),
+			Algorithm: methodAlgorithm(br.config.Params),
+			Preset:    br.config.Preset,
+			Date:      started,
+		})
+		output := filepath.Join(br.config.OutputDir, name+"."+br.config.OutputFormat)
+
+		absolute, err := filepath.Abs(output)
+		if err != nil {
+			return nil, fmt.Errorf("batch output: %w", err)
+		}
+		if owner, ok := claimed[absolute]; ok {
+			return nil, fmt.Errorf("batch output %s for %s would overwrite %s", output, input, owner)
+		}
+		claimed[absolute] = input
+
+		items = append(items, BatchItem{Input: input, Output: output})
+	}
+
+	return items, nil
+}
+
+// Run processes every planned item, several at a time as far as the memory
+// budget and the job limit allow. It stops early only when ctx is
+// cancelled; failed items are recorded and the run continues. Results and
+// manifest entries keep the order of items.
+func (br *BatchRunner) Run(ctx context.Context, items []BatchItem) []*BatchResult {
+	debugSystem := GetDebugSystem()
+
+	jobs := workerLimit(br.config.Jobs)
+	budget := newMemoryBudget(batchMemoryLimit(br.config.MemoryBudgetMB), jobs)
+	debugSystem.logger.Info("batch started",
+		"items", len(items),
+		"max_jobs", jobs,
+		"memory_budget_mb", budget.limit>>20,
+	)
+
+	slots := make([]*BatchResult, len(items))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	finish := func(index int, result *BatchResult) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		slots[index] = result
+		if br.checkpoint != nil && !result.Skipped && result.DuplicateOf == "" {
+			if err := br.checkpoint.Record(result.Entry); err != nil {
+				debugSystem.logger.Warn("batch checkpoint not saved", "error", err.Error())
+			}
+		}
+		if br.OnItemDone != nil {
+			br.OnItemDone(index, len(items), result)
+		}
+	}
+
+	for i, item := range items {
+		if ctx.Err() != nil {
+			debugSystem.logger.Warn("batch cancelled", "started", i, "total", len(items))
+			break
+		}
+
+		if _, duplicate := br.duplicateOf[item.Input]; duplicate {
+			continue
+		}
+
+		if entry, done := br.resumedEntry(item); done {
+			debugSystem.logger.Info("batch item already done", "input", item.Input, "output", entry.Output)
+			finish(i, &BatchResult{Item: item, Entry: entry, Skipped: true})
+			continue
+		}
+
+		need := estimateItemMemory(item.Input, br.config.Params, br.config.MaxMegapixels)
+		if need > budget.limit {
+			need = budget.limit
+		}
+		if !budget.acquire(ctx, need) {
+			debugSystem.logger.Warn("batch cancelled", "started", i, "total", len(items))
+			break
+		}
+
+		wg.Add(1)
+		go func(index int, item BatchItem) {
+			defer wg.Done()
+			defer budget.release(need)
+
+			result := br.processItem(ctx, item)
+			if result.Err != nil {
+				debugSystem.logger.Error("batch item failed", "input", item.Input, "error", result.Err.Error())
+			} else {
+				debugSystem.logger.Info("batch item complete",
+					"input", item.Input,
+					"output", result.Entry.Output,
+					"duration_ms", result.Duration.Milliseconds(),
+					"estimated_memory_mb", need>>20,
+				)
+			}
+			finish(index, result)
+		}(i, item)
+	}
+
+	wg.Wait()
+
+	// Duplicates take the result of the input they repeat, once it is in.
+	indexes := make(map[string]int, len(items))
+	for i, item := range items {
+		indexes[item.Input] = i
+	}
+	for i, item := range items {
+		match, duplicate := br.duplicateOf[item.Input]
+		if !duplicate {
+			continue
+		}
+		if original := slots[indexes[match.original]]; original != nil {
+			finish(i, duplicateResult(item, match, original))
+		}
+	}
+
+	results := make([]*BatchResult, 0, len(items))
+	for _, result := range slots {
+		if result != nil {
+			results = append(results, result)
+			br.manifest.Add(result.Entry)
+		}
+	}
+	return results
+}
+
+func (br *BatchRunner) resumedEntry(item BatchItem) (*ManifestEntry, bool) {
+	if br.checkpoint == nil || !br.config.Resume {
+		return nil, false
+	}
+	return br.checkpoint.Completed(item)
+}
+
+// processItem makes the first attempt at item and the retries the policy
+// allows for its failures.
+func (br *BatchRunner) processItem(ctx context.Context, item BatchItem) *BatchResult {
+	entry := &ManifestEntry{
+		Source:        item.Input,
+		Algorithm:     br.algorithm,
+		ParameterHash: br.parameterHash,
+		AppVersion:    AppVersion,
+		StartedAt:     time.Now().UTC(),
+		Status:        manifestStatusOK,
+	}
+	result := &BatchResult{Item: item, Entry: entry}
+
+	attempt := batchAttempt{params: br.config.Params, maxMegapixels: br.config.MaxMegapixels}
+	var metrics *MetricsReport
+	var err error
+	for retries := 0; ; retries++ {
+		entry.Attempts = retries + 1
+		metrics, err = br.produceOutput(ctx, item, entry, attempt)
+		if err == nil || ctx.Err() != nil {
+			break
+		}
+
+		category := classifyBatchError(err)
+		next, action, ok := br.config.Retry.next(attempt, retries, category)
+		if !ok {
+			break
+		}
+
+		GetDebugSystem().logger.Warn("batch item retrying",
+			"input", item.Input,
+			"category", category,
+			"action", action,
+			"error", err.Error(),
+		)
+		attempt = next
+		entry.RetryAction = action
+	}
+
+	entry.FinishedAt = time.Now().UTC()
+	result.Duration = entry.FinishedAt.Sub(entry.StartedAt)
+	result.Metrics = metrics
+
+	if err != nil {
+		entry.Status = manifestStatusFailed
+		entry.Error = err.Error()
+		entry.FailureCategory = classifyBatchError(err)
+		entry.Remediation = failureRemediations[entry.FailureCategory]
+		entry.Output = ""
+		result.Err = err
+	}
+
+	return result
+}
+
+func (br *BatchRunner) produceOutput(ctx context.Context, item BatchItem, entry *ManifestEntry, attempt batchAttempt) (*MetricsReport, error) {
+	params := attempt.params
+	if params != br.config.Params {
+		parameterHash, err := ParameterHash(params)
+		if err != nil {
+			return nil, err
+		}
+		entry.Algorithm = processingMethodName(params)
+		entry.ParameterHash = parameterHash
+	}
+
+	// Skipping is decided up front unless the name waits for the score.
+	if br.config.OnExists == CollisionSkip && withFMeasure(item.Output, 0) == item.Output {
+		if _, err := os.Stat(item.Output); err == nil {
+			br.skipExisting(entry, item.Output)
+			return nil, nil
+		}
+	}
+
+	data, err := os.ReadFile(item.Input)
+	if err != nil {
+		return nil, stageError(FailureRead, fmt.Errorf("read %s: %w", item.Input, err))
+	}
+	entry.SourceSHA256 = sha256Hex(data)
+
+	imageData, err := DecodeImageWithinLimits(data, strings.ToLower(filepath.Ext(item.Input)), attempt.maxMegapixels)
+	if err != nil {
+		return nil, stageError(FailureDecode, fmt.Errorf("load %s: %w", item.Input, err))
+	}
+
+	engine := NewProcessingEngine()
+	defer engine.Close()
+	engine.SetOriginalImage(imageData)
+	entry.ScaleFactor = imageData.ScaleFactor
+	if hash, err := PerceptualHashOf(imageData.Mat); err == nil {
+		entry.SourcePHash = hash.String()
+	}
+
+	if err := validateOtsuParameters(params, [2]int{imageData.Width, imageData.Height}); err != nil {
+		return nil, err
+	}
+
+	if br.config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, br.config.Timeout)
+		defer cancel()
+	}
+
+	processed, metrics, err := engine.ProcessImageWithTimeout(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("process %s: %w", item.Input, err)
+	}
+	report := NewMetricsReport(metrics)
+	item.Output = withFMeasure(item.Output, report.FMeasure)
+
+	var crop *OutputCrop
+	if br.config.Crop != nil {
+		cropped, applied, err := CropToContent(processed, *br.config.Crop)
+		if err != nil {
+			return nil, err
+		}
+		if applied != nil {
+			defer cropped.Mat.Close()
+			processed, crop = cropped, applied
+		}
+	}
+
+	var encoded bytes.Buffer
+	encoder := DefaultEncodeOptions()
+	if br.config.TIFFCompression != "" {
+		encoder.TIFFCompression = br.config.TIFFCompression
+	}
+	if err := EncodeImageWithOptions(&encoded, processed, filepath.Ext(item.Output), encoder); err != nil {
+		return nil, stageError(FailureWrite, fmt.Errorf("encode %s: %w", item.Output, err))
+	}
+
+	output := encoded.Bytes()
+	if br.config.EmbedProvenance && br.config.OutputFormat == "png" {
+		output, err = EmbedPNGTextChunks(output, br.provenanceChunks(entry, params))
+		if err != nil {
+			return nil, err
+		}
+	}
+	if br.config.ProvenanceKey != nil {
+		record, err := NewProvenanceRecord(item.Input, data, params)
+		if err != nil {
+			return nil, err
+		}
+		output, err = SignProvenance(output, filepath.Ext(item.Output), record, br.config.ProvenanceKey)
+		if err != nil {
+			return nil, stageError(FailureWrite, err)
+		}
+	}
+
+	target, collision, err := resolveOutputCollision(item.Output, br.config.OnExists)
+	if err != nil {
+		return nil, stageError(FailureWrite, err)
+	}
+	entry.Collision = collision
+	if target == "" {
+		br.skipExisting(entry, item.Output)
+		return report, nil
+	}
+	item.Output = target
+
+	if err := os.WriteFile(item.Output, output, 0644); err != nil {
+		return nil, stageError(FailureWrite, fmt.Errorf("write %s: %w", item.Output, err))
+	}
+
+	if sidecar := withCrop(NewOutputSidecar(item.Input, imageData), item.Input, imageData, crop); sidecar != nil {
+		if err := sidecar.Write(item.Output); err != nil {
+			return nil, stageError(FailureWrite, err)
+		}
+	}
+
+	if br.config.Layout != "" {
+		layout, err := DetectTextLayout(processed, filepath.Base(item.Output))
+		if err != nil {
+			return nil, err
+		}
+		if err := layout.WriteFile(LayoutPath(item.Output, br.config.Layout), br.config.Layout); err != nil {
+			return nil, stageError(FailureWrite, err)
+		}
+	}
+
+	entry.Output = item.Output
+	entry.OutputSHA256 = sha256Hex(output)
+	if hash, err := PerceptualHashOf(processed.Mat); err == nil {
+		entry.OutputPHash = hash.String()
+	}
+
+	return report, nil
+}
+
+// skipExisting records that the output at path was left as it was.
+func (br *BatchRunner) skipExisting(entry *ManifestEntry, path string) {
+	entry.Status = manifestStatusSkipped
+	entry.Collision = collisionSkipped
+	entry.Output = path
+	GetDebugSystem().logger.Info("batch output exists, skipped", "input", entry.Source, "output", path)
+}
+
+func (br *BatchRunner) provenanceChunks(entry *ManifestEntry, params *OtsuParameters) []TextChunk {
+	chunks := []TextChunk{
+		{Keyword: "Software", Text: fmt.Sprintf("%s %s", AppName, AppVersion)},
+		{Keyword: "Creation Time", Text: entry.StartedAt.Format(time.RFC3339)},
+		{Keyword: "Source", Text: filepath.Base(entry.Source)},
+		{Keyword: "Source SHA-256", Text: entry.SourceSHA256},
+		{Keyword: "Algorithm", Text: entry.Algorithm},
+		{Keyword: "Parameter Hash", Text: entry.ParameterHash},
+	}
+
+	if encoded, err := EncodeParametersJSON(params); err == nil {
+		chunks = append(chunks, TextChunk{Keyword: "Parameters", Text: encoded})
+	}
+
+	return chunks
+}
+
+// expandImageInputs replaces directories among inputs with the images they
+// contain, in name order. Glob patterns that reach it unexpanded, as from
+// scripts, CI jobs or Windows shells, are matched here.
+func expandImageInputs(paths []string) ([]string, error) {
+	var inputs []string
+	for _, input := range paths {
+		info, err := os.Stat(input)
+		if err != nil {
+			matches := globImageInputs(input)
+			if len(matches) == 0 {
+				return nil, err
+			}
+			inputs = append(inputs, matches...)
+			continue
+		}
+
+		if !info.IsDir() {
+			inputs = append(inputs, input)
+			continue
+		}
+
+		entries, err := os.ReadDir(input)
+		if err != nil {
+			return nil, err
+		}
+
+		var found []string
+		for _, entry := range entries {
+			if !entry.IsDir() && isSupportedImagePath(entry.Name()) {
+				found = append(found, filepath.Join(input, entry.Name()))
+			}
+		}
+		sort.Strings(found)
+		inputs = append(inputs, found...)
+	}
+
+	if len(inputs) == 0 {
+		return nil, fmt.Errorf("no images found")
+	}
+	return inputs, nil
+}
+
+// globImageInputs returns the supported images matching pattern in name
+// order, or nil when pattern is not a glob or matches none.
+func globImageInputs(pattern string) []string {
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil
+	}
+
+	var images []string
+	for _, match := range matches {
+		if isSupportedImagePath(match) {
+			images = append(images, match)
+		}
+	}
+	return images
+}
+
+func isSupportedImagePath(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".png", ".jpg", ".jpeg", ".tif", ".tiff":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,427 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	chartWidth  = 720
+	chartHeight = 440
+
+	chartMarginLeft   = 70
+	chartMarginRight  = 24
+	chartMarginTop    = 44
+	chartMarginBottom = 56
+
+	chartTicks = 5
+)
+
+var (
+	chartInk   = color.RGBA{0x22, 0x22, 0x22, 0xff}
+	chartGrid  = color.RGBA{0xdd, 0xdd, 0xdd, 0xff}
+	chartPaper = color.RGBA{0xff, 0xff, 0xff, 0xff}
+
+	chartPalette = []color.RGBA{
+		{0x1f, 0x77, 0xb4, 0xff},
+		{0xff, 0x7f, 0x0e, 0xff},
+		{0x2c, 0xa0, 0x2c, 0xff},
+		{0xd6, 0x27, 0x28, 0xff},
+		{0x94, 0x67, 0xbd, 0xff},
+		{0x8c, 0x56, 0x4b, 0xff},
+		{0xe3, 0x77, 0xc2, 0xff},
+		{0x7f, 0x7f, 0x7f, 0xff},
+	}
+)
+
+type chartShapeKind int
+
+const (
+	chartLine chartShapeKind = iota
+	chartRect
+	chartDot
+	chartText
+)
+
+// chartShape is one primitive of a chart in pixel coordinates. Rects span
+// x1,y1 to x2,y2; dots are centered on x1,y1 with radius x2; text sits on
+// the baseline at y1, anchored at x1 by anchor.
+type chartShape struct {
+	kind           chartShapeKind
+	x1, y1, x2, y2 float64
+	stroke         color.RGBA
+	fill           color.RGBA
+	text           string
+	anchor         string
+}
+
+// Chart is a small vector drawing that renders to SVG and to a raster
+// image with the same layout, so the dashboard shows what is exported.
+type Chart struct {
+	Title  string
+	Width  int
+	Height int
+	shapes []chartShape
+}
+
+func newChart(title string) *Chart {
+	chart := &Chart{Title: title, Width: chartWidth, Height: chartHeight}
+	chart.text(float64(chartWidth)/2, 24, title, "middle", chartInk)
+	return chart
+}
+
+func (c *Chart) line(x1, y1, x2, y2 float64, stroke color.RGBA) {
+	c.shapes = append(c.shapes, chartShape{kind: chartLine, x1: x1, y1: y1, x2: x2, y2: y2, stroke: stroke})
+}
+
+func (c *Chart) rect(x1, y1, x2, y2 float64, stroke, fill color.RGBA) {
+	c.shapes = append(c.shapes, chartShape{kind: chartRect, x1: math.Min(x1, x2), y1: math.Min(y1, y2),
+		x2: math.Max(x1, x2), y2: math.Max(y1, y2), stroke: stroke, fill: fill})
+}
+
+func (c *Chart) dot(x, y, radius float64, fill color.RGBA) {
+	c.shapes = append(c.shapes, chartShape{kind: chartDot, x1: x, y1: y, x2: radius, fill: fill})
+}
+
+func (c *Chart) text(x, y float64, text, anchor string, fill color.RGBA) {
+	c.shapes = append(c.shapes, chartShape{kind: chartText, x1: x, y1: y, text: text, anchor: anchor, fill: fill})
+}
+
+// chartAxis maps data values onto a pixel range.
+type chartAxis struct {
+	min, max float64
+	from, to float64
+}
+
+func newChartAxis(values []float64, from, to float64) chartAxis {
+	axis := chartAxis{min: math.Inf(1), max: math.Inf(-1), from: from, to: to}
+	for _, value := range values {
+		axis.min = math.Min(axis.min, value)
+		axis.max = math.Max(axis.max, value)
+	}
+	if len(values) == 0 {
+		axis.min, axis.max = 0, 1
+	}
+	if axis.max-axis.min < 1e-9 {
+		axis.min -= 0.5
+		axis.max += 0.5
+	}
+	padding := (axis.max - axis.min) * 0.05
+	axis.min -= padding
+	axis.max += padding
+	return axis
+}
+
+func (ca chartAxis) position(value float64) float64 {
+	return ca.from + (value-ca.min)/(ca.max-ca.min)*(ca.to-ca.from)
+}
+
+func (ca chartAxis) tick(i int) float64 {
+	return ca.min + (ca.max-ca.min)*float64(i)/chartTicks
+}
+
+func (c *Chart) plotArea() (left, top, right, bottom float64) {
+	return chartMarginLeft, chartMarginTop, float64(c.Width - chartMarginRight), float64(c.Height - chartMarginBottom)
+}
+
+// yAxis draws horizontal grid lines with labels and the axis title.
+func (c *Chart) yAxis(axis chartAxis, title string) {
+	left, top, right, _ := c.plotArea()
+	for i := 0; i <= chartTicks; i++ {
+		value := axis.tick(i)
+		y := axis.position(value)
+		c.line(left, y, right, y, chartGrid)
+		c.text(left-6, y+4, formatChartValue(value), "end", chartInk)
+	}
+	c.text(left, top-8, title, "start", chartInk)
+}
+
+func formatChartValue(value float64) string {
+	magnitude := math.Abs(value)
+	switch {
+	case magnitude >= 1000:
+		return fmt.Sprintf("%.0f", value)
+	case magnitude >= 10:
+		return fmt.Sprintf("%.1f", value)
+	}
+	return fmt.Sprintf("%.3f", value)
+}
+
+// benchmarkScores returns the values of metric per candidate, in candidate
+// order, from the successful runs.
+func benchmarkScores(report *BenchmarkReport, metric benchmarkMetric) [][]float64 {
+	scores := make([][]float64, len(report.Candidates))
+	for i, candidate := range report.Candidates {
+		for _, run := range report.Runs {
+			if run.Candidate == candidate.Name && run.Metrics != nil {
+				scores[i] = append(scores[i], metric.Value(run.Metrics))
+			}
+		}
+	}
+	return scores
+}
+
+// BenchmarkBoxPlot draws the distribution of one metric per candidate:
+// quartile box, median, whiskers to the furthest values within 1.5 IQR,
+// outliers as dots and the mean as a white dot.
+func BenchmarkBoxPlot(report *BenchmarkReport, metric benchmarkMetric) *Chart {
+	direction := "lower is better"
+	if metric.HigherBetter {
+		direction = "higher is better"
+	}
+	chart := newChart(fmt.Sprintf("%s per candidate (%s)", metric.Name, direction))
+	left, top, right, bottom := chart.plotArea()
+
+	scores := benchmarkScores(report, metric)
+	var all []float64
+	for _, values := range scores {
+		all = append(all, values...)
+	}
+	axis := newChartAxis(all, bottom, top)
+	chart.yAxis(axis, metric.Name)
+
+	slot := (right - left) / float64(max(1, len(scores)))
+	half := math.Min(30, slot/4)
+	for i, values := range scores {
+		x := left + (float64(i)+0.5)*slot
+		fill := chartPalette[i%len(chartPalette)]
+		chart.text(x, bottom+18, report.Candidates[i].Name, "middle", chartInk)
+		chart.text(x, bottom+34, fmt.Sprintf("n=%d", len(values)), "middle", chartInk)
+		if len(values) == 0 {
+			continue
+		}
+
+		sorted := append([]float64(nil), values...)
+		sort.Float64s(sorted)
+		q1, median, q3 := quantile(sorted, 0.25), quantile(sorted, 0.5), quantile(sorted, 0.75)
+		iqr := q3 - q1
+		low, high := q1, q3
+		for _, value := range sorted {
+			if value >= q1-1.5*iqr && value < low {
+				low = value
+			}
+			if value <= q3+1.5*iqr && value > high {
+				high = value
+			}
+		}
+
+		chart.line(x, axis.position(low), x, axis.position(q1), chartInk)
+		chart.line(x, axis.position(q3), x, axis.position(high), chartInk)
+		chart.line(x-half/2, axis.position(low), x+half/2, axis.position(low), chartInk)
+		chart.line(x-half/2, axis.position(high), x+half/2, axis.position(high), chartInk)
+		chart.rect(x-half, axis.position(q1), x+half, axis.position(q3), chartInk, lighten(fill))
+		chart.line(x-half, axis.position(median), x+half, axis.position(median), chartInk)
+
+		for _, value := range sorted {
+			if value < low || value > high {
+				chart.dot(x, axis.position(value), 3, fill)
+			}
+		}
+		chart.dot(x, axis.position(meanInterval(values).Mean), 3, chartPaper)
+	}
+
+	chart.line(left, bottom, right, bottom, chartInk)
+	chart.line(left, top, left, bottom, chartInk)
+	return chart
+}
+
+// BenchmarkTimeScatter plots F-measure against processing time for every
+// successful run, one color per candidate.
+func BenchmarkTimeScatter(report *BenchmarkReport) *Chart {
+	chart := newChart("F-measure vs processing time")
+	left, top, right, bottom := chart.plotArea()
+
+	var durations, scores []float64
+	for _, run := range report.Runs {
+		if run.Metrics != nil {
+			durations = append(durations, float64(run.DurationMS)/1000)
+			scores = append(scores, run.Metrics.FMeasure)
+		}
+	}
+
+	xAxis := newChartAxis(durations, left, right)
+	yAxis := newChartAxis(scores, bottom, top)
+	chart.yAxis(yAxis, "f_measure")
+	for i := 0; i <= chartTicks; i++ {
+		value := xAxis.tick(i)
+		x := xAxis.position(value)
+		chart.line(x, top, x, bottom, chartGrid)
+		chart.text(x, bottom+16, formatChartValue(value), "middle", chartInk)
+	}
+	chart.text((left+right)/2, bottom+36, "processing time (s)", "middle", chartInk)
+
+	for i, candidate := range report.Candidates {
+		fill := chartPalette[i%len(chartPalette)]
+		for _, run := range report.Runs {
+			if run.Candidate == candidate.Name && run.Metrics != nil {
+				chart.dot(xAxis.position(float64(run.DurationMS)/1000), yAxis.position(run.Metrics.FMeasure), 3.5, fill)
+			}
+		}
+	}
+
+	// The legend goes on top of the points, on a paper background.
+	chart.rect(right-130, top+2, right-4, top+8+float64(len(report.Candidates))*16, chartGrid, chartPaper)
+	for i, candidate := range report.Candidates {
+		legendY := top + 16 + float64(i)*16
+		chart.dot(right-120, legendY-4, 4, chartPalette[i%len(chartPalette)])
+		chart.text(right-110, legendY, candidate.Name, "start", chartInk)
+	}
+
+	chart.line(left, bottom, right, bottom, chartInk)
+	chart.line(left, top, left, bottom, chartInk)
+	return chart
+}
+
+// BenchmarkCharts returns the box plot of every metric and the time
+// scatter, keyed by a file-friendly name, in display order.
+func BenchmarkCharts(report *BenchmarkReport) ([]string, map[string]*Chart) {
+	names := make([]string, 0, len(benchmarkMetrics)+1)
+	charts := make(map[string]*Chart, len(benchmarkMetrics)+1)
+	for _, metric := range benchmarkMetrics {
+		names = append(names, metric.Name)
+		charts[metric.Name] = BenchmarkBoxPlot(report, metric)
+	}
+	names = append(names, "f_measure_vs_time")
+	charts["f_measure_vs_time"] = BenchmarkTimeScatter(report)
+	return names, charts
+}
+
+// WriteBenchmarkCharts writes every chart of report into dir as <name>.svg.
+func WriteBenchmarkCharts(report *BenchmarkReport, dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("create chart directory: %w", err)
+	}
+	names, charts := BenchmarkCharts(report)
+	for _, name := range names {
+		if err := charts[name].WriteFile(filepath.Join(dir, name+".svg")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// quantile interpolates linearly between the closest ranks of sorted.
+func quantile(sorted []float64, q float64) float64 {
+	position := q * float64(len(sorted)-1)
+	lower := int(math.Floor(position))
+	upper := int(math.Ceil(position))
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(position-float64(lower))
+}
+
+func lighten(c color.RGBA) color.RGBA {
+	return color.RGBA{uint8((int(c.R) + 2*255) / 3), uint8((int(c.G) + 2*255) / 3), uint8((int(c.B) + 2*255) / 3), 0xff}
+}
+
+// WriteFile writes the chart as SVG or PNG by the extension of path.
+func (c *Chart) WriteFile(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("create %s: %w", path, err)
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".png") {
+		err = png.Encode(file, c.Image())
+	} else {
+		err = c.WriteSVG(file)
+	}
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	return file.Close()
+}
+
+func (c *Chart) WriteSVG(w io.Writer) error {
+	out := bufio.NewWriter(w)
+	svgColor := func(c color.RGBA) string { return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B) }
+
+	fmt.Fprintf(out, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="monospace" font-size="12">`+"\n",
+		c.Width, c.Height, c.Width, c.Height)
+	fmt.Fprintf(out, `<rect width="%d" height="%d" fill="%s"/>`+"\n", c.Width, c.Height, svgColor(chartPaper))
+	for _, shape := range c.shapes {
+		switch shape.kind {
+		case chartLine:
+			fmt.Fprintf(out, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="%s"/>`+"\n",
+				shape.x1, shape.y1, shape.x2, shape.y2, svgColor(shape.stroke))
+		case chartRect:
+			fmt.Fprintf(out, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" stroke="%s" fill="%s"/>`+"\n",
+				shape.x1, shape.y1, shape.x2-shape.x1, shape.y2-shape.y1, svgColor(shape.stroke), svgColor(shape.fill))
+		case chartDot:
+			fmt.Fprintf(out, `<circle cx="%.1f" cy="%.1f" r="%.1f" fill="%s" stroke="%s"/>`+"\n",
+				shape.x1, shape.y1, shape.x2, svgColor(shape.fill), svgColor(chartInk))
+		case chartText:
+			fmt.Fprintf(out, `<text x="%.1f" y="%.1f" text-anchor="%s" fill="%s">%s</text>`+"\n",
+				shape.x1, shape.y1, shape.anchor, svgColor(shape.fill), html.EscapeString(shape.text))
+		}
+	}
+	fmt.Fprintln(out, "</svg>")
+	return out.Flush()
+}
+
+// Image rasterizes the chart with a fixed-width bitmap font.
+func (c *Chart) Image() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, c.Width, c.Height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(chartPaper), image.Point{}, draw.Src)
+
+	for _, shape := range c.shapes {
+		switch shape.kind {
+		case chartLine:
+			rasterLine(img, shape.x1, shape.y1, shape.x2, shape.y2, shape.stroke)
+		case chartRect:
+			bounds := image.Rect(int(shape.x1), int(shape.y1), int(shape.x2)+1, int(shape.y2)+1)
+			draw.Draw(img, bounds, image.NewUniform(shape.fill), image.Point{}, draw.Src)
+			rasterLine(img, shape.x1, shape.y1, shape.x2, shape.y1, shape.stroke)
+			rasterLine(img, shape.x1, shape.y2, shape.x2, shape.y2, shape.stroke)
+			rasterLine(img, shape.x1, shape.y1, shape.x1, shape.y2, shape.stroke)
+			rasterLine(img, shape.x2, shape.y1, shape.x2, shape.y2, shape.stroke)
+		case chartDot:
+			rasterDot(img, shape.x1, shape.y1, shape.x2+1, chartInk)
+			rasterDot(img, shape.x1, shape.y1, shape.x2, shape.fill)
+		case chartText:
+			drawer := &font.Drawer{Dst: img, Src: image.NewUniform(shape.fill), Face: basicfont.Face7x13}
+			x := shape.x1
+			switch shape.anchor {
+			case "middle":
+				x -= float64(drawer.MeasureString(shape.text).Round()) / 2
+			case "end":
+				x -= float64(drawer.MeasureString(shape.text).Round())
+			}
+			drawer.Dot = fixed.P(int(math.Round(x)), int(math.Round(shape.y1)))
+			drawer.DrawString(shape.text)
+		}
+	}
+	return img
+}
+
+func rasterLine(img *image.RGBA, x1, y1, x2, y2 float64, stroke color.RGBA) {
+	steps := int(math.Max(math.Abs(x2-x1), math.Abs(y2-y1))) + 1
+	for i := 0; i <= steps; i++ {
+		t := float64(i) / float64(steps)
+		img.SetRGBA(int(math.Round(x1+(x2-x1)*t)), int(math.Round(y1+(y2-y1)*t)), stroke)
+	}
+}
+
+func rasterDot(img *image.RGBA, cx, cy, radius float64, fill color.RGBA) {
+	for y := int(cy - radius); y <= int(cy+radius)+1; y++ {
+		for x := int(cx - radius); x <= int(cx+radius)+1; x++ {
+			if dx, dy := float64(x)-cx, float64(y)-cy; dx*dx+dy*dy <= radius*radius {
+				img.SetRGBA(x, y, fill)
+			}
+		}
+	}
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// BenchmarkCandidate is one named parameter set under evaluation.
+type BenchmarkCandidate struct {
+	Name   string          `json:"name"`
+	Params *OtsuParameters `json:"params"`
+}
+
+// BenchmarkPair is a source image and its ground truth binarization.
+type BenchmarkPair struct {
+	Image       string `json:"image"`
+	GroundTruth string `json:"ground_truth"`
+}
+
+// BenchmarkConfig describes a run of every candidate over every pair.
+type BenchmarkConfig struct {
+	ProcessingConfig
+	Candidates []BenchmarkCandidate
+	Pairs      []BenchmarkPair
+
+	// Alpha is the significance level of the paired comparisons.
+	Alpha float64
+}
+
+// BenchmarkRun is the outcome of one candidate on one image.
+type BenchmarkRun struct {
+	Image      string         `json:"image"`
+	Candidate  string         `json:"candidate"`
+	DurationMS int64          `json:"duration_ms"`
+	Metrics    *MetricsReport `json:"metrics,omitempty"`
+	Error      string         `json:"error,omitempty"`
+}
+
+// BenchmarkSummary is the mean of one metric for one candidate over the
+// images it processed.
+type BenchmarkSummary struct {
+	Candidate string `json:"candidate"`
+	Metric    string `json:"metric"`
+	MeanInterval
+}
+
+// BenchmarkComparison pairs two candidates on one metric over the images
+// both processed. MeanDifference is A minus B; Better names the candidate
+// that tends to score better when the difference is significant.
+type BenchmarkComparison struct {
+	Metric         string         `json:"metric"`
+	A              string         `json:"a"`
+	B              string         `json:"b"`
+	MeanDifference float64        `json:"mean_difference"`
+	Wilcoxon       WilcoxonResult `json:"wilcoxon"`
+	Significant    bool           `json:"significant"`
+	Better         string         `json:"better,omitempty"`
+}
+
+type BenchmarkReport struct {
+	CreatedAt   time.Time             `json:"created_at"`
+	Alpha       float64               `json:"alpha"`
+	Candidates  []BenchmarkCandidate  `json:"candidates"`
+	Pairs       []BenchmarkPair       `json:"pairs"`
+	Runs        []BenchmarkRun        `json:"runs"`
+	Summaries   []BenchmarkSummary    `json:"summaries"`
+	Comparisons []BenchmarkComparison `json:"comparisons"`
+}
+
+// runBenchmark processes every pair with every candidate and scores the
+// results against the ground truth. Failed runs are recorded and left out
+// of the statistics. onRun, when set, is called after every run.
+func runBenchmark(ctx context.Context, config *BenchmarkConfig, onRun func(run BenchmarkRun)) (*BenchmarkReport, error) {
+	report := &BenchmarkReport{
+		CreatedAt:  time.Now().UTC(),
+		Alpha:      config.Alpha,
+		Candidates: config.Candidates,
+		Pairs:      config.Pairs,
+	}
+
+	for _, pair := range config.Pairs {
+		runs := benchmarkPair(ctx, config, pair)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		for _, run := range runs {
+			if onRun != nil {
+				onRun(run)
+			}
+		}
+		report.Runs = append(report.Runs, runs...)
+	}
+
+	report.summarize()
+	return report, nil
+}
+
+// benchmarkPair runs every candidate on one image, loading the image and
+// its ground truth once.
+func benchmarkPair(ctx context.Context, config *BenchmarkConfig, pair BenchmarkPair) []BenchmarkRun {
+	runs := make([]BenchmarkRun, len(config.Candidates))
+	for i, candidate := range config.Candidates {
+		runs[i] = BenchmarkRun{Image: pair.Image, Candidate: candidate.Name}
+	}
+	fail := func(err error) []BenchmarkRun {
+		for i := range runs {
+			runs[i].Error = err.Error()
+		}
+		return runs
+	}
+
+	imageData, err := LoadImageFile(pair.Image, config.MaxMegapixels)
+	if err != nil {
+		return fail(fmt.Errorf("load %s: %w", pair.Image, err))
+	}
+	engine := NewProcessingEngine()
+	defer engine.Close()
+	engine.SetOriginalImage(imageData)
+
+	truth, err := LoadImageFile(pair.GroundTruth, 0)
+	if err != nil {
+		return fail(fmt.Errorf("load ground truth %s: %w", pair.GroundTruth, err))
+	}
+	defer truth.Mat.Close()
+
+	for i, candidate := range config.Candidates {
+		runs[i].Metrics, runs[i].DurationMS, err = benchmarkCandidate(ctx, config, engine, truth, candidate.Params)
+		if err != nil {
+			runs[i].Error = err.Error()
+			GetDebugSystem().logger.Warn("benchmark run failed",
+				"image", pair.Image, "candidate", candidate.Name, "error", err.Error())
+		}
+	}
+	return runs
+}
+
+func benchmarkCandidate(ctx context.Context, config *BenchmarkConfig, engine *ProcessingEngine, truth *ImageData, params *OtsuParameters) (*MetricsReport, int64, error) {
+	if config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, config.Timeout)
+		defer cancel()
+	}
+
+	startTime := time.Now()
+	processed, _, err := engine.ProcessImageWithTimeout(ctx, params)
+	duration := time.Since(startTime).Milliseconds()
+	if err != nil {
+		return nil, duration, err
+	}
+	defer processed.Mat.Close()
+
+	mask, err := engine.referenceMask(truth, processed.Mat, false, "ground truth")
+	if err != nil {
+		return nil, duration, err
+	}
+	defer mask.Close()
+
+	metrics, err := CalculateBinaryMetrics(mask, processed.Mat)
+	if err != nil {
+		return nil, duration, fmt.Errorf("metrics against ground truth: %w", err)
+	}
+	return NewMetricsReport(metrics), duration, nil
+}
+
+// summarize computes the per-candidate means and the pairwise Wilcoxon
+// signed-rank tests of every metric.
+func (br *BenchmarkReport) summarize() {
+	scores := make(map[string]map[string]*MetricsReport, len(br.Candidates))
+	for _, candidate := range br.Candidates {
+		scores[candidate.Name] = make(map[string]*MetricsReport)
+	}
+	for _, run := range br.Runs {
+		if run.Metrics != nil {
+			scores[run.Candidate][run.Image] = run.Metrics
+		}
+	}
+
+	for _, metric := range benchmarkMetrics {
+		for _, candidate := range br.Candidates {
+			var values []float64
+			for _, pair := range br.Pairs {
+				if report, ok := scores[candidate.Name][pair.Image]; ok {
+					values = append(values, metric.Value(report))
+				}
+			}
+			br.Summaries = append(br.Summaries, BenchmarkSummary{
+				Candidate:    candidate.Name,
+				Metric:       metric.Name,
+				MeanInterval: meanInterval(values),
+			})
+		}
+
+		for i, a := range br.Candidates {
+			for _, b := range br.Candidates[i+1:] {
+				br.Comparisons = append(br.Comparisons, br.compare(metric, scores[a.Name], scores[b.Name], a.Name, b.Name))
+			}
+		}
+	}
+}
+
+func (br *BenchmarkReport) compare(metric benchmarkMetric, scoresA, scoresB map[string]*MetricsReport, nameA, nameB string) BenchmarkComparison {
+	var valuesA, valuesB []float64
+	for _, pair := range br.Pairs {
+		reportA, okA := scoresA[pair.Image]
+		reportB, okB := scoresB[pair.Image]
+		if okA && okB {
+			valuesA = append(valuesA, metric.Value(reportA))
+			valuesB = append(valuesB, metric.Value(reportB))
+		}
+	}
+
+	comparison := BenchmarkComparison{
+		Metric:   metric.Name,
+		A:        nameA,
+		B:        nameB,
+		Wilcoxon: wilcoxonSignedRank(valuesA, valuesB),
+	}
+	for i := range valuesA {
+		comparison.MeanDifference += valuesA[i] - valuesB[i]
+	}
+	if len(valuesA) > 0 {
+		comparison.MeanDifference /= float64(len(valuesA))
+	}
+
+	comparison.Significant = comparison.Wilcoxon.N > 0 && comparison.Wilcoxon.PValue < br.Alpha
+	if comparison.Significant {
+		// The larger rank sum tells which candidate scored higher.
+		comparison.Better = nameA
+		if (comparison.Wilcoxon.WPlus > comparison.Wilcoxon.WMinus) != metric.HigherBetter {
+			comparison.Better = nameB
+		}
+	}
+	return comparison
+}
+
+// Write stores the report as indented JSON.
+func (br *BenchmarkReport) Write(path string) error {
+	data, err := json.MarshalIndent(br, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode benchmark report: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	return nil
+}
+
+// LoadBenchmarkReport reads a report written by Write.
+func LoadBenchmarkReport(path string) (*BenchmarkReport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+
+	report := &BenchmarkReport{}
+	if err := json.Unmarshal(data, report); err != nil {
+		return nil, fmt.Errorf("decode benchmark report %s: %w", path, err)
+	}
+	return report, nil
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"math"
+	"sort"
+)
+
+// DefaultSignificanceLevel is the two-sided alpha below which a paired
+// difference is flagged as significant.
+const DefaultSignificanceLevel = 0.05
+
+// wilcoxonExactMaxPairs bounds the sample size for which the signed-rank
+// distribution is enumerated; larger samples use the normal approximation.
+const wilcoxonExactMaxPairs = 25
+
+// benchmarkMetric names a field of MetricsReport and whether larger values
+// are better.
+type benchmarkMetric struct {
+	Name         string
+	HigherBetter bool
+	Value        func(report *MetricsReport) float64
+}
+
+var benchmarkMetrics = []benchmarkMetric{
+	{"f_measure", true, func(r *MetricsReport) float64 { return r.FMeasure }},
+	{"pseudo_f_measure", true, func(r *MetricsReport) float64 { return r.PseudoFMeasure }},
+	{"nrm", false, func(r *MetricsReport) float64 { return r.NRM }},
+	{"drd", false, func(r *MetricsReport) float64 { return r.DRD }},
+	{"mpm", false, func(r *MetricsReport) float64 { return r.MPM }},
+	{"bfc", false, func(r *MetricsReport) float64 { return r.BFC }},
+	{"skeleton", true, func(r *MetricsReport) float64 { return r.Skeleton }},
+	{"precision", true, func(r *MetricsReport) float64 { return r.Precision }},
+	{"recall", true, func(r *MetricsReport) float64 { return r.Recall }},
+}
+
+// MeanInterval is a sample mean with its 95% confidence interval from the
+// t distribution.
+type MeanInterval struct {
+	N      int     `json:"n"`
+	Mean   float64 `json:"mean"`
+	StdDev float64 `json:"std_dev"`
+	Low    float64 `json:"ci95_low"`
+	High   float64 `json:"ci95_high"`
+}
+
+func meanInterval(values []float64) MeanInterval {
+	interval := MeanInterval{N: len(values)}
+	if len(values) == 0 {
+		return interval
+	}
+
+	for _, value := range values {
+		interval.Mean += value
+	}
+	interval.Mean /= float64(len(values))
+	interval.Low, interval.High = interval.Mean, interval.Mean
+	if len(values) < 2 {
+		return interval
+	}
+
+	var squares float64
+	for _, value := range values {
+		squares += (value - interval.Mean) * (value - interval.Mean)
+	}
+	interval.StdDev = math.Sqrt(squares / float64(len(values)-1))
+
+	margin := studentT975(len(values)-1) * interval.StdDev / math.Sqrt(float64(len(values)))
+	interval.Low = interval.Mean - margin
+	interval.High = interval.Mean + margin
+	return interval
+}
+
+// studentT975 returns the 97.5th percentile of the t distribution with df
+// degrees of freedom, interpolated between tabulated values.
+func studentT975(df int) float64 {
+	table := []float64{
+		12.706, 4.303, 3.182, 2.776, 2.571, 2.447, 2.365, 2.306, 2.262, 2.228,
+		2.201, 2.179, 2.160, 2.145, 2.131, 2.120, 2.110, 2.101, 2.093, 2.086,
+		2.080, 2.074, 2.069, 2.064, 2.060, 2.056, 2.052, 2.048, 2.045, 2.042,
+	}
+	switch {
+	case df < 1:
+		return math.NaN()
+	case df <= len(table):
+		return table[df-1]
+	case df <= 40:
+		return 2.042 + (2.021-2.042)*float64(df-30)/10
+	case df <= 60:
+		return 2.021 + (2.000-2.021)*float64(df-40)/20
+	case df <= 120:
+		return 2.000 + (1.980-2.000)*float64(df-60)/60
+	}
+	return 1.960
+}
+
+// WilcoxonResult is the outcome of a two-sided Wilcoxon signed-rank test.
+// Pairs with no difference are dropped, so N can be below the sample size.
+type WilcoxonResult struct {
+	N      int     `json:"n"`
+	WPlus  float64 `json:"w_plus"`
+	WMinus float64 `json:"w_minus"`
+	Z      float64 `json:"z,omitempty"`
+	PValue float64 `json:"p_value"`
+	Exact  bool    `json:"exact"`
+}
+
+func wilcoxonSignedRank(a, b []float64) WilcoxonResult {
+	var differences []float64
+	for i := range a {
+		if d := a[i] - b[i]; d != 0 {
+			differences = append(differences, d)
+		}
+	}
+
+	result := WilcoxonResult{N: len(differences), PValue: 1}
+	if result.N == 0 {
+		return result
+	}
+
+	sort.Slice(differences, func(i, j int) bool {
+		return math.Abs(differences[i]) < math.Abs(differences[j])
+	})
+
+	// Tied magnitudes share the average of their ranks.
+	ties := false
+	var tieCorrection float64
+	for start := 0; start < len(differences); {
+		end := start + 1
+		for end < len(differences) && math.Abs(differences[end]) == math.Abs(differences[start]) {
+			end++
+		}
+		rank := float64(start+end+1) / 2
+		for _, d := range differences[start:end] {
+			if d > 0 {
+				result.WPlus += rank
+			} else {
+				result.WMinus += rank
+			}
+		}
+		if count := float64(end - start); count > 1 {
+			ties = true
+			tieCorrection += count*count*count - count
+		}
+		start = end
+	}
+
+	smaller := math.Min(result.WPlus, result.WMinus)
+	if result.N <= wilcoxonExactMaxPairs && !ties {
+		result.Exact = true
+		result.PValue = math.Min(1, 2*wilcoxonLowerTail(result.N, int(smaller)))
+		return result
+	}
+
+	n := float64(result.N)
+	mean := n * (n + 1) / 4
+	variance := n*(n+1)*(2*n+1)/24 - tieCorrection/48
+	if variance <= 0 {
+		return result
+	}
+	// Continuity correction toward the mean.
+	result.Z = (smaller - mean + 0.5) / math.Sqrt(variance)
+	result.PValue = math.Min(1, math.Erfc(math.Abs(result.Z)/math.Sqrt2))
+	return result
+}
+
+// wilcoxonLowerTail is P(W <= w) for the signed-rank statistic of n pairs
+// without ties, counting the subsets of ranks 1..n by their sum.
+func wilcoxonLowerTail(n, w int) float64 {
+	maxSum := n * (n + 1) / 2
+	counts := make([]float64, maxSum+1)
+	counts[0] = 1
+	for rank := 1; rank <= n; rank++ {
+		for sum := maxSum; sum >= rank; sum-- {
+			counts[sum] += counts[sum-rank]
+		}
+	}
+
+	var tail float64
+	for sum := 0; sum <= w && sum <= maxSum; sum++ {
+		tail += counts[sum]
+	}
+	return tail / math.Pow(2, float64(n))
+}
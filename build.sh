@@ -116,6 +116,11 @@ build() {
             extra_flags="-tags ${BUILD_TAGS}"
             log "Cross-compiling for Linux AMD64"
             ;;
+        "headless")
+            output_name="${BINARY_NAME}-headless"
+            extra_flags="-tags ${BUILD_TAGS},nogui"
+            log "Building CLI-only binary without GUI dependencies"
+            ;;
         "all")
             log "Building for all supported platforms"
             build "windows"
@@ -189,6 +194,7 @@ BUILD TARGETS:
   macos            macOS Intel 64-bit  
   macos-arm64      macOS Apple Silicon
   linux            Linux 64-bit
+  headless         CLI only, no Fyne/X11 (nogui tag)
   all              All supported platforms
 
 EXAMPLES:
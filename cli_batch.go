@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// batchCommand processes a list of images or directories with one parameter
+// set and writes a provenance manifest.
+const batchCommand = "batch"
+
+// LoadBatchConfig resolves batch settings with the same precedence as
+// LoadHeadlessConfig. Inputs are the positional arguments.
+func LoadBatchConfig(args []string) (*BatchConfig, error) {
+	flags := flag.NewFlagSet(batchCommand, flag.ContinueOnError)
+
+	outputDir := flags.String("output-dir", os.Getenv(envOutputDir), "directory for processed images ($"+envOutputDir+")")
+	outputFormat := flags.String("format", envOrDefault(envOutputFormat, "png"), "output format, png, jpg or tif ($"+envOutputFormat+")")
+	tiffCompression := flags.String("tiff-compression", TIFFCompressionDeflate, "compression of tif outputs: "+strings.Join(TIFFCompressions, ", "))
+	nameTemplate := flags.String("name-template", envOrDefault(envNameTemplate, DefaultOutputNameTemplate),
+		"output file name without extension, with tokens {name} {algorithm} {preset} {date} {fmeasure} ($"+envNameTemplate+")")
+	onExists := flags.String("on-exists", envOrDefault(envOnExists, CollisionOverwrite),
+		"when an output exists: overwrite, skip, or version to write file-v2.png and so on ($"+envOnExists+")")
+	layout := flags.String("layout", os.Getenv(envLayout), "write the text regions of each output as page or alto XML next to it ($"+envLayout+")")
+	manifestPath := flags.String("manifest", os.Getenv(envManifest), "write a provenance manifest to `path`, default <output-dir>/manifest.json ($"+envManifest+")")
+	manifestFormat := flags.String("manifest-format", os.Getenv(envManifestFormat), "json or csv, default from the manifest extension ($"+envManifestFormat+")")
+	embedDefault, _ := strconv.ParseBool(os.Getenv(envEmbedProvenance))
+	embedProvenance := flags.Bool("embed-provenance", embedDefault, "store source hash, algorithm and parameters as PNG text chunks ($"+envEmbedProvenance+")")
+	provenanceKey := flags.String("sign-provenance", os.Getenv(envProvenanceKey), "embed a provenance record signed with the Ed25519 key `file` in every png or tif output ($"+envProvenanceKey+")")
+	checkpointPath := flags.String("checkpoint", os.Getenv(envCheckpoint), "record progress in `path` after every image, default <output-dir>/checkpoint.json ($"+envCheckpoint+")")
+	jobs := flags.String("jobs", envOrDefault(envJobs, "0"), "process up to this many images at once, 0 for one per CPU ($"+envJobs+")")
+	memoryBudget := flags.String("memory-budget", envOrDefault(envMemoryBudget, "0"), "cap the estimated working memory of concurrent images in MB, 0 for 75% of free memory ($"+envMemoryBudget+")")
+	retries := flags.String("retries", envOrDefault(envRetries, "1"), "extra attempts for a failed image ($"+envRetries+")")
+	retryOn := flags.String("retry-on", envOrDefault(envRetryOn, DefaultRetryRules), "failure category=action rules, actions retry, tiled or downscale ($"+envRetryOn+")")
+	failureReport := flags.String("failure-report", os.Getenv(envFailureReport), "write failures grouped by category to `path`, default <output-dir>/failures.json ($"+envFailureReport+")")
+	duplicates := flags.String("duplicates", envOrDefault(envDuplicates, DuplicatesWarn),
+		"inputs that look like an earlier input: warn, unique to process only the first and map the rest to its output, or off to skip the check ($"+envDuplicates+")")
+	batterySaver := flags.String("battery-saver", envOrDefault(envBatterySaver, BatterySaverOff),
+		"limit the batch to "+strconv.Itoa(batterySaverMaxWorkers)+" images and OpenCV threads at once: on, off, or auto while on battery ($"+envBatterySaver+")")
+	resume := flags.Bool("resume", false, "skip images the checkpoint records as done whose source and output are unchanged")
+	crop := addCropFlags(flags)
+	processing := addProcessingFlags(flags)
+
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	processingConfig, err := processing.resolve()
+	if err != nil {
+		return nil, err
+	}
+
+	if flags.NArg() == 0 {
+		return nil, fmt.Errorf("no inputs: pass image files or directories")
+	}
+	if *outputDir == "" {
+		return nil, fmt.Errorf("no output directory: pass -output-dir or set %s", envOutputDir)
+	}
+
+	switch *outputFormat {
+	case "png", "jpg", "tif":
+	case "jpeg":
+		*outputFormat = "jpg"
+	case "tiff":
+		*outputFormat = "tif"
+	default:
+		return nil, fmt.Errorf("unknown output format %q: expected png, jpg or tif", *outputFormat)
+	}
+
+	encoder := DefaultEncodeOptions()
+	encoder.TIFFCompression = *tiffCompression
+	if err := encoder.Validate(); err != nil {
+		return nil, err
+	}
+
+	if err := ValidateOutputNameTemplate(*nameTemplate); err != nil {
+		return nil, err
+	}
+	if err := validateCollisionPolicy(*onExists); err != nil {
+		return nil, err
+	}
+	if err := validateDuplicatePolicy(*duplicates); err != nil {
+		return nil, err
+	}
+	if err := validateBatterySaverMode(*batterySaver); err != nil {
+		return nil, err
+	}
+	if *layout != "" {
+		if err := validateLayoutFormat(*layout); err != nil {
+			return nil, err
+		}
+	}
+
+	cropOptions, err := crop.resolve()
+	if err != nil {
+		return nil, err
+	}
+
+	jobCount, err := strconv.Atoi(*jobs)
+	if err != nil || jobCount < 0 {
+		return nil, fmt.Errorf("jobs %q: expected a non-negative integer", *jobs)
+	}
+	memoryBudgetMB, err := strconv.Atoi(*memoryBudget)
+	if err != nil || memoryBudgetMB < 0 {
+		return nil, fmt.Errorf("memory budget %q: expected a non-negative number of megabytes", *memoryBudget)
+	}
+
+	retryCount, err := strconv.Atoi(*retries)
+	if err != nil || retryCount < 0 {
+		return nil, fmt.Errorf("retries %q: expected a non-negative integer", *retries)
+	}
+	retryActions, err := ParseRetryRules(*retryOn)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &BatchConfig{
+		ProcessingConfig: processingConfig,
+		Inputs:           flags.Args(),
+		OutputDir:        *outputDir,
+		OutputFormat:     *outputFormat,
+		TIFFCompression:  *tiffCompression,
+		NameTemplate:     *nameTemplate,
+		OnExists:         *onExists,
+		Layout:           *layout,
+		Crop:             cropOptions,
+		ManifestPath:     *manifestPath,
+		ManifestFormat:   *manifestFormat,
+		EmbedProvenance:  *embedProvenance,
+		CheckpointPath:   *checkpointPath,
+		Resume:           *resume,
+		Jobs:             jobCount,
+		MemoryBudgetMB:   memoryBudgetMB,
+		Retry:            BatchRetryPolicy{MaxRetries: retryCount, Actions: retryActions},
+		FailureReport:    *failureReport,
+		Duplicates:       *duplicates,
+		BatterySaver:     *batterySaver,
+	}
+
+	if config.ManifestPath == "" {
+		extension := ManifestFormatJSON
+		if config.ManifestFormat == ManifestFormatCSV {
+			extension = ManifestFormatCSV
+		}
+		config.ManifestPath = filepath.Join(config.OutputDir, "manifest."+extension)
+	}
+	if _, err := manifestFormatForPath(config.ManifestPath, config.ManifestFormat); err != nil {
+		return nil, err
+	}
+
+	if config.FailureReport == "" {
+		config.FailureReport = filepath.Join(config.OutputDir, "failures.json")
+	}
+	if config.CheckpointPath == "" {
+		config.CheckpointPath = filepath.Join(config.OutputDir, "checkpoint.json")
+	}
+
+	if *provenanceKey != "" {
+		if !canCarryProvenance("." + config.OutputFormat) {
+			return nil, fmt.Errorf("signed provenance needs png or tif output, not %s", config.OutputFormat)
+		}
+		if config.ProvenanceKey, err = LoadProvenanceSigningKey(*provenanceKey); err != nil {
+			return nil, err
+		}
+	}
+
+	return config, nil
+}
+
+// runBatchCommand implements `otsu-obliterator batch`. It exits with 1 when
+// any image failed, after writing the manifest for the whole run.
+func runBatchCommand(ctx context.Context, args []string) int {
+	config, err := LoadBatchConfig(args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		fmt.Fprintf(os.Stderr, "%s: %v\n", batchCommand, err)
+		return 2
+	}
+
+	debugSystem := configureHeadlessLogging(config.LogLevel)
+	defer debugSystem.Close()
+
+	saving, _ := ResolveBatterySaver(config.BatterySaver)
+	SetBatterySaver(saving)
+
+	runner, err := NewBatchRunner(config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", batchCommand, err)
+		return 2
+	}
+
+	items, err := runner.Plan()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", batchCommand, err)
+		return 2
+	}
+
+	if err := os.MkdirAll(config.OutputDir, 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: create output directory: %v\n", batchCommand, err)
+		return 1
+	}
+
+	if config.Duplicates != DuplicatesOff {
+		reportDuplicates(runner.FindDuplicates(ctx, items), config.Duplicates)
+	}
+
+	runner.OnItemDone = func(index, total int, result *BatchResult) {
+		status := "ok"
+		if result.Skipped {
+			status = "already done"
+		} else if result.DuplicateOf != "" {
+			status = "duplicate of " + result.DuplicateOf
+		} else if result.Entry.Status == manifestStatusSkipped {
+			status = "skipped, output exists"
+		} else if result.Err != nil {
+			status = "FAILED: " + result.Err.Error()
+		}
+		fmt.Fprintf(os.Stderr, "[%d/%d] %s %s (%.1fs)\n",
+			index+1, total, result.Item.Input, status, result.Duration.Seconds())
+	}
+
+	results := runner.Run(ctx, items)
+
+	if err := runner.Manifest().Write(config.ManifestPath, config.ManifestFormat); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", batchCommand, err)
+		return 1
+	}
+
+	failed, skipped, existing, duplicates := 0, 0, 0, 0
+	for _, result := range results {
+		if result.Err != nil {
+			failed++
+		}
+		if result.Skipped {
+			skipped++
+		} else if result.DuplicateOf != "" {
+			duplicates++
+		} else if result.Entry.Status == manifestStatusSkipped {
+			existing++
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "%d of %d images processed (%d already done, %d skipped as existing, %d duplicates), %d failed; manifest: %s\n",
+		len(results)-failed-duplicates, len(items), skipped, existing, duplicates, failed, config.ManifestPath)
+
+	if failed > 0 {
+		report := NewBatchFailureReport(results, len(items))
+		for _, category := range report.Categories {
+			fmt.Fprintf(os.Stderr, "  %s: %d image(s) - %s\n", category.Category, len(category.Items), category.Remediation)
+		}
+		if err := report.Write(config.FailureReport); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", batchCommand, err)
+		} else {
+			fmt.Fprintf(os.Stderr, "failure report: %s\n", config.FailureReport)
+		}
+	}
+
+	if failed > 0 || len(results) < len(items) {
+		return 1
+	}
+	return 0
+}
+
+// reportDuplicates lists on stderr the inputs that repeat an earlier one.
+func reportDuplicates(groups []DuplicateGroup, policy string) {
+	if len(groups) == 0 {
+		return
+	}
+
+	count := 0
+	for _, group := range groups {
+		count += len(group.Duplicates)
+	}
+	fmt.Fprintf(os.Stderr, "%d input(s) look like an earlier input:\n", count)
+	for _, group := range groups {
+		for _, duplicate := range group.Duplicates {
+			match := fmt.Sprintf("perceptual hash distance %d", duplicate.Distance)
+			if duplicate.Identical {
+				match = "same file"
+			}
+			fmt.Fprintf(os.Stderr, "  %s repeats %s (%s)\n", duplicate.Item.Input, group.Original.Input, match)
+		}
+	}
+	if policy == DuplicatesWarn {
+		fmt.Fprintf(os.Stderr, "all are processed; pass -duplicates unique to process each only once\n")
+	}
+}
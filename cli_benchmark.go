@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// benchmarkCommand scores parameter sets against ground truth and tests
+// whether their differences are significant.
+const benchmarkCommand = "benchmark"
+
+// LoadBenchmarkConfig resolves benchmark settings. Candidates are given as
+// name=source, where source is an algorithm name, a parameter file or
+// inline parameter JSON applied over the shared processing flags.
+func LoadBenchmarkConfig(args []string) (*BenchmarkConfig, string, string, error) {
+	flags := flag.NewFlagSet(benchmarkCommand, flag.ContinueOnError)
+
+	groundTruthDir := flags.String("ground-truth", os.Getenv(envGroundTruth), "`directory` with a ground truth image per input, named like it with an optional _gt suffix ($"+envGroundTruth+")")
+	datasetName := flags.String("dataset", "", "benchmark the images of a registered dataset instead of the inputs")
+	reportPath := flags.String("report", envOrDefault(envBenchmarkReport, "benchmark.json"), "write the report JSON to `path` ($"+envBenchmarkReport+")")
+	chartDir := flags.String("charts", "", "write box plots and a time scatter as SVG into `directory`")
+	alpha := flags.Float64("alpha", DefaultSignificanceLevel, "significance level of the paired Wilcoxon tests")
+	var candidateSources []string
+	flags.Func("candidate", "`name=source` to evaluate, where source is an algorithm name, a parameter file or inline JSON; repeat for each candidate", func(value string) error {
+		candidateSources = append(candidateSources, value)
+		return nil
+	})
+	processing := addProcessingFlags(flags)
+
+	if err := flags.Parse(args); err != nil {
+		return nil, "", "", err
+	}
+
+	processingConfig, err := processing.resolve()
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	if len(candidateSources) == 0 {
+		return nil, "", "", fmt.Errorf("no candidates: pass -candidate name=source at least once")
+	}
+	if *alpha <= 0 || *alpha >= 1 {
+		return nil, "", "", fmt.Errorf("alpha %v: expected a value between 0 and 1", *alpha)
+	}
+
+	config := &BenchmarkConfig{ProcessingConfig: processingConfig, Alpha: *alpha}
+	names := make(map[string]bool)
+	for _, value := range candidateSources {
+		candidate, err := parseBenchmarkCandidate(value, processingConfig.Params)
+		if err != nil {
+			return nil, "", "", err
+		}
+		if names[candidate.Name] {
+			return nil, "", "", fmt.Errorf("candidate %q given twice", candidate.Name)
+		}
+		names[candidate.Name] = true
+		config.Candidates = append(config.Candidates, candidate)
+	}
+
+	config.Pairs, err = groundTruthPairs(*datasetName, *groundTruthDir, flags.Args())
+	if err != nil {
+		return nil, "", "", err
+	}
+	return config, *reportPath, *chartDir, nil
+}
+
+// groundTruthPairs returns the valid pairs of a registered dataset or, when
+// datasetName is empty, pairs each image found in inputs with its ground
+// truth in groundTruthDir.
+func groundTruthPairs(datasetName, groundTruthDir string, inputs []string) ([]BenchmarkPair, error) {
+	if (datasetName == "") == (groundTruthDir == "") {
+		return nil, fmt.Errorf("no ground truth: pass either -dataset or -ground-truth (or set %s)", envGroundTruth)
+	}
+	if (datasetName == "") == (len(inputs) == 0) {
+		return nil, fmt.Errorf("pass image files or directories with -ground-truth, or none with -dataset")
+	}
+	if datasetName != "" {
+		return datasetPairs(datasetName)
+	}
+
+	images, err := expandImageInputs(inputs)
+	if err != nil {
+		return nil, fmt.Errorf("input: %w", err)
+	}
+	dataset := &Dataset{GroundTruthDir: groundTruthDir}
+	var pairs []BenchmarkPair
+	for _, image := range images {
+		truth, err := dataset.groundTruthFor(image)
+		if err != nil {
+			return nil, err
+		}
+		pairs = append(pairs, BenchmarkPair{Image: image, GroundTruth: truth})
+	}
+	return pairs, nil
+}
+
+// datasetPairs validates a registered dataset and returns its valid pairs,
+// listing the problems on stderr.
+func datasetPairs(name string) ([]BenchmarkPair, error) {
+	registry, err := LoadDatasetRegistry(DefaultDatasetRegistryPath())
+	if err != nil {
+		return nil, err
+	}
+	dataset, err := registry.Find(name)
+	if err != nil {
+		return nil, err
+	}
+
+	pairs, problems, err := dataset.Validate()
+	if err != nil {
+		return nil, err
+	}
+	for _, problem := range problems {
+		fmt.Fprintf(os.Stderr, "dataset %s: skipped %s\n", name, problem)
+	}
+	if len(pairs) == 0 {
+		return nil, fmt.Errorf("dataset %s has no valid image and ground truth pairs", name)
+	}
+	return pairs, nil
+}
+
+func parseBenchmarkCandidate(value string, base *OtsuParameters) (BenchmarkCandidate, error) {
+	name, source, ok := strings.Cut(value, "=")
+	if !ok || name == "" || source == "" {
+		return BenchmarkCandidate{}, fmt.Errorf("candidate %q: expected name=source", value)
+	}
+
+	params := *base
+	switch source {
+	case AlgorithmSingleScale, AlgorithmMultiScale, AlgorithmRegionAdaptive, AlgorithmNeural, AlgorithmOtsu3D,
+		AlgorithmKapur, AlgorithmTsallis:
+		if err := applyAlgorithm(&params, source); err != nil {
+			return BenchmarkCandidate{}, err
+		}
+		return BenchmarkCandidate{Name: name, Params: &params}, nil
+	}
+
+	loaded, err := loadParameterSource(source, &params)
+	if err != nil {
+		return BenchmarkCandidate{}, fmt.Errorf("candidate %s: %w", name, err)
+	}
+	return BenchmarkCandidate{Name: name, Params: loaded}, nil
+}
+
+// runBenchmarkCommand implements `otsu-obliterator benchmark`. It prints
+// the summary table to stdout and exits with 1 when any run failed.
+func runBenchmarkCommand(ctx context.Context, args []string) int {
+	config, reportPath, chartDir, err := LoadBenchmarkConfig(args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		fmt.Fprintf(os.Stderr, "%s: %v\n", benchmarkCommand, err)
+		return 2
+	}
+
+	debugSystem := configureHeadlessLogging(config.LogLevel)
+	defer debugSystem.Close()
+
+	failed := 0
+	report, err := runBenchmark(ctx, config, func(run BenchmarkRun) {
+		if run.Error != "" {
+			failed++
+			fmt.Fprintf(os.Stderr, "%s [%s]: FAILED: %s\n", run.Image, run.Candidate, run.Error)
+			return
+		}
+		fmt.Fprintf(os.Stderr, "%s [%s]: F-measure %.4f in %d ms\n", run.Image, run.Candidate, run.Metrics.FMeasure, run.DurationMS)
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", benchmarkCommand, err)
+		return 1
+	}
+
+	if err := report.Write(reportPath); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", benchmarkCommand, err)
+		return 1
+	}
+	writeBenchmarkSummary(os.Stdout, report)
+	fmt.Fprintf(os.Stderr, "report written to %s\n", reportPath)
+
+	if chartDir != "" {
+		if err := WriteBenchmarkCharts(report, chartDir); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", benchmarkCommand, err)
+			return 1
+		}
+		fmt.Fprintf(os.Stderr, "charts written to %s\n", chartDir)
+	}
+
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// writeBenchmarkSummary prints mean ± 95% CI per metric and candidate, then
+// the paired comparisons with significant ones marked.
+func writeBenchmarkSummary(w io.Writer, report *BenchmarkReport) {
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprint(table, "metric")
+	for _, candidate := range report.Candidates {
+		fmt.Fprintf(table, "\t%s", candidate.Name)
+	}
+	fmt.Fprintln(table)
+	for _, metric := range benchmarkMetrics {
+		fmt.Fprint(table, metric.Name)
+		for _, summary := range report.Summaries {
+			if summary.Metric == metric.Name {
+				fmt.Fprintf(table, "\t%.4f ± %.4f (n=%d)", summary.Mean, summary.High-summary.Mean, summary.N)
+			}
+		}
+		fmt.Fprintln(table)
+	}
+	table.Flush()
+
+	if len(report.Comparisons) == 0 {
+		return
+	}
+
+	fmt.Fprintf(w, "\nWilcoxon signed-rank, alpha %s:\n", strconv.FormatFloat(report.Alpha, 'g', -1, 64))
+	table = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "metric\tcomparison\tmean difference\tn\tp\tresult")
+	for _, comparison := range report.Comparisons {
+		result := "no significant difference"
+		if comparison.Significant {
+			result = "* " + comparison.Better + " better"
+		}
+		fmt.Fprintf(table, "%s\t%s vs %s\t%+.4f\t%d\t%.4g\t%s\n",
+			comparison.Metric, comparison.A, comparison.B, comparison.MeanDifference,
+			comparison.Wilcoxon.N, comparison.Wilcoxon.PValue, result)
+	}
+	table.Flush()
+}
@@ -0,0 +1,418 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Environment variables read by the headless modes. Flags override them and
+// they override the parameter file, which overrides the built-in defaults.
+const (
+	envInput         = "OTSU_INPUT"
+	envOutput        = "OTSU_OUTPUT"
+	envMetricsOutput = "OTSU_METRICS_OUTPUT"
+	envAlgorithm     = "OTSU_ALGORITHM"
+	envParams        = "OTSU_PARAMS"
+	envLogLevel      = "OTSU_LOG_LEVEL"
+	envTimeout       = "OTSU_TIMEOUT"
+	envMaxMegapixels = "OTSU_MAX_MEGAPIXELS"
+	envAllowExternal = "OTSU_ALLOW_EXTERNAL_STAGES"
+	envRegionAudit   = "OTSU_REGION_AUDIT"
+	envProvenanceKey = "OTSU_PROVENANCE_KEY"
+
+	envOutputDir       = "OTSU_OUTPUT_DIR"
+	envOutputFormat    = "OTSU_OUTPUT_FORMAT"
+	envManifest        = "OTSU_MANIFEST"
+	envManifestFormat  = "OTSU_MANIFEST_FORMAT"
+	envEmbedProvenance = "OTSU_EMBED_PROVENANCE"
+	envCheckpoint      = "OTSU_CHECKPOINT"
+	envJobs            = "OTSU_JOBS"
+	envMemoryBudget    = "OTSU_MEMORY_BUDGET"
+	envRetries         = "OTSU_RETRIES"
+	envRetryOn         = "OTSU_RETRY_ON"
+	envFailureReport   = "OTSU_FAILURE_REPORT"
+	envNameTemplate    = "OTSU_NAME_TEMPLATE"
+	envOnExists        = "OTSU_ON_EXISTS"
+	envLayout          = "OTSU_LAYOUT"
+	envAutoCrop        = "OTSU_AUTO_CROP"
+	envCropMargin      = "OTSU_CROP_MARGIN"
+	envDuplicates      = "OTSU_DUPLICATES"
+	envBatterySaver    = "OTSU_BATTERY_SAVER"
+
+	envFarmListen      = "OTSU_FARM_LISTEN"
+	envFarmSpool       = "OTSU_FARM_SPOOL"
+	envFarmCoordinator = "OTSU_COORDINATOR"
+	envFarmWorker      = "OTSU_WORKER_NAME"
+
+	envCacheDir   = "OTSU_CACHE_DIR"
+	envCacheMaxMB = "OTSU_CACHE_MAX_MB"
+
+	envGroundTruth     = "OTSU_GROUND_TRUTH"
+	envBenchmarkReport = "OTSU_BENCHMARK_REPORT"
+	envDatasets        = "OTSU_DATASETS"
+	envProfiles        = "OTSU_PROFILES"
+
+	envModel         = "OTSU_MODEL"
+	envModelRegistry = "OTSU_MODEL_REGISTRY"
+
+	envColorManagement = "OTSU_COLOR_MANAGEMENT"
+)
+
+const (
+	AlgorithmSingleScale    = "single-scale"
+	AlgorithmMultiScale     = "multi-scale"
+	AlgorithmRegionAdaptive = "region-adaptive"
+	AlgorithmNeural         = "neural"
+	AlgorithmOtsu3D         = "otsu-3d"
+	AlgorithmKapur          = "kapur"
+	AlgorithmTsallis        = "tsallis"
+)
+
+// ProcessingConfig holds the settings shared by every headless command.
+type ProcessingConfig struct {
+	Algorithm     string
+	Params        *OtsuParameters
+	Preset        string
+	LogLevel      slog.Level
+	Timeout       time.Duration
+	MaxMegapixels float64
+}
+
+// HeadlessConfig holds the resolved settings of a single-image run.
+type HeadlessConfig struct {
+	ProcessingConfig
+	Input         string
+	Output        string
+	MetricsOutput string
+	RegionAudit   string
+	InkColors     string
+	Report        string
+	Crop          *CropOptions
+	Layout        string
+	LayoutFormat  string
+	Vector        string
+	VectorOptions TraceOptions
+
+	// Render names a directory for the annotated images of the result,
+	// with an error map when GroundTruth is set. GroundTruth is also what
+	// the metrics are scored against.
+	Render      string
+	GroundTruth string
+
+	// SplitSpread, SplitBefore or SplitAfter, writes the two pages of a
+	// book spread as separate outputs, cut at column SplitAt or at the
+	// detected gutter when SplitAt is 0.
+	SplitSpread string
+	SplitAt     int
+
+	// ProvenanceKey, when set, signs a provenance record embedded in the
+	// output image.
+	ProvenanceKey ed25519.PrivateKey
+}
+
+// cropFlags registers the output cropping flags of the commands that write
+// results.
+type cropFlags struct {
+	enabled *bool
+	margin  *string
+}
+
+func addCropFlags(flags *flag.FlagSet) *cropFlags {
+	enabledDefault, _ := strconv.ParseBool(os.Getenv(envAutoCrop))
+	return &cropFlags{
+		enabled: flags.Bool("auto-crop", enabledDefault, "trim outputs to the bounding box of their content, ignoring ink on the image edges ($"+envAutoCrop+")"),
+		margin:  flags.String("crop-margin", envOrDefault(envCropMargin, strconv.Itoa(DefaultCropMargin)), "pixels kept around the content with -auto-crop ($"+envCropMargin+")"),
+	}
+}
+
+// resolve returns the crop options, or nil when outputs are not cropped.
+func (cf *cropFlags) resolve() (*CropOptions, error) {
+	margin, err := strconv.Atoi(*cf.margin)
+	if err != nil || margin < 0 {
+		return nil, fmt.Errorf("crop margin %q: expected a non-negative number of pixels", *cf.margin)
+	}
+	if !*cf.enabled {
+		return nil, nil
+	}
+	return &CropOptions{Margin: margin}, nil
+}
+
+// processingFlags registers the flags behind ProcessingConfig on a command's
+// flag set; resolve reads them back once the set has been parsed.
+type processingFlags struct {
+	algorithm       *string
+	params          *string
+	logLevel        *string
+	timeout         *string
+	maxMegapixels   *string
+	allowExternal   *bool
+	model           *string
+	colorManagement *bool
+}
+
+func addProcessingFlags(flags *flag.FlagSet) *processingFlags {
+	allowExternalDefault, _ := strconv.ParseBool(os.Getenv(envAllowExternal))
+	colorManagementDefault, err := strconv.ParseBool(envOrDefault(envColorManagement, "true"))
+	if err != nil {
+		colorManagementDefault = true
+	}
+	return &processingFlags{
+		algorithm: flags.String("algorithm", os.Getenv(envAlgorithm), "single-scale, multi-scale, region-adaptive, neural, otsu-3d, kapur or tsallis ($"+envAlgorithm+")"),
+		params:    flags.String("params", os.Getenv(envParams), "parameter JSON `file` or inline JSON object ($"+envParams+")"),
+		logLevel:  flags.String("log-level", envOrDefault(envLogLevel, "warn"), "debug, info, warn or error ($"+envLogLevel+")"),
+		timeout:   flags.String("timeout", os.Getenv(envTimeout), "time limit per image such as 5m, 0 for the per-method default ($"+envTimeout+")"),
+		maxMegapixels: flags.String("max-megapixels", envOrDefault(envMaxMegapixels, strconv.FormatFloat(DefaultMaxWorkingMegapixels, 'f', -1, 64)),
+			"downscale larger images to this size, 0 for no limit ($"+envMaxMegapixels+")"),
+		allowExternal: flags.Bool("allow-external-stages", allowExternalDefault, "run the external command stages of the parameter set ($"+envAllowExternal+")"),
+		model:         flags.String("model", os.Getenv(envModel), "ONNX model `file`, or the name of a downloaded model, of the neural algorithm ($"+envModel+")"),
+		colorManagement: flags.Bool("color-management", colorManagementDefault,
+			"convert images with an embedded ICC profile to sRGB before processing ($"+envColorManagement+")"),
+	}
+}
+
+func (pf *processingFlags) resolve() (ProcessingConfig, error) {
+	config := ProcessingConfig{
+		Algorithm: *pf.algorithm,
+		Params:    DefaultOtsuParameters(),
+		Preset:    outputNamePreset(*pf.params),
+	}
+
+	if err := config.LogLevel.UnmarshalText([]byte(*pf.logLevel)); err != nil {
+		return config, fmt.Errorf("log level %q: %w", *pf.logLevel, err)
+	}
+
+	if *pf.timeout != "" {
+		duration, err := time.ParseDuration(*pf.timeout)
+		if err != nil || duration < 0 {
+			return config, fmt.Errorf("timeout %q: expected a duration such as 90s or 5m", *pf.timeout)
+		}
+		config.Timeout = duration
+	}
+
+	maxMegapixels, err := strconv.ParseFloat(*pf.maxMegapixels, 64)
+	if err != nil || maxMegapixels < 0 {
+		return config, fmt.Errorf("max megapixels %q: expected a non-negative number", *pf.maxMegapixels)
+	}
+	config.MaxMegapixels = maxMegapixels
+
+	if *pf.params != "" {
+		loaded, err := loadParameterSource(*pf.params, config.Params)
+		if err != nil {
+			return config, err
+		}
+		config.Params = loaded
+	}
+
+	if *pf.model != "" {
+		config.Params.NeuralModel = ResolveModelPath(*pf.model)
+	}
+	if err := applyAlgorithm(config.Params, config.Algorithm); err != nil {
+		return config, err
+	}
+
+	if len(config.Params.ExternalStages) > 0 && !*pf.allowExternal {
+		return config, fmt.Errorf("the parameters declare %d external stages; pass -allow-external-stages to run them",
+			len(config.Params.ExternalStages))
+	}
+	SetExternalStagesAllowed(*pf.allowExternal)
+	SetColorManagement(*pf.colorManagement)
+
+	return config, nil
+}
+
+// LoadHeadlessConfig resolves configuration from defaults, the parameter
+// file, environment variables and args, in increasing order of precedence.
+func LoadHeadlessConfig(name string, args []string) (*HeadlessConfig, error) {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+
+	input := flags.String("input", os.Getenv(envInput), "input image `path` ($"+envInput+")")
+	output := flags.String("output", os.Getenv(envOutput), "output image `path`, .png, .jpg or .tif ($"+envOutput+")")
+	metricsOutput := flags.String("metrics", os.Getenv(envMetricsOutput), "write metrics JSON to `path`, - for stdout ($"+envMetricsOutput+")")
+	regionAudit := flags.String("region-audit", os.Getenv(envRegionAudit), "write the per-region decisions of the region-adaptive algorithm as JSON to `path` ($"+envRegionAudit+")")
+	inkColors := flags.String("ink-colors", "", "write the mean ink color, ink color clusters and suggested grayscale channel as JSON to `path`, - for stdout")
+	report := flags.String("report", "", "write a printable PDF report of the original, result, parameters and metrics to `path`")
+	layout := flags.String("layout", "", "write the text regions of the result as a PAGE-XML or ALTO skeleton to `path`")
+	layoutFormat := flags.String("layout-format", LayoutPAGE, "layout format, page or alto")
+	vector := flags.String("vector", "", "trace the result into vector outlines written to an .svg or .pdf `path`")
+	vectorOptions := flags.String("vector-options", "", "tracing `options` as tolerance=1,smoothness=0.8,min-area=4; smoothness runs from 0, sharp corners, to 1")
+	provenanceKey := flags.String("sign-provenance", os.Getenv(envProvenanceKey), "embed a provenance record signed with the Ed25519 key `file` in the png or tif output ($"+envProvenanceKey+")")
+	render := flags.String("render", "", "write the comparison, decision, density and error images the window shows as PNG files to `directory`")
+	groundTruth := flags.String("ground-truth", "", "ground truth image `path` to score -metrics against and for the error map of -render")
+	splitSpread := flags.String("split-spread", "", "write the two pages of a book spread as <output>_left and <output>_right, binarized before or after the split")
+	splitAt := flags.Int("split-at", 0, "column of the split line with -split-spread, 0 to detect the gutter")
+	crop := addCropFlags(flags)
+	processing := addProcessingFlags(flags)
+
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	// A lone positional argument is accepted as the input for convenience.
+	if *input == "" && flags.NArg() == 1 {
+		*input = flags.Arg(0)
+	} else if flags.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %s", strings.Join(flags.Args(), " "))
+	}
+
+	processingConfig, err := processing.resolve()
+	if err != nil {
+		return nil, err
+	}
+
+	if *input == "" {
+		return nil, fmt.Errorf("no input image: pass -input or set %s", envInput)
+	}
+	if _, err := os.Stat(*input); err != nil {
+		matches := globImageInputs(*input)
+		if len(matches) > 1 {
+			return nil, fmt.Errorf("input pattern %q matches %d images; process takes one, use batch for several", *input, len(matches))
+		}
+		if len(matches) == 1 {
+			*input = matches[0]
+		}
+	}
+
+	config := &HeadlessConfig{
+		ProcessingConfig: processingConfig,
+		Input:            *input,
+		Output:           *output,
+		MetricsOutput:    *metricsOutput,
+		RegionAudit:      *regionAudit,
+		InkColors:        *inkColors,
+		Report:           *report,
+		Layout:           *layout,
+		LayoutFormat:     *layoutFormat,
+		Vector:           *vector,
+		Render:           *render,
+		GroundTruth:      *groundTruth,
+	}
+
+	if err := validateLayoutFormat(config.LayoutFormat); err != nil {
+		return nil, err
+	}
+	if config.Crop, err = crop.resolve(); err != nil {
+		return nil, err
+	}
+	if *splitSpread != "" {
+		if err := validateSplitMode(*splitSpread); err != nil {
+			return nil, err
+		}
+		if config.Output == "" {
+			return nil, fmt.Errorf("-split-spread needs -output to name the pages")
+		}
+		if config.MetricsOutput != "" || config.RegionAudit != "" || config.InkColors != "" || config.Report != "" || config.Layout != "" || config.Vector != "" || config.Render != "" || config.GroundTruth != "" || *provenanceKey != "" {
+			return nil, fmt.Errorf("-split-spread writes only the page images; drop -metrics, -region-audit, -ink-colors, -report, -layout, -vector, -render, -ground-truth and -sign-provenance")
+		}
+		if *splitAt < 0 {
+			return nil, fmt.Errorf("split column %d: expected a positive column or 0 to detect it", *splitAt)
+		}
+		config.SplitSpread, config.SplitAt = *splitSpread, *splitAt
+	}
+
+	if config.GroundTruth != "" && config.MetricsOutput == "" && config.Report == "" && config.Render == "" {
+		return nil, fmt.Errorf("-ground-truth is only used with -metrics, -report or -render")
+	}
+
+	if config.VectorOptions, err = ParseTraceOptions(*vectorOptions); err != nil {
+		return nil, err
+	}
+	if ext := strings.ToLower(filepath.Ext(config.Vector)); config.Vector != "" && ext != ".svg" && ext != ".pdf" {
+		return nil, fmt.Errorf("vector output %s: expected a .svg or .pdf file", config.Vector)
+	}
+
+	if *provenanceKey != "" {
+		if !canCarryProvenance(filepath.Ext(config.Output)) {
+			return nil, fmt.Errorf("signed provenance needs a .png or .tif output")
+		}
+		if config.ProvenanceKey, err = LoadProvenanceSigningKey(*provenanceKey); err != nil {
+			return nil, err
+		}
+	}
+
+	return config, nil
+}
+
+// canCarryProvenance reports whether outputs with extension can embed a
+// provenance record.
+func canCarryProvenance(extension string) bool {
+	switch strings.ToLower(extension) {
+	case ".png", ".tif", ".tiff":
+		return true
+	}
+	return false
+}
+
+func envOrDefault(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		return value
+	}
+	return fallback
+}
+
+// loadParameterSource accepts either inline JSON or the path of a JSON file,
+// the same format the GUI copies to the clipboard.
+func loadParameterSource(source string, base *OtsuParameters) (*OtsuParameters, error) {
+	text := source
+	if !strings.HasPrefix(strings.TrimSpace(source), "{") {
+		data, err := os.ReadFile(source)
+		if err != nil {
+			return nil, fmt.Errorf("read parameters: %w", err)
+		}
+		text = string(data)
+	}
+
+	params, err := DecodeParametersJSON(text, base)
+	if err != nil {
+		return nil, fmt.Errorf("parameters: %w", err)
+	}
+	return params, nil
+}
+
+// applyAlgorithm overrides the method switches in params. An empty name keeps
+// whatever the parameter source selected.
+func applyAlgorithm(params *OtsuParameters, algorithm string) error {
+	switch algorithm {
+	case "":
+		return nil
+	case AlgorithmSingleScale, AlgorithmMultiScale, AlgorithmRegionAdaptive, AlgorithmNeural, AlgorithmOtsu3D,
+		AlgorithmKapur, AlgorithmTsallis:
+	default:
+		return fmt.Errorf("unknown algorithm %q: expected %s, %s, %s, %s, %s, %s or %s",
+			algorithm, AlgorithmSingleScale, AlgorithmMultiScale, AlgorithmRegionAdaptive, AlgorithmNeural, AlgorithmOtsu3D,
+			AlgorithmKapur, AlgorithmTsallis)
+	}
+
+	// The neural model comes from the parameter source or -model.
+	params.MultiScaleProcessing = algorithm == AlgorithmMultiScale
+	params.RegionAdaptiveThresholding = algorithm == AlgorithmRegionAdaptive
+	params.NeuralBinarization = algorithm == AlgorithmNeural
+	params.Otsu3D = algorithm == AlgorithmOtsu3D
+	switch algorithm {
+	case AlgorithmKapur:
+		params.EntropyMethod = EntropyMethodKapur
+	case AlgorithmTsallis:
+		params.EntropyMethod = EntropyMethodTsallis
+	default:
+		params.EntropyMethod = ""
+	}
+	return nil
+}
+
+// configureHeadlessLogging routes logs to stderr so stdout stays free for
+// machine-readable output.
+func configureHeadlessLogging(level slog.Level) *DebugSystem {
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level})))
+
+	return InitDebugSystem(DebugConfig{
+		LogLevel:      level,
+		EnableTracing: level <= slog.LevelDebug,
+		ConsoleOutput: true,
+	})
+}
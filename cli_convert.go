@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// convertCommand re-encodes images between formats without thresholding.
+const convertCommand = "convert"
+
+// ConvertConfig holds the settings of `otsu-obliterator convert`. Output
+// names the file of a single input; otherwise outputs go to OutputDir.
+type ConvertConfig struct {
+	Inputs       []string
+	Output       string
+	OutputDir    string
+	OutputFormat string
+	Options      ConvertOptions
+}
+
+func LoadConvertConfig(args []string) (*ConvertConfig, error) {
+	flags := flag.NewFlagSet(convertCommand, flag.ContinueOnError)
+
+	output := flags.String("output", "", "output `file` of a single input; its extension picks the format")
+	outputDir := flags.String("output-dir", os.Getenv(envOutputDir), "directory for converted images ($"+envOutputDir+")")
+	outputFormat := flags.String("format", envOrDefault(envOutputFormat, "png"), "output format with -output-dir, png, jpg or tif ($"+envOutputFormat+")")
+	dpi := flags.Float64("dpi", 0, "resolution to record, 0 keeps the source's")
+	bitDepth := flags.Int("bit-depth", 8, "8, or 1 for PNG and Group 4 TIFF images that are already black and white")
+	colorManagementDefault, err := strconv.ParseBool(envOrDefault(envColorManagement, "true"))
+	colorManagement := flags.Bool("color-management", colorManagementDefault || err != nil,
+		"convert images with an embedded ICC profile to sRGB ($"+envColorManagement+")")
+
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if flags.NArg() == 0 {
+		return nil, fmt.Errorf("no inputs: pass image files or directories")
+	}
+	SetColorManagement(*colorManagement)
+
+	config := &ConvertConfig{
+		Output:    *output,
+		OutputDir: *outputDir,
+		Options:   ConvertOptions{DPI: *dpi, BitDepth: *bitDepth},
+	}
+
+	format := *outputFormat
+	if config.Output != "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(config.Output)), ".")
+	}
+	switch format {
+	case "png", "jpg", "tif":
+		config.OutputFormat = format
+	case "jpeg":
+		config.OutputFormat = "jpg"
+	case "tiff":
+		config.OutputFormat = "tif"
+	default:
+		return nil, fmt.Errorf("unknown output format %q: expected png, jpg or tif", format)
+	}
+
+	inputs, err := expandImageInputs(flags.Args())
+	if err != nil {
+		return nil, err
+	}
+	config.Inputs = inputs
+
+	if config.Output != "" {
+		if len(inputs) > 1 {
+			return nil, fmt.Errorf("-output takes a single input, got %d; use -output-dir", len(inputs))
+		}
+	} else if config.OutputDir == "" {
+		return nil, fmt.Errorf("no output: pass -output or -output-dir")
+	}
+
+	if err := config.Options.validate("." + config.OutputFormat); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// outputPath is where input is written.
+func (cc *ConvertConfig) outputPath(input string) string {
+	if cc.Output != "" {
+		return cc.Output
+	}
+	stem := strings.TrimSuffix(filepath.Base(input), filepath.Ext(input))
+	return filepath.Join(cc.OutputDir, stem+"."+cc.OutputFormat)
+}
+
+// runConvertCommand implements `otsu-obliterator convert`. It exits with 1
+// when any image failed and stops between images once ctx is cancelled.
+func runConvertCommand(ctx context.Context, args []string) int {
+	config, err := LoadConvertConfig(args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		fmt.Fprintf(os.Stderr, "%s: %v\n", convertCommand, err)
+		return 2
+	}
+
+	if config.OutputDir != "" && config.Output == "" {
+		if err := os.MkdirAll(config.OutputDir, 0755); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: create output directory: %v\n", convertCommand, err)
+			return 1
+		}
+	}
+
+	failed := 0
+	for i, input := range config.Inputs {
+		if ctx.Err() != nil {
+			fmt.Fprintf(os.Stderr, "%s: interrupted after %d of %d images\n", convertCommand, i, len(config.Inputs))
+			return 1
+		}
+
+		output := config.outputPath(input)
+		status := "ok"
+		if sameFile(input, output) {
+			err = fmt.Errorf("output would overwrite the input")
+		} else {
+			err = ConvertImageFile(input, output, config.Options)
+		}
+		if err != nil {
+			failed++
+			status = "FAILED: " + err.Error()
+		}
+		fmt.Fprintf(os.Stderr, "[%d/%d] %s -> %s %s\n", i+1, len(config.Inputs), input, output, status)
+	}
+
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+func sameFile(a, b string) bool {
+	aInfo, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	bInfo, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(aInfo, bInfo)
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// datasetCommand registers and validates ground truth collections for the
+// benchmark.
+const datasetCommand = "dataset"
+
+const datasetUsage = `usage:
+  otsu-obliterator dataset import -convention dibco|phibd [-replace] <name> <folder>
+  otsu-obliterator dataset add -images <folder> -ground-truth <folder> [-image-suffix s] [-gt-suffixes a,b] [-replace] <name>
+  otsu-obliterator dataset list
+  otsu-obliterator dataset validate <name>
+  otsu-obliterator dataset remove <name>
+`
+
+// runDatasetCommand implements `otsu-obliterator dataset`. validate exits
+// with 1 when it found problems.
+func runDatasetCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, datasetUsage)
+		return 2
+	}
+
+	registry, err := LoadDatasetRegistry(DefaultDatasetRegistryPath())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", datasetCommand, err)
+		return 1
+	}
+
+	flags := flag.NewFlagSet(datasetCommand+" "+args[0], flag.ContinueOnError)
+	replace := flags.Bool("replace", false, "replace a registered dataset of the same name")
+	convention := flags.String("convention", "", "directory convention to import, dibco or phibd")
+	imageDir := flags.String("images", "", "`folder` with the source images")
+	groundTruthDir := flags.String("ground-truth", "", "`folder` with the ground truth images, may be the image folder")
+	imageSuffix := flags.String("image-suffix", "", "suffix stripped from image names before the ground truth is looked up, such as _in")
+	truthSuffixes := flags.String("gt-suffixes", strings.Join(defaultGroundTruthSuffixes, ","), "comma-separated suffixes tried after the image name, in order")
+
+	if err := flags.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+
+	usageError := func(message string) int {
+		fmt.Fprintf(os.Stderr, "%s %s: %s\n%s", datasetCommand, args[0], message, datasetUsage)
+		return 2
+	}
+
+	switch args[0] {
+	case "import", "add":
+		var dataset *Dataset
+		if args[0] == "import" {
+			if flags.NArg() != 2 {
+				return usageError("expected a name and a folder")
+			}
+			dataset, err = ImportDataset(flags.Arg(0), *convention, flags.Arg(1))
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", datasetCommand, err)
+				return 2
+			}
+		} else {
+			if flags.NArg() != 1 || *imageDir == "" || *groundTruthDir == "" {
+				return usageError("expected a name, -images and -ground-truth")
+			}
+			dataset = &Dataset{
+				Name:                flags.Arg(0),
+				Convention:          DatasetConventionCustom,
+				ImageDir:            *imageDir,
+				GroundTruthDir:      *groundTruthDir,
+				ImageSuffix:         *imageSuffix,
+				GroundTruthSuffixes: strings.Split(*truthSuffixes, ","),
+			}
+		}
+
+		if err := registry.Add(dataset, *replace); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", datasetCommand, err)
+			return 2
+		}
+		pairs, problems, err := dataset.Pairs()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", datasetCommand, err)
+			return 1
+		}
+		if err := registry.Save(); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", datasetCommand, err)
+			return 1
+		}
+		fmt.Fprintf(os.Stderr, "registered %s: %d pairs, %d images without ground truth; images in %s, ground truth in %s\n",
+			dataset.Name, len(pairs), len(problems), dataset.ImageDir, dataset.GroundTruthDir)
+		return 0
+
+	case "list":
+		table := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(table, "name\tconvention\tpairs\timages\tground truth")
+		for _, dataset := range registry.Datasets {
+			pairs, _, err := dataset.Pairs()
+			count := fmt.Sprint(len(pairs))
+			if err != nil {
+				count = "unavailable"
+			}
+			fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\n", dataset.Name, dataset.Convention, count, dataset.ImageDir, dataset.GroundTruthDir)
+		}
+		table.Flush()
+		return 0
+
+	case "validate":
+		if flags.NArg() != 1 {
+			return usageError("expected a dataset name")
+		}
+		dataset, err := registry.Find(flags.Arg(0))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", datasetCommand, err)
+			return 2
+		}
+		pairs, problems, err := dataset.Validate()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", datasetCommand, err)
+			return 1
+		}
+		for _, problem := range problems {
+			fmt.Println(problem)
+		}
+		fmt.Fprintf(os.Stderr, "%s: %d valid pairs, %d problems\n", dataset.Name, len(pairs), len(problems))
+		if len(problems) > 0 {
+			return 1
+		}
+		return 0
+
+	case "remove":
+		if flags.NArg() != 1 {
+			return usageError("expected a dataset name")
+		}
+		if err := registry.Remove(flags.Arg(0)); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", datasetCommand, err)
+			return 2
+		}
+		if err := registry.Save(); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", datasetCommand, err)
+			return 1
+		}
+		return 0
+	}
+
+	return usageError("unknown subcommand")
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Commands of the processing farm: a coordinator queues images, workers on
+// any number of machines pull and process them, and submit uploads images
+// and downloads the results.
+const (
+	coordinatorCommand = "coordinator"
+	workerCommand      = "worker"
+	submitCommand      = "submit"
+)
+
+const defaultFarmAddress = "127.0.0.1:7710"
+
+func runCoordinatorCommand(ctx context.Context, args []string) int {
+	flags := flag.NewFlagSet(coordinatorCommand, flag.ContinueOnError)
+
+	listen := flags.String("listen", envOrDefault(envFarmListen, defaultFarmAddress), "`address` to accept submissions and workers on ($"+envFarmListen+")")
+	spoolDir := flags.String("spool", os.Getenv(envFarmSpool), "directory for queued images and results, default the cache directory ($"+envFarmSpool+")")
+	cacheMaxMB := flags.Int64("cache-max-mb", defaultCacheMaxBytes()>>20, "evict finished results, oldest first, beyond this many MB ($"+envCacheMaxMB+")")
+	lease := flags.Duration("lease", 2*time.Minute, "requeue a job when its worker sends no heartbeat for this long")
+	attempts := flags.Int("attempts", 3, "fail a job after this many workers lost it")
+	logLevel := flags.String("log-level", envOrDefault(envLogLevel, "info"), "debug, info, warn or error ($"+envLogLevel+")")
+
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+
+	config := ProcessingConfig{}
+	err := config.LogLevel.UnmarshalText([]byte(*logLevel))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: log level %q: %v\n", coordinatorCommand, *logLevel, err)
+		return 2
+	}
+	if *lease < 10*time.Second || *attempts < 1 || *cacheMaxMB < 1 {
+		fmt.Fprintf(os.Stderr, "%s: -lease must be at least 10s, -attempts and -cache-max-mb at least 1\n", coordinatorCommand)
+		return 2
+	}
+
+	debugSystem := configureHeadlessLogging(config.LogLevel)
+	defer debugSystem.Close()
+
+	cache := GetArtifactCache()
+	if *spoolDir != "" {
+		cache, err = OpenArtifactCache(*spoolDir, *cacheMaxMB<<20)
+	} else {
+		_, err = cache.SetMaxBytes(*cacheMaxMB << 20)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", coordinatorCommand, err)
+		return 1
+	}
+
+	coordinator := NewFarmCoordinator(cache, *lease, *attempts)
+	defer coordinator.Close()
+
+	listener, err := net.Listen("tcp", *listen)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", coordinatorCommand, err)
+		return 1
+	}
+
+	fmt.Fprintf(os.Stderr, "coordinator listening on %s\n", listener.Addr())
+	if err := coordinator.Serve(ctx, listener); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", coordinatorCommand, err)
+		return 1
+	}
+	return 0
+}
+
+func runWorkerCommand(ctx context.Context, args []string) int {
+	flags := flag.NewFlagSet(workerCommand, flag.ContinueOnError)
+
+	address := flags.String("coordinator", envOrDefault(envFarmCoordinator, defaultFarmAddress), "coordinator `address` ($"+envFarmCoordinator+")")
+	hostname, _ := os.Hostname()
+	name := flags.String("name", envOrDefault(envFarmWorker, fmt.Sprintf("%s-%d", hostname, os.Getpid())), "worker name shown in job events ($"+envFarmWorker+")")
+	heartbeat := flags.Duration("heartbeat", 30*time.Second, "interval of lease heartbeats, below the coordinator's -lease")
+	processing := addProcessingFlags(flags)
+
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+
+	processingConfig, err := processing.resolve()
+	if err == nil && *heartbeat < time.Second {
+		err = fmt.Errorf("heartbeat %v: expected at least 1s", *heartbeat)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", workerCommand, err)
+		return 2
+	}
+
+	debugSystem := configureHeadlessLogging(processingConfig.LogLevel)
+	defer debugSystem.Close()
+
+	conn, err := dialFarm(*address)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", workerCommand, err)
+		return 1
+	}
+	defer conn.Close()
+
+	worker, err := NewFarmWorker(conn, *name, processingConfig, *heartbeat)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", workerCommand, err)
+		return 1
+	}
+	defer worker.Close()
+
+	fmt.Fprintf(os.Stderr, "worker %s pulling jobs from %s\n", *name, *address)
+	worker.Run(ctx)
+	return 0
+}
+
+// runSubmitCommand uploads images, follows their jobs and downloads each
+// result as soon as it is done. It exits with 1 when any job failed.
+func runSubmitCommand(ctx context.Context, args []string) int {
+	flags := flag.NewFlagSet(submitCommand, flag.ContinueOnError)
+
+	address := flags.String("coordinator", envOrDefault(envFarmCoordinator, defaultFarmAddress), "coordinator `address` ($"+envFarmCoordinator+")")
+	outputDir := flags.String("output-dir", os.Getenv(envOutputDir), "directory for the results ($"+envOutputDir+")")
+	algorithm := flags.String("algorithm", os.Getenv(envAlgorithm), "single-scale, multi-scale, region-adaptive, neural, otsu-3d, kapur or tsallis ($"+envAlgorithm+")")
+	paramsSource := flags.String("params", os.Getenv(envParams), "parameter JSON `file` or inline JSON object ($"+envParams+")")
+
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+
+	params := DefaultOtsuParameters()
+	var err error
+	if *paramsSource != "" {
+		params, err = loadParameterSource(*paramsSource, params)
+	}
+	if err == nil {
+		err = applyAlgorithm(params, *algorithm)
+	}
+	if err == nil && *outputDir == "" {
+		err = fmt.Errorf("no output directory: pass -output-dir or set %s", envOutputDir)
+	}
+	if err == nil && flags.NArg() == 0 {
+		err = fmt.Errorf("no inputs: pass image files")
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", submitCommand, err)
+		return 2
+	}
+
+	if err := os.MkdirAll(*outputDir, 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: create output directory: %v\n", submitCommand, err)
+		return 1
+	}
+
+	conn, err := dialFarm(*address)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", submitCommand, err)
+		return 1
+	}
+	defer conn.Close()
+
+	client := &FarmClient{conn: conn}
+	failed := 0
+	results := make(chan error, flags.NArg())
+	for _, input := range flags.Args() {
+		jobID, err := client.submit(ctx, input, params)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: FAILED: %v\n", input, err)
+			failed++
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "%s: submitted as job %s\n", input, jobID)
+
+		output := filepath.Join(*outputDir, strings.TrimSuffix(filepath.Base(input), filepath.Ext(input))+".png")
+		go func() {
+			results <- client.follow(ctx, jobID, input, output)
+		}()
+	}
+
+	for range flags.NArg() - failed {
+		if err := <-results; err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			failed++
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "%d of %d images processed, %d failed\n", flags.NArg()-failed, flags.NArg(), failed)
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// framesCommand binarizes a video or image sequence frame by frame.
+const framesCommand = "frames"
+
+func runFramesCommand(ctx context.Context, args []string) int {
+	flags := flag.NewFlagSet(framesCommand, flag.ContinueOnError)
+
+	input := flags.String("input", os.Getenv(envInput), "video file, multi-page TIFF, image directory or glob pattern ($"+envInput+")")
+	output := flags.String("output", os.Getenv(envOutput), "output video (.mp4, .avi, ...), multi-page Group 4 TIFF (.tif) or directory for numbered PNG frames ($"+envOutput+")")
+	fps := flags.Float64("fps", 0, "output frame rate, default from the video or 24 for image sequences")
+	processing := addProcessingFlags(flags)
+
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+
+	processingConfig, err := processing.resolve()
+	if err == nil && (*input == "" || *output == "") {
+		err = fmt.Errorf("both -input and -output are required")
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", framesCommand, err)
+		return 2
+	}
+
+	debugSystem := configureHeadlessLogging(processingConfig.LogLevel)
+	defer debugSystem.Close()
+
+	config := &FrameSequenceConfig{
+		Input:  *input,
+		Output: *output,
+		Params: processingConfig.Params,
+		FPS:    *fps,
+	}
+
+	written, err := ProcessFrameSequence(ctx, config, func(done, total int) {
+		if total > 0 {
+			fmt.Fprintf(os.Stderr, "\rframe %d/%d", done, total)
+		} else {
+			fmt.Fprintf(os.Stderr, "\rframe %d", done)
+		}
+	})
+	fmt.Fprintln(os.Stderr)
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v (%d frames written)\n", framesCommand, err, written)
+		return 1
+	}
+
+	fmt.Fprintf(os.Stderr, "%d frames written to %s\n", written, config.Output)
+	return 0
+}
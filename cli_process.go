@@ -0,0 +1,286 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// processCommand runs one image through the engine without starting the GUI.
+const processCommand = "process"
+
+// runHeadlessProcess implements `otsu-obliterator process` and returns the
+// process exit code: 2 for configuration errors, 1 for processing failures.
+func runHeadlessProcess(ctx context.Context, args []string) int {
+	config, err := LoadHeadlessConfig(processCommand, args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		fmt.Fprintf(os.Stderr, "%s: %v\n", processCommand, err)
+		return 2
+	}
+
+	debugSystem := configureHeadlessLogging(config.LogLevel)
+	defer debugSystem.Close()
+
+	if err := processHeadless(ctx, config); err != nil {
+		debugSystem.logger.Error("headless processing failed", "input", config.Input, "error", err.Error())
+		fmt.Fprintf(os.Stderr, "%s: %v\n", processCommand, err)
+		return 1
+	}
+	return 0
+}
+
+func processHeadless(ctx context.Context, config *HeadlessConfig) error {
+	debugSystem := GetDebugSystem()
+
+	if config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, config.Timeout)
+		defer cancel()
+	}
+
+	imageData, err := LoadImageFile(config.Input, config.MaxMegapixels)
+	if err != nil {
+		return fmt.Errorf("load %s: %w", config.Input, err)
+	}
+
+	imageSize := [2]int{imageData.Width, imageData.Height}
+	if err := validateOtsuParameters(config.Params, imageSize); err != nil {
+		return err
+	}
+
+	engine := NewProcessingEngine()
+	engine.SetOriginalImage(imageData)
+
+	if config.SplitSpread != "" {
+		return processSpread(ctx, config, engine, imageData)
+	}
+
+	if config.GroundTruth != "" {
+		if err := setHeadlessGroundTruth(engine, config.GroundTruth); err != nil {
+			return err
+		}
+	}
+
+	startTime := time.Now()
+	result, metrics, err := engine.ProcessImageWithTimeout(ctx, config.Params)
+	if err != nil {
+		return fmt.Errorf("process %s: %w", config.Input, err)
+	}
+
+	debugSystem.logger.Info("headless processing complete",
+		"input", config.Input,
+		"duration_ms", time.Since(startTime).Milliseconds(),
+	)
+
+	var crop *OutputCrop
+	if config.Crop != nil {
+		cropped, applied, err := CropToContent(result, *config.Crop)
+		if err != nil {
+			return err
+		}
+		if applied != nil {
+			defer cropped.Mat.Close()
+			result, crop = cropped, applied
+		}
+	}
+
+	if config.Output != "" {
+		if config.ProvenanceKey != nil {
+			err = writeSignedImageFile(config, result)
+		} else {
+			err = writeImageFile(config.Output, result)
+		}
+		if err != nil {
+			return err
+		}
+		if sidecar := withCrop(NewOutputSidecar(config.Input, imageData), config.Input, imageData, crop); sidecar != nil {
+			if err := sidecar.Write(config.Output); err != nil {
+				return err
+			}
+		}
+	}
+
+	if config.MetricsOutput != "" {
+		if err := writeMetricsReport(config.MetricsOutput, NewMetricsReport(metrics)); err != nil {
+			return err
+		}
+	}
+
+	if config.Report != "" {
+		report := &QAReport{
+			Source:    filepath.Base(config.Input),
+			CreatedAt: time.Now(),
+			Original:  imageData.Image,
+			Result:    result.Image,
+			Params:    config.Params,
+			Metrics:   NewMetricsReport(metrics),
+			Warnings:  engine.QualityWarnings(),
+		}
+		if err := report.WritePDF(config.Report); err != nil {
+			return err
+		}
+	}
+
+	if config.Layout != "" {
+		imageFile := filepath.Base(config.Input)
+		if config.Output != "" {
+			imageFile = filepath.Base(config.Output)
+		}
+		layout, err := DetectTextLayout(result, imageFile)
+		if err != nil {
+			return err
+		}
+		if err := layout.WriteFile(config.Layout, config.LayoutFormat); err != nil {
+			return err
+		}
+	}
+
+	if config.Vector != "" {
+		outlines, err := TraceOutlines(result.Mat, config.VectorOptions)
+		if err != nil {
+			return fmt.Errorf("trace %s: %w", config.Input, err)
+		}
+		outlines.DPI = imageData.DPI
+		if err := outlines.WriteFile(config.Vector); err != nil {
+			return err
+		}
+	}
+
+	if config.Render != "" {
+		if err := renderHeadless(config, engine); err != nil {
+			return err
+		}
+	}
+
+	if config.RegionAudit != "" {
+		audit := engine.RegionAudit()
+		if audit == nil {
+			return fmt.Errorf("region audit: %s did not use the %s algorithm", config.Input, AlgorithmRegionAdaptive)
+		}
+		audit.Input = config.Input
+		if imageData.ScaleFactor > 0 {
+			audit.ScaleFactor = imageData.ScaleFactor
+		}
+		if err := audit.Write(config.RegionAudit); err != nil {
+			return err
+		}
+	}
+
+	if config.InkColors != "" {
+		stats, err := engine.InkColors()
+		if err != nil {
+			return fmt.Errorf("%s: %w", config.Input, err)
+		}
+		if err := stats.Write(config.InkColors); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// setHeadlessGroundTruth loads the ground truth at path and scores the
+// engine's runs against it.
+func setHeadlessGroundTruth(engine *ProcessingEngine, path string) error {
+	truth, err := LoadImageFile(path, 0)
+	if err != nil {
+		return fmt.Errorf("load ground truth %s: %w", path, err)
+	}
+	defer truth.Mat.Close()
+	return engine.SetGroundTruth(truth)
+}
+
+// renderHeadless writes the annotated images of the engine's latest run to
+// config.Render, named after the input.
+func renderHeadless(config *HeadlessConfig, engine *ProcessingEngine) error {
+	var groundTruth *ImageData
+	if config.GroundTruth != "" {
+		truth, err := LoadImageFile(config.GroundTruth, 0)
+		if err != nil {
+			return fmt.Errorf("load ground truth %s: %w", config.GroundTruth, err)
+		}
+		defer truth.Mat.Close()
+		groundTruth = truth
+	}
+
+	visuals, err := engine.RenderVisuals(groundTruth)
+	if err != nil {
+		return fmt.Errorf("render %s: %w", config.Input, err)
+	}
+	stem := strings.TrimSuffix(filepath.Base(config.Input), filepath.Ext(config.Input))
+	return WriteRenderedVisuals(config.Render, stem, visuals)
+}
+
+func writeImageFile(path string, imageData *ImageData) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("create %s: %w", path, err)
+	}
+
+	if err := EncodeImage(file, imageData, filepath.Ext(path)); err != nil {
+		file.Close()
+		return fmt.Errorf("save %s: %w", path, err)
+	}
+
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("close %s: %w", path, err)
+	}
+	return nil
+}
+
+// writeSignedImageFile writes the output with a provenance record signed by
+// the configured key.
+func writeSignedImageFile(config *HeadlessConfig, imageData *ImageData) error {
+	source, err := os.ReadFile(config.Input)
+	if err != nil {
+		return fmt.Errorf("read %s: %w", config.Input, err)
+	}
+	record, err := NewProvenanceRecord(config.Input, source, config.Params)
+	if err != nil {
+		return err
+	}
+
+	var encoded bytes.Buffer
+	if err := EncodeImage(&encoded, imageData, filepath.Ext(config.Output)); err != nil {
+		return fmt.Errorf("save %s: %w", config.Output, err)
+	}
+	signed, err := SignProvenance(encoded.Bytes(), filepath.Ext(config.Output), record, config.ProvenanceKey)
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(config.Output, signed, 0644); err != nil {
+		return fmt.Errorf("write %s: %w", config.Output, err)
+	}
+	return nil
+}
+
+// writeMetricsReport writes report as indented JSON to path, or to stdout
+// when path is "-".
+func writeMetricsReport(path string, report *MetricsReport) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode metrics: %w", err)
+	}
+	data = append(data, '\n')
+
+	if path == "-" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	return nil
+}
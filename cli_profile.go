@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+)
+
+// profileCommand lists the tuned profiles and matches images against them.
+const profileCommand = "profile"
+
+const profileUsage = `usage:
+  otsu-obliterator profile list
+  otsu-obliterator profile show <name>
+  otsu-obliterator profile suggest <image>...
+  otsu-obliterator profile remove <name>
+`
+
+// runProfileCommand implements `otsu-obliterator profile`. suggest exits
+// with 1 when no image matches a profile.
+func runProfileCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, profileUsage)
+		return 2
+	}
+
+	registry, err := LoadProfileRegistry(DefaultProfileRegistryPath())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", profileCommand, err)
+		return 1
+	}
+
+	flags := flag.NewFlagSet(profileCommand+" "+args[0], flag.ContinueOnError)
+	if err := flags.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+
+	usageError := func(message string) int {
+		fmt.Fprintf(os.Stderr, "%s %s: %s\n%s", profileCommand, args[0], message, profileUsage)
+		return 2
+	}
+
+	switch args[0] {
+	case "list":
+		table := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(table, "name\tdataset\timages\tscore\tcreated")
+		for _, profile := range registry.Profiles {
+			fmt.Fprintf(table, "%s\t%s\t%d\t%s %.4f\t%s\n", profile.Name, profile.Dataset, profile.Signature.Images,
+				profile.Metric, profile.Score, profile.CreatedAt.Format("2006-01-02"))
+		}
+		table.Flush()
+		return 0
+
+	case "show":
+		if flags.NArg() != 1 {
+			return usageError("expected a profile name")
+		}
+		profile, err := registry.Find(flags.Arg(0))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", profileCommand, err)
+			return 2
+		}
+		text, err := EncodeParametersJSON(profile.Params)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", profileCommand, err)
+			return 1
+		}
+		fmt.Println(text)
+		return 0
+
+	case "suggest":
+		if flags.NArg() == 0 {
+			return usageError("expected at least one image")
+		}
+		matched := 0
+		for _, path := range flags.Args() {
+			signature, err := ImageSignatureOfFile(path)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", profileCommand, err)
+				continue
+			}
+			profile, distance := registry.Suggest(signature)
+			if profile == nil {
+				fmt.Printf("%s: no matching profile\n", path)
+				continue
+			}
+			matched++
+			fmt.Printf("%s: %s, distance %.2f\n", path, profile.Describe(), distance)
+		}
+		if matched == 0 {
+			return 1
+		}
+		return 0
+
+	case "remove":
+		if flags.NArg() != 1 {
+			return usageError("expected a profile name")
+		}
+		if err := registry.Remove(flags.Arg(0)); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", profileCommand, err)
+			return 2
+		}
+		if err := registry.Save(); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", profileCommand, err)
+			return 1
+		}
+		return 0
+	}
+
+	return usageError("unknown subcommand")
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// provenanceCommand creates signing keys and checks the provenance records
+// that process and batch embed with -sign-provenance.
+const provenanceCommand = "provenance"
+
+const provenanceUsage = `usage:
+  otsu-obliterator provenance keygen <key file>
+  otsu-obliterator provenance show <image>
+  otsu-obliterator provenance verify [-public-key file] [-source original] <image>...
+`
+
+// runProvenanceCommand implements `otsu-obliterator provenance`. verify
+// exits with 1 when any image fails a check.
+func runProvenanceCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, provenanceUsage)
+		return 2
+	}
+
+	flags := flag.NewFlagSet(provenanceCommand+" "+args[0], flag.ContinueOnError)
+	publicKeyPath := flags.String("public-key", "", "require records signed by the Ed25519 public key `file` written by keygen")
+	sourcePath := flags.String("source", "", "check the records against the original image `file`")
+
+	if err := flags.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+
+	usageError := func(message string) int {
+		fmt.Fprintf(os.Stderr, "%s %s: %s\n%s", provenanceCommand, args[0], message, provenanceUsage)
+		return 2
+	}
+
+	switch args[0] {
+	case "keygen":
+		if flags.NArg() != 1 {
+			return usageError("expected a key file")
+		}
+		publicKey, err := GenerateProvenanceKey(flags.Arg(0))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", provenanceCommand, err)
+			return 1
+		}
+		fmt.Printf("signing key %s\npublic key %s.pub\nfingerprint %s\n", flags.Arg(0), flags.Arg(0), ProvenanceKeyFingerprint(publicKey))
+		return 0
+
+	case "show":
+		if flags.NArg() != 1 {
+			return usageError("expected an image")
+		}
+		data, err := os.ReadFile(flags.Arg(0))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", provenanceCommand, err)
+			return 1
+		}
+		signed, err := ExtractProvenance(data)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s: %v\n", provenanceCommand, flags.Arg(0), err)
+			return 1
+		}
+		fmt.Printf("%s\n", signed.JSON)
+		fmt.Printf("signed by %s\n", ProvenanceKeyFingerprint(signed.PublicKey))
+		return 0
+
+	case "verify":
+		if flags.NArg() == 0 {
+			return usageError("expected at least one image")
+		}
+
+		var trusted ed25519.PublicKey
+		if *publicKeyPath != "" {
+			key, err := LoadProvenancePublicKey(*publicKeyPath)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", provenanceCommand, err)
+				return 2
+			}
+			trusted = key
+		}
+		var source []byte
+		if *sourcePath != "" {
+			data, err := os.ReadFile(*sourcePath)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", provenanceCommand, err)
+				return 2
+			}
+			source = data
+		}
+
+		failed := 0
+		for _, path := range flags.Args() {
+			if !verifyProvenanceFile(path, trusted, source) {
+				failed++
+			}
+		}
+		if failed > 0 {
+			return 1
+		}
+		return 0
+	}
+
+	return usageError("unknown subcommand")
+}
+
+// verifyProvenanceFile prints the checks of one image and reports whether
+// all of them passed.
+func verifyProvenanceFile(path string, trusted ed25519.PublicKey, source []byte) bool {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Printf("%s: FAILED: %v\n", path, err)
+		return false
+	}
+	verification, err := VerifyProvenance(data, trusted, source)
+	if err != nil {
+		fmt.Printf("%s: FAILED: %v\n", path, err)
+		return false
+	}
+
+	record := verification.Signed.Record
+	var problems []string
+	if !verification.SignatureValid {
+		problems = append(problems, "signature does not match the record")
+	}
+	if !verification.PixelsMatch {
+		problems = append(problems, "pixels differ from the signed output")
+	}
+	if trusted != nil && !verification.Trusted {
+		problems = append(problems, "signed by an untrusted key "+ProvenanceKeyFingerprint(verification.Signed.PublicKey))
+	}
+	if verification.SourceMatches != nil && !*verification.SourceMatches {
+		problems = append(problems, "made from a different source than "+record.Source.Name)
+	}
+
+	if len(problems) > 0 {
+		for _, problem := range problems {
+			fmt.Printf("%s: FAILED: %s\n", path, problem)
+		}
+		return false
+	}
+
+	signer := ProvenanceKeyFingerprint(verification.Signed.PublicKey)
+	if trusted == nil {
+		signer += " (not checked against a trusted key)"
+	}
+	fmt.Printf("%s: OK: %s from %s (sha256 %s) by %s %s, signed by %s\n",
+		path, record.Algorithm, record.Source.Name, record.Source.SHA256, record.Software, record.AppVersion, signer)
+	return true
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// schemaCommand prints the JSON schema of the metrics output and checks
+// metrics files against it.
+const schemaCommand = "schema"
+
+const schemaUsage = `usage:
+  otsu-obliterator schema metrics
+  otsu-obliterator schema validate <metrics.json>...   (- reads stdin)
+`
+
+// runSchemaCommand implements `otsu-obliterator schema`. validate exits with
+// 1 when any file does not match the schema.
+func runSchemaCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, schemaUsage)
+		return 2
+	}
+
+	switch args[0] {
+	case "metrics":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(MetricsSchema()); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", schemaCommand, err)
+			return 1
+		}
+		return 0
+
+	case "validate":
+		if len(args) < 2 {
+			fmt.Fprintf(os.Stderr, "%s validate: expected metrics files\n%s", schemaCommand, schemaUsage)
+			return 2
+		}
+		failed := 0
+		for _, path := range args[1:] {
+			var data []byte
+			var err error
+			if path == "-" {
+				data, err = io.ReadAll(os.Stdin)
+			} else {
+				data, err = os.ReadFile(path)
+			}
+			if err == nil {
+				err = ValidateMetricsJSON(data)
+			}
+			if err != nil {
+				failed++
+				fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+				continue
+			}
+			fmt.Printf("%s: ok\n", path)
+		}
+		if failed > 0 {
+			return 1
+		}
+		return 0
+
+	default:
+		fmt.Fprintf(os.Stderr, "%s: unknown subcommand %q\n%s", schemaCommand, args[0], schemaUsage)
+		return 2
+	}
+}
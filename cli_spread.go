@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// processSpread splits a two-page spread and writes each page to
+// SpreadPagePath of the output, binarizing the pages separately or the
+// spread as a whole as config.SplitSpread selects.
+func processSpread(ctx context.Context, config *HeadlessConfig, engine *ProcessingEngine, imageData *ImageData) error {
+	gutter := config.SplitAt
+	if gutter == 0 {
+		detected, err := engine.DetectSpreadGutter()
+		if err != nil {
+			return fmt.Errorf("split %s: %w", config.Input, err)
+		}
+		gutter = detected
+	}
+
+	var pages [2]*ImageData
+	var crops [2]*OutputCrop
+	switch config.SplitSpread {
+	case SplitBefore:
+		left, right, leftCrop, rightCrop, err := SplitSpread(imageData, gutter)
+		if err != nil {
+			return err
+		}
+		crops = [2]*OutputCrop{leftCrop, rightCrop}
+		for i, page := range [2]*ImageData{left, right} {
+			pageEngine := NewProcessingEngine()
+			defer pageEngine.Close()
+			pageEngine.SetOriginalImage(page)
+
+			result, _, err := pageEngine.ProcessImageWithTimeout(ctx, config.Params)
+			if err != nil {
+				return fmt.Errorf("process %s page %d: %w", config.Input, i+1, err)
+			}
+			pages[i] = result
+		}
+
+	case SplitAfter:
+		result, _, err := engine.ProcessImageWithTimeout(ctx, config.Params)
+		if err != nil {
+			return fmt.Errorf("process %s: %w", config.Input, err)
+		}
+		// Page geometry stages may have resized the result.
+		left, right, leftCrop, rightCrop, err := SplitSpread(result, gutter*result.Width/imageData.Width)
+		if err != nil {
+			return err
+		}
+		defer left.Mat.Close()
+		defer right.Mat.Close()
+		pages, crops = [2]*ImageData{left, right}, [2]*OutputCrop{leftCrop, rightCrop}
+	}
+
+	for i, side := range [2]string{"left", "right"} {
+		page, crop := pages[i], crops[i]
+		if config.Crop != nil {
+			cropped, applied, err := CropToContent(page, *config.Crop)
+			if err != nil {
+				return err
+			}
+			if applied != nil {
+				defer cropped.Mat.Close()
+				page = cropped
+				crop = &OutputCrop{
+					X:               crop.X + applied.X,
+					Y:               crop.Y + applied.Y,
+					Width:           applied.Width,
+					Height:          applied.Height,
+					Margin:          applied.Margin,
+					UncroppedWidth:  crop.UncroppedWidth,
+					UncroppedHeight: crop.UncroppedHeight,
+				}
+			}
+		}
+
+		path := SpreadPagePath(config.Output, side)
+		if err := writeImageFile(path, page); err != nil {
+			return err
+		}
+		if sidecar := withCrop(NewOutputSidecar(config.Input, imageData), config.Input, imageData, crop); sidecar != nil {
+			if err := sidecar.Write(path); err != nil {
+				return err
+			}
+		}
+	}
+
+	GetDebugSystem().logger.Info("spread split",
+		"input", config.Input,
+		"mode", config.SplitSpread,
+		"gutter", gutter,
+	)
+	return nil
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// tuneCommand searches parameter values that score best against ground
+// truth.
+const tuneCommand = "tune"
+
+// defaultTuningTrials is the trial budget of each strategy when -trials is
+// not given.
+var defaultTuningTrials = map[string]int{
+	TuningStrategyHalving: 81,
+	TuningStrategyBayes:   40,
+	TuningStrategyGrid:    243,
+}
+
+// tuneOutputs are the files and profile a tune run writes.
+type tuneOutputs struct {
+	paramsPath  string
+	reportPath  string
+	profileName string
+	datasetName string
+}
+
+// LoadTuningConfig resolves tune settings. The processing flags give the
+// starting parameters, which also supply every value that is not tuned.
+func LoadTuningConfig(args []string) (*TuningConfig, *tuneOutputs, error) {
+	flags := flag.NewFlagSet(tuneCommand, flag.ContinueOnError)
+
+	groundTruthDir := flags.String("ground-truth", os.Getenv(envGroundTruth), "`directory` with a ground truth image per input, named like it with an optional _gt suffix ($"+envGroundTruth+")")
+	datasetName := flags.String("dataset", "", "tune on the images of a registered dataset instead of the inputs")
+	strategy := flags.String("strategy", TuningStrategyHalving, "search strategy: "+strings.Join(TuningStrategies, ", "))
+	dimensions := flags.String("tune", strings.Join(DefaultTuningDimensions, ","), "comma-separated parameters to tune, from "+strings.Join(tuningDimensionNames(tuningDimensions), ", "))
+	metricName := flags.String("metric", "f_measure", "metric to optimize, averaged over the images")
+	trials := flags.Int("trials", 0, "configurations to sample, 0 for the strategy default (halving 81, bayes 40, grid 243)")
+	scale := flags.Float64("scale", 0.5, "downscale factor of the images the bayes strategy searches on")
+	seed := flags.Uint64("seed", 1, "random seed; the same seed and inputs repeat a search")
+	jobs := flags.String("jobs", envOrDefault(envJobs, "0"), "process up to this many images at once, 0 for one per CPU ($"+envJobs+")")
+	outputPath := flags.String("output", "tuned.json", "write the best parameters to `path`, usable with -params")
+	reportPath := flags.String("report", "", "write every trial as JSON to `path`")
+	profileName := flags.String("save-profile", "", "save the best parameters as a profile `name` matched against new images, replacing one of the same name")
+	processing := addProcessingFlags(flags)
+
+	if err := flags.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	processingConfig, err := processing.resolve()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	config := &TuningConfig{
+		ProcessingConfig: processingConfig,
+		Strategy:         *strategy,
+		Trials:           *trials,
+		Scale:            *scale,
+		Seed:             *seed,
+	}
+
+	if _, ok := defaultTuningTrials[config.Strategy]; !ok {
+		return nil, nil, fmt.Errorf("unknown strategy %q: expected %s", config.Strategy, strings.Join(TuningStrategies, ", "))
+	}
+	if config.Trials < 0 {
+		return nil, nil, fmt.Errorf("trials %d: expected a non-negative integer", config.Trials)
+	}
+	if config.Trials == 0 {
+		config.Trials = defaultTuningTrials[config.Strategy]
+	}
+	if config.Scale <= 0 || config.Scale > 1 {
+		return nil, nil, fmt.Errorf("scale %v: expected a value in (0, 1]", config.Scale)
+	}
+
+	metricFound := false
+	for _, metric := range benchmarkMetrics {
+		if metric.Name == *metricName {
+			config.Metric, metricFound = metric, true
+		}
+	}
+	if !metricFound {
+		var names []string
+		for _, metric := range benchmarkMetrics {
+			names = append(names, metric.Name)
+		}
+		return nil, nil, fmt.Errorf("unknown metric %q: expected one of %s", *metricName, strings.Join(names, ", "))
+	}
+
+	config.Jobs, err = strconv.Atoi(*jobs)
+	if err != nil || config.Jobs < 0 {
+		return nil, nil, fmt.Errorf("jobs %q: expected a non-negative integer", *jobs)
+	}
+
+	config.Dimensions, err = ParseTuningDimensions(*dimensions)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	config.Pairs, err = groundTruthPairs(*datasetName, *groundTruthDir, flags.Args())
+	if err != nil {
+		return nil, nil, err
+	}
+	return config, &tuneOutputs{
+		paramsPath:  *outputPath,
+		reportPath:  *reportPath,
+		profileName: *profileName,
+		datasetName: *datasetName,
+	}, nil
+}
+
+// runTuneCommand implements `otsu-obliterator tune`. Progress goes to
+// stderr; the best parameters are written to the output file and printed.
+func runTuneCommand(ctx context.Context, args []string) int {
+	config, outputs, err := LoadTuningConfig(args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		fmt.Fprintf(os.Stderr, "%s: %v\n", tuneCommand, err)
+		return 2
+	}
+
+	debugSystem := configureHeadlessLogging(config.LogLevel)
+	defer debugSystem.Close()
+
+	for _, dimension := range config.Dimensions {
+		if !dimension.Active(config.Params) {
+			fmt.Fprintf(os.Stderr, "%s: warning: %s has no effect with the stage that uses it disabled\n", tuneCommand, dimension.Name)
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "tuning %s on %d images with %s, %d trials, %s\n",
+		strings.Join(tuningDimensionNames(config.Dimensions), ", "), len(config.Pairs), config.Strategy, config.Trials, config.Metric.Name)
+
+	trialCount := 0
+	report, err := runTuning(ctx, config, func(trial TuningTrial) {
+		trialCount++
+		failures := ""
+		if trial.Failures > 0 {
+			failures = fmt.Sprintf(", %d images failed", trial.Failures)
+		}
+		fmt.Fprintf(os.Stderr, "trial %d at scale %g: %s %.4f in %d ms%s  %s\n",
+			trialCount, trial.Scale, config.Metric.Name, trial.Score, trial.DurationMS, failures, formatTuningValues(trial.Values))
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", tuneCommand, err)
+		return 1
+	}
+
+	if outputs.reportPath != "" {
+		if err := report.Write(outputs.reportPath); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", tuneCommand, err)
+			return 1
+		}
+	}
+
+	text, err := EncodeParametersJSON(report.Params)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", tuneCommand, err)
+		return 1
+	}
+	if err := os.WriteFile(outputs.paramsPath, []byte(text+"\n"), 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: write %s: %v\n", tuneCommand, outputs.paramsPath, err)
+		return 1
+	}
+
+	fmt.Printf("%s %.4f (baseline %.4f) after %d evaluations in %s\n",
+		config.Metric.Name, report.BestScore, report.BaselineScore, len(report.Trials),
+		(time.Duration(report.DurationMS) * time.Millisecond).Round(time.Second))
+	fmt.Println(formatTuningValues(report.Best))
+	fmt.Fprintf(os.Stderr, "parameters written to %s\n", outputs.paramsPath)
+
+	if outputs.profileName != "" {
+		if err := saveTuningProfile(config, report, outputs); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", tuneCommand, err)
+			return 1
+		}
+		fmt.Fprintf(os.Stderr, "profile %s saved\n", outputs.profileName)
+	}
+	return 0
+}
+
+// saveTuningProfile binds the best parameters to the signature of the
+// images they were tuned on.
+func saveTuningProfile(config *TuningConfig, report *TuningReport, outputs *tuneOutputs) error {
+	registry, err := LoadProfileRegistry(DefaultProfileRegistryPath())
+	if err != nil {
+		return err
+	}
+
+	var signatures []*ImageSignature
+	for _, pair := range config.Pairs {
+		signature, err := ImageSignatureOfFile(pair.Image)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: profile signature skips %v\n", tuneCommand, err)
+			continue
+		}
+		signatures = append(signatures, signature)
+	}
+
+	profile := &Profile{
+		Name:      outputs.profileName,
+		Dataset:   outputs.datasetName,
+		Metric:    report.Metric,
+		Score:     report.BestScore,
+		Params:    report.Params,
+		Signature: NewDatasetSignature(signatures),
+	}
+	if err := registry.Add(profile, true); err != nil {
+		return err
+	}
+	return registry.Save()
+}
+
+func formatTuningValues(values map[string]float64) string {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = name + "=" + strconv.FormatFloat(values[name], 'g', -1, 64)
+	}
+	return strings.Join(parts, " ")
+}
@@ -19,6 +19,12 @@ const (
 	AppExecutable = "otsu-obliterator"
 	DeveloperName = "Ervins Strauhmanis"
 	Copyright     = "© 2025 Ervins Strauhmanis"
+	ServiceName   = "Binarize with Otsu Obliterator"
+
+	// DefaultOpenCVImage ships OpenCV matching the gocv release in go.mod
+	// together with a Go toolchain.
+	DefaultOpenCVImage = "gocv/opencv:4.11.0"
+	DockerOutputDir    = "dist/docker"
 )
 
 type PackageConfig struct {
@@ -34,6 +40,17 @@ type PackageConfig struct {
 	AppDir        string
 	DMGPath       string
 	MinVersion    string
+	ServiceName   string
+	WorkflowDir   string
+}
+
+type DockerConfig struct {
+	AppName       string
+	AppVersion    string
+	AppExecutable string
+	OpenCVImage   string
+	OutputDir     string
+	ImageTag      string
 }
 
 type PackageStats struct {
@@ -57,6 +74,8 @@ func main() {
 		handleClean()
 	case "verify":
 		handleVerify()
+	case "docker":
+		handleDocker()
 	default:
 		fmt.Printf("Unknown command: %s\n", command)
 		showUsage()
@@ -73,17 +92,21 @@ COMMANDS:
   package [binary_path]    Create .app bundle and .dmg (default: build/otsu-obliterator)
   clean                    Remove all packaging artifacts  
   verify [app_path]        Verify .app bundle structure
+  docker [--build]         Generate a Dockerfile for the headless CLI, optionally building it
 
 EXAMPLES:
   go run cmd/package/main.go package                          # Package default binary
   go run cmd/package/main.go package build/otsu-obliterator  # Package specific binary
   go run cmd/package/main.go verify dist/Otsu\ Obliterator.app
   go run cmd/package/main.go clean
+  go run ./cmd/package docker --build
 
 OUTPUT:
   dist/Otsu Obliterator.app     - macOS application bundle
   dist/Otsu-Obliterator.dmg     - Disk image for distribution
-`, AppName)
+  dist/%s.workflow - Finder Quick Action (copy to ~/Library/Services)
+  dist/docker/Dockerfile        - Headless CLI container image
+`, AppName, ServiceName)
 }
 
 func handlePackage() {
@@ -102,6 +125,7 @@ func handlePackage() {
 		SourceBinary:  binaryPath,
 		IconPath:      "icon.png",
 		OutputDir:     "dist",
+		ServiceName:   ServiceName,
 	}
 
 	if err := packageApp(config); err != nil {
@@ -191,6 +215,12 @@ func packageApp(config *PackageConfig) error {
 		return fmt.Errorf("permissions: %w", err)
 	}
 
+	// Create Finder Quick Action
+	config.WorkflowDir = filepath.Join(config.OutputDir, config.ServiceName+".workflow")
+	if err := createQuickAction(config); err != nil {
+		fmt.Printf("⚠️  Quick Action creation failed (non-fatal): %v\n", err)
+	}
+
 	// Calculate app size
 	if appSize, err := calculateDirectorySize(config.AppDir); err == nil {
 		stats.AppSize = appSize
@@ -212,6 +242,7 @@ func packageApp(config *PackageConfig) error {
 	fmt.Printf("✅ Package created successfully:\n")
 	fmt.Printf("   📱 App Bundle: %s\n", config.AppDir)
 	fmt.Printf("   💿 DMG: %s\n", config.DMGPath)
+	fmt.Printf("   ⚡ Quick Action: %s (copy to ~/Library/Services)\n", config.WorkflowDir)
 
 	return nil
 }
@@ -373,6 +404,183 @@ func createInfoPlist(config *PackageConfig) error {
 	return tmpl.Execute(file, config)
 }
 
+// createQuickAction writes an Automator service that appears in Finder's
+// Quick Actions menu for images. It launches the bundled executable with the
+// selected files as arguments; the app opens each one and processes it with
+// the default parameters.
+func createQuickAction(config *PackageConfig) error {
+	const workflowInfoTemplate = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>NSServices</key>
+	<array>
+		<dict>
+			<key>NSMenuItem</key>
+			<dict>
+				<key>default</key>
+				<string>{{.ServiceName}}</string>
+			</dict>
+			<key>NSMessage</key>
+			<string>runWorkflowAsService</string>
+			<key>NSRequiredContext</key>
+			<dict>
+				<key>NSApplicationIdentifier</key>
+				<string>com.apple.finder</string>
+			</dict>
+			<key>NSSendFileTypes</key>
+			<array>
+				<string>public.image</string>
+			</array>
+		</dict>
+	</array>
+</dict>
+</plist>`
+
+	const workflowDocumentTemplate = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>AMApplicationBuild</key>
+	<string>523</string>
+	<key>AMApplicationVersion</key>
+	<string>2.10</string>
+	<key>AMDocumentVersion</key>
+	<string>2</string>
+	<key>actions</key>
+	<array>
+		<dict>
+			<key>action</key>
+			<dict>
+				<key>AMAccepts</key>
+				<dict>
+					<key>Container</key>
+					<string>List</string>
+					<key>Optional</key>
+					<true/>
+					<key>Types</key>
+					<array>
+						<string>com.apple.cocoa.string</string>
+					</array>
+				</dict>
+				<key>AMActionVersion</key>
+				<string>2.0.3</string>
+				<key>AMParameterProperties</key>
+				<dict>
+					<key>COMMAND_STRING</key>
+					<dict/>
+					<key>CheckedForUserDefaultShell</key>
+					<dict/>
+					<key>inputMethod</key>
+					<dict/>
+					<key>shell</key>
+					<dict/>
+					<key>source</key>
+					<dict/>
+				</dict>
+				<key>AMProvides</key>
+				<dict>
+					<key>Container</key>
+					<string>List</string>
+					<key>Types</key>
+					<array>
+						<string>com.apple.cocoa.string</string>
+					</array>
+				</dict>
+				<key>ActionBundlePath</key>
+				<string>/System/Library/Automator/Run Shell Script.action</string>
+				<key>ActionName</key>
+				<string>Run Shell Script</string>
+				<key>ActionParameters</key>
+				<dict>
+					<key>COMMAND_STRING</key>
+					<string>"/Applications/{{.AppName}}.app/Contents/MacOS/{{.AppExecutable}}" "$@" &gt;/dev/null 2&gt;&amp;1 &amp;</string>
+					<key>CheckedForUserDefaultShell</key>
+					<true/>
+					<key>inputMethod</key>
+					<integer>1</integer>
+					<key>shell</key>
+					<string>/bin/bash</string>
+					<key>source</key>
+					<string></string>
+				</dict>
+				<key>BundleIdentifier</key>
+				<string>com.apple.RunShellScript</string>
+				<key>CFBundleVersion</key>
+				<string>2.0.3</string>
+				<key>CanShowSelectedItemsWhenRun</key>
+				<false/>
+				<key>CanShowWhenRun</key>
+				<true/>
+				<key>Category</key>
+				<array>
+					<string>AMCategoryUtilities</string>
+				</array>
+				<key>Class Name</key>
+				<string>RunShellScriptAction</string>
+				<key>InputUUID</key>
+				<string>6F2A4C1E-9B7D-4E2A-8C35-0D1F7A9B3E21</string>
+				<key>OutputUUID</key>
+				<string>3B8E5D27-1C4F-4A96-B0E2-7F6D9C8A5B14</string>
+				<key>UUID</key>
+				<string>A4D1C7E9-2F3B-4C58-9E6A-1B0D8F7C2E35</string>
+				<key>isViewVisible</key>
+				<integer>1</integer>
+			</dict>
+		</dict>
+	</array>
+	<key>connectors</key>
+	<dict/>
+	<key>workflowMetaData</key>
+	<dict>
+		<key>serviceApplicationBundleID</key>
+		<string>com.apple.finder</string>
+		<key>serviceInputTypeIdentifier</key>
+		<string>com.apple.Automator.fileSystemObject.image</string>
+		<key>serviceOutputTypeIdentifier</key>
+		<string>com.apple.Automator.nothing</string>
+		<key>serviceProcessesInput</key>
+		<integer>0</integer>
+		<key>workflowTypeIdentifier</key>
+		<string>com.apple.Automator.servicesMenu</string>
+	</dict>
+</dict>
+</plist>`
+
+	contentsDir := filepath.Join(config.WorkflowDir, "Contents")
+	if err := os.MkdirAll(contentsDir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", contentsDir, err)
+	}
+
+	files := []struct {
+		name     string
+		template string
+	}{
+		{"Info.plist", workflowInfoTemplate},
+		{"document.wflow", workflowDocumentTemplate},
+	}
+
+	for _, f := range files {
+		tmpl, err := template.New(f.name).Parse(f.template)
+		if err != nil {
+			return err
+		}
+
+		file, err := os.Create(filepath.Join(contentsDir, f.name))
+		if err != nil {
+			return err
+		}
+
+		err = tmpl.Execute(file, config)
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("write %s: %w", f.name, err)
+		}
+	}
+
+	return nil
+}
+
 func copyBinary(config *PackageConfig) error {
 	srcFile, err := os.Open(config.SourceBinary)
 	if err != nil {
@@ -536,3 +744,102 @@ func verifyAppBundle(appPath string) error {
 
 	return nil
 }
+
+func handleDocker() {
+	build := false
+	for _, arg := range os.Args[2:] {
+		switch arg {
+		case "--build":
+			build = true
+		default:
+			fmt.Printf("Unknown docker option: %s\n", arg)
+			os.Exit(1)
+		}
+	}
+
+	config := &DockerConfig{
+		AppName:       AppName,
+		AppVersion:    AppVersion,
+		AppExecutable: AppExecutable,
+		OpenCVImage:   DefaultOpenCVImage,
+		OutputDir:     DockerOutputDir,
+		ImageTag:      fmt.Sprintf("%s:%s", AppExecutable, AppVersion),
+	}
+
+	if err := createDockerfile(config); err != nil {
+		fmt.Printf("❌ Dockerfile generation failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	dockerfile := filepath.Join(config.OutputDir, "Dockerfile")
+	fmt.Printf("🐳 Dockerfile written to %s\n", dockerfile)
+
+	if !build {
+		fmt.Printf("   Build with: docker build -f %s -t %s .\n", dockerfile, config.ImageTag)
+		return
+	}
+
+	if err := buildDockerImage(config, dockerfile); err != nil {
+		fmt.Printf("❌ Docker build failed: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("✅ Built image %s\n", config.ImageTag)
+}
+
+// createDockerfile writes a two-stage Dockerfile. The build stage compiles
+// the CLI with the nogui tag; the final stage starts from scratch and holds
+// only the binary plus the shared libraries ldd reports for it, which keeps
+// the OpenCV runtime and loader consistent with the build stage.
+func createDockerfile(config *DockerConfig) error {
+	const dockerfileTemplate = `# Generated by cmd/package docker. Build from the repository root:
+#   docker build -f {{.OutputDir}}/Dockerfile -t {{.ImageTag}} .
+ARG OPENCV_IMAGE={{.OpenCVImage}}
+
+FROM ${OPENCV_IMAGE} AS build
+ENV GOTOOLCHAIN=auto CGO_ENABLED=1
+WORKDIR /src
+COPY go.mod go.sum ./
+RUN go mod download
+COPY . .
+RUN go build -tags nogui -trimpath -ldflags "-s -w" -o /out/{{.AppExecutable}} . \
+ && mkdir -p /runtime/tmp \
+ && ldd /out/{{.AppExecutable}} | grep -o '/[^ ]*' | sort -u | xargs -I{} cp --parents -L {} /runtime
+
+FROM scratch
+LABEL org.opencontainers.image.title="{{.AppName}}" \
+      org.opencontainers.image.version="{{.AppVersion}}"
+COPY --from=build /runtime/ /
+COPY --from=build /out/{{.AppExecutable}} /usr/local/bin/{{.AppExecutable}}
+ENV OTSU_LOG_LEVEL=info
+WORKDIR /data
+ENTRYPOINT ["/usr/local/bin/{{.AppExecutable}}", "process"]
+`
+
+	if err := os.MkdirAll(config.OutputDir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", config.OutputDir, err)
+	}
+
+	tmpl, err := template.New("dockerfile").Parse(dockerfileTemplate)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(filepath.Join(config.OutputDir, "Dockerfile"))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return tmpl.Execute(file, config)
+}
+
+func buildDockerImage(config *DockerConfig, dockerfile string) error {
+	if _, err := exec.LookPath("docker"); err != nil {
+		return fmt.Errorf("docker not found in PATH")
+	}
+
+	cmd := exec.Command("docker", "build", "-f", dockerfile, "-t", config.ImageTag, ".")
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
@@ -0,0 +1,452 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	DatasetConventionDIBCO  = "dibco"
+	DatasetConventionPHIBD  = "phibd"
+	DatasetConventionCustom = "custom"
+)
+
+// defaultGroundTruthSuffixes are tried in order after the image stem when
+// a dataset does not name its own.
+var defaultGroundTruthSuffixes = []string{"", "_gt", "_GT"}
+
+// datasetImageExtensions are listed as dataset members. BMP does not
+// decode; it is listed so validation can report it.
+var datasetImageExtensions = []string{".png", ".jpg", ".jpeg", ".bmp", ".tif", ".tiff"}
+
+// Dataset is a registered ground truth collection. The ground truth of an
+// image with stem s, after ImageSuffix is stripped from it, is the file in
+// GroundTruthDir named s plus the first matching GroundTruthSuffixes entry,
+// with any extension. Both directories may be the same.
+type Dataset struct {
+	Name                string    `json:"name"`
+	Convention          string    `json:"convention"`
+	ImageDir            string    `json:"image_dir"`
+	GroundTruthDir      string    `json:"ground_truth_dir"`
+	ImageSuffix         string    `json:"image_suffix,omitempty"`
+	GroundTruthSuffixes []string  `json:"ground_truth_suffixes"`
+	AddedAt             time.Time `json:"added_at"`
+}
+
+// DatasetProblem is one finding of Validate.
+type DatasetProblem struct {
+	Path    string `json:"path"`
+	Problem string `json:"problem"`
+}
+
+func (dp DatasetProblem) String() string {
+	return fmt.Sprintf("%s: %s", dp.Path, dp.Problem)
+}
+
+func (d *Dataset) suffixes() []string {
+	if len(d.GroundTruthSuffixes) == 0 {
+		return defaultGroundTruthSuffixes
+	}
+	return d.GroundTruthSuffixes
+}
+
+// isGroundTruthName reports whether a file in a shared directory is ground
+// truth rather than an image.
+func (d *Dataset) isGroundTruthName(stem string) bool {
+	if d.ImageDir != d.GroundTruthDir {
+		return false
+	}
+	if d.ImageSuffix != "" {
+		return !strings.HasSuffix(stem, d.ImageSuffix)
+	}
+	for _, suffix := range d.suffixes() {
+		if suffix != "" && strings.HasSuffix(stem, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// groundTruthFor returns the ground truth file of image, or an error when
+// there is none.
+func (d *Dataset) groundTruthFor(image string) (string, error) {
+	stem := strings.TrimSuffix(strings.TrimSuffix(filepath.Base(image), filepath.Ext(image)), d.ImageSuffix)
+	for _, suffix := range d.suffixes() {
+		matches, err := filepath.Glob(filepath.Join(d.GroundTruthDir, globEscape(stem+suffix)+".*"))
+		if err != nil {
+			return "", err
+		}
+		for _, match := range matches {
+			if match != image && hasDatasetImageExtension(match) {
+				return match, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("no ground truth for %s in %s", image, d.GroundTruthDir)
+}
+
+// Images lists the dataset's images in name order.
+func (d *Dataset) Images() ([]string, error) {
+	entries, err := os.ReadDir(d.ImageDir)
+	if err != nil {
+		return nil, fmt.Errorf("dataset %s: %w", d.Name, err)
+	}
+
+	var images []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !hasDatasetImageExtension(name) || d.isGroundTruthName(strings.TrimSuffix(name, filepath.Ext(name))) {
+			continue
+		}
+		images = append(images, filepath.Join(d.ImageDir, name))
+	}
+	sort.Strings(images)
+	return images, nil
+}
+
+// Pairs maps every image to its ground truth. Images without one are left
+// out and reported as problems.
+func (d *Dataset) Pairs() ([]BenchmarkPair, []DatasetProblem, error) {
+	images, err := d.Images()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var pairs []BenchmarkPair
+	var problems []DatasetProblem
+	for _, image := range images {
+		truth, err := d.groundTruthFor(image)
+		if err != nil {
+			problems = append(problems, DatasetProblem{Path: image, Problem: "no ground truth"})
+			continue
+		}
+		pairs = append(pairs, BenchmarkPair{Image: image, GroundTruth: truth})
+	}
+	return pairs, problems, nil
+}
+
+// Validate checks every pair as the benchmark will load it: both files must
+// decode and have the same dimensions. Ground truth files no image maps to
+// are reported too. The returned pairs are the valid ones.
+func (d *Dataset) Validate() ([]BenchmarkPair, []DatasetProblem, error) {
+	pairs, problems, err := d.Pairs()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var valid []BenchmarkPair
+	claimed := make(map[string]bool, len(pairs))
+	for _, pair := range pairs {
+		claimed[pair.GroundTruth] = true
+
+		imageHeader, err := inspectImageFile(pair.Image)
+		if err != nil {
+			problems = append(problems, DatasetProblem{Path: pair.Image, Problem: err.Error()})
+			continue
+		}
+		truthHeader, err := inspectImageFile(pair.GroundTruth)
+		if err != nil {
+			problems = append(problems, DatasetProblem{Path: pair.GroundTruth, Problem: err.Error()})
+			continue
+		}
+		if imageHeader.Width != truthHeader.Width || imageHeader.Height != truthHeader.Height {
+			problems = append(problems, DatasetProblem{Path: pair.GroundTruth, Problem: fmt.Sprintf(
+				"ground truth is %dx%d but the image is %dx%d",
+				truthHeader.Width, truthHeader.Height, imageHeader.Width, imageHeader.Height)})
+			continue
+		}
+		valid = append(valid, pair)
+	}
+
+	entries, err := os.ReadDir(d.GroundTruthDir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("dataset %s: %w", d.Name, err)
+	}
+	for _, entry := range entries {
+		path := filepath.Join(d.GroundTruthDir, entry.Name())
+		stem := strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+		if entry.IsDir() || !hasDatasetImageExtension(path) || claimed[path] {
+			continue
+		}
+		if d.ImageDir == d.GroundTruthDir && !d.isGroundTruthName(stem) {
+			continue
+		}
+		problems = append(problems, DatasetProblem{Path: path, Problem: "ground truth without an image"})
+	}
+
+	problems = append(problems, duplicateImageProblems(valid)...)
+	return valid, problems, nil
+}
+
+// duplicateImageProblems reports images that repeat an earlier image of
+// pairs, byte for byte or by their perceptual hash, since a repeated page
+// counts twice in benchmark statistics.
+func duplicateImageProblems(pairs []BenchmarkPair) []DatasetProblem {
+	var problems []DatasetProblem
+	var earlier []*ImageHashes
+	var earlierPaths []string
+	for _, pair := range pairs {
+		hashes, err := HashImageFile(pair.Image)
+		if err != nil {
+			problems = append(problems, DatasetProblem{Path: pair.Image, Problem: err.Error()})
+			continue
+		}
+		for i, other := range earlier {
+			if other.SHA256 == hashes.SHA256 {
+				problems = append(problems, DatasetProblem{Path: pair.Image, Problem: "same file as " + earlierPaths[i]})
+				break
+			}
+			if distance := other.Perceptual.Distance(hashes.Perceptual); distance <= NearDuplicateDistance {
+				problems = append(problems, DatasetProblem{Path: pair.Image, Problem: fmt.Sprintf(
+					"near-duplicate of %s (perceptual hash distance %d)", earlierPaths[i], distance)})
+				break
+			}
+		}
+		earlier = append(earlier, hashes)
+		earlierPaths = append(earlierPaths, pair.Image)
+	}
+	return problems
+}
+
+// globEscape quotes the pattern characters in a file name.
+func globEscape(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		if strings.ContainsRune(`*?[\`, r) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func inspectImageFile(path string) (*ImageHeader, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return InspectImageData(data)
+}
+
+func hasDatasetImageExtension(path string) bool {
+	extension := strings.ToLower(filepath.Ext(path))
+	for _, candidate := range datasetImageExtensions {
+		if extension == candidate {
+			return true
+		}
+	}
+	return false
+}
+
+// ImportDataset recognizes the directory convention of a public collection
+// under root and describes it as a Dataset.
+//
+// DIBCO releases either keep <id>_in and <id>_gt files in one folder, or
+// split images and ground truth into two folders, the ground truth one
+// named with "GT". PHIBD keeps images in an "Original" folder and ground
+// truth in a "GT" or "GroundTruth" folder, with a _gt suffix.
+func ImportDataset(name, convention, root string) (*Dataset, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+
+	dataset := &Dataset{Name: name, Convention: convention}
+	switch convention {
+	case DatasetConventionDIBCO:
+		if countDatasetFiles(root, "_in") > 0 && countDatasetFiles(root, "_gt") > 0 {
+			dataset.ImageDir, dataset.GroundTruthDir = root, root
+			dataset.ImageSuffix = "_in"
+			dataset.GroundTruthSuffixes = []string{"_gt", "_GT"}
+			return dataset, nil
+		}
+		dataset.GroundTruthSuffixes = []string{"_gt", "_GT", "_estGT", ""}
+		dataset.ImageDir, dataset.GroundTruthDir, err = findSplitLayout(root, nil, []string{"gt", "groundtruth", "ground_truth"})
+	case DatasetConventionPHIBD:
+		dataset.GroundTruthSuffixes = []string{"_gt", "_GT", ""}
+		dataset.ImageDir, dataset.GroundTruthDir, err = findSplitLayout(root, []string{"original", "images"}, []string{"gt", "groundtruth", "ground_truth"})
+	default:
+		return nil, fmt.Errorf("unknown convention %q: expected %s or %s", convention, DatasetConventionDIBCO, DatasetConventionPHIBD)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s layout in %s: %w", convention, root, err)
+	}
+	return dataset, nil
+}
+
+// findSplitLayout picks the ground truth folder among the subfolders of
+// root by name, and the image folder as the one matching imageNames, or
+// the only other folder with images when imageNames is nil.
+func findSplitLayout(root string, imageNames, truthNames []string) (string, string, error) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return "", "", err
+	}
+
+	nameMatches := func(name string, candidates []string) bool {
+		name = strings.ToLower(name)
+		for _, candidate := range candidates {
+			if strings.Contains(name, candidate) {
+				return true
+			}
+		}
+		return false
+	}
+
+	var imageDirs, truthDirs []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		path := filepath.Join(root, entry.Name())
+		if countDatasetFiles(path, "") == 0 {
+			continue
+		}
+		switch {
+		case nameMatches(entry.Name(), truthNames):
+			truthDirs = append(truthDirs, path)
+		case imageNames == nil || nameMatches(entry.Name(), imageNames):
+			imageDirs = append(imageDirs, path)
+		}
+	}
+
+	if len(truthDirs) != 1 || len(imageDirs) != 1 {
+		return "", "", fmt.Errorf("expected one image folder and one ground truth folder with images, found %d and %d",
+			len(imageDirs), len(truthDirs))
+	}
+	return imageDirs[0], truthDirs[0], nil
+}
+
+// countDatasetFiles counts the images in dir whose stem ends in suffix.
+func countDatasetFiles(dir, suffix string) int {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0
+	}
+
+	count := 0
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() && hasDatasetImageExtension(name) && strings.HasSuffix(strings.TrimSuffix(name, filepath.Ext(name)), suffix) {
+			count++
+		}
+	}
+	return count
+}
+
+// DatasetRegistry is the list of registered datasets, kept as JSON in the
+// user configuration directory.
+type DatasetRegistry struct {
+	Datasets []*Dataset `json:"datasets"`
+
+	path string
+}
+
+// DefaultDatasetRegistryPath is $OTSU_DATASETS, or datasets.json in the
+// otsu-obliterator configuration directory.
+func DefaultDatasetRegistryPath() string {
+	if path := os.Getenv(envDatasets); path != "" {
+		return path
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "otsu-obliterator", "datasets.json")
+}
+
+// LoadDatasetRegistry reads the registry at path; a missing file is an
+// empty registry.
+func LoadDatasetRegistry(path string) (*DatasetRegistry, error) {
+	registry := &DatasetRegistry{path: path}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return registry, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read dataset registry: %w", err)
+	}
+	if err := json.Unmarshal(data, registry); err != nil {
+		return nil, fmt.Errorf("decode dataset registry %s: %w", path, err)
+	}
+	return registry, nil
+}
+
+func (dr *DatasetRegistry) Find(name string) (*Dataset, error) {
+	for _, dataset := range dr.Datasets {
+		if dataset.Name == name {
+			return dataset, nil
+		}
+	}
+	return nil, fmt.Errorf("no dataset named %q", name)
+}
+
+// Add registers dataset after checking its folders exist, replacing a
+// dataset of the same name only when replace is set.
+func (dr *DatasetRegistry) Add(dataset *Dataset, replace bool) error {
+	if dataset.Name == "" {
+		return fmt.Errorf("dataset has no name")
+	}
+	for _, dir := range []string{dataset.ImageDir, dataset.GroundTruthDir} {
+		info, err := os.Stat(dir)
+		if err != nil {
+			return fmt.Errorf("dataset %s: %w", dataset.Name, err)
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("dataset %s: %s is not a directory", dataset.Name, dir)
+		}
+	}
+
+	for i, existing := range dr.Datasets {
+		if existing.Name == dataset.Name {
+			if !replace {
+				return fmt.Errorf("dataset %q is already registered", dataset.Name)
+			}
+			dr.Datasets = append(dr.Datasets[:i], dr.Datasets[i+1:]...)
+			break
+		}
+	}
+
+	dataset.AddedAt = time.Now().UTC()
+	dr.Datasets = append(dr.Datasets, dataset)
+	sort.Slice(dr.Datasets, func(i, j int) bool { return dr.Datasets[i].Name < dr.Datasets[j].Name })
+	return nil
+}
+
+func (dr *DatasetRegistry) Remove(name string) error {
+	for i, dataset := range dr.Datasets {
+		if dataset.Name == name {
+			dr.Datasets = append(dr.Datasets[:i], dr.Datasets[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("no dataset named %q", name)
+}
+
+// Save writes through a temporary file like the batch checkpoint.
+func (dr *DatasetRegistry) Save() error {
+	if err := os.MkdirAll(filepath.Dir(dr.path), 0755); err != nil {
+		return fmt.Errorf("create registry directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(dr, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode dataset registry: %w", err)
+	}
+
+	temporary := dr.path + ".tmp"
+	if err := os.WriteFile(temporary, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("write dataset registry: %w", err)
+	}
+	if err := os.Rename(temporary, dr.path); err != nil {
+		return fmt.Errorf("write dataset registry: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+const (
+	// signatureMaxDimension bounds the image a signature is measured on, so
+	// edge density compares between scans of different resolution.
+	signatureMaxDimension = 1024
+	// profileMatchDistance is the largest root mean square z-score at which
+	// an image still counts as resembling a profile's dataset.
+	profileMatchDistance = 2.0
+)
+
+// ImageSignature summarizes the appearance of a page: paper brightness,
+// contrast, how much of it is ink, histogram entropy, color and texture.
+type ImageSignature struct {
+	MeanIntensity float64 `json:"mean_intensity"`
+	Contrast      float64 `json:"contrast"`
+	InkCoverage   float64 `json:"ink_coverage"`
+	Entropy       float64 `json:"entropy"`
+	Saturation    float64 `json:"saturation"`
+	EdgeDensity   float64 `json:"edge_density"`
+}
+
+// signatureFeature names one ImageSignature field. Floor is the smallest
+// spread assumed for a dataset, so a uniform dataset does not reject an
+// image over a difference no one would see.
+type signatureFeature struct {
+	Name  string
+	Floor float64
+	Field func(signature *ImageSignature) *float64
+}
+
+var signatureFeatures = []signatureFeature{
+	{"mean_intensity", 8, func(s *ImageSignature) *float64 { return &s.MeanIntensity }},
+	{"contrast", 5, func(s *ImageSignature) *float64 { return &s.Contrast }},
+	{"ink_coverage", 0.03, func(s *ImageSignature) *float64 { return &s.InkCoverage }},
+	{"entropy", 0.3, func(s *ImageSignature) *float64 { return &s.Entropy }},
+	{"saturation", 8, func(s *ImageSignature) *float64 { return &s.Saturation }},
+	{"edge_density", 0.01, func(s *ImageSignature) *float64 { return &s.EdgeDensity }},
+}
+
+// ImageSignature measures the original image.
+func (pe *ProcessingEngine) ImageSignature() (*ImageSignature, error) {
+	if pe.originalImage == nil {
+		return nil, fmt.Errorf("no original image loaded")
+	}
+	source := pe.originalImage.Mat
+
+	working := source.Clone()
+	defer working.Close()
+	if longest := max(source.Cols(), source.Rows()); longest > signatureMaxDimension {
+		scale := float64(signatureMaxDimension) / float64(longest)
+		gocv.Resize(source, &working, image.Pt(scaledDimension(source.Cols(), scale), scaledDimension(source.Rows(), scale)), 0, 0, gocv.InterpolationArea)
+	}
+
+	gray := pe.convertToGrayscale(working)
+	defer gray.Close()
+
+	signature := &ImageSignature{}
+
+	var histogram [256]float64
+	for _, value := range gray.ToBytes() {
+		histogram[value]++
+	}
+	total := float64(gray.Rows() * gray.Cols())
+	for value, count := range histogram {
+		signature.MeanIntensity += float64(value) * count / total
+	}
+	for value, count := range histogram {
+		p := count / total
+		d := float64(value) - signature.MeanIntensity
+		signature.Contrast += d * d * p
+		if p > 0 {
+			signature.Entropy -= p * math.Log2(p)
+		}
+	}
+	signature.Contrast = math.Sqrt(signature.Contrast)
+
+	binary := gocv.NewMat()
+	defer binary.Close()
+	gocv.Threshold(gray, &binary, 0, 255, gocv.ThresholdBinary|gocv.ThresholdOtsu)
+	signature.InkCoverage = 1 - float64(gocv.CountNonZero(binary))/total
+
+	edges := gocv.NewMat()
+	defer edges.Close()
+	gocv.Canny(gray, &edges, 50, 150)
+	signature.EdgeDensity = float64(gocv.CountNonZero(edges)) / total
+
+	if working.Channels() >= 3 {
+		bgr := working
+		if working.Channels() == 4 {
+			bgr = gocv.NewMat()
+			defer bgr.Close()
+			gocv.CvtColor(working, &bgr, gocv.ColorBGRAToBGR)
+		}
+		hsv := gocv.NewMat()
+		defer hsv.Close()
+		gocv.CvtColor(bgr, &hsv, gocv.ColorBGRToHSV)
+		signature.Saturation = hsv.Mean().Val2
+	}
+
+	return signature, nil
+}
+
+// ImageSignatureOfFile decodes path at about the size signatures are
+// measured on and measures it.
+func ImageSignatureOfFile(path string) (*ImageSignature, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	header, err := InspectImageData(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	scale := math.Min(1, float64(signatureMaxDimension)/float64(max(header.Width, header.Height)))
+	imageData, err := DecodeImageDataScaled(data, strings.ToLower(filepath.Ext(path)), scale)
+	if err != nil {
+		return nil, fmt.Errorf("decode %s: %w", path, err)
+	}
+
+	engine := NewProcessingEngine()
+	defer engine.Close()
+	engine.SetOriginalImage(imageData)
+	return engine.ImageSignature()
+}
+
+// DatasetSignature is the mean and spread of the signatures of a set of
+// images.
+type DatasetSignature struct {
+	Images int            `json:"images"`
+	Mean   ImageSignature `json:"mean"`
+	StdDev ImageSignature `json:"std_dev"`
+}
+
+func NewDatasetSignature(signatures []*ImageSignature) *DatasetSignature {
+	ds := &DatasetSignature{Images: len(signatures)}
+	if len(signatures) == 0 {
+		return ds
+	}
+
+	for _, feature := range signatureFeatures {
+		mean, spread := feature.Field(&ds.Mean), feature.Field(&ds.StdDev)
+		for _, signature := range signatures {
+			*mean += *feature.Field(signature)
+		}
+		*mean /= float64(len(signatures))
+		for _, signature := range signatures {
+			d := *feature.Field(signature) - *mean
+			*spread += d * d
+		}
+		*spread = math.Sqrt(*spread / float64(len(signatures)))
+	}
+	return ds
+}
+
+// Distance is the root mean square z-score of signature against the
+// dataset, with each spread raised to its feature's floor.
+func (ds *DatasetSignature) Distance(signature *ImageSignature) float64 {
+	sum := 0.0
+	for _, feature := range signatureFeatures {
+		spread := math.Max(*feature.Field(&ds.StdDev), feature.Floor)
+		z := (*feature.Field(signature) - *feature.Field(&ds.Mean)) / spread
+		sum += z * z
+	}
+	return math.Sqrt(sum / float64(len(signatureFeatures)))
+}
+
+// Profile is a tuned parameter set bound to the signature of the images it
+// was tuned on.
+type Profile struct {
+	Name      string            `json:"name"`
+	Dataset   string            `json:"dataset,omitempty"`
+	Metric    string            `json:"metric"`
+	Score     float64           `json:"score"`
+	Params    *OtsuParameters   `json:"params"`
+	Signature *DatasetSignature `json:"signature"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+// Describe names the profile and where it came from for suggestions.
+func (p *Profile) Describe() string {
+	source := fmt.Sprintf("%d images", p.Signature.Images)
+	if p.Dataset != "" {
+		source = fmt.Sprintf("dataset %s, %s", p.Dataset, source)
+	}
+	return fmt.Sprintf("%s (%s %.4f on %s)", p.Name, p.Metric, p.Score, source)
+}
+
+// ProfileRegistry is the list of saved profiles, kept as JSON next to the
+// dataset registry.
+type ProfileRegistry struct {
+	Profiles []*Profile `json:"profiles"`
+
+	path string
+}
+
+// DefaultProfileRegistryPath is $OTSU_PROFILES, or profiles.json in the
+// otsu-obliterator configuration directory.
+func DefaultProfileRegistryPath() string {
+	if path := os.Getenv(envProfiles); path != "" {
+		return path
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "otsu-obliterator", "profiles.json")
+}
+
+// LoadProfileRegistry reads the registry at path; a missing file is an
+// empty registry.
+func LoadProfileRegistry(path string) (*ProfileRegistry, error) {
+	registry := &ProfileRegistry{path: path}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return registry, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read profile registry: %w", err)
+	}
+	if err := json.Unmarshal(data, registry); err != nil {
+		return nil, fmt.Errorf("decode profile registry %s: %w", path, err)
+	}
+	return registry, nil
+}
+
+func (pr *ProfileRegistry) Find(name string) (*Profile, error) {
+	for _, profile := range pr.Profiles {
+		if profile.Name == name {
+			return profile, nil
+		}
+	}
+	return nil, fmt.Errorf("no profile named %q", name)
+}
+
+// Add saves profile, replacing a profile of the same name only when
+// replace is set.
+func (pr *ProfileRegistry) Add(profile *Profile, replace bool) error {
+	if profile.Name == "" {
+		return fmt.Errorf("profile has no name")
+	}
+	if profile.Signature == nil || profile.Signature.Images == 0 {
+		return fmt.Errorf("profile %s has no dataset signature", profile.Name)
+	}
+
+	for i, existing := range pr.Profiles {
+		if existing.Name == profile.Name {
+			if !replace {
+				return fmt.Errorf("profile %q already exists", profile.Name)
+			}
+			pr.Profiles = append(pr.Profiles[:i], pr.Profiles[i+1:]...)
+			break
+		}
+	}
+
+	profile.CreatedAt = time.Now().UTC()
+	pr.Profiles = append(pr.Profiles, profile)
+	sort.Slice(pr.Profiles, func(i, j int) bool { return pr.Profiles[i].Name < pr.Profiles[j].Name })
+	return nil
+}
+
+func (pr *ProfileRegistry) Remove(name string) error {
+	for i, profile := range pr.Profiles {
+		if profile.Name == name {
+			pr.Profiles = append(pr.Profiles[:i], pr.Profiles[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("no profile named %q", name)
+}
+
+// Suggest returns the profile whose dataset signature is closest to
+// signature, or nil when none is within profileMatchDistance.
+func (pr *ProfileRegistry) Suggest(signature *ImageSignature) (*Profile, float64) {
+	var best *Profile
+	bestDistance := math.Inf(1)
+	for _, profile := range pr.Profiles {
+		if profile.Signature == nil || profile.Params == nil {
+			continue
+		}
+		if distance := profile.Signature.Distance(signature); distance < bestDistance {
+			best, bestDistance = profile, distance
+		}
+	}
+	if bestDistance > profileMatchDistance {
+		return nil, bestDistance
+	}
+	return best, bestDistance
+}
+
+// Save writes through a temporary file like the dataset registry.
+func (pr *ProfileRegistry) Save() error {
+	if err := os.MkdirAll(filepath.Dir(pr.path), 0755); err != nil {
+		return fmt.Errorf("create registry directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(pr, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode profile registry: %w", err)
+	}
+
+	temporary := pr.path + ".tmp"
+	if err := os.WriteFile(temporary, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("write profile registry: %w", err)
+	}
+	if err := os.Rename(temporary, pr.path); err != nil {
+		return fmt.Errorf("write profile registry: %w", err)
+	}
+	return nil
+}
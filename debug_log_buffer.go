@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// logBufferCapacity is how many records the log viewer can look back on.
+const logBufferCapacity = 5000
+
+// LogEntry is one captured log record with its attributes flattened to
+// key=value pairs.
+type LogEntry struct {
+	Time    time.Time
+	Level   slog.Level
+	Message string
+	Attrs   string
+}
+
+// Text is the entry as one line, as the log viewer shows and searches it.
+func (e LogEntry) Text() string {
+	text := e.Time.Format("15:04:05.000") + " " + e.Level.String() + " " + e.Message
+	if e.Attrs != "" {
+		text += " " + e.Attrs
+	}
+	return text
+}
+
+// LogBuffer keeps the most recent log records of every level in memory so
+// the GUI can show them without a terminal.
+type LogBuffer struct {
+	mu      sync.Mutex
+	entries []LogEntry
+	start   int
+	version uint64
+}
+
+var (
+	logBuffer     *LogBuffer
+	logBufferOnce sync.Once
+)
+
+// GetLogBuffer returns the process-wide buffer the GUI captures logs into.
+func GetLogBuffer() *LogBuffer {
+	logBufferOnce.Do(func() {
+		logBuffer = &LogBuffer{entries: make([]LogEntry, 0, logBufferCapacity)}
+	})
+	return logBuffer
+}
+
+func (lb *LogBuffer) add(entry LogEntry) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	if len(lb.entries) < logBufferCapacity {
+		lb.entries = append(lb.entries, entry)
+	} else {
+		lb.entries[lb.start] = entry
+		lb.start = (lb.start + 1) % logBufferCapacity
+	}
+	lb.version++
+}
+
+// Entries returns the buffered records, oldest first, and the version they
+// were taken at.
+func (lb *LogBuffer) Entries() ([]LogEntry, uint64) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	entries := make([]LogEntry, 0, len(lb.entries))
+	entries = append(entries, lb.entries[lb.start:]...)
+	entries = append(entries, lb.entries[:lb.start]...)
+	return entries, lb.version
+}
+
+// Version changes whenever a record is added or the buffer is cleared.
+func (lb *LogBuffer) Version() uint64 {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	return lb.version
+}
+
+func (lb *LogBuffer) Clear() {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	lb.entries = lb.entries[:0]
+	lb.start = 0
+	lb.version++
+}
+
+// Wrap returns a handler that records every record of Debug level and above
+// and passes on those next is enabled for.
+func (lb *LogBuffer) Wrap(next slog.Handler) slog.Handler {
+	return &logBufferHandler{buffer: lb, next: next}
+}
+
+type logBufferHandler struct {
+	buffer *LogBuffer
+	next   slog.Handler
+	attrs  string
+	group  string
+}
+
+func (h *logBufferHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= slog.LevelDebug || h.next.Enabled(ctx, level)
+}
+
+func (h *logBufferHandler) Handle(ctx context.Context, record slog.Record) error {
+	var attrs strings.Builder
+	attrs.WriteString(h.attrs)
+	record.Attrs(func(attr slog.Attr) bool {
+		appendLogAttr(&attrs, h.group, attr)
+		return true
+	})
+	h.buffer.add(LogEntry{
+		Time:    record.Time,
+		Level:   record.Level,
+		Message: record.Message,
+		Attrs:   attrs.String(),
+	})
+
+	if !h.next.Enabled(ctx, record.Level) {
+		return nil
+	}
+	return h.next.Handle(ctx, record)
+}
+
+func (h *logBufferHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var prefix strings.Builder
+	prefix.WriteString(h.attrs)
+	for _, attr := range attrs {
+		appendLogAttr(&prefix, h.group, attr)
+	}
+	return &logBufferHandler{buffer: h.buffer, next: h.next.WithAttrs(attrs), attrs: prefix.String(), group: h.group}
+}
+
+func (h *logBufferHandler) WithGroup(name string) slog.Handler {
+	group := name
+	if h.group != "" {
+		group = h.group + "." + name
+	}
+	return &logBufferHandler{buffer: h.buffer, next: h.next.WithGroup(name), attrs: h.attrs, group: group}
+}
+
+func appendLogAttr(text *strings.Builder, group string, attr slog.Attr) {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return
+	}
+
+	key := attr.Key
+	if key == "" {
+		key = group
+	} else if group != "" {
+		key = group + "." + key
+	}
+	if attr.Value.Kind() == slog.KindGroup {
+		for _, member := range attr.Value.Group() {
+			appendLogAttr(text, key, member)
+		}
+		return
+	}
+
+	if text.Len() > 0 {
+		text.WriteByte(' ')
+	}
+	value := attr.Value.String()
+	if strings.ContainsAny(value, " =\"") || value == "" {
+		value = strconv.Quote(value)
+	}
+	text.WriteString(key + "=" + value)
+}
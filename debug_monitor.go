@@ -218,6 +218,21 @@ func (rm *ResourceMonitor) RecordOperationEnd(operationID int64, duration time.D
 	}
 }
 
+// CurrentHeapUsage returns the live heap allocation and updates the recorded
+// peak, so callers polling for display also feed the peak statistic.
+func (rm *ResourceMonitor) CurrentHeapUsage() uint64 {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+
+	rm.mutex.Lock()
+	if m.HeapAlloc > rm.peakMemoryUsage {
+		rm.peakMemoryUsage = m.HeapAlloc
+	}
+	rm.mutex.Unlock()
+
+	return m.HeapAlloc
+}
+
 func (rm *ResourceMonitor) DumpStats() {
 	rm.mutex.RLock()
 	defer rm.mutex.RUnlock()
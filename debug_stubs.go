@@ -4,6 +4,8 @@ package main
 
 import (
 	"log/slog"
+	"os"
+	"runtime"
 	"time"
 )
 
@@ -17,9 +19,17 @@ type DebugConfig struct {
 	EnableMonitor bool
 	OutputFile    string
 	ConsoleOutput bool
+
+	// Capture also records logs in a LogBuffer for the GUI log viewer.
+	Capture *LogBuffer
 }
 
+// InitDebugSystem logs through slog's default logger; capturing replaces it
+// with one that writes to stderr as well as the buffer.
 func InitDebugSystem(config DebugConfig) *DebugSystem {
+	if config.Capture != nil {
+		slog.SetDefault(slog.New(config.Capture.Wrap(slog.NewTextHandler(os.Stderr, nil))))
+	}
 	return &DebugSystem{
 		logger: slog.Default(),
 	}
@@ -38,6 +48,14 @@ func (ds *DebugSystem) TraceProcessingStart(method string, params *OtsuParameter
 func (ds *DebugSystem) TraceProcessingEnd(operationID int64, duration time.Duration, success bool, errorMsg string) {
 }
 
+// HeapUsageMB reads the runtime directly since release builds have no
+// resource monitor.
+func (ds *DebugSystem) HeapUsageMB() float64 {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return float64(m.HeapAlloc) / 1024 / 1024
+}
+
 func (ds *DebugSystem) TraceParameterChange(field string, oldValue, newValue interface{}) {
 }
 
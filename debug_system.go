@@ -17,6 +17,7 @@ type DebugSystem struct {
 	logger       *slog.Logger
 	tracer       *ParameterTracer
 	monitor      *ResourceMonitor
+	logFile      *os.File
 	enabled      bool
 	startTime    time.Time
 	operationID  int64
@@ -29,6 +30,9 @@ type DebugConfig struct {
 	EnableMonitor bool
 	OutputFile    string
 	ConsoleOutput bool
+
+	// Capture also records logs in a LogBuffer for the GUI log viewer.
+	Capture *LogBuffer
 }
 
 var debugSystem *DebugSystem
@@ -55,6 +59,7 @@ func GetDebugSystem() *DebugSystem {
 
 func newDebugSystem(config DebugConfig) *DebugSystem {
 	var handler slog.Handler
+	var logFile *os.File
 
 	opts := &slog.HandlerOptions{
 		Level:     config.LogLevel,
@@ -78,15 +83,20 @@ func newDebugSystem(config DebugConfig) *DebugSystem {
 			handler = slog.NewTextHandler(os.Stdout, opts)
 		} else {
 			handler = slog.NewJSONHandler(file, opts)
+			logFile = file
 		}
 	} else {
 		handler = slog.NewTextHandler(os.Stdout, opts)
 	}
 
+	if config.Capture != nil {
+		handler = config.Capture.Wrap(handler)
+	}
 	logger := slog.New(handler)
 
 	ds := &DebugSystem{
 		logger:    logger,
+		logFile:   logFile,
 		enabled:   true,
 		startTime: time.Now(),
 	}
@@ -216,6 +226,7 @@ func (ds *DebugSystem) TraceProcessingStart(method string, params *OtsuParameter
 		"image_height", imageSize[1],
 		"window_size", params.WindowSize,
 		"histogram_bins", params.HistogramBins,
+		"neighborhood_bins", params.NeighborhoodBins,
 		"smoothing_strength", params.SmoothingStrength,
 	)
 
@@ -253,6 +264,16 @@ func (ds *DebugSystem) TraceProcessingEnd(operationID int64, duration time.Durat
 	}
 }
 
+func (ds *DebugSystem) HeapUsageMB() float64 {
+	if ds.monitor != nil {
+		return bytesToMB(ds.monitor.CurrentHeapUsage())
+	}
+
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return bytesToMB(m.HeapAlloc)
+}
+
 func (ds *DebugSystem) TraceParameterChange(field string, oldValue, newValue interface{}) {
 	if !ds.enabled {
 		return
@@ -342,6 +363,12 @@ func (ds *DebugSystem) Close() error {
 		"total_operations", ds.operationID,
 	)
 
+	if ds.logFile != nil {
+		if err := ds.logFile.Sync(); err != nil {
+			return fmt.Errorf("flush log file: %w", err)
+		}
+		return ds.logFile.Close()
+	}
 	return nil
 }
 
@@ -138,11 +138,12 @@ func (pt *ParameterTracer) AnalyzeParameterPatterns() map[string]interface{} {
 }
 
 func (pt *ParameterTracer) generateParameterKey(params *OtsuParameters) string {
-	return fmt.Sprintf("method_%t_%t_window_%d_bins_%d_smooth_%.1f",
+	return fmt.Sprintf("method_%t_%t_window_%d_bins_%dx%d_smooth_%.1f",
 		params.MultiScaleProcessing,
 		params.RegionAdaptiveThresholding,
 		params.WindowSize,
 		params.HistogramBins,
+		params.NeighborhoodBins,
 		params.SmoothingStrength,
 	)
 }
@@ -170,30 +171,9 @@ func (pt *ParameterTracer) DumpParameterHistory() {
 }
 
 func (pt *ParameterTracer) cloneParameters(params *OtsuParameters) *OtsuParameters {
-	return &OtsuParameters{
-		WindowSize:                 params.WindowSize,
-		HistogramBins:              params.HistogramBins,
-		SmoothingStrength:          params.SmoothingStrength,
-		EdgePreservation:           params.EdgePreservation,
-		NoiseRobustness:            params.NoiseRobustness,
-		GaussianPreprocessing:      params.GaussianPreprocessing,
-		UseLogHistogram:            params.UseLogHistogram,
-		NormalizeHistogram:         params.NormalizeHistogram,
-		ApplyContrastEnhancement:   params.ApplyContrastEnhancement,
-		AdaptiveWindowSizing:       params.AdaptiveWindowSizing,
-		MultiScaleProcessing:       params.MultiScaleProcessing,
-		PyramidLevels:              params.PyramidLevels,
-		NeighborhoodType:           params.NeighborhoodType,
-		InterpolationMethod:        params.InterpolationMethod,
-		MorphologicalPostProcess:   params.MorphologicalPostProcess,
-		MorphologicalKernelSize:    params.MorphologicalKernelSize,
-		HomomorphicFiltering:       params.HomomorphicFiltering,
-		AnisotropicDiffusion:       params.AnisotropicDiffusion,
-		DiffusionIterations:        params.DiffusionIterations,
-		DiffusionKappa:             params.DiffusionKappa,
-		RegionAdaptiveThresholding: params.RegionAdaptiveThresholding,
-		RegionGridSize:             params.RegionGridSize,
-	}
+	clone := *params
+	clone.PostProcessing = append([]MorphOperation(nil), params.PostProcessing...)
+	return &clone
 }
 
 func (pt *ParameterTracer) CleanupOldHistory(maxAge time.Duration) {
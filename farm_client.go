@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"google.golang.org/grpc"
+)
+
+// FarmClient submits images to a coordinator and fetches their results.
+type FarmClient struct {
+	conn *grpc.ClientConn
+}
+
+// submit uploads the image at path and returns its job ID.
+func (fc *FarmClient) submit(ctx context.Context, path string, params *OtsuParameters) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("read %s: %w", path, err)
+	}
+
+	stream, err := openFarmStream(ctx, fc.conn, "Submit", nil)
+	if err != nil {
+		return "", err
+	}
+
+	header := &FarmJobHeader{Name: filepath.Base(path), Params: params}
+	err = sendChunked(data,
+		func(part []byte) any { return &FarmChunk{Header: header, Data: part} },
+		func(part []byte) any { return &FarmChunk{Data: part} },
+		stream.SendMsg)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	if err := stream.CloseSend(); err != nil {
+		return "", err
+	}
+
+	ref := &FarmJobRef{}
+	if err := stream.RecvMsg(ref); err != nil {
+		return "", err
+	}
+	return ref.JobID, nil
+}
+
+// follow prints the events of a job and downloads its result to output
+// once it is done.
+func (fc *FarmClient) follow(ctx context.Context, jobID, input, output string) error {
+	stream, err := openFarmStream(ctx, fc.conn, "Watch", &FarmJobRef{JobID: jobID})
+	if err != nil {
+		return fmt.Errorf("%s: %w", input, err)
+	}
+
+	for {
+		event := &FarmEvent{}
+		if err := stream.RecvMsg(event); err != nil {
+			if errors.Is(err, io.EOF) {
+				err = fmt.Errorf("job %s ended without a result", jobID)
+			}
+			return fmt.Errorf("%s: FAILED: %w", input, err)
+		}
+
+		fmt.Fprintf(os.Stderr, "%s: %s\n", input, event)
+		switch event.State {
+		case FarmJobFailed:
+			return fmt.Errorf("%s: FAILED: %s", input, event.Message)
+		case FarmJobDone:
+			if err := fc.download(ctx, jobID, output); err != nil {
+				return fmt.Errorf("%s: FAILED: %w", input, err)
+			}
+			fmt.Fprintf(os.Stderr, "%s: written to %s\n", input, output)
+			return nil
+		}
+	}
+}
+
+// download writes the result of a finished job to output, with its metrics
+// and, for downscaled sources, its sidecar next to it.
+func (fc *FarmClient) download(ctx context.Context, jobID, output string) error {
+	stream, err := openFarmStream(ctx, fc.conn, "Download", &FarmJobRef{JobID: jobID})
+	if err != nil {
+		return err
+	}
+
+	first := &FarmResultChunk{}
+	if err := stream.RecvMsg(first); err != nil {
+		return err
+	}
+
+	if err := receiveToFile(output, first.Data, func() ([]byte, error) {
+		next := &FarmResultChunk{}
+		err := stream.RecvMsg(next)
+		return next.Data, err
+	}); err != nil {
+		return err
+	}
+
+	metricsPath := strings.TrimSuffix(output, filepath.Ext(output)) + ".metrics.json"
+	if err := os.WriteFile(metricsPath, first.Metrics, 0644); err != nil {
+		return fmt.Errorf("write %s: %w", metricsPath, err)
+	}
+	if len(first.Sidecar) > 0 {
+		if err := os.WriteFile(SidecarPath(output), first.Sidecar, 0644); err != nil {
+			return fmt.Errorf("write %s: %w", SidecarPath(output), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,478 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// farmJobRetention is how long a finished job can still be watched and
+// downloaded before the coordinator forgets it and removes its files.
+const farmJobRetention = time.Hour
+
+// FarmCoordinator queues submitted images and hands them to workers that
+// pull them over gRPC. Each job spools its files to a cache entry, which is
+// released for eviction once the job finishes; job state lives in memory
+// and is lost when the coordinator stops.
+type FarmCoordinator struct {
+	mu     sync.Mutex
+	jobs   map[string]*farmJob
+	queue  []*farmJob
+	queued chan struct{}
+
+	cache       *ArtifactCache
+	lease       time.Duration
+	maxAttempts int
+}
+
+type farmJob struct {
+	id       string
+	name     string
+	params   *OtsuParameters
+	state    string
+	worker   string
+	attempts int
+
+	// leaseUntil is when a running job is requeued unless its worker sends
+	// a heartbeat first.
+	leaseUntil time.Time
+	// finishedAt is when the job was done or failed, zero before.
+	finishedAt time.Time
+
+	entry      string
+	sourcePath string
+	resultPath string
+	metrics    []byte
+	sidecar    []byte
+
+	// events is the job history; changed is closed and replaced whenever
+	// an event is added, waking the watchers.
+	events  []FarmEvent
+	changed chan struct{}
+}
+
+func NewFarmCoordinator(cache *ArtifactCache, lease time.Duration, maxAttempts int) *FarmCoordinator {
+	return &FarmCoordinator{
+		jobs:        make(map[string]*farmJob),
+		queued:      make(chan struct{}),
+		cache:       cache,
+		lease:       lease,
+		maxAttempts: max(1, maxAttempts),
+	}
+}
+
+// Serve accepts connections on listener until ctx is cancelled.
+func (fc *FarmCoordinator) Serve(ctx context.Context, listener net.Listener) error {
+	server := grpc.NewServer(grpc.ForceServerCodec(farmCodec{}))
+	server.RegisterService(&farmServiceDesc, fc)
+
+	go fc.expireLeases(ctx)
+	go func() {
+		<-ctx.Done()
+		server.GracefulStop()
+	}()
+
+	GetDebugSystem().logger.Info("farm coordinator listening", "address", listener.Addr().String(), "spool", fc.cache.Dir())
+	return server.Serve(listener)
+}
+
+// addEvent records an event of job; callers hold fc.mu.
+func (fc *FarmCoordinator) addEvent(job *farmJob, state, worker, message string) {
+	event := FarmEvent{
+		JobID:   job.id,
+		State:   state,
+		Worker:  worker,
+		Message: message,
+		Attempt: job.attempts,
+		Time:    time.Now().UTC(),
+	}
+	job.events = append(job.events, event)
+	close(job.changed)
+	job.changed = make(chan struct{})
+
+	GetDebugSystem().logger.Info("farm job event",
+		"job_id", job.id,
+		"name", job.name,
+		"state", state,
+		"worker", worker,
+		"message", message)
+}
+
+// finish records the final state of job and drops its source, leaving the
+// result to the cache's eviction; callers hold fc.mu.
+func (fc *FarmCoordinator) finish(job *farmJob, state, worker, message string) {
+	job.state = state
+	job.finishedAt = time.Now()
+	fc.addEvent(job, state, worker, message)
+
+	go func() {
+		os.Remove(job.sourcePath)
+		fc.cache.Release(job.entry)
+	}()
+}
+
+// enqueue puts job at the back of the queue; callers hold fc.mu.
+func (fc *FarmCoordinator) enqueue(job *farmJob) {
+	job.state = FarmJobQueued
+	job.worker = ""
+	fc.queue = append(fc.queue, job)
+	close(fc.queued)
+	fc.queued = make(chan struct{})
+}
+
+func (fc *FarmCoordinator) job(id string) (*farmJob, error) {
+	job, ok := fc.jobs[id]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "unknown job %q", id)
+	}
+	return job, nil
+}
+
+func (fc *FarmCoordinator) Submit(stream grpc.ServerStream) error {
+	chunk := &FarmChunk{}
+	if err := stream.RecvMsg(chunk); err != nil {
+		return err
+	}
+	if chunk.Header == nil || chunk.Header.Params == nil {
+		return status.Error(codes.InvalidArgument, "the first message must carry the job name and parameters")
+	}
+	if fieldErrors := validateParameterFields(chunk.Header.Params); len(fieldErrors) > 0 {
+		return status.Error(codes.InvalidArgument, fieldErrors[0].Error())
+	}
+
+	id, err := newFarmJobID()
+	if err != nil {
+		return err
+	}
+	entry, err := fc.cache.NewEntry("farm-" + id)
+	if err != nil {
+		return status.Errorf(codes.Internal, "spool: %v", err)
+	}
+	job := &farmJob{
+		id:         id,
+		name:       filepath.Base(chunk.Header.Name),
+		params:     chunk.Header.Params,
+		entry:      entry,
+		sourcePath: filepath.Join(entry, "source"+filepath.Ext(chunk.Header.Name)),
+		resultPath: filepath.Join(entry, "result.png"),
+		changed:    make(chan struct{}),
+	}
+
+	if err := receiveToFile(job.sourcePath, chunk.Data, func() ([]byte, error) {
+		next := &FarmChunk{}
+		err := stream.RecvMsg(next)
+		return next.Data, err
+	}); err != nil {
+		fc.cache.Remove(entry)
+		return err
+	}
+
+	fc.mu.Lock()
+	fc.jobs[id] = job
+	fc.addEvent(job, FarmJobQueued, "", job.name)
+	fc.enqueue(job)
+	fc.mu.Unlock()
+
+	return stream.SendMsg(&FarmJobRef{JobID: id})
+}
+
+// Watch sends the history of a job and then its events as they happen,
+// until the job finishes or the client goes away.
+func (fc *FarmCoordinator) Watch(ref *FarmJobRef, stream grpc.ServerStream) error {
+	sent := 0
+	for {
+		fc.mu.Lock()
+		job, err := fc.job(ref.JobID)
+		if err != nil {
+			fc.mu.Unlock()
+			return err
+		}
+		pending := append([]FarmEvent(nil), job.events[sent:]...)
+		changed := job.changed
+		fc.mu.Unlock()
+
+		for _, event := range pending {
+			if err := stream.SendMsg(&event); err != nil {
+				return err
+			}
+			sent++
+			if event.terminal() {
+				return nil
+			}
+		}
+
+		select {
+		case <-changed:
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		}
+	}
+}
+
+func (fc *FarmCoordinator) Download(ref *FarmJobRef, stream grpc.ServerStream) error {
+	fc.mu.Lock()
+	job, err := fc.job(ref.JobID)
+	if err != nil {
+		fc.mu.Unlock()
+		return err
+	}
+	state, resultPath, metrics, sidecar := job.state, job.resultPath, job.metrics, job.sidecar
+	fc.mu.Unlock()
+
+	if state != FarmJobDone {
+		return status.Errorf(codes.FailedPrecondition, "job %s is %s", ref.JobID, state)
+	}
+
+	data, err := os.ReadFile(resultPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return status.Errorf(codes.NotFound, "the result of job %s was evicted from the cache", ref.JobID)
+	}
+	if err != nil {
+		return status.Errorf(codes.Internal, "read result: %v", err)
+	}
+	fc.cache.Touch(job.entry)
+
+	return sendChunked(data,
+		func(part []byte) any {
+			return &FarmResultChunk{JobID: ref.JobID, Metrics: metrics, Sidecar: sidecar, Data: part}
+		},
+		func(part []byte) any { return &FarmResultChunk{Data: part} },
+		stream.SendMsg)
+}
+
+// PullJob blocks until a job is queued, leases it to the worker and streams
+// its source image.
+func (fc *FarmCoordinator) PullJob(request *FarmPullRequest, stream grpc.ServerStream) error {
+	ctx := stream.Context()
+
+	var job *farmJob
+	for job == nil {
+		fc.mu.Lock()
+		if len(fc.queue) > 0 {
+			job = fc.queue[0]
+			fc.queue = fc.queue[1:]
+			job.state = FarmJobRunning
+			job.worker = request.Worker
+			job.attempts++
+			job.leaseUntil = time.Now().Add(fc.lease)
+			fc.addEvent(job, FarmJobRunning, request.Worker, "assigned")
+		}
+		queued := fc.queued
+		fc.mu.Unlock()
+
+		if job == nil {
+			select {
+			case <-queued:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+
+	data, err := os.ReadFile(job.sourcePath)
+	if err == nil {
+		err = sendChunked(data,
+			func(part []byte) any {
+				header := &FarmJobHeader{JobID: job.id, Name: job.name, Params: job.params, Attempt: job.attempts}
+				return &FarmChunk{Header: header, Data: part}
+			},
+			func(part []byte) any { return &FarmChunk{Data: part} },
+			stream.SendMsg)
+	}
+	if err != nil {
+		fc.mu.Lock()
+		if job.state == FarmJobRunning && job.worker == request.Worker {
+			fc.addEvent(job, FarmJobRequeued, request.Worker, "could not send the source: "+err.Error())
+			fc.enqueue(job)
+		}
+		fc.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// Heartbeat extends the lease of a running job and records the worker's
+// progress note. A worker whose lease was taken away gets an error and
+// should drop the job.
+func (fc *FarmCoordinator) Heartbeat(ctx context.Context, event *FarmEvent) (*FarmAck, error) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	job, err := fc.leasedJob(event.JobID, event.Worker, event.Attempt)
+	if err != nil {
+		return nil, err
+	}
+
+	job.leaseUntil = time.Now().Add(fc.lease)
+	if event.Message != "" {
+		fc.addEvent(job, FarmJobRunning, event.Worker, event.Message)
+	}
+	return &FarmAck{}, nil
+}
+
+// leasedJob returns job id when worker holds its lease for attempt. The
+// attempt tells a worker's current lease from one it lost and was granted
+// again; callers hold fc.mu.
+func (fc *FarmCoordinator) leasedJob(id, worker string, attempt int) (*farmJob, error) {
+	job, err := fc.job(id)
+	if err != nil {
+		return nil, err
+	}
+	if job.state != FarmJobRunning || job.worker != worker || job.attempts != attempt {
+		return nil, status.Errorf(codes.FailedPrecondition, "job %s is no longer leased to %s", id, worker)
+	}
+	return job, nil
+}
+
+// Complete receives the result, or the error, of a job from its worker.
+// The result is spooled to a file of its own attempt and only moved into
+// place if the worker still holds the lease once the upload ends, so a
+// worker that lost the job cannot overwrite the result of the next one.
+func (fc *FarmCoordinator) Complete(stream grpc.ServerStream) error {
+	first := &FarmResultChunk{}
+	if err := stream.RecvMsg(first); err != nil {
+		return err
+	}
+
+	fc.mu.Lock()
+	job, err := fc.leasedJob(first.JobID, first.Worker, first.Attempt)
+	fc.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	uploadPath := filepath.Join(job.entry, fmt.Sprintf("result-%d.png", first.Attempt))
+	if first.Error == "" {
+		if err := receiveToFile(uploadPath, first.Data, func() ([]byte, error) {
+			next := &FarmResultChunk{}
+			err := stream.RecvMsg(next)
+			return next.Data, err
+		}); err != nil {
+			os.Remove(uploadPath)
+			return err
+		}
+	}
+
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	if _, err := fc.leasedJob(first.JobID, first.Worker, first.Attempt); err != nil {
+		os.Remove(uploadPath)
+		return err
+	}
+	if first.Error != "" {
+		fc.finish(job, FarmJobFailed, first.Worker, first.Error)
+	} else {
+		if err := os.Rename(uploadPath, job.resultPath); err != nil {
+			os.Remove(uploadPath)
+			return status.Errorf(codes.Internal, "spool: %v", err)
+		}
+		job.metrics = first.Metrics
+		job.sidecar = first.Sidecar
+		fc.finish(job, FarmJobDone, first.Worker, "")
+	}
+	return stream.SendMsg(&FarmAck{})
+}
+
+// expireLeases requeues running jobs whose worker stopped sending
+// heartbeats, failing them after maxAttempts, and forgets jobs that
+// finished more than farmJobRetention ago.
+func (fc *FarmCoordinator) expireLeases(ctx context.Context) {
+	interval := fc.lease / 4
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			var forgotten []string
+			fc.mu.Lock()
+			for id, job := range fc.jobs {
+				if !job.finishedAt.IsZero() && now.Sub(job.finishedAt) >= farmJobRetention {
+					delete(fc.jobs, id)
+					forgotten = append(forgotten, job.entry)
+					continue
+				}
+				if job.state != FarmJobRunning || now.Before(job.leaseUntil) {
+					continue
+				}
+
+				message := fmt.Sprintf("no heartbeat from %s for %v", job.worker, fc.lease)
+				if job.attempts >= fc.maxAttempts {
+					fc.finish(job, FarmJobFailed, job.worker, message)
+					continue
+				}
+				fc.addEvent(job, FarmJobRequeued, job.worker, message)
+				fc.enqueue(job)
+			}
+			fc.mu.Unlock()
+
+			for _, entry := range forgotten {
+				fc.cache.Remove(entry)
+			}
+		}
+	}
+}
+
+// Close removes the spooled sources and results, which cannot be fetched
+// once the coordinator forgets its jobs.
+func (fc *FarmCoordinator) Close() {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	for _, job := range fc.jobs {
+		fc.cache.Remove(job.entry)
+	}
+}
+
+// receiveToFile writes first and then every chunk next returns to path,
+// until the client closes its side of the stream.
+func receiveToFile(path string, first []byte, next func() ([]byte, error)) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return status.Errorf(codes.Internal, "spool: %v", err)
+	}
+
+	data := first
+	for {
+		if _, err := file.Write(data); err != nil {
+			file.Close()
+			return status.Errorf(codes.Internal, "spool: %v", err)
+		}
+
+		data, err = next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			file.Close()
+			return err
+		}
+	}
+	return file.Close()
+}
+
+func newFarmJobID() (string, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("generate job id: %w", err)
+	}
+	return hex.EncodeToString(id), nil
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// The processing farm speaks gRPC with gob-encoded messages, so the service
+// is declared here by hand instead of being generated from a .proto file.
+// Coordinator and workers must therefore run the same build.
+const farmServiceName = "otsu.Farm"
+
+// farmChunkSize bounds the image bytes carried by one streamed message,
+// well under the default 4 MB gRPC message limit.
+const farmChunkSize = 1 << 20
+
+// Farm job states reported in FarmEvent.State.
+const (
+	FarmJobQueued   = "queued"
+	FarmJobRunning  = "running"
+	FarmJobDone     = "done"
+	FarmJobFailed   = "failed"
+	FarmJobRequeued = "requeued"
+)
+
+// FarmJobHeader opens a job upload or download. A pulled job carries the
+// attempt it was leased for, which the worker sends back with its
+// heartbeats and result.
+type FarmJobHeader struct {
+	JobID   string
+	Name    string
+	Params  *OtsuParameters
+	Attempt int
+}
+
+// FarmChunk carries a source image: the first message holds the header,
+// every message may hold image bytes.
+type FarmChunk struct {
+	Header *FarmJobHeader
+	Data   []byte
+}
+
+type FarmJobRef struct {
+	JobID string
+}
+
+type FarmPullRequest struct {
+	Worker string
+}
+
+// FarmEvent is one state change or progress note of a job.
+type FarmEvent struct {
+	JobID   string
+	State   string
+	Worker  string
+	Message string
+	Attempt int
+	Time    time.Time
+}
+
+// FarmResultChunk carries a result: the first message holds the job,
+// metrics and sidecar, or the error of a failed job; every message may hold
+// bytes of the result PNG.
+type FarmResultChunk struct {
+	JobID   string
+	Worker  string
+	Attempt int
+	Error   string
+	Metrics []byte
+	Sidecar []byte
+	Data    []byte
+}
+
+type FarmAck struct{}
+
+func (fe FarmEvent) terminal() bool {
+	return fe.State == FarmJobDone || fe.State == FarmJobFailed
+}
+
+func (fe FarmEvent) String() string {
+	text := fmt.Sprintf("job %s %s", fe.JobID, fe.State)
+	if fe.Worker != "" {
+		text += " on " + fe.Worker
+	}
+	if fe.Message != "" {
+		text += ": " + fe.Message
+	}
+	return text
+}
+
+// farmCodec encodes messages with encoding/gob, which keeps image bytes
+// binary unlike JSON.
+type farmCodec struct{}
+
+func (farmCodec) Marshal(v any) ([]byte, error) {
+	var buffer bytes.Buffer
+	if err := gob.NewEncoder(&buffer).Encode(v); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+func (farmCodec) Unmarshal(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+func (farmCodec) Name() string {
+	return "gob"
+}
+
+// farmService is implemented by FarmCoordinator.
+type farmService interface {
+	Submit(stream grpc.ServerStream) error
+	Watch(ref *FarmJobRef, stream grpc.ServerStream) error
+	Download(ref *FarmJobRef, stream grpc.ServerStream) error
+	PullJob(request *FarmPullRequest, stream grpc.ServerStream) error
+	Heartbeat(ctx context.Context, event *FarmEvent) (*FarmAck, error)
+	Complete(stream grpc.ServerStream) error
+}
+
+var farmServiceDesc = grpc.ServiceDesc{
+	ServiceName: farmServiceName,
+	HandlerType: (*farmService)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "Heartbeat", Handler: farmHeartbeatHandler},
+	},
+	Streams: []grpc.StreamDesc{
+		{StreamName: "Submit", ClientStreams: true, Handler: func(srv any, stream grpc.ServerStream) error {
+			return srv.(farmService).Submit(stream)
+		}},
+		{StreamName: "Watch", ServerStreams: true, Handler: func(srv any, stream grpc.ServerStream) error {
+			ref := &FarmJobRef{}
+			if err := stream.RecvMsg(ref); err != nil {
+				return err
+			}
+			return srv.(farmService).Watch(ref, stream)
+		}},
+		{StreamName: "Download", ServerStreams: true, Handler: func(srv any, stream grpc.ServerStream) error {
+			ref := &FarmJobRef{}
+			if err := stream.RecvMsg(ref); err != nil {
+				return err
+			}
+			return srv.(farmService).Download(ref, stream)
+		}},
+		{StreamName: "PullJob", ServerStreams: true, Handler: func(srv any, stream grpc.ServerStream) error {
+			request := &FarmPullRequest{}
+			if err := stream.RecvMsg(request); err != nil {
+				return err
+			}
+			return srv.(farmService).PullJob(request, stream)
+		}},
+		{StreamName: "Complete", ClientStreams: true, Handler: func(srv any, stream grpc.ServerStream) error {
+			return srv.(farmService).Complete(stream)
+		}},
+	},
+}
+
+func farmHeartbeatHandler(srv any, ctx context.Context, decode func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+	event := &FarmEvent{}
+	if err := decode(event); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(farmService).Heartbeat(ctx, event)
+	}
+
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: farmMethod("Heartbeat")}
+	return interceptor(ctx, event, info, func(ctx context.Context, request any) (any, error) {
+		return srv.(farmService).Heartbeat(ctx, request.(*FarmEvent))
+	})
+}
+
+func farmMethod(name string) string {
+	return "/" + farmServiceName + "/" + name
+}
+
+func farmStreamDesc(name string) *grpc.StreamDesc {
+	for i := range farmServiceDesc.Streams {
+		if farmServiceDesc.Streams[i].StreamName == name {
+			return &farmServiceDesc.Streams[i]
+		}
+	}
+	panic("unknown farm stream " + name)
+}
+
+// openFarmStream starts a call of the named stream and, for server streams,
+// sends its single request.
+func openFarmStream(ctx context.Context, conn *grpc.ClientConn, name string, request any) (grpc.ClientStream, error) {
+	desc := farmStreamDesc(name)
+	stream, err := conn.NewStream(ctx, desc, farmMethod(name))
+	if err != nil {
+		return nil, err
+	}
+
+	if !desc.ClientStreams {
+		if err := stream.SendMsg(request); err != nil {
+			return nil, err
+		}
+		if err := stream.CloseSend(); err != nil {
+			return nil, err
+		}
+	}
+	return stream, nil
+}
+
+// dialFarm connects to a coordinator. Connections are unencrypted; run the
+// farm on a trusted network.
+func dialFarm(address string) (*grpc.ClientConn, error) {
+	conn, err := grpc.NewClient(address,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(farmCodec{})))
+	if err != nil {
+		return nil, fmt.Errorf("connect to coordinator %s: %w", address, err)
+	}
+	return conn, nil
+}
+
+// sendChunked streams data in farmChunkSize pieces, the first of them
+// built by first and the rest by next.
+func sendChunked(data []byte, first func([]byte) any, next func([]byte) any, send func(any) error) error {
+	end := min(len(data), farmChunkSize)
+	if err := send(first(data[:end])); err != nil {
+		return err
+	}
+	for start := end; start < len(data); start += farmChunkSize {
+		end := min(len(data), start+farmChunkSize)
+		if err := send(next(data[start:end])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// farmRetryDelay is the pause before a worker reconnects after losing the
+// coordinator.
+const farmRetryDelay = 5 * time.Second
+
+// FarmWorker pulls jobs from a coordinator one at a time and processes them
+// with the local engine.
+type FarmWorker struct {
+	conn      *grpc.ClientConn
+	name      string
+	config    ProcessingConfig
+	workDir   string
+	heartbeat time.Duration
+}
+
+func NewFarmWorker(conn *grpc.ClientConn, name string, config ProcessingConfig, heartbeat time.Duration) (*FarmWorker, error) {
+	workDir, err := GetArtifactCache().NewEntry("worker")
+	if err != nil {
+		return nil, err
+	}
+
+	return &FarmWorker{
+		conn:      conn,
+		name:      name,
+		config:    config,
+		workDir:   workDir,
+		heartbeat: heartbeat,
+	}, nil
+}
+
+// Run processes jobs until ctx is cancelled. Connection failures are
+// retried; job failures are reported to the coordinator.
+func (fw *FarmWorker) Run(ctx context.Context) error {
+	logger := GetDebugSystem().logger
+
+	for ctx.Err() == nil {
+		if err := fw.runNext(ctx); err != nil && ctx.Err() == nil {
+			logger.Warn("farm worker error", "worker", fw.name, "error", err.Error())
+			select {
+			case <-time.After(farmRetryDelay):
+			case <-ctx.Done():
+			}
+		}
+	}
+	return nil
+}
+
+func (fw *FarmWorker) Close() {
+	GetArtifactCache().Remove(fw.workDir)
+}
+
+func (fw *FarmWorker) runNext(ctx context.Context) error {
+	header, sourcePath, err := fw.pull(ctx)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(sourcePath)
+
+	jobCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go fw.keepLease(jobCtx, cancel, header)
+
+	fw.note(ctx, header, "processing")
+
+	outputPath := filepath.Join(fw.workDir, header.JobID+"-result.png")
+	metricsPath := filepath.Join(fw.workDir, header.JobID+"-metrics.json")
+	defer os.Remove(outputPath)
+	defer os.Remove(SidecarPath(outputPath))
+	defer os.Remove(metricsPath)
+
+	config := &HeadlessConfig{
+		ProcessingConfig: fw.config,
+		Input:            sourcePath,
+		Output:           outputPath,
+		MetricsOutput:    metricsPath,
+	}
+	config.Params = header.Params
+
+	startTime := time.Now()
+	processErr := processHeadless(jobCtx, config)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if processErr != nil && jobCtx.Err() != nil {
+		// The coordinator took the lease away; the job runs elsewhere.
+		return fmt.Errorf("job %s: %w", header.JobID, processErr)
+	}
+
+	GetDebugSystem().logger.Info("farm job processed",
+		"job_id", header.JobID,
+		"name", header.Name,
+		"duration_ms", time.Since(startTime).Milliseconds(),
+		"success", processErr == nil)
+
+	fw.note(ctx, header, "uploading")
+	return fw.complete(ctx, header, outputPath, metricsPath, processErr)
+}
+
+// pull waits for a job and writes its source image to the work directory.
+func (fw *FarmWorker) pull(ctx context.Context) (*FarmJobHeader, string, error) {
+	stream, err := openFarmStream(ctx, fw.conn, "PullJob", &FarmPullRequest{Worker: fw.name})
+	if err != nil {
+		return nil, "", err
+	}
+
+	first := &FarmChunk{}
+	if err := stream.RecvMsg(first); err != nil {
+		return nil, "", err
+	}
+	if first.Header == nil {
+		return nil, "", fmt.Errorf("coordinator sent a job without a header")
+	}
+
+	header := first.Header
+	sourcePath := filepath.Join(fw.workDir, header.JobID+"-source"+filepath.Ext(header.Name))
+	if err := receiveToFile(sourcePath, first.Data, func() ([]byte, error) {
+		next := &FarmChunk{}
+		err := stream.RecvMsg(next)
+		return next.Data, err
+	}); err != nil {
+		os.Remove(sourcePath)
+		return nil, "", err
+	}
+	return header, sourcePath, nil
+}
+
+// keepLease sends heartbeats while a job runs and cancels it when the
+// coordinator no longer recognizes the lease.
+func (fw *FarmWorker) keepLease(ctx context.Context, cancel context.CancelFunc, header *FarmJobHeader) {
+	ticker := time.NewTicker(fw.heartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			event := &FarmEvent{JobID: header.JobID, Worker: fw.name, Attempt: header.Attempt}
+			if err := fw.conn.Invoke(ctx, farmMethod("Heartbeat"), event, &FarmAck{}); err != nil {
+				GetDebugSystem().logger.Warn("farm heartbeat failed", "job_id", header.JobID, "error", err.Error())
+				if isLeaseLost(err) {
+					cancel()
+					return
+				}
+			}
+		}
+	}
+}
+
+// note reports progress on a job; failures only cost the progress event.
+func (fw *FarmWorker) note(ctx context.Context, header *FarmJobHeader, message string) {
+	event := &FarmEvent{JobID: header.JobID, Worker: fw.name, Message: message, Attempt: header.Attempt}
+	if err := fw.conn.Invoke(ctx, farmMethod("Heartbeat"), event, &FarmAck{}); err != nil {
+		GetDebugSystem().logger.Warn("farm progress note failed", "job_id", header.JobID, "error", err.Error())
+	}
+}
+
+// complete uploads the result of a job, or processErr when it failed.
+func (fw *FarmWorker) complete(ctx context.Context, header *FarmJobHeader, outputPath, metricsPath string, processErr error) error {
+	stream, err := openFarmStream(ctx, fw.conn, "Complete", nil)
+	if err != nil {
+		return err
+	}
+
+	first := &FarmResultChunk{JobID: header.JobID, Worker: fw.name, Attempt: header.Attempt}
+	var data []byte
+	if processErr != nil {
+		first.Error = processErr.Error()
+	} else {
+		if data, err = os.ReadFile(outputPath); err != nil {
+			return fmt.Errorf("read result: %w", err)
+		}
+		if first.Metrics, err = os.ReadFile(metricsPath); err != nil {
+			return fmt.Errorf("read metrics: %w", err)
+		}
+		first.Sidecar, err = os.ReadFile(SidecarPath(outputPath))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("read sidecar: %w", err)
+		}
+	}
+
+	err = sendChunked(data,
+		func(part []byte) any {
+			first.Data = part
+			return first
+		},
+		func(part []byte) any { return &FarmResultChunk{Data: part} },
+		stream.SendMsg)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	if err := stream.CloseSend(); err != nil {
+		return err
+	}
+	return stream.RecvMsg(&FarmAck{})
+}
+
+// isLeaseLost reports whether the coordinator refused a heartbeat because
+// the job was requeued or forgotten.
+func isLeaseLost(err error) bool {
+	switch status.Code(err) {
+	case codes.FailedPrecondition, codes.NotFound:
+		return true
+	}
+	return false
+}
@@ -5,6 +5,9 @@ go 1.24
 require (
 	fyne.io/fyne/v2 v2.6.1
 	gocv.io/x/gocv v0.41.0
+	golang.org/x/image v0.24.0
+	golang.org/x/sys v0.30.0
+	google.golang.org/grpc v1.71.0
 )
 
 require (
@@ -35,13 +38,13 @@ require (
 	github.com/srwiley/rasterx v0.0.0-20220730225603-2ab79fcdd4ef // indirect
 	github.com/stretchr/testify v1.10.0 // indirect
 	github.com/yuin/goldmark v1.7.8 // indirect
-	golang.org/x/image v0.24.0 // indirect
 	golang.org/x/net v0.35.0 // indirect
-	golang.org/x/sys v0.30.0 // indirect
 	golang.org/x/text v0.22.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20250115164207-1a7da9e5054f // indirect
+	google.golang.org/protobuf v1.36.4 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
 
 // Dependencies for quality checking tools - installed via quality check runtime
-// go install honnef.co/go/tools/cmd/staticcheck@latest  
-// go install golang.org/x/vuln/cmd/govulncheck@latest
\ No newline at end of file
+// go install honnef.co/go/tools/cmd/staticcheck@latest
+// go install golang.org/x/vuln/cmd/govulncheck@latest
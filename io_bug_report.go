@@ -0,0 +1,145 @@
+package main
+
+import (
+	"archive/zip"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+// bugReportLogLines is how many of the latest log records a bug report
+// keeps.
+const bugReportLogLines = 200
+
+// PanicError is the error of a run that panicked, with the stack of the
+// goroutine where it was recovered.
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("operation panicked: %v", e.Value)
+}
+
+// BugReport gathers what a maintainer needs to reproduce a processing
+// error: the environment, the image dimensions, the parameters, the stack of
+// a panic and the latest log records. It leaves out the image and its path.
+type BugReport struct {
+	CreatedAt  time.Time
+	AppVersion string
+	GoVersion  string
+	Platform   string
+	CPUs       int
+	OpenCV     string
+	Image      string
+	Error      string
+	Stack      string
+	Parameters string
+	Log        []string
+}
+
+// NewBugReport describes err, raised while processing source with params.
+// source and params may be nil.
+func NewBugReport(err error, source *ImageData, params *OtsuParameters) *BugReport {
+	report := &BugReport{
+		CreatedAt:  time.Now().UTC(),
+		AppVersion: AppVersion,
+		GoVersion:  runtime.Version(),
+		Platform:   runtime.GOOS + "/" + runtime.GOARCH,
+		CPUs:       runtime.NumCPU(),
+		OpenCV:     gocv.OpenCVVersion(),
+		Image:      "none loaded",
+	}
+	if err != nil {
+		report.Error = err.Error()
+		if panicked, ok := err.(*PanicError); ok {
+			report.Stack = string(panicked.Stack)
+		}
+	}
+	if source != nil {
+		report.Image = fmt.Sprintf("%dx%d, %d channels, %s", source.Width, source.Height, source.Channels, source.Format)
+		if source.ScaleFactor > 0 && source.ScaleFactor != 1 {
+			report.Image += fmt.Sprintf(", downscaled by %.3f from %dx%d", source.ScaleFactor, source.OriginalWidth, source.OriginalHeight)
+		}
+	}
+	if params != nil {
+		if text, err := EncodeParametersJSON(params); err == nil {
+			report.Parameters = text
+		}
+	}
+
+	entries, _ := GetLogBuffer().Entries()
+	for _, entry := range entries[max(0, len(entries)-bugReportLogLines):] {
+		report.Log = append(report.Log, entry.Text())
+	}
+	return report
+}
+
+// Text is the report as Markdown, ready to paste into an issue.
+func (br *BugReport) Text() string {
+	var text strings.Builder
+	text.WriteString("## Processing error\n\n")
+	fmt.Fprintf(&text, "```\n%s\n```\n\n", br.Error)
+	text.WriteString("## Steps to reproduce\n\n1. \n\n")
+	text.WriteString("## Environment\n\n")
+	fmt.Fprintf(&text, "- Otsu Obliterator %s\n", br.AppVersion)
+	fmt.Fprintf(&text, "- %s, %d CPUs\n", br.Platform, br.CPUs)
+	fmt.Fprintf(&text, "- Go %s, OpenCV %s\n", strings.TrimPrefix(br.GoVersion, "go"), br.OpenCV)
+	fmt.Fprintf(&text, "- Image: %s\n", br.Image)
+	fmt.Fprintf(&text, "- Reported: %s\n\n", br.CreatedAt.Format(time.RFC3339))
+	if br.Parameters != "" {
+		fmt.Fprintf(&text, "## Parameters\n\n```json\n%s\n```\n\n", strings.TrimSpace(br.Parameters))
+	}
+	if br.Stack != "" {
+		fmt.Fprintf(&text, "## Stack\n\n```\n%s\n```\n\n", strings.TrimSpace(br.Stack))
+	}
+	if len(br.Log) > 0 {
+		fmt.Fprintf(&text, "<details><summary>Last %d log records</summary>\n\n```\n%s\n```\n\n</details>\n", len(br.Log), strings.Join(br.Log, "\n"))
+	}
+	return text.String()
+}
+
+// WriteBundle stores the report as a zip at path: report.md as Text gives
+// it, parameters.json for -params, and the log and stack in full.
+func (br *BugReport) WriteBundle(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("create bug report bundle: %w", err)
+	}
+
+	archive := zip.NewWriter(file)
+	files := []struct{ name, content string }{
+		{"report.md", br.Text()},
+		{"parameters.json", br.Parameters},
+		{"log.txt", strings.Join(br.Log, "\n")},
+		{"stack.txt", br.Stack},
+	}
+	for _, f := range files {
+		if f.content == "" {
+			continue
+		}
+		writer, err := archive.CreateHeader(&zip.FileHeader{Name: f.name, Method: zip.Deflate, Modified: br.CreatedAt})
+		if err == nil {
+			_, err = writer.Write([]byte(f.content))
+		}
+		if err != nil {
+			archive.Close()
+			file.Close()
+			return fmt.Errorf("write bug report bundle: %w", err)
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		file.Close()
+		return fmt.Errorf("write bug report bundle: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("write bug report bundle: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultCacheMaxMB = 2048
+
+	// Entries changed this recently are not evicted, since another process
+	// may still be working in them. Clearing by hand waits less.
+	cacheMinIdle      = 15 * time.Minute
+	cacheClearMinIdle = time.Minute
+)
+
+// ArtifactCache manages the directory that temporary intermediates and
+// kept artifacts live in. Each entry is a directory. Entries in use by this
+// process are pinned; the rest are evicted least recently used first once
+// the cache grows over its size cap.
+type ArtifactCache struct {
+	mu       sync.Mutex
+	dir      string
+	maxBytes int64
+	pinned   map[string]bool
+}
+
+// CacheUsage describes the entries of the cache.
+type CacheUsage struct {
+	Bytes   int64
+	Entries int
+	InUse   int
+}
+
+var (
+	artifactCache     *ArtifactCache
+	artifactCacheOnce sync.Once
+)
+
+// GetArtifactCache returns the process-wide cache under DefaultCacheDir.
+// It falls back to the system temporary directory when the user cache
+// directory cannot be created.
+func GetArtifactCache() *ArtifactCache {
+	artifactCacheOnce.Do(func() {
+		cache, err := OpenArtifactCache(DefaultCacheDir(), defaultCacheMaxBytes())
+		if err != nil {
+			GetDebugSystem().logger.Warn("cache directory unavailable, using the temporary directory",
+				"dir", DefaultCacheDir(), "error", err.Error())
+			fallback := filepath.Join(os.TempDir(), fmt.Sprintf("otsu-obliterator-cache-%d", os.Getuid()))
+			cache = &ArtifactCache{dir: fallback, maxBytes: defaultCacheMaxBytes(), pinned: make(map[string]bool)}
+		}
+		artifactCache = cache
+	})
+	return artifactCache
+}
+
+// DefaultCacheDir is $OTSU_CACHE_DIR, or otsu-obliterator in the user cache
+// directory.
+func DefaultCacheDir() string {
+	if dir := os.Getenv(envCacheDir); dir != "" {
+		return dir
+	}
+	if dir, err := os.UserCacheDir(); err == nil {
+		return filepath.Join(dir, "otsu-obliterator")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("otsu-obliterator-cache-%d", os.Getuid()))
+}
+
+func defaultCacheMaxBytes() int64 {
+	megabytes, err := strconv.ParseInt(os.Getenv(envCacheMaxMB), 10, 64)
+	if err != nil || megabytes <= 0 {
+		megabytes = DefaultCacheMaxMB
+	}
+	return megabytes << 20
+}
+
+func OpenArtifactCache(dir string, maxBytes int64) (*ArtifactCache, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("create cache directory: %w", err)
+	}
+
+	cache := &ArtifactCache{dir: dir, maxBytes: maxBytes, pinned: make(map[string]bool)}
+	if reclaimed, err := cache.Trim(); err != nil {
+		GetDebugSystem().logger.Warn("cache trim failed", "dir", dir, "error", err.Error())
+	} else if reclaimed > 0 {
+		GetDebugSystem().logger.Info("cache trimmed", "dir", dir, "reclaimed_bytes", reclaimed)
+	}
+	return cache, nil
+}
+
+func (ac *ArtifactCache) Dir() string {
+	return ac.dir
+}
+
+func (ac *ArtifactCache) MaxBytes() int64 {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	return ac.maxBytes
+}
+
+// SetMaxBytes changes the size cap and evicts down to it.
+func (ac *ArtifactCache) SetMaxBytes(maxBytes int64) (int64, error) {
+	ac.mu.Lock()
+	ac.maxBytes = maxBytes
+	ac.mu.Unlock()
+	return ac.Trim()
+}
+
+// NewEntry creates a pinned entry directory whose name starts with prefix.
+func (ac *ArtifactCache) NewEntry(prefix string) (string, error) {
+	if err := os.MkdirAll(ac.dir, 0700); err != nil {
+		return "", fmt.Errorf("create cache directory: %w", err)
+	}
+	path, err := os.MkdirTemp(ac.dir, prefix+"-")
+	if err != nil {
+		return "", fmt.Errorf("create cache entry: %w", err)
+	}
+
+	ac.mu.Lock()
+	ac.pinned[path] = true
+	ac.mu.Unlock()
+	return path, nil
+}
+
+// Release unpins an entry that is worth keeping, marks it as just used and
+// trims the cache.
+func (ac *ArtifactCache) Release(path string) {
+	ac.mu.Lock()
+	delete(ac.pinned, path)
+	ac.mu.Unlock()
+
+	ac.Touch(path)
+	if _, err := ac.Trim(); err != nil {
+		GetDebugSystem().logger.Warn("cache trim failed", "dir", ac.dir, "error", err.Error())
+	}
+}
+
+// Remove unpins and deletes an entry that is no longer needed.
+func (ac *ArtifactCache) Remove(path string) {
+	ac.mu.Lock()
+	delete(ac.pinned, path)
+	ac.mu.Unlock()
+
+	if err := os.RemoveAll(path); err != nil {
+		GetDebugSystem().logger.Warn("cache entry removal failed", "path", path, "error", err.Error())
+	}
+}
+
+// Touch marks an entry as used now for LRU ordering.
+func (ac *ArtifactCache) Touch(path string) {
+	now := time.Now()
+	os.Chtimes(path, now, now)
+}
+
+// Trim evicts unpinned idle entries, least recently used first, until the
+// cache fits its cap, and returns the bytes reclaimed.
+func (ac *ArtifactCache) Trim() (int64, error) {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+
+	entries, err := ac.scan()
+	if err != nil {
+		return 0, err
+	}
+
+	var total int64
+	for _, entry := range entries {
+		total += entry.size
+	}
+
+	var reclaimed int64
+	for _, entry := range entries {
+		if total <= ac.maxBytes {
+			break
+		}
+		if !ac.evictable(entry, cacheMinIdle) {
+			continue
+		}
+		if err := os.RemoveAll(entry.path); err != nil {
+			return reclaimed, fmt.Errorf("evict %s: %w", entry.path, err)
+		}
+		total -= entry.size
+		reclaimed += entry.size
+	}
+	return reclaimed, nil
+}
+
+// Clear removes every entry not in use and returns the bytes reclaimed.
+func (ac *ArtifactCache) Clear() (int64, error) {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+
+	entries, err := ac.scan()
+	if err != nil {
+		return 0, err
+	}
+
+	var reclaimed int64
+	for _, entry := range entries {
+		if !ac.evictable(entry, cacheClearMinIdle) {
+			continue
+		}
+		if err := os.RemoveAll(entry.path); err != nil {
+			return reclaimed, fmt.Errorf("remove %s: %w", entry.path, err)
+		}
+		reclaimed += entry.size
+	}
+
+	GetDebugSystem().logger.Info("cache cleared", "dir", ac.dir, "reclaimed_bytes", reclaimed)
+	return reclaimed, nil
+}
+
+func (ac *ArtifactCache) Usage() (CacheUsage, error) {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+
+	entries, err := ac.scan()
+	if err != nil {
+		return CacheUsage{}, err
+	}
+
+	usage := CacheUsage{Entries: len(entries)}
+	for _, entry := range entries {
+		usage.Bytes += entry.size
+		if !ac.evictable(entry, cacheClearMinIdle) {
+			usage.InUse++
+		}
+	}
+	return usage, nil
+}
+
+type cacheEntry struct {
+	path     string
+	size     int64
+	lastUsed time.Time
+}
+
+// evictable reports whether entry is not pinned here, is not the model
+// directory and has been idle for minIdle; callers hold ac.mu.
+func (ac *ArtifactCache) evictable(entry cacheEntry, minIdle time.Duration) bool {
+	if filepath.Base(entry.path) == modelCacheEntry {
+		return false
+	}
+	return !ac.pinned[entry.path] && time.Since(entry.lastUsed) >= minIdle
+}
+
+// scan lists the entries, least recently used first. An entry was last
+// used at its own modification time or that of its newest file, whichever
+// is later. Callers hold ac.mu.
+func (ac *ArtifactCache) scan() ([]cacheEntry, error) {
+	items, err := os.ReadDir(ac.dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read cache directory: %w", err)
+	}
+
+	entries := make([]cacheEntry, 0, len(items))
+	for _, item := range items {
+		entry := cacheEntry{path: filepath.Join(ac.dir, item.Name())}
+		filepath.WalkDir(entry.path, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
+			if !d.IsDir() {
+				entry.size += info.Size()
+			}
+			if info.ModTime().After(entry.lastUsed) {
+				entry.lastUsed = info.ModTime()
+			}
+			return nil
+		})
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].lastUsed.Before(entries[j].lastUsed)
+	})
+	return entries, nil
+}
+
+// formatBytes renders a byte count for status messages.
+func formatBytes(bytes int64) string {
+	switch {
+	case bytes >= 1<<30:
+		return fmt.Sprintf("%.1f GB", float64(bytes)/(1<<30))
+	case bytes >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(bytes)/(1<<20))
+	case bytes >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(bytes)/(1<<10))
+	}
+	return fmt.Sprintf("%d bytes", bytes)
+}
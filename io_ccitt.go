@@ -0,0 +1,159 @@
+package main
+
+import "image"
+
+// ITU-T T.6 (Group 4) code words, written as bit strings. Runs of 64 or more
+// pixels are a make-up code for the multiple of 64 followed by the
+// terminating code of the rest; runs above 2560 repeat the 2560 make-up code.
+var (
+	faxWhiteTerminating = [64]string{
+		"00110101", "000111", "0111", "1000", "1011", "1100", "1110", "1111",
+		"10011", "10100", "00111", "01000", "001000", "000011", "110100", "110101",
+		"101010", "101011", "0100111", "0001100", "0001000", "0010111", "0000011", "0000100",
+		"0101000", "0101011", "0010011", "0100100", "0011000", "00000010", "00000011", "00011010",
+		"00011011", "00010010", "00010011", "00010100", "00010101", "00010110", "00010111", "00101000",
+		"00101001", "00101010", "00101011", "00101100", "00101101", "00000100", "00000101", "00001010",
+		"00001011", "01010010", "01010011", "01010100", "01010101", "00100100", "00100101", "01011000",
+		"01011001", "01011010", "01011011", "01001010", "01001011", "00110010", "00110011", "00110100",
+	}
+	faxWhiteMakeup = [27]string{
+		"11011", "10010", "010111", "0110111", "00110110", "00110111", "01100100", "01100101",
+		"01101000", "01100111", "011001100", "011001101", "011010010", "011010011", "011010100", "011010101",
+		"011010110", "011010111", "011011000", "011011001", "011011010", "011011011", "010011000", "010011001",
+		"010011010", "011000", "010011011",
+	}
+	faxBlackTerminating = [64]string{
+		"0000110111", "010", "11", "10", "011", "0011", "0010", "00011",
+		"000101", "000100", "0000100", "0000101", "0000111", "00000100", "00000111", "000011000",
+		"0000010111", "0000011000", "0000001000", "00001100111", "00001101000", "00001101100", "00000110111", "00000101000",
+		"00000010111", "00000011000", "000011001010", "000011001011", "000011001100", "000011001101", "000001101000", "000001101001",
+		"000001101010", "000001101011", "000011010010", "000011010011", "000011010100", "000011010101", "000011010110", "000011010111",
+		"000001101100", "000001101101", "000011011010", "000011011011", "000001010100", "000001010101", "000001010110", "000001010111",
+		"000001100100", "000001100101", "000001010010", "000001010011", "000000100100", "000000110111", "000000111000", "000000100111",
+		"000000101000", "000001011000", "000001011001", "000000101011", "000000101100", "000001011010", "000001100110", "000001100111",
+	}
+	faxBlackMakeup = [27]string{
+		"0000001111", "000011001000", "000011001001", "000001011011", "000000110011", "000000110100", "000000110101", "0000001101100",
+		"0000001101101", "0000001001010", "0000001001011", "0000001001100", "0000001001101", "0000001110010", "0000001110011", "0000001110100",
+		"0000001110101", "0000001110110", "0000001110111", "0000001010010", "0000001010011", "0000001010100", "0000001010101", "0000001011010",
+		"0000001011011", "0000001100100", "0000001100101",
+	}
+	// faxSharedMakeup codes runs of 1792 to 2560 pixels of either color.
+	faxSharedMakeup = [13]string{
+		"00000001000", "00000001100", "00000001101", "000000010010", "000000010011", "000000010100", "000000010101", "000000010110",
+		"000000010111", "000000011100", "000000011101", "000000011110", "000000011111",
+	}
+)
+
+const (
+	faxPass       = "0001"
+	faxHorizontal = "001"
+	// faxEOFB ends a Group 4 page: two EOL codes.
+	faxEOFB = "000000000001000000000001"
+)
+
+// faxVertical holds the vertical mode codes by a1-b1, from -3 to 3.
+var faxVertical = [7]string{"0000010", "000010", "010", "1", "011", "000011", "0000011"}
+
+// faxBitWriter packs code words most significant bit first.
+type faxBitWriter struct {
+	data  []byte
+	nBits uint
+}
+
+func (w *faxBitWriter) write(code string) {
+	for i := 0; i < len(code); i++ {
+		if w.nBits%8 == 0 {
+			w.data = append(w.data, 0)
+		}
+		if code[i] == '1' {
+			w.data[len(w.data)-1] |= 0x80 >> (w.nBits % 8)
+		}
+		w.nBits++
+	}
+}
+
+func (w *faxBitWriter) writeRun(length int, black bool) {
+	terminating, makeup := &faxWhiteTerminating, &faxWhiteMakeup
+	if black {
+		terminating, makeup = &faxBlackTerminating, &faxBlackMakeup
+	}
+	for length > 2560 {
+		w.write(faxSharedMakeup[len(faxSharedMakeup)-1])
+		length -= 2560
+	}
+	if length >= 1792 {
+		w.write(faxSharedMakeup[length/64-28])
+		length %= 64
+	} else if length >= 64 {
+		w.write(makeup[length/64-1])
+		length %= 64
+	}
+	w.write(terminating[length])
+}
+
+// encodeGroup4 compresses a bilevel image, where palette index 0 is black,
+// with CCITT T.6 two-dimensional coding as TIFF compression 4 stores it.
+func encodeGroup4(bilevel *image.Paletted) []byte {
+	bounds := bilevel.Bounds()
+	width := bounds.Dx()
+	writer := &faxBitWriter{}
+
+	// Each line is coded against the one above; the first against white.
+	reference := make([]bool, width)
+	coding := make([]bool, width)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		row := bilevel.Pix[bilevel.PixOffset(bounds.Min.X, y):]
+		for x := range coding {
+			coding[x] = row[x] == 0
+		}
+		encodeGroup4Line(writer, coding, reference)
+		reference, coding = coding, reference
+	}
+
+	writer.write(faxEOFB)
+	return writer.data
+}
+
+// encodeGroup4Line codes one line of black (true) and white pixels against
+// the reference line above it.
+func encodeGroup4Line(writer *faxBitWriter, coding, reference []bool) {
+	width := len(coding)
+	// nextChange is the first position at or after from whose pixel is
+	// not of color, or width.
+	nextChange := func(line []bool, from int, color bool) int {
+		for from < width && line[from] == color {
+			from++
+		}
+		return from
+	}
+	at := func(line []bool, x int) bool {
+		return x < width && line[x]
+	}
+
+	a0, black := 0, false
+	a1 := nextChange(coding, 0, false)
+	b1 := nextChange(reference, 0, false)
+	for {
+		b2 := nextChange(reference, b1, at(reference, b1))
+		switch d := a1 - b1; {
+		case b2 < a1:
+			writer.write(faxPass)
+			a0 = b2
+		case d >= -3 && d <= 3:
+			writer.write(faxVertical[d+3])
+			a0, black = a1, !black
+		default:
+			a2 := nextChange(coding, a1, at(coding, a1))
+			writer.write(faxHorizontal)
+			writer.writeRun(a1-a0, black)
+			writer.writeRun(a2-a1, !black)
+			a0 = a2
+		}
+		if a0 >= width {
+			return
+		}
+		a1 = nextChange(coding, a0, black)
+		b1 = nextChange(reference, nextChange(reference, a0, !black), black)
+	}
+}
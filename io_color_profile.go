@@ -0,0 +1,376 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"unicode/utf16"
+)
+
+// Color management converts pixels described by an embedded ICC profile to
+// sRGB on load, so the histogram of an Adobe RGB or gray gamma 1.8 scan is
+// not read as if the values were sRGB. It is on unless turned off through
+// the -color-management flag or the GUI preference.
+var colorManagementDisabled atomic.Bool
+
+func SetColorManagement(enabled bool) {
+	colorManagementDisabled.Store(!enabled)
+}
+
+func ColorManagementEnabled() bool {
+	return !colorManagementDisabled.Load()
+}
+
+const tiffICCProfileTag = 34675
+
+// srgbFromXYZD50 is the Bradford-adapted matrix from the D50 profile
+// connection space to linear sRGB.
+var srgbFromXYZD50 = [3][3]float64{
+	{3.1338561, -1.6168667, -0.4906146},
+	{-0.9787684, 1.9161415, 0.0334540},
+	{0.0719453, -0.2289914, 1.4052427},
+}
+
+// colorTransform maps 8-bit values of a matrix/TRC or gray TRC profile to
+// 8-bit sRGB. It covers the profiles scanners and editors embed; profiles
+// built from lookup tables only are not supported.
+type colorTransform struct {
+	Profile string
+	Gray    bool
+
+	// curves linearize R, G and B, or the gray channel in curves[0];
+	// matrix takes linear profile RGB to linear sRGB.
+	curves [3][256]float64
+	matrix [3][3]float64
+	encode [4097]uint8
+}
+
+// String is what the sidecar records about the transform.
+func (ct *colorTransform) String() string {
+	return fmt.Sprintf("ICC profile %q converted to sRGB", ct.Profile)
+}
+
+// apply converts pixels in place. channels is 1 for gray and 3 for BGR, the
+// order OpenCV stores color pixels in.
+func (ct *colorTransform) apply(pixels []byte, channels int) {
+	if ct.Gray {
+		for i, value := range pixels {
+			pixels[i] = ct.encodeLinear(ct.curves[0][value])
+		}
+		return
+	}
+
+	m := &ct.matrix
+	for i := 0; i+2 < len(pixels); i += channels {
+		r, g, b := ct.curves[0][pixels[i+2]], ct.curves[1][pixels[i+1]], ct.curves[2][pixels[i]]
+		pixels[i+2] = ct.encodeLinear(m[0][0]*r + m[0][1]*g + m[0][2]*b)
+		pixels[i+1] = ct.encodeLinear(m[1][0]*r + m[1][1]*g + m[1][2]*b)
+		pixels[i] = ct.encodeLinear(m[2][0]*r + m[2][1]*g + m[2][2]*b)
+	}
+}
+
+func (ct *colorTransform) encodeLinear(value float64) uint8 {
+	index := int(value*4096 + 0.5)
+	return ct.encode[max(0, min(4096, index))]
+}
+
+// embeddedColorTransform reads the ICC profile of a PNG, JPEG or TIFF file
+// and returns the transform it calls for. It returns nil when the file has
+// no profile or one that is already sRGB.
+func embeddedColorTransform(data []byte) (*colorTransform, error) {
+	profile, err := extractICCProfile(data)
+	if err != nil || profile == nil {
+		return nil, err
+	}
+	return parseICCProfile(profile)
+}
+
+func extractICCProfile(data []byte) ([]byte, error) {
+	switch {
+	case bytes.HasPrefix(data, pngSignature):
+		return pngICCProfile(data)
+	case len(data) >= 2 && data[0] == 0xFF && data[1] == 0xD8:
+		return jpegICCProfile(data)
+	case bytes.HasPrefix(data, []byte("II*\x00")) || bytes.HasPrefix(data, []byte("MM\x00*")):
+		return tiffICCProfile(data)
+	}
+	return nil, nil
+}
+
+// pngICCProfile inflates the iCCP chunk, which precedes the image data.
+func pngICCProfile(data []byte) ([]byte, error) {
+	offset := len(pngSignature)
+	for offset+12 <= len(data) {
+		length := int(binary.BigEndian.Uint32(data[offset:]))
+		chunkType := string(data[offset+4 : offset+8])
+		end := offset + 12 + length
+		if length < 0 || end > len(data) {
+			return nil, fmt.Errorf("truncated %s chunk", chunkType)
+		}
+		payload := data[offset+8 : offset+8+length]
+		offset = end
+
+		if chunkType == "IDAT" || chunkType == "IEND" {
+			break
+		}
+		if chunkType != "iCCP" {
+			continue
+		}
+
+		// profile name, null terminator and compression method
+		nameEnd := bytes.IndexByte(payload, 0)
+		if nameEnd < 0 || nameEnd+2 > len(payload) {
+			return nil, fmt.Errorf("truncated iCCP chunk")
+		}
+		reader, err := zlib.NewReader(bytes.NewReader(payload[nameEnd+2:]))
+		if err != nil {
+			return nil, fmt.Errorf("iCCP chunk: %w", err)
+		}
+		defer reader.Close()
+		return io.ReadAll(reader)
+	}
+	return nil, nil
+}
+
+// jpegICCProfile joins the ICC_PROFILE APP2 segments in sequence order.
+func jpegICCProfile(data []byte) ([]byte, error) {
+	const header = "ICC_PROFILE\x00"
+	type part struct {
+		sequence int
+		data     []byte
+	}
+	var parts []part
+
+	offset := 2
+	for offset+4 <= len(data) && data[offset] == 0xFF {
+		marker := data[offset+1]
+		if marker == 0xDA || marker == 0xD9 {
+			break
+		}
+		length := int(binary.BigEndian.Uint16(data[offset+2:]))
+		end := offset + 2 + length
+		if length < 2 || end > len(data) {
+			return nil, fmt.Errorf("truncated JPEG segment")
+		}
+		payload := data[offset+4 : end]
+		if marker == 0xE2 && len(payload) > len(header)+2 && string(payload[:len(header)]) == header {
+			parts = append(parts, part{sequence: int(payload[len(header)]), data: payload[len(header)+2:]})
+		}
+		offset = end
+	}
+
+	if len(parts) == 0 {
+		return nil, nil
+	}
+	sort.Slice(parts, func(i, j int) bool { return parts[i].sequence < parts[j].sequence })
+	var profile []byte
+	for _, p := range parts {
+		profile = append(profile, p.data...)
+	}
+	return profile, nil
+}
+
+func tiffICCProfile(data []byte) ([]byte, error) {
+	ifd, err := readTIFFIFD(data)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range ifd.entries {
+		if ifd.order.Uint16(entry) != tiffICCProfileTag {
+			continue
+		}
+		size := int(ifd.order.Uint32(entry[4:]))
+		offset := int(ifd.order.Uint32(entry[8:]))
+		if size <= 4 || offset < 0 || offset+size > len(data) {
+			return nil, fmt.Errorf("ICC profile tag outside the file")
+		}
+		return data[offset : offset+size], nil
+	}
+	return nil, nil
+}
+
+// parseICCProfile builds the transform of an RGB matrix/TRC or a gray TRC
+// profile.
+func parseICCProfile(profile []byte) (*colorTransform, error) {
+	if len(profile) < 132 {
+		return nil, fmt.Errorf("ICC profile: truncated header")
+	}
+
+	tags := make(map[string][]byte)
+	count := int(binary.BigEndian.Uint32(profile[128:]))
+	for i := 0; i < count; i++ {
+		entry := 132 + i*12
+		if entry+12 > len(profile) {
+			return nil, fmt.Errorf("ICC profile: truncated tag table")
+		}
+		offset := int(binary.BigEndian.Uint32(profile[entry+4:]))
+		size := int(binary.BigEndian.Uint32(profile[entry+8:]))
+		if offset < 0 || size < 0 || offset+size > len(profile) {
+			return nil, fmt.Errorf("ICC profile: tag %s outside the profile", profile[entry:entry+4])
+		}
+		tags[string(profile[entry:entry+4])] = profile[offset : offset+size]
+	}
+
+	ct := &colorTransform{Profile: iccDescription(tags["desc"])}
+	if strings.Contains(ct.Profile, "sRGB") {
+		return nil, nil
+	}
+	for i := range ct.encode {
+		ct.encode[i] = uint8(math.Round(srgbEncode(float64(i)/4096) * 255))
+	}
+
+	switch colorSpace := string(profile[16:20]); colorSpace {
+	case "GRAY":
+		curve, err := iccCurve(tags["kTRC"])
+		if err != nil {
+			return nil, fmt.Errorf("ICC profile kTRC: %w", err)
+		}
+		ct.Gray = true
+		ct.curves[0] = curve
+		return ct, nil
+
+	case "RGB ":
+		var colorants [3][3]float64
+		for channel, name := range []string{"r", "g", "b"} {
+			curve, err := iccCurve(tags[name+"TRC"])
+			if err != nil {
+				return nil, fmt.Errorf("ICC profile %sTRC: %w", name, err)
+			}
+			ct.curves[channel] = curve
+
+			xyz := tags[name+"XYZ"]
+			if len(xyz) < 20 || string(xyz[:4]) != "XYZ " {
+				return nil, fmt.Errorf("ICC profile: no %sXYZ colorant; lookup table profiles are not supported", name)
+			}
+			for row := 0; row < 3; row++ {
+				colorants[row][channel] = s15Fixed16(xyz[8+row*4:])
+			}
+		}
+		for row := 0; row < 3; row++ {
+			for column := 0; column < 3; column++ {
+				for k := 0; k < 3; k++ {
+					ct.matrix[row][column] += srgbFromXYZD50[row][k] * colorants[k][column]
+				}
+			}
+		}
+		return ct, nil
+
+	default:
+		return nil, fmt.Errorf("ICC profile: %q color space is not supported", strings.TrimSpace(colorSpace))
+	}
+}
+
+// iccCurve samples a curv or para tone curve at the 256 8-bit values.
+func iccCurve(tag []byte) ([256]float64, error) {
+	var curve [256]float64
+	if len(tag) < 12 {
+		return curve, fmt.Errorf("missing or truncated curve")
+	}
+
+	switch string(tag[:4]) {
+	case "curv":
+		count := int(binary.BigEndian.Uint32(tag[8:]))
+		if len(tag) < 12+count*2 {
+			return curve, fmt.Errorf("truncated curve")
+		}
+		for i := range curve {
+			x := float64(i) / 255
+			switch count {
+			case 0:
+				curve[i] = x
+			case 1:
+				curve[i] = math.Pow(x, float64(binary.BigEndian.Uint16(tag[12:]))/256)
+			default:
+				position := x * float64(count-1)
+				low := int(position)
+				high := min(low+1, count-1)
+				a := float64(binary.BigEndian.Uint16(tag[12+low*2:])) / 65535
+				b := float64(binary.BigEndian.Uint16(tag[12+high*2:])) / 65535
+				curve[i] = a + (b-a)*(position-float64(low))
+			}
+		}
+		return curve, nil
+
+	case "para":
+		function := int(binary.BigEndian.Uint16(tag[8:]))
+		counts := []int{1, 3, 4, 5, 7}
+		if function >= len(counts) || len(tag) < 12+counts[function]*4 {
+			return curve, fmt.Errorf("unsupported parametric curve %d", function)
+		}
+		var p [7]float64
+		for i := 0; i < counts[function]; i++ {
+			p[i] = s15Fixed16(tag[12+i*4:])
+		}
+		g, a, b, c, d, e, f := p[0], p[1], p[2], p[3], p[4], p[5], p[6]
+		for i := range curve {
+			x := float64(i) / 255
+			var y float64
+			switch function {
+			case 0:
+				y = math.Pow(x, g)
+			case 1:
+				if x >= -b/a {
+					y = math.Pow(a*x+b, g)
+				}
+			case 2:
+				y = c
+				if x >= -b/a {
+					y += math.Pow(a*x+b, g)
+				}
+			case 3:
+				y = c * x
+				if x >= d {
+					y = math.Pow(a*x+b, g)
+				}
+			case 4:
+				y = c*x + f
+				if x >= d {
+					y = math.Pow(a*x+b, g) + e
+				}
+			}
+			curve[i] = y
+		}
+		return curve, nil
+	}
+	return curve, fmt.Errorf("unsupported curve type %q", tag[:4])
+}
+
+// iccDescription reads a v2 desc or v4 mluc description, the first record
+// of the latter.
+func iccDescription(tag []byte) string {
+	switch {
+	case len(tag) >= 12 && string(tag[:4]) == "desc":
+		length := int(binary.BigEndian.Uint32(tag[8:]))
+		if 12+length <= len(tag) {
+			return strings.TrimRight(string(tag[12:12+length]), "\x00")
+		}
+	case len(tag) >= 28 && string(tag[:4]) == "mluc":
+		length := int(binary.BigEndian.Uint32(tag[20:]))
+		offset := int(binary.BigEndian.Uint32(tag[24:]))
+		if offset+length <= len(tag) {
+			units := make([]uint16, length/2)
+			for i := range units {
+				units[i] = binary.BigEndian.Uint16(tag[offset+i*2:])
+			}
+			return string(utf16.Decode(units))
+		}
+	}
+	return "unnamed"
+}
+
+func s15Fixed16(b []byte) float64 {
+	return float64(int32(binary.BigEndian.Uint32(b))) / 65536
+}
+
+func srgbEncode(linear float64) float64 {
+	if linear <= 0.0031308 {
+		return 12.92 * linear
+	}
+	return 1.055*math.Pow(linear, 1/2.4) - 0.055
+}
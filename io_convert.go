@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// TIFF resolution tags; the encoder writes 72 per inch, which
+// embedImageDPI replaces.
+const (
+	tiffXResolutionTag = 282
+	tiffYResolutionTag = 283
+)
+
+// ConvertOptions controls how ConvertImage re-encodes an image. A DPI of 0
+// keeps the source resolution. BitDepth is 8, or 1 for PNG and TIFF images
+// that are already black and white; 1-bit TIFF files use Group 4.
+type ConvertOptions struct {
+	DPI      float64
+	BitDepth int
+}
+
+func (co ConvertOptions) validate(extension string) error {
+	if co.DPI < 0 || co.DPI > 65535 {
+		return fmt.Errorf("dpi %g outside 0-65535", co.DPI)
+	}
+	switch co.BitDepth {
+	case 0, 8:
+	case 1:
+		if extension != ".png" && extension != ".tif" && extension != ".tiff" {
+			return fmt.Errorf("1-bit output is only supported for PNG and TIFF")
+		}
+	default:
+		return fmt.Errorf("bit depth %d: expected 1 or 8", co.BitDepth)
+	}
+	return nil
+}
+
+// ConvertImage decodes data and encodes it again in the format of
+// extension, with the resolution and bit depth of options. It never
+// thresholds: 1-bit output requires an image that is already bilevel.
+func ConvertImage(data []byte, sourceExtension, extension string, options ConvertOptions) ([]byte, error) {
+	extension = strings.ToLower(extension)
+	if err := options.validate(extension); err != nil {
+		return nil, err
+	}
+
+	imageData, err := DecodeImageData(data, strings.ToLower(sourceExtension))
+	if err != nil {
+		return nil, err
+	}
+	defer imageData.Mat.Close()
+
+	if options.BitDepth == 1 {
+		bilevel, err := bilevelImage(imageData.Image)
+		if err != nil {
+			return nil, err
+		}
+		imageData.Image = bilevel
+	}
+
+	encoder := CurrentEncodeOptions()
+	if options.BitDepth == 1 {
+		encoder.TIFFCompression = TIFFCompressionGroup4
+	}
+	var encoded bytes.Buffer
+	if err := EncodeImageWithOptions(&encoded, imageData, extension, encoder); err != nil {
+		return nil, err
+	}
+
+	dpi := options.DPI
+	if dpi == 0 {
+		dpi = imageData.DPI
+	}
+	if dpi == 0 {
+		return encoded.Bytes(), nil
+	}
+	return embedImageDPI(encoded.Bytes(), extension, dpi)
+}
+
+// ConvertImageFile converts the image at input and writes it to output,
+// whose extension picks the format.
+func ConvertImageFile(input, output string, options ConvertOptions) error {
+	data, err := os.ReadFile(input)
+	if err != nil {
+		return fmt.Errorf("read %s: %w", input, err)
+	}
+	converted, err := ConvertImage(data, filepath.Ext(input), filepath.Ext(output), options)
+	if err != nil {
+		return fmt.Errorf("convert %s: %w", input, err)
+	}
+	if err := os.WriteFile(output, converted, 0644); err != nil {
+		return fmt.Errorf("write %s: %w", output, err)
+	}
+	return nil
+}
+
+// bilevelImage returns img as a two-color palette image, which the PNG
+// encoder writes with one bit per pixel. Any gray other than black or white
+// is an error.
+func bilevelImage(img image.Image) (*image.Paletted, error) {
+	bounds := img.Bounds()
+	gray := image.NewGray(bounds)
+	draw.Draw(gray, bounds, img, bounds.Min, draw.Src)
+
+	bilevel := image.NewPaletted(bounds, color.Palette{color.Gray{Y: 0}, color.Gray{Y: 255}})
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			switch gray.GrayAt(x, y).Y {
+			case 0:
+			case 255:
+				bilevel.SetColorIndex(x, y, 1)
+			default:
+				return nil, fmt.Errorf("the image has gray levels besides black and white; binarize it first")
+			}
+		}
+	}
+	return bilevel, nil
+}
+
+// embedImageDPI records dpi in an encoded PNG (pHYs), JPEG (JFIF) or TIFF
+// (XResolution and YResolution) image.
+func embedImageDPI(data []byte, extension string, dpi float64) ([]byte, error) {
+	switch strings.ToLower(extension) {
+	case ".jpg", ".jpeg":
+		if len(data) < 2 {
+			return nil, fmt.Errorf("embed resolution: truncated JPEG")
+		}
+		density := uint16(math.Round(dpi))
+		segment := []byte{0xFF, 0xE0, 0, 16, 'J', 'F', 'I', 'F', 0, 1, 1, 1,
+			byte(density >> 8), byte(density), byte(density >> 8), byte(density), 0, 0}
+		var out bytes.Buffer
+		out.Write(data[:2])
+		out.Write(segment)
+		out.Write(data[2:])
+		return out.Bytes(), nil
+
+	case ".tif", ".tiff":
+		var order binary.ByteOrder = binary.LittleEndian
+		if len(data) > 0 && data[0] == 'M' {
+			order = binary.BigEndian
+		}
+		// Hundredths of an inch keep fractional DPI from downscaled images.
+		value := make([]byte, 8)
+		order.PutUint32(value, uint32(math.Round(dpi*100)))
+		order.PutUint32(value[4:], 100)
+		return embedTIFFTags(data, []tiffTag{
+			{id: tiffXResolutionTag, dataType: tiffTypeRational, value: value},
+			{id: tiffYResolutionTag, dataType: tiffTypeRational, value: value},
+		})
+
+	default:
+		if !bytes.HasPrefix(data, pngSignature) {
+			return nil, fmt.Errorf("embed resolution: not a PNG file")
+		}
+		// IHDR always comes first and is 13 bytes long.
+		headerEnd := len(pngSignature) + 12 + 13
+		if len(data) < headerEnd {
+			return nil, fmt.Errorf("embed resolution: truncated PNG")
+		}
+		pixelsPerMeter := uint32(math.Round(dpi / 0.0254))
+		payload := make([]byte, 9)
+		binary.BigEndian.PutUint32(payload, pixelsPerMeter)
+		binary.BigEndian.PutUint32(payload[4:], pixelsPerMeter)
+		payload[8] = 1
+
+		var out bytes.Buffer
+		out.Write(data[:headerEnd])
+		writePNGChunk(&out, "pHYs", payload)
+		out.Write(data[headerEnd:])
+		return out.Bytes(), nil
+	}
+}
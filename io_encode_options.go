@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"strings"
+	"sync/atomic"
+
+	"golang.org/x/image/tiff"
+)
+
+const (
+	PNGCompressionDefault = "default"
+	PNGCompressionFast    = "fast"
+	PNGCompressionBest    = "best"
+	PNGCompressionNone    = "none"
+
+	TIFFCompressionDeflate = "deflate"
+	TIFFCompressionNone    = "none"
+	TIFFCompressionGroup4  = "group4"
+)
+
+var (
+	PNGCompressionLevels = []string{PNGCompressionDefault, PNGCompressionFast, PNGCompressionBest, PNGCompressionNone}
+	TIFFCompressions     = []string{TIFFCompressionDeflate, TIFFCompressionNone, TIFFCompressionGroup4}
+)
+
+// EncodeOptions are the encoder settings of saved images. Bilevel writes
+// black and white PNG images with one bit per pixel, as Group 4 TIFF
+// compression does; images with other gray levels, and the other formats,
+// keep 8 bits.
+type EncodeOptions struct {
+	PNGCompression  string
+	JPEGQuality     int
+	TIFFCompression string
+	Bilevel         bool
+}
+
+func DefaultEncodeOptions() EncodeOptions {
+	return EncodeOptions{
+		PNGCompression:  PNGCompressionDefault,
+		JPEGQuality:     95,
+		TIFFCompression: TIFFCompressionDeflate,
+	}
+}
+
+func (eo EncodeOptions) Validate() error {
+	if eo.JPEGQuality < 1 || eo.JPEGQuality > 100 {
+		return fmt.Errorf("JPEG quality %d outside 1-100", eo.JPEGQuality)
+	}
+	if _, err := eo.pngEncoder(); err != nil {
+		return err
+	}
+	if _, err := eo.tiffOptions(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (eo EncodeOptions) pngEncoder() (*png.Encoder, error) {
+	levels := map[string]png.CompressionLevel{
+		PNGCompressionDefault: png.DefaultCompression,
+		PNGCompressionFast:    png.BestSpeed,
+		PNGCompressionBest:    png.BestCompression,
+		PNGCompressionNone:    png.NoCompression,
+	}
+	level, ok := levels[eo.PNGCompression]
+	if !ok {
+		return nil, fmt.Errorf("PNG compression %q: expected one of %s", eo.PNGCompression, strings.Join(PNGCompressionLevels, ", "))
+	}
+	return &png.Encoder{CompressionLevel: level}, nil
+}
+
+// tiffOptions configures tiff.Encode. Group 4 only codes black and white
+// images, so other images saved with it fall back to deflate.
+func (eo EncodeOptions) tiffOptions() (*tiff.Options, error) {
+	switch eo.TIFFCompression {
+	case TIFFCompressionDeflate, TIFFCompressionGroup4:
+		return &tiff.Options{Compression: tiff.Deflate}, nil
+	case TIFFCompressionNone:
+		return &tiff.Options{Compression: tiff.Uncompressed}, nil
+	}
+	return nil, fmt.Errorf("TIFF compression %q: expected one of %s", eo.TIFFCompression, strings.Join(TIFFCompressions, ", "))
+}
+
+// encodeOptions holds the defaults EncodeImage uses; the GUI sets them from
+// its preferences.
+var encodeOptions atomic.Pointer[EncodeOptions]
+
+func SetEncodeOptions(options EncodeOptions) {
+	encodeOptions.Store(&options)
+}
+
+func CurrentEncodeOptions() EncodeOptions {
+	if options := encodeOptions.Load(); options != nil {
+		return *options
+	}
+	return DefaultEncodeOptions()
+}
+
+// EncodeImageWithOptions encodes imageData in the format of extension with
+// the encoder settings of options.
+func EncodeImageWithOptions(writer io.Writer, imageData *ImageData, extension string, options EncodeOptions) error {
+	if imageData == nil {
+		return fmt.Errorf("no image data to save")
+	}
+
+	if err := validateImageDimensions(imageData.Width, imageData.Height, "image saving"); err != nil {
+		return fmt.Errorf("save image validation: %w", err)
+	}
+
+	if err := validateMatForMetrics(imageData.Mat, "save image"); err != nil {
+		return fmt.Errorf("save image matrix validation: %w", err)
+	}
+
+	if err := options.Validate(); err != nil {
+		return fmt.Errorf("encoder options: %w", err)
+	}
+
+	img := imageData.Image
+
+	var err error
+	switch strings.ToLower(extension) {
+	case ".jpg", ".jpeg":
+		err = jpeg.Encode(writer, img, &jpeg.Options{Quality: options.JPEGQuality})
+	case ".tif", ".tiff":
+		if options.TIFFCompression == TIFFCompressionGroup4 {
+			group4 := &Group4TIFF{DPI: imageData.DPI}
+			if pageErr := group4.AddPage(img); pageErr == nil {
+				_, err = group4.WriteTo(writer)
+				break
+			}
+			GetDebugSystem().logger.Warn("image has gray levels, saving TIFF with deflate instead of group 4")
+		}
+		tiffOptions, _ := options.tiffOptions()
+		err = tiff.Encode(writer, img, tiffOptions)
+	default:
+		if options.Bilevel {
+			if bilevel, bilevelErr := bilevelImage(img); bilevelErr == nil {
+				img = bilevel
+			}
+		}
+		encoder, _ := options.pngEncoder()
+		err = encoder.Encode(writer, img)
+	}
+
+	if err != nil {
+		return fmt.Errorf("encode image: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gocv.io/x/gocv"
+)
+
+const (
+	// perceptualHashSize is the side of the grayscale thumbnail the
+	// perceptual hash transforms; the hash keeps the lowest 8x8 frequencies.
+	perceptualHashSize        = 32
+	perceptualHashFrequencies = 8
+
+	// hashDecodeMaxDimension bounds the decode of files that are only
+	// hashed, since the thumbnail is tiny.
+	hashDecodeMaxDimension = 512
+
+	// NearDuplicateDistance is the largest number of differing perceptual
+	// hash bits at which two images count as the same page: rescans,
+	// recompressions and small crops of it.
+	NearDuplicateDistance = 10
+)
+
+// PerceptualHash is a 64-bit DCT hash of how an image looks. Images that
+// look alike have hashes a small Hamming distance apart, whatever their
+// size, format or compression.
+type PerceptualHash uint64
+
+func (h PerceptualHash) String() string {
+	return fmt.Sprintf("%016x", uint64(h))
+}
+
+func ParsePerceptualHash(text string) (PerceptualHash, error) {
+	value, err := strconv.ParseUint(text, 16, 64)
+	if err != nil || len(text) != 16 {
+		return 0, fmt.Errorf("perceptual hash %q: expected 16 hex digits", text)
+	}
+	return PerceptualHash(value), nil
+}
+
+func (h PerceptualHash) MarshalText() ([]byte, error) {
+	return []byte(h.String()), nil
+}
+
+func (h *PerceptualHash) UnmarshalText(text []byte) error {
+	parsed, err := ParsePerceptualHash(string(text))
+	if err != nil {
+		return err
+	}
+	*h = parsed
+	return nil
+}
+
+// Distance is the number of bits in which h and other differ, 0 to 64.
+func (h PerceptualHash) Distance(other PerceptualHash) int {
+	return bits.OnesCount64(uint64(h ^ other))
+}
+
+// ImageHashes identifies an image file by its exact bytes and by its
+// appearance.
+type ImageHashes struct {
+	SHA256     string         `json:"sha256"`
+	Perceptual PerceptualHash `json:"phash"`
+}
+
+// HashImageFile hashes the file at path, decoding it at a reduced size for
+// the perceptual hash.
+func HashImageFile(path string) (*ImageHashes, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	header, err := InspectImageData(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	scale := math.Min(1, float64(hashDecodeMaxDimension)/float64(max(header.Width, header.Height)))
+	imageData, err := DecodeImageDataScaled(data, strings.ToLower(filepath.Ext(path)), scale)
+	if err != nil {
+		return nil, fmt.Errorf("decode %s: %w", path, err)
+	}
+	defer imageData.Mat.Close()
+
+	perceptual, err := PerceptualHashOf(imageData.Mat)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &ImageHashes{SHA256: sha256Hex(data), Perceptual: perceptual}, nil
+}
+
+// PerceptualHashOf hashes an image Mat of any channel count.
+func PerceptualHashOf(src gocv.Mat) (PerceptualHash, error) {
+	gray, err := normalizeToGrayscale(src, "perceptual hash")
+	if err != nil {
+		return 0, err
+	}
+	defer gray.Close()
+
+	thumbnail := gocv.NewMat()
+	defer thumbnail.Close()
+	gocv.Resize(gray, &thumbnail, image.Pt(perceptualHashSize, perceptualHashSize), 0, 0, gocv.InterpolationArea)
+
+	pixels := make([]float64, perceptualHashSize*perceptualHashSize)
+	for i, value := range thumbnail.ToBytes() {
+		pixels[i] = float64(value)
+	}
+	return perceptualHashOfPixels(pixels), nil
+}
+
+// perceptualHashOfPixels takes the 2D DCT of a perceptualHashSize square
+// thumbnail and sets one bit per low frequency coefficient above their
+// median. The constant term is left out of the median, so brightness does
+// not shift it.
+func perceptualHashOfPixels(pixels []float64) PerceptualHash {
+	const n, k = perceptualHashSize, perceptualHashFrequencies
+
+	var cosines [k][n]float64
+	for u := 0; u < k; u++ {
+		for x := 0; x < n; x++ {
+			cosines[u][x] = math.Cos(float64(2*x+1) * float64(u) * math.Pi / (2 * n))
+		}
+	}
+
+	// Rows first, then columns, keeping only the frequencies the hash uses.
+	var rows [n][k]float64
+	for y := 0; y < n; y++ {
+		for u := 0; u < k; u++ {
+			sum := 0.0
+			for x := 0; x < n; x++ {
+				sum += pixels[y*n+x] * cosines[u][x]
+			}
+			rows[y][u] = sum
+		}
+	}
+	coefficients := make([]float64, 0, k*k)
+	for v := 0; v < k; v++ {
+		for u := 0; u < k; u++ {
+			sum := 0.0
+			for y := 0; y < n; y++ {
+				sum += rows[y][u] * cosines[v][y]
+			}
+			coefficients = append(coefficients, sum)
+		}
+	}
+
+	sorted := append([]float64(nil), coefficients[1:]...)
+	sort.Float64s(sorted)
+	median := sorted[len(sorted)/2]
+
+	var hash PerceptualHash
+	for i, coefficient := range coefficients {
+		if coefficient > median {
+			hash |= 1 << uint(63-i)
+		}
+	}
+	return hash
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// fileSHA256 hashes the file at path without reading it into memory.
+func fileSHA256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", fmt.Errorf("hash %s: %w", path, err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
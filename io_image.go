@@ -1,38 +1,87 @@
 package main
 
 import (
-	"bufio"
+	"bytes"
+	"encoding/binary"
 	"fmt"
 	"image"
-	"image/jpeg"
-	"image/png"
 	"io"
+	"os"
 	"path/filepath"
 	"strings"
 
-	"fyne.io/fyne/v2"
 	"gocv.io/x/gocv"
 )
 
-func LoadImageFromReader(reader fyne.URIReadCloser) (*ImageData, error) {
-	originalURI := reader.URI()
-	uriExtension := strings.ToLower(filepath.Ext(originalURI.Path()))
-
-	bufferedReader := bufio.NewReader(reader)
-	data, err := io.ReadAll(bufferedReader)
+// LoadImageFile reads an image straight from disk for headless runs,
+// downscaling it to fit maxMegapixels.
+func LoadImageFile(path string, maxMegapixels float64) (*ImageData, error) {
+	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("read image data: %w", err)
 	}
 
-	img, standardLibFormat, err := image.Decode(strings.NewReader(string(data)))
+	return DecodeImageWithinLimits(data, strings.ToLower(filepath.Ext(path)), maxMegapixels)
+}
+
+// DecodeImageData decodes an encoded image; extension is the lowercase file
+// extension including the dot and is only used to name the format.
+func DecodeImageData(data []byte, extension string) (*ImageData, error) {
+	return DecodeImageDataScaled(data, extension, 1)
+}
+
+// DecodeImageDataScaled decodes an image and resizes it by scale, which must
+// be in (0, 1]. Use WorkingScale to pick a scale that fits the limits.
+func DecodeImageDataScaled(data []byte, extension string, scale float64) (*ImageData, error) {
+	header, err := InspectImageData(data)
 	if err != nil {
-		return nil, fmt.Errorf("decode image with standard library: %w", err)
+		return nil, err
 	}
 
-	// Use IMReadUnchanged to preserve alpha channels
-	mat, err := gocv.IMDecode(data, gocv.IMReadUnchanged)
-	if err != nil {
-		return nil, fmt.Errorf("decode image with OpenCV: %w", err)
+	if scale <= 0 || scale > 1 {
+		return nil, fmt.Errorf("decode image: scale %.3f outside (0, 1]", scale)
+	}
+
+	// Skip the full-resolution standard library decode for downscaled images;
+	// the preview is rebuilt from the resized matrix instead.
+	var img image.Image
+	standardLibFormat := strings.ToLower(header.Format)
+	var mat gocv.Mat
+	if header.Format == "JPEG" && header.Components == 4 {
+		mat, err = decodeCMYKJPEG(data, header)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		if scale == 1 {
+			img, standardLibFormat, err = image.Decode(bytes.NewReader(data))
+			if err != nil {
+				// The standard library rejects some progressive streams from
+				// older scanners, and TIFF compressions such as JPEG, that
+				// libjpeg and libtiff in OpenCV read fine, so the preview is
+				// rebuilt from the matrix instead.
+				if header.Format == "PNG" {
+					return nil, fmt.Errorf("decode image with standard library: %w", err)
+				}
+				GetDebugSystem().logger.Warn("standard library decode failed, using OpenCV",
+					"format", header.Format, "progressive", header.Progressive, "error", err.Error())
+				img = nil
+				standardLibFormat = strings.ToLower(header.Format)
+			}
+		}
+
+		// Use IMReadUnchanged to preserve alpha channels
+		mat, err = gocv.IMDecode(data, gocv.IMReadUnchanged)
+		if err != nil {
+			return nil, fmt.Errorf("decode image with OpenCV: %w", err)
+		}
+		if mat.Empty() {
+			mat.Close()
+			return nil, &ImageDiagnosticError{
+				Problem:    fmt.Sprintf("OpenCV could not decode this %s file although its header looks valid", header.Format),
+				Suggestion: "the pixel data is probably damaged; re-export the image or " + convertToPNGSuggestion,
+			}
+		}
 	}
 
 	// Handle transparency by compositing with white background
@@ -42,6 +91,32 @@ func LoadImageFromReader(reader fyne.URIReadCloser) (*ImageData, error) {
 		mat = composited
 	}
 
+	colorTransform := applyEmbeddedColorProfile(mat, data)
+	if (colorTransform != "" || img == nil) && scale == 1 {
+		img, err = mat.ToImage()
+		if err != nil {
+			mat.Close()
+			return nil, fmt.Errorf("build preview from decoded image: %w", err)
+		}
+	}
+
+	originalWidth := mat.Cols()
+	originalHeight := mat.Rows()
+
+	if scale < 1 {
+		resized := gocv.NewMat()
+		size := image.Pt(scaledDimension(originalWidth, scale), scaledDimension(originalHeight, scale))
+		gocv.Resize(mat, &resized, size, 0, 0, gocv.InterpolationArea)
+		mat.Close()
+		mat = resized
+
+		img, err = mat.ToImage()
+		if err != nil {
+			mat.Close()
+			return nil, fmt.Errorf("build preview from resized image: %w", err)
+		}
+	}
+
 	bounds := img.Bounds()
 	width := bounds.Dx()
 	height := bounds.Dy()
@@ -56,20 +131,110 @@ func LoadImageFromReader(reader fyne.URIReadCloser) (*ImageData, error) {
 		return nil, fmt.Errorf("loaded image matrix validation: %w", err)
 	}
 
-	actualFormat := determineImageFormat(uriExtension, standardLibFormat)
+	actualFormat := determineImageFormat(extension, standardLibFormat)
 
 	imageData := &ImageData{
-		Image:    img,
-		Mat:      mat,
-		Width:    width,
-		Height:   height,
-		Channels: mat.Channels(),
-		Format:   actualFormat,
+		Image:          img,
+		Mat:            mat,
+		Width:          width,
+		Height:         height,
+		Channels:       mat.Channels(),
+		Format:         actualFormat,
+		DPI:            detectImageDPI(data) * scale,
+		OriginalWidth:  originalWidth,
+		OriginalHeight: originalHeight,
+		ScaleFactor:    scale,
+		ColorTransform: colorTransform,
 	}
 
 	return imageData, nil
 }
 
+// applyEmbeddedColorProfile converts the 8-bit pixels of mat to sRGB when
+// data embeds an ICC profile other than sRGB, and describes the conversion.
+// A profile that cannot be read or applied leaves mat unchanged; that is
+// logged rather than failing the load.
+func applyEmbeddedColorProfile(mat gocv.Mat, data []byte) string {
+	if !ColorManagementEnabled() {
+		return ""
+	}
+
+	logger := GetDebugSystem().logger
+	transform, err := embeddedColorTransform(data)
+	if err != nil {
+		logger.Warn("embedded color profile ignored", "error", err.Error())
+		return ""
+	}
+	if transform == nil {
+		return ""
+	}
+
+	channels := mat.Channels()
+	if mat.Type()&0x7 != gocv.MatTypeCV8U || !mat.IsContinuous() || (!transform.Gray && channels != 3) {
+		logger.Warn("embedded color profile ignored", "profile", transform.Profile,
+			"error", fmt.Sprintf("does not apply to %d-channel %v pixels", channels, mat.Type()))
+		return ""
+	}
+	pixels, err := mat.DataPtrUint8()
+	if err != nil {
+		logger.Warn("embedded color profile ignored", "profile", transform.Profile, "error", err.Error())
+		return ""
+	}
+
+	transform.apply(pixels, channels)
+	logger.Info("embedded color profile applied", "profile", transform.Profile)
+	return transform.String()
+}
+
+var pngSignature = []byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1a, '\n'}
+
+// detectImageDPI reads the horizontal resolution from a PNG pHYs chunk, a
+// JPEG JFIF header or the TIFF resolution tags. It returns 0 when no usable
+// resolution is stored.
+func detectImageDPI(data []byte) float64 {
+	if isTIFFData(data) {
+		return tiffDPI(data)
+	}
+
+	if bytes.HasPrefix(data, pngSignature) {
+		offset := len(pngSignature)
+		for offset+8 <= len(data) {
+			length := int(binary.BigEndian.Uint32(data[offset:]))
+			chunkType := string(data[offset+4 : offset+8])
+			chunkData := offset + 8
+
+			if chunkType == "IDAT" || chunkData+length > len(data) {
+				return 0
+			}
+
+			if chunkType == "pHYs" && length >= 9 {
+				pixelsPerUnit := binary.BigEndian.Uint32(data[chunkData:])
+				if data[chunkData+8] != 1 {
+					return 0 // aspect ratio only, no absolute unit
+				}
+				return float64(pixelsPerUnit) * 0.0254
+			}
+
+			offset = chunkData + length + 4 // skip CRC
+		}
+		return 0
+	}
+
+	if len(data) >= 18 && data[0] == 0xFF && data[1] == 0xD8 &&
+		data[2] == 0xFF && data[3] == 0xE0 && string(data[6:11]) == "JFIF\x00" {
+		units := data[13]
+		density := float64(binary.BigEndian.Uint16(data[14:]))
+		switch units {
+		case 1:
+			return density
+		case 2:
+			return density * 2.54
+		}
+	}
+
+	return 0
+}
+
 func compositeTransparencyWithWhiteBackground(src gocv.Mat) gocv.Mat {
 	if src.Channels() != 4 {
 		return src.Clone()
@@ -143,38 +308,10 @@ func compositeTransparencyWithWhiteBackground(src gocv.Mat) gocv.Mat {
 	return result
 }
 
-func SaveImageToWriter(writer fyne.URIWriteCloser, imageData *ImageData) error {
-	if imageData == nil {
-		return fmt.Errorf("no image data to save")
-	}
-
-	// Validate image data before saving
-	if err := validateImageDimensions(imageData.Width, imageData.Height, "image saving"); err != nil {
-		return fmt.Errorf("save image validation: %w", err)
-	}
-
-	if err := validateMatForMetrics(imageData.Mat, "save image"); err != nil {
-		return fmt.Errorf("save image matrix validation: %w", err)
-	}
-
-	img := imageData.Image
-	ext := strings.ToLower(writer.URI().Extension())
-
-	var err error
-	switch ext {
-	case ".jpg", ".jpeg":
-		err = jpeg.Encode(writer, img, &jpeg.Options{Quality: 95})
-	case ".png":
-		err = png.Encode(writer, img)
-	default:
-		err = png.Encode(writer, img)
-	}
-
-	if err != nil {
-		return fmt.Errorf("encode image: %w", err)
-	}
-
-	return nil
+// EncodeImage writes imageData as JPEG for .jpg/.jpeg extensions, as TIFF
+// for .tif/.tiff and as PNG otherwise, with the current encoder defaults.
+func EncodeImage(writer io.Writer, imageData *ImageData, extension string) error {
+	return EncodeImageWithOptions(writer, imageData, extension, CurrentEncodeOptions())
 }
 
 func determineImageFormat(uriExtension, stdLibFormat string) string {
@@ -183,6 +320,8 @@ func determineImageFormat(uriExtension, stdLibFormat string) string {
 		return "jpeg"
 	case ".png":
 		return "png"
+	case ".tif", ".tiff":
+		return "tiff"
 	default:
 		if stdLibFormat != "" {
 			return stdLibFormat
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// ImageDiagnosticError explains why a file cannot be loaded and what the
+// user can do about it.
+type ImageDiagnosticError struct {
+	Problem    string
+	Suggestion string
+}
+
+func (e *ImageDiagnosticError) Error() string {
+	return fmt.Sprintf("%s - %s", e.Problem, e.Suggestion)
+}
+
+// ImageHeader is what InspectImageData learns from the file header without
+// decoding pixel data.
+type ImageHeader struct {
+	Format      string
+	Width       int
+	Height      int
+	BitDepth    int
+	Components  int
+	Progressive bool
+	// AdobeMarker is set when a JPEG carries the Adobe APP14 segment, which
+	// tells inverted Adobe CMYK apart from the plain CMYK scanners write.
+	AdobeMarker bool
+	// Pages is the number of pages of a TIFF file, 1 for other formats.
+	Pages int
+}
+
+// maxHeaderDimension is far beyond any scanner output; larger header values
+// come from corrupt files rather than real images.
+const maxHeaderDimension = 1 << 20
+
+const convertToPNGSuggestion = "convert it to an 8-bit PNG, e.g. `magick input output.png`"
+
+// InspectImageData checks the container structure of an encoded image before
+// it is handed to the decoders, so broken or unsupported files fail with an
+// explanation instead of an empty matrix.
+func InspectImageData(data []byte) (*ImageHeader, error) {
+	if len(data) == 0 {
+		return nil, &ImageDiagnosticError{
+			Problem:    "file is empty",
+			Suggestion: "check that the file finished copying or downloading",
+		}
+	}
+
+	var header *ImageHeader
+	var err error
+
+	switch {
+	case bytes.HasPrefix(data, pngSignature):
+		header, err = inspectPNG(data)
+	case len(data) >= 3 && data[0] == 0xFF && data[1] == 0xD8 && data[2] == 0xFF:
+		header, err = inspectJPEG(data)
+	case isTIFFData(data):
+		header, err = inspectTIFF(data)
+	default:
+		return nil, unsupportedFormatError(data)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	if header.Pages == 0 {
+		header.Pages = 1
+	}
+
+	// Images above MaxImageDimension are downscaled on load; only sizes that
+	// cannot be real scans are rejected here.
+	if header.Width < 3 || header.Height < 3 {
+		return header, &ImageDiagnosticError{
+			Problem:    fmt.Sprintf("%s image is %dx%d pixels, below the 3x3 minimum", header.Format, header.Width, header.Height),
+			Suggestion: "check that the right file was selected",
+		}
+	}
+	if header.Width > maxHeaderDimension || header.Height > maxHeaderDimension {
+		return header, &ImageDiagnosticError{
+			Problem:    fmt.Sprintf("%s header claims %dx%d pixels, which suggests a corrupt file", header.Format, header.Width, header.Height),
+			Suggestion: "re-export the image from its source application",
+		}
+	}
+
+	return header, nil
+}
+
+func unsupportedFormatError(data []byte) error {
+	format := "unrecognized"
+	switch {
+	case bytes.HasPrefix(data, []byte("GIF8")):
+		format = "GIF"
+	case bytes.HasPrefix(data, []byte("BM")):
+		format = "BMP"
+	case len(data) >= 12 && string(data[0:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		format = "WebP"
+	case len(data) >= 12 && string(data[4:12]) == "ftypheic":
+		format = "HEIC"
+	}
+
+	return &ImageDiagnosticError{
+		Problem:    fmt.Sprintf("%s file format is not supported (PNG, JPEG and TIFF are)", format),
+		Suggestion: convertToPNGSuggestion,
+	}
+}
+
+func inspectPNG(data []byte) (*ImageHeader, error) {
+	offset := len(pngSignature)
+	if len(data) < offset+8+13 || string(data[offset+4:offset+8]) != "IHDR" {
+		return nil, truncatedError("PNG", "header", len(data))
+	}
+
+	ihdr := data[offset+8:]
+	header := &ImageHeader{
+		Format:   "PNG",
+		Width:    int(binary.BigEndian.Uint32(ihdr[0:4])),
+		Height:   int(binary.BigEndian.Uint32(ihdr[4:8])),
+		BitDepth: int(ihdr[8]),
+	}
+
+	switch ihdr[9] {
+	case 0:
+		header.Components = 1
+	case 2:
+		header.Components = 3
+	case 3:
+		header.Components = 1 // palette indices
+	case 4:
+		header.Components = 2
+	case 6:
+		header.Components = 4
+	}
+	header.Progressive = ihdr[12] == 1
+
+	if header.BitDepth == 16 {
+		return header, &ImageDiagnosticError{
+			Problem:    "16-bit PNG images are not supported",
+			Suggestion: "reduce it to 8 bits per channel, e.g. `magick input -depth 8 output.png`",
+		}
+	}
+
+	// Walk the chunk list to find IEND; a file cut short ends mid-chunk.
+	for offset+8 <= len(data) {
+		length := int(binary.BigEndian.Uint32(data[offset:]))
+		chunkType := string(data[offset+4 : offset+8])
+		next := offset + 12 + length
+		if next > len(data) {
+			break
+		}
+		if chunkType == "IEND" {
+			return header, nil
+		}
+		offset = next
+	}
+
+	return header, truncatedError("PNG", "image data", len(data))
+}
+
+func inspectJPEG(data []byte) (*ImageHeader, error) {
+	header := &ImageHeader{Format: "JPEG"}
+	foundFrame := false
+
+	offset := 2
+	for offset+4 <= len(data) {
+		if data[offset] != 0xFF {
+			return nil, &ImageDiagnosticError{
+				Problem:    fmt.Sprintf("JPEG structure is corrupt at byte %d", offset),
+				Suggestion: "re-export the image from its source application",
+			}
+		}
+
+		marker := data[offset+1]
+		if marker == 0xFF {
+			offset++ // fill byte
+			continue
+		}
+
+		length := int(binary.BigEndian.Uint16(data[offset+2:]))
+		segment := offset + 4
+		if segment+length-2 > len(data) {
+			return nil, truncatedError("JPEG", "header", len(data))
+		}
+
+		switch {
+		case marker == 0xDA: // start of scan: entropy-coded data follows
+			if !foundFrame {
+				return nil, truncatedError("JPEG", "frame header", len(data))
+			}
+			return header, checkJPEGEnd(data)
+		case marker >= 0xC0 && marker <= 0xCF && marker != 0xC4 && marker != 0xC8 && marker != 0xCC:
+			if length < 8 {
+				return nil, truncatedError("JPEG", "frame header", len(data))
+			}
+			foundFrame = true
+			header.BitDepth = int(data[segment])
+			header.Height = int(binary.BigEndian.Uint16(data[segment+1:]))
+			header.Width = int(binary.BigEndian.Uint16(data[segment+3:]))
+			header.Components = int(data[segment+5])
+			header.Progressive = marker == 0xC2 || marker == 0xC6 || marker == 0xCA || marker == 0xCE
+
+			if err := checkJPEGFrame(marker, header); err != nil {
+				return header, err
+			}
+		case marker == 0xEE: // APP14
+			header.AdobeMarker = length >= 14 && string(data[segment:segment+5]) == "Adobe"
+		}
+
+		offset = segment + length - 2
+	}
+
+	return nil, truncatedError("JPEG", "header", len(data))
+}
+
+func checkJPEGFrame(marker byte, header *ImageHeader) error {
+	if marker >= 0xC9 {
+		return &ImageDiagnosticError{
+			Problem:    "arithmetic-coded JPEG images are not supported",
+			Suggestion: "re-save it as a standard baseline JPEG or " + convertToPNGSuggestion,
+		}
+	}
+
+	if header.BitDepth != 8 {
+		return &ImageDiagnosticError{
+			Problem:    fmt.Sprintf("%d-bit JPEG images are not supported", header.BitDepth),
+			Suggestion: convertToPNGSuggestion,
+		}
+	}
+
+	return nil
+}
+
+// checkJPEGEnd looks for the end-of-image marker; trailing bytes after it,
+// which some cameras append, are tolerated.
+func checkJPEGEnd(data []byte) error {
+	if bytes.LastIndex(data, []byte{0xFF, 0xD9}) < 0 {
+		return truncatedError("JPEG", "image data", len(data))
+	}
+	return nil
+}
+
+func truncatedError(format, part string, size int) error {
+	return &ImageDiagnosticError{
+		Problem:    fmt.Sprintf("%s file is truncated: %s ends early (file is %d bytes)", format, part, size),
+		Suggestion: "the file was likely cut off while copying or downloading; fetch it again",
+	}
+}
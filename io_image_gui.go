@@ -0,0 +1,63 @@
+//go:build !nogui
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"fyne.io/fyne/v2"
+)
+
+func LoadImageFromReader(reader fyne.URIReadCloser) (*ImageData, error) {
+	data, extension, err := ReadImageBytes(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	return DecodeImageData(data, extension)
+}
+
+// ReadImageBytes returns the encoded image and its lowercase extension so the
+// caller can inspect the header before deciding how to decode it.
+func ReadImageBytes(reader fyne.URIReadCloser) ([]byte, string, error) {
+	originalURI := reader.URI()
+	uriExtension := strings.ToLower(filepath.Ext(originalURI.Path()))
+
+	bufferedReader := bufio.NewReader(reader)
+	data, err := io.ReadAll(bufferedReader)
+	if err != nil {
+		return nil, "", fmt.Errorf("read image data: %w", err)
+	}
+
+	return data, uriExtension, nil
+}
+
+// SaveImageToWriter encodes imageData in the format of the writer's
+// extension with metadata embedded.
+func SaveImageToWriter(writer fyne.URIWriteCloser, imageData *ImageData, metadata ImageMetadata) error {
+	return saveImageWithOptions(writer, imageData, CurrentEncodeOptions(), metadata)
+}
+
+func saveImageWithOptions(writer fyne.URIWriteCloser, imageData *ImageData, options EncodeOptions, metadata ImageMetadata) error {
+	var encoded bytes.Buffer
+	if err := EncodeImageWithOptions(&encoded, imageData, writer.URI().Extension(), options); err != nil {
+		return err
+	}
+	return writeWithMetadata(writer, encoded.Bytes(), writer.URI().Extension(), metadata)
+}
+
+func writeWithMetadata(writer io.Writer, encoded []byte, extension string, metadata ImageMetadata) error {
+	output, err := EmbedImageMetadata(encoded, extension, metadata)
+	if err != nil {
+		return err
+	}
+	if _, err := writer.Write(output); err != nil {
+		return fmt.Errorf("write image: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,495 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// pngXMPKeyword is the iTXt keyword XMP readers look for in PNG files.
+	pngXMPKeyword = "XML:com.adobe.xmp"
+
+	// TIFF tags of an XMP packet and of an IPTC-IIM record.
+	tiffXMPTag  = 700
+	tiffIPTCTag = 33723
+
+	// jpegXMPHeader and jpegPhotoshopHeader start the APP1 and APP13
+	// segments holding XMP and IPTC-IIM.
+	jpegXMPHeader       = "http://ns.adobe.com/xap/1.0/\x00"
+	jpegPhotoshopHeader = "Photoshop 3.0\x00"
+)
+
+// xmpNamespaces are declared on every packet written.
+var xmpNamespaces = [][2]string{
+	{"xmp", "http://ns.adobe.com/xap/1.0/"},
+	{"dc", "http://purl.org/dc/elements/1.1/"},
+	{"otsu", provenanceNamespace},
+}
+
+// TextChunk is a keyword/value pair stored in an image's metadata.
+type TextChunk struct {
+	Keyword string
+	Text    string
+}
+
+// ImageMetadata is the descriptive metadata digitization deliverables carry.
+// It is written as Dublin Core XMP, which IPTC Core uses for these fields,
+// and as an IPTC-IIM record in JPEG and TIFF files for older readers.
+type ImageMetadata struct {
+	Title       string `json:"title,omitempty"`
+	Creator     string `json:"creator,omitempty"`
+	Rights      string `json:"rights,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+func (im ImageMetadata) IsEmpty() bool {
+	return im == ImageMetadata{}
+}
+
+// EmbedImageMetadata stores metadata in an encoded PNG, JPEG or TIFF image;
+// extension selects the format.
+func EmbedImageMetadata(data []byte, extension string, metadata ImageMetadata) ([]byte, error) {
+	if metadata.IsEmpty() {
+		return data, nil
+	}
+
+	properties := []xmpProperty{
+		{name: "dc:title", value: metadata.Title, kind: xmpAlt},
+		{name: "dc:creator", value: metadata.Creator, kind: xmpSeq},
+		{name: "dc:rights", value: metadata.Rights, kind: xmpAlt},
+		{name: "dc:description", value: metadata.Description, kind: xmpAlt},
+	}
+	packet := buildXMPPacket(properties)
+
+	switch strings.ToLower(extension) {
+	case ".jpg", ".jpeg":
+		return embedJPEGSegments(data, [][]byte{
+			append([]byte{0xFF, 0xE1}, jpegSegmentPayload(jpegXMPHeader, []byte(packet))...),
+			append([]byte{0xFF, 0xED}, jpegSegmentPayload(jpegPhotoshopHeader, photoshopIPTCResource(iptcRecord(metadata)))...),
+		})
+	case ".tif", ".tiff":
+		return embedTIFFTags(data, []tiffTag{
+			{id: tiffXMPTag, dataType: tiffTypeByte, value: []byte(packet)},
+			{id: tiffIPTCTag, dataType: tiffTypeUndefined, value: iptcRecord(metadata)},
+		})
+	default:
+		return EmbedXMPPacket(data, extension, packet)
+	}
+}
+
+// EmbedXMPPacket stores packet in an encoded PNG, JPEG or TIFF image,
+// replacing any packet there in TIFF files.
+func EmbedXMPPacket(data []byte, extension, packet string) ([]byte, error) {
+	switch strings.ToLower(extension) {
+	case ".jpg", ".jpeg":
+		return embedJPEGSegments(data, [][]byte{append([]byte{0xFF, 0xE1}, jpegSegmentPayload(jpegXMPHeader, []byte(packet))...)})
+	case ".tif", ".tiff":
+		return embedTIFFTags(data, []tiffTag{{id: tiffXMPTag, dataType: tiffTypeByte, value: []byte(packet)}})
+	default:
+		return EmbedPNGTextChunks(data, []TextChunk{{Keyword: pngXMPKeyword, Text: packet}})
+	}
+}
+
+// ReadXMPPacket returns the XMP packet of a PNG or TIFF file, or nil when
+// it has none.
+func ReadXMPPacket(data []byte) ([]byte, error) {
+	switch {
+	case bytes.HasPrefix(data, pngSignature):
+		return pngXMPPacket(data)
+	case bytes.HasPrefix(data, []byte("II*\x00")) || bytes.HasPrefix(data, []byte("MM\x00*")):
+		return tiffXMPPacket(data)
+	default:
+		return nil, fmt.Errorf("not a PNG or TIFF file")
+	}
+}
+
+const (
+	xmpSimple = iota
+	xmpAlt
+	xmpSeq
+)
+
+// xmpProperty is one property of a packet's rdf:Description. Empty values
+// are left out.
+type xmpProperty struct {
+	name  string
+	value string
+	kind  int
+}
+
+func buildXMPPacket(properties []xmpProperty) string {
+	escape := func(value string) string {
+		var escaped bytes.Buffer
+		xml.EscapeText(&escaped, []byte(value))
+		return escaped.String()
+	}
+
+	var text strings.Builder
+	text.WriteString("<?xpacket begin=\"\ufeff\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n")
+	text.WriteString("<x:xmpmeta xmlns:x=\"adobe:ns:meta/\">\n")
+	text.WriteString(" <rdf:RDF xmlns:rdf=\"http://www.w3.org/1999/02/22-rdf-syntax-ns#\">\n")
+	text.WriteString("  <rdf:Description rdf:about=\"\"")
+	for _, namespace := range xmpNamespaces {
+		text.WriteString(" xmlns:" + namespace[0] + "=\"" + namespace[1] + "\"")
+	}
+	text.WriteString(">\n")
+	text.WriteString("   <xmp:CreatorTool>" + escape(AppName+" "+AppVersion) + "</xmp:CreatorTool>\n")
+
+	for _, property := range properties {
+		if property.value == "" {
+			continue
+		}
+		value := escape(property.value)
+		switch property.kind {
+		case xmpAlt:
+			value = "<rdf:Alt><rdf:li xml:lang=\"x-default\">" + value + "</rdf:li></rdf:Alt>"
+		case xmpSeq:
+			value = "<rdf:Seq><rdf:li>" + value + "</rdf:li></rdf:Seq>"
+		}
+		text.WriteString("   <" + property.name + ">" + value + "</" + property.name + ">\n")
+	}
+
+	text.WriteString("  </rdf:Description>\n </rdf:RDF>\n</x:xmpmeta>\n<?xpacket end=\"r\"?>")
+	return text.String()
+}
+
+// iptcRecord encodes metadata as IPTC-IIM datasets in UTF-8, truncated to
+// the lengths the standard allows; XMP keeps the full text.
+func iptcRecord(metadata ImageMetadata) []byte {
+	var record bytes.Buffer
+	dataset := func(recordNumber, datasetNumber byte, value []byte) {
+		record.Write([]byte{0x1C, recordNumber, datasetNumber, byte(len(value) >> 8), byte(len(value))})
+		record.Write(value)
+	}
+
+	dataset(1, 90, []byte("\x1b%G"))
+	dataset(2, 0, []byte{0, 4})
+	for _, field := range []struct {
+		number byte
+		value  string
+		limit  int
+	}{
+		{5, metadata.Title, 64},
+		{80, metadata.Creator, 32},
+		{116, metadata.Rights, 128},
+		{120, metadata.Description, 2000},
+	} {
+		if field.value != "" {
+			dataset(2, field.number, []byte(truncateUTF8(field.value, field.limit)))
+		}
+	}
+	return record.Bytes()
+}
+
+func truncateUTF8(value string, limit int) string {
+	if len(value) <= limit {
+		return value
+	}
+	value = value[:limit]
+	for !utf8.ValidString(value) {
+		value = value[:len(value)-1]
+	}
+	return value
+}
+
+// photoshopIPTCResource wraps an IPTC-IIM record in the image resource block
+// JPEG files carry it in.
+func photoshopIPTCResource(record []byte) []byte {
+	var resource bytes.Buffer
+	resource.WriteString("8BIM")
+	resource.Write([]byte{0x04, 0x04, 0, 0})
+	binary.Write(&resource, binary.BigEndian, uint32(len(record)))
+	resource.Write(record)
+	if len(record)%2 == 1 {
+		resource.WriteByte(0)
+	}
+	return resource.Bytes()
+}
+
+func jpegSegmentPayload(header string, body []byte) []byte {
+	payload := make([]byte, 2, 2+len(header)+len(body))
+	binary.BigEndian.PutUint16(payload, uint16(2+len(header)+len(body)))
+	payload = append(payload, header...)
+	return append(payload, body...)
+}
+
+// embedJPEGSegments inserts marker segments after SOI and any JFIF APP0
+// segment.
+func embedJPEGSegments(data []byte, segments [][]byte) ([]byte, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, fmt.Errorf("embed metadata: not a JPEG stream")
+	}
+	for _, segment := range segments {
+		if len(segment)-2 > 0xFFFF {
+			return nil, fmt.Errorf("embed metadata: %d bytes exceed a JPEG segment", len(segment))
+		}
+	}
+
+	offset := 2
+	if data[2] == 0xFF && data[3] == 0xE0 && len(data) >= 6 {
+		offset = 4 + int(binary.BigEndian.Uint16(data[4:]))
+		if offset > len(data) {
+			return nil, fmt.Errorf("embed metadata: truncated APP0 segment")
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.Write(data[:offset])
+	for _, segment := range segments {
+		buf.Write(segment)
+	}
+	buf.Write(data[offset:])
+	return buf.Bytes(), nil
+}
+
+// EmbedPNGTextChunks inserts chunks as uncompressed iTXt chunks directly
+// after IHDR, so readers that stop at the first IDAT still see them.
+func EmbedPNGTextChunks(data []byte, chunks []TextChunk) ([]byte, error) {
+	if !bytes.HasPrefix(data, pngSignature) || len(data) < len(pngSignature)+8 {
+		return nil, fmt.Errorf("embed text chunks: not a PNG stream")
+	}
+
+	headerOffset := len(pngSignature)
+	if string(data[headerOffset+4:headerOffset+8]) != "IHDR" {
+		return nil, fmt.Errorf("embed text chunks: IHDR chunk missing")
+	}
+
+	headerEnd := headerOffset + 12 + int(binary.BigEndian.Uint32(data[headerOffset:]))
+	if headerEnd > len(data) {
+		return nil, fmt.Errorf("embed text chunks: truncated IHDR chunk")
+	}
+
+	var buf bytes.Buffer
+	buf.Write(data[:headerEnd])
+
+	for _, chunk := range chunks {
+		if len(chunk.Keyword) == 0 || len(chunk.Keyword) > 79 {
+			return nil, fmt.Errorf("embed text chunks: keyword %q must be 1-79 bytes", chunk.Keyword)
+		}
+
+		// keyword, null, compression flag, compression method, empty
+		// language tag and translated keyword, then the UTF-8 text.
+		var payload bytes.Buffer
+		payload.WriteString(chunk.Keyword)
+		payload.Write([]byte{0, 0, 0, 0, 0})
+		payload.WriteString(chunk.Text)
+
+		writePNGChunk(&buf, "iTXt", payload.Bytes())
+	}
+
+	buf.Write(data[headerEnd:])
+	return buf.Bytes(), nil
+}
+
+func writePNGChunk(buf *bytes.Buffer, chunkType string, payload []byte) {
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(len(payload)))
+	buf.Write(length[:])
+
+	crc := crc32.NewIEEE()
+	crc.Write([]byte(chunkType))
+	crc.Write(payload)
+
+	buf.WriteString(chunkType)
+	buf.Write(payload)
+
+	var sum [4]byte
+	binary.BigEndian.PutUint32(sum[:], crc.Sum32())
+	buf.Write(sum[:])
+}
+
+// pngXMPPacket returns the text of the XMP iTXt chunk, or nil without one.
+func pngXMPPacket(data []byte) ([]byte, error) {
+	offset := len(pngSignature)
+	for offset+12 <= len(data) {
+		length := int(binary.BigEndian.Uint32(data[offset:]))
+		chunkType := string(data[offset+4 : offset+8])
+		end := offset + 12 + length
+		if length < 0 || end > len(data) {
+			return nil, fmt.Errorf("truncated %s chunk", chunkType)
+		}
+		payload := data[offset+8 : offset+8+length]
+		offset = end
+
+		if chunkType == "IEND" {
+			break
+		}
+		if chunkType != "iTXt" || !bytes.HasPrefix(payload, []byte(pngXMPKeyword+"\x00")) {
+			continue
+		}
+
+		// compression flag and method, then the null-terminated language
+		// tag and translated keyword.
+		rest := payload[len(pngXMPKeyword)+1:]
+		if len(rest) < 2 {
+			return nil, fmt.Errorf("truncated XMP chunk")
+		}
+		compressed := rest[0] == 1
+		rest = rest[2:]
+		for field := 0; field < 2; field++ {
+			end := bytes.IndexByte(rest, 0)
+			if end < 0 {
+				return nil, fmt.Errorf("truncated XMP chunk")
+			}
+			rest = rest[end+1:]
+		}
+		if !compressed {
+			return rest, nil
+		}
+		reader, err := zlib.NewReader(bytes.NewReader(rest))
+		if err != nil {
+			return nil, fmt.Errorf("XMP chunk: %w", err)
+		}
+		defer reader.Close()
+		return io.ReadAll(reader)
+	}
+	return nil, nil
+}
+
+const (
+	tiffTypeByte      = 1
+	tiffTypeRational  = 5
+	tiffTypeUndefined = 7
+)
+
+// tiffTag is a tag of BYTE, UNDEFINED or RATIONAL values to store in a TIFF
+// file; RATIONAL values are in the file's byte order.
+type tiffTag struct {
+	id       uint16
+	dataType uint16
+	value    []byte
+}
+
+// tiffIFD is the first image directory of a TIFF file.
+type tiffIFD struct {
+	order   binary.ByteOrder
+	entries [][]byte
+	next    []byte
+}
+
+func readTIFFIFD(data []byte) (*tiffIFD, error) {
+	if len(data) < 8 {
+		return nil, fmt.Errorf("truncated TIFF header")
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	if data[0] == 'M' {
+		order = binary.BigEndian
+	}
+
+	offset := int(order.Uint32(data[4:]))
+	if offset+2 > len(data) {
+		return nil, fmt.Errorf("TIFF directory outside the file")
+	}
+	count := int(order.Uint16(data[offset:]))
+	end := offset + 2 + count*12
+	if end+4 > len(data) {
+		return nil, fmt.Errorf("truncated TIFF directory")
+	}
+
+	ifd := &tiffIFD{order: order, next: data[end : end+4]}
+	for i := 0; i < count; i++ {
+		start := offset + 2 + i*12
+		ifd.entries = append(ifd.entries, data[start:start+12])
+	}
+	return ifd, nil
+}
+
+// tiffXMPPacket returns the XMP tag of the first directory, or nil without
+// one.
+func tiffXMPPacket(data []byte) ([]byte, error) {
+	ifd, err := readTIFFIFD(data)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range ifd.entries {
+		if ifd.order.Uint16(entry) != tiffXMPTag {
+			continue
+		}
+		size := int(ifd.order.Uint32(entry[4:]))
+		if size <= 4 {
+			return entry[8 : 8+size], nil
+		}
+		offset := int(ifd.order.Uint32(entry[8:]))
+		if offset < 0 || offset+size > len(data) {
+			return nil, fmt.Errorf("XMP tag outside the file")
+		}
+		return data[offset : offset+size], nil
+	}
+	return nil, nil
+}
+
+// embedTIFFTags appends the tag values and a copy of the first directory
+// holding the tags to the file and points the header at the copy. Offsets
+// of the other tags stay valid because nothing before them moves. Tags the
+// directory already has are replaced.
+func embedTIFFTags(data []byte, tags []tiffTag) ([]byte, error) {
+	ifd, err := readTIFFIFD(data)
+	if err != nil {
+		return nil, fmt.Errorf("embed metadata: %w", err)
+	}
+
+	var out bytes.Buffer
+	out.Write(data)
+	replaced := make(map[uint16]bool)
+	var added [][]byte
+	for _, tag := range tags {
+		entry := make([]byte, 12)
+		ifd.order.PutUint16(entry, tag.id)
+		ifd.order.PutUint16(entry[2:], tag.dataType)
+		count := len(tag.value)
+		if tag.dataType == tiffTypeRational {
+			count /= 8
+		}
+		ifd.order.PutUint32(entry[4:], uint32(count))
+		if len(tag.value) <= 4 {
+			copy(entry[8:], tag.value)
+		} else {
+			if out.Len()%2 == 1 {
+				out.WriteByte(0)
+			}
+			ifd.order.PutUint32(entry[8:], uint32(out.Len()))
+			out.Write(tag.value)
+		}
+		replaced[tag.id] = true
+		added = append(added, entry)
+	}
+	if out.Len()%2 == 1 {
+		out.WriteByte(0)
+	}
+
+	// Tags must stay in ascending order.
+	var entries [][]byte
+	for _, entry := range ifd.entries {
+		if !replaced[ifd.order.Uint16(entry)] {
+			entries = append(entries, entry)
+		}
+	}
+	entries = append(entries, added...)
+	sort.SliceStable(entries, func(i, j int) bool {
+		return ifd.order.Uint16(entries[i]) < ifd.order.Uint16(entries[j])
+	})
+
+	ifdOffset := out.Len()
+	count := make([]byte, 2)
+	ifd.order.PutUint16(count, uint16(len(entries)))
+	out.Write(count)
+	for _, entry := range entries {
+		out.Write(entry)
+	}
+	out.Write(ifd.next)
+
+	result := out.Bytes()
+	if uint64(len(result)) > 1<<32-1 {
+		return nil, fmt.Errorf("embed metadata: TIFF file too large")
+	}
+	ifd.order.PutUint32(result[4:], uint32(ifdOffset))
+	return result, nil
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/draw"
+	"math"
+	"os"
+)
+
+const (
+	// MaxImageDimension is the largest side the processing engine accepts.
+	MaxImageDimension = 32768
+
+	// DefaultMaxWorkingMegapixels keeps a full 2D Otsu run within a few GB
+	// of memory; archival scans at 600 DPI of A2 sheets stay below it.
+	DefaultMaxWorkingMegapixels = 150.0
+)
+
+// WorkingScale returns the factor in (0, 1] that brings width x height within
+// MaxImageDimension per side and maxMegapixels in total. A maxMegapixels of
+// zero or less disables the megapixel limit.
+func WorkingScale(width, height int, maxMegapixels float64) float64 {
+	scale := 1.0
+
+	if longest := max(width, height); longest > MaxImageDimension {
+		scale = float64(MaxImageDimension) / float64(longest)
+	}
+
+	if maxMegapixels > 0 {
+		megapixels := float64(width) * float64(height) / 1e6
+		if limit := math.Sqrt(maxMegapixels / megapixels); limit < scale {
+			scale = limit
+		}
+	}
+
+	return scale
+}
+
+// DecodeImageWithinLimits decodes data, downscaling it when it exceeds the
+// working limits.
+func DecodeImageWithinLimits(data []byte, extension string, maxMegapixels float64) (*ImageData, error) {
+	header, err := InspectImageData(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if header.Pages > 1 {
+		GetDebugSystem().logger.Warn("multi-page TIFF, decoding the first page", "pages", header.Pages)
+	}
+
+	scale := WorkingScale(header.Width, header.Height, maxMegapixels)
+	if scale < 1 {
+		debugSystem := GetDebugSystem()
+		debugSystem.logger.Warn("image exceeds working limits, downscaling",
+			"width", header.Width,
+			"height", header.Height,
+			"max_megapixels", maxMegapixels,
+			"scale", scale,
+		)
+	}
+
+	return DecodeImageDataScaled(data, extension, scale)
+}
+
+func scaledDimension(size int, scale float64) int {
+	return max(1, int(math.Round(float64(size)*scale)))
+}
+
+// OutputSidecar is written next to an output image as <output>.json to
+// record how the output relates to its source when that is not 1:1.
+type OutputSidecar struct {
+	Source         string  `json:"source,omitempty"`
+	SourceWidth    int     `json:"source_width"`
+	SourceHeight   int     `json:"source_height"`
+	WorkingWidth   int     `json:"working_width"`
+	WorkingHeight  int     `json:"working_height"`
+	ScaleFactor    float64 `json:"scale_factor"`
+	AppVersion     string  `json:"app_version"`
+	ScaleRationale string  `json:"scale_rationale,omitempty"`
+	ColorTransform string  `json:"color_transform,omitempty"`
+
+	// TouchUp is set when the result was edited by hand before saving.
+	TouchUp *TouchUpRecord `json:"touch_up,omitempty"`
+
+	// Crop is set when the output was trimmed to its content.
+	Crop *OutputCrop `json:"crop,omitempty"`
+
+	// Notes are the notes pinned to the source, in working image pixels.
+	Notes []Annotation `json:"notes,omitempty"`
+
+	// Parameters, Summary and Metrics describe the run that made a result
+	// saved from the GUI, for reviewers opening it with the result viewer.
+	Parameters *OtsuParameters `json:"parameters,omitempty"`
+	Summary    string          `json:"summary,omitempty"`
+	Metrics    *MetricsReport  `json:"metrics,omitempty"`
+}
+
+// NewOutputSidecar returns nil when source was processed at full resolution
+// without a color conversion and there is nothing to record.
+func NewOutputSidecar(sourcePath string, source *ImageData) *OutputSidecar {
+	if source == nil {
+		return nil
+	}
+	scaled := source.ScaleFactor != 0 && source.ScaleFactor != 1
+	if !scaled && source.ColorTransform == "" {
+		return nil
+	}
+
+	sidecar := &OutputSidecar{
+		Source:         sourcePath,
+		SourceWidth:    source.OriginalWidth,
+		SourceHeight:   source.OriginalHeight,
+		WorkingWidth:   source.Width,
+		WorkingHeight:  source.Height,
+		ScaleFactor:    1,
+		AppVersion:     AppVersion,
+		ColorTransform: source.ColorTransform,
+	}
+	if scaled {
+		sidecar.ScaleFactor = source.ScaleFactor
+		sidecar.ScaleRationale = "downscaled on load to fit the working size limit"
+	}
+	return sidecar
+}
+
+// withCrop records crop in sidecar, starting a sidecar for source when
+// there was nothing else to record. A nil crop leaves sidecar as it is.
+func withCrop(sidecar *OutputSidecar, sourcePath string, source *ImageData, crop *OutputCrop) *OutputSidecar {
+	if crop == nil || source == nil {
+		return sidecar
+	}
+	if sidecar == nil {
+		sidecar = &OutputSidecar{
+			Source:        sourcePath,
+			SourceWidth:   source.Width,
+			SourceHeight:  source.Height,
+			WorkingWidth:  source.Width,
+			WorkingHeight: source.Height,
+			ScaleFactor:   1,
+			AppVersion:    AppVersion,
+		}
+	}
+	sidecar.Crop = crop
+	return sidecar
+}
+
+func SidecarPath(outputPath string) string {
+	return outputPath + ".json"
+}
+
+func (sc *OutputSidecar) Write(outputPath string) error {
+	data, err := json.MarshalIndent(sc, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode sidecar: %w", err)
+	}
+
+	path := SidecarPath(outputPath)
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("write sidecar %s: %w", path, err)
+	}
+	return nil
+}
+
+// newImageLike returns an empty image to scale img into, gray for gray
+// images at a quarter of the memory of RGBA.
+func newImageLike(img image.Image, bounds image.Rectangle) draw.Image {
+	if _, ok := img.(*image.Gray); ok {
+		return image.NewGray(bounds)
+	}
+	return image.NewRGBA(bounds)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+
+	"gocv.io/x/gocv"
+)
+
+// adobeCMYKMarker is an APP14 segment declaring untransformed CMYK. It lets
+// the standard library decode CMYK JPEGs written without one.
+var adobeCMYKMarker = []byte{
+	0xFF, 0xEE, 0x00, 0x0E,
+	'A', 'd', 'o', 'b', 'e',
+	0x00, 0x64, // version 100
+	0x00, 0x00, 0x00, 0x00, // flags
+	0x00, // transform: none
+}
+
+// decodeCMYKJPEG decodes a four-component JPEG to a BGR matrix.
+//
+// Adobe applications store CMYK inverted and mark it with APP14; scanner
+// firmware usually stores plain CMYK without the marker. OpenCV assumes the
+// inverted form for both, so plain CMYK came out color-inverted, and the
+// standard library refuses files without the marker.
+func decodeCMYKJPEG(data []byte, header *ImageHeader) (gocv.Mat, error) {
+	if !header.AdobeMarker {
+		patched := make([]byte, 0, len(data)+len(adobeCMYKMarker))
+		patched = append(patched, data[:2]...)
+		patched = append(patched, adobeCMYKMarker...)
+		data = append(patched, data[2:]...)
+	}
+
+	img, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		return gocv.NewMat(), fmt.Errorf("decode CMYK JPEG: %w", err)
+	}
+	cmyk, ok := img.(*image.CMYK)
+	if !ok {
+		return gocv.NewMat(), fmt.Errorf("decode CMYK JPEG: decoder returned %T", img)
+	}
+
+	// The standard library undoes the Adobe inversion; for plain CMYK that
+	// inversion was never applied, so flip the values back.
+	var flip uint8
+	if !header.AdobeMarker {
+		flip = 0xFF
+	}
+
+	bounds := cmyk.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	bgr := make([]byte, width*height*3)
+	for y := 0; y < height; y++ {
+		row := cmyk.Pix[y*cmyk.Stride:]
+		out := bgr[y*width*3:]
+		for x := 0; x < width; x++ {
+			c := uint32(row[x*4] ^ flip)
+			m := uint32(row[x*4+1] ^ flip)
+			yellow := uint32(row[x*4+2] ^ flip)
+			white := 255 - uint32(row[x*4+3]^flip)
+
+			out[x*3] = uint8((255 - yellow) * white / 255)
+			out[x*3+1] = uint8((255 - m) * white / 255)
+			out[x*3+2] = uint8((255 - c) * white / 255)
+		}
+	}
+
+	GetDebugSystem().logger.Info("CMYK JPEG converted to RGB",
+		"adobe_marker", header.AdobeMarker, "progressive", header.Progressive,
+		"width", width, "height", height)
+
+	return gocv.NewMatFromBytes(height, width, gocv.MatTypeCV8UC3, bgr)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// The fixtures are 32x32 four-component JPEGs laid out like scanner output:
+// white, cyan, magenta and black quadrants, one flat block each, with a unit
+// quantization table so decoding is exact. cmyk_plain.jpg is baseline plain
+// CMYK without an Adobe marker, as scanner firmware writes it;
+// cmyk_adobe.jpg is inverted CMYK with the APP14 marker, as Adobe
+// applications write it; cmyk_progressive.jpg is plain CMYK in a
+// progressive stream with a DC scan and one AC scan per component.
+var cmykQuadrants = []struct {
+	name string
+	x, y int
+	want color.RGBA
+}{
+	{"white", 8, 8, color.RGBA{255, 255, 255, 255}},
+	{"cyan", 24, 8, color.RGBA{0, 255, 255, 255}},
+	{"magenta", 8, 24, color.RGBA{255, 0, 255, 255}},
+	{"black", 24, 24, color.RGBA{0, 0, 0, 255}},
+}
+
+func TestDecodeCMYKJPEGFixtures(t *testing.T) {
+	tests := []struct {
+		file        string
+		adobe       bool
+		progressive bool
+	}{
+		{"cmyk_plain.jpg", false, false},
+		{"cmyk_adobe.jpg", true, false},
+		{"cmyk_progressive.jpg", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			header, err := InspectImageData(data)
+			if err != nil {
+				t.Fatalf("InspectImageData: %v", err)
+			}
+			if header.Components != 4 || header.AdobeMarker != tt.adobe || header.Progressive != tt.progressive {
+				t.Fatalf("header = %d components, Adobe marker %v, progressive %v; want 4, %v, %v",
+					header.Components, header.AdobeMarker, header.Progressive, tt.adobe, tt.progressive)
+			}
+
+			imageData, err := DecodeImageData(data, ".jpg")
+			if err != nil {
+				t.Fatalf("DecodeImageData: %v", err)
+			}
+			defer imageData.Mat.Close()
+
+			if imageData.Width != 32 || imageData.Height != 32 || imageData.Mat.Channels() != 3 {
+				t.Fatalf("decoded %dx%d with %d channels, want 32x32 with 3",
+					imageData.Width, imageData.Height, imageData.Mat.Channels())
+			}
+
+			for _, quadrant := range cmykQuadrants {
+				bgr := imageData.Mat.GetVecbAt(quadrant.y, quadrant.x)
+				got := color.RGBA{bgr[2], bgr[1], bgr[0], 255}
+				if !colorsClose(got, quadrant.want) {
+					t.Errorf("%s quadrant matrix pixel = %v, want %v", quadrant.name, got, quadrant.want)
+				}
+
+				preview := color.RGBAModel.Convert(imageData.Image.At(quadrant.x, quadrant.y)).(color.RGBA)
+				if !colorsClose(preview, quadrant.want) {
+					t.Errorf("%s quadrant preview pixel = %v, want %v", quadrant.name, preview, quadrant.want)
+				}
+			}
+		})
+	}
+}
+
+// colorsClose allows for rounding in the CMYK to RGB conversion; an
+// inverted decode is off by far more.
+func colorsClose(a, b color.RGBA) bool {
+	near := func(x, y uint8) bool {
+		return int(x)-int(y) <= 2 && int(y)-int(x) <= 2
+	}
+	return near(a.R, b.R) && near(a.G, b.G) && near(a.B, b.B)
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Layout export formats.
+const (
+	LayoutPAGE = "page"
+	LayoutALTO = "alto"
+)
+
+const (
+	pageXMLNamespace = "http://schema.primaresearch.org/PAGE/gts/pagecontent/2019-07-15"
+	altoNamespace    = "http://www.loc.gov/standards/alto/ns-v4#"
+)
+
+func validateLayoutFormat(format string) error {
+	switch format {
+	case LayoutPAGE, LayoutALTO:
+		return nil
+	}
+	return fmt.Errorf("unknown layout format %q: expected %s or %s", format, LayoutPAGE, LayoutALTO)
+}
+
+// LayoutPath is where the layout of an output image is written, next to it
+// as <name>.page.xml or <name>.alto.xml.
+func LayoutPath(outputPath, format string) string {
+	return strings.TrimSuffix(outputPath, filepath.Ext(outputPath)) + "." + format + ".xml"
+}
+
+// TextLayout is a layout skeleton for OCR: the text regions found in a
+// binarized image, without lines, words or text. ImageFile names the
+// binarized image the regions refer to.
+type TextLayout struct {
+	ImageFile string
+	Width     int
+	Height    int
+	Regions   []image.Rectangle
+	CreatedAt time.Time
+}
+
+type pageXMLDocument struct {
+	XMLName  xml.Name `xml:"PcGts"`
+	Xmlns    string   `xml:"xmlns,attr"`
+	Metadata struct {
+		Creator    string
+		Created    string
+		LastChange string
+	}
+	Page struct {
+		ImageFilename string          `xml:"imageFilename,attr"`
+		ImageWidth    int             `xml:"imageWidth,attr"`
+		ImageHeight   int             `xml:"imageHeight,attr"`
+		TextRegions   []pageXMLRegion `xml:"TextRegion"`
+	}
+}
+
+type pageXMLRegion struct {
+	ID     string `xml:"id,attr"`
+	Coords struct {
+		Points string `xml:"points,attr"`
+	}
+}
+
+type altoDocument struct {
+	XMLName     xml.Name `xml:"alto"`
+	Xmlns       string   `xml:"xmlns,attr"`
+	Description struct {
+		MeasurementUnit        string
+		SourceImageInformation struct {
+			FileName string `xml:"fileName"`
+		} `xml:"sourceImageInformation"`
+	}
+	Layout struct {
+		Page struct {
+			ID         string `xml:"ID,attr"`
+			Width      int    `xml:"WIDTH,attr"`
+			Height     int    `xml:"HEIGHT,attr"`
+			ImageNr    int    `xml:"PHYSICAL_IMG_NR,attr"`
+			PrintSpace struct {
+				HPos       int         `xml:"HPOS,attr"`
+				VPos       int         `xml:"VPOS,attr"`
+				Width      int         `xml:"WIDTH,attr"`
+				Height     int         `xml:"HEIGHT,attr"`
+				TextBlocks []altoBlock `xml:"TextBlock"`
+			}
+		}
+	}
+}
+
+type altoBlock struct {
+	ID     string `xml:"ID,attr"`
+	HPos   int    `xml:"HPOS,attr"`
+	VPos   int    `xml:"VPOS,attr"`
+	Width  int    `xml:"WIDTH,attr"`
+	Height int    `xml:"HEIGHT,attr"`
+}
+
+// PageXML encodes the layout as PAGE-XML 2019 with one TextRegion per
+// region.
+func (tl *TextLayout) PageXML() ([]byte, error) {
+	var document pageXMLDocument
+	document.Xmlns = pageXMLNamespace
+	document.Metadata.Creator = AppName + " " + AppVersion
+	document.Metadata.Created = tl.CreatedAt.UTC().Format(time.RFC3339)
+	document.Metadata.LastChange = document.Metadata.Created
+	document.Page.ImageFilename = tl.ImageFile
+	document.Page.ImageWidth = tl.Width
+	document.Page.ImageHeight = tl.Height
+	for i, region := range tl.Regions {
+		entry := pageXMLRegion{ID: fmt.Sprintf("r%d", i+1)}
+		entry.Coords.Points = fmt.Sprintf("%d,%d %d,%d %d,%d %d,%d",
+			region.Min.X, region.Min.Y, region.Max.X-1, region.Min.Y,
+			region.Max.X-1, region.Max.Y-1, region.Min.X, region.Max.Y-1)
+		document.Page.TextRegions = append(document.Page.TextRegions, entry)
+	}
+	return encodeLayoutXML(document)
+}
+
+// ALTO encodes the layout as ALTO v4 with one TextBlock per region.
+func (tl *TextLayout) ALTO() ([]byte, error) {
+	var document altoDocument
+	document.Xmlns = altoNamespace
+	document.Description.MeasurementUnit = "pixel"
+	document.Description.SourceImageInformation.FileName = tl.ImageFile
+	page := &document.Layout.Page
+	page.ID = "p1"
+	page.Width, page.Height = tl.Width, tl.Height
+	page.ImageNr = 1
+	page.PrintSpace.Width, page.PrintSpace.Height = tl.Width, tl.Height
+	for i, region := range tl.Regions {
+		page.PrintSpace.TextBlocks = append(page.PrintSpace.TextBlocks, altoBlock{
+			ID:     fmt.Sprintf("r%d", i+1),
+			HPos:   region.Min.X,
+			VPos:   region.Min.Y,
+			Width:  region.Dx(),
+			Height: region.Dy(),
+		})
+	}
+	return encodeLayoutXML(document)
+}
+
+// Encode writes the layout in format, LayoutPAGE or LayoutALTO.
+func (tl *TextLayout) Encode(format string) ([]byte, error) {
+	switch format {
+	case LayoutPAGE:
+		return tl.PageXML()
+	case LayoutALTO:
+		return tl.ALTO()
+	}
+	return nil, validateLayoutFormat(format)
+}
+
+func (tl *TextLayout) WriteFile(path, format string) error {
+	data, err := tl.Encode(format)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("write layout %s: %w", path, err)
+	}
+	return nil
+}
+
+func encodeLayoutXML(document any) ([]byte, error) {
+	data, err := xml.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("encode layout: %w", err)
+	}
+	return append([]byte(xml.Header), append(data, '\n')...), nil
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// modelCacheEntry is the cache entry downloaded models live in. It is
+	// never evicted; models are removed through the model manager.
+	modelCacheEntry = "models"
+	modelExtension  = ".onnx"
+
+	// modelRegistryLimit bounds the registry index, which is small JSON.
+	modelRegistryLimit = 4 << 20
+)
+
+var modelNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// ModelRegistryEntry is one downloadable model of a registry index. URL may
+// be relative to the index. Config, when given, is written as the model's
+// descriptor.
+type ModelRegistryEntry struct {
+	Name        string             `json:"name"`
+	Description string             `json:"description,omitempty"`
+	URL         string             `json:"url"`
+	SHA256      string             `json:"sha256"`
+	Size        int64              `json:"size,omitempty"`
+	Config      *NeuralModelConfig `json:"config,omitempty"`
+}
+
+// ModelRegistry is the index a registry URL serves.
+type ModelRegistry struct {
+	Models []ModelRegistryEntry `json:"models"`
+
+	base *url.URL
+}
+
+// LocalModel is a model file in the managed model directory.
+type LocalModel struct {
+	Name     string
+	Path     string
+	Size     int64
+	Modified time.Time
+}
+
+// ModelDir is the managed model directory in the artifact cache.
+func ModelDir() string {
+	return filepath.Join(GetArtifactCache().Dir(), modelCacheEntry)
+}
+
+// DefaultModelRegistryURL is $OTSU_MODEL_REGISTRY; there is no built-in
+// registry.
+func DefaultModelRegistryURL() string {
+	return os.Getenv(envModelRegistry)
+}
+
+// LocalModels lists the managed models in name order.
+func LocalModels() ([]LocalModel, error) {
+	items, err := os.ReadDir(ModelDir())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read model directory: %w", err)
+	}
+
+	var models []LocalModel
+	for _, item := range items {
+		if item.IsDir() || !strings.EqualFold(filepath.Ext(item.Name()), modelExtension) {
+			continue
+		}
+		info, err := item.Info()
+		if err != nil {
+			continue
+		}
+		path := filepath.Join(ModelDir(), item.Name())
+		models = append(models, LocalModel{
+			Name:     neuralModelName(path),
+			Path:     path,
+			Size:     info.Size(),
+			Modified: info.ModTime(),
+		})
+	}
+	sort.Slice(models, func(i, j int) bool { return models[i].Name < models[j].Name })
+	return models, nil
+}
+
+// ResolveModelPath returns the managed model named nameOrPath when there is
+// one and nameOrPath is not itself a file, so -model accepts either.
+func ResolveModelPath(nameOrPath string) string {
+	if _, err := os.Stat(nameOrPath); err == nil || !modelNamePattern.MatchString(nameOrPath) {
+		return nameOrPath
+	}
+	managed := filepath.Join(ModelDir(), strings.TrimSuffix(nameOrPath, modelExtension)+modelExtension)
+	if _, err := os.Stat(managed); err == nil {
+		return managed
+	}
+	return nameOrPath
+}
+
+// FetchModelRegistry reads the index at location, an http or https URL or
+// a local file.
+func FetchModelRegistry(ctx context.Context, location string) (*ModelRegistry, error) {
+	base, err := url.Parse(location)
+	if err != nil {
+		return nil, fmt.Errorf("model registry %q: %w", location, err)
+	}
+
+	body, err := openModelSource(ctx, base)
+	if err != nil {
+		return nil, fmt.Errorf("model registry: %w", err)
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(body, modelRegistryLimit))
+	if err != nil {
+		return nil, fmt.Errorf("read model registry: %w", err)
+	}
+
+	registry := &ModelRegistry{base: base}
+	if err := json.Unmarshal(data, registry); err != nil {
+		return nil, fmt.Errorf("decode model registry %s: %w", location, err)
+	}
+	for _, entry := range registry.Models {
+		switch {
+		case !modelNamePattern.MatchString(entry.Name):
+			return nil, fmt.Errorf("model registry %s: invalid model name %q", location, entry.Name)
+		case entry.URL == "":
+			return nil, fmt.Errorf("model registry %s: model %s has no url", location, entry.Name)
+		case len(entry.SHA256) != sha256.Size*2:
+			return nil, fmt.Errorf("model registry %s: model %s needs a sha256 checksum", location, entry.Name)
+		}
+	}
+	return registry, nil
+}
+
+// Download fetches entry into the model directory and returns its path.
+// The file only takes the model's name once its checksum matches. progress
+// receives the bytes read so far and the expected size, which is 0 when
+// unknown.
+func (mr *ModelRegistry) Download(ctx context.Context, entry ModelRegistryEntry, progress func(read, size int64)) (string, error) {
+	source, err := url.Parse(entry.URL)
+	if err != nil {
+		return "", fmt.Errorf("model %s: %w", entry.Name, err)
+	}
+	if mr.base != nil {
+		source = mr.base.ResolveReference(source)
+	}
+
+	dir := ModelDir()
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("create model directory: %w", err)
+	}
+
+	body, err := openModelSource(ctx, source)
+	if err != nil {
+		return "", fmt.Errorf("download model %s: %w", entry.Name, err)
+	}
+	defer body.Close()
+
+	temporary, err := os.CreateTemp(dir, entry.Name+"-*.part")
+	if err != nil {
+		return "", fmt.Errorf("download model %s: %w", entry.Name, err)
+	}
+	defer os.Remove(temporary.Name())
+
+	// A registry size caps the download, so a wrong or hostile source
+	// cannot fill the disk before the checksum rejects it; one byte past
+	// the size is read to tell a longer file from an exact one.
+	var reader io.Reader = body
+	if entry.Size > 0 {
+		reader = io.LimitReader(body, entry.Size+1)
+	}
+
+	hash := sha256.New()
+	counter := &progressWriter{size: entry.Size, progress: progress}
+	_, err = io.Copy(io.MultiWriter(temporary, hash, counter), reader)
+	if closeErr := temporary.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", fmt.Errorf("download model %s: %w", entry.Name, err)
+	}
+	if entry.Size > 0 && counter.read > entry.Size {
+		return "", fmt.Errorf("model %s: download exceeds the registry's size of %d bytes", entry.Name, entry.Size)
+	}
+
+	if sum := hex.EncodeToString(hash.Sum(nil)); !strings.EqualFold(sum, entry.SHA256) {
+		return "", fmt.Errorf("model %s: checksum %s does not match the registry's %s", entry.Name, sum, strings.ToLower(entry.SHA256))
+	}
+
+	// A descriptor left by an earlier download must not outlive the model
+	// it described.
+	path := filepath.Join(dir, entry.Name+modelExtension)
+	if err := os.Remove(path + ".json"); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("replace model descriptor: %w", err)
+	}
+	if entry.Config != nil {
+		data, err := json.MarshalIndent(entry.Config, "", "  ")
+		if err != nil {
+			return "", fmt.Errorf("encode model descriptor: %w", err)
+		}
+		if err := os.WriteFile(path+".json", append(data, '\n'), 0644); err != nil {
+			return "", fmt.Errorf("write model descriptor: %w", err)
+		}
+		if _, err := LoadNeuralModelConfig(path); err != nil {
+			os.Remove(path + ".json")
+			return "", err
+		}
+	}
+	if err := os.Rename(temporary.Name(), path); err != nil {
+		return "", fmt.Errorf("install model %s: %w", entry.Name, err)
+	}
+
+	GetDebugSystem().logger.Info("model downloaded",
+		"name", entry.Name,
+		"source", source.Redacted(),
+		"bytes", counter.read,
+	)
+	return path, nil
+}
+
+// RemoveModel deletes a managed model and its descriptor.
+func RemoveModel(name string) error {
+	if !modelNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid model name %q", name)
+	}
+	path := filepath.Join(ModelDir(), name+modelExtension)
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("remove model %s: %w", name, err)
+	}
+	if err := os.Remove(path + ".json"); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("remove model descriptor %s: %w", name, err)
+	}
+	return nil
+}
+
+// openModelSource opens a registry or model location; anything that is not
+// http or https is read as a local path.
+func openModelSource(ctx context.Context, location *url.URL) (io.ReadCloser, error) {
+	switch location.Scheme {
+	case "http", "https":
+	case "file":
+		return os.Open(location.Path)
+	case "":
+		return os.Open(location.String())
+	default:
+		return nil, fmt.Errorf("unsupported scheme %q", location.Scheme)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, location.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode != http.StatusOK {
+		response.Body.Close()
+		return nil, fmt.Errorf("%s: %s", location.Redacted(), response.Status)
+	}
+	return response.Body, nil
+}
+
+type progressWriter struct {
+	read     int64
+	size     int64
+	progress func(read, size int64)
+}
+
+func (pw *progressWriter) Write(p []byte) (int, error) {
+	pw.read += int64(len(p))
+	if pw.progress != nil {
+		pw.progress(pw.read, pw.size)
+	}
+	return len(p), nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Collision policies decide what happens when an output file already
+// exists.
+const (
+	CollisionOverwrite = "overwrite"
+	CollisionSkip      = "skip"
+	CollisionVersion   = "version"
+)
+
+// Outcomes recorded for outputs that already existed.
+const (
+	collisionOverwritten = "overwritten"
+	collisionSkipped     = "skipped"
+	collisionVersioned   = "versioned"
+)
+
+// maxOutputVersions bounds the search for a free versioned name.
+const maxOutputVersions = 10000
+
+func validateCollisionPolicy(policy string) error {
+	switch policy {
+	case CollisionOverwrite, CollisionSkip, CollisionVersion:
+		return nil
+	}
+	return fmt.Errorf("unknown collision policy %q: expected %s, %s or %s",
+		policy, CollisionOverwrite, CollisionSkip, CollisionVersion)
+}
+
+// resolveOutputCollision applies policy to path. It returns the path to
+// write, empty when the output is skipped, and the outcome to record, empty
+// when path did not exist. A versioned path is created empty so concurrent
+// writers cannot claim the same version.
+func resolveOutputCollision(path, policy string) (string, string, error) {
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		return path, "", nil
+	} else if err != nil {
+		return "", "", fmt.Errorf("check %s: %w", path, err)
+	}
+
+	switch policy {
+	case CollisionSkip:
+		return "", collisionSkipped, nil
+	case CollisionVersion:
+		versioned, err := reserveVersionedPath(path)
+		if err != nil {
+			return "", "", err
+		}
+		return versioned, collisionVersioned, nil
+	}
+	return path, collisionOverwritten, nil
+}
+
+// reserveVersionedPath creates the first free name of the form
+// file-v2.png, file-v3.png and so on.
+func reserveVersionedPath(path string) (string, error) {
+	extension := filepath.Ext(path)
+	stem := strings.TrimSuffix(path, extension)
+	for version := 2; version < maxOutputVersions; version++ {
+		candidate := fmt.Sprintf("%s-v%d%s", stem, version, extension)
+		file, err := os.OpenFile(candidate, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("reserve %s: %w", candidate, err)
+		}
+		file.Close()
+		return candidate, nil
+	}
+	return "", fmt.Errorf("no free version of %s below v%d", path, maxOutputVersions)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// DefaultOutputNameTemplate names outputs after their source image.
+const DefaultOutputNameTemplate = "{name}"
+
+const fmeasureToken = "{fmeasure}"
+
+var (
+	outputNameTokens = []string{"{name}", "{algorithm}", "{preset}", "{date}", fmeasureToken}
+	outputNameToken  = regexp.MustCompile(`\{[^{}]*\}`)
+	unsafeNameChars  = strings.NewReplacer("/", "_", "\\", "_", ":", "_", "*", "_", "?", "_", "\"", "_", "<", "_", ">", "_", "|", "_")
+)
+
+// OutputNameFields are the values substituted into an output name
+// template. A nil FMeasure leaves {fmeasure} in place for a later render,
+// since the score is only known once the image is processed.
+type OutputNameFields struct {
+	Name      string
+	Algorithm string
+	Preset    string
+	Date      time.Time
+	FMeasure  *float64
+}
+
+// ValidateOutputNameTemplate rejects templates with unknown tokens or that
+// render to an empty name.
+func ValidateOutputNameTemplate(template string) error {
+	if strings.TrimSpace(template) == "" {
+		return fmt.Errorf("output name template is empty")
+	}
+	for _, token := range outputNameToken.FindAllString(template, -1) {
+		known := false
+		for _, candidate := range outputNameTokens {
+			known = known || token == candidate
+		}
+		if !known {
+			return fmt.Errorf("output name template: unknown token %s, expected one of %s", token, strings.Join(outputNameTokens, " "))
+		}
+	}
+	return nil
+}
+
+// RenderOutputName substitutes fields into template and returns a file name
+// without extension. Path separators and characters Windows forbids in
+// file names become underscores.
+func RenderOutputName(template string, fields OutputNameFields) string {
+	replacements := []string{
+		"{name}", fields.Name,
+		"{algorithm}", fields.Algorithm,
+		"{preset}", fields.Preset,
+		"{date}", fields.Date.Format("2006-01-02"),
+	}
+	if fields.FMeasure != nil {
+		replacements = append(replacements, fmeasureToken, fmt.Sprintf("%.4f", *fields.FMeasure))
+	}
+
+	name := strings.NewReplacer(replacements...).Replace(template)
+	name = strings.TrimSpace(unsafeNameChars.Replace(name))
+	if name == "" || name == "." || name == ".." {
+		return "output"
+	}
+	return name
+}
+
+// withFMeasure renders the {fmeasure} token left in a planned output path.
+func withFMeasure(path string, fmeasure float64) string {
+	if !strings.Contains(filepath.Base(path), fmeasureToken) {
+		return path
+	}
+	name := strings.ReplaceAll(filepath.Base(path), fmeasureToken, fmt.Sprintf("%.4f", fmeasure))
+	return filepath.Join(filepath.Dir(path), name)
+}
+
+// matchesPlannedOutput reports whether output is what planned becomes once
+// its {fmeasure} token is rendered, or a version of it written because
+// planned already existed.
+func matchesPlannedOutput(planned, output string) bool {
+	if planned == output {
+		return true
+	}
+	extension := filepath.Ext(planned)
+	parts := strings.Split(strings.TrimSuffix(planned, extension), fmeasureToken)
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	pattern := "^" + strings.Join(parts, `\d+\.\d{4}`) + `(-v\d+)?` + regexp.QuoteMeta(extension) + "$"
+	matched, _ := regexp.MatchString(pattern, output)
+	return matched
+}
+
+// outputNamePreset names the parameter source of a command-line run: the
+// stem of a parameter file, "custom" for inline JSON and "default" without
+// one.
+func outputNamePreset(source string) string {
+	switch {
+	case source == "":
+		return "default"
+	case strings.HasPrefix(strings.TrimSpace(source), "{"):
+		return "custom"
+	}
+	return strings.TrimSuffix(filepath.Base(source), filepath.Ext(source))
+}
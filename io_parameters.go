@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ParameterFieldErrors collects every invalid field found in a parameter set
+// so the user can fix them all at once.
+type ParameterFieldErrors []*ValidationError
+
+func (pfe ParameterFieldErrors) Error() string {
+	messages := make([]string, len(pfe))
+	for i, fieldErr := range pfe {
+		messages[i] = fieldErr.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
+func EncodeParametersJSON(params *OtsuParameters) (string, error) {
+	if params == nil {
+		return "", fmt.Errorf("encode parameters: parameters are nil")
+	}
+
+	data, err := json.MarshalIndent(params, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("encode parameters: %w", err)
+	}
+
+	return string(data), nil
+}
+
+// ParameterHash returns a SHA-256 digest identifying params, stable for as
+// long as the OtsuParameters field set is unchanged.
+func ParameterHash(params *OtsuParameters) (string, error) {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return "", fmt.Errorf("hash parameters: %w", err)
+	}
+
+	return sha256Hex(data), nil
+}
+
+// DecodeParametersJSON overlays the JSON document on top of base, so a
+// partial parameter set only changes the fields it mentions.
+func DecodeParametersJSON(text string, base *OtsuParameters) (*OtsuParameters, error) {
+	if base == nil {
+		return nil, fmt.Errorf("decode parameters: base parameters are nil")
+	}
+
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, fmt.Errorf("decode parameters: input is empty")
+	}
+
+	decoded := *base
+
+	decoder := json.NewDecoder(strings.NewReader(text))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(&decoded); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return nil, ParameterFieldErrors{{
+				Context: "parameter JSON",
+				Field:   typeErr.Field,
+				Value:   typeErr.Value,
+				Reason:  fmt.Sprintf("expected %s", typeErr.Type),
+			}}
+		}
+		return nil, fmt.Errorf("decode parameters: %w", err)
+	}
+
+	if fieldErrors := validateParameterFields(&decoded); len(fieldErrors) > 0 {
+		return nil, ParameterFieldErrors(fieldErrors)
+	}
+
+	return &decoded, nil
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"io"
+	"strings"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+// A4 in PDF points.
+const (
+	pdfA4Width  = 595.0
+	pdfA4Height = 842.0
+)
+
+// pdfMaxImageSide caps embedded images, about 300 dpi across an A4 page.
+const pdfMaxImageSide = 2480
+
+// pdfDocument writes a PDF of equally sized pages with Helvetica text,
+// images and paths. It covers what the reports and vector exports need and
+// nothing more: no font embedding and only Latin-1 text.
+type pdfDocument struct {
+	width, height float64
+	objects       [][]byte
+	pages         []*pdfPage
+}
+
+type pdfPage struct {
+	content bytes.Buffer
+	images  []int
+}
+
+func newPDFDocument(width, height float64) *pdfDocument {
+	return &pdfDocument{width: width, height: height}
+}
+
+// addObject stores an object body and returns its object number.
+func (d *pdfDocument) addObject(body []byte) int {
+	d.objects = append(d.objects, body)
+	return len(d.objects)
+}
+
+func (d *pdfDocument) NewPage() *pdfPage {
+	page := &pdfPage{}
+	d.pages = append(d.pages, page)
+	return page
+}
+
+// AddImage embeds img as a deflated gray or RGB image, downscaled to at
+// most pdfMaxImageSide pixels a side, and returns its reference.
+func (d *pdfDocument) AddImage(img image.Image) int {
+	bounds := img.Bounds()
+	if side := max(bounds.Dx(), bounds.Dy()); side > pdfMaxImageSide {
+		scaled := newImageLike(img, image.Rect(0, 0,
+			max(1, bounds.Dx()*pdfMaxImageSide/side), max(1, bounds.Dy()*pdfMaxImageSide/side)))
+		xdraw.ApproxBiLinear.Scale(scaled, scaled.Bounds(), img, bounds, xdraw.Src, nil)
+		img = scaled
+		bounds = img.Bounds()
+	}
+
+	colorSpace, components := "/DeviceRGB", 3
+	if _, ok := img.(*image.Gray); ok {
+		colorSpace, components = "/DeviceGray", 1
+	}
+
+	raw := make([]byte, 0, bounds.Dx()*bounds.Dy()*components)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, _ := img.At(x, y).RGBA()
+			if components == 1 {
+				raw = append(raw, byte(r>>8))
+			} else {
+				raw = append(raw, byte(r>>8), byte(g>>8), byte(b>>8))
+			}
+		}
+	}
+
+	return d.addObject(pdfStream(fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace %s /BitsPerComponent 8",
+		bounds.Dx(), bounds.Dy(), colorSpace), raw))
+}
+
+// Text draws text with its baseline at x, y from the bottom left corner.
+func (p *pdfPage) Text(x, y, size float64, bold bool, text string) {
+	font := "/F1"
+	if bold {
+		font = "/F2"
+	}
+	fmt.Fprintf(&p.content, "BT %s %.1f Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x, y, pdfString(text))
+}
+
+// Image draws an image added with AddImage into the given box.
+func (p *pdfPage) Image(ref int, x, y, width, height float64) {
+	p.images = append(p.images, ref)
+	fmt.Fprintf(&p.content, "q %.2f 0 0 %.2f %.2f %.2f cm /Im%d Do Q\n", width, height, x, y, ref)
+}
+
+// Path appends raw path operators, such as those of a traced outline.
+func (p *pdfPage) Path(operators string) {
+	p.content.WriteString(operators)
+}
+
+func (d *pdfDocument) Write(w io.Writer) error {
+	fonts := d.addObject([]byte("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>"))
+	boldFont := d.addObject([]byte("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>"))
+	pagesRef := len(d.objects) + 2*len(d.pages) + 1
+
+	kids := make([]string, 0, len(d.pages))
+	for _, page := range d.pages {
+		content := d.addObject(pdfStream("", page.content.Bytes()))
+		var xobjects strings.Builder
+		for _, ref := range page.images {
+			fmt.Fprintf(&xobjects, " /Im%d %d 0 R", ref, ref)
+		}
+		pageRef := d.addObject([]byte(fmt.Sprintf(
+			"<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %.2f %.2f] /Contents %d 0 R /Resources << /Font << /F1 %d 0 R /F2 %d 0 R >> /XObject <<%s >> >> >>",
+			pagesRef, d.width, d.height, content, fonts, boldFont, xobjects.String())))
+		kids = append(kids, fmt.Sprintf("%d 0 R", pageRef))
+	}
+	d.addObject([]byte(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(kids))))
+	catalog := d.addObject([]byte(fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pagesRef)))
+
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(d.objects))
+	for i, body := range d.objects {
+		offsets[i] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n", i+1)
+		out.Write(body)
+		out.WriteString("\nendobj\n")
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(d.objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(d.objects)+1, catalog, xref)
+
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+// pdfStream deflates data into a stream object with the extra dictionary
+// entries of dictionary.
+func pdfStream(dictionary string, data []byte) []byte {
+	var compressed bytes.Buffer
+	writer := zlib.NewWriter(&compressed)
+	writer.Write(data)
+	writer.Close()
+
+	var body bytes.Buffer
+	fmt.Fprintf(&body, "<< %s /Filter /FlateDecode /Length %d >>\nstream\n", dictionary, compressed.Len())
+	body.Write(compressed.Bytes())
+	body.WriteString("\nendstream")
+	return body.Bytes()
+}
+
+// pdfString escapes text for a literal string in WinAnsi encoding; runes
+// outside Latin-1 become question marks.
+func pdfString(text string) string {
+	var b strings.Builder
+	for _, r := range strings.ReplaceAll(text, "→", "->") {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20 || r > 0xFF:
+			b.WriteByte('?')
+		default:
+			b.WriteByte(byte(r))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,390 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/image/tiff"
+)
+
+const (
+	// ProvenanceFormat versions the record; verifiers reject other formats.
+	ProvenanceFormat = "otsu-obliterator-provenance/1"
+
+	provenanceNamespace          = "urn:otsu-obliterator:provenance:1"
+	provenanceSignatureAlgorithm = "ed25519"
+)
+
+// ErrNoProvenance is returned for images without an embedded record.
+var ErrNoProvenance = errors.New("no provenance record embedded")
+
+// ProvenanceRecord describes how an output image was made. The output is
+// identified by the hash of its decoded pixels, so embedding the record does
+// not change what it describes.
+type ProvenanceRecord struct {
+	Format        string           `json:"format"`
+	Software      string           `json:"software"`
+	AppVersion    string           `json:"app_version"`
+	CreatedAt     time.Time        `json:"created_at"`
+	Source        ProvenanceSource `json:"source"`
+	Output        ProvenanceOutput `json:"output"`
+	Algorithm     string           `json:"algorithm"`
+	Steps         []string         `json:"steps"`
+	ParameterHash string           `json:"parameter_hash"`
+	Parameters    json.RawMessage  `json:"parameters,omitempty"`
+}
+
+type ProvenanceSource struct {
+	Name   string `json:"name"`
+	SHA256 string `json:"sha256"`
+}
+
+type ProvenanceOutput struct {
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	PixelSHA256 string `json:"pixel_sha256"`
+}
+
+// SignedProvenance is the record as embedded: its exact JSON text, which the
+// signature covers, and the key that made the signature.
+type SignedProvenance struct {
+	Record    *ProvenanceRecord
+	JSON      []byte
+	PublicKey ed25519.PublicKey
+	Signature []byte
+}
+
+// NewProvenanceRecord describes an output produced from the source file
+// bytes with params. The output hash is filled in by SignProvenance.
+func NewProvenanceRecord(sourcePath string, source []byte, params *OtsuParameters) (*ProvenanceRecord, error) {
+	parameterHash, err := ParameterHash(params)
+	if err != nil {
+		return nil, err
+	}
+	encoded, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("encode parameters: %w", err)
+	}
+
+	return &ProvenanceRecord{
+		Format:        ProvenanceFormat,
+		Software:      AppName,
+		AppVersion:    AppVersion,
+		CreatedAt:     time.Now().UTC().Truncate(time.Second),
+		Source:        ProvenanceSource{Name: filepath.Base(sourcePath), SHA256: sha256Hex(source)},
+		Algorithm:     processingMethodName(params),
+		Steps:         provenanceSteps(params),
+		ParameterHash: parameterHash,
+		Parameters:    encoded,
+	}, nil
+}
+
+// provenanceSteps lists the stages params run, in pipeline order.
+func provenanceSteps(params *OtsuParameters) []string {
+	var steps []string
+	add := func(enabled bool, step string) {
+		if enabled {
+			steps = append(steps, step)
+		}
+	}
+
+	add(params.StainSuppression, fmt.Sprintf("stain_suppression hue %.0f-%.0f strength %.2f", params.StainHueMin, params.StainHueMax, params.StainStrength))
+	add(params.DropoutRed || params.DropoutGreen || params.DropoutBlue, fmt.Sprintf("color_dropout tolerance %.0f", params.DropoutTolerance))
+	channel := params.GrayscaleChannel
+	if channel == "" {
+		channel = GrayChannelLuminance
+	}
+	steps = append(steps, "grayscale "+strings.ToLower(channel))
+	add(params.PerspectiveCorrection, "perspective_correction")
+	add(params.CylindricalDewarp, fmt.Sprintf("cylindrical_dewarp curvature %.2f", params.DewarpCurvature))
+	for _, stage := range params.ExternalStages {
+		add(stage.Position == ExternalStagePre, "external "+stage.String())
+	}
+	add(hasToneAdjustment(params), "tone_adjustment")
+	add(params.ShadowRemoval, fmt.Sprintf("shadow_removal %s strength %.2f", params.ShadowRemovalMethod, params.ShadowRemovalStrength))
+	add(params.HomomorphicFiltering, "homomorphic_filtering")
+	add(params.AnisotropicDiffusion, fmt.Sprintf("anisotropic_diffusion %d iterations kappa %.0f", params.DiffusionIterations, params.DiffusionKappa))
+	add(params.GaussianPreprocessing, fmt.Sprintf("gaussian_blur sigma %.2f", params.SmoothingStrength))
+	add(params.ApplyContrastEnhancement, "contrast_enhancement")
+	steps = append(steps, processingMethodName(params))
+	if params.MorphologicalPostProcess {
+		for _, op := range morphOperations(params) {
+			steps = append(steps, "morphology "+op.String())
+		}
+	}
+	add(params.FillHoles, fmt.Sprintf("fill_holes max area %d", params.MaxHoleArea))
+	add(params.BridgeGaps, fmt.Sprintf("bridge_gaps max gap %d", params.MaxGapSize))
+	add(hasPhotoZones(params), "photo_zones "+strings.ToLower(params.PhotoZones))
+	for _, stage := range params.ExternalStages {
+		add(stage.Position == ExternalStagePost, "external "+stage.String())
+	}
+	return steps
+}
+
+// SignProvenance records the pixels of the encoded output in record, signs
+// it with key and returns the output with the record embedded as XMP.
+// extension selects PNG or TIFF.
+func SignProvenance(encoded []byte, extension string, record *ProvenanceRecord, key ed25519.PrivateKey) ([]byte, error) {
+	width, height, pixelHash, err := provenancePixelHash(encoded)
+	if err != nil {
+		return nil, err
+	}
+	record.Output = ProvenanceOutput{Width: width, Height: height, PixelSHA256: pixelHash}
+
+	recordJSON, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("encode provenance: %w", err)
+	}
+	packet := provenanceXMPPacket(recordJSON, key.Public().(ed25519.PublicKey), ed25519.Sign(key, recordJSON))
+
+	if !canCarryProvenance(extension) {
+		return nil, fmt.Errorf("provenance: %s output cannot carry a manifest; use png or tif", extension)
+	}
+	return EmbedXMPPacket(encoded, extension, packet)
+}
+
+// ProvenanceVerification is the outcome of VerifyProvenance. Trusted is only
+// meaningful when a trusted key was given.
+type ProvenanceVerification struct {
+	Signed         *SignedProvenance
+	SignatureValid bool
+	PixelsMatch    bool
+	Trusted        bool
+	SourceMatches  *bool
+}
+
+// VerifyProvenance checks the record embedded in data against its signature
+// and the image's pixels, the signing key against trusted when it is not
+// nil, and the source hash against source when it is not nil.
+func VerifyProvenance(data []byte, trusted ed25519.PublicKey, source []byte) (*ProvenanceVerification, error) {
+	signed, err := ExtractProvenance(data)
+	if err != nil {
+		return nil, err
+	}
+
+	verification := &ProvenanceVerification{
+		Signed:         signed,
+		SignatureValid: ed25519.Verify(signed.PublicKey, signed.JSON, signed.Signature),
+		Trusted:        trusted != nil && trusted.Equal(signed.PublicKey),
+	}
+
+	width, height, pixelHash, err := provenancePixelHash(data)
+	if err != nil {
+		return nil, err
+	}
+	output := signed.Record.Output
+	verification.PixelsMatch = output.Width == width && output.Height == height && output.PixelSHA256 == pixelHash
+
+	if source != nil {
+		matches := sha256Hex(source) == signed.Record.Source.SHA256
+		verification.SourceMatches = &matches
+	}
+	return verification, nil
+}
+
+// ExtractProvenance reads the signed record embedded in a PNG or TIFF file.
+func ExtractProvenance(data []byte) (*SignedProvenance, error) {
+	packet, err := ReadXMPPacket(data)
+	if err != nil {
+		return nil, fmt.Errorf("provenance: %w", err)
+	}
+	if packet == nil {
+		return nil, ErrNoProvenance
+	}
+	return parseProvenanceXMP(packet)
+}
+
+// provenancePixelHash hashes the size and the gray levels of the decoded
+// image, independent of how the file encodes them.
+func provenancePixelHash(data []byte) (int, int, string, error) {
+	var img image.Image
+	var err error
+	if bytes.HasPrefix(data, pngSignature) {
+		img, err = png.Decode(bytes.NewReader(data))
+	} else {
+		img, err = tiff.Decode(bytes.NewReader(data))
+	}
+	if err != nil {
+		return 0, 0, "", fmt.Errorf("provenance: decode image: %w", err)
+	}
+
+	bounds := img.Bounds()
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%dx%d\n", bounds.Dx(), bounds.Dy())
+	row := make([]byte, bounds.Dx())
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			row[x-bounds.Min.X] = color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y
+		}
+		hash.Write(row)
+	}
+	return bounds.Dx(), bounds.Dy(), hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func provenanceXMPPacket(recordJSON []byte, publicKey ed25519.PublicKey, signature []byte) string {
+	return buildXMPPacket([]xmpProperty{
+		{name: "otsu:Manifest", value: string(recordJSON)},
+		{name: "otsu:SignatureAlgorithm", value: provenanceSignatureAlgorithm},
+		{name: "otsu:PublicKey", value: base64.StdEncoding.EncodeToString(publicKey)},
+		{name: "otsu:Signature", value: base64.StdEncoding.EncodeToString(signature)},
+	})
+}
+
+func parseProvenanceXMP(packet []byte) (*SignedProvenance, error) {
+	fields := make(map[string]string)
+	decoder := xml.NewDecoder(bytes.NewReader(packet))
+	var current string
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("provenance: read XMP packet: %w", err)
+		}
+		switch token := token.(type) {
+		case xml.StartElement:
+			current = ""
+			if token.Name.Space == provenanceNamespace {
+				current = token.Name.Local
+			}
+		case xml.CharData:
+			if current != "" {
+				fields[current] += string(token)
+			}
+		case xml.EndElement:
+			current = ""
+		}
+	}
+
+	if fields["Manifest"] == "" {
+		return nil, ErrNoProvenance
+	}
+	if algorithm := fields["SignatureAlgorithm"]; algorithm != provenanceSignatureAlgorithm {
+		return nil, fmt.Errorf("provenance: unsupported signature algorithm %q", algorithm)
+	}
+
+	signed := &SignedProvenance{JSON: []byte(fields["Manifest"])}
+	publicKey, err := base64.StdEncoding.DecodeString(strings.TrimSpace(fields["PublicKey"]))
+	if err != nil || len(publicKey) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("provenance: invalid public key")
+	}
+	signed.PublicKey = publicKey
+	if signed.Signature, err = base64.StdEncoding.DecodeString(strings.TrimSpace(fields["Signature"])); err != nil {
+		return nil, fmt.Errorf("provenance: invalid signature encoding: %w", err)
+	}
+
+	signed.Record = &ProvenanceRecord{}
+	if err := json.Unmarshal(signed.JSON, signed.Record); err != nil {
+		return nil, fmt.Errorf("provenance: decode manifest: %w", err)
+	}
+	if signed.Record.Format != ProvenanceFormat {
+		return nil, fmt.Errorf("provenance: unsupported manifest format %q", signed.Record.Format)
+	}
+	return signed, nil
+}
+
+// GenerateProvenanceKey writes a new Ed25519 private key to path and its
+// public key to the path with .pub appended, both PEM encoded.
+func GenerateProvenanceKey(path string) (ed25519.PublicKey, error) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("generate signing key: %w", err)
+	}
+
+	privateDER, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("encode signing key: %w", err)
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("encode public key: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("create signing key: %w", err)
+	}
+	if err := pem.Encode(file, &pem.Block{Type: "PRIVATE KEY", Bytes: privateDER}); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("write signing key: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return nil, fmt.Errorf("write signing key: %w", err)
+	}
+
+	if err := os.WriteFile(path+".pub", pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}), 0644); err != nil {
+		return nil, fmt.Errorf("write public key: %w", err)
+	}
+	return publicKey, nil
+}
+
+// LoadProvenanceSigningKey reads a PEM encoded Ed25519 private key.
+func LoadProvenanceSigningKey(path string) (ed25519.PrivateKey, error) {
+	block, err := readPEMFile(path, "PRIVATE KEY")
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("signing key %s: %w", path, err)
+	}
+	private, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("signing key %s: not an Ed25519 key", path)
+	}
+	return private, nil
+}
+
+// LoadProvenancePublicKey reads a PEM encoded Ed25519 public key.
+func LoadProvenancePublicKey(path string) (ed25519.PublicKey, error) {
+	block, err := readPEMFile(path, "PUBLIC KEY")
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("public key %s: %w", path, err)
+	}
+	public, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("public key %s: not an Ed25519 key", path)
+	}
+	return public, nil
+}
+
+func readPEMFile(path, blockType string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read key: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != blockType {
+		return nil, fmt.Errorf("key %s: expected a PEM %s block", path, blockType)
+	}
+	return block, nil
+}
+
+// ProvenanceKeyFingerprint identifies a signing key in reports.
+func ProvenanceKeyFingerprint(key ed25519.PublicKey) string {
+	sum := sha256.Sum256(key)
+	return "SHA256:" + hex.EncodeToString(sum[:16])
+}
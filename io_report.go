@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"math"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	reportMargin     = 50.0
+	reportLineHeight = 14.0
+	reportFontSize   = 10.0
+)
+
+// QAReport is the printable record of one processing run: the original,
+// the result, the parameters and the metrics, for archives that keep paper
+// QA records.
+type QAReport struct {
+	Source    string
+	CreatedAt time.Time
+	Original  image.Image
+	Result    image.Image
+	Params    *OtsuParameters
+	Metrics   *MetricsReport
+	Warnings  []QualityWarning
+
+	// Summary describes the run in plain words; see summarizeRun.
+	Summary string
+
+	// Notes are listed and pinned by number on the original and the
+	// result.
+	Notes []Annotation
+}
+
+// reportWriter lays out lines top to bottom and starts a page when one is
+// full.
+type reportWriter struct {
+	document *pdfDocument
+	page     *pdfPage
+	y        float64
+}
+
+func (rw *reportWriter) newPage() {
+	rw.page = rw.document.NewPage()
+	rw.y = pdfA4Height - reportMargin
+}
+
+func (rw *reportWriter) line(indent, size float64, bold bool, text string) {
+	if rw.y < reportMargin+reportLineHeight {
+		rw.newPage()
+	}
+	rw.page.Text(reportMargin+indent, rw.y, size, bold, text)
+	rw.y -= reportLineHeight * size / reportFontSize
+}
+
+func (rw *reportWriter) heading(text string) {
+	rw.y -= reportLineHeight / 2
+	rw.line(0, 13, true, text)
+}
+
+// wrapped breaks text at spaces to fit the page, estimating Helvetica at
+// half the font size per character.
+func (rw *reportWriter) wrapped(indent float64, text string) {
+	limit := int((pdfA4Width - 2*reportMargin - indent) / (reportFontSize / 2))
+	var current string
+	for _, word := range strings.Fields(text) {
+		if current != "" && len(current)+1+len(word) > limit {
+			rw.line(indent, reportFontSize, false, current)
+			current = ""
+		}
+		if current != "" {
+			current += " "
+		}
+		current += word
+	}
+	if current != "" {
+		rw.line(indent, reportFontSize, false, current)
+	}
+}
+
+// imagePage fills a page with img under a heading, keeping its aspect
+// ratio, and marks the pixel of every note with its number.
+func (rw *reportWriter) imagePage(title string, img image.Image, notes []Annotation) {
+	rw.newPage()
+	rw.line(0, 13, true, title)
+	if img == nil {
+		rw.line(0, reportFontSize, false, "Not available.")
+		return
+	}
+
+	bounds := img.Bounds()
+	boxWidth := pdfA4Width - 2*reportMargin
+	boxHeight := rw.y - reportMargin - reportLineHeight
+	scale := math.Min(boxWidth/float64(bounds.Dx()), boxHeight/float64(bounds.Dy()))
+	width, height := float64(bounds.Dx())*scale, float64(bounds.Dy())*scale
+	left, bottom := reportMargin+(boxWidth-width)/2, rw.y-height
+	rw.page.Image(rw.document.AddImage(img), left, bottom, width, height)
+	for _, note := range notes {
+		x := left + (float64(note.X-bounds.Min.X)+0.5)*scale
+		y := bottom + height - (float64(note.Y-bounds.Min.Y)+0.5)*scale
+		rw.page.Text(x, y, 9, true, fmt.Sprintf("[%d]", note.ID))
+	}
+	rw.line(0, 8, false, fmt.Sprintf("%dx%d pixels", bounds.Dx(), bounds.Dy()))
+}
+
+// PDF lays out the report on A4 pages: a summary with the metrics, quality
+// warnings, notes and parameters, then the original and the result on a
+// page each.
+func (r *QAReport) PDF() ([]byte, error) {
+	rw := &reportWriter{document: newPDFDocument(pdfA4Width, pdfA4Height)}
+	rw.newPage()
+
+	rw.line(0, 16, true, AppName+" QA Report")
+	rw.y -= reportLineHeight / 2
+	rw.line(0, reportFontSize, false, "Source: "+r.Source)
+	rw.line(0, reportFontSize, false, "Created: "+r.CreatedAt.Format("2006-01-02 15:04:05 MST"))
+	rw.line(0, reportFontSize, false, fmt.Sprintf("Software: %s %s", AppName, AppVersion))
+	if r.Params != nil {
+		rw.line(0, reportFontSize, false, "Algorithm: "+methodAlgorithm(r.Params))
+	}
+	if r.Summary != "" {
+		rw.heading("Summary")
+		rw.wrapped(0, r.Summary)
+	}
+
+	rw.heading("Metrics")
+	if r.Metrics == nil {
+		rw.line(0, reportFontSize, false, "No metrics were computed.")
+	} else {
+		for _, metric := range r.Metrics.namedValues() {
+			rw.line(0, reportFontSize, false, fmt.Sprintf("%s: %.4f", metric.name, metric.value))
+		}
+	}
+
+	rw.heading("Quality Warnings")
+	if len(r.Warnings) == 0 {
+		rw.line(0, reportFontSize, false, "None.")
+	}
+	for _, warning := range r.Warnings {
+		rw.line(0, reportFontSize, true, warning.Message)
+		rw.wrapped(12, warning.Suggestion)
+	}
+
+	if len(r.Notes) > 0 {
+		rw.heading("Notes")
+		for _, note := range r.Notes {
+			by := ""
+			if note.Author != "" {
+				by = " by " + note.Author
+			}
+			rw.line(0, reportFontSize, true, fmt.Sprintf("[%d] at (%d, %d)%s", note.ID, note.X, note.Y, by))
+			rw.wrapped(12, note.Text)
+		}
+	}
+
+	rw.heading("Parameters")
+	for _, line := range listParameters(r.Params) {
+		rw.line(0, reportFontSize, false, line)
+	}
+
+	rw.imagePage("Original", r.Original, r.Notes)
+	rw.imagePage("Result", r.Result, r.Notes)
+
+	for i, page := range rw.document.pages {
+		page.Text(reportMargin, reportMargin/2, 8, false, fmt.Sprintf("%s - page %d of %d", r.Source, i+1, len(rw.document.pages)))
+	}
+
+	var out bytes.Buffer
+	if err := rw.document.Write(&out); err != nil {
+		return nil, fmt.Errorf("write report: %w", err)
+	}
+	return out.Bytes(), nil
+}
+
+func (r *QAReport) WritePDF(path string) error {
+	data, err := r.PDF()
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("write report %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"io/fs"
+	"os"
+)
+
+// ResultPackage is a saved result with the sidecar written next to it and
+// the provenance record embedded in it, as the result viewer shows them.
+// Sidecar and Provenance are nil when the result has none.
+type ResultPackage struct {
+	Path       string
+	Image      image.Image
+	Sidecar    *OutputSidecar
+	Provenance *SignedProvenance
+}
+
+func LoadResultPackage(path string) (*ResultPackage, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read result: %w", err)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decode result %s: %w", path, err)
+	}
+	pkg := &ResultPackage{Path: path, Image: img}
+
+	sidecarPath := SidecarPath(path)
+	sidecarData, err := os.ReadFile(sidecarPath)
+	switch {
+	case err == nil:
+		var sidecar OutputSidecar
+		if err := json.Unmarshal(sidecarData, &sidecar); err != nil {
+			return nil, fmt.Errorf("read sidecar %s: %w", sidecarPath, err)
+		}
+		pkg.Sidecar = &sidecar
+	case !errors.Is(err, fs.ErrNotExist):
+		return nil, fmt.Errorf("read sidecar: %w", err)
+	}
+
+	provenance, err := ExtractProvenance(data)
+	switch {
+	case err == nil:
+		pkg.Provenance = provenance
+	case !errors.Is(err, ErrNoProvenance):
+		GetDebugSystem().logger.Warn("unreadable provenance record", "path", path, "error", err.Error())
+	}
+	return pkg, nil
+}
+
+// Parameters are those the sidecar records, or else those of the embedded
+// provenance record; nil when neither has them.
+func (rp *ResultPackage) Parameters() *OtsuParameters {
+	if rp.Sidecar != nil && rp.Sidecar.Parameters != nil {
+		return rp.Sidecar.Parameters
+	}
+	if rp.Provenance != nil && len(rp.Provenance.Record.Parameters) > 0 {
+		params := DefaultOtsuParameters()
+		if err := json.Unmarshal(rp.Provenance.Record.Parameters, params); err == nil {
+			return params
+		}
+	}
+	return nil
+}
+
+// ResultPoint maps a working image pixel, as notes and touch-ups record
+// them, to the pixel of the saved result, which may have been cropped.
+func (rp *ResultPackage) ResultPoint(p image.Point) image.Point {
+	if rp.Sidecar != nil && rp.Sidecar.Crop != nil {
+		p = p.Sub(image.Pt(rp.Sidecar.Crop.X, rp.Sidecar.Crop.Y))
+	}
+	return p.Add(rp.Image.Bounds().Min)
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// ScannerDevice is a scanner reported by the platform backend.
+type ScannerDevice struct {
+	ID   string
+	Name string
+}
+
+type ScanRequest struct {
+	DeviceID  string
+	DPI       int
+	Grayscale bool
+}
+
+// ScannerBackend drives the platform scanning tool. Both backends shell out
+// to a command-line front end so no scanner SDK is linked into the binary.
+type ScannerBackend interface {
+	Name() string
+	ListDevices(ctx context.Context) ([]ScannerDevice, error)
+	// Scan returns the encoded image and its extension including the dot.
+	Scan(ctx context.Context, request ScanRequest) ([]byte, string, error)
+}
+
+var ScanResolutions = []int{150, 200, 300, 400, 600}
+
+// DetectScannerBackend returns the backend for this platform, or an error
+// explaining which tool to install.
+func DetectScannerBackend() (ScannerBackend, error) {
+	switch runtime.GOOS {
+	case "linux":
+		if _, err := exec.LookPath("scanimage"); err != nil {
+			return nil, fmt.Errorf("scanimage not found: install SANE, e.g. `sudo apt-get install sane-utils`")
+		}
+		return &saneBackend{}, nil
+	case "darwin":
+		if _, err := exec.LookPath("scanline"); err != nil {
+			return nil, fmt.Errorf("scanline not found: install it to scan through ImageCapture, e.g. `brew install scanline`")
+		}
+		return &imageCaptureBackend{}, nil
+	default:
+		return nil, fmt.Errorf("scanning is not supported on %s", runtime.GOOS)
+	}
+}
+
+func runScannerCommand(ctx context.Context, name string, args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stderr = &stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		message := strings.TrimSpace(stderr.String())
+		if message == "" {
+			message = err.Error()
+		}
+		return nil, fmt.Errorf("%s: %s", name, message)
+	}
+
+	return output, nil
+}
+
+// saneBackend uses scanimage from sane-utils.
+type saneBackend struct{}
+
+func (sb *saneBackend) Name() string {
+	return "SANE"
+}
+
+func (sb *saneBackend) ListDevices(ctx context.Context) ([]ScannerDevice, error) {
+	output, err := runScannerCommand(ctx, "scanimage", "--formatted-device-list=%d\t%v %m%n")
+	if err != nil {
+		return nil, fmt.Errorf("list scanners: %w", err)
+	}
+
+	var devices []ScannerDevice
+	for _, line := range strings.Split(string(output), "\n") {
+		id, name, found := strings.Cut(strings.TrimSpace(line), "\t")
+		if !found || id == "" {
+			continue
+		}
+		devices = append(devices, ScannerDevice{ID: id, Name: name})
+	}
+	return devices, nil
+}
+
+func (sb *saneBackend) Scan(ctx context.Context, request ScanRequest) ([]byte, string, error) {
+	args := []string{"--format=png", "--resolution", strconv.Itoa(request.DPI)}
+	if request.DeviceID != "" {
+		args = append(args, "--device-name", request.DeviceID)
+	}
+	if request.Grayscale {
+		args = append(args, "--mode", "Gray")
+	}
+
+	data, err := runScannerCommand(ctx, "scanimage", args...)
+	if err != nil {
+		return nil, "", fmt.Errorf("scan: %w", err)
+	}
+	keepScan(data, ".png")
+	return data, ".png", nil
+}
+
+// imageCaptureBackend uses the scanline tool, which drives ImageCapture.
+type imageCaptureBackend struct{}
+
+func (ib *imageCaptureBackend) Name() string {
+	return "ImageCapture"
+}
+
+func (ib *imageCaptureBackend) ListDevices(ctx context.Context) ([]ScannerDevice, error) {
+	output, err := runScannerCommand(ctx, "scanline", "-list")
+	if err != nil {
+		return nil, fmt.Errorf("list scanners: %w", err)
+	}
+
+	var devices []ScannerDevice
+	for _, line := range strings.Split(string(output), "\n") {
+		name := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "*"))
+		if name == "" || strings.HasSuffix(name, ":") {
+			continue
+		}
+		devices = append(devices, ScannerDevice{ID: name, Name: name})
+	}
+	return devices, nil
+}
+
+func (ib *imageCaptureBackend) Scan(ctx context.Context, request ScanRequest) ([]byte, string, error) {
+	cache := GetArtifactCache()
+	dir, err := cache.NewEntry("scan")
+	if err != nil {
+		return nil, "", fmt.Errorf("scan: %w", err)
+	}
+	defer cache.Remove(dir)
+
+	args := []string{"-flatbed", "-jpeg", "-resolution", strconv.Itoa(request.DPI), "-dir", dir, "-name", "scan"}
+	if request.DeviceID != "" {
+		args = append(args, "-scanner", request.DeviceID)
+	}
+
+	if _, err := runScannerCommand(ctx, "scanline", args...); err != nil {
+		return nil, "", fmt.Errorf("scan: %w", err)
+	}
+
+	matches, _ := filepath.Glob(filepath.Join(dir, "scan*.jp*g"))
+	if len(matches) == 0 {
+		return nil, "", fmt.Errorf("scan: scanline produced no image")
+	}
+
+	data, err := os.ReadFile(matches[0])
+	if err != nil {
+		return nil, "", fmt.Errorf("scan: %w", err)
+	}
+	keepScan(data, ".jpg")
+	return data, ".jpg", nil
+}
+
+// keepScan stores a copy of a scan in the cache until it is evicted, so it
+// survives a crash before the result is saved. Failures only cost the copy.
+func keepScan(data []byte, extension string) {
+	cache := GetArtifactCache()
+	dir, err := cache.NewEntry("scan")
+	if err != nil {
+		GetDebugSystem().logger.Warn("scan not kept", "error", err.Error())
+		return
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "scan"+extension), data, 0644); err != nil {
+		GetDebugSystem().logger.Warn("scan not kept", "error", err.Error())
+		cache.Remove(dir)
+		return
+	}
+	cache.Release(dir)
+}
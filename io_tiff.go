@@ -0,0 +1,315 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"io"
+	"math"
+)
+
+// TIFF tags read when inspecting a file and written for Group 4 pages.
+const (
+	tiffNewSubfileTypeTag  = 254
+	tiffImageWidthTag      = 256
+	tiffImageLengthTag     = 257
+	tiffBitsPerSampleTag   = 258
+	tiffCompressionTag     = 259
+	tiffPhotometricTag     = 262
+	tiffStripOffsetsTag    = 273
+	tiffSamplesPerPixelTag = 277
+	tiffRowsPerStripTag    = 278
+	tiffStripByteCountsTag = 279
+	tiffResolutionUnitTag  = 296
+	tiffPageNumberTag      = 297
+
+	tiffTypeShort = 3
+	tiffTypeLong  = 4
+
+	// tiffCompressionGroup4 is CCITT T.6 and tiffPhotometricWhiteIsZero
+	// the interpretation fax and archival scans use with it.
+	tiffCompressionGroup4      = 4
+	tiffPhotometricWhiteIsZero = 0
+
+	// maxTIFFPages stops directory chains that loop back on themselves.
+	maxTIFFPages = 10000
+
+	// tiffDefaultDPI is the resolution tiff.Encode writes; embedImageDPI
+	// replaces it.
+	tiffDefaultDPI = 72
+)
+
+func isTIFFData(data []byte) bool {
+	return bytes.HasPrefix(data, []byte("II*\x00")) || bytes.HasPrefix(data, []byte("MM\x00*"))
+}
+
+// tiffDirectories returns the byte order of a TIFF file and the offsets of
+// its image directories, one per page.
+func tiffDirectories(data []byte) (binary.ByteOrder, []int, error) {
+	if !isTIFFData(data) || len(data) < 8 {
+		return nil, nil, truncatedError("TIFF", "header", len(data))
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	if data[0] == 'M' {
+		order = binary.BigEndian
+	}
+
+	var offsets []int
+	seen := make(map[int]bool)
+	for offset := int(order.Uint32(data[4:])); offset != 0; {
+		if seen[offset] || len(offsets) == maxTIFFPages {
+			return nil, nil, &ImageDiagnosticError{
+				Problem:    "TIFF page directories loop back on themselves",
+				Suggestion: "re-export the image from its source application",
+			}
+		}
+		if offset+2 > len(data) {
+			return nil, nil, truncatedError("TIFF", fmt.Sprintf("page %d directory", len(offsets)+1), len(data))
+		}
+		end := offset + 2 + int(order.Uint16(data[offset:]))*12
+		if end+4 > len(data) {
+			return nil, nil, truncatedError("TIFF", fmt.Sprintf("page %d directory", len(offsets)+1), len(data))
+		}
+		seen[offset] = true
+		offsets = append(offsets, offset)
+		offset = int(order.Uint32(data[end:]))
+	}
+
+	if len(offsets) == 0 {
+		return nil, nil, &ImageDiagnosticError{
+			Problem:    "TIFF file has no pages",
+			Suggestion: "re-export the image from its source application",
+		}
+	}
+	return order, offsets, nil
+}
+
+// tiffDirectoryValues returns the first value of every BYTE, SHORT or LONG
+// tag of the directory at offset.
+func tiffDirectoryValues(data []byte, order binary.ByteOrder, offset int) map[uint16]uint32 {
+	values := make(map[uint16]uint32)
+	count := int(order.Uint16(data[offset:]))
+	for i := range count {
+		entry := data[offset+2+i*12 : offset+14+i*12]
+		var size int
+		switch order.Uint16(entry[2:]) {
+		case tiffTypeByte:
+			size = 1
+		case tiffTypeShort:
+			size = 2
+		case tiffTypeLong:
+			size = 4
+		}
+		if size == 0 || order.Uint32(entry[4:]) == 0 {
+			continue
+		}
+		value := entry[8:12]
+		if int(order.Uint32(entry[4:]))*size > 4 {
+			at := int(order.Uint32(entry[8:]))
+			if at < 0 || at+size > len(data) {
+				continue
+			}
+			value = data[at : at+size]
+		}
+		switch size {
+		case 1:
+			values[order.Uint16(entry)] = uint32(value[0])
+		case 2:
+			values[order.Uint16(entry)] = uint32(order.Uint16(value))
+		default:
+			values[order.Uint16(entry)] = order.Uint32(value)
+		}
+	}
+	return values
+}
+
+func inspectTIFF(data []byte) (*ImageHeader, error) {
+	order, directories, err := tiffDirectories(data)
+	if err != nil {
+		return nil, err
+	}
+
+	values := tiffDirectoryValues(data, order, directories[0])
+	header := &ImageHeader{
+		Format:     "TIFF",
+		Width:      int(values[tiffImageWidthTag]),
+		Height:     int(values[tiffImageLengthTag]),
+		BitDepth:   1,
+		Components: 1,
+		Pages:      len(directories),
+	}
+	if bits, ok := values[tiffBitsPerSampleTag]; ok {
+		header.BitDepth = int(bits)
+	}
+	if samples, ok := values[tiffSamplesPerPixelTag]; ok {
+		header.Components = int(samples)
+	}
+
+	if header.BitDepth > 8 {
+		return header, &ImageDiagnosticError{
+			Problem:    fmt.Sprintf("%d-bit TIFF images are not supported", header.BitDepth),
+			Suggestion: "reduce it to 8 bits per channel, e.g. `magick input -depth 8 output.tif`",
+		}
+	}
+	return header, nil
+}
+
+// SelectTIFFPage returns a copy of a multi-page TIFF file that holds only
+// page, counted from 1, so the decoders, which read the first page, read
+// that one.
+func SelectTIFFPage(data []byte, page int) ([]byte, error) {
+	order, directories, err := tiffDirectories(data)
+	if err != nil {
+		return nil, err
+	}
+	if page < 1 || page > len(directories) {
+		return nil, fmt.Errorf("TIFF page %d: the file has %d pages", page, len(directories))
+	}
+
+	offset := directories[page-1]
+	selected := bytes.Clone(data)
+	order.PutUint32(selected[4:], uint32(offset))
+	end := offset + 2 + int(order.Uint16(selected[offset:]))*12
+	order.PutUint32(selected[end:], 0)
+	return selected, nil
+}
+
+// tiffDPI reads the horizontal resolution of the first page, 0 when the
+// file stores none or only an aspect ratio.
+func tiffDPI(data []byte) float64 {
+	ifd, err := readTIFFIFD(data)
+	if err != nil {
+		return 0
+	}
+
+	unit := uint16(2)
+	resolution := 0.0
+	for _, entry := range ifd.entries {
+		switch ifd.order.Uint16(entry) {
+		case tiffResolutionUnitTag:
+			unit = ifd.order.Uint16(entry[8:])
+		case tiffXResolutionTag:
+			offset := int(ifd.order.Uint32(entry[8:]))
+			if offset < 0 || offset+8 > len(data) {
+				return 0
+			}
+			if denominator := ifd.order.Uint32(data[offset+4:]); denominator != 0 {
+				resolution = float64(ifd.order.Uint32(data[offset:])) / float64(denominator)
+			}
+		}
+	}
+
+	switch unit {
+	case 2:
+		return resolution
+	case 3:
+		return resolution * 2.54
+	}
+	return 0
+}
+
+// Group4TIFF collects black and white pages into one TIFF file compressed
+// with CCITT Group 4, the format archives keep bilevel scans in.
+type Group4TIFF struct {
+	// DPI is written as the resolution of every page; 0 writes 72.
+	DPI   float64
+	pages []group4Page
+}
+
+type group4Page struct {
+	width, height int
+	data          []byte
+}
+
+// AddPage compresses img, which must hold only black and white pixels, as
+// the next page.
+func (gt *Group4TIFF) AddPage(img image.Image) error {
+	bilevel, err := bilevelImage(img)
+	if err != nil {
+		return fmt.Errorf("group 4 page %d: %w", len(gt.pages)+1, err)
+	}
+	bounds := bilevel.Bounds()
+	gt.pages = append(gt.pages, group4Page{width: bounds.Dx(), height: bounds.Dy(), data: encodeGroup4(bilevel)})
+	return nil
+}
+
+func (gt *Group4TIFF) Pages() int {
+	return len(gt.pages)
+}
+
+// WriteTo writes the file: each page's strip is followed by its directory,
+// and the directories are chained in page order.
+func (gt *Group4TIFF) WriteTo(writer io.Writer) (int64, error) {
+	if len(gt.pages) == 0 {
+		return 0, fmt.Errorf("group 4 TIFF: no pages")
+	}
+
+	dpi := gt.DPI
+	if dpi <= 0 {
+		dpi = tiffDefaultDPI
+	}
+
+	order := binary.LittleEndian
+	var out bytes.Buffer
+	out.WriteString("II*\x00")
+	out.Write(make([]byte, 4))
+	link := 4
+
+	for i, page := range gt.pages {
+		stripOffset := out.Len()
+		out.Write(page.data)
+		if out.Len()%2 == 1 {
+			out.WriteByte(0)
+		}
+		resolutionOffset := out.Len()
+		binary.Write(&out, order, []uint32{uint32(math.Round(dpi * 100)), 100})
+
+		entry := func(tag, dataType uint16, count uint32, value ...uint32) []byte {
+			field := make([]byte, 12)
+			order.PutUint16(field, tag)
+			order.PutUint16(field[2:], dataType)
+			order.PutUint32(field[4:], count)
+			for j, v := range value {
+				if dataType == tiffTypeShort {
+					order.PutUint16(field[8+2*j:], uint16(v))
+				} else {
+					order.PutUint32(field[8:], v)
+				}
+			}
+			return field
+		}
+		entries := [][]byte{
+			entry(tiffNewSubfileTypeTag, tiffTypeLong, 1, 2),
+			entry(tiffImageWidthTag, tiffTypeLong, 1, uint32(page.width)),
+			entry(tiffImageLengthTag, tiffTypeLong, 1, uint32(page.height)),
+			entry(tiffBitsPerSampleTag, tiffTypeShort, 1, 1),
+			entry(tiffCompressionTag, tiffTypeShort, 1, tiffCompressionGroup4),
+			entry(tiffPhotometricTag, tiffTypeShort, 1, tiffPhotometricWhiteIsZero),
+			entry(tiffStripOffsetsTag, tiffTypeLong, 1, uint32(stripOffset)),
+			entry(tiffSamplesPerPixelTag, tiffTypeShort, 1, 1),
+			entry(tiffRowsPerStripTag, tiffTypeLong, 1, uint32(page.height)),
+			entry(tiffStripByteCountsTag, tiffTypeLong, 1, uint32(len(page.data))),
+			entry(tiffXResolutionTag, tiffTypeRational, 1, uint32(resolutionOffset)),
+			entry(tiffYResolutionTag, tiffTypeRational, 1, uint32(resolutionOffset)),
+			entry(tiffResolutionUnitTag, tiffTypeShort, 1, 2),
+			entry(tiffPageNumberTag, tiffTypeShort, 2, uint32(i), uint32(len(gt.pages))),
+		}
+
+		directory := out.Len()
+		order.PutUint32(out.Bytes()[link:], uint32(directory))
+		binary.Write(&out, order, uint16(len(entries)))
+		for _, field := range entries {
+			out.Write(field)
+		}
+		link = out.Len()
+		out.Write(make([]byte, 4))
+
+		if uint64(out.Len()) > 1<<32-1 {
+			return 0, fmt.Errorf("group 4 TIFF: file too large")
+		}
+	}
+
+	written, err := writer.Write(out.Bytes())
+	return int64(written), err
+}
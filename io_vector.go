@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// TraceOptions control how the binary result is traced into outlines.
+// Tolerance is how far, in pixels, a simplified outline may stray from the
+// pixel boundary; Smoothness from 0 to 1 turns the corners of the outline
+// into curves; MinArea drops outlines enclosing fewer pixels.
+type TraceOptions struct {
+	Tolerance  float64
+	Smoothness float64
+	MinArea    float64
+}
+
+func DefaultTraceOptions() TraceOptions {
+	return TraceOptions{Tolerance: 1, Smoothness: 0.8, MinArea: 4}
+}
+
+// ParseTraceOptions reads "tolerance=1,smoothness=0.8,min-area=4" over the
+// defaults; omitted options keep their default.
+func ParseTraceOptions(options string) (TraceOptions, error) {
+	parsed := DefaultTraceOptions()
+	for _, option := range strings.Split(options, ",") {
+		option = strings.TrimSpace(option)
+		if option == "" {
+			continue
+		}
+
+		name, text, ok := strings.Cut(option, "=")
+		if !ok {
+			return parsed, fmt.Errorf("vector option %q: expected name=value", option)
+		}
+		value, err := strconv.ParseFloat(strings.TrimSpace(text), 64)
+		if err != nil || value < 0 {
+			return parsed, fmt.Errorf("vector option %q: value must be a non-negative number", option)
+		}
+
+		switch strings.TrimSpace(name) {
+		case "tolerance":
+			parsed.Tolerance = value
+		case "smoothness":
+			if value > 1 {
+				return parsed, fmt.Errorf("vector option %q: smoothness must be between 0 and 1", option)
+			}
+			parsed.Smoothness = value
+		case "min-area":
+			parsed.MinArea = value
+		default:
+			return parsed, fmt.Errorf("vector option %q: expected tolerance, smoothness or min-area", option)
+		}
+	}
+	return parsed, nil
+}
+
+// VectorOutlines are the traced outlines of the ink of a Width by Height
+// pixel image. Holes are outlines inside outlines and are cut out by the
+// even-odd fill rule.
+type VectorOutlines struct {
+	Width      int
+	Height     int
+	DPI        float64
+	Paths      [][]image.Point
+	Smoothness float64
+}
+
+// pathOperators describes each outline with move, line, curve and close
+// operators as (operator, coordinates) pairs, so SVG and PDF share the
+// geometry. Pixel coordinates are shifted by half a pixel to run through
+// pixel centres, the way the contours were traced.
+//
+// With smoothness, each outline runs through the midpoints of its edges and
+// bends around its corners with a cubic curve whose control points are
+// pulled from the midpoints towards the corner; at 1 a square becomes close
+// to a circle.
+func (vo *VectorOutlines) pathOperators(emit func(operator byte, coordinates ...float64)) {
+	pull := 1 - 0.45*vo.Smoothness
+	for _, path := range vo.Paths {
+		point := func(i int) (float64, float64) {
+			p := path[(i+len(path))%len(path)]
+			return float64(p.X) + 0.5, float64(p.Y) + 0.5
+		}
+
+		if vo.Smoothness <= 0 {
+			x, y := point(0)
+			emit('M', x, y)
+			for i := 1; i < len(path); i++ {
+				x, y := point(i)
+				emit('L', x, y)
+			}
+			emit('Z')
+			continue
+		}
+
+		midpoint := func(i int) (float64, float64) {
+			x0, y0 := point(i)
+			x1, y1 := point(i + 1)
+			return (x0 + x1) / 2, (y0 + y1) / 2
+		}
+		startX, startY := midpoint(-1)
+		emit('M', startX, startY)
+		for i := 0; i < len(path); i++ {
+			fromX, fromY := midpoint(i - 1)
+			toX, toY := midpoint(i)
+			cornerX, cornerY := point(i)
+			emit('C',
+				cornerX+(fromX-cornerX)*pull, cornerY+(fromY-cornerY)*pull,
+				cornerX+(toX-cornerX)*pull, cornerY+(toY-cornerY)*pull,
+				toX, toY)
+		}
+		emit('Z')
+	}
+}
+
+// vectorCoordinate formats a coordinate to a hundredth of a pixel.
+func vectorCoordinate(coordinate float64) string {
+	return strconv.FormatFloat(math.Round(coordinate*100)/100, 'f', -1, 64)
+}
+
+// SVG writes the outlines as one even-odd filled path, sized in inches when
+// the resolution is known.
+func (vo *VectorOutlines) SVG() []byte {
+	var path strings.Builder
+	vo.pathOperators(func(operator byte, coordinates ...float64) {
+		path.WriteByte(operator)
+		for i, coordinate := range coordinates {
+			if i > 0 {
+				path.WriteByte(' ')
+			}
+			path.WriteString(vectorCoordinate(coordinate))
+		}
+	})
+
+	width, height := strconv.Itoa(vo.Width), strconv.Itoa(vo.Height)
+	if vo.DPI > 0 {
+		width = strconv.FormatFloat(float64(vo.Width)/vo.DPI, 'f', 3, 64) + "in"
+		height = strconv.FormatFloat(float64(vo.Height)/vo.DPI, 'f', 3, 64) + "in"
+	}
+
+	var out bytes.Buffer
+	out.WriteString("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
+	fmt.Fprintf(&out, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%s\" height=\"%s\" viewBox=\"0 0 %d %d\">\n",
+		width, height, vo.Width, vo.Height)
+	fmt.Fprintf(&out, "<!-- Traced by %s %s -->\n", AppName, AppVersion)
+	fmt.Fprintf(&out, "<path fill=\"#000\" fill-rule=\"evenodd\" d=\"%s\"/>\n", path.String())
+	out.WriteString("</svg>\n")
+	return out.Bytes()
+}
+
+// PDF writes the outlines on a single page of the image's printed size, or
+// at 72 dpi when the resolution is unknown.
+func (vo *VectorOutlines) PDF() ([]byte, error) {
+	scale := 1.0
+	if vo.DPI > 0 {
+		scale = 72 / vo.DPI
+	}
+	document := newPDFDocument(float64(vo.Width)*scale, float64(vo.Height)*scale)
+	page := document.NewPage()
+
+	// Flip the page so the outlines keep their top-left pixel coordinates.
+	var operators strings.Builder
+	fmt.Fprintf(&operators, "q %.4f 0 0 %.4f 0 %.4f cm 0 g\n", scale, -scale, float64(vo.Height)*scale)
+	vo.pathOperators(func(operator byte, coordinates ...float64) {
+		for _, coordinate := range coordinates {
+			operators.WriteString(vectorCoordinate(coordinate))
+			operators.WriteByte(' ')
+		}
+		switch operator {
+		case 'M':
+			operators.WriteString("m\n")
+		case 'L':
+			operators.WriteString("l\n")
+		case 'C':
+			operators.WriteString("c\n")
+		case 'Z':
+			operators.WriteString("h\n")
+		}
+	})
+	operators.WriteString("f* Q\n")
+	page.Path(operators.String())
+
+	var out bytes.Buffer
+	if err := document.Write(&out); err != nil {
+		return nil, fmt.Errorf("write vector PDF: %w", err)
+	}
+	return out.Bytes(), nil
+}
+
+// WriteFile writes the outlines as SVG or PDF, chosen by the extension of
+// path.
+func (vo *VectorOutlines) WriteFile(path string) error {
+	var data []byte
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".svg":
+		data = vo.SVG()
+	case ".pdf":
+		var err error
+		if data, err = vo.PDF(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("vector output %s: expected a .svg or .pdf file", path)
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("write vector outlines %s: %w", path, err)
+	}
+	return nil
+}
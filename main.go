@@ -6,9 +6,7 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
-
-	"fyne.io/fyne/v2"
-	"fyne.io/fyne/v2/app"
+	"time"
 )
 
 const (
@@ -17,33 +15,79 @@ const (
 	AppVersion = "1.0.0"
 )
 
-func main() {
-	app.SetMetadata(fyne.AppMetadata{
-		ID:      AppID,
-		Name:    AppName,
-		Version: AppVersion,
-		Build:   1,
-	})
+// shutdownDrainTimeout bounds how long shutdown waits for running
+// processing to release its Mats before exiting anyway.
+const shutdownDrainTimeout = 5 * time.Second
 
-	fyneApp := app.NewWithID(AppID)
-	window := fyneApp.NewWindow(AppName)
+func main() {
+	args := os.Args[1:]
 
-	ctx, cancel := context.WithCancel(context.Background())
+	if len(args) > 0 {
+		switch args[0] {
+		case processCommand:
+			exitAfterDrain(runHeadlessProcess(headlessContext(), args[1:]))
+		case batchCommand:
+			exitAfterDrain(runBatchCommand(headlessContext(), args[1:]))
+		case framesCommand:
+			exitAfterDrain(runFramesCommand(headlessContext(), args[1:]))
+		case coordinatorCommand:
+			exitAfterDrain(runCoordinatorCommand(headlessContext(), args[1:]))
+		case workerCommand:
+			exitAfterDrain(runWorkerCommand(headlessContext(), args[1:]))
+		case submitCommand:
+			exitAfterDrain(runSubmitCommand(headlessContext(), args[1:]))
+		case benchmarkCommand:
+			exitAfterDrain(runBenchmarkCommand(headlessContext(), args[1:]))
+		case tuneCommand:
+			exitAfterDrain(runTuneCommand(headlessContext(), args[1:]))
+		case convertCommand:
+			exitAfterDrain(runConvertCommand(headlessContext(), args[1:]))
+		case datasetCommand:
+			os.Exit(runDatasetCommand(args[1:]))
+		case profileCommand:
+			os.Exit(runProfileCommand(args[1:]))
+		case provenanceCommand:
+			os.Exit(runProvenanceCommand(args[1:]))
+		case schemaCommand:
+			os.Exit(runSchemaCommand(args[1:]))
+		}
+	}
 
-	application := NewApplication(fyneApp, window, ctx, cancel)
+	runGUI(args)
+}
 
+// headlessContext is cancelled on SIGINT/SIGTERM so a command can stop
+// between images and still write its results.
+func headlessContext() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
 	setupSignalHandling(cancel)
-
-	application.ShowAndRun()
+	return ctx
 }
 
-func setupSignalHandling(cancel context.CancelFunc) {
-	sigChan := make(chan os.Signal, 1)
+// setupSignalHandling calls onSignal on the first SIGINT/SIGTERM so running
+// work can wind down; a second signal exits at once.
+func setupSignalHandling(onSignal func()) {
+	sigChan := make(chan os.Signal, 2)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM, syscall.SIGINT)
 
 	go func() {
 		<-sigChan
 		log.Println("Signal received, shutting down...")
-		cancel()
+		onSignal()
+
+		<-sigChan
+		log.Println("Second signal received, exiting immediately")
+		os.Exit(1)
 	}()
 }
+
+// exitAfterDrain exits with code once processing abandoned by a cancelled
+// command has finished, or shutdownDrainTimeout passed, and the logs are
+// flushed.
+func exitAfterDrain(code int) {
+	if !waitForProcessingWorkers(shutdownDrainTimeout) {
+		log.Printf("processing still running after %v, exiting anyway", shutdownDrainTimeout)
+	}
+	GetDebugSystem().Close()
+	os.Exit(code)
+}
@@ -0,0 +1,65 @@
+//go:build !nogui
+
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/app"
+)
+
+func runGUI(args []string) {
+	if len(args) > 0 && args[0] == remoteCommand {
+		os.Exit(runRemoteCommand(args[1:]))
+	}
+	if len(args) > 0 && args[0] == viewCommand {
+		os.Exit(runResultViewer(args[1:]))
+	}
+
+	paths := openFileArguments(args)
+
+	singleInstance := !hasArgument(args, newInstanceFlag)
+	if singleInstance && forwardToRunningInstance(paths) {
+		return
+	}
+
+	fyneApp := newFyneApp()
+	window := fyneApp.NewWindow(AppName)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	application := NewApplication(fyneApp, window, ctx, cancel)
+
+	if singleInstance {
+		if err := application.startInstanceListener(); err != nil {
+			log.Printf("single-instance listener disabled: %v", err)
+		}
+	}
+
+	fyneApp.Lifecycle().SetOnStarted(func() {
+		if len(paths) > 0 {
+			application.OpenFiles(paths)
+			return
+		}
+		application.showFirstRunTour()
+	})
+
+	setupSignalHandling(func() {
+		fyne.Do(application.Quit)
+	})
+
+	application.ShowAndRun()
+}
+
+func newFyneApp() fyne.App {
+	app.SetMetadata(fyne.AppMetadata{
+		ID:      AppID,
+		Name:    AppName,
+		Version: AppVersion,
+		Build:   1,
+	})
+	return app.NewWithID(AppID)
+}
@@ -0,0 +1,30 @@
+//go:build nogui
+
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// runGUI reports that this binary was built for servers and containers.
+func runGUI(args []string) {
+	fmt.Fprintf(os.Stderr, `%s %s was built without the GUI (nogui tag).
+
+Usage:
+  otsu-obliterator process [flags]
+  otsu-obliterator batch [flags] <image or directory>...
+  otsu-obliterator frames [flags]
+  otsu-obliterator coordinator [flags]
+  otsu-obliterator worker [flags]
+  otsu-obliterator submit [flags] <image>...
+  otsu-obliterator benchmark [flags] <image or directory>...
+  otsu-obliterator tune [flags] <image or directory>...
+  otsu-obliterator dataset import|add|list|validate|remove ...
+  otsu-obliterator profile list|show|suggest|remove ...
+  otsu-obliterator provenance keygen|show|verify ...
+
+Run a command with -h for its flags.
+`, AppName, AppVersion)
+	os.Exit(2)
+}
@@ -70,3 +70,71 @@ func CalculateBinaryMetrics(groundTruth, result gocv.Mat) (*BinaryImageMetrics,
 
 	return metrics, nil
 }
+
+// MetricsReport is the serializable form of BinaryImageMetrics used when
+// metrics leave the process. SchemaVersion follows the rules of
+// MetricsSchemaVersion.
+type MetricsReport struct {
+	SchemaVersion  int     `json:"schema_version"`
+	FMeasure       float64 `json:"f_measure"`
+	PseudoFMeasure float64 `json:"pseudo_f_measure"`
+	NRM            float64 `json:"nrm"`
+	DRD            float64 `json:"drd"`
+	MPM            float64 `json:"mpm"`
+	BFC            float64 `json:"bfc"`
+	Skeleton       float64 `json:"skeleton"`
+	Precision      float64 `json:"precision"`
+	Recall         float64 `json:"recall"`
+	TruePositives  int     `json:"true_positives"`
+	TrueNegatives  int     `json:"true_negatives"`
+	FalsePositives int     `json:"false_positives"`
+	FalseNegatives int     `json:"false_negatives"`
+	TotalPixels    int     `json:"total_pixels"`
+	Reference      string  `json:"reference,omitempty"`
+}
+
+type namedMetric struct {
+	name  string
+	value float64
+}
+
+// namedValues lists the quality metrics with the names reports show them
+// under.
+func (mr *MetricsReport) namedValues() []namedMetric {
+	return []namedMetric{
+		{"F-measure", mr.FMeasure},
+		{"Pseudo F-measure", mr.PseudoFMeasure},
+		{"NRM", mr.NRM},
+		{"DRD", mr.DRD},
+		{"MPM", mr.MPM},
+		{"Background/foreground contrast", mr.BFC},
+		{"Skeleton similarity", mr.Skeleton},
+		{"Precision", mr.Precision},
+		{"Recall", mr.Recall},
+	}
+}
+
+func NewMetricsReport(metrics *BinaryImageMetrics) *MetricsReport {
+	if metrics == nil {
+		return nil
+	}
+
+	return &MetricsReport{
+		SchemaVersion:  MetricsSchemaVersion,
+		FMeasure:       metrics.FMeasure(),
+		PseudoFMeasure: metrics.PseudoFMeasure(),
+		NRM:            metrics.NRM(),
+		DRD:            metrics.DRD(),
+		MPM:            metrics.MPM(),
+		BFC:            metrics.BackgroundForegroundContrast(),
+		Skeleton:       metrics.SkeletonSimilarity(),
+		Precision:      metrics.Precision(),
+		Recall:         metrics.Recall(),
+		TruePositives:  metrics.TruePositives,
+		TrueNegatives:  metrics.TrueNegatives,
+		FalsePositives: metrics.FalsePositives,
+		FalseNegatives: metrics.FalseNegatives,
+		TotalPixels:    metrics.TotalPixels,
+		Reference:      metrics.Reference,
+	}
+}
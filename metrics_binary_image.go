@@ -9,6 +9,9 @@ import (
 )
 
 type BinaryImageMetrics struct {
+	// Reference is MetricsReferenceGroundTruth or MetricsReferenceGrayscale
+	// for runs of the engine, and empty for other comparisons.
+	Reference      string
 	TruePositives  int
 	TrueNegatives  int
 	FalsePositives int
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"sort"
+
+	"gocv.io/x/gocv"
+)
+
+const (
+	// ComponentDensityGrid is the number of rows and columns of the ink
+	// density grid.
+	ComponentDensityGrid = 4
+
+	// Component areas are bucketed by powers of two up to this many buckets;
+	// the last bucket collects everything larger.
+	componentSizeBuckets = 16
+)
+
+// ComponentStats describes one connected ink component of a binary result.
+type ComponentStats struct {
+	Label       int
+	Area        int
+	Bounds      image.Rectangle
+	Centroid    [2]float64
+	StrokeWidth float64
+}
+
+// SizeBucket counts components whose area lies in [MinArea, MaxArea].
+type SizeBucket struct {
+	MinArea int
+	MaxArea int
+	Count   int
+}
+
+// ComponentAnalysis summarizes the connected ink components of a binary
+// result and keeps the ink and paper label images for looking regions up
+// by pixel. Close releases the label images.
+type ComponentAnalysis struct {
+	Components         []ComponentStats
+	SizeHistogram      []SizeBucket
+	MedianArea         int
+	AverageStrokeWidth float64
+	InkDensity         [ComponentDensityGrid][ComponentDensityGrid]float64
+
+	labels gocv.Mat
+
+	// paperLabels labels the connected paper regions; paperOnBorder marks
+	// those touching the image border, which are page background rather
+	// than enclosed holes.
+	paperLabels   gocv.Mat
+	paperOnBorder []bool
+}
+
+// AnalyzeComponents labels the ink of binary, where ink is 0 and paper 255,
+// and measures each component. Stroke width is estimated per component as
+// twice its largest distance to paper, minus one.
+func AnalyzeComponents(binary gocv.Mat) (*ComponentAnalysis, error) {
+	if err := validateMatForMetrics(binary, "component analysis"); err != nil {
+		return nil, err
+	}
+
+	ink := gocv.NewMat()
+	defer ink.Close()
+	gocv.BitwiseNot(binary, &ink)
+
+	labels := gocv.NewMat()
+	stats := gocv.NewMat()
+	defer stats.Close()
+	centroids := gocv.NewMat()
+	defer centroids.Close()
+
+	count := gocv.ConnectedComponentsWithStats(ink, &labels, &stats, &centroids)
+
+	distance := gocv.NewMat()
+	defer distance.Close()
+	distanceLabels := gocv.NewMat()
+	defer distanceLabels.Close()
+	gocv.DistanceTransform(ink, &distance, &distanceLabels, gocv.DistL2, gocv.DistanceMask3, gocv.DistanceLabelCComp)
+
+	labelData, err := labelValues(labels)
+	if err != nil {
+		labels.Close()
+		return nil, fmt.Errorf("component analysis: %w", err)
+	}
+	distanceData, err := distance.DataPtrFloat32()
+	if err != nil {
+		labels.Close()
+		return nil, fmt.Errorf("component analysis: %w", err)
+	}
+
+	maxDistance := make([]float32, count)
+	for i, label := range labelData {
+		if label > 0 && distanceData[i] > maxDistance[label] {
+			maxDistance[label] = distanceData[i]
+		}
+	}
+
+	analysis := &ComponentAnalysis{
+		Components: make([]ComponentStats, 0, max(0, count-1)),
+		labels:     labels,
+	}
+
+	weightedWidth := 0.0
+	totalArea := 0
+	for label := 1; label < count; label++ {
+		left := int(stats.GetIntAt(label, int(gocv.CC_STAT_LEFT)))
+		top := int(stats.GetIntAt(label, int(gocv.CC_STAT_TOP)))
+		width := int(stats.GetIntAt(label, int(gocv.CC_STAT_WIDTH)))
+		height := int(stats.GetIntAt(label, int(gocv.CC_STAT_HEIGHT)))
+		area := int(stats.GetIntAt(label, int(gocv.CC_STAT_AREA)))
+
+		strokeWidth := math.Max(1, 2*float64(maxDistance[label])-1)
+
+		analysis.Components = append(analysis.Components, ComponentStats{
+			Label:       label,
+			Area:        area,
+			Bounds:      image.Rect(left, top, left+width, top+height),
+			Centroid:    [2]float64{centroids.GetDoubleAt(label, 0), centroids.GetDoubleAt(label, 1)},
+			StrokeWidth: strokeWidth,
+		})
+
+		weightedWidth += strokeWidth * float64(area)
+		totalArea += area
+	}
+
+	if totalArea > 0 {
+		analysis.AverageStrokeWidth = weightedWidth / float64(totalArea)
+	}
+
+	analysis.SizeHistogram = componentSizeHistogram(analysis.Components)
+	analysis.MedianArea = medianComponentArea(analysis.Components)
+	analysis.InkDensity = inkDensityGrid(ink)
+	analysis.paperLabels, analysis.paperOnBorder = labelPaperRegions(binary)
+
+	return analysis, nil
+}
+
+func labelPaperRegions(binary gocv.Mat) (gocv.Mat, []bool) {
+	labels := gocv.NewMat()
+	stats := gocv.NewMat()
+	defer stats.Close()
+	centroids := gocv.NewMat()
+	defer centroids.Close()
+
+	count := gocv.ConnectedComponentsWithStats(binary, &labels, &stats, &centroids)
+	cols, rows := binary.Cols(), binary.Rows()
+
+	onBorder := make([]bool, count)
+	for label := 1; label < count; label++ {
+		left := int(stats.GetIntAt(label, int(gocv.CC_STAT_LEFT)))
+		top := int(stats.GetIntAt(label, int(gocv.CC_STAT_TOP)))
+		width := int(stats.GetIntAt(label, int(gocv.CC_STAT_WIDTH)))
+		height := int(stats.GetIntAt(label, int(gocv.CC_STAT_HEIGHT)))
+		onBorder[label] = left == 0 || top == 0 || left+width == cols || top+height == rows
+	}
+
+	return labels, onBorder
+}
+
+func componentSizeHistogram(components []ComponentStats) []SizeBucket {
+	buckets := make([]SizeBucket, componentSizeBuckets)
+	for i := range buckets {
+		buckets[i].MinArea = 1 << i
+		buckets[i].MaxArea = 1<<(i+1) - 1
+	}
+	buckets[len(buckets)-1].MaxArea = 0
+
+	for _, component := range components {
+		index := 0
+		for index < len(buckets)-1 && component.Area > buckets[index].MaxArea {
+			index++
+		}
+		buckets[index].Count++
+	}
+
+	return buckets
+}
+
+func medianComponentArea(components []ComponentStats) int {
+	if len(components) == 0 {
+		return 0
+	}
+
+	areas := make([]int, len(components))
+	for i, component := range components {
+		areas[i] = component.Area
+	}
+	sort.Ints(areas)
+	return areas[len(areas)/2]
+}
+
+func inkDensityGrid(ink gocv.Mat) [ComponentDensityGrid][ComponentDensityGrid]float64 {
+	var grid [ComponentDensityGrid][ComponentDensityGrid]float64
+	counts := countNonZeroGrid(ink)
+
+	for gy := range grid {
+		for gx := range grid[gy] {
+			if cell := densityGridCell(ink, gx, gy); !cell.Empty() {
+				grid[gy][gx] = float64(counts[gy][gx]) / float64(cell.Dx()*cell.Dy())
+			}
+		}
+	}
+
+	return grid
+}
+
+// countNonZeroGrid counts the nonzero pixels of mask in each cell of the
+// density grid.
+func countNonZeroGrid(mask gocv.Mat) [ComponentDensityGrid][ComponentDensityGrid]int {
+	var grid [ComponentDensityGrid][ComponentDensityGrid]int
+
+	for gy := range grid {
+		for gx := range grid[gy] {
+			cell := densityGridCell(mask, gx, gy)
+			if cell.Empty() {
+				continue
+			}
+
+			region := mask.Region(cell)
+			grid[gy][gx] = gocv.CountNonZero(region)
+			region.Close()
+		}
+	}
+
+	return grid
+}
+
+func densityGridCell(mat gocv.Mat, gx, gy int) image.Rectangle {
+	return densityGridRect(mat.Cols(), mat.Rows(), gx, gy)
+}
+
+func densityGridRect(cols, rows, gx, gy int) image.Rectangle {
+	return image.Rect(
+		gx*cols/ComponentDensityGrid, gy*rows/ComponentDensityGrid,
+		(gx+1)*cols/ComponentDensityGrid, (gy+1)*rows/ComponentDensityGrid,
+	)
+}
+
+// densityGridCellAt returns the [column, row] of the density grid cell of
+// a cols×rows image that holds point.
+func densityGridCellAt(cols, rows int, point image.Point) [2]int {
+	for gy := 0; gy < ComponentDensityGrid; gy++ {
+		for gx := 0; gx < ComponentDensityGrid; gx++ {
+			if point.In(densityGridRect(cols, rows, gx, gy)) {
+				return [2]int{gx, gy}
+			}
+		}
+	}
+	return [2]int{ComponentDensityGrid - 1, ComponentDensityGrid - 1}
+}
+
+// ComponentAt returns the component covering pixel (x, y), or false when
+// the pixel is paper or outside the image.
+func (ca *ComponentAnalysis) ComponentAt(x, y int) (*ComponentStats, bool) {
+	if x < 0 || y < 0 || x >= ca.labels.Cols() || y >= ca.labels.Rows() {
+		return nil, false
+	}
+
+	label := int(ca.labels.GetIntAt(y, x))
+	if label <= 0 || label > len(ca.Components) {
+		return nil, false
+	}
+
+	return &ca.Components[label-1], true
+}
+
+// RegionMaskAt masks the connected region under pixel (x, y): the ink
+// component covering it, or the enclosed paper region when it is paper.
+// ink reports which of the two it is. Paper touching the image border is
+// page background and is refused. The caller closes the mask.
+func (ca *ComponentAnalysis) RegionMaskAt(x, y int) (mask gocv.Mat, ink bool, err error) {
+	if x < 0 || y < 0 || x >= ca.labels.Cols() || y >= ca.labels.Rows() {
+		return gocv.NewMat(), false, fmt.Errorf("point (%d, %d) is outside the image", x, y)
+	}
+
+	labels := ca.labels
+	label := int(ca.labels.GetIntAt(y, x))
+	ink = label > 0
+	if !ink {
+		labels = ca.paperLabels
+		label = int(ca.paperLabels.GetIntAt(y, x))
+		if label <= 0 || label >= len(ca.paperOnBorder) {
+			return gocv.NewMat(), false, fmt.Errorf("no region at (%d, %d)", x, y)
+		}
+		if ca.paperOnBorder[label] {
+			return gocv.NewMat(), false, fmt.Errorf("the page background cannot be flipped")
+		}
+	}
+
+	mask = gocv.NewMat()
+	gocv.InRangeWithScalar(labels,
+		gocv.NewScalar(float64(label), 0, 0, 0),
+		gocv.NewScalar(float64(label), 0, 0, 0),
+		&mask)
+	return mask, ink, nil
+}
+
+func (ca *ComponentAnalysis) Close() {
+	ca.labels.Close()
+	ca.paperLabels.Close()
+}
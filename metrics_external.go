@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"os"
+
+	"gocv.io/x/gocv"
+)
+
+// ExternalComparison evaluates the current result against a binarization
+// another tool produced for the same source. Overlay shows agreement in
+// black and white, ink only this tool found in red and ink only the other
+// tool found in blue; the counts are in working image pixels.
+type ExternalComparison struct {
+	Tool               string  `json:"tool"`
+	Width              int     `json:"width"`
+	Height             int     `json:"height"`
+	ScaleFactor        float64 `json:"scale_factor"`
+	Inverted           bool    `json:"inverted"`
+	DisagreementPixels int     `json:"disagreement_pixels"`
+	OursOnlyInk        int     `json:"ours_only_ink"`
+	TheirsOnlyInk      int     `json:"theirs_only_ink"`
+
+	// Regions counts disagreeing pixels in a grid over the page, row by row.
+	Regions [ComponentDensityGrid][ComponentDensityGrid]int `json:"regions"`
+
+	// Agreement scores the current result with the external one as the
+	// reference. Ours and Theirs score both against ground truth and are
+	// nil when none was given.
+	Agreement *MetricsReport `json:"agreement"`
+	Ours      *MetricsReport `json:"ours_vs_ground_truth,omitempty"`
+	Theirs    *MetricsReport `json:"external_vs_ground_truth,omitempty"`
+
+	Parameters *OtsuParameters `json:"parameters,omitempty"`
+
+	Overlay gocv.Mat `json:"-"`
+}
+
+// CompareExternalResult compares the current result with an external
+// binarization and, when groundTruth is not nil, scores both against it.
+// Invert flips an external result that draws ink in white. The caller
+// closes the returned comparison.
+func (pe *ProcessingEngine) CompareExternalResult(tool string, external, groundTruth *ImageData, invert bool) (*ExternalComparison, error) {
+	if pe.processedImage == nil || pe.originalImage == nil {
+		return nil, fmt.Errorf("no result to compare: process the image first")
+	}
+	ours := pe.processedImage.Mat
+
+	theirs, err := pe.referenceMask(external, ours, invert, "external result")
+	if err != nil {
+		return nil, err
+	}
+	defer theirs.Close()
+
+	comparison := &ExternalComparison{
+		Tool:        tool,
+		Width:       ours.Cols(),
+		Height:      ours.Rows(),
+		ScaleFactor: 1,
+		Inverted:    invert,
+	}
+	if pe.originalImage.ScaleFactor != 0 {
+		comparison.ScaleFactor = pe.originalImage.ScaleFactor
+	}
+	if run := pe.currentRun(); run != nil {
+		comparison.Parameters = run.Params
+	}
+
+	agreement, err := CalculateBinaryMetrics(theirs, ours)
+	if err != nil {
+		return nil, fmt.Errorf("metrics against external result: %w", err)
+	}
+	comparison.Agreement = NewMetricsReport(agreement)
+
+	if groundTruth != nil {
+		truth, err := pe.referenceMask(groundTruth, ours, false, "ground truth")
+		if err != nil {
+			return nil, err
+		}
+		defer truth.Close()
+
+		oursMetrics, err := CalculateBinaryMetrics(truth, ours)
+		if err != nil {
+			return nil, fmt.Errorf("metrics of current result against ground truth: %w", err)
+		}
+		theirsMetrics, err := CalculateBinaryMetrics(truth, theirs)
+		if err != nil {
+			return nil, fmt.Errorf("metrics of external result against ground truth: %w", err)
+		}
+		comparison.Ours = NewMetricsReport(oursMetrics)
+		comparison.Theirs = NewMetricsReport(theirsMetrics)
+	}
+
+	disagreement := gocv.NewMat()
+	defer disagreement.Close()
+	gocv.BitwiseXor(ours, theirs, &disagreement)
+	comparison.DisagreementPixels = gocv.CountNonZero(disagreement)
+	comparison.Regions = countNonZeroGrid(disagreement)
+
+	// Disagreeing pixels that are paper in the external result are ink only
+	// here, and the other way round.
+	oursOnly := gocv.NewMat()
+	defer oursOnly.Close()
+	gocv.BitwiseAnd(disagreement, theirs, &oursOnly)
+	theirsOnly := gocv.NewMat()
+	defer theirsOnly.Close()
+	gocv.BitwiseAnd(disagreement, ours, &theirsOnly)
+	comparison.OursOnlyInk = gocv.CountNonZero(oursOnly)
+	comparison.TheirsOnlyInk = gocv.CountNonZero(theirsOnly)
+
+	comparison.Overlay = gocv.NewMat()
+	gocv.CvtColor(ours, &comparison.Overlay, gocv.ColorGrayToBGR)
+	paintOverlay(&comparison.Overlay, oursOnly, gocv.NewScalar(0, 0, 255, 0))
+	paintOverlay(&comparison.Overlay, theirsOnly, gocv.NewScalar(255, 0, 0, 0))
+
+	GetDebugSystem().logger.Info("external result compared",
+		"tool", tool,
+		"disagreement_pixels", comparison.DisagreementPixels,
+		"ours_only_ink", comparison.OursOnlyInk,
+		"theirs_only_ink", comparison.TheirsOnlyInk,
+		"f_measure", comparison.Agreement.FMeasure,
+		"ground_truth", groundTruth != nil)
+
+	return comparison, nil
+}
+
+// referenceMask thresholds a reference image to the 0/255 mask of the
+// current result. References at the file's full resolution are scaled down
+// to the working image; any other size mismatch is an error.
+func (pe *ProcessingEngine) referenceMask(data *ImageData, result gocv.Mat, invert bool, name string) (gocv.Mat, error) {
+	if data == nil || data.Mat.Empty() {
+		return gocv.NewMat(), fmt.Errorf("%s is empty", name)
+	}
+
+	gray := pe.convertToGrayscale(data.Mat)
+	defer gray.Close()
+
+	rows, cols := result.Rows(), result.Cols()
+	if gray.Rows() != rows || gray.Cols() != cols {
+		original := pe.originalImage
+		if gray.Cols() != original.OriginalWidth || gray.Rows() != original.OriginalHeight ||
+			original.Width != cols || original.Height != rows {
+			return gocv.NewMat(), fmt.Errorf("%s is %dx%d but the result is %dx%d, likely from page geometry settings",
+				name, gray.Cols(), gray.Rows(), cols, rows)
+		}
+		gocv.Resize(gray, &gray, image.Pt(cols, rows), 0, 0, gocv.InterpolationNearestNeighbor)
+	}
+
+	mask := gocv.NewMat()
+	thresholdType := gocv.ThresholdBinary
+	if invert {
+		thresholdType = gocv.ThresholdBinaryInv
+	}
+	gocv.Threshold(gray, &mask, 127, 255, thresholdType)
+	return mask, nil
+}
+
+func paintOverlay(overlay *gocv.Mat, mask gocv.Mat, color gocv.Scalar) {
+	fill := gocv.NewMatWithSizeFromScalar(color, overlay.Rows(), overlay.Cols(), gocv.MatTypeCV8UC3)
+	defer fill.Close()
+	fill.CopyToWithMask(overlay, mask)
+}
+
+// Export writes the overlay as a PNG at path and the comparison as JSON
+// next to it.
+func (ec *ExternalComparison) Export(path string) error {
+	if !gocv.IMWrite(path, ec.Overlay) {
+		return fmt.Errorf("write disagreement overlay %s", path)
+	}
+
+	data, err := json.MarshalIndent(ec, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode comparison: %w", err)
+	}
+
+	summaryPath := SidecarPath(path)
+	if err := os.WriteFile(summaryPath, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("write comparison %s: %w", summaryPath, err)
+	}
+	return nil
+}
+
+func (ec *ExternalComparison) Close() {
+	ec.Overlay.Close()
+}
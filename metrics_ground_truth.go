@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+
+	"gocv.io/x/gocv"
+)
+
+// What the metrics of a run compared the result with. F-measure, DRD and
+// MPM are defined against a reference binarization; without one the engine
+// scores the result against the grayscale page, which only ranks runs.
+const (
+	MetricsReferenceGrayscale   = "grayscale"
+	MetricsReferenceGroundTruth = "ground_truth"
+)
+
+// SetGroundTruth makes truth, a reference binarization of the loaded image
+// at its working or full size, what later runs are scored against. The
+// engine keeps a mask of it; the caller still owns truth.
+func (pe *ProcessingEngine) SetGroundTruth(truth *ImageData) error {
+	original := pe.originalImage
+	if original == nil {
+		return fmt.Errorf("ground truth: load an image first")
+	}
+
+	mask, err := pe.referenceMask(truth, original.Mat, false, "ground truth")
+	if err != nil {
+		return err
+	}
+
+	pe.metricsMu.Lock()
+	defer pe.metricsMu.Unlock()
+	pe.releaseGroundTruth()
+	pe.groundTruth = &mask
+	return nil
+}
+
+// ClearGroundTruth goes back to scoring runs against the grayscale page.
+func (pe *ProcessingEngine) ClearGroundTruth() {
+	pe.metricsMu.Lock()
+	defer pe.metricsMu.Unlock()
+	pe.releaseGroundTruth()
+}
+
+func (pe *ProcessingEngine) HasGroundTruth() bool {
+	pe.metricsMu.Lock()
+	defer pe.metricsMu.Unlock()
+	return pe.groundTruth != nil
+}
+
+func (pe *ProcessingEngine) releaseGroundTruth() {
+	if pe.groundTruth != nil {
+		pe.groundTruth.Close()
+		pe.groundTruth = nil
+	}
+}
+
+// metricsReference returns what the metrics of result compare with: the
+// ground truth when one is set and fits result, else gray. Callers hold
+// metricsMu.
+func (pe *ProcessingEngine) metricsReference(gray, result gocv.Mat) (gocv.Mat, string) {
+	if pe.groundTruth == nil {
+		return gray, MetricsReferenceGrayscale
+	}
+	if pe.groundTruth.Rows() != result.Rows() || pe.groundTruth.Cols() != result.Cols() {
+		GetDebugSystem().logger.Warn("ground truth does not fit the result, scoring against the grayscale page",
+			"ground_truth_size", fmt.Sprintf("%dx%d", pe.groundTruth.Cols(), pe.groundTruth.Rows()),
+			"result_size", fmt.Sprintf("%dx%d", result.Cols(), result.Rows()))
+		return gray, MetricsReferenceGrayscale
+	}
+	return *pe.groundTruth, MetricsReferenceGroundTruth
+}
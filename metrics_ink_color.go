@@ -0,0 +1,324 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+
+	"gocv.io/x/gocv"
+)
+
+const (
+	// inkColorSamples bounds the ink pixels clustered, taken at an even
+	// stride over the page.
+	inkColorSamples = 50000
+
+	// inkColorClusters is the number of k-means clusters before clusters
+	// whose a*b* chroma lies closer than inkClusterMergeDistance are merged.
+	// Lightness is left out since the edges of one ink blend into the paper.
+	inkColorClusters        = 3
+	inkClusterMergeDistance = 15.0
+
+	// A document has several inks when clusters of at least multiInkMinShare
+	// of the ink each remain after merging.
+	multiInkMinShare = 0.05
+)
+
+// InkColorCluster is a group of similar ink colors. RGB is its mean sRGB
+// color and Lab the same color in CIE L*a*b*; Share is its part of the ink.
+type InkColorCluster struct {
+	RGB    [3]float64 `json:"rgb"`
+	Lab    [3]float64 `json:"lab"`
+	Share  float64    `json:"share"`
+	Pixels int        `json:"pixels"`
+}
+
+// InkColorStats describes the original colors under the ink of a binary
+// result. ChannelContrast is, per grayscale channel, the smallest gray
+// distance between the paper and an ink cluster of at least
+// multiInkMinShare; SuggestedChannel is the channel where that distance is
+// largest, so no ink fades into the paper.
+type InkColorStats struct {
+	InkPixels        int                `json:"ink_pixels"`
+	MeanInk          [3]float64         `json:"mean_ink_rgb"`
+	MeanPaper        [3]float64         `json:"mean_paper_rgb"`
+	Clusters         []InkColorCluster  `json:"clusters"`
+	MultiInk         bool               `json:"multi_ink"`
+	ChannelContrast  map[string]float64 `json:"channel_contrast"`
+	SuggestedChannel string             `json:"suggested_channel"`
+}
+
+// AnalyzeInkColors measures the colors of original, a BGR or BGRA image,
+// under the ink of binary, where ink is 0 and paper 255, at the same size.
+func AnalyzeInkColors(original, binary gocv.Mat) (*InkColorStats, error) {
+	if original.Channels() < 3 {
+		return nil, fmt.Errorf("ink colors: the original image has a single channel")
+	}
+	if original.Rows() != binary.Rows() || original.Cols() != binary.Cols() {
+		return nil, fmt.Errorf("ink colors: original is %dx%d but the result is %dx%d",
+			original.Cols(), original.Rows(), binary.Cols(), binary.Rows())
+	}
+	if binary.Channels() != 1 {
+		return nil, fmt.Errorf("ink colors: the result is not a binary image")
+	}
+
+	pixels := original.ToBytes()
+	mask := binary.ToBytes()
+	channels := original.Channels()
+
+	stats := &InkColorStats{}
+	var inkSum, paperSum [3]float64
+	paperPixels := 0
+	for i, value := range mask {
+		b, g, r := float64(pixels[i*channels]), float64(pixels[i*channels+1]), float64(pixels[i*channels+2])
+		if value == 0 {
+			stats.InkPixels++
+			inkSum[0], inkSum[1], inkSum[2] = inkSum[0]+r, inkSum[1]+g, inkSum[2]+b
+		} else {
+			paperPixels++
+			paperSum[0], paperSum[1], paperSum[2] = paperSum[0]+r, paperSum[1]+g, paperSum[2]+b
+		}
+	}
+	if stats.InkPixels == 0 {
+		return nil, fmt.Errorf("ink colors: the result has no ink")
+	}
+	for c := range 3 {
+		stats.MeanInk[c] = inkSum[c] / float64(stats.InkPixels)
+		if paperPixels > 0 {
+			stats.MeanPaper[c] = paperSum[c] / float64(paperPixels)
+		} else {
+			stats.MeanPaper[c] = 255
+		}
+	}
+
+	stride := max(1, stats.InkPixels/inkColorSamples)
+	samples := make([][3]float64, 0, stats.InkPixels/stride+1)
+	seen := 0
+	for i, value := range mask {
+		if value != 0 {
+			continue
+		}
+		if seen%stride == 0 {
+			samples = append(samples, [3]float64{
+				float64(pixels[i*channels+2]), float64(pixels[i*channels+1]), float64(pixels[i*channels]),
+			})
+		}
+		seen++
+	}
+
+	stats.Clusters = clusterInkColors(samples, stats.InkPixels)
+	significant := 0
+	for _, cluster := range stats.Clusters {
+		if cluster.Share >= multiInkMinShare {
+			significant++
+		}
+	}
+	stats.MultiInk = significant > 1
+
+	stats.ChannelContrast = make(map[string]float64, len(GrayscaleChannels))
+	best := -1.0
+	for _, channel := range GrayscaleChannels {
+		paper := grayChannelValue(channel, stats.MeanPaper)
+		contrast := math.Inf(1)
+		for _, cluster := range stats.Clusters {
+			if cluster.Share >= multiInkMinShare {
+				contrast = math.Min(contrast, math.Abs(paper-grayChannelValue(channel, cluster.RGB)))
+			}
+		}
+		stats.ChannelContrast[channel] = contrast
+		if contrast > best {
+			best = contrast
+			stats.SuggestedChannel = channel
+		}
+	}
+
+	return stats, nil
+}
+
+// clusterInkColors groups samples, sRGB colors, with k-means in CIE
+// L*a*b*, merges clusters that look alike and returns them largest first.
+// inkPixels scales the shares to pixel counts.
+func clusterInkColors(samples [][3]float64, inkPixels int) []InkColorCluster {
+	labs := make([][3]float64, len(samples))
+	for i, rgb := range samples {
+		labs[i] = srgbToLab(rgb)
+	}
+
+	// Farthest-point seeding keeps the result deterministic.
+	centers := [][3]float64{labs[0]}
+	for len(centers) < inkColorClusters {
+		farthest, distance := -1, 0.0
+		for i, lab := range labs {
+			if d := nearestDistance(lab, centers); d > distance {
+				farthest, distance = i, d
+			}
+		}
+		if farthest < 0 || distance == 0 {
+			break
+		}
+		centers = append(centers, labs[farthest])
+	}
+
+	assignment := make([]int, len(labs))
+	for range 10 {
+		for i, lab := range labs {
+			assignment[i] = nearestCenter(lab, centers)
+		}
+		sums := make([][3]float64, len(centers))
+		counts := make([]int, len(centers))
+		for i, lab := range labs {
+			c := assignment[i]
+			counts[c]++
+			for k := range 3 {
+				sums[c][k] += lab[k]
+			}
+		}
+		for c := range centers {
+			if counts[c] > 0 {
+				for k := range 3 {
+					centers[c][k] = sums[c][k] / float64(counts[c])
+				}
+			}
+		}
+	}
+
+	// Merge clusters that ended up close; each group maps to its first
+	// member.
+	group := make([]int, len(centers))
+	for c := range centers {
+		group[c] = c
+		for earlier := 0; earlier < c; earlier++ {
+			if group[earlier] == earlier && chromaDistance(centers[c], centers[earlier]) < inkClusterMergeDistance {
+				group[c] = earlier
+				break
+			}
+		}
+	}
+
+	rgbSums := make([][3]float64, len(centers))
+	counts := make([]int, len(centers))
+	for i, rgb := range samples {
+		g := group[assignment[i]]
+		counts[g]++
+		for k := range 3 {
+			rgbSums[g][k] += rgb[k]
+		}
+	}
+
+	var clusters []InkColorCluster
+	for g, count := range counts {
+		if count == 0 {
+			continue
+		}
+		var rgb [3]float64
+		for k := range 3 {
+			rgb[k] = rgbSums[g][k] / float64(count)
+		}
+		share := float64(count) / float64(len(samples))
+		clusters = append(clusters, InkColorCluster{
+			RGB:    rgb,
+			Lab:    srgbToLab(rgb),
+			Share:  share,
+			Pixels: int(math.Round(share * float64(inkPixels))),
+		})
+	}
+	sort.Slice(clusters, func(i, j int) bool { return clusters[i].Share > clusters[j].Share })
+	return clusters
+}
+
+func nearestCenter(lab [3]float64, centers [][3]float64) int {
+	nearest, distance := 0, math.Inf(1)
+	for c, center := range centers {
+		if d := labDistance(lab, center); d < distance {
+			nearest, distance = c, d
+		}
+	}
+	return nearest
+}
+
+func nearestDistance(lab [3]float64, centers [][3]float64) float64 {
+	return labDistance(lab, centers[nearestCenter(lab, centers)])
+}
+
+func labDistance(a, b [3]float64) float64 {
+	return math.Sqrt((a[0]-b[0])*(a[0]-b[0]) + (a[1]-b[1])*(a[1]-b[1]) + (a[2]-b[2])*(a[2]-b[2]))
+}
+
+func chromaDistance(a, b [3]float64) float64 {
+	return math.Hypot(a[1]-b[1], a[2]-b[2])
+}
+
+// srgbToLab converts an 8-bit sRGB color to CIE L*a*b* under D65.
+func srgbToLab(rgb [3]float64) [3]float64 {
+	var linear [3]float64
+	for i, value := range rgb {
+		v := value / 255
+		if v <= 0.04045 {
+			linear[i] = v / 12.92
+		} else {
+			linear[i] = math.Pow((v+0.055)/1.055, 2.4)
+		}
+	}
+
+	x := (0.4124*linear[0] + 0.3576*linear[1] + 0.1805*linear[2]) / 0.95047
+	y := 0.2126*linear[0] + 0.7152*linear[1] + 0.0722*linear[2]
+	z := (0.0193*linear[0] + 0.1192*linear[1] + 0.9505*linear[2]) / 1.08883
+
+	f := func(t float64) float64 {
+		if t > 216.0/24389 {
+			return math.Cbrt(t)
+		}
+		return (24389.0/27*t + 16) / 116
+	}
+	fx, fy, fz := f(x), f(y), f(z)
+	return [3]float64{116*fy - 16, 500 * (fx - fy), 200 * (fy - fz)}
+}
+
+// grayChannelValue is the gray level, from 0 to 255, that the grayscale
+// conversion channel gives an sRGB color; see extractGrayChannel.
+func grayChannelValue(channel string, rgb [3]float64) float64 {
+	switch channel {
+	case GrayChannelRed:
+		return rgb[0]
+	case GrayChannelGreen:
+		return rgb[1]
+	case GrayChannelBlue:
+		return rgb[2]
+	case GrayChannelLightness:
+		return srgbToLab(rgb)[0] * 255 / 100
+	case GrayChannelValue:
+		return math.Max(rgb[0], math.Max(rgb[1], rgb[2]))
+	}
+	return 0.299*rgb[0] + 0.587*rgb[1] + 0.114*rgb[2]
+}
+
+// InkColors measures the ink colors of the latest result against the
+// loaded image.
+func (pe *ProcessingEngine) InkColors() (*InkColorStats, error) {
+	original := pe.GetOriginalImage()
+	processed := pe.GetProcessedImage()
+	if original == nil || processed == nil {
+		return nil, fmt.Errorf("ink colors: process an image first")
+	}
+	return AnalyzeInkColors(original.Mat, processed.Mat)
+}
+
+// Write stores the statistics as JSON at path, or on stdout for "-".
+func (ics *InkColorStats) Write(path string) error {
+	data, err := json.MarshalIndent(ics, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode ink colors: %w", err)
+	}
+	data = append(data, '\n')
+
+	if path == "-" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("write ink colors: %w", err)
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/binary"
 	"fmt"
 
 	"gocv.io/x/gocv"
@@ -87,3 +88,18 @@ func performMatrixOperation(mat1, mat2 gocv.Mat, operation string) (gocv.Mat, er
 
 	return result, nil
 }
+
+// labelValues returns the CV_32S label image written by the connected
+// component functions as one value per pixel in row-major order.
+func labelValues(labels gocv.Mat) ([]int32, error) {
+	if labels.Type() != gocv.MatTypeCV32S {
+		return nil, fmt.Errorf("label image has type %v, want CV_32S", labels.Type())
+	}
+
+	data := labels.ToBytes()
+	values := make([]int32, len(data)/4)
+	for i := range values {
+		values[i] = int32(binary.LittleEndian.Uint32(data[i*4:]))
+	}
+	return values, nil
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// MetricsSchemaVersion is the version of the MetricsReport JSON. Adding a
+// field keeps the version, so consumers must ignore keys they do not know;
+// renaming, removing or changing the meaning of a field increments it.
+const MetricsSchemaVersion = 1
+
+var metricsFieldDescriptions = map[string]string{
+	"schema_version":   "Version of this schema the report follows",
+	"f_measure":        "Harmonic mean of precision and recall, 0 to 1",
+	"pseudo_f_measure": "F-measure weighted by distance to the ground truth skeleton (beta 0.5), 0 to 1",
+	"nrm":              "Negative rate metric, (FN+FP)/(2*(TP+TN)), lower is better",
+	"drd":              "Distance reciprocal distortion with a 5x5 weight matrix, lower is better",
+	"mpm":              "Morphological path misalignment, lower is better",
+	"bfc":              "Background/foreground contrast",
+	"skeleton":         "Similarity of the result and ground truth skeletons, 0 to 1",
+	"precision":        "Share of result ink that is ground truth ink, 0 to 1",
+	"recall":           "Share of ground truth ink found in the result, 0 to 1",
+	"true_positives":   "Ink pixels in both images",
+	"true_negatives":   "Paper pixels in both images",
+	"false_positives":  "Ink pixels only in the result",
+	"false_negatives":  "Ink pixels only in the ground truth",
+	"total_pixels":     "Pixels compared",
+	"reference":        "What the result was compared with: ground_truth for a reference binarization, grayscale for the page itself",
+}
+
+// metricsSchemaField is one JSON property of MetricsReport.
+type metricsSchemaField struct {
+	name     string
+	jsonType string
+	optional bool
+}
+
+// metricsSchemaFields lists the properties of MetricsReport in field order,
+// read from its json tags so the schema follows the struct.
+func metricsSchemaFields() []metricsSchemaField {
+	reportType := reflect.TypeOf(MetricsReport{})
+	fields := make([]metricsSchemaField, 0, reportType.NumField())
+	for i := 0; i < reportType.NumField(); i++ {
+		field := reportType.Field(i)
+		tag := strings.Split(field.Tag.Get("json"), ",")
+		name := tag[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		jsonType := "number"
+		switch field.Type.Kind() {
+		case reflect.Int, reflect.Int64:
+			jsonType = "integer"
+		case reflect.String:
+			jsonType = "string"
+		}
+		fields = append(fields, metricsSchemaField{
+			name:     name,
+			jsonType: jsonType,
+			optional: len(tag) > 1 && tag[1] == "omitempty",
+		})
+	}
+	return fields
+}
+
+// MetricsSchema returns the JSON Schema (draft 2020-12) of MetricsReport at
+// MetricsSchemaVersion.
+func MetricsSchema() map[string]any {
+	properties := make(map[string]any)
+	var required []string
+	for _, field := range metricsSchemaFields() {
+		property := map[string]any{"type": field.jsonType}
+		if description, ok := metricsFieldDescriptions[field.name]; ok {
+			property["description"] = description
+		}
+		switch {
+		case field.name == "schema_version":
+			property["const"] = MetricsSchemaVersion
+		case field.name == "reference":
+			property["enum"] = []string{MetricsReferenceGroundTruth, MetricsReferenceGrayscale}
+		case field.jsonType == "integer":
+			property["minimum"] = 0
+		}
+		properties[field.name] = property
+		if !field.optional {
+			required = append(required, field.name)
+		}
+	}
+
+	return map[string]any{
+		"$schema":              "https://json-schema.org/draft/2020-12/schema",
+		"$id":                  fmt.Sprintf("urn:otsu-obliterator:metrics:v%d", MetricsSchemaVersion),
+		"title":                AppName + " metrics report",
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": true,
+	}
+}
+
+// ValidateMetricsJSON checks a metrics report against MetricsSchema and
+// returns every problem found. Keys the schema does not know are allowed.
+func ValidateMetricsJSON(data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var report map[string]any
+	if err := decoder.Decode(&report); err != nil {
+		return fmt.Errorf("decode metrics: %w", err)
+	}
+
+	if value, ok := report["schema_version"].(json.Number); ok {
+		if version, err := value.Int64(); err == nil && version != MetricsSchemaVersion {
+			return fmt.Errorf("metrics schema version %d: this build reads version %d", version, MetricsSchemaVersion)
+		}
+	}
+
+	var problems []string
+	for _, field := range metricsSchemaFields() {
+		value, ok := report[field.name]
+		if !ok {
+			if !field.optional {
+				problems = append(problems, field.name+" is missing")
+			}
+			continue
+		}
+		if field.jsonType == "string" {
+			if _, ok := value.(string); !ok {
+				problems = append(problems, fmt.Sprintf("%s is not a string", field.name))
+			}
+			continue
+		}
+		number, ok := value.(json.Number)
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s is not a number", field.name))
+			continue
+		}
+		if field.jsonType == "integer" {
+			if count, err := number.Int64(); err != nil {
+				problems = append(problems, fmt.Sprintf("%s is not an integer", field.name))
+			} else if count < 0 {
+				problems = append(problems, fmt.Sprintf("%s is negative", field.name))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid metrics: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// publishedMetricsSchema returns MetricsSchema as consumers read it from
+// 'schema metrics'.
+func publishedMetricsSchema(t *testing.T) map[string]any {
+	t.Helper()
+	data, err := json.Marshal(MetricsSchema())
+	if err != nil {
+		t.Fatalf("marshal schema: %v", err)
+	}
+	var schema map[string]any
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatalf("unmarshal schema: %v", err)
+	}
+	return schema
+}
+
+func sampleMetricsJSON(t *testing.T) []byte {
+	t.Helper()
+	metrics := &BinaryImageMetrics{
+		Reference:      MetricsReferenceGroundTruth,
+		TruePositives:  40,
+		TrueNegatives:  50,
+		FalsePositives: 6,
+		FalseNegatives: 4,
+		TotalPixels:    100,
+		drdValue:       1.25,
+		mpmValue:       0.5,
+		pbcValue:       0.75,
+		skeletonValue:  0.9,
+	}
+	data, err := json.Marshal(NewMetricsReport(metrics))
+	if err != nil {
+		t.Fatalf("marshal metrics: %v", err)
+	}
+	return data
+}
+
+func TestMetricsReportMatchesPublishedSchema(t *testing.T) {
+	schema := publishedMetricsSchema(t)
+	properties, ok := schema["properties"].(map[string]any)
+	if !ok {
+		t.Fatalf("schema properties are %T, want an object", schema["properties"])
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(sampleMetricsJSON(t)))
+	decoder.UseNumber()
+	var report map[string]any
+	if err := decoder.Decode(&report); err != nil {
+		t.Fatalf("decode metrics: %v", err)
+	}
+
+	required, _ := schema["required"].([]any)
+	for _, name := range required {
+		if _, ok := report[name.(string)]; !ok {
+			t.Errorf("required field %s is missing from the report", name)
+		}
+	}
+	if !slices.Contains(required, any("schema_version")) {
+		t.Error("schema_version is not required")
+	}
+
+	for name, value := range report {
+		property, ok := properties[name].(map[string]any)
+		if !ok {
+			t.Errorf("report field %s is not in the schema", name)
+			continue
+		}
+		switch property["type"] {
+		case "string":
+			if _, ok := value.(string); !ok {
+				t.Errorf("%s is %T, schema type is string", name, value)
+			}
+		case "integer":
+			number, ok := value.(json.Number)
+			if _, err := number.Int64(); !ok || err != nil {
+				t.Errorf("%s = %v, schema type is integer", name, value)
+			}
+		case "number":
+			number, ok := value.(json.Number)
+			if _, err := number.Float64(); !ok || err != nil {
+				t.Errorf("%s = %v, schema type is number", name, value)
+			}
+		default:
+			t.Errorf("%s has unexpected schema type %v", name, property["type"])
+		}
+		if allowed, ok := property["enum"].([]any); ok && !slices.Contains(allowed, value) {
+			t.Errorf("%s = %v, schema allows %v", name, value, allowed)
+		}
+	}
+
+	version, _ := report["schema_version"].(json.Number)
+	if got, err := version.Int64(); err != nil || got != MetricsSchemaVersion {
+		t.Errorf("schema_version = %v, want %d", report["schema_version"], MetricsSchemaVersion)
+	}
+	versionProperty, _ := properties["schema_version"].(map[string]any)
+	if constant, _ := versionProperty["const"].(float64); int(constant) != MetricsSchemaVersion {
+		t.Errorf("schema pins schema_version to %v, want %d", versionProperty["const"], MetricsSchemaVersion)
+	}
+
+	if err := ValidateMetricsJSON(sampleMetricsJSON(t)); err != nil {
+		t.Errorf("ValidateMetricsJSON: %v", err)
+	}
+}
+
+func TestValidateMetricsJSONRejectsInvalidReports(t *testing.T) {
+	valid := sampleMetricsJSON(t)
+
+	tests := []struct {
+		name   string
+		edit   func(report map[string]any)
+		reason string
+	}{
+		{"missing field", func(r map[string]any) { delete(r, "f_measure") }, "f_measure is missing"},
+		{"string for number", func(r map[string]any) { r["drd"] = "low" }, "drd is not a number"},
+		{"fractional count", func(r map[string]any) { r["total_pixels"] = 1.5 }, "total_pixels is not an integer"},
+		{"negative count", func(r map[string]any) { r["false_positives"] = -1 }, "false_positives is negative"},
+		{"number for string", func(r map[string]any) { r["reference"] = 1 }, "reference is not a string"},
+		{"other version", func(r map[string]any) { r["schema_version"] = MetricsSchemaVersion + 1 }, "this build reads version"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var report map[string]any
+			if err := json.Unmarshal(valid, &report); err != nil {
+				t.Fatal(err)
+			}
+			tt.edit(report)
+			data, err := json.Marshal(report)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = ValidateMetricsJSON(data)
+			if err == nil || !strings.Contains(err.Error(), tt.reason) {
+				t.Errorf("ValidateMetricsJSON error = %v, want one mentioning %q", err, tt.reason)
+			}
+		})
+	}
+}
+
+func TestValidateMetricsJSONAllowsUnknownKeys(t *testing.T) {
+	var report map[string]any
+	if err := json.Unmarshal(sampleMetricsJSON(t), &report); err != nil {
+		t.Fatal(err)
+	}
+	report["added_later"] = true
+	data, err := json.Marshal(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ValidateMetricsJSON(data); err != nil {
+		t.Errorf("ValidateMetricsJSON rejected an unknown key: %v", err)
+	}
+}
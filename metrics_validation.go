@@ -2,6 +2,8 @@ package main
 
 import (
 	"fmt"
+	"slices"
+	"strings"
 
 	"gocv.io/x/gocv"
 )
@@ -381,97 +383,178 @@ func validateOtsuParameters(params *OtsuParameters, imageSize [2]int) error {
 		return err
 	}
 
-	if params.WindowSize < 3 || params.WindowSize > 21 {
-		return &ValidationError{
-			Context: "parameter validation",
-			Field:   "WindowSize",
-			Value:   params.WindowSize,
-			Reason:  "must be between 3 and 21",
-		}
+	if fieldErrors := validateParameterFields(params); len(fieldErrors) > 0 {
+		return fieldErrors[0]
 	}
 
-	if params.WindowSize%2 == 0 {
+	if params.WindowSize >= min(width, height) {
 		return &ValidationError{
 			Context: "parameter validation",
 			Field:   "WindowSize",
 			Value:   params.WindowSize,
-			Reason:  "must be odd number",
+			Reason:  fmt.Sprintf("must be smaller than image dimensions %dx%d", width, height),
 		}
 	}
 
-	if params.WindowSize >= min(width, height) {
-		return &ValidationError{
+	return nil
+}
+
+// validateParameterFields checks every image-independent field and reports
+// all failures instead of stopping at the first one.
+func validateParameterFields(params *OtsuParameters) []*ValidationError {
+	var fieldErrors []*ValidationError
+
+	fail := func(field string, value interface{}, reason string) {
+		fieldErrors = append(fieldErrors, &ValidationError{
 			Context: "parameter validation",
-			Field:   "WindowSize",
-			Value:   params.WindowSize,
-			Reason:  fmt.Sprintf("must be smaller than image dimensions %dx%d", width, height),
-		}
+			Field:   field,
+			Value:   value,
+			Reason:  reason,
+		})
+	}
+
+	if params.WindowSize < 3 || params.WindowSize > 21 {
+		fail("WindowSize", params.WindowSize, "must be between 3 and 21")
+	} else if params.WindowSize%2 == 0 {
+		fail("WindowSize", params.WindowSize, "must be odd number")
 	}
 
 	if params.HistogramBins < 0 || params.HistogramBins > 256 {
-		return &ValidationError{
-			Context: "parameter validation",
-			Field:   "HistogramBins",
-			Value:   params.HistogramBins,
-			Reason:  "must be 0 (auto) or between 1 and 256",
-		}
+		fail("HistogramBins", params.HistogramBins, "must be 0 (auto) or between 1 and 256")
+	}
+
+	if params.NeighborhoodBins != 0 && (params.NeighborhoodBins < minHistogramAxisBins || params.NeighborhoodBins > 256) {
+		fail("NeighborhoodBins", params.NeighborhoodBins, fmt.Sprintf("must be 0 (same as HistogramBins) or between %d and 256", minHistogramAxisBins))
 	}
 
 	if params.SmoothingStrength < 0.0 || params.SmoothingStrength > 10.0 {
-		return &ValidationError{
-			Context: "parameter validation",
-			Field:   "SmoothingStrength",
-			Value:   params.SmoothingStrength,
-			Reason:  "must be between 0.0 and 10.0",
-		}
+		fail("SmoothingStrength", params.SmoothingStrength, "must be between 0.0 and 10.0")
 	}
 
 	if params.PyramidLevels < 1 || params.PyramidLevels > 8 {
-		return &ValidationError{
-			Context: "parameter validation",
-			Field:   "PyramidLevels",
-			Value:   params.PyramidLevels,
-			Reason:  "must be between 1 and 8",
-		}
+		fail("PyramidLevels", params.PyramidLevels, "must be between 1 and 8")
 	}
 
 	if params.DiffusionIterations < 1 || params.DiffusionIterations > 50 {
-		return &ValidationError{
-			Context: "parameter validation",
-			Field:   "DiffusionIterations",
-			Value:   params.DiffusionIterations,
-			Reason:  "must be between 1 and 50",
-		}
+		fail("DiffusionIterations", params.DiffusionIterations, "must be between 1 and 50")
 	}
 
 	if params.DiffusionKappa < 1.0 || params.DiffusionKappa > 200.0 {
-		return &ValidationError{
-			Context: "parameter validation",
-			Field:   "DiffusionKappa",
-			Value:   params.DiffusionKappa,
-			Reason:  "must be between 1.0 and 200.0",
-		}
+		fail("DiffusionKappa", params.DiffusionKappa, "must be between 1.0 and 200.0")
 	}
 
 	if params.RegionGridSize < 16 || params.RegionGridSize > 512 {
-		return &ValidationError{
-			Context: "parameter validation",
-			Field:   "RegionGridSize",
-			Value:   params.RegionGridSize,
-			Reason:  "must be between 16 and 512",
-		}
+		fail("RegionGridSize", params.RegionGridSize, "must be between 16 and 512")
 	}
 
 	if params.MorphologicalKernelSize < 1 || params.MorphologicalKernelSize > 15 {
-		return &ValidationError{
-			Context: "parameter validation",
-			Field:   "MorphologicalKernelSize",
-			Value:   params.MorphologicalKernelSize,
-			Reason:  "must be between 1 and 15",
+		fail("MorphologicalKernelSize", params.MorphologicalKernelSize, "must be between 1 and 15")
+	}
+
+	if params.Brightness < -100 || params.Brightness > 100 {
+		fail("Brightness", params.Brightness, "must be between -100 and 100")
+	}
+
+	if params.Contrast < 0.25 || params.Contrast > 4.0 {
+		fail("Contrast", params.Contrast, "must be between 0.25 and 4.0")
+	}
+
+	if params.Gamma < 0.2 || params.Gamma > 5.0 {
+		fail("Gamma", params.Gamma, "must be between 0.2 and 5.0")
+	}
+
+	switch params.ShadowRemovalMethod {
+	case ShadowMethodGaussian, ShadowMethodMorphological:
+	default:
+		fail("ShadowRemovalMethod", params.ShadowRemovalMethod, "must be Gaussian or Morphological")
+	}
+
+	if params.GrayscaleChannel != "" && !slices.Contains(GrayscaleChannels, params.GrayscaleChannel) {
+		fail("GrayscaleChannel", params.GrayscaleChannel, "must be one of "+strings.Join(GrayscaleChannels, ", "))
+	}
+
+	if params.ShadowRemovalStrength < 0.0 || params.ShadowRemovalStrength > 1.0 {
+		fail("ShadowRemovalStrength", params.ShadowRemovalStrength, "must be between 0.0 and 1.0")
+	}
+
+	if params.DewarpCurvature < 0.05 || params.DewarpCurvature > 1.0 {
+		fail("DewarpCurvature", params.DewarpCurvature, "must be between 0.05 and 1.0")
+	}
+
+	if params.StainHueMin < 0 || params.StainHueMin > 360 {
+		fail("StainHueMin", params.StainHueMin, "must be between 0 and 360 degrees")
+	}
+
+	if params.StainHueMax < 0 || params.StainHueMax > 360 {
+		fail("StainHueMax", params.StainHueMax, "must be between 0 and 360 degrees")
+	}
+
+	if params.StainStrength < 0.0 || params.StainStrength > 1.0 {
+		fail("StainStrength", params.StainStrength, "must be between 0.0 and 1.0")
+	}
+
+	if params.DropoutTolerance < 5 || params.DropoutTolerance > 60 {
+		fail("DropoutTolerance", params.DropoutTolerance, "must be between 5 and 60 degrees")
+	}
+
+	validateMorphOperations(params.PostProcessing, fail)
+	validateExternalStages(params.ExternalStages, fail)
+
+	if params.MaxHoleArea < 1 || params.MaxHoleArea > 10000 {
+		fail("MaxHoleArea", params.MaxHoleArea, "must be between 1 and 10000 pixels")
+	}
+
+	if params.MaxGapSize < 1 || params.MaxGapSize > 5 {
+		fail("MaxGapSize", params.MaxGapSize, "must be between 1 and 5 pixels")
+	}
+
+	if params.PhotoZones != "" && !slices.Contains(PhotoZoneModes, params.PhotoZones) {
+		fail("PhotoZones", params.PhotoZones, "must be one of "+strings.Join(PhotoZoneModes, ", "))
+	}
+
+	if !slices.Contains(NeighborhoodTypes, params.NeighborhoodType) {
+		fail("NeighborhoodType", params.NeighborhoodType, "must be one of "+strings.Join(NeighborhoodTypes, ", "))
+	}
+	if params.FeaturePairing != "" && !slices.Contains(FeaturePairings, params.FeaturePairing) {
+		fail("FeaturePairing", params.FeaturePairing, "must be one of "+strings.Join(FeaturePairings, ", "))
+	}
+	if params.LegacyNeighborhoods && (params.NeighborhoodType == NeighborhoodGaussian || params.NeighborhoodType == NeighborhoodMedian) {
+		fail("LegacyNeighborhoods", true, "applies only to the Rectangular, Circular and Distance Weighted neighborhoods")
+	}
+
+	if params.NeuralThreshold <= 0 || params.NeuralThreshold >= 1 {
+		fail("NeuralThreshold", params.NeuralThreshold, "must be between 0.0 and 1.0, exclusive")
+	}
+
+	if params.NeuralBinarization {
+		if params.NeuralModel == "" {
+			fail("NeuralModel", params.NeuralModel, "must name an ONNX model file")
+		}
+		if params.MultiScaleProcessing || params.RegionAdaptiveThresholding {
+			fail("NeuralBinarization", true, "cannot be combined with MultiScaleProcessing or RegionAdaptiveThresholding")
 		}
 	}
 
-	return nil
+	if params.Otsu3DBins != 0 && (params.Otsu3DBins < minOtsu3DBins || params.Otsu3DBins > maxOtsu3DBins) {
+		fail("Otsu3DBins", params.Otsu3DBins, fmt.Sprintf("must be between %d and %d", minOtsu3DBins, maxOtsu3DBins))
+	}
+	if params.Otsu3D && (params.MultiScaleProcessing || params.RegionAdaptiveThresholding || params.NeuralBinarization) {
+		fail("Otsu3D", true, "cannot be combined with MultiScaleProcessing, RegionAdaptiveThresholding or NeuralBinarization")
+	}
+
+	if params.EntropyMethod != "" {
+		if !slices.Contains(EntropyMethods, params.EntropyMethod) {
+			fail("EntropyMethod", params.EntropyMethod, "must be empty or one of "+strings.Join(EntropyMethods, ", "))
+		}
+		if params.MultiScaleProcessing || params.RegionAdaptiveThresholding || params.NeuralBinarization || params.Otsu3D {
+			fail("EntropyMethod", params.EntropyMethod, "cannot be combined with MultiScaleProcessing, RegionAdaptiveThresholding, NeuralBinarization or Otsu3D")
+		}
+	}
+	if params.TsallisQ <= 0 || params.TsallisQ > maxTsallisQ {
+		fail("TsallisQ", params.TsallisQ, fmt.Sprintf("must be above 0 and at most %.1f", maxTsallisQ))
+	}
+
+	return fieldErrors
 }
 
 func validateImageDimensions(width, height int, context string) error {
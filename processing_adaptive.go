@@ -25,6 +25,21 @@ func (pe *ProcessingEngine) calculateAdaptiveWindowSize(src gocv.Mat) int {
 	}
 }
 
+// histogramBins resolves the pixel and neighborhood bin counts of the 2D
+// histogram of src. HistogramBins of 0 picks the pixel count from the image
+// size, and NeighborhoodBins of 0 follows the pixel count.
+func (pe *ProcessingEngine) histogramBins(src gocv.Mat, params *OtsuParameters) [2]int {
+	pixelBins := params.HistogramBins
+	if pixelBins == 0 {
+		pixelBins = pe.calculateHistogramBins(src)
+	}
+	neighborhoodBins := params.NeighborhoodBins
+	if neighborhoodBins == 0 {
+		neighborhoodBins = pixelBins
+	}
+	return [2]int{pixelBins, neighborhoodBins}
+}
+
 func (pe *ProcessingEngine) calculateHistogramBins(src gocv.Mat) int {
 	if err := validateMatForMetrics(src, "histogram bins calculation"); err != nil {
 		return 64 // safe default
@@ -44,25 +59,9 @@ func (pe *ProcessingEngine) calculateHistogramBins(src gocv.Mat) int {
 	}
 }
 
-func (pe *ProcessingEngine) calculateNeighborhood(src gocv.Mat, windowSize int, neighborhoodType string) gocv.Mat {
-	if err := validateMatForMetrics(src, "neighborhood calculation"); err != nil {
-		return gocv.NewMat()
-	}
-
-	if err := validateImageDimensions(src.Cols(), src.Rows(), "neighborhood calculation"); err != nil {
-		return gocv.NewMat()
-	}
-
-	switch neighborhoodType {
-	case "Circular":
-		return pe.calculateCircularNeighborhood(src, windowSize)
-	case "Distance Weighted":
-		return pe.calculateDistanceWeightedNeighborhood(src, windowSize)
-	default:
-		return pe.calculateRectangularNeighborhood(src, windowSize)
-	}
-}
-
+// calculateRectangularNeighborhood, calculateCircularNeighborhood and
+// calculateDistanceWeightedNeighborhood are the original per-pixel loops,
+// run only for LegacyNeighborhoods; see calculateNeighborhood.
 func (pe *ProcessingEngine) calculateRectangularNeighborhood(src gocv.Mat, windowSize int) gocv.Mat {
 	result := gocv.NewMatWithSize(src.Rows(), src.Cols(), gocv.MatTypeCV8UC1)
 
@@ -6,18 +6,21 @@ import (
 	"math"
 
 	"gocv.io/x/gocv"
+
+	"otsu-obliterator/progress"
 )
 
-// Complete region adaptive processing implementation
-func (pe *ProcessingEngine) processRegionAdaptive(src gocv.Mat, params *OtsuParameters) gocv.Mat {
+// Complete region adaptive processing implementation. The audit records
+// the decision taken for each region.
+func (pe *ProcessingEngine) processRegionAdaptive(reporter progress.Reporter, src gocv.Mat, params *OtsuParameters) (gocv.Mat, *RegionAudit) {
 	if err := validateMatForMetrics(src, "region adaptive processing"); err != nil {
-		return gocv.NewMat()
+		return gocv.NewMat(), nil
 	}
 
 	rows, cols := src.Rows(), src.Cols()
 
 	if err := validateImageDimensions(cols, rows, "region adaptive dimensions"); err != nil {
-		return gocv.NewMat()
+		return gocv.NewMat(), nil
 	}
 
 	debugSystem := GetDebugSystem()
@@ -27,20 +30,25 @@ func (pe *ProcessingEngine) processRegionAdaptive(src gocv.Mat, params *OtsuPara
 
 	if useOverlapping {
 		debugSystem.logger.Info("using overlapping regions for complex image")
-		return pe.processOverlappingRegions(src, params)
+		return pe.processOverlappingRegions(reporter, src, params)
 	}
 
 	// Standard non-overlapping region processing
 	gridSize := pe.calculateAdaptiveGridSize(src)
 
-	if gridSize > intMin(rows, cols)/2 {
+	// Two cells must fit across the short side; fitGridToAspect rounds up.
+	if gridSize > (intMin(rows, cols)+1)/2 {
 		debugSystem.logger.Warn("grid size too large for image dimensions, falling back to single scale",
 			"grid_size", gridSize,
 			"image_rows", rows,
 			"image_cols", cols)
-		return pe.processSingleScaleAdaptive(src, params)
+		audit := newRegionAudit(cols, rows, gridSize, 0)
+		audit.Fallback = RegionFallbackSingleScale
+		return pe.processSingleScaleAdaptive(src, params), audit
 	}
 
+	audit := newRegionAudit(cols, rows, gridSize, 0)
+
 	// Initialize result matrix to background (BLACK = 0)
 	result := gocv.NewMatWithSize(rows, cols, gocv.MatTypeCV8UC1)
 	backgroundScalar := gocv.NewScalar(255, 0, 0, 0) // WHITE is the proper background for art and text
@@ -52,88 +60,39 @@ func (pe *ProcessingEngine) processRegionAdaptive(src gocv.Mat, params *OtsuPara
 	lowContrastRegions := 0
 	totalContrast := 0.0
 
-	// Debug tracking
-	totalForegroundPixels := 0
-	totalBackgroundPixels := 0
-
-	// Process regions using efficient row/column operations
-	for y := 0; y < rows; y += gridSize {
-		endY := intMin(y+gridSize, rows)
-
-		for x := 0; x < cols; x += gridSize {
-			endX := intMin(x+gridSize, cols)
-
-			// Extract region using matrix slicing
-			srcRegion := src.Region(image.Rect(x, y, endX, endY))
-
-			if srcRegion.Rows() < 16 || srcRegion.Cols() < 16 {
-				srcRegion.Close()
+	// Regions are thresholded concurrently and copied into result in grid
+	// order.
+	err := runRegionPool(reporter, "regions", regionTiles(rows, cols, gridSize, 0),
+		func(bounds image.Rectangle) regionOutcome {
+			return pe.thresholdRegion(src, bounds, params)
+		},
+		func(bounds image.Rectangle, outcome regionOutcome) {
+			defer outcome.close()
+			switch outcome.entry.Decision {
+			case RegionTooSmall:
 				regionErrors++
-				continue
-			}
-
-			hasContrast, contrast, _ := pe.validateRegionContrastAdaptive(srcRegion)
-			totalContrast += contrast
-
-			if !hasContrast {
+			case RegionLowContrast:
+				// Region remains initialized background
 				lowContrastRegions++
-				debugSystem.logger.Debug("region quality analysis",
-					"x", x, "y", y,
-					"width", endX-x, "height", endY-y,
-					"has_contrast", false,
-					"contrast", contrast,
-					"entropy", 0)
-
-				srcRegion.Close()
 				regionsSkipped++
-				// Region remains initialized background (BLACK) - consistent
-				regionPixels := (endX - x) * (endY - y)
-				totalBackgroundPixels += regionPixels
-				continue
-			}
-
-			debugSystem.logger.Debug("region quality analysis",
-				"x", x, "y", y,
-				"width", endX-x, "height", endY-y,
-				"has_contrast", true,
-				"contrast", contrast,
-				"entropy", 0)
-
-			regionParams := *params
-			regionParams.RegionAdaptiveThresholding = false
-			regionResult := pe.processSingleScaleAdaptive(srcRegion, &regionParams)
-
-			if !regionResult.Empty() {
-				// Count pixels in this region result
-				regionForeground, regionErr := calculateSafeCountNonZero(regionResult, "region result")
-				if regionErr == nil {
-					regionPixels := (endX - x) * (endY - y)
-					regionBackground := regionPixels - regionForeground
-					totalForegroundPixels += regionForeground
-					totalBackgroundPixels += regionBackground
-
-					debugSystem.logger.Debug("region processing result",
-						"x", x, "y", y,
-						"region_pixels", regionPixels,
-						"foreground_pixels", regionForeground,
-						"background_pixels", regionBackground,
-						"foreground_ratio", float64(regionForeground)/float64(regionPixels))
-				}
-
-				dstRegion := result.Region(image.Rect(x, y, endX, endY))
-				regionResult.CopyTo(&dstRegion)
+			case RegionThresholded:
+				dstRegion := result.Region(bounds)
+				outcome.result.CopyTo(&dstRegion)
 				dstRegion.Close()
 				regionsProcessed++
-			} else {
-				regionErrors++
+			default:
 				// Failed region remains background
-				regionPixels := (endX - x) * (endY - y)
-				totalBackgroundPixels += regionPixels
+				regionErrors++
 			}
-
-			srcRegion.Close()
-			regionResult.Close()
-		}
+			if outcome.entry.Decision != RegionTooSmall {
+				totalContrast += outcome.entry.Contrast
+			}
+			audit.add(bounds, outcome.entry)
+		},
+		regionOutcome.close)
+	if err != nil {
+		result.Close()
+		return gocv.NewMat(), audit
 	}
 
 	totalRegions := regionsProcessed + regionErrors + regionsSkipped
@@ -179,20 +138,81 @@ func (pe *ProcessingEngine) processRegionAdaptive(src gocv.Mat, params *OtsuPara
 		globalResult := gocv.NewMat()
 		gocv.Threshold(src, &globalResult, 0, 255, gocv.ThresholdBinary+gocv.ThresholdOtsu)
 		debugSystem.logger.Info("applied global Otsu fallback")
-		return globalResult
+		audit.Fallback = RegionFallbackGlobalOtsu
+		return globalResult, audit
 	}
 
 	if err := validateMatForMetrics(result, "region adaptive result"); err != nil {
 		result.Close()
-		return gocv.NewMat()
+		return gocv.NewMat(), audit
 	}
 
-	return result
+	return result, audit
+}
+
+// thresholdRegion thresholds the region of src within bounds on its own
+// and records the decision. It only reads src, so regions can run
+// concurrently.
+func (pe *ProcessingEngine) thresholdRegion(src gocv.Mat, bounds image.Rectangle, params *OtsuParameters) regionOutcome {
+	srcRegion := src.Region(bounds)
+	defer srcRegion.Close()
+
+	if srcRegion.Rows() < 16 || srcRegion.Cols() < 16 {
+		return regionOutcome{entry: RegionAuditEntry{Decision: RegionTooSmall}, result: gocv.NewMat()}
+	}
+
+	hasContrast, contrast, _ := pe.validateRegionContrastAdaptive(srcRegion)
+	entropy := regionEntropy(srcRegion)
+
+	debugSystem := GetDebugSystem()
+	debugSystem.logger.Debug("region quality analysis",
+		"x", bounds.Min.X, "y", bounds.Min.Y,
+		"width", bounds.Dx(), "height", bounds.Dy(),
+		"has_contrast", hasContrast,
+		"contrast", contrast,
+		"entropy", entropy)
+
+	entry := RegionAuditEntry{Contrast: contrast, Entropy: entropy, Decision: RegionLowContrast}
+	if !hasContrast {
+		return regionOutcome{entry: entry, result: gocv.NewMat()}
+	}
+
+	regionParams := *params
+	regionParams.RegionAdaptiveThresholding = false
+	regionResult, threshold, bins := pe.processSingleScaleAdaptiveThreshold(srcRegion, &regionParams)
+	entry.Decision = RegionFailed
+	if regionResult.Empty() {
+		return regionOutcome{entry: entry, result: regionResult}
+	}
+
+	regionPixels := bounds.Dx() * bounds.Dy()
+	if paper, err := calculateSafeCountNonZero(regionResult, "region result"); err == nil {
+		debugSystem.logger.Debug("region processing result",
+			"x", bounds.Min.X, "y", bounds.Min.Y,
+			"region_pixels", regionPixels,
+			"foreground_pixels", paper,
+			"background_pixels", regionPixels-paper,
+			"foreground_ratio", float64(paper)/float64(regionPixels))
+		entry.InkRatio = float64(regionPixels-paper) / float64(regionPixels)
+	}
+
+	entry.Decision = RegionThresholded
+	entry.FallbackLevel = RegionLevelStandard
+	entry.Threshold = &threshold
+	entry.HistogramBins, entry.NeighborhoodBins = bins[0], bins[1]
+	return regionOutcome{entry: entry, result: regionResult}
 }
 
 func (pe *ProcessingEngine) processSingleScaleAdaptive(src gocv.Mat, params *OtsuParameters) gocv.Mat {
+	result, _, _ := pe.processSingleScaleAdaptiveThreshold(src, params)
+	return result
+}
+
+// processSingleScaleAdaptiveThreshold also returns the threshold it applied
+// and the pixel and neighborhood bin counts it is measured in.
+func (pe *ProcessingEngine) processSingleScaleAdaptiveThreshold(src gocv.Mat, params *OtsuParameters) (gocv.Mat, [2]int, [2]int) {
 	if err := validateMatForMetrics(src, "single scale adaptive processing"); err != nil {
-		return gocv.NewMat()
+		return gocv.NewMat(), [2]int{}, [2]int{}
 	}
 
 	windowSize := params.WindowSize
@@ -200,15 +220,11 @@ func (pe *ProcessingEngine) processSingleScaleAdaptive(src gocv.Mat, params *Ots
 		windowSize = pe.calculateAdaptiveWindowSize(src)
 	}
 
-	neighborhood := pe.calculateNeighborhood(src, windowSize, params.NeighborhoodType)
-	defer neighborhood.Close()
+	feature := pe.calculateSecondFeature(src, windowSize, params)
+	defer feature.Close()
 
-	histBins := params.HistogramBins
-	if histBins == 0 {
-		histBins = pe.calculateHistogramBins(src)
-	}
-
-	histogram := pe.build2DHistogram(src, neighborhood, histBins)
+	bins := pe.histogramBins(src, params)
+	histogram := pe.build2DHistogram(src, feature, bins)
 
 	if params.UseLogHistogram {
 		pe.applyLogScaling(histogram)
@@ -223,14 +239,14 @@ func (pe *ProcessingEngine) processSingleScaleAdaptive(src gocv.Mat, params *Ots
 	}
 
 	threshold := pe.find2DOtsuThresholdInteger(histogram)
-	result := pe.applyThreshold(src, neighborhood, threshold, histBins)
+	result := pe.applyThreshold(src, feature, threshold, bins)
 
 	if err := validateMatForMetrics(result, "single scale adaptive result"); err != nil {
 		result.Close()
-		return gocv.NewMat()
+		return gocv.NewMat(), threshold, bins
 	}
 
-	return result
+	return result, threshold, bins
 }
 
 func (pe *ProcessingEngine) validateRegionContrastAdaptive(src gocv.Mat) (bool, float64, error) {
@@ -276,32 +292,60 @@ func (pe *ProcessingEngine) calculateAdaptiveGridSize(src gocv.Mat) int {
 	contrast := calculateRegionContrast(src)
 
 	baseSize := intMin(rows, cols) / 6 // less aggressive than /8
+	extreme := isExtremeAspect(cols, rows)
+	if extreme {
+		// The short side of a receipt alone would give hundreds of tiny
+		// cells; size the grid from the area instead.
+		baseSize = int(math.Sqrt(float64(rows)*float64(cols))) / 6
+	}
 
 	debugSystem := GetDebugSystem()
 	debugSystem.logger.Debug("grid size calculation",
 		"entropy", entropy,
 		"contrast", contrast,
 		"base_size", baseSize,
+		"extreme_aspect", extreme,
 		"image_dimensions", fmt.Sprintf("%dx%d", cols, rows))
 
 	// Adapt based on image complexity
 	if entropy > 6.5 && contrast > 30.0 {
-		gridSize := intMax(32, baseSize/2) // fine grid for complex regions
+		gridSize := fitGridToAspect(intMax(32, baseSize/2), rows, cols) // fine grid for complex regions
 		debugSystem.logger.Debug("using fine grid for complex image", "grid_size", gridSize)
 		return gridSize
 	}
 
 	if entropy < 4.0 || contrast < 15.0 {
-		gridSize := intMax(96, baseSize*3/2) // coarser for uniform regions
+		gridSize := fitGridToAspect(intMax(96, baseSize*3/2), rows, cols) // coarser for uniform regions
 		debugSystem.logger.Debug("using coarse grid for uniform image", "grid_size", gridSize)
 		return gridSize
 	}
 
-	gridSize := intMax(64, baseSize) // standard grid
+	gridSize := fitGridToAspect(intMax(64, baseSize), rows, cols) // standard grid
 	debugSystem.logger.Debug("using standard grid", "grid_size", gridSize)
 	return gridSize
 }
 
+// extremeAspectRatio is the ratio of long to short side from which a page,
+// such as a till receipt, is treated as a strip.
+const extremeAspectRatio = 4
+
+func isExtremeAspect(width, height int) bool {
+	short := intMin(width, height)
+	return short > 0 && intMax(width, height) >= extremeAspectRatio*short
+}
+
+// fitGridToAspect divides the short side of a strip into at least two equal
+// cells, so the grid neither falls back to a single scale nor leaves a
+// sliver of a column at the edge. Other pages keep gridSize.
+func fitGridToAspect(gridSize, rows, cols int) int {
+	if !isExtremeAspect(cols, rows) {
+		return gridSize
+	}
+	short := intMin(rows, cols)
+	cells := intMax(2, (short+gridSize-1)/gridSize)
+	return (short + cells - 1) / cells
+}
+
 func calculateHistogramEntropy(hist gocv.Mat) float64 {
 	if hist.Empty() {
 		return 0.0
@@ -385,14 +429,15 @@ func (pe *ProcessingEngine) calculateImageEntropy(src gocv.Mat) float64 {
 	return calculateHistogramEntropy(hist)
 }
 
-func (pe *ProcessingEngine) processOverlappingRegions(src gocv.Mat, params *OtsuParameters) gocv.Mat {
+func (pe *ProcessingEngine) processOverlappingRegions(reporter progress.Reporter, src gocv.Mat, params *OtsuParameters) (gocv.Mat, *RegionAudit) {
 	if err := validateMatForMetrics(src, "overlapping regions processing"); err != nil {
-		return gocv.NewMat()
+		return gocv.NewMat(), nil
 	}
 
 	rows, cols := src.Rows(), src.Cols()
 	gridSize := pe.calculateAdaptiveGridSize(src)
 	overlap := gridSize / 4 // 25% overlap
+	audit := newRegionAudit(cols, rows, gridSize, overlap)
 
 	result := gocv.NewMatWithSize(rows, cols, gocv.MatTypeCV8UC1)
 	weights := gocv.NewMatWithSize(rows, cols, gocv.MatTypeCV32F)
@@ -416,41 +461,43 @@ func (pe *ProcessingEngine) processOverlappingRegions(src gocv.Mat, params *Otsu
 		"overlap", overlap,
 		"total_regions_estimate", (rows/gridSize+1)*(cols/gridSize+1))
 
-	for y := 0; y < rows; y += gridSize - overlap {
-		endY := intMin(y+gridSize, rows)
-
-		for x := 0; x < cols; x += gridSize - overlap {
-			endX := intMin(x+gridSize, cols)
-
-			// Validate region size
-			regionWidth := endX - x
-			regionHeight := endY - y
-			if regionWidth < 16 || regionHeight < 16 {
-				regionsSkipped++
-				continue
+	// Regions are thresholded concurrently; blending stays in grid order
+	// since the regions overlap.
+	err := runRegionPool(reporter, "overlapping regions", regionTiles(rows, cols, gridSize, overlap),
+		func(bounds image.Rectangle) regionOutcome {
+			if bounds.Dx() < 16 || bounds.Dy() < 16 {
+				return regionOutcome{entry: RegionAuditEntry{Decision: RegionTooSmall}, result: gocv.NewMat()}
 			}
-
-			regionResult := pe.processRegionWithMultilevelFallback(src, x, y, endX, endY, params)
-			if regionResult.Empty() {
+			regionResult, entry := pe.processRegionWithMultilevelFallback(src, bounds.Min.X, bounds.Min.Y, bounds.Max.X, bounds.Max.Y, params)
+			if !regionResult.Empty() {
+				if ink, err := calculateSafeCountNonZero(regionResult, "region result"); err == nil {
+					entry.InkRatio = 1 - float64(ink)/float64(regionResult.Rows()*regionResult.Cols())
+				}
+			}
+			return regionOutcome{entry: entry, result: regionResult}
+		},
+		func(bounds image.Rectangle, outcome regionOutcome) {
+			defer outcome.close()
+			audit.add(bounds, outcome.entry)
+			if outcome.result.Empty() {
 				regionsSkipped++
-				continue
+				return
 			}
 
-			regionWeight := pe.createGaussianWeight(regionWidth, regionHeight)
-
-			// Extract target regions using Region
-			targetRegion := result.Region(image.Rect(x, y, endX, endY))
-			targetWeights := weights.Region(image.Rect(x, y, endX, endY))
-
-			pe.blendRegionWeighted(regionResult, regionWeight, &targetRegion, &targetWeights)
+			regionWeight := pe.createGaussianWeight(bounds.Dx(), bounds.Dy())
+			defer regionWeight.Close()
 
+			targetRegion := result.Region(bounds)
+			targetWeights := weights.Region(bounds)
+			pe.blendRegionWeighted(outcome.result, regionWeight, &targetRegion, &targetWeights)
 			targetRegion.Close()
 			targetWeights.Close()
-			regionResult.Close()
-			regionWeight.Close()
 
 			regionsProcessed++
-		}
+		},
+		regionOutcome.close)
+	if err != nil {
+		return gocv.NewMat(), audit
 	}
 
 	pe.normalizeByWeights(&result, weights)
@@ -461,14 +508,15 @@ func (pe *ProcessingEngine) processOverlappingRegions(src gocv.Mat, params *Otsu
 		"processing_rate", float64(regionsProcessed)/float64(regionsProcessed+regionsSkipped))
 
 	if err := validateMatForMetrics(result, "overlapping regions result"); err != nil {
-		result.Close()
-		return gocv.NewMat()
+		return gocv.NewMat(), audit
 	}
 
-	return result.Clone()
+	return result.Clone(), audit
 }
 
-func (pe *ProcessingEngine) processRegionWithMultilevelFallback(src gocv.Mat, x, y, endX, endY int, params *OtsuParameters) gocv.Mat {
+// processRegionWithMultilevelFallback also returns the audit entry of the
+// region, without its bounds and ink ratio.
+func (pe *ProcessingEngine) processRegionWithMultilevelFallback(src gocv.Mat, x, y, endX, endY int, params *OtsuParameters) (gocv.Mat, RegionAuditEntry) {
 	// Extract region using efficient matrix slicing
 	region := src.Region(image.Rect(x, y, endX, endY))
 	defer region.Close()
@@ -485,28 +533,46 @@ func (pe *ProcessingEngine) processRegionWithMultilevelFallback(src gocv.Mat, x,
 		"contrast", contrast,
 		"entropy", entropy)
 
+	entry := RegionAuditEntry{Contrast: contrast, Entropy: entropy, Decision: RegionLowContrast}
+
 	// Return empty Mat for zero-contrast regions (let caller handle background)
 	if !hasContrast {
 		debugSystem.logger.Debug("returning empty result for zero-contrast region")
-		return gocv.NewMat()
+		return gocv.NewMat(), entry
+	}
+
+	// thresholded completes entry for the level that produced result.
+	thresholded := func(result gocv.Mat, threshold, bins [2]int, level int) (gocv.Mat, RegionAuditEntry) {
+		entry.Decision = RegionFailed
+		if !result.Empty() {
+			entry.Decision = RegionThresholded
+			entry.Threshold = &threshold
+			entry.HistogramBins, entry.NeighborhoodBins = bins[0], bins[1]
+		}
+		entry.FallbackLevel = level
+		return result, entry
 	}
 
 	// Level 1: Standard 2D Otsu for high-quality regions
 	if hasContrast && contrast > 20.0 && entropy > 5.0 {
 		if pe.detectBimodalDistribution(region) {
 			debugSystem.logger.Debug("using standard 2D Otsu for high-quality bimodal region")
-			return pe.processSingleScaleAdaptive(region, params)
+			result, threshold, bins := pe.processSingleScaleAdaptiveThreshold(region, params)
+			return thresholded(result, threshold, bins, RegionLevelStandard)
 		}
 	}
 
 	// Level 2: Adaptive window growing for medium-quality regions
 	if contrast > 10.0 && entropy > 3.0 {
 		debugSystem.logger.Debug("using adaptive window growing for medium-quality region")
-		expandedRegion := pe.expandRegionAdaptively(src, x, y, endX, endY)
+		expandedRegion, expandedBounds := pe.expandRegionAdaptively(src, x, y, endX, endY)
 		if !expandedRegion.Empty() {
 			defer expandedRegion.Close()
 			if err := validateMatForMetrics(expandedRegion, "expanded region"); err == nil {
-				return pe.processSingleScaleAdaptive(expandedRegion, params)
+				expanded := newRegionBounds(expandedBounds)
+				entry.Expanded = &expanded
+				result, threshold, bins := pe.processSingleScaleAdaptiveThreshold(expandedRegion, params)
+				return thresholded(result, threshold, bins, RegionLevelExpanded)
 			}
 		}
 	}
@@ -518,7 +584,8 @@ func (pe *ProcessingEngine) processRegionWithMultilevelFallback(src gocv.Mat, x,
 	globalParams.SmoothingStrength = 2.0
 	globalParams.GaussianPreprocessing = true
 
-	return pe.processSingleScaleAdaptive(region, &globalParams)
+	result, threshold, bins := pe.processSingleScaleAdaptiveThreshold(region, &globalParams)
+	return thresholded(result, threshold, bins, RegionLevelGlobal)
 }
 
 func (pe *ProcessingEngine) analyzeRegionQuality(region gocv.Mat) (bool, float64, float64) {
@@ -530,7 +597,12 @@ func (pe *ProcessingEngine) analyzeRegionQuality(region gocv.Mat) (bool, float64
 	minVal, maxVal, _, _ := gocv.MinMaxLoc(region)
 	contrast := float64(maxVal - minVal)
 
-	// Calculate entropy using histogram
+	return contrast > 15.0, contrast, regionEntropy(region)
+}
+
+// regionEntropy is the entropy of a 64-bin histogram of region, 0 when the
+// histogram cannot be computed.
+func regionEntropy(region gocv.Mat) float64 {
 	hist := gocv.NewMat()
 	defer hist.Close()
 
@@ -541,14 +613,10 @@ func (pe *ProcessingEngine) analyzeRegionQuality(region gocv.Mat) (bool, float64
 	histSize := []int{64}
 	ranges := []float64{0, 256}
 
-	err := gocv.CalcHist([]gocv.Mat{region}, channels, mask, &hist, histSize, ranges, false)
-	if err != nil {
-		return contrast > 15.0, contrast, 0.0
+	if err := gocv.CalcHist([]gocv.Mat{region}, channels, mask, &hist, histSize, ranges, false); err != nil {
+		return 0.0
 	}
-
-	entropy := calculateHistogramEntropy(hist)
-
-	return contrast > 15.0, contrast, entropy
+	return calculateHistogramEntropy(hist)
 }
 
 func (pe *ProcessingEngine) detectBimodalDistribution(region gocv.Mat) bool {
@@ -641,7 +709,7 @@ func (pe *ProcessingEngine) findMaxValue(data []float32) float32 {
 	return maxVal
 }
 
-func (pe *ProcessingEngine) expandRegionAdaptively(src gocv.Mat, x, y, endX, endY int) gocv.Mat {
+func (pe *ProcessingEngine) expandRegionAdaptively(src gocv.Mat, x, y, endX, endY int) (gocv.Mat, image.Rectangle) {
 	rows, cols := src.Rows(), src.Cols()
 
 	// Calculate expansion based on current region contrast
@@ -672,11 +740,11 @@ func (pe *ProcessingEngine) expandRegionAdaptively(src gocv.Mat, x, y, endX, end
 
 	// Validate expansion result
 	if newEndX <= newX || newEndY <= newY {
-		return gocv.NewMat()
+		return gocv.NewMat(), image.Rectangle{}
 	}
 
 	if newEndX-newX < 32 || newEndY-newY < 32 {
-		return gocv.NewMat() // expansion too small
+		return gocv.NewMat(), image.Rectangle{} // expansion too small
 	}
 
 	debugSystem := GetDebugSystem()
@@ -686,7 +754,8 @@ func (pe *ProcessingEngine) expandRegionAdaptively(src gocv.Mat, x, y, endX, end
 		"expansion_factor", expansionFactor,
 		"contrast", contrast)
 
-	return src.Region(image.Rect(newX, newY, newEndX, newEndY))
+	expanded := image.Rect(newX, newY, newEndX, newEndY)
+	return src.Region(expanded), expanded
 }
 
 func (pe *ProcessingEngine) createGaussianWeight(width, height int) gocv.Mat {
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"math"
+	"os"
+	"strings"
+	"time"
+)
+
+// Annotation is a note pinned to a pixel of the loaded image, such as
+// "faint stamp here", left by an operator for a reviewer or the other way
+// round. X and Y are working image pixels.
+type Annotation struct {
+	ID      int       `json:"id"`
+	X       int       `json:"x"`
+	Y       int       `json:"y"`
+	Text    string    `json:"text"`
+	Author  string    `json:"author,omitempty"`
+	Created time.Time `json:"created"`
+}
+
+// AddAnnotation pins text to p and returns the new note.
+func (pe *ProcessingEngine) AddAnnotation(p image.Point, text, author string) (Annotation, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return Annotation{}, fmt.Errorf("note: the text is empty")
+	}
+	if err := pe.checkAnnotationPoint(p); err != nil {
+		return Annotation{}, err
+	}
+
+	pe.historyMu.Lock()
+	defer pe.historyMu.Unlock()
+	pe.nextAnnotationID++
+	note := Annotation{
+		ID:      pe.nextAnnotationID,
+		X:       p.X,
+		Y:       p.Y,
+		Text:    text,
+		Author:  strings.TrimSpace(author),
+		Created: time.Now().UTC(),
+	}
+	pe.annotations = append(pe.annotations, note)
+	return note, nil
+}
+
+// UpdateAnnotation replaces the text of note id.
+func (pe *ProcessingEngine) UpdateAnnotation(id int, text string) error {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return fmt.Errorf("note: the text is empty")
+	}
+	return pe.changeAnnotation(id, func(note *Annotation) { note.Text = text })
+}
+
+// MoveAnnotation pins note id to p instead.
+func (pe *ProcessingEngine) MoveAnnotation(id int, p image.Point) error {
+	if err := pe.checkAnnotationPoint(p); err != nil {
+		return err
+	}
+	return pe.changeAnnotation(id, func(note *Annotation) { note.X, note.Y = p.X, p.Y })
+}
+
+func (pe *ProcessingEngine) changeAnnotation(id int, change func(*Annotation)) error {
+	pe.historyMu.Lock()
+	defer pe.historyMu.Unlock()
+	for i := range pe.annotations {
+		if pe.annotations[i].ID == id {
+			change(&pe.annotations[i])
+			return nil
+		}
+	}
+	return fmt.Errorf("note %d not found", id)
+}
+
+func (pe *ProcessingEngine) RemoveAnnotation(id int) {
+	pe.historyMu.Lock()
+	defer pe.historyMu.Unlock()
+	for i, note := range pe.annotations {
+		if note.ID == id {
+			pe.annotations = append(pe.annotations[:i], pe.annotations[i+1:]...)
+			return
+		}
+	}
+}
+
+// Annotations returns a copy of the notes on the loaded image in the order
+// they were added.
+func (pe *ProcessingEngine) Annotations() []Annotation {
+	pe.historyMu.Lock()
+	defer pe.historyMu.Unlock()
+	return append([]Annotation(nil), pe.annotations...)
+}
+
+func (pe *ProcessingEngine) clearAnnotations() {
+	pe.historyMu.Lock()
+	defer pe.historyMu.Unlock()
+	pe.annotations = nil
+	pe.nextAnnotationID = 0
+}
+
+func (pe *ProcessingEngine) checkAnnotationPoint(p image.Point) error {
+	original := pe.originalImage
+	if original == nil {
+		return fmt.Errorf("note: load an image first")
+	}
+	if !p.In(image.Rect(0, 0, original.Width, original.Height)) {
+		return fmt.Errorf("note: (%d, %d) is outside the %dx%d image", p.X, p.Y, original.Width, original.Height)
+	}
+	return nil
+}
+
+// ReadSidecarAnnotations reads the notes of an output sidecar, scaled from
+// the working size of the run that saved them to workingWidth by
+// workingHeight.
+func ReadSidecarAnnotations(path string, workingWidth, workingHeight int) ([]Annotation, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read notes: %w", err)
+	}
+	var sidecar OutputSidecar
+	if err := json.Unmarshal(data, &sidecar); err != nil {
+		return nil, fmt.Errorf("read notes %s: %w", path, err)
+	}
+	if len(sidecar.Notes) == 0 {
+		return nil, fmt.Errorf("read notes: %s holds no notes", path)
+	}
+
+	scaleX, scaleY := 1.0, 1.0
+	if sidecar.WorkingWidth > 0 && sidecar.WorkingHeight > 0 {
+		scaleX = float64(workingWidth) / float64(sidecar.WorkingWidth)
+		scaleY = float64(workingHeight) / float64(sidecar.WorkingHeight)
+	}
+	notes := sidecar.Notes
+	for i := range notes {
+		notes[i].X = int(math.Floor(float64(notes[i].X) * scaleX))
+		notes[i].Y = int(math.Floor(float64(notes[i].Y) * scaleY))
+	}
+	return notes, nil
+}
+
+// ImportAnnotations adds notes read from elsewhere, numbered after the
+// notes already there. Notes outside the loaded image are skipped; the
+// number added is returned.
+func (pe *ProcessingEngine) ImportAnnotations(notes []Annotation) (int, error) {
+	added := 0
+	for _, note := range notes {
+		if pe.checkAnnotationPoint(image.Pt(note.X, note.Y)) != nil || strings.TrimSpace(note.Text) == "" {
+			continue
+		}
+		pe.historyMu.Lock()
+		pe.nextAnnotationID++
+		note.ID = pe.nextAnnotationID
+		pe.annotations = append(pe.annotations, note)
+		pe.historyMu.Unlock()
+		added++
+	}
+	if added == 0 && len(notes) > 0 {
+		return 0, fmt.Errorf("import notes: none of the %d notes lie on the loaded image", len(notes))
+	}
+	return added, nil
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"math"
+
+	"gocv.io/x/gocv"
+)
+
+// Grayscale conversions of a color image. Luminance is the BT.601 weighting
+// OpenCV applies; Lightness is CIE L*, and Value the brightest of R, G and B.
+const (
+	GrayChannelLuminance = "Luminance"
+	GrayChannelRed       = "Red"
+	GrayChannelGreen     = "Green"
+	GrayChannelBlue      = "Blue"
+	GrayChannelLightness = "Lightness"
+	GrayChannelValue     = "Value"
+)
+
+var GrayscaleChannels = []string{
+	GrayChannelLuminance,
+	GrayChannelRed,
+	GrayChannelGreen,
+	GrayChannelBlue,
+	GrayChannelLightness,
+	GrayChannelValue,
+}
+
+// channelPreviewSide bounds the longer side of channel previews.
+const channelPreviewSide = 480
+
+// extractGrayChannel reduces src to the grayscale channel named channel and
+// returns a new Mat the caller closes. Single-channel images are returned
+// as they are; an empty name means luminance.
+func (pe *ProcessingEngine) extractGrayChannel(src gocv.Mat, channel string) gocv.Mat {
+	if src.Channels() < 3 || channel == "" || channel == GrayChannelLuminance {
+		return pe.convertToGrayscale(src)
+	}
+
+	bgr := src
+	if src.Channels() == 4 {
+		bgr = gocv.NewMat()
+		defer bgr.Close()
+		gocv.CvtColor(src, &bgr, gocv.ColorBGRAToBGR)
+	}
+
+	planes := bgr
+	index := 0
+	switch channel {
+	case GrayChannelRed:
+		index = 2
+	case GrayChannelGreen:
+		index = 1
+	case GrayChannelBlue:
+		index = 0
+	case GrayChannelLightness:
+		planes = gocv.NewMat()
+		defer planes.Close()
+		gocv.CvtColor(bgr, &planes, gocv.ColorBGRToLab)
+	case GrayChannelValue:
+		planes = gocv.NewMat()
+		defer planes.Close()
+		gocv.CvtColor(bgr, &planes, gocv.ColorBGRToHSV)
+		index = 2
+	default:
+		return pe.convertToGrayscale(src)
+	}
+
+	channels := gocv.Split(planes)
+	for i, plane := range channels {
+		if i != index {
+			plane.Close()
+		}
+	}
+	return channels[index]
+}
+
+// ChannelView is one grayscale conversion of the loaded image with its
+// histogram. Separability is Otsu's between-class share of the variance at
+// Threshold, from 0 to 1; higher values split ink from paper more cleanly.
+type ChannelView struct {
+	Channel      string
+	Preview      image.Image
+	Histogram    [256]int
+	Threshold    int
+	Separability float64
+}
+
+// ChannelViews converts the original image with every grayscale channel,
+// before any color stage, so the conversions can be compared.
+func (pe *ProcessingEngine) ChannelViews() ([]ChannelView, error) {
+	original := pe.GetOriginalImage()
+	if original == nil {
+		return nil, fmt.Errorf("no image loaded")
+	}
+	if original.Mat.Channels() < 3 {
+		return nil, fmt.Errorf("the image has a single channel; every conversion gives the same result")
+	}
+
+	views := make([]ChannelView, 0, len(GrayscaleChannels))
+	for _, channel := range GrayscaleChannels {
+		gray := pe.extractGrayChannel(original.Mat, channel)
+
+		view := ChannelView{Channel: channel}
+		for _, value := range gray.ToBytes() {
+			view.Histogram[value]++
+		}
+		view.Threshold, view.Separability = otsuSeparability(view.Histogram)
+
+		scale := math.Min(1, float64(channelPreviewSide)/float64(max(gray.Cols(), gray.Rows())))
+		if scale < 1 {
+			preview := gocv.NewMat()
+			size := image.Pt(scaledDimension(gray.Cols(), scale), scaledDimension(gray.Rows(), scale))
+			gocv.Resize(gray, &preview, size, 0, 0, gocv.InterpolationArea)
+			view.Preview = pe.matToImage(preview)
+			preview.Close()
+		} else {
+			view.Preview = pe.matToImage(gray)
+		}
+		gray.Close()
+
+		views = append(views, view)
+	}
+	return views, nil
+}
+
+// otsuSeparability returns the 1D Otsu threshold of histogram and the share
+// of the total variance between the two classes it makes.
+func otsuSeparability(histogram [256]int) (int, float64) {
+	var total, sum float64
+	for value, count := range histogram {
+		total += float64(count)
+		sum += float64(value * count)
+	}
+	if total == 0 {
+		return 0, 0
+	}
+
+	mean := sum / total
+	var variance float64
+	for value, count := range histogram {
+		variance += float64(count) * (float64(value) - mean) * (float64(value) - mean)
+	}
+	variance /= total
+	if variance == 0 {
+		return 0, 0
+	}
+
+	var weight0, sum0, bestBetween float64
+	threshold := 0
+	for value, count := range histogram {
+		weight0 += float64(count)
+		sum0 += float64(value * count)
+		weight1 := total - weight0
+		if weight0 == 0 || weight1 == 0 {
+			continue
+		}
+		mean0 := sum0 / weight0
+		mean1 := (sum - sum0) / weight1
+		between := weight0 * weight1 * (mean0 - mean1) * (mean0 - mean1) / (total * total)
+		if between > bestBetween {
+			bestBetween = between
+			threshold = value
+		}
+	}
+	return threshold, bestBetween / variance
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"image"
+	"math"
+
+	"gocv.io/x/gocv"
+)
+
+const (
+	// Stain pixels must be at least this saturated and this bright (0-255);
+	// dark strokes and grey paper are left alone.
+	stainMinSaturation = 40
+	stainMinValue      = 80
+
+	// Dropout ink is often darker and less saturated than stains, so the
+	// floors are set on saturation alone with only near-black excluded.
+	dropoutMinSaturation = 60
+	dropoutMinValue      = 40
+
+	// Feathering radius of the stain mask, so attenuated patches have no
+	// hard edges for the threshold to pick up.
+	stainMaskBlur = 5
+)
+
+// applyColorStages runs the stages that need color information, before the
+// image is reduced to grayscale. It returns a new Mat the caller closes.
+func (pe *ProcessingEngine) applyColorStages(src gocv.Mat, params *OtsuParameters) gocv.Mat {
+	working := src.Clone()
+	if working.Channels() < 3 {
+		return working
+	}
+
+	if working.Channels() == 4 {
+		bgr := gocv.NewMat()
+		gocv.CvtColor(working, &bgr, gocv.ColorBGRAToBGR)
+		working.Close()
+		working = bgr
+	}
+
+	if params.StainSuppression {
+		cleaned := pe.applyStainSuppression(working, params.StainHueMin, params.StainHueMax, params.StainStrength)
+		working.Close()
+		working = cleaned
+	}
+
+	if hasColorDropout(params) {
+		dropped := pe.applyColorDropout(working, params)
+		working.Close()
+		working = dropped
+	}
+
+	return working
+}
+
+// colorGrayscale converts src to the grayscale channel params select after
+// any color stages params enable.
+func (pe *ProcessingEngine) colorGrayscale(src gocv.Mat, params *OtsuParameters) gocv.Mat {
+	if !hasColorStages(params) {
+		return pe.extractGrayChannel(src, params.GrayscaleChannel)
+	}
+
+	colored := pe.applyColorStages(src, params)
+	defer colored.Close()
+	return pe.extractGrayChannel(colored, params.GrayscaleChannel)
+}
+
+// hasColorStages reports whether params enable any stage run by
+// applyColorStages.
+func hasColorStages(params *OtsuParameters) bool {
+	return params.StainSuppression || hasColorDropout(params)
+}
+
+func hasColorDropout(params *OtsuParameters) bool {
+	return params.DropoutRed || params.DropoutGreen || params.DropoutBlue
+}
+
+// applyColorDropout whitens ink in the selected colors, as scanner dropout
+// does for pre-printed form lines, leaving black handwriting to threshold.
+// Each color covers its hue center plus or minus DropoutTolerance degrees.
+func (pe *ProcessingEngine) applyColorDropout(src gocv.Mat, params *OtsuParameters) gocv.Mat {
+	if err := validateMatForMetrics(src, "color dropout input"); err != nil {
+		return src.Clone()
+	}
+
+	hsv := gocv.NewMat()
+	defer hsv.Close()
+	gocv.CvtColor(src, &hsv, gocv.ColorBGRToHSV)
+
+	mask := gocv.NewMatWithSize(src.Rows(), src.Cols(), gocv.MatTypeCV8U)
+	defer mask.Close()
+	mask.SetTo(gocv.NewScalar(0, 0, 0, 0))
+
+	for _, dropout := range []struct {
+		selected bool
+		hue      float64
+	}{
+		{params.DropoutRed, 0},
+		{params.DropoutGreen, 120},
+		{params.DropoutBlue, 240},
+	} {
+		if !dropout.selected {
+			continue
+		}
+
+		hueMin := math.Mod(dropout.hue-params.DropoutTolerance+360, 360)
+		hueMax := math.Mod(dropout.hue+params.DropoutTolerance, 360)
+		colorMask := hueRangeMask(hsv, hueMin, hueMax, dropoutMinSaturation, dropoutMinValue)
+		gocv.BitwiseOr(mask, colorMask, &mask)
+		colorMask.Close()
+	}
+
+	return blendTowardWhite(src, mask, 1.0)
+}
+
+// applyStainSuppression finds brown and yellow foxing by hue, between hueMin
+// and hueMax degrees, and blends it toward paper white by strength. A hue
+// range with hueMin above hueMax wraps through red.
+func (pe *ProcessingEngine) applyStainSuppression(src gocv.Mat, hueMin, hueMax, strength float64) gocv.Mat {
+	if err := validateMatForMetrics(src, "stain suppression input"); err != nil {
+		return src.Clone()
+	}
+
+	hsv := gocv.NewMat()
+	defer hsv.Close()
+	gocv.CvtColor(src, &hsv, gocv.ColorBGRToHSV)
+
+	mask := hueRangeMask(hsv, hueMin, hueMax, stainMinSaturation, stainMinValue)
+	defer mask.Close()
+
+	return blendTowardWhite(src, mask, strength)
+}
+
+// hueRangeMask selects pixels of hsv whose hue lies between hueMin and hueMax
+// degrees and whose saturation and value reach the given floors. OpenCV
+// stores 8-bit hue as degrees halved.
+func hueRangeMask(hsv gocv.Mat, hueMin, hueMax float64, minSaturation, minValue float64) gocv.Mat {
+	inRange := func(low, high float64) gocv.Mat {
+		mask := gocv.NewMat()
+		gocv.InRangeWithScalar(hsv,
+			gocv.NewScalar(low/2, minSaturation, minValue, 0),
+			gocv.NewScalar(high/2, 255, 255, 0),
+			&mask)
+		return mask
+	}
+
+	if hueMin <= hueMax {
+		return inRange(hueMin, hueMax)
+	}
+
+	upper := inRange(hueMin, 360)
+	defer upper.Close()
+	lower := inRange(0, hueMax)
+	defer lower.Close()
+
+	mask := gocv.NewMat()
+	gocv.BitwiseOr(upper, lower, &mask)
+	return mask
+}
+
+// blendTowardWhite lightens the pixels of src under mask by strength, where
+// 1 turns them fully white. The mask is feathered so the blend fades out at
+// the edges of each patch.
+func blendTowardWhite(src, mask gocv.Mat, strength float64) gocv.Mat {
+	feathered := gocv.NewMat()
+	defer feathered.Close()
+	gocv.GaussianBlur(mask, &feathered, image.Pt(stainMaskBlur, stainMaskBlur), 0, 0, gocv.BorderDefault)
+
+	weight := gocv.NewMat()
+	defer weight.Close()
+	feathered.ConvertToWithParams(&weight, gocv.MatTypeCV32F, float32(strength/255), 0)
+
+	weights := gocv.NewMat()
+	defer weights.Close()
+	gocv.Merge([]gocv.Mat{weight, weight, weight}, &weights)
+
+	source := gocv.NewMat()
+	defer source.Close()
+	src.ConvertTo(&source, gocv.MatTypeCV32FC3)
+
+	// dst = src + (255 - src) * weight
+	headroom := gocv.NewMatWithSizeFromScalar(gocv.NewScalar(255, 255, 255, 0), src.Rows(), src.Cols(), gocv.MatTypeCV32FC3)
+	defer headroom.Close()
+	gocv.Subtract(headroom, source, &headroom)
+	gocv.Multiply(headroom, weights, &headroom)
+	gocv.Add(source, headroom, &source)
+
+	dst := gocv.NewMat()
+	source.ConvertTo(&dst, gocv.MatTypeCV8UC3)
+	return dst
+}
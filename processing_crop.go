@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+
+	"gocv.io/x/gocv"
+)
+
+// DefaultCropMargin is the margin, in pixels, kept around the content when
+// outputs are cropped.
+const DefaultCropMargin = 20
+
+// CropOptions trims outputs to the bounding box of their content grown by
+// Margin pixels a side.
+type CropOptions struct {
+	Margin int
+}
+
+// OutputCrop records where a cropped output sits in the uncropped result,
+// in working pixels.
+type OutputCrop struct {
+	X               int `json:"x"`
+	Y               int `json:"y"`
+	Width           int `json:"width"`
+	Height          int `json:"height"`
+	Margin          int `json:"margin"`
+	UncroppedWidth  int `json:"uncropped_width"`
+	UncroppedHeight int `json:"uncropped_height"`
+}
+
+// ContentBounds is the bounding box of the ink of binary, where ink is 0
+// and paper 255. Ink touching the image edge, such as the dark border of a
+// scanner bed, and specks of fewer than 4 pixels are left out. The box is
+// empty when nothing else is inked.
+func ContentBounds(binary gocv.Mat) (image.Rectangle, error) {
+	if err := validateMatForMetrics(binary, "content bounds"); err != nil {
+		return image.Rectangle{}, err
+	}
+
+	ink := gocv.NewMat()
+	defer ink.Close()
+	gocv.BitwiseNot(binary, &ink)
+
+	labels := gocv.NewMat()
+	defer labels.Close()
+	stats := gocv.NewMat()
+	defer stats.Close()
+	centroids := gocv.NewMat()
+	defer centroids.Close()
+	count := gocv.ConnectedComponentsWithStats(ink, &labels, &stats, &centroids)
+
+	page := image.Rect(0, 0, binary.Cols(), binary.Rows())
+	var bounds image.Rectangle
+	for label := 1; label < count; label++ {
+		left := int(stats.GetIntAt(label, int(gocv.CC_STAT_LEFT)))
+		top := int(stats.GetIntAt(label, int(gocv.CC_STAT_TOP)))
+		width := int(stats.GetIntAt(label, int(gocv.CC_STAT_WIDTH)))
+		height := int(stats.GetIntAt(label, int(gocv.CC_STAT_HEIGHT)))
+		component := image.Rect(left, top, left+width, top+height)
+
+		if stats.GetIntAt(label, int(gocv.CC_STAT_AREA)) < 4 || component.Min.X == page.Min.X || component.Min.Y == page.Min.Y ||
+			component.Max.X == page.Max.X || component.Max.Y == page.Max.Y {
+			continue
+		}
+		bounds = bounds.Union(component)
+	}
+	return bounds, nil
+}
+
+// CropToContent returns result trimmed to its content bounds and the crop
+// applied. When the result has no content, or the crop would cover all of
+// it, result itself is returned with a nil crop. Otherwise the caller owns
+// and closes the Mat of the returned image.
+func CropToContent(result *ImageData, options CropOptions) (*ImageData, *OutputCrop, error) {
+	content, err := ContentBounds(result.Mat)
+	if err != nil {
+		return nil, nil, fmt.Errorf("crop to content: %w", err)
+	}
+	page := image.Rect(0, 0, result.Width, result.Height)
+	rect := content.Inset(-max(0, options.Margin)).Intersect(page)
+	if content.Empty() || rect == page {
+		return result, nil, nil
+	}
+
+	cropped := cropImageData(result, rect)
+
+	GetDebugSystem().logger.Debug("cropped output to content",
+		"x", rect.Min.X,
+		"y", rect.Min.Y,
+		"width", rect.Dx(),
+		"height", rect.Dy(),
+	)
+	return cropped, &OutputCrop{
+		X:               rect.Min.X,
+		Y:               rect.Min.Y,
+		Width:           rect.Dx(),
+		Height:          rect.Dy(),
+		Margin:          options.Margin,
+		UncroppedWidth:  result.Width,
+		UncroppedHeight: result.Height,
+	}, nil
+}
+
+// cropImageData copies rect of data into an image of its own whose Mat the
+// caller closes.
+func cropImageData(data *ImageData, rect image.Rectangle) *ImageData {
+	region := data.Mat.Region(rect)
+	defer region.Close()
+
+	bounds := data.Image.Bounds()
+	img := newImageLike(data.Image, image.Rect(0, 0, rect.Dx(), rect.Dy()))
+	draw.Draw(img, img.Bounds(), data.Image, bounds.Min.Add(rect.Min), draw.Src)
+
+	cropped := *data
+	cropped.Mat = region.Clone()
+	cropped.Image = img
+	cropped.Width, cropped.Height = rect.Dx(), rect.Dy()
+	return &cropped
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+)
+
+// PixelDecision names the rule that labeled a pixel in a 2D Otsu run. The
+// method makes a pixel paper only when its gray bin exceeds t1 and the bin
+// of its second feature, by default the neighborhood mean, exceeds t2.
+type PixelDecision uint8
+
+const (
+	DecisionPaper PixelDecision = iota
+	DecisionFailedGray
+	DecisionFailedMean
+	DecisionFailedBoth
+	DecisionFallback
+	DecisionUncertain
+
+	decisionKinds = iota
+)
+
+// decisionUncertaintyBins is how far the threshold may move before a pixel
+// in the uncertainty band changes label.
+const decisionUncertaintyBins = 1
+
+var decisionNames = [decisionKinds]string{
+	DecisionPaper:      "Above both thresholds",
+	DecisionFailedGray: "Failed t1 (gray)",
+	DecisionFailedMean: "Failed t2 (feature)",
+	DecisionFailedBoth: "Failed t1 and t2",
+	DecisionFallback:   "Region fallback",
+	DecisionUncertain:  "Uncertainty band",
+}
+
+var decisionColors = color.Palette{
+	DecisionPaper:      color.RGBA{R: 235, G: 235, B: 235, A: 255},
+	DecisionFailedGray: color.RGBA{R: 40, G: 100, B: 210, A: 255},
+	DecisionFailedMean: color.RGBA{R: 235, G: 140, B: 30, A: 255},
+	DecisionFailedBoth: color.RGBA{R: 30, G: 30, B: 30, A: 255},
+	DecisionFallback:   color.RGBA{R: 150, G: 60, B: 170, A: 255},
+	DecisionUncertain:  color.RGBA{R: 240, G: 210, B: 40, A: 255},
+}
+
+func (d PixelDecision) String() string {
+	if int(d) < len(decisionNames) {
+		return decisionNames[d]
+	}
+	return fmt.Sprintf("PixelDecision(%d)", d)
+}
+
+// Color is the shade of d in the decision overlay.
+func (d PixelDecision) Color() color.Color {
+	return decisionColors[d]
+}
+
+// classifyBins names the rule that labels a pixel with histogram bins
+// against threshold.
+func classifyBins(bins, threshold [2]int) PixelDecision {
+	grayAbove := bins[0] > threshold[0]
+	meanAbove := bins[1] > threshold[1]
+	if grayAbove && meanAbove {
+		if bins[0]-threshold[0] <= decisionUncertaintyBins || bins[1]-threshold[1] <= decisionUncertaintyBins {
+			return DecisionUncertain
+		}
+		return DecisionPaper
+	}
+
+	grayNear := grayAbove || threshold[0]-bins[0] < decisionUncertaintyBins
+	meanNear := meanAbove || threshold[1]-bins[1] < decisionUncertaintyBins
+	switch {
+	case grayNear && meanNear:
+		return DecisionUncertain
+	case !grayAbove && !meanAbove:
+		return DecisionFailedBoth
+	case !grayAbove:
+		return DecisionFailedGray
+	default:
+		return DecisionFailedMean
+	}
+}
+
+// histogramBin is the bin of value in a histogram of histBins bins, as
+// build2DHistogram and applyThreshold compute it.
+func histogramBin(value uint8, histBins int) int {
+	return min(int(float64(value)*float64(histBins-1)/255.0), histBins-1)
+}
+
+// DecisionMap holds the rule that labeled each pixel of the latest run,
+// row by row, and how many pixels each rule labeled.
+type DecisionMap struct {
+	Width     int
+	Height    int
+	Decisions []PixelDecision
+	Counts    [decisionKinds]int
+}
+
+// Image shades each pixel with the color of its decision.
+func (dm *DecisionMap) Image() image.Image {
+	img := image.NewPaletted(image.Rect(0, 0, dm.Width, dm.Height), decisionColors)
+	for i, decision := range dm.Decisions {
+		img.Pix[i] = uint8(decision)
+	}
+	return img
+}
+
+// DecisionMap classifies every pixel of the latest single-scale or
+// region-adaptive run. The second feature is recomputed over the whole
+// image, so pixels along region edges can differ slightly from what the
+// regions saw.
+func (pe *ProcessingEngine) DecisionMap() (*DecisionMap, error) {
+	pe.historyMu.Lock()
+	inspection := pe.inspection
+	if inspection == nil {
+		pe.historyMu.Unlock()
+		return nil, fmt.Errorf("no processed image to explain")
+	}
+	if inspection.threshold == nil && inspection.regions == nil {
+		pe.historyMu.Unlock()
+		return nil, fmt.Errorf("the %s method has no 2D threshold to explain", inspection.method)
+	}
+	snapshot := *inspection
+	working := inspection.working.Clone()
+	pe.historyMu.Unlock()
+	defer working.Close()
+
+	rows, cols := working.Rows(), working.Cols()
+	dm := &DecisionMap{
+		Width:     cols,
+		Height:    rows,
+		Decisions: make([]PixelDecision, rows*cols),
+	}
+
+	if snapshot.regions != nil && snapshot.regions.Fallback != "" {
+		for i := range dm.Decisions {
+			dm.Decisions[i] = DecisionFallback
+		}
+		dm.Counts[DecisionFallback] = len(dm.Decisions)
+		return dm, nil
+	}
+
+	feature := pe.calculateSecondFeature(working, snapshot.windowSize, &snapshot.params)
+	defer feature.Close()
+	if feature.Empty() {
+		return nil, fmt.Errorf("compute the second feature")
+	}
+
+	gray := working.ToBytes()
+	second := feature.ToBytes()
+	classify := func(i int, threshold, bins [2]int) PixelDecision {
+		return classifyBins([2]int{histogramBin(gray[i], bins[0]), histogramBin(second[i], bins[1])}, threshold)
+	}
+
+	if snapshot.regions == nil {
+		for i := range dm.Decisions {
+			dm.Decisions[i] = classify(i, *snapshot.threshold, snapshot.bins)
+		}
+	} else {
+		pe.classifyRegions(dm, snapshot.regions, func(i int, entry *RegionAuditEntry) PixelDecision {
+			if entry.Threshold == nil {
+				return DecisionFallback
+			}
+			return classify(i, *entry.Threshold, entry.bins(snapshot.bins))
+		})
+	}
+
+	for _, decision := range dm.Decisions {
+		dm.Counts[decision]++
+	}
+	return dm, nil
+}
+
+// classifyRegions assigns each pixel to the region whose center is nearest,
+// as RegionAudit.regionAt does; pixels no region covers are fallbacks.
+func (pe *ProcessingEngine) classifyRegions(dm *DecisionMap, audit *RegionAudit, classify func(i int, entry *RegionAuditEntry) PixelDecision) {
+	nearest := make([]int, len(dm.Decisions))
+	for i := range nearest {
+		nearest[i] = math.MaxInt
+		dm.Decisions[i] = DecisionFallback
+	}
+
+	for r := range audit.Regions {
+		entry := &audit.Regions[r]
+		rect := image.Rect(entry.Bounds.X, entry.Bounds.Y, entry.Bounds.X+entry.Bounds.Width, entry.Bounds.Y+entry.Bounds.Height).
+			Intersect(image.Rect(0, 0, dm.Width, dm.Height))
+		centerX := (rect.Min.X + rect.Max.X) / 2
+		centerY := (rect.Min.Y + rect.Max.Y) / 2
+
+		for y := rect.Min.Y; y < rect.Max.Y; y++ {
+			for x := rect.Min.X; x < rect.Max.X; x++ {
+				i := y*dm.Width + x
+				dx, dy := x-centerX, y-centerY
+				if distance := dx*dx + dy*dy; distance < nearest[i] {
+					nearest[i] = distance
+					dm.Decisions[i] = classify(i, entry)
+				}
+			}
+		}
+	}
+}
@@ -1,17 +1,92 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"image"
 	"image/color"
+	"sync"
 
 	"gocv.io/x/gocv"
+
+	"otsu-obliterator/progress"
 )
 
 type ProcessingEngine struct {
-	originalImage  *ImageData
-	processedImage *ImageData
-	integralImage  gocv.Mat
+	originalImage    *ImageData
+	processedImage   *ImageData
+	processedMetrics *BinaryImageMetrics
+
+	// DeferMetrics leaves the metrics of a full run until they are first
+	// asked for; metricsSource keeps the page or ground truth they compare
+	// the result against until then, metricsSourceKind says which, and
+	// metricsMu guards them and groundTruth, the reference set by
+	// SetGroundTruth. resultGeneration counts the results and images
+	// replaced, so metrics computed for one are not kept for the next;
+	// metricsMu guards it too, and originalImage is replaced under it so a
+	// run reads both together.
+	DeferMetrics      bool
+	metricsMu         sync.Mutex
+	metricsSource     *gocv.Mat
+	metricsSourceKind string
+	groundTruth       *gocv.Mat
+	resultGeneration  int
+
+	// localStats caches the integral images of the latest working image for
+	// the local methods; see localStatistics.
+	localStatsMu sync.Mutex
+	localStats   *LocalStatistics
+
+	// tonePreviewBase caches the downscaled grayscale image the tone preview
+	// is rendered from; it is rebuilt when the original image, the
+	// grayscale channel or the preview width changes.
+	tonePreviewBase    *gocv.Mat
+	tonePreviewChannel string
+	tonePreviewWidth   int
+
+	// pageCorners holds page corners placed by hand for the current image;
+	// nil means perspective correction detects them.
+	pageCorners *PageCorners
+
+	// touchUpMask marks result pixels painted by hand and touchUpUndo holds
+	// the state before each stroke; both belong to processedImage.
+	touchUpMask *gocv.Mat
+	touchUpUndo []touchUpSnapshot
+
+	// runHistory keeps recent results of the current image for comparison;
+	// historyMu guards it since runs finish on worker goroutines.
+	historyMu  sync.Mutex
+	runHistory []*runHistoryEntry
+	nextRunID  int
+
+	// shownRun is the ID of the run whose result is processedImage; see
+	// StepRun.
+	shownRun int
+
+	// regionAudit records the regions of the latest region-adaptive run;
+	// historyMu guards it too.
+	regionAudit *RegionAudit
+
+	// inspection keeps the binarization input of the latest run for
+	// InspectPixel; historyMu guards it too.
+	inspection *thresholdInspection
+
+	// qualityWarnings holds the anomalies of the latest run; historyMu
+	// guards it too.
+	qualityWarnings []QualityWarning
+
+	// photoZones holds the photo zones of the latest run; historyMu guards
+	// it too.
+	photoZones []image.Rectangle
+
+	// strips is how many strips the latest run was binarized in, 0 for
+	// runs on the whole image; historyMu guards it too.
+	strips int
+
+	// annotations are the notes pinned to the loaded image; historyMu
+	// guards them too.
+	annotations      []Annotation
+	nextAnnotationID int
 }
 
 type ImageData struct {
@@ -21,11 +96,24 @@ type ImageData struct {
 	Height   int
 	Channels int
 	Format   string
+	DPI      float64 // 0 when the file carries no resolution metadata
+
+	// OriginalWidth and OriginalHeight are the dimensions stored in the file;
+	// ScaleFactor is Width/OriginalWidth, 1 unless the image was downscaled
+	// on load to fit the working limits.
+	OriginalWidth  int
+	OriginalHeight int
+	ScaleFactor    float64
+
+	// ColorTransform describes the conversion of an embedded ICC profile
+	// to sRGB on load, empty when none was applied.
+	ColorTransform string
 }
 
 type OtsuParameters struct {
 	WindowSize                 int
 	HistogramBins              int
+	NeighborhoodBins           int
 	SmoothingStrength          float64
 	EdgePreservation           bool
 	NoiseRobustness            bool
@@ -37,6 +125,8 @@ type OtsuParameters struct {
 	MultiScaleProcessing       bool
 	PyramidLevels              int
 	NeighborhoodType           string
+	LegacyNeighborhoods        bool
+	FeaturePairing             string
 	InterpolationMethod        string
 	MorphologicalPostProcess   bool
 	MorphologicalKernelSize    int
@@ -46,6 +136,116 @@ type OtsuParameters struct {
 	DiffusionKappa             float64
 	RegionAdaptiveThresholding bool
 	RegionGridSize             int
+
+	// Tone adjustments applied to the grayscale image before any other
+	// stage; see applyToneAdjustment.
+	EqualizeHistogram bool
+	Brightness        int
+	Contrast          float64
+	Gamma             float64
+
+	ShadowRemoval         bool
+	ShadowRemovalMethod   string
+	ShadowRemovalStrength float64
+
+	// Page geometry stages run on the grayscale image before preprocessing
+	// and change the output size.
+	PerspectiveCorrection bool
+	CylindricalDewarp     bool
+	DewarpCurvature       float64
+
+	// GrayscaleChannel selects how color images become grayscale, one of
+	// GrayscaleChannels; see extractGrayChannel.
+	GrayscaleChannel string
+
+	// Stain suppression runs on the color image; hues are in degrees.
+	StainSuppression bool
+	StainHueMin      float64
+	StainHueMax      float64
+	StainStrength    float64
+
+	// Color dropout whitens form ink of the selected colors before
+	// grayscale conversion; the tolerance is a hue half-width in degrees.
+	DropoutRed       bool
+	DropoutGreen     bool
+	DropoutBlue      bool
+	DropoutTolerance float64
+
+	// PostProcessing lists the steps run when MorphologicalPostProcess is
+	// set; empty means the default open-then-close pair.
+	PostProcessing []MorphOperation
+
+	// Stroke repair runs after post-processing. MaxHoleArea is in pixels,
+	// MaxGapSize in pixels along the stroke.
+	FillHoles   bool
+	MaxHoleArea int
+	BridgeGaps  bool
+	MaxGapSize  int
+
+	// PhotoZones, one of PhotoZoneModes, says what becomes of the
+	// photographs and halftone pictures of the page once the text is
+	// binarized; empty binarizes them with the text.
+	PhotoZones string
+
+	// ExternalStages splice external commands into the pipeline; see
+	// ExternalStage.
+	ExternalStages []ExternalStage
+
+	// Neural binarization replaces the thresholding method with tiled
+	// inference of an ONNX model; NeuralThreshold is the ink probability
+	// above which a pixel becomes ink. See NeuralModelConfig.
+	NeuralBinarization bool
+	NeuralModel        string
+	NeuralThreshold    float64
+
+	// Otsu3D replaces the thresholding method with the experimental 3D
+	// Otsu method over pixel value, neighborhood mean and gradient;
+	// Otsu3DBins is the histogram size of each axis.
+	Otsu3D     bool
+	Otsu3DBins int
+
+	// EntropyMethod replaces the thresholding method with a global
+	// maximum-entropy threshold, one of EntropyMethods; empty keeps Otsu.
+	// TsallisQ is the entropy order of the Tsallis method.
+	EntropyMethod string
+	TsallisQ      float64
+}
+
+// DefaultOtsuParameters returns the parameter set the UI starts with and
+// resets to.
+func DefaultOtsuParameters() *OtsuParameters {
+	return &OtsuParameters{
+		WindowSize:              7,
+		HistogramBins:           0,
+		NeighborhoodBins:        0,
+		SmoothingStrength:       1.0,
+		GaussianPreprocessing:   true,
+		NormalizeHistogram:      true,
+		PyramidLevels:           3,
+		NeighborhoodType:        NeighborhoodRectangular,
+		FeaturePairing:          FeaturePairingMean,
+		InterpolationMethod:     "Bilinear",
+		MorphologicalKernelSize: 3,
+		DiffusionIterations:     5,
+		DiffusionKappa:          30,
+		RegionGridSize:          64,
+		Contrast:                1.0,
+		Gamma:                   1.0,
+		ShadowRemovalMethod:     ShadowMethodGaussian,
+		ShadowRemovalStrength:   1.0,
+		DewarpCurvature:         0.5,
+		GrayscaleChannel:        GrayChannelLuminance,
+		StainHueMin:             10,
+		StainHueMax:             60,
+		StainStrength:           0.8,
+		DropoutTolerance:        25,
+		MaxHoleArea:             16,
+		MaxGapSize:              2,
+		PhotoZones:              PhotoZonesBinarize,
+		NeuralThreshold:         0.5,
+		Otsu3DBins:              defaultOtsu3DBins,
+		TsallisQ:                defaultTsallisQ,
+	}
 }
 
 func NewProcessingEngine() *ProcessingEngine {
@@ -53,8 +253,30 @@ func NewProcessingEngine() *ProcessingEngine {
 }
 
 func (pe *ProcessingEngine) SetOriginalImage(data *ImageData) {
+	pe.metricsMu.Lock()
+	pe.resultGeneration++
 	pe.originalImage = data
-	pe.buildIntegralImage()
+	pe.processedMetrics = nil
+	pe.releaseMetricsSource()
+	pe.metricsMu.Unlock()
+	pe.releaseTonePreviewBase()
+	pe.pageCorners = nil
+	pe.resetTouchUp()
+	pe.clearRunHistory()
+	pe.setInspection(nil)
+	pe.setQualityWarnings(nil)
+	pe.releaseLocalStatistics()
+	pe.ClearGroundTruth()
+	pe.clearAnnotations()
+}
+
+// imageForRun returns the loaded image and the result generation a run
+// starts from, read together so the run can tell when it finishes whether
+// either was replaced.
+func (pe *ProcessingEngine) imageForRun() (*ImageData, int) {
+	pe.metricsMu.Lock()
+	defer pe.metricsMu.Unlock()
+	return pe.originalImage, pe.resultGeneration
 }
 
 func (pe *ProcessingEngine) GetOriginalImage() *ImageData {
@@ -65,95 +287,130 @@ func (pe *ProcessingEngine) GetProcessedImage() *ImageData {
 	return pe.processedImage
 }
 
-func (pe *ProcessingEngine) buildIntegralImage() {
-	if pe.originalImage == nil {
-		return
+// GetProcessedMetrics returns the metrics of the latest run, computing them
+// first when they were deferred. Deferred metrics are computed without
+// holding metricsMu and dropped if the result was replaced meanwhile, in
+// which case the metrics of the new result are returned.
+func (pe *ProcessingEngine) GetProcessedMetrics() *BinaryImageMetrics {
+	pe.metricsMu.Lock()
+	if pe.metricsSource == nil || pe.processedImage == nil {
+		defer pe.metricsMu.Unlock()
+		return pe.processedMetrics
 	}
-
-	gray := pe.convertToGrayscale(pe.originalImage.Mat)
-	defer gray.Close()
-
-	pe.integralImage = gocv.NewMat()
-	sqsum := gocv.NewMat()
-	defer sqsum.Close()
-	tilted := gocv.NewMat()
-	defer tilted.Close()
-
-	gocv.Integral(gray, &pe.integralImage, &sqsum, &tilted)
-}
-
-func (pe *ProcessingEngine) ProcessImage(params *OtsuParameters) (*ImageData, *BinaryImageMetrics, error) {
-	if pe.originalImage == nil {
-		return nil, nil, fmt.Errorf("no original image loaded")
+	source := pe.metricsSource.Clone()
+	defer source.Close()
+	result := pe.processedImage.Mat.Clone()
+	defer result.Close()
+	kind, generation := pe.metricsSourceKind, pe.resultGeneration
+	pe.metricsMu.Unlock()
+
+	metrics, err := CalculateBinaryMetrics(source, result)
+
+	pe.metricsMu.Lock()
+	defer pe.metricsMu.Unlock()
+	switch {
+	case generation != pe.resultGeneration:
+		GetDebugSystem().logger.Debug("stale deferred metrics discarded",
+			"generation", generation, "current_generation", pe.resultGeneration)
+	case err != nil:
+		GetDebugSystem().logger.Warn("deferred metrics failed", "error", err.Error())
+		pe.releaseMetricsSource()
+	default:
+		metrics.Reference = kind
+		pe.processedMetrics = metrics
+		pe.addMetricsToSummary(metrics)
+		pe.releaseMetricsSource()
 	}
+	return pe.processedMetrics
+}
 
-	if err := validateMatForMetrics(pe.originalImage.Mat, "original image processing"); err != nil {
-		return nil, nil, fmt.Errorf("original image validation: %w", err)
-	}
+// ResultGeneration changes whenever the processed result or the loaded
+// image is replaced. Work started on a result compares it afterwards to
+// tell whether its outcome still applies.
+func (pe *ProcessingEngine) ResultGeneration() int {
+	pe.metricsMu.Lock()
+	defer pe.metricsMu.Unlock()
+	return pe.resultGeneration
+}
 
-	gray := pe.convertToGrayscale(pe.originalImage.Mat)
-	defer gray.Close()
+// MetricsDeferred reports whether the latest run left its metrics to be
+// computed on request.
+func (pe *ProcessingEngine) MetricsDeferred() bool {
+	pe.metricsMu.Lock()
+	defer pe.metricsMu.Unlock()
+	return pe.metricsSource != nil
+}
 
-	working := gray
-	if params.HomomorphicFiltering {
-		homomorphic := pe.applyHomomorphicFiltering(gray)
-		defer homomorphic.Close()
-		working = homomorphic
-	}
+// deferMetrics keeps a copy of reference, of the given kind, so the
+// metrics of the new result can be computed later. Callers hold metricsMu.
+func (pe *ProcessingEngine) deferMetrics(reference gocv.Mat, kind string) {
+	source := reference.Clone()
+	pe.metricsSource = &source
+	pe.metricsSourceKind = kind
+}
 
-	if params.AnisotropicDiffusion {
-		diffused := pe.applyAnisotropicDiffusion(working, params.DiffusionIterations, params.DiffusionKappa)
-		defer diffused.Close()
-		working = diffused
+func (pe *ProcessingEngine) releaseMetricsSource() {
+	if pe.metricsSource != nil {
+		pe.metricsSource.Close()
+		pe.metricsSource = nil
 	}
+}
 
-	if params.GaussianPreprocessing {
-		blurred := pe.applyGaussianBlur(working, params.SmoothingStrength)
-		defer blurred.Close()
-		working = blurred
+// Close releases the Mats held for the current image. Headless runs create
+// one engine per image and must free them before moving on.
+func (pe *ProcessingEngine) Close() {
+	if pe.originalImage != nil {
+		pe.originalImage.Mat.Close()
+		pe.originalImage = nil
 	}
-
-	if params.ApplyContrastEnhancement {
-		enhanced := pe.applyAdaptiveContrastEnhancement(working)
-		defer enhanced.Close()
-		working = enhanced
+	if pe.processedImage != nil {
+		pe.processedImage.Mat.Close()
+		pe.processedImage = nil
 	}
+	pe.metricsMu.Lock()
+	pe.processedMetrics = nil
+	pe.releaseMetricsSource()
+	pe.releaseGroundTruth()
+	pe.metricsMu.Unlock()
+	pe.releaseTonePreviewBase()
+	pe.resetTouchUp()
+	pe.clearRunHistory()
+	pe.setInspection(nil)
+	pe.setQualityWarnings(nil)
+	pe.releaseLocalStatistics()
+}
 
-	var result gocv.Mat
-	if params.MultiScaleProcessing {
-		result = pe.processMultiScale(working, params)
+// processingMethodName identifies the thresholding method params select, as
+// used in traces and provenance records.
+func processingMethodName(params *OtsuParameters) string {
+	if params.NeuralBinarization {
+		return "neural_" + neuralModelName(params.NeuralModel)
+	} else if params.MultiScaleProcessing {
+		return fmt.Sprintf("multi_scale_%d_levels", params.PyramidLevels)
 	} else if params.RegionAdaptiveThresholding {
-		result = pe.processRegionAdaptive(working, params)
-	} else {
-		result = pe.processSingleScale(working, params)
-	}
-	defer result.Close()
-
-	if params.MorphologicalPostProcess {
-		morphed := pe.applyMorphologicalPostProcessing(result, params.MorphologicalKernelSize)
-		defer morphed.Close()
-		result = morphed
+		return fmt.Sprintf("region_adaptive_%d_grid", params.RegionGridSize)
+	} else if params.Otsu3D {
+		return fmt.Sprintf("otsu_3d_%d_bins", params.Otsu3DBins)
+	} else if params.EntropyMethod == EntropyMethodTsallis {
+		return fmt.Sprintf("tsallis_entropy_q%.2f", params.TsallisQ)
+	} else if params.EntropyMethod == EntropyMethodKapur {
+		return "kapur_entropy"
 	}
+	return "single_scale"
+}
 
-	resultImage := pe.matToImage(result)
-
-	processedData := &ImageData{
-		Image:    resultImage,
-		Mat:      result.Clone(),
-		Width:    resultImage.Bounds().Dx(),
-		Height:   resultImage.Bounds().Dy(),
-		Channels: 1,
-		Format:   pe.originalImage.Format,
-	}
-
-	pe.processedImage = processedData
-
-	metrics, err := CalculateBinaryMetrics(gray, result)
-	if err != nil {
-		return processedData, nil, fmt.Errorf("metrics calculation: %w", err)
-	}
+// ProcessImage runs the processing pipeline to completion, without the time
+// limit of ProcessImageWithProgress.
+func (pe *ProcessingEngine) ProcessImage(params *OtsuParameters) (*ImageData, *BinaryImageMetrics, error) {
+	return pe.ProcessImageWithContext(context.Background(), params)
+}
 
-	return processedData, metrics, nil
+// ProcessImageWithContext is ProcessImage stopped by ctx: the stages check
+// it between them and the anisotropic diffusion and region loops within,
+// so a run made obsolete by a new setting returns ctx's error promptly and
+// leaves the shown result alone.
+func (pe *ProcessingEngine) ProcessImageWithContext(ctx context.Context, params *OtsuParameters) (*ImageData, *BinaryImageMetrics, error) {
+	return pe.processImageSafely(progress.Discard(ctx), params)
 }
 
 func (pe *ProcessingEngine) convertToGrayscale(src gocv.Mat) gocv.Mat {
@@ -195,17 +452,3 @@ func (pe *ProcessingEngine) matToImage(mat gocv.Mat) image.Image {
 
 	return img
 }
-
-func max(a, b int) int {
-	if a > b {
-		return a
-	}
-	return b
-}
-
-func min(a, b int) int {
-	if a < b {
-		return a
-	}
-	return b
-}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"math"
+
+	"gocv.io/x/gocv"
+)
+
+// Maximum-entropy global thresholds over the gray histogram. Kapur picks
+// the threshold that maximizes the sum of the Shannon entropies of the two
+// classes. Tsallis replaces them with Tsallis entropies of order TsallisQ,
+// combined pseudo-additively; q near 1 gives Kapur.
+const (
+	EntropyMethodKapur   = "Kapur"
+	EntropyMethodTsallis = "Tsallis"
+)
+
+var EntropyMethods = []string{
+	EntropyMethodKapur,
+	EntropyMethodTsallis,
+}
+
+const (
+	defaultTsallisQ = 0.8
+	maxTsallisQ     = 4.0
+)
+
+// processEntropy thresholds src globally with params.EntropyMethod. Pixels
+// above the threshold become paper.
+func (pe *ProcessingEngine) processEntropy(src gocv.Mat, params *OtsuParameters) gocv.Mat {
+	if err := validateMatForMetrics(src, "entropy thresholding"); err != nil {
+		return gocv.NewMat()
+	}
+
+	pixels := src
+	if !src.IsContinuous() {
+		pixels = src.Clone()
+		defer pixels.Close()
+	}
+
+	var histogram [256]float64
+	data := pixels.ToBytes()
+	for _, value := range data {
+		histogram[value]++
+	}
+	for i := range histogram {
+		histogram[i] /= float64(len(data))
+	}
+
+	var threshold int
+	if params.EntropyMethod == EntropyMethodTsallis && params.TsallisQ != 1 {
+		threshold = tsallisThreshold(histogram, params.TsallisQ)
+	} else {
+		threshold = kapurThreshold(histogram)
+	}
+
+	GetDebugSystem().logger.Debug("entropy threshold",
+		"method", params.EntropyMethod,
+		"tsallis_q", params.TsallisQ,
+		"threshold", threshold)
+
+	result := gocv.NewMat()
+	gocv.Threshold(src, &result, float32(threshold), 255, gocv.ThresholdBinary)
+	if err := validateMatForMetrics(result, "entropy result"); err != nil {
+		result.Close()
+		return gocv.NewMat()
+	}
+	return result
+}
+
+// kapurThreshold returns the gray level t that maximizes the Shannon
+// entropy of [0,t] plus that of (t,255], each normalized to its own mass.
+func kapurThreshold(histogram [256]float64) int {
+	return maxEntropyThreshold(histogram, func(p []float64, mass float64) float64 {
+		entropy := 0.0
+		for _, pi := range p {
+			if pi > 0 {
+				entropy -= pi / mass * math.Log(pi/mass)
+			}
+		}
+		return entropy
+	}, func(a, b float64) float64 { return a + b })
+}
+
+// tsallisThreshold returns the gray level t that maximizes
+// S_A + S_B + (1-q) S_A S_B, where S = (1 - sum (p_i/P)^q) / (q-1).
+func tsallisThreshold(histogram [256]float64, q float64) int {
+	return maxEntropyThreshold(histogram, func(p []float64, mass float64) float64 {
+		sum := 0.0
+		for _, pi := range p {
+			if pi > 0 {
+				sum += math.Pow(pi/mass, q)
+			}
+		}
+		return (1 - sum) / (q - 1)
+	}, func(a, b float64) float64 { return a + b + (1-q)*a*b })
+}
+
+// maxEntropyThreshold scores every split of histogram into [0,t] and
+// (t,255] that leaves both classes nonempty by combining their entropies.
+func maxEntropyThreshold(histogram [256]float64, entropy func(p []float64, mass float64) float64, combine func(a, b float64) float64) int {
+	first, last := 0, 255
+	for first < 255 && histogram[first] == 0 {
+		first++
+	}
+	for last > 0 && histogram[last] == 0 {
+		last--
+	}
+
+	best := 127
+	bestScore := math.Inf(-1)
+	mass := 0.0
+	for t := 0; t < last; t++ {
+		mass += histogram[t]
+		if t < first {
+			continue
+		}
+		score := combine(entropy(histogram[:t+1], mass), entropy(histogram[t+1:], 1-mass))
+		if score > bestScore {
+			bestScore = score
+			best = t
+		}
+	}
+	return best
+}
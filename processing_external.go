@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"gocv.io/x/gocv"
+
+	"otsu-obliterator/progress"
+)
+
+// External stage positions: pre stages receive the grayscale page before
+// preprocessing, post stages the binary result after stroke repair.
+const (
+	ExternalStagePre  = "pre"
+	ExternalStagePost = "post"
+)
+
+const (
+	maxExternalStages              = 8
+	defaultExternalStageTimeout    = 30
+	maxExternalStageTimeout        = 3600
+	maxExternalStageOutputBytes    = 512 << 20
+	maxExternalStageStderrBytes    = 64 << 10
+	externalStageTerminationGrace  = 2 * time.Second
+	externalStageBinarizeThreshold = 128
+)
+
+// ExternalStageFormats lists the image formats a stage can exchange.
+var ExternalStageFormats = []string{"png", "pgm", "bmp", "tif"}
+
+// ExternalStage runs a command that reads an image on stdin and writes the
+// processed image, of the same size and in the same format, to stdout.
+// Command is an argument list run without a shell.
+type ExternalStage struct {
+	Name           string
+	Position       string
+	Command        []string
+	Format         string
+	TimeoutSeconds int
+}
+
+func (es ExternalStage) String() string {
+	name := es.Name
+	if name == "" && len(es.Command) > 0 {
+		name = es.Command[0]
+	}
+	return fmt.Sprintf("%s (%s, %s)", name, es.Position, es.Format)
+}
+
+// External commands run only when the user opted in, through the
+// -allow-external-stages flag or the GUI preference, so a parameter file
+// from elsewhere cannot start programs on its own.
+var externalStagesAllowed atomic.Bool
+
+func SetExternalStagesAllowed(allowed bool) {
+	externalStagesAllowed.Store(allowed)
+}
+
+func ExternalStagesAllowed() bool {
+	return externalStagesAllowed.Load()
+}
+
+func hasExternalStages(params *OtsuParameters, position string) bool {
+	for _, stage := range params.ExternalStages {
+		if stage.Position == position {
+			return true
+		}
+	}
+	return false
+}
+
+func validateExternalStages(stages []ExternalStage, fail func(field string, value interface{}, reason string)) {
+	if len(stages) > maxExternalStages {
+		fail("ExternalStages", len(stages), fmt.Sprintf("must have at most %d stages", maxExternalStages))
+	}
+
+	for i, stage := range stages {
+		field := fmt.Sprintf("ExternalStages[%d]", i)
+
+		switch stage.Position {
+		case ExternalStagePre, ExternalStagePost:
+		default:
+			fail(field+".Position", stage.Position, "must be pre or post")
+		}
+
+		if len(stage.Command) == 0 || strings.TrimSpace(stage.Command[0]) == "" {
+			fail(field+".Command", stage.Command, "must name a program")
+		}
+
+		if !isExternalStageFormat(stage.Format) {
+			fail(field+".Format", stage.Format, "must be png, pgm, bmp or tif")
+		}
+
+		if stage.TimeoutSeconds < 0 || stage.TimeoutSeconds > maxExternalStageTimeout {
+			fail(field+".TimeoutSeconds", stage.TimeoutSeconds,
+				fmt.Sprintf("must be between 0 and %d seconds, 0 for the default", maxExternalStageTimeout))
+		}
+	}
+}
+
+func isExternalStageFormat(format string) bool {
+	for _, candidate := range ExternalStageFormats {
+		if format == candidate {
+			return true
+		}
+	}
+	return false
+}
+
+// applyExternalStages runs the stages at position over input in order. The
+// returned Mat is always new; post stage outputs are thresholded back to
+// binary since tools often write anti-aliased edges.
+func (pe *ProcessingEngine) applyExternalStages(reporter progress.Reporter, input gocv.Mat, params *OtsuParameters, position string) (gocv.Mat, error) {
+	current := input.Clone()
+
+	for _, stage := range params.ExternalStages {
+		if stage.Position != position {
+			continue
+		}
+
+		reporter.Report(position, 0, "external stage "+stage.Name)
+		output, err := runExternalStage(reporter.Context(), stage, current)
+		current.Close()
+		if err != nil {
+			return gocv.NewMat(), err
+		}
+		current = output
+
+		if position == ExternalStagePost {
+			binary := gocv.NewMat()
+			gocv.Threshold(current, &binary, externalStageBinarizeThreshold-1, 255, gocv.ThresholdBinary)
+			current.Close()
+			current = binary
+		}
+	}
+
+	return current, nil
+}
+
+func runExternalStage(ctx context.Context, stage ExternalStage, input gocv.Mat) (gocv.Mat, error) {
+	logger := GetDebugSystem().logger
+	name := stage.String()
+
+	if !ExternalStagesAllowed() {
+		return gocv.NewMat(), fmt.Errorf("external stage %s: external commands are not enabled", name)
+	}
+
+	encoded, err := gocv.IMEncode(gocv.FileExt("."+stage.Format), input)
+	if err != nil {
+		return gocv.NewMat(), fmt.Errorf("external stage %s: encode input: %w", name, err)
+	}
+	stdin := append([]byte(nil), encoded.GetBytes()...)
+	encoded.Close()
+
+	timeout := time.Duration(stage.TimeoutSeconds) * time.Second
+	if timeout == 0 {
+		timeout = defaultExternalStageTimeout * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, stage.Command[0], stage.Command[1:]...)
+	cmd.WaitDelay = externalStageTerminationGrace
+	cmd.Stdin = bytes.NewReader(stdin)
+	stdout := &limitedBuffer{limit: maxExternalStageOutputBytes}
+	stderr := &limitedBuffer{limit: maxExternalStageStderrBytes}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	start := time.Now()
+	runErr := cmd.Run()
+	logExternalStageStderr(name, stderr)
+
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return gocv.NewMat(), &TimeoutError{Operation: "external stage", Duration: timeout, Context: name}
+	case runErr != nil:
+		return gocv.NewMat(), fmt.Errorf("external stage %s: %w", name, runErr)
+	case stdout.truncated:
+		return gocv.NewMat(), fmt.Errorf("external stage %s: output exceeds %d MB", name, maxExternalStageOutputBytes>>20)
+	}
+
+	output, err := gocv.IMDecode(stdout.Bytes(), gocv.IMReadGrayScale)
+	if err != nil || output.Empty() {
+		output.Close()
+		return gocv.NewMat(), fmt.Errorf("external stage %s: stdout is not a %s image", name, stage.Format)
+	}
+	if output.Rows() != input.Rows() || output.Cols() != input.Cols() {
+		output.Close()
+		return gocv.NewMat(), fmt.Errorf("external stage %s: output is %dx%d, expected %dx%d",
+			name, output.Cols(), output.Rows(), input.Cols(), input.Rows())
+	}
+
+	logger.Info("external stage complete",
+		"stage", name,
+		"position", stage.Position,
+		"duration_ms", time.Since(start).Milliseconds())
+	return output, nil
+}
+
+func logExternalStageStderr(name string, stderr *limitedBuffer) {
+	logger := GetDebugSystem().logger
+
+	scanner := bufio.NewScanner(bytes.NewReader(stderr.Bytes()))
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			logger.Info("external stage stderr", "stage", name, "line", line)
+		}
+	}
+	if stderr.truncated {
+		logger.Warn("external stage stderr truncated", "stage", name, "limit_bytes", stderr.limit)
+	}
+}
+
+// limitedBuffer keeps the first limit bytes written to it and discards the
+// rest, so a runaway command cannot exhaust memory.
+type limitedBuffer struct {
+	bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (lb *limitedBuffer) Write(p []byte) (int, error) {
+	if room := lb.limit - lb.Len(); len(p) > room {
+		lb.truncated = true
+		if room > 0 {
+			lb.Buffer.Write(p[:room])
+		}
+		return len(p), nil
+	}
+	return lb.Buffer.Write(p)
+}
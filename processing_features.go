@@ -0,0 +1,127 @@
+package main
+
+import (
+	"math"
+
+	"gocv.io/x/gocv"
+)
+
+// Second features the 2D Otsu histogram pairs with the pixel value. Mean is
+// the neighborhood statistic chosen by NeighborhoodType. Gradient and
+// Standard Deviation are inverted so that, like the mean, high values are
+// paper-like: smooth paper scores near 255 and stroke edges low. LBP is the
+// 8-neighbor local binary pattern code, which separates textures rather
+// than levels.
+const (
+	FeaturePairingMean     = "Mean"
+	FeaturePairingGradient = "Gradient"
+	FeaturePairingStdDev   = "Standard Deviation"
+	FeaturePairingLBP      = "LBP"
+)
+
+var FeaturePairings = []string{
+	FeaturePairingMean,
+	FeaturePairingGradient,
+	FeaturePairingStdDev,
+	FeaturePairingLBP,
+}
+
+// calculateSecondFeature returns the second histogram axis for src; an
+// empty pairing means the neighborhood mean.
+func (pe *ProcessingEngine) calculateSecondFeature(src gocv.Mat, windowSize int, params *OtsuParameters) gocv.Mat {
+	switch params.FeaturePairing {
+	case FeaturePairingGradient:
+		return smoothnessFromGradient(src)
+	case FeaturePairingStdDev:
+		return smoothnessFromDeviation(pe.localStatistics(src, false), windowSize|1)
+	case FeaturePairingLBP:
+		return localBinaryPattern(src)
+	default:
+		return pe.calculateNeighborhood(src, windowSize, params.NeighborhoodType, params.LegacyNeighborhoods)
+	}
+}
+
+// smoothnessFromGradient is 255 minus the Sobel gradient magnitude. A 3x3
+// Sobel gives four times the height of a step, so the magnitude is divided
+// by four to read in gray levels.
+func smoothnessFromGradient(src gocv.Mat) gocv.Mat {
+	gx := gocv.NewMat()
+	defer gx.Close()
+	gy := gocv.NewMat()
+	defer gy.Close()
+	if err := gocv.Sobel(src, &gx, gocv.MatTypeCV32F, 1, 0, 3, 1, 0, gocv.BorderReplicate); err != nil {
+		return gocv.NewMat()
+	}
+	if err := gocv.Sobel(src, &gy, gocv.MatTypeCV32F, 0, 1, 3, 1, 0, gocv.BorderReplicate); err != nil {
+		return gocv.NewMat()
+	}
+
+	magnitude := gocv.NewMat()
+	defer magnitude.Close()
+	if err := gocv.Magnitude(gx, gy, &magnitude); err != nil {
+		return gocv.NewMat()
+	}
+
+	result := gocv.NewMat()
+	if err := magnitude.ConvertToWithParams(&result, gocv.MatTypeCV8U, -0.25, 255); err != nil {
+		result.Close()
+		return gocv.NewMat()
+	}
+	return result
+}
+
+// smoothnessFromDeviation is 255 minus twice the standard deviation over a
+// windowSize window, clipped at the image edges like the neighborhood
+// means. The deviation of 8-bit values is at most 127.5.
+func smoothnessFromDeviation(stats *LocalStatistics, windowSize int) gocv.Mat {
+	smoothness := make([]byte, stats.Rows*stats.Cols)
+	for y := 0; y < stats.Rows; y++ {
+		for x := 0; x < stats.Cols; x++ {
+			_, deviation := stats.MeanStdDev(x, y, windowSize)
+			smoothness[y*stats.Cols+x] = byte(255 - math.Min(255, math.Round(2*deviation)))
+		}
+	}
+
+	result, err := gocv.NewMatFromBytes(stats.Rows, stats.Cols, gocv.MatTypeCV8UC1, smoothness)
+	if err != nil {
+		return gocv.NewMat()
+	}
+	return result
+}
+
+// localBinaryPattern sets bit i of each code when neighbor i, clockwise
+// from the top left, is at least as bright as the pixel. Edge pixels
+// repeat the border.
+func localBinaryPattern(src gocv.Mat) gocv.Mat {
+	if !src.IsContinuous() {
+		continuous := src.Clone()
+		defer continuous.Close()
+		src = continuous
+	}
+
+	rows, cols := src.Rows(), src.Cols()
+	data := src.ToBytes()
+	offsets := [8][2]int{{-1, -1}, {0, -1}, {1, -1}, {1, 0}, {1, 1}, {0, 1}, {-1, 1}, {-1, 0}}
+
+	codes := make([]byte, rows*cols)
+	for y := 0; y < rows; y++ {
+		for x := 0; x < cols; x++ {
+			center := data[y*cols+x]
+			var code byte
+			for bit, offset := range offsets {
+				nx := max(0, min(cols-1, x+offset[0]))
+				ny := max(0, min(rows-1, y+offset[1]))
+				if data[ny*cols+nx] >= center {
+					code |= 1 << bit
+				}
+			}
+			codes[y*cols+x] = code
+		}
+	}
+
+	result, err := gocv.NewMatFromBytes(rows, cols, gocv.MatTypeCV8UC1, codes)
+	if err != nil {
+		return gocv.NewMat()
+	}
+	return result
+}
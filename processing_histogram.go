@@ -0,0 +1,74 @@
+package main
+
+// minHistogramAxisBins is the fewest bins either axis of a 2D histogram may
+// have: the threshold search tries every bin but the first and the last, so
+// fewer than three leave no candidate.
+const minHistogramAxisBins = 3
+
+// histogram2D counts pixels by pixel bin and neighborhood bin, stored row by
+// row in one slice so the passes over it walk memory in order.
+type histogram2D struct {
+	pixelBins        int
+	neighborhoodBins int
+	counts           []float64
+}
+
+func newHistogram2D(bins [2]int) histogram2D {
+	return histogram2D{
+		pixelBins:        bins[0],
+		neighborhoodBins: bins[1],
+		counts:           make([]float64, bins[0]*bins[1]),
+	}
+}
+
+// index is the position of bin (i, j) in counts.
+func (h histogram2D) index(i, j int) int {
+	return i*h.neighborhoodBins + j
+}
+
+// row is the neighborhood bins of pixel bin i.
+func (h histogram2D) row(i int) []float64 {
+	return h.counts[i*h.neighborhoodBins : (i+1)*h.neighborhoodBins]
+}
+
+// histogramMoments are summed-area tables of a histogram2D: entry (i, j)
+// of count sums the bins [0,i)x[0,j), and of position the same bins
+// weighted by their index. The table has one more row and column than the
+// histogram, so every box sum is four lookups.
+type histogramMoments struct {
+	side     int
+	count    []float64
+	position []float64
+}
+
+func newHistogramMoments(h histogram2D) histogramMoments {
+	side := h.neighborhoodBins + 1
+	moments := histogramMoments{
+		side:     side,
+		count:    make([]float64, (h.pixelBins+1)*side),
+		position: make([]float64, (h.pixelBins+1)*side),
+	}
+	for i := 1; i <= h.pixelBins; i++ {
+		// Running sums of the current row keep the inner loop to one
+		// lookup of the row above.
+		rowCount, rowPosition := 0.0, 0.0
+		bins := h.row(i - 1)
+		above := (i - 1) * side
+		here := i * side
+		for j := 1; j <= h.neighborhoodBins; j++ {
+			weight := bins[j-1]
+			rowCount += weight
+			rowPosition += float64(h.index(i-1, j-1)) * weight
+			moments.count[here+j] = moments.count[above+j] + rowCount
+			moments.position[here+j] = moments.position[above+j] + rowPosition
+		}
+	}
+	return moments
+}
+
+// box sums count and position over the bins [i0,i1)x[j0,j1).
+func (m histogramMoments) box(i0, i1, j0, j1 int) (float64, float64) {
+	a, b, c, d := i0*m.side+j0, i0*m.side+j1, i1*m.side+j0, i1*m.side+j1
+	return m.count[d] - m.count[b] - m.count[c] + m.count[a],
+		m.position[d] - m.position[b] - m.position[c] + m.position[a]
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"os"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+// maxRunHistory bounds the results kept per image for comparison.
+const maxRunHistory = 10
+
+// RunSummary identifies one processing run of the current image. Changes
+// lists the parameters that differ from the run before it, and Summary
+// describes the run in plain words; see summarizeRun.
+type RunSummary struct {
+	ID      int
+	Time    time.Time
+	Method  string
+	Params  *OtsuParameters
+	Changes []ParameterDiff
+	Summary string
+}
+
+func (rs RunSummary) String() string {
+	return fmt.Sprintf("Run %d, %s, %s", rs.ID, rs.Time.Format("15:04:05"), rs.Method)
+}
+
+// runHistoryEntry keeps a run's result and what the engine learned while
+// making it, so StepRun can show the run again without reprocessing.
+type runHistoryEntry struct {
+	RunSummary
+	result gocv.Mat
+
+	metrics     *BinaryImageMetrics
+	regionAudit *RegionAudit
+	photoZones  []image.Rectangle
+	warnings    []QualityWarning
+	strips      int
+}
+
+// RunDifference compares the results of two runs. Mask is 255 where the
+// results differ; the counts are in working image pixels.
+type RunDifference struct {
+	FromRun       int     `json:"from_run"`
+	ToRun         int     `json:"to_run"`
+	Width         int     `json:"width"`
+	Height        int     `json:"height"`
+	ScaleFactor   float64 `json:"scale_factor"`
+	ChangedPixels int     `json:"changed_pixels"`
+	InkAdded      int     `json:"ink_added"`
+	InkRemoved    int     `json:"ink_removed"`
+
+	// Regions counts changed pixels in a grid over the page, row by row.
+	Regions [ComponentDensityGrid][ComponentDensityGrid]int `json:"regions"`
+
+	FromParameters   *OtsuParameters `json:"from_parameters"`
+	ToParameters     *OtsuParameters `json:"to_parameters"`
+	ParameterChanges []ParameterDiff `json:"parameter_changes"`
+
+	Mask gocv.Mat `json:"-"`
+}
+
+// recordRun keeps a copy of result for later comparison, dropping the
+// oldest run when the history is full. Its changes are counted from the run
+// shown before it.
+func (pe *ProcessingEngine) recordRun(result gocv.Mat, params *OtsuParameters) {
+	pe.historyMu.Lock()
+	defer pe.historyMu.Unlock()
+
+	pe.nextRunID++
+	paramsCopy := *params
+	var changes []ParameterDiff
+	if shown := pe.shownRunEntry(); shown != nil {
+		changes = diffParameters(shown.Params, &paramsCopy)
+	}
+	pe.runHistory = append(pe.runHistory, &runHistoryEntry{
+		RunSummary: RunSummary{
+			ID:      pe.nextRunID,
+			Time:    time.Now(),
+			Method:  processingMethodName(params),
+			Params:  &paramsCopy,
+			Changes: changes,
+		},
+		result:      result.Clone(),
+		regionAudit: pe.regionAudit,
+		photoZones:  pe.photoZones,
+		strips:      pe.strips,
+	})
+	pe.shownRun = pe.nextRunID
+
+	if len(pe.runHistory) > maxRunHistory {
+		pe.runHistory[0].result.Close()
+		pe.runHistory = pe.runHistory[1:]
+	}
+}
+
+// RunHistory lists the kept runs of the current image, oldest first.
+func (pe *ProcessingEngine) RunHistory() []RunSummary {
+	pe.historyMu.Lock()
+	defer pe.historyMu.Unlock()
+
+	summaries := make([]RunSummary, len(pe.runHistory))
+	for i, entry := range pe.runHistory {
+		summaries[i] = entry.RunSummary
+	}
+	return summaries
+}
+
+// DiffRuns compares the results of two runs by ID. The caller closes the
+// returned difference.
+func (pe *ProcessingEngine) DiffRuns(fromID, toID int) (*RunDifference, error) {
+	pe.historyMu.Lock()
+	defer pe.historyMu.Unlock()
+
+	from, to := pe.findRun(fromID), pe.findRun(toID)
+	if from == nil || to == nil {
+		return nil, fmt.Errorf("run no longer in history")
+	}
+	if from.result.Rows() != to.result.Rows() || from.result.Cols() != to.result.Cols() {
+		return nil, fmt.Errorf("runs %d and %d differ in size (%dx%d vs %dx%d), likely from page geometry settings",
+			fromID, toID, from.result.Cols(), from.result.Rows(), to.result.Cols(), to.result.Rows())
+	}
+
+	diff := &RunDifference{
+		FromRun:          fromID,
+		ToRun:            toID,
+		Width:            from.result.Cols(),
+		Height:           from.result.Rows(),
+		ScaleFactor:      1,
+		FromParameters:   from.Params,
+		ToParameters:     to.Params,
+		ParameterChanges: diffParameters(from.Params, to.Params),
+		Mask:             gocv.NewMat(),
+	}
+	if pe.originalImage != nil && pe.originalImage.ScaleFactor != 0 {
+		diff.ScaleFactor = pe.originalImage.ScaleFactor
+	}
+
+	gocv.BitwiseXor(from.result, to.result, &diff.Mask)
+	diff.ChangedPixels = gocv.CountNonZero(diff.Mask)
+
+	// Changed pixels that were paper in the earlier run became ink.
+	added := gocv.NewMat()
+	defer added.Close()
+	gocv.BitwiseAnd(diff.Mask, from.result, &added)
+	diff.InkAdded = gocv.CountNonZero(added)
+	diff.InkRemoved = diff.ChangedPixels - diff.InkAdded
+
+	diff.Regions = countNonZeroGrid(diff.Mask)
+	return diff, nil
+}
+
+func (pe *ProcessingEngine) findRun(id int) *runHistoryEntry {
+	for _, entry := range pe.runHistory {
+		if entry.ID == id {
+			return entry
+		}
+	}
+	return nil
+}
+
+// ChangesSinceLastRun lists the parameters of params that differ from the
+// run whose result is shown; before the first run there are none.
+func (pe *ProcessingEngine) ChangesSinceLastRun(params *OtsuParameters) []ParameterDiff {
+	shown := pe.currentRun()
+	if shown == nil {
+		return nil
+	}
+	return diffParameters(shown.Params, params)
+}
+
+// currentRun returns the run whose result is shown, the most recent one
+// unless StepRun went back, or nil before the first run.
+func (pe *ProcessingEngine) currentRun() *runHistoryEntry {
+	pe.historyMu.Lock()
+	defer pe.historyMu.Unlock()
+	return pe.shownRunEntry()
+}
+
+// shownRunEntry is currentRun for callers holding historyMu.
+func (pe *ProcessingEngine) shownRunEntry() *runHistoryEntry {
+	if entry := pe.findRun(pe.shownRun); entry != nil {
+		return entry
+	}
+	if len(pe.runHistory) == 0 {
+		return nil
+	}
+	return pe.runHistory[len(pe.runHistory)-1]
+}
+
+// CanStepRun reports whether StepRun(delta) has a run to show.
+func (pe *ProcessingEngine) CanStepRun(delta int) bool {
+	pe.historyMu.Lock()
+	defer pe.historyMu.Unlock()
+	_, ok := pe.runIndexAfter(delta)
+	return ok
+}
+
+func (pe *ProcessingEngine) runIndexAfter(delta int) (int, bool) {
+	for i, entry := range pe.runHistory {
+		if entry.ID == pe.shownRun {
+			target := i + delta
+			return target, target >= 0 && target < len(pe.runHistory)
+		}
+	}
+	return 0, false
+}
+
+// StepRun shows the kept result of the run delta places after the one
+// shown, before it for a negative delta, as undo and redo do. The history
+// stays as it is, so a run processed next is added after the latest one.
+// Touch-up edits of the shown result are dropped, and the pixel inspector
+// has nothing to explain until the next run.
+func (pe *ProcessingEngine) StepRun(delta int) (*RunSummary, error) {
+	if pe.originalImage == nil {
+		return nil, fmt.Errorf("no original image loaded")
+	}
+
+	pe.historyMu.Lock()
+	index, ok := pe.runIndexAfter(delta)
+	if !ok {
+		pe.historyMu.Unlock()
+		return nil, fmt.Errorf("no run to step to")
+	}
+	entry := pe.runHistory[index]
+	pe.shownRun = entry.ID
+	pe.regionAudit = entry.regionAudit
+	pe.photoZones = entry.photoZones
+	pe.qualityWarnings = entry.warnings
+	pe.strips = entry.strips
+	if pe.inspection != nil {
+		pe.inspection.working.Close()
+		pe.inspection = nil
+	}
+	summary := entry.RunSummary
+	metrics := entry.metrics
+	result := entry.result.Clone()
+	pe.historyMu.Unlock()
+
+	resultImage := pe.matToImage(result)
+	processedData := &ImageData{
+		Image:    resultImage,
+		Mat:      result,
+		Width:    resultImage.Bounds().Dx(),
+		Height:   resultImage.Bounds().Dy(),
+		Channels: 1,
+		Format:   pe.originalImage.Format,
+	}
+
+	pe.resetTouchUp()
+	pe.metricsMu.Lock()
+	pe.releaseMetricsSource()
+	pe.resultGeneration++
+	pe.processedMetrics = metrics
+	pe.processedImage = processedData
+	pe.metricsMu.Unlock()
+
+	GetDebugSystem().logger.Debug("run restored", "run_id", summary.ID, "delta", delta)
+	return &summary, nil
+}
+
+func (pe *ProcessingEngine) clearRunHistory() {
+	pe.historyMu.Lock()
+	defer pe.historyMu.Unlock()
+
+	for _, entry := range pe.runHistory {
+		entry.result.Close()
+	}
+	pe.runHistory = nil
+	pe.shownRun = 0
+}
+
+// Export writes the difference mask as a PNG at path and the summary as
+// JSON next to it.
+func (rd *RunDifference) Export(path string) error {
+	if !gocv.IMWrite(path, rd.Mask) {
+		return fmt.Errorf("write difference mask %s", path)
+	}
+
+	data, err := json.MarshalIndent(rd, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode difference summary: %w", err)
+	}
+
+	summaryPath := SidecarPath(path)
+	if err := os.WriteFile(summaryPath, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("write difference summary %s: %w", summaryPath, err)
+	}
+	return nil
+}
+
+func (rd *RunDifference) Close() {
+	rd.Mask.Close()
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"fmt"
+	"image"
+
+	"gocv.io/x/gocv"
+)
+
+// PixelInspection explains the label of one result pixel. Gray and Feature
+// are what the 2D Otsu method compared, the second named by FeaturePairing;
+// Bins are the histogram bins they fall in, out of HistogramBins per axis,
+// and Threshold the bin pair a pixel must exceed in both
+// to become paper; it is nil when the method has no single threshold there.
+// Decision is the rule that labeled the pixel.
+type PixelInspection struct {
+	X              int
+	Y              int
+	Gray           int
+	Feature        int
+	FeaturePairing string
+	WindowSize     int
+	HistogramBins  [2]int
+	Bins           [2]int
+	Threshold      *[2]int
+	Method         string
+	Region         string
+	Decision       PixelDecision
+	Ink            bool
+	TouchedUp      bool
+}
+
+// thresholdInspection keeps the binarization input of the latest run so
+// its pixels can be inspected afterwards. threshold is set by single-scale
+// runs; region-adaptive runs look thresholds up in regions.
+type thresholdInspection struct {
+	working    gocv.Mat
+	params     OtsuParameters
+	windowSize int
+	bins       [2]int
+	method     string
+	threshold  *[2]int
+	regions    *RegionAudit
+}
+
+func (pe *ProcessingEngine) newThresholdInspection(working gocv.Mat, params *OtsuParameters) *thresholdInspection {
+	windowSize := params.WindowSize
+	if params.AdaptiveWindowSizing {
+		windowSize = pe.calculateAdaptiveWindowSize(working)
+	}
+	return &thresholdInspection{
+		working:    working.Clone(),
+		params:     *params,
+		windowSize: windowSize,
+		bins:       pe.histogramBins(working, params),
+		method:     processingMethodName(params),
+	}
+}
+
+// setInspection replaces the inspection of the previous run; historyMu
+// guards it since runs finish on worker goroutines.
+func (pe *ProcessingEngine) setInspection(inspection *thresholdInspection) {
+	pe.historyMu.Lock()
+	defer pe.historyMu.Unlock()
+
+	if pe.inspection != nil {
+		pe.inspection.working.Close()
+	}
+	pe.inspection = inspection
+}
+
+// InspectPixel explains the label of pixel (x, y) of the current result.
+func (pe *ProcessingEngine) InspectPixel(x, y int) (*PixelInspection, error) {
+	pe.historyMu.Lock()
+	defer pe.historyMu.Unlock()
+
+	inspection := pe.inspection
+	if inspection == nil || pe.processedImage == nil {
+		return nil, fmt.Errorf("no processed image to inspect")
+	}
+	working := inspection.working
+	if x < 0 || y < 0 || x >= working.Cols() || y >= working.Rows() {
+		return nil, fmt.Errorf("(%d, %d) is outside the image", x, y)
+	}
+
+	gray := int(working.GetUCharAt(y, x))
+	feature := pe.secondFeatureAt(working, x, y, inspection)
+	pairing := inspection.params.FeaturePairing
+	if pairing == "" {
+		pairing = FeaturePairingMean
+	}
+
+	result := &PixelInspection{
+		X:              x,
+		Y:              y,
+		Gray:           gray,
+		Feature:        feature,
+		FeaturePairing: pairing,
+		WindowSize:     inspection.windowSize,
+		HistogramBins:  inspection.bins,
+		Method:         inspection.method,
+		Threshold:      inspection.threshold,
+	}
+
+	if inspection.regions != nil {
+		result.Threshold = nil
+		if inspection.regions.Fallback != "" {
+			result.Region = inspection.regions.Fallback
+		} else if entry := inspection.regions.regionAt(image.Pt(x, y)); entry != nil {
+			result.Region = entry.Decision
+			result.Threshold = entry.Threshold
+			result.HistogramBins = entry.bins(result.HistogramBins)
+		}
+	}
+
+	result.Bins = [2]int{histogramBin(uint8(gray), result.HistogramBins[0]), histogramBin(uint8(feature), result.HistogramBins[1])}
+	result.Decision = DecisionFallback
+	if result.Threshold != nil {
+		result.Decision = classifyBins(result.Bins, *result.Threshold)
+	}
+
+	processed := pe.processedImage.Mat
+	if y < processed.Rows() && x < processed.Cols() {
+		result.Ink = processed.GetUCharAt(y, x) == 0
+		if pe.touchUpMask != nil {
+			result.TouchedUp = pe.touchUpMask.GetUCharAt(y, x) != 0
+		}
+	}
+
+	return result, nil
+}
+
+// secondFeatureAt runs calculateSecondFeature on a crop around (x, y)
+// wide enough that the pixel sees the same window as in the whole image.
+func (pe *ProcessingEngine) secondFeatureAt(src gocv.Mat, x, y int, inspection *thresholdInspection) int {
+	windowSize := inspection.windowSize
+	bounds := image.Rect(x-windowSize, y-windowSize, x+windowSize+1, y+windowSize+1).
+		Intersect(image.Rect(0, 0, src.Cols(), src.Rows()))
+
+	crop := src.Region(bounds)
+	defer crop.Close()
+	patch := crop.Clone()
+	defer patch.Close()
+
+	feature := pe.calculateSecondFeature(patch, windowSize, &inspection.params)
+	defer feature.Close()
+	if feature.Empty() {
+		return int(src.GetUCharAt(y, x))
+	}
+	return int(feature.GetUCharAt(y-bounds.Min.Y, x-bounds.Min.X))
+}
+
+// regionAt returns the region containing p whose center is nearest to it,
+// since overlapping regions cover a pixel more than once.
+func (ra *RegionAudit) regionAt(p image.Point) *RegionAuditEntry {
+	var nearest *RegionAuditEntry
+	bestDistance := 0
+	for i := range ra.Regions {
+		bounds := ra.Regions[i].Bounds
+		rect := image.Rect(bounds.X, bounds.Y, bounds.X+bounds.Width, bounds.Y+bounds.Height)
+		if !p.In(rect) {
+			continue
+		}
+		dx := p.X - (rect.Min.X+rect.Max.X)/2
+		dy := p.Y - (rect.Min.Y+rect.Max.Y)/2
+		if distance := dx*dx + dy*dy; nearest == nil || distance < bestDistance {
+			nearest = &ra.Regions[i]
+			bestDistance = distance
+		}
+	}
+	return nearest
+}
+
+// PixelsPerMillimeter converts result pixels to millimeters using the
+// resolution stored in the source file. It reports false when the file has
+// none or page geometry changed the output size.
+func (pe *ProcessingEngine) PixelsPerMillimeter() (float64, bool) {
+	original := pe.originalImage
+	if original == nil || original.DPI <= 0 || pe.processedImage == nil {
+		return 0, false
+	}
+	if pe.processedImage.Width != original.Width || pe.processedImage.Height != original.Height {
+		return 0, false
+	}
+
+	scale := original.ScaleFactor
+	if scale <= 0 {
+		scale = 1
+	}
+	return original.DPI * scale / 25.4, true
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"sort"
+	"time"
+)
+
+// Text regions gather components whose boxes come within these multiples
+// of the median component height: wider horizontally to join the letters
+// and words of a line, narrower vertically to join lines but not columns
+// or paragraphs set apart by a blank line.
+const (
+	layoutHorizontalGap = 1.5
+	layoutVerticalGap   = 0.7
+
+	// Components taller than this multiple of the median height are
+	// illustrations, rules or borders rather than text.
+	layoutMaxTextHeight = 6
+)
+
+// DetectTextLayout finds the text regions of a binarized result for a
+// layout file that refers to it as imageFile.
+func DetectTextLayout(result *ImageData, imageFile string) (*TextLayout, error) {
+	analysis, err := AnalyzeComponents(result.Mat)
+	if err != nil {
+		return nil, fmt.Errorf("text regions: %w", err)
+	}
+	defer analysis.Close()
+
+	return &TextLayout{
+		ImageFile: imageFile,
+		Width:     result.Width,
+		Height:    result.Height,
+		Regions:   analysis.TextRegions(),
+		CreatedAt: time.Now(),
+	}, nil
+}
+
+// TextRegions groups the ink components into text blocks and returns their
+// bounding boxes in reading order, top to bottom and then left to right.
+func (ca *ComponentAnalysis) TextRegions() []image.Rectangle {
+	return groupTextRegions(ca.Components, ca.labels.Cols(), ca.labels.Rows())
+}
+
+// groupTextRegions paints every text component's box, grown by the layout
+// gaps, onto a coarse grid and takes each connected patch of the grid as a
+// region bounded by the components that fall in it.
+func groupTextRegions(components []ComponentStats, width, height int) []image.Rectangle {
+	heights := make([]int, 0, len(components))
+	for _, component := range components {
+		if component.Area >= 4 {
+			heights = append(heights, component.Bounds.Dy())
+		}
+	}
+	if len(heights) == 0 || width <= 0 || height <= 0 {
+		return nil
+	}
+	sort.Ints(heights)
+	median := heights[len(heights)/2]
+
+	text := make([]ComponentStats, 0, len(components))
+	for _, component := range components {
+		if component.Area < 4 || component.Bounds.Dy() > layoutMaxTextHeight*median {
+			continue
+		}
+		text = append(text, component)
+	}
+
+	cell := max(1, median/2)
+	gridWidth, gridHeight := (width+cell-1)/cell, (height+cell-1)/cell
+	grid := make([]int, gridWidth*gridHeight)
+	pageCells := image.Rect(0, 0, gridWidth, gridHeight)
+	grow := image.Pt(int(layoutHorizontalGap*float64(median))/2, int(layoutVerticalGap*float64(median))/2)
+	for _, component := range text {
+		grown := image.Rectangle{Min: component.Bounds.Min.Sub(grow), Max: component.Bounds.Max.Add(grow)}
+		cells := image.Rect(grown.Min.X/cell, grown.Min.Y/cell,
+			(grown.Max.X-1)/cell+1, (grown.Max.Y-1)/cell+1).Intersect(pageCells)
+		for y := cells.Min.Y; y < cells.Max.Y; y++ {
+			for x := cells.Min.X; x < cells.Max.X; x++ {
+				grid[y*gridWidth+x] = -1
+			}
+		}
+	}
+
+	// Label the painted patches of the grid, 4-connected.
+	patches := 0
+	var stack []int
+	for start, value := range grid {
+		if value != -1 {
+			continue
+		}
+		patches++
+		grid[start] = patches
+		stack = append(stack[:0], start)
+		for len(stack) > 0 {
+			index := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			x, y := index%gridWidth, index/gridWidth
+			for _, neighbour := range [4][2]int{{x - 1, y}, {x + 1, y}, {x, y - 1}, {x, y + 1}} {
+				nx, ny := neighbour[0], neighbour[1]
+				if nx < 0 || ny < 0 || nx >= gridWidth || ny >= gridHeight || grid[ny*gridWidth+nx] != -1 {
+					continue
+				}
+				grid[ny*gridWidth+nx] = patches
+				stack = append(stack, ny*gridWidth+nx)
+			}
+		}
+	}
+
+	regions := make([]image.Rectangle, patches+1)
+	for _, component := range text {
+		patch := grid[(component.Bounds.Min.Y/cell)*gridWidth+component.Bounds.Min.X/cell]
+		regions[patch] = regions[patch].Union(component.Bounds)
+	}
+
+	result := make([]image.Rectangle, 0, patches)
+	for _, region := range regions[1:] {
+		if !region.Empty() {
+			result = append(result, region)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Min.Y != result[j].Min.Y {
+			return result[i].Min.Y < result[j].Min.Y
+		}
+		return result[i].Min.X < result[j].Min.X
+	})
+	return result
+}
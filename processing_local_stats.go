@@ -0,0 +1,129 @@
+package main
+
+import (
+	"hash/fnv"
+	"math"
+
+	"gocv.io/x/gocv"
+)
+
+// LocalStatistics holds the integral and squared integral images of one
+// grayscale image, from which the mean and standard deviation of any window
+// cost four lookups each. Windows are clipped at the image edges.
+type LocalStatistics struct {
+	Rows, Cols int
+
+	stride  int
+	sums    []int64
+	squares []int64
+	key     uint64
+}
+
+func newLocalStatisticsFromBytes(rows, cols int, data []byte, key uint64) *LocalStatistics {
+	stride := cols + 1
+	ls := &LocalStatistics{
+		Rows:    rows,
+		Cols:    cols,
+		stride:  stride,
+		sums:    make([]int64, (rows+1)*stride),
+		squares: make([]int64, (rows+1)*stride),
+		key:     key,
+	}
+	for y := 0; y < rows; y++ {
+		var rowSum, rowSquares int64
+		for x := 0; x < cols; x++ {
+			value := int64(data[y*cols+x])
+			rowSum += value
+			rowSquares += value * value
+			ls.sums[(y+1)*stride+x+1] = ls.sums[y*stride+x+1] + rowSum
+			ls.squares[(y+1)*stride+x+1] = ls.squares[y*stride+x+1] + rowSquares
+		}
+	}
+	return ls
+}
+
+// localStatisticsKey identifies image content, so statistics can be reused
+// by later runs whose working image came out the same.
+func localStatisticsKey(rows, cols int, data []byte) uint64 {
+	hash := fnv.New64a()
+	hash.Write([]byte{byte(rows >> 24), byte(rows >> 16), byte(rows >> 8), byte(rows),
+		byte(cols >> 24), byte(cols >> 16), byte(cols >> 8), byte(cols)})
+	hash.Write(data)
+	return hash.Sum64()
+}
+
+// window returns the sum, sum of squares and pixel count of the windowSize
+// square centered on (x, y).
+func (ls *LocalStatistics) window(x, y, windowSize int) (sum, squares int64, count int) {
+	half := windowSize / 2
+	x0, x1 := max(0, x-half), min(ls.Cols, x+half+1)
+	y0, y1 := max(0, y-half), min(ls.Rows, y+half+1)
+	a, b, c, d := y0*ls.stride+x0, y0*ls.stride+x1, y1*ls.stride+x0, y1*ls.stride+x1
+	return ls.sums[d] - ls.sums[b] - ls.sums[c] + ls.sums[a],
+		ls.squares[d] - ls.squares[b] - ls.squares[c] + ls.squares[a],
+		(x1 - x0) * (y1 - y0)
+}
+
+// MeanStdDev is the mean and population standard deviation of the
+// windowSize square centered on (x, y).
+func (ls *LocalStatistics) MeanStdDev(x, y, windowSize int) (mean, deviation float64) {
+	sum, squares, count := ls.window(x, y, windowSize)
+	n := float64(count)
+	mean = float64(sum) / n
+	return mean, math.Sqrt(math.Max(0, float64(squares)/n-mean*mean))
+}
+
+// localStatistics returns the statistics of src, reusing the cached set of
+// the working image when the content matches. keep caches a new set in its
+// place; binarize keeps the working image's, so every local method of a run
+// shares it, and so do later runs and auto-tuner candidates whose working
+// image comes out the same. Regions and pyramid levels are summed on their
+// own without evicting it.
+func (pe *ProcessingEngine) localStatistics(src gocv.Mat, keep bool) *LocalStatistics {
+	if !src.IsContinuous() {
+		continuous := src.Clone()
+		defer continuous.Close()
+		src = continuous
+	}
+	rows, cols := src.Rows(), src.Cols()
+	data := src.ToBytes()
+	key := localStatisticsKey(rows, cols, data)
+
+	pe.localStatsMu.Lock()
+	cached := pe.localStats
+	pe.localStatsMu.Unlock()
+	if cached != nil && cached.key == key && cached.Rows == rows && cached.Cols == cols {
+		return cached
+	}
+
+	// The sums are built outside the lock so region workers summing their
+	// own tiles do not wait on each other.
+	stats := newLocalStatisticsFromBytes(rows, cols, data, key)
+	if keep {
+		pe.localStatsMu.Lock()
+		pe.localStats = stats
+		pe.localStatsMu.Unlock()
+	}
+	return stats
+}
+
+func (pe *ProcessingEngine) releaseLocalStatistics() {
+	pe.localStatsMu.Lock()
+	pe.localStats = nil
+	pe.localStatsMu.Unlock()
+}
+
+// keepsLocalStatistics reports whether the method params select reads the
+// local statistics of the whole working image.
+func keepsLocalStatistics(params *OtsuParameters) bool {
+	boxMean := params.NeighborhoodType == NeighborhoodRectangular && !params.LegacyNeighborhoods
+	switch {
+	case params.NeuralBinarization, params.MultiScaleProcessing, params.RegionAdaptiveThresholding, params.EntropyMethod != "":
+		return false
+	case params.Otsu3D:
+		return boxMean
+	case params.FeaturePairing == FeaturePairingStdDev:
+		return true
+	}
+	return boxMean && (params.FeaturePairing == "" || params.FeaturePairing == FeaturePairingMean)
+}
@@ -0,0 +1,129 @@
+package main
+
+// MethodInfo describes a thresholding method for users choosing between
+// them: how it picks the threshold, what its parameters mean and where it
+// comes from.
+type MethodInfo struct {
+	Name       string
+	Summary    string
+	Formula    string
+	Parameters []MethodParameterInfo
+	Citation   string
+}
+
+// MethodParameterInfo explains one OtsuParameters field of a method.
+type MethodParameterInfo struct {
+	Field   string
+	Meaning string
+}
+
+var otsu2DCitation = "Liu J., Li W., Tian Y. (1991). Automatic thresholding of gray-level pictures using two-dimension Otsu method. " +
+	"Proc. International Conference on Circuits and Systems, China. " +
+	"Builds on Otsu N. (1979). A threshold selection method from gray-level histograms. IEEE Trans. SMC 9(1), 62-66."
+
+var otsu2DParameters = []MethodParameterInfo{
+	{"WindowSize", "Side of the square window the second feature is computed over."},
+	{"HistogramBins", "Bins of the pixel axis of the 2D histogram; 0 picks them from the image size."},
+	{"NeighborhoodBins", "Bins of the second axis; 0 uses as many as the pixel axis. The second axis is smoother, so fewer bins cut the search cost with little loss."},
+	{"SmoothingStrength", "Gaussian sigma applied to the 2D histogram before the search."},
+	{"FeaturePairing", "Second histogram axis: neighborhood mean, gradient, standard deviation or LBP."},
+	{"NeighborhoodType", "Statistic the Mean pairing uses."},
+}
+
+var methodInfos = map[string]MethodInfo{
+	AlgorithmSingleScale: {
+		Name:    "Single Scale 2D Otsu",
+		Summary: "Builds a histogram of each pixel's gray value against a second feature, by default its neighborhood mean, and picks the pair of thresholds that best separates ink from paper.",
+		Formula: "(t1, t2) = argmax w0·w1·(μ0 − μ1)²\n" +
+			"class 0: bins [0, t1] × [0, t2], class 1: bins (t1, L) × (t2, L)\n" +
+			"paper ⇔ gray bin > t1 and feature bin > t2",
+		Parameters: otsu2DParameters,
+		Citation:   otsu2DCitation,
+	},
+	AlgorithmMultiScale: {
+		Name:    "Multi-Scale Pyramid",
+		Summary: "Runs the 2D Otsu method on each level of an image pyramid, with the window shrinking along with the image, and blends the results from coarse to fine.",
+		Formula: "level k = image downsampled by 2^k, k = 0 … PyramidLevels\n" +
+			"r_k = 0.7·2D Otsu(level k) + 0.3·upsample(r_{k+1}), result = r_0",
+		Parameters: append([]MethodParameterInfo{
+			{"PyramidLevels", "Number of coarser levels below full size, each half the size of the previous."},
+		}, otsu2DParameters...),
+		Citation: "Burt P., Adelson E. (1983). The Laplacian pyramid as a compact image code. IEEE Trans. Communications 31(4), 532-540. " +
+			"Each level uses the 2D Otsu method of Liu, Li and Tian (1991).",
+	},
+	AlgorithmRegionAdaptive: {
+		Name:    "Region Adaptive 2D Otsu",
+		Summary: "Splits the page into a grid and thresholds each region on its own, which copes with uneven lighting. Regions with too little contrast fall back to a global decision.",
+		Formula: "for each region R of the grid: (t1, t2)_R = 2D Otsu(histogram of R)\n" +
+			"paper ⇔ gray bin > t1_R and feature bin > t2_R",
+		Parameters: append([]MethodParameterInfo{
+			{"RegionGridSize", "Side of each region in pixels."},
+		}, otsu2DParameters...),
+		Citation: otsu2DCitation,
+	},
+	AlgorithmNeural: {
+		Name:    "Neural Network (ONNX)",
+		Summary: "Runs a pretrained segmentation model, such as a U-Net trained on DIBCO, over overlapping tiles and thresholds its ink probability.",
+		Formula: "p(ink) = model(tile)\nink ⇔ p(ink) > NeuralThreshold",
+		Parameters: []MethodParameterInfo{
+			{"NeuralModel", "ONNX model file; an optional .onnx.json descriptor sets tiling and input format."},
+			{"NeuralThreshold", "Ink probability above which a pixel becomes ink."},
+		},
+		Citation: "Ronneberger O., Fischer P., Brox T. (2015). U-Net: Convolutional networks for biomedical image segmentation. MICCAI, 234-241.",
+	},
+	AlgorithmOtsu3D: {
+		Name:    "3D Otsu (Experimental)",
+		Summary: "Adds the inverted gradient magnitude as a third axis to the 2D method. Meant for research comparisons.",
+		Formula: "(s, t, u) = argmax Σ_c w_c·‖μ_c − μ_T‖², c ∈ {[0,s]×[0,t]×[0,u], (s,L)×(t,L)×(u,L)}\n" +
+			"paper ⇔ gray bin > s and mean bin > t and smoothness bin > u",
+		Parameters: []MethodParameterInfo{
+			{"Otsu3DBins", "Bins per axis; memory grows with its cube."},
+			{"WindowSize", "Side of the window of the neighborhood mean."},
+			{"NeighborhoodType", "Statistic used for the mean axis."},
+		},
+		Citation: "Extends the 2D method of Liu, Li and Tian (1991) with a gradient axis.",
+	},
+	AlgorithmKapur: {
+		Name:    "Kapur Entropy",
+		Summary: "Picks the global gray level that makes the ink and paper histograms each as spread out, in the Shannon entropy sense, as possible.",
+		Formula: "t = argmax H_A(t) + H_B(t)\n" +
+			"H_A = −Σ_{i≤t} (p_i/P_A) ln(p_i/P_A), H_B likewise over i > t",
+		Citation: "Kapur J.N., Sahoo P.K., Wong A.K.C. (1985). A new method for gray-level picture thresholding using the entropy of the histogram. " +
+			"Computer Vision, Graphics, and Image Processing 29(3), 273-285.",
+	},
+	AlgorithmTsallis: {
+		Name:    "Tsallis Entropy",
+		Summary: "Like Kapur, but with the non-extensive Tsallis entropy, whose order q tunes how strongly the two classes interact.",
+		Formula: "t = argmax S_A + S_B + (1 − q)·S_A·S_B\n" +
+			"S_A = (1 − Σ_{i≤t} (p_i/P_A)^q) / (q − 1), S_B likewise over i > t",
+		Parameters: []MethodParameterInfo{
+			{"TsallisQ", "Entropy order q; as q approaches 1 the method becomes Kapur."},
+		},
+		Citation: "Portes de Albuquerque M., Esquef I.A., Gesualdi Mello A.R., Portes de Albuquerque M. (2004). Image thresholding using Tsallis entropy. " +
+			"Pattern Recognition Letters 25(9), 1059-1065.",
+	},
+}
+
+// methodAlgorithm names the method params select as -algorithm does.
+func methodAlgorithm(params *OtsuParameters) string {
+	switch {
+	case params.NeuralBinarization:
+		return AlgorithmNeural
+	case params.MultiScaleProcessing:
+		return AlgorithmMultiScale
+	case params.RegionAdaptiveThresholding:
+		return AlgorithmRegionAdaptive
+	case params.Otsu3D:
+		return AlgorithmOtsu3D
+	case params.EntropyMethod == EntropyMethodKapur:
+		return AlgorithmKapur
+	case params.EntropyMethod == EntropyMethodTsallis:
+		return AlgorithmTsallis
+	}
+	return AlgorithmSingleScale
+}
+
+// MethodInfoFor describes the method params select.
+func MethodInfoFor(params *OtsuParameters) MethodInfo {
+	return methodInfos[methodAlgorithm(params)]
+}
@@ -2,11 +2,20 @@ package main
 
 import (
 	"gocv.io/x/gocv"
+
+	"otsu-obliterator/progress"
 )
 
 func (pe *ProcessingEngine) processSingleScale(src gocv.Mat, params *OtsuParameters) gocv.Mat {
+	result, _, _ := pe.processSingleScaleThreshold(src, params)
+	return result
+}
+
+// processSingleScaleThreshold also returns the threshold it applied and the
+// pixel and neighborhood bin counts it is measured in.
+func (pe *ProcessingEngine) processSingleScaleThreshold(src gocv.Mat, params *OtsuParameters) (gocv.Mat, [2]int, [2]int) {
 	if err := validateMatForMetrics(src, "single scale processing"); err != nil {
-		return gocv.NewMat()
+		return gocv.NewMat(), [2]int{}, [2]int{}
 	}
 
 	windowSize := params.WindowSize
@@ -14,15 +23,11 @@ func (pe *ProcessingEngine) processSingleScale(src gocv.Mat, params *OtsuParamet
 		windowSize = pe.calculateAdaptiveWindowSize(src)
 	}
 
-	neighborhood := pe.calculateNeighborhood(src, windowSize, params.NeighborhoodType)
-	defer neighborhood.Close()
+	feature := pe.calculateSecondFeature(src, windowSize, params)
+	defer feature.Close()
 
-	histBins := params.HistogramBins
-	if histBins == 0 {
-		histBins = pe.calculateHistogramBins(src)
-	}
-
-	histogram := pe.build2DHistogram(src, neighborhood, histBins)
+	bins := pe.histogramBins(src, params)
+	histogram := pe.build2DHistogram(src, feature, bins)
 
 	if params.UseLogHistogram {
 		pe.applyLogScaling(histogram)
@@ -37,20 +42,20 @@ func (pe *ProcessingEngine) processSingleScale(src gocv.Mat, params *OtsuParamet
 	}
 
 	threshold := pe.find2DOtsuThresholdInteger(histogram)
-	result := pe.applyThreshold(src, neighborhood, threshold, histBins)
+	result := pe.applyThreshold(src, feature, threshold, bins)
 
 	if err := validateMatForMetrics(result, "single scale result"); err != nil {
 		result.Close()
-		return gocv.NewMat()
+		return gocv.NewMat(), threshold, bins
 	}
 
-	return result
+	return result, threshold, bins
 }
 
-func (pe *ProcessingEngine) processMultiScale(src gocv.Mat, params *OtsuParameters) gocv.Mat {
+func (pe *ProcessingEngine) processMultiScale(reporter progress.Reporter, src gocv.Mat, params *OtsuParameters) gocv.Mat {
 	if err := validateMatForMetrics(src, "multi-scale processing"); err != nil {
 		return gocv.NewMat()
 	}
 
-	return pe.processMultiScalePyramid(src, params)
+	return pe.processMultiScalePyramid(reporter, src, params)
 }
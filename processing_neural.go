@@ -0,0 +1,347 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"gocv.io/x/gocv"
+
+	"otsu-obliterator/progress"
+)
+
+const (
+	NeuralOutputInk   = "ink"
+	NeuralOutputPaper = "paper"
+)
+
+// NeuralModelConfig describes how a model expects its input and what it
+// outputs. It is read from a JSON file next to the model, <model>.json,
+// and every field has a default that suits the common DIBCO U-Nets: one
+// channel tiles of 256 pixels scaled to [0, 1], returning the ink
+// probability per pixel.
+type NeuralModelConfig struct {
+	InputChannels int     `json:"input_channels"`
+	TileSize      int     `json:"tile_size"`
+	Overlap       int     `json:"overlap"`
+	Scale         float64 `json:"scale"`
+	Mean          float64 `json:"mean"`
+	Output        string  `json:"output"`
+	// OutputChannel selects the class plane of multi-channel outputs; the
+	// default is the last one.
+	OutputChannel *int `json:"output_channel,omitempty"`
+	// Logits marks outputs before the sigmoid.
+	Logits bool `json:"logits"`
+}
+
+func defaultNeuralModelConfig() NeuralModelConfig {
+	return NeuralModelConfig{
+		InputChannels: 1,
+		TileSize:      256,
+		Overlap:       32,
+		Scale:         1.0 / 255,
+		Output:        NeuralOutputInk,
+	}
+}
+
+// LoadNeuralModelConfig reads the descriptor of modelPath, falling back to
+// the defaults when there is none.
+func LoadNeuralModelConfig(modelPath string) (NeuralModelConfig, error) {
+	config := defaultNeuralModelConfig()
+	descriptor := modelPath + ".json"
+
+	data, err := os.ReadFile(descriptor)
+	if errors.Is(err, os.ErrNotExist) {
+		return config, nil
+	}
+	if err != nil {
+		return config, fmt.Errorf("read model descriptor: %w", err)
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("decode model descriptor %s: %w", descriptor, err)
+	}
+
+	switch {
+	case config.InputChannels != 1 && config.InputChannels != 3:
+		return config, fmt.Errorf("model descriptor %s: input_channels must be 1 or 3", descriptor)
+	case config.TileSize < 32 || config.TileSize > 4096:
+		return config, fmt.Errorf("model descriptor %s: tile_size must be between 32 and 4096", descriptor)
+	case config.Overlap < 0 || 2*config.Overlap >= config.TileSize:
+		return config, fmt.Errorf("model descriptor %s: overlap must be below half the tile size", descriptor)
+	case config.Output != NeuralOutputInk && config.Output != NeuralOutputPaper:
+		return config, fmt.Errorf("model descriptor %s: output must be %s or %s", descriptor, NeuralOutputInk, NeuralOutputPaper)
+	}
+	return config, nil
+}
+
+// neuralModel is a loaded network. OpenCV networks are not safe for
+// concurrent use, so inference holds the mutex.
+type neuralModel struct {
+	mu       sync.Mutex
+	net      gocv.Net
+	closed   bool
+	config   NeuralModelConfig
+	modified time.Time
+}
+
+// neuralModels caches networks by path; a model file that changed on disk
+// is loaded again.
+var neuralModels = struct {
+	sync.Mutex
+	byPath map[string]*neuralModel
+}{byPath: make(map[string]*neuralModel)}
+
+func loadNeuralModel(path string) (*neuralModel, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("neural model: %w", err)
+	}
+
+	neuralModels.Lock()
+	defer neuralModels.Unlock()
+
+	if model, ok := neuralModels.byPath[path]; ok && model.modified.Equal(info.ModTime()) {
+		return model, nil
+	}
+
+	config, err := LoadNeuralModelConfig(path)
+	if err != nil {
+		return nil, err
+	}
+
+	net := gocv.ReadNetFromONNX(path)
+	if net.Empty() {
+		net.Close()
+		return nil, fmt.Errorf("neural model %s: OpenCV could not load the ONNX file", path)
+	}
+
+	if replaced, ok := neuralModels.byPath[path]; ok {
+		replaced.close()
+	}
+	model := &neuralModel{net: net, config: config, modified: info.ModTime()}
+	neuralModels.byPath[path] = model
+
+	GetDebugSystem().logger.Info("neural model loaded",
+		"path", path,
+		"tile_size", config.TileSize,
+		"input_channels", config.InputChannels,
+		"output", config.Output,
+	)
+	return model, nil
+}
+
+// binarize runs the thresholding method params select on the preprocessed
+// grayscale image. Methods stop early once reporter is cancelled.
+func (pe *ProcessingEngine) binarize(reporter progress.Reporter, working gocv.Mat, params *OtsuParameters) (gocv.Mat, error) {
+	pe.setRegionAudit(nil)
+
+	var result gocv.Mat
+	var audit *RegionAudit
+	var threshold *[2]int
+	var bins [2]int
+	var err error
+	strips := 0
+	if usesStrips(float64(working.Total()), params) {
+		result, strips, err = pe.binarizeStrips(reporter, working, params)
+	} else {
+		if keepsLocalStatistics(params) {
+			pe.localStatistics(working, true)
+		}
+		result, audit, threshold, bins, err = pe.binarizeMethod(reporter, working, params)
+	}
+	pe.setStrips(strips)
+	if err != nil {
+		return result, err
+	}
+	pe.setRegionAudit(audit)
+
+	if err := reporter.Err(); err != nil {
+		result.Close()
+		return gocv.NewMat(), err
+	}
+
+	inspection := pe.newThresholdInspection(working, params)
+	inspection.regions = audit
+	inspection.threshold = threshold
+	if bins[0] > 0 {
+		inspection.bins = bins
+	}
+	pe.setInspection(inspection)
+	reporter.Report(StageBinarize, 1, "")
+	return result, nil
+}
+
+// binarizeMethod thresholds working with the method params select. audit
+// is set by region-adaptive runs, threshold and bins by single-scale ones.
+func (pe *ProcessingEngine) binarizeMethod(reporter progress.Reporter, working gocv.Mat, params *OtsuParameters) (result gocv.Mat, audit *RegionAudit, threshold *[2]int, bins [2]int, err error) {
+	switch {
+	case params.NeuralBinarization:
+		result, err = pe.processNeural(reporter, working, params)
+	case params.MultiScaleProcessing:
+		result = pe.processMultiScale(reporter, working, params)
+	case params.RegionAdaptiveThresholding:
+		result, audit = pe.processRegionAdaptive(reporter, working, params)
+	case params.Otsu3D:
+		result = pe.processOtsu3D(reporter, working, params)
+	case params.EntropyMethod != "":
+		result = pe.processEntropy(working, params)
+	default:
+		var applied [2]int
+		result, applied, bins = pe.processSingleScaleThreshold(working, params)
+		threshold = &applied
+	}
+	return result, audit, threshold, bins, err
+}
+
+// processNeural runs the model over overlapping tiles and keeps the center
+// of each, so tile borders do not show in the result.
+func (pe *ProcessingEngine) processNeural(reporter progress.Reporter, working gocv.Mat, params *OtsuParameters) (gocv.Mat, error) {
+	model, err := loadNeuralModel(params.NeuralModel)
+	if err != nil {
+		return gocv.NewMat(), err
+	}
+	config := model.config
+
+	rows, cols := working.Rows(), working.Cols()
+	margin := config.Overlap
+	core := config.TileSize - 2*margin
+	tilesDown := (rows + core - 1) / core
+	tilesAcross := (cols + core - 1) / core
+
+	padded := gocv.NewMat()
+	defer padded.Close()
+	if err := gocv.CopyMakeBorder(working, &padded,
+		margin, margin+tilesDown*core-rows, margin, margin+tilesAcross*core-cols,
+		gocv.BorderReflect101, color.RGBA{}); err != nil {
+		return gocv.NewMat(), fmt.Errorf("pad image for neural tiles: %w", err)
+	}
+	if config.InputChannels == 3 {
+		gocv.CvtColor(padded, &padded, gocv.ColorGrayToBGR)
+	}
+
+	probability := gocv.NewMatWithSize(rows, cols, gocv.MatTypeCV32F)
+	defer probability.Close()
+
+	startTime := time.Now()
+	tiles := tilesDown * tilesAcross
+	for ty := 0; ty < tilesDown; ty++ {
+		for tx := 0; tx < tilesAcross; tx++ {
+			tile := ty*tilesAcross + tx
+			if err := progress.Steps(reporter, StageBinarize, tile, tiles, fmt.Sprintf("tile %d of %d", tile+1, tiles)); err != nil {
+				return gocv.NewMat(), err
+			}
+
+			y, x := ty*core, tx*core
+			input := padded.Region(image.Rect(x, y, x+config.TileSize, y+config.TileSize))
+			output, err := model.infer(input)
+			input.Close()
+			if err != nil {
+				return gocv.NewMat(), err
+			}
+
+			height, width := min(core, rows-y), min(core, cols-x)
+			center := output.Region(image.Rect(margin, margin, margin+width, margin+height))
+			target := probability.Region(image.Rect(x, y, x+width, y+height))
+			center.CopyTo(&target)
+			target.Close()
+			center.Close()
+			output.Close()
+		}
+	}
+
+	GetDebugSystem().logger.Debug("neural inference complete",
+		"model", params.NeuralModel,
+		"tiles", tiles,
+		"duration_ms", time.Since(startTime).Milliseconds(),
+	)
+
+	threshold := params.NeuralThreshold
+	if config.Output == NeuralOutputPaper {
+		threshold = 1 - threshold
+	}
+	if config.Logits {
+		threshold = math.Log(threshold / (1 - threshold))
+	}
+	thresholdType := gocv.ThresholdBinaryInv
+	if config.Output == NeuralOutputPaper {
+		thresholdType = gocv.ThresholdBinary
+	}
+
+	// Ink is 0 and paper 255, as every other method returns.
+	mask := gocv.NewMat()
+	defer mask.Close()
+	gocv.Threshold(probability, &mask, float32(threshold), 255, thresholdType)
+	result := gocv.NewMat()
+	mask.ConvertTo(&result, gocv.MatTypeCV8U)
+	return result, nil
+}
+
+// infer returns the selected output plane of one tile as a float matrix of
+// the tile's size.
+func (nm *neuralModel) infer(tile gocv.Mat) (gocv.Mat, error) {
+	config := nm.config
+	size := image.Pt(config.TileSize, config.TileSize)
+	blob := gocv.BlobFromImage(tile, config.Scale, size, gocv.NewScalar(config.Mean, config.Mean, config.Mean, 0), false, false)
+	defer blob.Close()
+
+	nm.mu.Lock()
+	if nm.closed {
+		nm.mu.Unlock()
+		return gocv.NewMat(), fmt.Errorf("neural model was replaced on disk during processing")
+	}
+	nm.net.SetInput(blob, "")
+	output := nm.net.Forward("")
+	nm.mu.Unlock()
+	defer output.Close()
+
+	if output.Empty() {
+		return gocv.NewMat(), fmt.Errorf("neural model returned no output")
+	}
+	shape := output.Size()
+	if len(shape) != 4 {
+		return gocv.NewMat(), fmt.Errorf("neural model output has shape %v, expected batch, channels, height and width", shape)
+	}
+
+	channel := shape[1] - 1
+	if config.OutputChannel != nil {
+		channel = *config.OutputChannel
+	}
+	if channel < 0 || channel >= shape[1] {
+		return gocv.NewMat(), fmt.Errorf("neural model output channel %d of %d", channel, shape[1])
+	}
+
+	plane := gocv.GetBlobChannel(output, 0, channel)
+	defer plane.Close()
+
+	result := gocv.NewMat()
+	if plane.Rows() != config.TileSize || plane.Cols() != config.TileSize {
+		gocv.Resize(plane, &result, size, 0, 0, gocv.InterpolationLinear)
+	} else {
+		plane.CopyTo(&result)
+	}
+	return result, nil
+}
+
+// close waits for a running inference and releases the network.
+func (nm *neuralModel) close() {
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+	if !nm.closed {
+		nm.net.Close()
+		nm.closed = true
+	}
+}
+
+// neuralModelName is the model file name without its extension, for
+// labels and method names.
+func neuralModelName(path string) string {
+	return strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+}
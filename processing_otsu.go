@@ -6,17 +6,19 @@ import (
 	"gocv.io/x/gocv"
 )
 
-func (pe *ProcessingEngine) build2DHistogram(src, neighborhood gocv.Mat, histBins int) [][]float64 {
+// build2DHistogram counts pixels by their value and neighborhood value in
+// bins[0] by bins[1] bins.
+func (pe *ProcessingEngine) build2DHistogram(src, neighborhood gocv.Mat, bins [2]int) histogram2D {
 	if err := validateMatForMetrics(src, "2D histogram source"); err != nil {
-		return pe.createEmptyHistogram(histBins)
+		return newHistogram2D(bins)
 	}
 
 	if err := validateMatForMetrics(neighborhood, "2D histogram neighborhood"); err != nil {
-		return pe.createEmptyHistogram(histBins)
+		return newHistogram2D(bins)
 	}
 
 	if err := validateMatDimensionsMatch(src, neighborhood, "2D histogram"); err != nil {
-		return pe.createEmptyHistogram(histBins)
+		return newHistogram2D(bins)
 	}
 
 	// Validate contrast before processing
@@ -28,17 +30,13 @@ func (pe *ProcessingEngine) build2DHistogram(src, neighborhood gocv.Mat, histBin
 		debugSystem.logger.Warn("skipping region due to insufficient contrast",
 			"contrast", contrast,
 			"minimum", 5.0)
-		return pe.createEmptyHistogram(histBins)
+		return newHistogram2D(bins)
 	}
 
-	histogram := make([][]float64, histBins)
-	for i := range histogram {
-		histogram[i] = make([]float64, histBins)
-	}
+	histogram := newHistogram2D(bins)
 
 	rows := src.Rows()
 	cols := src.Cols()
-	binScale := float64(histBins-1) / 255.0
 
 	// Debug: Check input data ranges
 	debugSystem := GetDebugSystem()
@@ -48,7 +46,7 @@ func (pe *ProcessingEngine) build2DHistogram(src, neighborhood gocv.Mat, histBin
 	debugSystem.logger.Debug("histogram input analysis",
 		"src_min", float64(srcMinVal), "src_max", float64(srcMaxVal),
 		"neigh_min", float64(neighMinVal), "neigh_max", float64(neighMaxVal),
-		"hist_bins", histBins, "bin_scale", binScale)
+		"pixel_bins", bins[0], "neighborhood_bins", bins[1])
 
 	totalPixels := 0
 	for y := 0; y < rows; y++ {
@@ -56,17 +54,7 @@ func (pe *ProcessingEngine) build2DHistogram(src, neighborhood gocv.Mat, histBin
 			pixelValue := src.GetUCharAt(y, x)
 			neighValue := neighborhood.GetUCharAt(y, x)
 
-			pixelBin := int(float64(pixelValue) * binScale)
-			neighBin := int(float64(neighValue) * binScale)
-
-			if pixelBin >= histBins {
-				pixelBin = histBins - 1
-			}
-			if neighBin >= histBins {
-				neighBin = histBins - 1
-			}
-
-			histogram[pixelBin][neighBin]++
+			histogram.counts[histogram.index(histogramBin(pixelValue, bins[0]), histogramBin(neighValue, bins[1]))]++
 			totalPixels++
 		}
 	}
@@ -74,14 +62,10 @@ func (pe *ProcessingEngine) build2DHistogram(src, neighborhood gocv.Mat, histBin
 	// Debug: Analyze histogram distribution
 	nonZeroBins := 0
 	maxBinValue := 0.0
-	for i := 0; i < histBins; i++ {
-		for j := 0; j < histBins; j++ {
-			if histogram[i][j] > 0 {
-				nonZeroBins++
-				if histogram[i][j] > maxBinValue {
-					maxBinValue = histogram[i][j]
-				}
-			}
+	for _, count := range histogram.counts {
+		if count > 0 {
+			nonZeroBins++
+			maxBinValue = math.Max(maxBinValue, count)
 		}
 	}
 
@@ -89,139 +73,118 @@ func (pe *ProcessingEngine) build2DHistogram(src, neighborhood gocv.Mat, histBin
 		"total_pixels", totalPixels,
 		"non_zero_bins", nonZeroBins,
 		"max_bin_value", maxBinValue,
-		"bins_ratio", float64(nonZeroBins)/float64(histBins*histBins))
-
-	return histogram
-}
+		"bins_ratio", float64(nonZeroBins)/float64(bins[0]*bins[1]))
 
-func (pe *ProcessingEngine) createEmptyHistogram(histBins int) [][]float64 {
-	// Create properly initialized empty histogram with zero values
-	histogram := make([][]float64, histBins)
-	for i := range histogram {
-		histogram[i] = make([]float64, histBins) // Initialize inner slices with zeros
-	}
 	return histogram
 }
 
-func (pe *ProcessingEngine) applyLogScaling(histogram [][]float64) {
-	histBins := len(histogram)
-	for i := 0; i < histBins; i++ {
-		for j := 0; j < histBins; j++ {
-			if histogram[i][j] > 0 {
-				histogram[i][j] = math.Log1p(histogram[i][j])
-			}
+func (pe *ProcessingEngine) applyLogScaling(histogram histogram2D) {
+	for i, count := range histogram.counts {
+		if count > 0 {
+			histogram.counts[i] = math.Log1p(count)
 		}
 	}
 }
 
-func (pe *ProcessingEngine) normalizeHistogram(histogram [][]float64) {
-	histBins := len(histogram)
+func (pe *ProcessingEngine) normalizeHistogram(histogram histogram2D) {
 	total := 0.0
-
-	for i := 0; i < histBins; i++ {
-		for j := 0; j < histBins; j++ {
-			total += histogram[i][j]
-		}
+	for _, count := range histogram.counts {
+		total += count
 	}
 
 	if total > 0 {
 		invTotal := 1.0 / total
-		for i := 0; i < histBins; i++ {
-			for j := 0; j < histBins; j++ {
-				histogram[i][j] *= invTotal
-			}
+		for i := range histogram.counts {
+			histogram.counts[i] *= invTotal
 		}
 	}
 }
 
-func (pe *ProcessingEngine) smoothHistogram(histogram [][]float64, sigma float64) {
-	histBins := len(histogram)
+// smoothHistogram blurs the histogram with a Gaussian of sigma bins. The
+// kernel is separable, so it runs as a pass along each axis; bins beyond the
+// edges count as empty.
+func (pe *ProcessingEngine) smoothHistogram(histogram histogram2D, sigma float64) {
 	kernelRadius := int(sigma * 3)
-	kernelSize := kernelRadius*2 + 1
-
-	kernel := make([][]float64, kernelSize)
-	for i := range kernel {
-		kernel[i] = make([]float64, kernelSize)
-	}
+	kernel := make([]float64, kernelRadius*2+1)
 
 	sum := 0.0
 	invSigmaSq := 1.0 / (2.0 * sigma * sigma)
-
-	for i := 0; i < kernelSize; i++ {
-		for j := 0; j < kernelSize; j++ {
-			x := float64(i - kernelRadius)
-			y := float64(j - kernelRadius)
-			value := math.Exp(-(x*x + y*y) * invSigmaSq)
-			kernel[i][j] = value
-			sum += value
-		}
-	}
-
-	for i := 0; i < kernelSize; i++ {
-		for j := 0; j < kernelSize; j++ {
-			kernel[i][j] /= sum
-		}
+	for i := range kernel {
+		x := float64(i - kernelRadius)
+		kernel[i] = math.Exp(-x * x * invSigmaSq)
+		sum += kernel[i]
 	}
-
-	smoothed := make([][]float64, histBins)
-	for i := range smoothed {
-		smoothed[i] = make([]float64, histBins)
+	for i := range kernel {
+		kernel[i] /= sum
 	}
 
-	for i := 0; i < histBins; i++ {
-		for j := 0; j < histBins; j++ {
+	pixelBins, neighborhoodBins := histogram.pixelBins, histogram.neighborhoodBins
+	along := newHistogram2D([2]int{pixelBins, neighborhoodBins})
+	for i := 0; i < pixelBins; i++ {
+		source, target := histogram.row(i), along.row(i)
+		for j := range target {
 			value := 0.0
-
-			for ki := 0; ki < kernelSize; ki++ {
-				for kj := 0; kj < kernelSize; kj++ {
-					hi := i + ki - kernelRadius
-					hj := j + kj - kernelRadius
-
-					if hi >= 0 && hi < histBins && hj >= 0 && hj < histBins {
-						value += histogram[hi][hj] * kernel[ki][kj]
-					}
+			for k, weight := range kernel {
+				if hj := j + k - kernelRadius; hj >= 0 && hj < neighborhoodBins {
+					value += source[hj] * weight
 				}
 			}
-
-			smoothed[i][j] = value
+			target[j] = value
 		}
 	}
 
-	for i := 0; i < histBins; i++ {
-		copy(histogram[i], smoothed[i])
+	clear(histogram.counts)
+	for i := 0; i < pixelBins; i++ {
+		target := histogram.row(i)
+		for k, weight := range kernel {
+			hi := i + k - kernelRadius
+			if hi < 0 || hi >= pixelBins {
+				continue
+			}
+			for j, value := range along.row(hi) {
+				target[j] += value * weight
+			}
+		}
 	}
 }
 
-func (pe *ProcessingEngine) find2DOtsuThresholdInteger(histogram [][]float64) [2]int {
-	histBins := len(histogram)
-	bestThreshold := [2]int{histBins / 2, histBins / 2}
+// find2DOtsuThresholdInteger searches every pixel and neighborhood bin pair
+// for the one that best separates the histogram. Summed-area tables make
+// each candidate four lookups, so the search costs one step per bin pair.
+func (pe *ProcessingEngine) find2DOtsuThresholdInteger(histogram histogram2D) [2]int {
+	pixelBins, neighborhoodBins := histogram.pixelBins, histogram.neighborhoodBins
+	bestThreshold := [2]int{pixelBins / 2, neighborhoodBins / 2}
 	maxVariance := 0.0
 
-	totalSum := 0.0
-	totalCount := 0.0
-	for i := 0; i < histBins; i++ {
-		for j := 0; j < histBins; j++ {
-			weight := histogram[i][j]
-			totalSum += float64(i*histBins+j) * weight
-			totalCount += weight
-		}
-	}
+	moments := newHistogramMoments(histogram)
+	totalCount, _ := moments.box(0, pixelBins, 0, neighborhoodBins)
 
 	debugSystem := GetDebugSystem()
 
 	if totalCount == 0 {
 		debugSystem.logger.Error("histogram empty - no pixel data",
-			"histogram_bins", histBins)
+			"pixel_bins", pixelBins,
+			"neighborhood_bins", neighborhoodBins)
 		return bestThreshold
 	}
 
-	// Test thresholds and track variance quality
-	varianceData := make([]float64, 0, (histBins-2)*(histBins-2))
-
-	for t1 := 1; t1 < histBins-1; t1++ {
-		for t2 := 1; t2 < histBins-1; t2++ {
-			variance := pe.calculateVarianceForIntegerThresholds(histogram, t1, t2, totalSum, totalCount)
-			varianceData = append(varianceData, variance)
+	// Test thresholds and track variance quality. A candidate (t1, t2)
+	// splits the histogram into the boxes [0,t1]x[0,t2] and
+	// (t1,end)x(t2,end); bins in neither box are left out.
+	varianceSum := 0.0
+	candidates := 0
+	for t1 := 1; t1 < pixelBins-1; t1++ {
+		for t2 := 1; t2 < neighborhoodBins-1; t2++ {
+			w0, sum0 := moments.box(0, t1+1, 0, t2+1)
+			w1, sum1 := moments.box(t1+1, pixelBins, t2+1, neighborhoodBins)
+
+			variance := 0.0
+			if w0 > 0 && w1 > 0 {
+				meanDiff := sum0/w0 - sum1/w1
+				variance = w0 * w1 * meanDiff * meanDiff
+			}
+			varianceSum += variance
+			candidates++
 
 			if variance > maxVariance {
 				maxVariance = variance
@@ -231,11 +194,7 @@ func (pe *ProcessingEngine) find2DOtsuThresholdInteger(histogram [][]float64) [2
 	}
 
 	// Calculate variance statistics for quality assessment
-	avgVariance := 0.0
-	for _, v := range varianceData {
-		avgVariance += v
-	}
-	avgVariance /= float64(len(varianceData))
+	avgVariance := varianceSum / float64(candidates)
 
 	// Quality check - detect poor separation
 	varianceRatio := maxVariance / avgVariance
@@ -246,7 +205,8 @@ func (pe *ProcessingEngine) find2DOtsuThresholdInteger(histogram [][]float64) [2
 		"max_variance", maxVariance,
 		"avg_variance", avgVariance,
 		"variance_ratio", varianceRatio,
-		"histogram_bins", histBins,
+		"pixel_bins", pixelBins,
+		"neighborhood_bins", neighborhoodBins,
 		"total_count", totalCount)
 
 	if varianceRatio < 1.5 {
@@ -261,37 +221,7 @@ func (pe *ProcessingEngine) find2DOtsuThresholdInteger(histogram [][]float64) [2
 	return bestThreshold
 }
 
-func (pe *ProcessingEngine) calculateVarianceForIntegerThresholds(histogram [][]float64, t1, t2 int, totalSum, totalCount float64) float64 {
-	histBins := len(histogram)
-	var w0, w1, sum0, sum1 float64
-
-	for i := 0; i <= t1; i++ {
-		for j := 0; j <= t2; j++ {
-			weight := histogram[i][j]
-			w0 += weight
-			sum0 += float64(i*histBins+j) * weight
-		}
-	}
-
-	for i := t1 + 1; i < histBins; i++ {
-		for j := t2 + 1; j < histBins; j++ {
-			weight := histogram[i][j]
-			w1 += weight
-			sum1 += float64(i*histBins+j) * weight
-		}
-	}
-
-	if w0 > 0 && w1 > 0 {
-		mean0 := sum0 / w0
-		mean1 := sum1 / w1
-		meanDiff := mean0 - mean1
-		return w0 * w1 * meanDiff * meanDiff
-	}
-
-	return 0.0
-}
-
-func (pe *ProcessingEngine) applyThreshold(src, neighborhood gocv.Mat, threshold [2]int, histBins int) gocv.Mat {
+func (pe *ProcessingEngine) applyThreshold(src, neighborhood gocv.Mat, threshold [2]int, bins [2]int) gocv.Mat {
 	if err := validateMatForMetrics(src, "threshold application source"); err != nil {
 		return gocv.NewMat()
 	}
@@ -305,7 +235,6 @@ func (pe *ProcessingEngine) applyThreshold(src, neighborhood gocv.Mat, threshold
 	}
 
 	result := gocv.NewMatWithSize(src.Rows(), src.Cols(), gocv.MatTypeCV8UC1)
-	binScale := float64(histBins-1) / 255.0
 
 	foregroundPixels := 0
 	backgroundPixels := 0
@@ -315,8 +244,8 @@ func (pe *ProcessingEngine) applyThreshold(src, neighborhood gocv.Mat, threshold
 			pixelValue := src.GetUCharAt(y, x)
 			neighValue := neighborhood.GetUCharAt(y, x)
 
-			pixelBin := int(float64(pixelValue) * binScale)
-			neighBin := int(float64(neighValue) * binScale)
+			pixelBin := histogramBin(pixelValue, bins[0])
+			neighBin := histogramBin(neighValue, bins[1])
 
 			if pixelBin > threshold[0] && neighBin > threshold[1] {
 				result.SetUCharAt(y, x, 255)
@@ -338,7 +267,8 @@ func (pe *ProcessingEngine) applyThreshold(src, neighborhood gocv.Mat, threshold
 		"foreground_pixels", foregroundPixels,
 		"background_pixels", backgroundPixels,
 		"foreground_ratio", foregroundRatio,
-		"bin_scale", binScale)
+		"pixel_bins", bins[0],
+		"neighborhood_bins", bins[1])
 
 	// Enhanced diagnostic logging for problematic results
 	if foregroundPixels == 0 {
@@ -346,7 +276,8 @@ func (pe *ProcessingEngine) applyThreshold(src, neighborhood gocv.Mat, threshold
 		debugSystem.logger.Error("threshold produced all-background image",
 			"threshold_t1", threshold[0],
 			"threshold_t2", threshold[1],
-			"hist_bins", histBins,
+			"pixel_bins", bins[0],
+			"neighborhood_bins", bins[1],
 			"src_contrast", float64(maxVal-minVal),
 			"src_min", float64(minVal),
 			"src_max", float64(maxVal))
@@ -354,7 +285,8 @@ func (pe *ProcessingEngine) applyThreshold(src, neighborhood gocv.Mat, threshold
 		debugSystem.logger.Error("threshold produced all-foreground image",
 			"threshold_t1", threshold[0],
 			"threshold_t2", threshold[1],
-			"hist_bins", histBins)
+			"pixel_bins", bins[0],
+			"neighborhood_bins", bins[1])
 	}
 
 	if err := validateMatForMetrics(result, "threshold application result"); err != nil {
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+
+	"gocv.io/x/gocv"
+
+	"otsu-obliterator/progress"
+)
+
+// The 3D Otsu method thresholds pixel value, neighborhood mean and
+// gradient smoothness together. Its histogram and prefix sums hold
+// 4*(bins+1)^3 float64 values, about 8.8 MB at the 64 bin limit.
+const (
+	defaultOtsu3DBins = 32
+	minOtsu3DBins     = 8
+	maxOtsu3DBins     = 64
+)
+
+// processOtsu3D is an experimental extension of the 2D method with the
+// inverted gradient magnitude as a third axis. As in 2D, a pixel is paper
+// when all three of its bins exceed the threshold.
+func (pe *ProcessingEngine) processOtsu3D(reporter progress.Reporter, src gocv.Mat, params *OtsuParameters) gocv.Mat {
+	if err := validateMatForMetrics(src, "3D Otsu processing"); err != nil {
+		return gocv.NewMat()
+	}
+
+	bins := params.Otsu3DBins
+	if bins == 0 {
+		bins = defaultOtsu3DBins
+	}
+
+	windowSize := params.WindowSize
+	if params.AdaptiveWindowSizing {
+		windowSize = pe.calculateAdaptiveWindowSize(src)
+	}
+
+	pixels := src
+	if !src.IsContinuous() {
+		pixels = src.Clone()
+		defer pixels.Close()
+	}
+	mean := pe.calculateNeighborhood(src, windowSize, params.NeighborhoodType, params.LegacyNeighborhoods)
+	defer mean.Close()
+	smoothness := smoothnessFromGradient(src)
+	defer smoothness.Close()
+	if mean.Empty() || smoothness.Empty() {
+		return gocv.NewMat()
+	}
+
+	// Both passes bin the pixels through a lookup table rather than keeping
+	// three int bins per pixel, 24 bytes for every 1-byte pixel.
+	var binOf [256]int
+	for value := range binOf {
+		binOf[value] = histogramBin(uint8(value), bins)
+	}
+
+	axes := [3][]byte{pixels.ToBytes(), mean.ToBytes(), smoothness.ToBytes()}
+	count := len(axes[0])
+	histogram := make([]float64, bins*bins*bins)
+	for i := 0; i < count; i++ {
+		histogram[(binOf[axes[0][i]]*bins+binOf[axes[1][i]])*bins+binOf[axes[2][i]]]++
+	}
+
+	threshold, err := otsu3DThreshold(reporter, histogram, bins)
+	if err != nil {
+		return gocv.NewMat()
+	}
+
+	labels := make([]byte, count)
+	for i := range labels {
+		if binOf[axes[0][i]] > threshold[0] && binOf[axes[1][i]] > threshold[1] && binOf[axes[2][i]] > threshold[2] {
+			labels[i] = 255
+		}
+	}
+
+	GetDebugSystem().logger.Debug("3D Otsu threshold",
+		"threshold_pixel", threshold[0],
+		"threshold_mean", threshold[1],
+		"threshold_smoothness", threshold[2],
+		"bins", bins)
+
+	result, err := gocv.NewMatFromBytes(src.Rows(), src.Cols(), gocv.MatTypeCV8UC1, labels)
+	if err != nil {
+		return gocv.NewMat()
+	}
+	return result
+}
+
+// otsu3DThreshold maximizes the trace of the between-class scatter of the
+// boxes [0,s]x[0,t]x[0,u] and (s,bins)x(t,bins)x(u,bins). Prefix sums of
+// the count and of each coordinate make every candidate O(1), so the search
+// costs bins^3 steps rather than bins^6.
+func otsu3DThreshold(reporter progress.Reporter, histogram []float64, bins int) ([3]int, error) {
+	side := bins + 1
+	index := func(i, j, k int) int { return (i*side+j)*side + k }
+
+	// prefix[m][i][j][k] sums moment m (count, then i, j and k weighted)
+	// over the box [0,i)x[0,j)x[0,k).
+	var prefix [4][]float64
+	for m := range prefix {
+		prefix[m] = make([]float64, side*side*side)
+	}
+	for i := 1; i <= bins; i++ {
+		for j := 1; j <= bins; j++ {
+			for k := 1; k <= bins; k++ {
+				h := histogram[((i-1)*bins+j-1)*bins+k-1]
+				moments := [4]float64{h, h * float64(i-1), h * float64(j-1), h * float64(k-1)}
+				for m, p := range prefix {
+					p[index(i, j, k)] = moments[m] +
+						p[index(i-1, j, k)] + p[index(i, j-1, k)] + p[index(i, j, k-1)] -
+						p[index(i-1, j-1, k)] - p[index(i-1, j, k-1)] - p[index(i, j-1, k-1)] +
+						p[index(i-1, j-1, k-1)]
+				}
+			}
+		}
+	}
+
+	// box sums moment m over [i0,i1)x[j0,j1)x[k0,k1).
+	box := func(m, i0, i1, j0, j1, k0, k1 int) float64 {
+		p := prefix[m]
+		return p[index(i1, j1, k1)] -
+			p[index(i0, j1, k1)] - p[index(i1, j0, k1)] - p[index(i1, j1, k0)] +
+			p[index(i0, j0, k1)] + p[index(i0, j1, k0)] + p[index(i1, j0, k0)] -
+			p[index(i0, j0, k0)]
+	}
+
+	total := box(0, 0, bins, 0, bins, 0, bins)
+	if total == 0 {
+		return [3]int{}, fmt.Errorf("3D histogram is empty")
+	}
+	var totalMean [3]float64
+	for axis := range totalMean {
+		totalMean[axis] = box(axis+1, 0, bins, 0, bins, 0, bins) / total
+	}
+
+	best := [3]int{bins / 2, bins / 2, bins / 2}
+	bestScatter := 0.0
+	for s := 0; s < bins-1; s++ {
+		if err := progress.Steps(reporter, StageBinarize, s, bins-1, "3D threshold search"); err != nil {
+			return best, err
+		}
+		for t := 0; t < bins-1; t++ {
+			for u := 0; u < bins-1; u++ {
+				w0 := box(0, 0, s+1, 0, t+1, 0, u+1)
+				w1 := box(0, s+1, bins, t+1, bins, u+1, bins)
+				if w0 == 0 || w1 == 0 {
+					continue
+				}
+
+				scatter := 0.0
+				for axis := range totalMean {
+					mean0 := box(axis+1, 0, s+1, 0, t+1, 0, u+1) / w0
+					mean1 := box(axis+1, s+1, bins, t+1, bins, u+1, bins) / w1
+					scatter += w0*(mean0-totalMean[axis])*(mean0-totalMean[axis]) +
+						w1*(mean1-totalMean[axis])*(mean1-totalMean[axis])
+				}
+				if scatter > bestScatter {
+					bestScatter = scatter
+					best = [3]int{s, t, u}
+				}
+			}
+		}
+	}
+	return best, nil
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+
+	"gocv.io/x/gocv"
+)
+
+// ErrPageNotDetected is returned when perspective correction is requested
+// but no page outline is found and no corners were placed by hand.
+var ErrPageNotDetected = errors.New("page outline not detected, place the page corners by hand")
+
+const (
+	// Page detection runs on a copy whose longer side is at most this long.
+	pageDetectMaxSide = 1000
+
+	// The page must cover at least this fraction of the frame.
+	pageMinAreaFraction = 0.2
+)
+
+// PageCorners are the page corners in image pixels, ordered top-left,
+// top-right, bottom-right, bottom-left.
+type PageCorners [4]image.Point
+
+// SetPageCorners fixes the page outline used by perspective correction for
+// the current image; nil returns to automatic detection.
+func (pe *ProcessingEngine) SetPageCorners(corners *PageCorners) {
+	pe.pageCorners = corners
+}
+
+func (pe *ProcessingEngine) GetPageCorners() *PageCorners {
+	return pe.pageCorners
+}
+
+// DetectPageCorners looks for the page outline in the loaded image.
+func (pe *ProcessingEngine) DetectPageCorners() (PageCorners, error) {
+	if pe.originalImage == nil {
+		return PageCorners{}, fmt.Errorf("no original image loaded")
+	}
+
+	gray := pe.convertToGrayscale(pe.originalImage.Mat)
+	defer gray.Close()
+
+	return detectPageCorners(gray)
+}
+
+// pageGrayscale converts original, the image a run started from, to
+// grayscale after its color stages, and applies the page geometry stages
+// params select. The caller closes the result.
+func (pe *ProcessingEngine) pageGrayscale(original *ImageData, params *OtsuParameters) (gocv.Mat, error) {
+	gray := pe.colorGrayscale(original.Mat, params)
+	if !params.PerspectiveCorrection && !params.CylindricalDewarp {
+		return gray, nil
+	}
+
+	if params.PerspectiveCorrection {
+		var corners PageCorners
+		if pe.pageCorners != nil {
+			corners = *pe.pageCorners
+		} else {
+			detected, err := detectPageCorners(gray)
+			if err != nil {
+				gray.Close()
+				return gocv.NewMat(), err
+			}
+			corners = detected
+		}
+
+		warped := warpPage(gray, corners)
+		gray.Close()
+		gray = warped
+	}
+
+	if params.CylindricalDewarp {
+		unrolled := dewarpCylinder(gray, params.DewarpCurvature)
+		gray.Close()
+		gray = unrolled
+	}
+
+	if err := validateMatForMetrics(gray, "page geometry output"); err != nil {
+		gray.Close()
+		return gocv.NewMat(), fmt.Errorf("page geometry: %w", err)
+	}
+
+	return gray, nil
+}
+
+// detectPageCorners finds the largest four-sided contour in the edge map of
+// gray, which on a photographed page is the paper against its background.
+func detectPageCorners(gray gocv.Mat) (PageCorners, error) {
+	scale := 1.0
+	longSide := max(gray.Cols(), gray.Rows())
+	if longSide > pageDetectMaxSide {
+		scale = float64(pageDetectMaxSide) / float64(longSide)
+	}
+
+	small := gocv.NewMat()
+	defer small.Close()
+	smallSize := image.Pt(max(1, int(float64(gray.Cols())*scale)), max(1, int(float64(gray.Rows())*scale)))
+	gocv.Resize(gray, &small, smallSize, 0, 0, gocv.InterpolationArea)
+
+	blurred := gocv.NewMat()
+	defer blurred.Close()
+	gocv.GaussianBlur(small, &blurred, image.Pt(5, 5), 0, 0, gocv.BorderDefault)
+
+	edges := gocv.NewMat()
+	defer edges.Close()
+	gocv.Canny(blurred, &edges, 50, 150)
+
+	kernel := gocv.GetStructuringElement(gocv.MorphRect, image.Pt(3, 3))
+	defer kernel.Close()
+	gocv.Dilate(edges, &edges, kernel)
+
+	contours := gocv.FindContours(edges, gocv.RetrievalExternal, gocv.ChainApproxSimple)
+	defer contours.Close()
+
+	minArea := pageMinAreaFraction * float64(smallSize.X*smallSize.Y)
+	bestArea := 0.0
+	var best []image.Point
+
+	for i := 0; i < contours.Size(); i++ {
+		contour := contours.At(i)
+		area := gocv.ContourArea(contour)
+		if area < minArea || area <= bestArea {
+			continue
+		}
+
+		approx := gocv.ApproxPolyDP(contour, 0.02*gocv.ArcLength(contour, true), true)
+		points := approx.ToPoints()
+		approx.Close()
+
+		if len(points) == 4 {
+			bestArea = area
+			best = points
+		}
+	}
+
+	if best == nil {
+		return PageCorners{}, ErrPageNotDetected
+	}
+
+	for i := range best {
+		best[i] = image.Pt(int(float64(best[i].X)/scale), int(float64(best[i].Y)/scale))
+	}
+
+	return orderPageCorners(best), nil
+}
+
+// orderPageCorners sorts four points into top-left, top-right, bottom-right,
+// bottom-left using the sums and differences of their coordinates.
+func orderPageCorners(points []image.Point) PageCorners {
+	var corners PageCorners
+	corners[0], corners[1], corners[2], corners[3] = points[0], points[0], points[0], points[0]
+
+	for _, p := range points[1:] {
+		if p.X+p.Y < corners[0].X+corners[0].Y {
+			corners[0] = p
+		}
+		if p.X-p.Y > corners[1].X-corners[1].Y {
+			corners[1] = p
+		}
+		if p.X+p.Y > corners[2].X+corners[2].Y {
+			corners[2] = p
+		}
+		if p.Y-p.X > corners[3].Y-corners[3].X {
+			corners[3] = p
+		}
+	}
+
+	return corners
+}
+
+// warpPage maps the quadrilateral corners onto an upright rectangle sized by
+// the longer of each pair of opposite edges.
+func warpPage(src gocv.Mat, corners PageCorners) gocv.Mat {
+	distance := func(a, b image.Point) float64 {
+		return math.Hypot(float64(a.X-b.X), float64(a.Y-b.Y))
+	}
+
+	width := int(math.Max(distance(corners[0], corners[1]), distance(corners[3], corners[2])))
+	height := int(math.Max(distance(corners[0], corners[3]), distance(corners[1], corners[2])))
+	width, height = max(3, width), max(3, height)
+
+	srcPoints := gocv.NewPoint2fVectorFromPoints([]gocv.Point2f{
+		{X: float32(corners[0].X), Y: float32(corners[0].Y)},
+		{X: float32(corners[1].X), Y: float32(corners[1].Y)},
+		{X: float32(corners[2].X), Y: float32(corners[2].Y)},
+		{X: float32(corners[3].X), Y: float32(corners[3].Y)},
+	})
+	defer srcPoints.Close()
+
+	dstPoints := gocv.NewPoint2fVectorFromPoints([]gocv.Point2f{
+		{X: 0, Y: 0},
+		{X: float32(width - 1), Y: 0},
+		{X: float32(width - 1), Y: float32(height - 1)},
+		{X: 0, Y: float32(height - 1)},
+	})
+	defer dstPoints.Close()
+
+	transform := gocv.GetPerspectiveTransform2f(srcPoints, dstPoints)
+	defer transform.Close()
+
+	dst := gocv.NewMat()
+	gocv.WarpPerspective(src, &dst, transform, image.Pt(width, height))
+	return dst
+}
+
+// dewarpCylinder unrolls a page that bends around a vertical axis, as pages
+// near a book spine do. curvature is the fraction of a quarter turn the page
+// edges reach; text squeezed toward the edges is stretched back to its
+// unrolled width. Only the horizontal foreshortening is corrected.
+func dewarpCylinder(src gocv.Mat, curvature float64) gocv.Mat {
+	cols, rows := src.Cols(), src.Rows()
+	maxAngle := math.Max(0.01, math.Min(1, curvature)) * math.Pi / 2
+
+	halfSource := float64(cols) / 2
+	dstCols := int(float64(cols) * maxAngle / math.Sin(maxAngle))
+	halfDest := float64(dstCols) / 2
+
+	mapRow := gocv.NewMatWithSize(1, dstCols, gocv.MatTypeCV32F)
+	defer mapRow.Close()
+	for x := 0; x < dstCols; x++ {
+		angle := (float64(x) - halfDest) / halfDest * maxAngle
+		mapRow.SetFloatAt(0, x, float32(halfSource+halfSource*math.Sin(angle)/math.Sin(maxAngle)))
+	}
+
+	mapColumn := gocv.NewMatWithSize(rows, 1, gocv.MatTypeCV32F)
+	defer mapColumn.Close()
+	for y := 0; y < rows; y++ {
+		mapColumn.SetFloatAt(y, 0, float32(y))
+	}
+
+	mapX := gocv.NewMat()
+	defer mapX.Close()
+	gocv.Repeat(mapRow, rows, 1, &mapX)
+
+	mapY := gocv.NewMat()
+	defer mapY.Close()
+	gocv.Repeat(mapColumn, 1, dstCols, &mapY)
+
+	dst := gocv.NewMat()
+	gocv.Remap(src, &dst, &mapX, &mapY, gocv.InterpolationLinear, gocv.BorderConstant, color.RGBA{R: 255, G: 255, B: 255, A: 255})
+	return dst
+}
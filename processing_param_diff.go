@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// maxListedChanges bounds the changes formatParameterChanges spells out.
+const maxListedChanges = 4
+
+// ParameterDiff is one OtsuParameters field that differs between two
+// runs. From and To are empty for list fields, which are only marked as
+// changed.
+type ParameterDiff struct {
+	Field string `json:"field"`
+	From  string `json:"from,omitempty"`
+	To    string `json:"to,omitempty"`
+}
+
+func (pc ParameterDiff) String() string {
+	if pc.From == "" && pc.To == "" {
+		return pc.Field + " changed"
+	}
+	return fmt.Sprintf("%s %s→%s", pc.Field, pc.From, pc.To)
+}
+
+// diffParameters lists the fields of to that differ from from, in
+// declaration order. A nil from gives no changes.
+func diffParameters(from, to *OtsuParameters) []ParameterDiff {
+	if from == nil || to == nil {
+		return nil
+	}
+
+	var changes []ParameterDiff
+	fromValue, toValue := reflect.ValueOf(*from), reflect.ValueOf(*to)
+	for i := 0; i < fromValue.NumField(); i++ {
+		a, b := fromValue.Field(i), toValue.Field(i)
+		if reflect.DeepEqual(a.Interface(), b.Interface()) || a.Kind() == reflect.Slice && a.Len() == 0 && b.Len() == 0 {
+			continue
+		}
+		change := ParameterDiff{Field: fromValue.Type().Field(i).Name}
+		if a.Kind() != reflect.Slice {
+			change.From, change.To = formatParameterValue(a), formatParameterValue(b)
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// listParameters formats the fields of params as "Name: value" lines in
+// declaration order. List fields give their length and are left out when
+// empty.
+func listParameters(params *OtsuParameters) []string {
+	if params == nil {
+		return nil
+	}
+
+	var lines []string
+	value := reflect.ValueOf(*params)
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Field(i)
+		formatted := formatParameterValue(field)
+		if field.Kind() == reflect.Slice {
+			if field.Len() == 0 {
+				continue
+			}
+			formatted = fmt.Sprintf("%d entries", field.Len())
+		}
+		lines = append(lines, fmt.Sprintf("%s: %s", value.Type().Field(i).Name, formatted))
+	}
+	return lines
+}
+
+func formatParameterValue(value reflect.Value) string {
+	switch value.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return fmt.Sprintf("%.4g", value.Float())
+	case reflect.String:
+		if value.String() == "" {
+			return `""`
+		}
+	}
+	return fmt.Sprint(value.Interface())
+}
+
+// formatParameterChanges joins changes into one line, listing the first
+// few and counting the rest.
+func formatParameterChanges(changes []ParameterDiff) string {
+	listed := make([]string, 0, maxListedChanges+1)
+	for i, change := range changes {
+		if i == maxListedChanges {
+			listed = append(listed, fmt.Sprintf("%d more", len(changes)-i))
+			break
+		}
+		listed = append(listed, change.String())
+	}
+	return strings.Join(listed, ", ")
+}
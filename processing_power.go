@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+
+	"gocv.io/x/gocv"
+)
+
+// Battery saver modes: auto turns the saver on while the machine runs on
+// battery, on and off force it.
+const (
+	BatterySaverAuto = "auto"
+	BatterySaverOn   = "on"
+	BatterySaverOff  = "off"
+)
+
+// batterySaverMaxWorkers caps concurrent images and OpenCV threads while the
+// saver is on.
+const batterySaverMaxWorkers = 2
+
+var (
+	batterySaverActive atomic.Bool
+
+	// openCVThreads is OpenCV's own thread count, restored when the saver
+	// turns off.
+	openCVThreads = gocv.GetNumThreads()
+)
+
+func validateBatterySaverMode(mode string) error {
+	switch mode {
+	case BatterySaverAuto, BatterySaverOn, BatterySaverOff:
+		return nil
+	}
+	return fmt.Errorf("unknown battery saver mode %q: expected %s, %s or %s",
+		mode, BatterySaverAuto, BatterySaverOn, BatterySaverOff)
+}
+
+// ResolveBatterySaver reports whether mode turns the saver on now. A power
+// source that cannot be read counts as mains power.
+func ResolveBatterySaver(mode string) (bool, error) {
+	if err := validateBatterySaverMode(mode); err != nil {
+		return false, err
+	}
+	switch mode {
+	case BatterySaverOn:
+		return true, nil
+	case BatterySaverOff:
+		return false, nil
+	}
+
+	onBattery, err := OnBattery()
+	if err != nil {
+		GetDebugSystem().logger.Debug("power source unknown", "error", err.Error())
+		return false, nil
+	}
+	return onBattery, nil
+}
+
+// SetBatterySaver turns the saver on or off for the whole process.
+func SetBatterySaver(active bool) {
+	if batterySaverActive.Swap(active) == active {
+		return
+	}
+	if active {
+		gocv.SetNumThreads(batterySaverMaxWorkers)
+	} else {
+		gocv.SetNumThreads(openCVThreads)
+	}
+	GetDebugSystem().logger.Info("battery saver changed", "active", active)
+}
+
+func BatterySaverActive() bool {
+	return batterySaverActive.Load()
+}
+
+// workerLimit is the number of images to process at once for a requested
+// job count, 0 or less meaning one per CPU.
+func workerLimit(requested int) int {
+	if requested <= 0 {
+		requested = runtime.NumCPU()
+	}
+	if BatterySaverActive() && requested > batterySaverMaxWorkers {
+		return batterySaverMaxWorkers
+	}
+	return requested
+}
+
+// OnBattery reports whether the machine is running on battery, asking the
+// operating system: sysfs on Linux, pmset on macOS and WMI on Windows.
+func OnBattery() (bool, error) {
+	switch runtime.GOOS {
+	case "linux":
+		return linuxOnBattery()
+	case "darwin":
+		output, err := exec.Command("pmset", "-g", "batt").Output()
+		if err != nil {
+			return false, fmt.Errorf("pmset: %w", err)
+		}
+		return strings.Contains(string(output), "'Battery Power'"), nil
+	case "windows":
+		// BatteryStatus 1 is discharging; machines without a battery print
+		// nothing.
+		output, err := exec.Command("powershell", "-NoProfile", "-Command",
+			"(Get-CimInstance -ClassName Win32_Battery).BatteryStatus").Output()
+		if err != nil {
+			return false, fmt.Errorf("query battery status: %w", err)
+		}
+		return strings.TrimSpace(string(output)) == "1", nil
+	}
+	return false, fmt.Errorf("power source detection is not supported on %s", runtime.GOOS)
+}
+
+// linuxOnBattery treats the machine as on battery when it has a battery and
+// none of its mains adapters is online.
+func linuxOnBattery() (bool, error) {
+	supplies, err := filepath.Glob("/sys/class/power_supply/*")
+	if err != nil {
+		return false, err
+	}
+
+	hasBattery := false
+	for _, supply := range supplies {
+		kind, err := os.ReadFile(filepath.Join(supply, "type"))
+		if err != nil {
+			continue
+		}
+		switch strings.TrimSpace(string(kind)) {
+		case "Mains", "USB":
+			online, err := os.ReadFile(filepath.Join(supply, "online"))
+			if err == nil && strings.TrimSpace(string(online)) == "1" {
+				return false, nil
+			}
+		case "Battery":
+			// Peripheral batteries, such as a mouse's, report a scope.
+			if scope, err := os.ReadFile(filepath.Join(supply, "scope")); err == nil && strings.TrimSpace(string(scope)) == "Device" {
+				continue
+			}
+			hasBattery = true
+		}
+	}
+	return hasBattery, nil
+}
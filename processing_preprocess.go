@@ -5,8 +5,63 @@ import (
 	"math"
 
 	"gocv.io/x/gocv"
+
+	"otsu-obliterator/progress"
 )
 
+// preprocess runs the grayscale preprocessing chain selected by params on
+// gray, in strips for very large images, and returns a new Mat the caller
+// closes. Once reporter is cancelled the chain cuts its slow loops short,
+// and the caller discards the result.
+func (pe *ProcessingEngine) preprocess(reporter progress.Reporter, gray gocv.Mat, params *OtsuParameters) gocv.Mat {
+	if usesStrips(float64(gray.Total()), params) {
+		return pe.preprocessStrips(reporter, gray, params)
+	}
+	return pe.preprocessImage(reporter, gray, params)
+}
+
+func (pe *ProcessingEngine) preprocessImage(reporter progress.Reporter, gray gocv.Mat, params *OtsuParameters) gocv.Mat {
+	working := gray.Clone()
+
+	if hasToneAdjustment(params) {
+		toned := pe.applyToneAdjustment(working, params)
+		working.Close()
+		working = toned
+	}
+
+	if params.ShadowRemoval {
+		flattened := pe.applyShadowRemoval(working, params.ShadowRemovalMethod, params.ShadowRemovalStrength)
+		working.Close()
+		working = flattened
+	}
+
+	if params.HomomorphicFiltering {
+		homomorphic := pe.applyHomomorphicFiltering(working)
+		working.Close()
+		working = homomorphic
+	}
+
+	if params.AnisotropicDiffusion {
+		diffused := pe.applyAnisotropicDiffusion(reporter, working, params.DiffusionIterations, params.DiffusionKappa)
+		working.Close()
+		working = diffused
+	}
+
+	if params.GaussianPreprocessing {
+		blurred := pe.applyGaussianBlur(working, params.SmoothingStrength)
+		working.Close()
+		working = blurred
+	}
+
+	if params.ApplyContrastEnhancement {
+		enhanced := pe.applyAdaptiveContrastEnhancement(working)
+		working.Close()
+		working = enhanced
+	}
+
+	return working
+}
+
 func (pe *ProcessingEngine) applyGaussianBlur(src gocv.Mat, sigma float64) gocv.Mat {
 	if err := validateMatForMetrics(src, "Gaussian blur input"); err != nil {
 		return gocv.NewMat()
@@ -109,7 +164,10 @@ func (pe *ProcessingEngine) applyHomomorphicFiltering(src gocv.Mat) gocv.Mat {
 	return result
 }
 
-func (pe *ProcessingEngine) applyAnisotropicDiffusion(src gocv.Mat, iterations int, kappa float64) gocv.Mat {
+// applyAnisotropicDiffusion smooths src with Perona-Malik diffusion. The
+// per-pixel loops check reporter once a row; a cancelled run stops there
+// and returns the partly diffused image.
+func (pe *ProcessingEngine) applyAnisotropicDiffusion(reporter progress.Reporter, src gocv.Mat, iterations int, kappa float64) gocv.Mat {
 	if err := validateMatForMetrics(src, "anisotropic diffusion input"); err != nil {
 		return gocv.NewMat()
 	}
@@ -127,8 +185,14 @@ func (pe *ProcessingEngine) applyAnisotropicDiffusion(src gocv.Mat, iterations i
 	next := gocv.NewMatWithSize(rows, cols, gocv.MatTypeCV32F)
 	defer next.Close()
 
+diffusion:
 	for iter := 0; iter < iterations; iter++ {
+		reporter.Report(StagePreprocess, float64(iter)/float64(iterations), "anisotropic diffusion")
 		for y := 1; y < rows-1; y++ {
+			if reporter.Err() != nil {
+				GetDebugSystem().logger.Debug("anisotropic diffusion cancelled", "iteration", iter, "row", y)
+				break diffusion
+			}
 			for x := 1; x < cols-1; x++ {
 				center := current.GetFloatAt(y, x)
 				north := current.GetFloatAt(y-1, x)
@@ -164,39 +228,3 @@ func (pe *ProcessingEngine) applyAnisotropicDiffusion(src gocv.Mat, iterations i
 
 	return result
 }
-
-func (pe *ProcessingEngine) applyMorphologicalPostProcessing(src gocv.Mat, kernelSize int) gocv.Mat {
-	if err := validateMatForMetrics(src, "morphological post-processing input"); err != nil {
-		return gocv.NewMat()
-	}
-
-	if err := validateBinaryMat(src, "morphological post-processing"); err != nil {
-		// Try to create binary mask if input isn't properly binary
-		binaryMask, maskErr := createBinaryMask(src, 127)
-		if maskErr != nil {
-			return gocv.NewMat()
-		}
-		defer binaryMask.Close()
-		src = binaryMask
-	}
-
-	openingKernel := gocv.GetStructuringElement(gocv.MorphEllipse, image.Pt(kernelSize, kernelSize))
-	defer openingKernel.Close()
-
-	opened := gocv.NewMat()
-	defer opened.Close()
-	gocv.MorphologyEx(src, &opened, gocv.MorphOpen, openingKernel)
-
-	closingKernel := gocv.GetStructuringElement(gocv.MorphEllipse, image.Pt(kernelSize+2, kernelSize+2))
-	defer closingKernel.Close()
-
-	result := gocv.NewMat()
-	gocv.MorphologyEx(opened, &result, gocv.MorphClose, closingKernel)
-
-	if err := validateMatForMetrics(result, "morphological post-processing output"); err != nil {
-		result.Close()
-		return gocv.NewMat()
-	}
-
-	return result
-}
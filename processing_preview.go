@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"image"
+
+	"gocv.io/x/gocv"
+)
+
+// PreviewMaxWidth bounds the frame size of the fast preview pipeline at the
+// balanced preview quality; see PreviewSettings.
+const PreviewMaxWidth = 640
+
+// ProcessPreview is a fast approximation of the full pipeline for live
+// previews: the frame is downscaled to maxWidth, tone adjusted, shadow
+// corrected and smoothed as params ask, and thresholded with global Otsu, or OpenCV's adaptive mean
+// threshold when params select region-adaptive processing. The caller closes
+// the result.
+func (pe *ProcessingEngine) ProcessPreview(src gocv.Mat, params *OtsuParameters, maxWidth int) (gocv.Mat, error) {
+	if src.Empty() {
+		return gocv.NewMat(), fmt.Errorf("preview: empty frame")
+	}
+
+	gray := pe.colorGrayscale(src, params)
+	defer gray.Close()
+
+	working := gray
+	if gray.Cols() > maxWidth {
+		scaled := gocv.NewMat()
+		defer scaled.Close()
+		height := gray.Rows() * maxWidth / gray.Cols()
+		gocv.Resize(gray, &scaled, image.Pt(maxWidth, height), 0, 0, gocv.InterpolationArea)
+		working = scaled
+	}
+
+	if hasToneAdjustment(params) {
+		toned := pe.applyToneAdjustment(working, params)
+		defer toned.Close()
+		if !toned.Empty() {
+			working = toned
+		}
+	}
+
+	if params.ShadowRemoval {
+		flattened := pe.applyShadowRemoval(working, params.ShadowRemovalMethod, params.ShadowRemovalStrength)
+		defer flattened.Close()
+		if !flattened.Empty() {
+			working = flattened
+		}
+	}
+
+	if params.GaussianPreprocessing && params.SmoothingStrength > 0 {
+		blurred := pe.applyGaussianBlur(working, params.SmoothingStrength)
+		defer blurred.Close()
+		if !blurred.Empty() {
+			working = blurred
+		}
+	}
+
+	result := gocv.NewMat()
+	if params.RegionAdaptiveThresholding {
+		blockSize := max(3, params.WindowSize|1)
+		gocv.AdaptiveThreshold(working, &result, 255, gocv.AdaptiveThresholdMean, gocv.ThresholdBinary, blockSize, 5)
+	} else {
+		gocv.Threshold(working, &result, 0, 255, gocv.ThresholdBinary|gocv.ThresholdOtsu)
+	}
+
+	if params.MorphologicalPostProcess {
+		morphed := pe.applyMorphologicalPostProcessing(result, morphOperations(params))
+		result.Close()
+		result = morphed
+	}
+
+	return result, nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"sync/atomic"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+// Preview qualities trade the resolution of live and tone previews, and how
+// long parameter changes settle before a run, against responsiveness.
+// PreviewQualityAuto picks one by timing the machine at startup.
+const (
+	PreviewQualityAuto     = "auto"
+	PreviewQualityHigh     = "high"
+	PreviewQualityBalanced = "balanced"
+	PreviewQualityLow      = "low"
+)
+
+// PreviewSettings are the preview limits in effect.
+type PreviewSettings struct {
+	Quality string
+
+	// LiveWidth bounds live capture frames, ToneWidth the tone preview.
+	LiveWidth int
+	ToneWidth int
+
+	// Debounce is how long parameter changes must settle before the image
+	// is processed again.
+	Debounce time.Duration
+}
+
+var previewPresets = map[string]PreviewSettings{
+	PreviewQualityHigh:     {Quality: PreviewQualityHigh, LiveWidth: 1280, ToneWidth: 2400, Debounce: 120 * time.Millisecond},
+	PreviewQualityBalanced: {Quality: PreviewQualityBalanced, LiveWidth: PreviewMaxWidth, ToneWidth: TonePreviewMaxWidth, Debounce: 200 * time.Millisecond},
+	PreviewQualityLow:      {Quality: PreviewQualityLow, LiveWidth: 480, ToneWidth: 1024, Debounce: 400 * time.Millisecond},
+}
+
+// Calibration throughput, in megapixels per second through the preview
+// pipeline, from which a machine gets the high or the balanced preset.
+const (
+	previewHighThroughput     = 200.0
+	previewBalancedThroughput = 60.0
+
+	previewCalibrationWidth  = 1600
+	previewCalibrationHeight = 1200
+	previewCalibrationRuns   = 3
+)
+
+var currentPreviewSettings atomic.Pointer[PreviewSettings]
+
+func init() {
+	settings := previewPresets[PreviewQualityBalanced]
+	currentPreviewSettings.Store(&settings)
+}
+
+func CurrentPreviewSettings() PreviewSettings {
+	return *currentPreviewSettings.Load()
+}
+
+// SetPreviewQuality applies the preset named quality, which must not be
+// PreviewQualityAuto.
+func SetPreviewQuality(quality string) error {
+	settings, ok := previewPresets[quality]
+	if !ok {
+		return fmt.Errorf("unknown preview quality %q: expected %s, %s or %s",
+			quality, PreviewQualityHigh, PreviewQualityBalanced, PreviewQualityLow)
+	}
+	currentPreviewSettings.Store(&settings)
+	return nil
+}
+
+// CalibratePreviewQuality times the preview pipeline on a synthetic page and
+// returns the preset it suggests with the measured throughput in megapixels
+// per second. The best of a few runs counts, so a busy moment at startup
+// does not drop a fast machine to a lower preset.
+func CalibratePreviewQuality() (string, float64, error) {
+	page := syntheticCalibrationPage()
+	defer page.Close()
+
+	engine := NewProcessingEngine()
+	defer engine.Close()
+	params := DefaultOtsuParameters()
+	params.GaussianPreprocessing = true
+	params.SmoothingStrength = 1
+
+	var best time.Duration
+	for run := 0; run < previewCalibrationRuns; run++ {
+		start := time.Now()
+		result, err := engine.ProcessPreview(page, params, previewCalibrationWidth)
+		elapsed := time.Since(start)
+		result.Close()
+		if err != nil {
+			return "", 0, fmt.Errorf("preview calibration: %w", err)
+		}
+		if run == 0 || elapsed < best {
+			best = elapsed
+		}
+	}
+
+	megapixels := float64(previewCalibrationWidth*previewCalibrationHeight) / 1e6
+	throughput := megapixels / math.Max(best.Seconds(), 1e-6)
+	switch {
+	case throughput >= previewHighThroughput:
+		return PreviewQualityHigh, throughput, nil
+	case throughput >= previewBalancedThroughput:
+		return PreviewQualityBalanced, throughput, nil
+	}
+	return PreviewQualityLow, throughput, nil
+}
+
+// syntheticCalibrationPage draws lines of dark blocks on a shaded page, so
+// the calibration exercises the pipeline as text would.
+func syntheticCalibrationPage() gocv.Mat {
+	page := gocv.NewMatWithSize(previewCalibrationHeight, previewCalibrationWidth, gocv.MatTypeCV8UC3)
+	for y := 0; y < previewCalibrationHeight; y++ {
+		shade := uint8(200 + 40*y/previewCalibrationHeight)
+		gocv.Line(&page, image.Pt(0, y), image.Pt(previewCalibrationWidth-1, y), color.RGBA{shade, shade, shade, 0}, 1)
+	}
+	ink := color.RGBA{40, 40, 40, 0}
+	for line := 0; line < 30; line++ {
+		top := 60 + line*36
+		for x := 80; x < previewCalibrationWidth-80; x += 22 + line%5 {
+			gocv.Rectangle(&page, image.Rect(x, top, x+14, top+18), ink, -1)
+		}
+	}
+	return page
+}
@@ -0,0 +1,40 @@
+package main
+
+import "otsu-obliterator/progress"
+
+// Stages of the processing pipeline as reported to a progress.Reporter.
+const (
+	StagePage        = "page"
+	StagePreprocess  = "preprocess"
+	StageBinarize    = "binarize"
+	StagePostprocess = "postprocess"
+	StageMetrics     = "metrics"
+)
+
+// pipelineStages is the share of a run each stage takes, in order; the
+// thresholding method dominates.
+var pipelineStages = []struct {
+	Name  string
+	Share float64
+}{
+	{StagePage, 0.10},
+	{StagePreprocess, 0.15},
+	{StageBinarize, 0.60},
+	{StagePostprocess, 0.08},
+	{StageMetrics, 0.07},
+}
+
+// stageProgress reports that stage starts and returns the reporter for the
+// work inside it.
+func stageProgress(reporter progress.Reporter, stage string) progress.Reporter {
+	from := 0.0
+	for _, candidate := range pipelineStages {
+		if candidate.Name == stage {
+			span := progress.Span(reporter, from, from+candidate.Share)
+			span.Report(stage, 0, "")
+			return span
+		}
+		from += candidate.Share
+	}
+	return reporter
+}
@@ -5,10 +5,12 @@ import (
 	"image"
 
 	"gocv.io/x/gocv"
+
+	"otsu-obliterator/progress"
 )
 
 // Real Gaussian pyramid using 5x5 kernel and proper downsampling
-func (pe *ProcessingEngine) processMultiScalePyramid(src gocv.Mat, params *OtsuParameters) gocv.Mat {
+func (pe *ProcessingEngine) processMultiScalePyramid(reporter progress.Reporter, src gocv.Mat, params *OtsuParameters) gocv.Mat {
 	if err := validateMatForMetrics(src, "multi-scale processing"); err != nil {
 		return gocv.NewMat()
 	}
@@ -64,6 +66,13 @@ func (pe *ProcessingEngine) processMultiScalePyramid(src gocv.Mat, params *OtsuP
 	// Process each level with scale-appropriate parameters
 	results := make([]gocv.Mat, levels+1)
 	for i := 0; i <= levels; i++ {
+		if err := progress.Steps(reporter, StageBinarize, i, levels+1, fmt.Sprintf("pyramid level %d of %d", i+1, levels+1)); err != nil {
+			for j := 0; j < i; j++ {
+				results[j].Close()
+			}
+			return gocv.NewMat()
+		}
+
 		scaleParams := *params
 		scaleParams.MultiScaleProcessing = false
 		scaleParams.WindowSize = max(3, params.WindowSize/(1<<i))
@@ -74,6 +83,9 @@ func (pe *ProcessingEngine) processMultiScalePyramid(src gocv.Mat, params *OtsuP
 		if scaleParams.HistogramBins > 0 {
 			scaleParams.HistogramBins = max(32, params.HistogramBins/(1<<i))
 		}
+		if scaleParams.NeighborhoodBins > 0 {
+			scaleParams.NeighborhoodBins = max(32, params.NeighborhoodBins/(1<<i))
+		}
 
 		results[i] = pe.processSingleScale(pyramid[i], &scaleParams)
 	}
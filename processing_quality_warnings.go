@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+
+	"gocv.io/x/gocv"
+)
+
+const (
+	WarningUniformOutput   = "uniform_output"
+	WarningSparseInk       = "sparse_ink"
+	WarningDenseInk        = "dense_ink"
+	WarningPoorSeparation  = "poor_separation"
+	WarningRegionFallback  = "region_fallback"
+	WarningRegionGridLarge = "region_grid_large"
+)
+
+// Thresholds of the quality checks. A single Gaussian peak splits at a
+// between-class share of 2/π ≈ 0.64, so input below minSeparability has no
+// clear ink and paper modes.
+const (
+	minInkRatio     = 0.002
+	maxInkRatio     = 0.5
+	minSeparability = 0.65
+)
+
+// QualityWarning is an anomaly of the latest run, with what it means and
+// which parameters are worth changing.
+type QualityWarning struct {
+	Kind       string
+	Message    string
+	Suggestion string
+}
+
+// assessQuality checks the binarization input working and the final result
+// of a run for anomalies that usually mean unsuitable parameters.
+func assessQuality(working, result gocv.Mat, params *OtsuParameters, audit *RegionAudit) []QualityWarning {
+	var warnings []QualityWarning
+
+	if audit != nil {
+		switch audit.Fallback {
+		case RegionFallbackGlobalOtsu:
+			warnings = append(warnings, QualityWarning{
+				Kind:       WarningRegionFallback,
+				Message:    "Region adaptive output was uniform, so global Otsu was used instead.",
+				Suggestion: "Lower Region Grid Size or Smoothing Strength, or try Single Scale.",
+			})
+		case RegionFallbackSingleScale:
+			warnings = append(warnings, QualityWarning{
+				Kind:       WarningRegionGridLarge,
+				Message:    "Region Grid Size is too large for this image, so Single Scale was used instead.",
+				Suggestion: fmt.Sprintf("Lower Region Grid Size below %d.", params.RegionGridSize),
+			})
+		}
+	}
+
+	if !result.Empty() {
+		total := result.Rows() * result.Cols()
+		inkRatio := 1 - float64(gocv.CountNonZero(result))/float64(total)
+		switch {
+		case inkRatio == 0:
+			warnings = append(warnings, QualityWarning{
+				Kind:       WarningUniformOutput,
+				Message:    "The result is blank: every pixel became paper.",
+				Suggestion: "Check that Brightness, Contrast and Gamma have not washed out the ink, or try Region Adaptive or Kapur Entropy.",
+			})
+		case inkRatio == 1:
+			warnings = append(warnings, QualityWarning{
+				Kind:       WarningUniformOutput,
+				Message:    "The result is solid black: every pixel became ink.",
+				Suggestion: "Raise Brightness or Gamma, or turn on Shadow Removal or Region Adaptive for dark, unevenly lit pages.",
+			})
+		case inkRatio < minInkRatio:
+			warnings = append(warnings, QualityWarning{
+				Kind:       WarningSparseInk,
+				Message:    fmt.Sprintf("Almost no ink was found (%.2f%% of pixels).", 100*inkRatio),
+				Suggestion: "Lower Smoothing Strength or Window Size, or try Region Adaptive for faint text.",
+			})
+		case inkRatio > maxInkRatio:
+			warnings = append(warnings, QualityWarning{
+				Kind:       WarningDenseInk,
+				Message:    fmt.Sprintf("%.0f%% of the page became ink, more than text usually covers.", 100*inkRatio),
+				Suggestion: "Turn on Shadow Removal or Stain Suppression, or try Region Adaptive for uneven lighting.",
+			})
+		}
+	}
+
+	if !working.Empty() && working.Type() == gocv.MatTypeCV8UC1 {
+		pixels := working
+		if !working.IsContinuous() {
+			pixels = working.Clone()
+			defer pixels.Close()
+		}
+		var histogram [256]int
+		for _, value := range pixels.ToBytes() {
+			histogram[value]++
+		}
+		if _, separability := otsuSeparability(histogram); separability < minSeparability {
+			warnings = append(warnings, QualityWarning{
+				Kind:       WarningPoorSeparation,
+				Message:    fmt.Sprintf("Ink and paper gray levels overlap (separability %.2f).", separability),
+				Suggestion: "Try Adaptive Contrast Enhancement, Shadow Removal or another Grayscale Channel (File > Inspect Channels...).",
+			})
+		}
+	}
+
+	logger := GetDebugSystem().logger
+	for _, warning := range warnings {
+		logger.Warn("quality warning", "kind", warning.Kind, "message", warning.Message)
+	}
+	return warnings
+}
+
+// setQualityWarnings keeps the warnings of the latest run.
+func (pe *ProcessingEngine) setQualityWarnings(warnings []QualityWarning) {
+	pe.historyMu.Lock()
+	defer pe.historyMu.Unlock()
+	pe.qualityWarnings = warnings
+}
+
+// QualityWarnings returns the anomalies found in the latest run.
+func (pe *ProcessingEngine) QualityWarnings() []QualityWarning {
+	pe.historyMu.Lock()
+	defer pe.historyMu.Unlock()
+	return pe.qualityWarnings
+}
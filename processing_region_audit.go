@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"os"
+)
+
+// Region decisions of the region-adaptive method.
+const (
+	RegionThresholded = "thresholded"
+	RegionLowContrast = "low_contrast"
+	RegionTooSmall    = "too_small"
+	RegionFailed      = "failed"
+)
+
+// Fallback levels of overlapping regions: standard 2D Otsu on a bimodal
+// region, 2D Otsu on a region grown into its neighborhood, and 2D Otsu with
+// adaptive windows and smoothing. Non-overlapping regions always use level
+// 1.
+const (
+	RegionLevelStandard = 1
+	RegionLevelExpanded = 2
+	RegionLevelGlobal   = 3
+)
+
+// Whole-image fallbacks that replace the regions.
+const (
+	RegionFallbackSingleScale = "single_scale"
+	RegionFallbackGlobalOtsu  = "global_otsu"
+)
+
+// RegionBounds is a rectangle of the working image.
+type RegionBounds struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+func newRegionBounds(rect image.Rectangle) RegionBounds {
+	return RegionBounds{X: rect.Min.X, Y: rect.Min.Y, Width: rect.Dx(), Height: rect.Dy()}
+}
+
+// RegionAuditEntry records what the region-adaptive method decided for one
+// region. Cell is the [column, row] of the ink density grid cell holding
+// the region's center. Threshold is the 2D Otsu threshold in histogram bins
+// of pixel and neighborhood mean, of which there are HistogramBins and
+// NeighborhoodBins.
+type RegionAuditEntry struct {
+	Bounds           RegionBounds  `json:"bounds"`
+	Cell             [2]int        `json:"cell"`
+	Contrast         float64       `json:"contrast"`
+	Entropy          float64       `json:"entropy"`
+	Decision         string        `json:"decision"`
+	FallbackLevel    int           `json:"fallback_level,omitempty"`
+	Expanded         *RegionBounds `json:"expanded,omitempty"`
+	Threshold        *[2]int       `json:"threshold,omitempty"`
+	HistogramBins    int           `json:"histogram_bins,omitempty"`
+	NeighborhoodBins int           `json:"neighborhood_bins,omitempty"`
+	InkRatio         float64       `json:"ink_ratio"`
+}
+
+// bins is the pixel and neighborhood bin counts the entry's threshold is
+// measured in, fallback for audits written before they were recorded.
+func (e *RegionAuditEntry) bins(fallback [2]int) [2]int {
+	bins := fallback
+	if e.HistogramBins > 0 {
+		bins = [2]int{e.HistogramBins, e.HistogramBins}
+	}
+	if e.NeighborhoodBins > 0 {
+		bins[1] = e.NeighborhoodBins
+	}
+	return bins
+}
+
+// RegionAudit is the per-region record of a region-adaptive run, in
+// working image coordinates. ScaleFactor relates them to the source file.
+type RegionAudit struct {
+	Input       string             `json:"input,omitempty"`
+	Width       int                `json:"width"`
+	Height      int                `json:"height"`
+	ScaleFactor float64            `json:"scale_factor"`
+	Overlapping bool               `json:"overlapping"`
+	GridSize    int                `json:"grid_size"`
+	Overlap     int                `json:"overlap"`
+	DensityGrid int                `json:"density_grid"`
+	Fallback    string             `json:"fallback,omitempty"`
+	Regions     []RegionAuditEntry `json:"regions"`
+}
+
+func newRegionAudit(width, height, gridSize, overlap int) *RegionAudit {
+	return &RegionAudit{
+		Width:       width,
+		Height:      height,
+		ScaleFactor: 1,
+		Overlapping: overlap > 0,
+		GridSize:    gridSize,
+		Overlap:     overlap,
+		DensityGrid: ComponentDensityGrid,
+		Regions:     []RegionAuditEntry{},
+	}
+}
+
+// add records entry for the region at rect.
+func (ra *RegionAudit) add(rect image.Rectangle, entry RegionAuditEntry) {
+	entry.Bounds = newRegionBounds(rect)
+	center := image.Pt((rect.Min.X+rect.Max.X)/2, (rect.Min.Y+rect.Max.Y)/2)
+	entry.Cell = densityGridCellAt(ra.Width, ra.Height, center)
+	ra.Regions = append(ra.Regions, entry)
+}
+
+// Decisions counts the regions of each decision.
+func (ra *RegionAudit) Decisions() map[string]int {
+	counts := make(map[string]int)
+	for _, region := range ra.Regions {
+		counts[region.Decision]++
+	}
+	return counts
+}
+
+func (ra *RegionAudit) Write(path string) error {
+	data, err := json.MarshalIndent(ra, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode region audit: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("write region audit: %w", err)
+	}
+	return nil
+}
+
+// setRegionAudit keeps the audit of the latest run; methods other than
+// region-adaptive clear it.
+func (pe *ProcessingEngine) setRegionAudit(audit *RegionAudit) {
+	pe.historyMu.Lock()
+	defer pe.historyMu.Unlock()
+	pe.regionAudit = audit
+}
+
+// RegionAudit returns the audit of the latest run, or nil when it did not
+// use the region-adaptive method.
+func (pe *ProcessingEngine) RegionAudit() *RegionAudit {
+	pe.historyMu.Lock()
+	defer pe.historyMu.Unlock()
+	return pe.regionAudit
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"image"
+	"runtime"
+	"sync"
+
+	"gocv.io/x/gocv"
+
+	"otsu-obliterator/progress"
+)
+
+// regionOutcome is what a worker made of one region: its audit entry,
+// without the bounds, and the thresholded region, empty unless the region
+// was thresholded. The merge phase takes ownership of result.
+type regionOutcome struct {
+	entry  RegionAuditEntry
+	result gocv.Mat
+}
+
+func (ro regionOutcome) close() {
+	ro.result.Close()
+}
+
+// regionTiles lays a grid of gridSize cells over a rows by cols image,
+// advancing by gridSize-overlap, in row-major order. Cells at the right and
+// bottom edges are cut short.
+func regionTiles(rows, cols, gridSize, overlap int) []image.Rectangle {
+	step := max(1, gridSize-overlap)
+	var tiles []image.Rectangle
+	for y := 0; y < rows; y += step {
+		for x := 0; x < cols; x += step {
+			tiles = append(tiles, image.Rect(x, y, intMin(x+gridSize, cols), intMin(y+gridSize, rows)))
+		}
+	}
+	return tiles
+}
+
+// regionWorkers is the number of regions thresholded at once: one per
+// GOMAXPROCS, fewer under the battery saver, and never more than tiles.
+func regionWorkers(tiles int) int {
+	return max(1, intMin(workerLimit(runtime.GOMAXPROCS(0)), tiles))
+}
+
+// regionPoolResult carries the outcome of one tile, or the panic that
+// stopped it, from a worker to the merge phase.
+type regionPoolResult[T any] struct {
+	index   int
+	outcome T
+	panic   any
+}
+
+// runRegionPool runs process on every tile on a pool of regionWorkers
+// goroutines and hands the outcomes to merge on the calling goroutine in
+// tile order, so merging needs no locks and the result does not depend on
+// scheduling. process must only read shared Mats. Once reporter is
+// cancelled no further tiles start, the outcomes not yet merged go to
+// discard and the cancellation error is returned. A panic in process is
+// raised again on the calling goroutine after the workers stop.
+func runRegionPool[T any](reporter progress.Reporter, message string, tiles []image.Rectangle,
+	process func(bounds image.Rectangle) T, merge func(bounds image.Rectangle, outcome T), discard func(T)) error {
+	workers := regionWorkers(len(tiles))
+	jobs := make(chan int)
+	results := make(chan regionPoolResult[T], workers)
+	stop := make(chan struct{})
+
+	go func() {
+		defer close(jobs)
+		for i := range tiles {
+			select {
+			case jobs <- i:
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results <- runRegionTile(i, tiles[i], process)
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	GetDebugSystem().logger.Debug("region pool started", "tiles", len(tiles), "workers", workers)
+
+	pending := make(map[int]T)
+	next := 0
+	var err error
+	var panicked any
+	abort := func() {
+		close(stop)
+		for _, outcome := range pending {
+			discard(outcome)
+		}
+		pending = nil
+	}
+
+	for finished := range results {
+		switch {
+		case finished.panic != nil:
+			if panicked == nil && err == nil {
+				abort()
+			}
+			if panicked == nil {
+				panicked = finished.panic
+			}
+			continue
+		case panicked != nil || err != nil:
+			discard(finished.outcome)
+			continue
+		}
+
+		pending[finished.index] = finished.outcome
+		for {
+			outcome, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			merge(tiles[next], outcome)
+			next++
+		}
+
+		if err = progress.Steps(reporter, StageBinarize, next, len(tiles), message); err != nil {
+			abort()
+		}
+	}
+
+	if panicked != nil {
+		panic(panicked)
+	}
+	return err
+}
+
+// runRegionTile calls process on one tile, turning a panic into a value so
+// the pool can stop its workers first.
+func runRegionTile[T any](index int, bounds image.Rectangle, process func(image.Rectangle) T) (finished regionPoolResult[T]) {
+	finished.index = index
+	defer func() {
+		if r := recover(); r != nil {
+			finished.panic = r
+		}
+	}()
+	finished.outcome = process(bounds)
+	return finished
+}
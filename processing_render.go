@@ -0,0 +1,299 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	// Rendered images are scaled down to at most this many pixels a side.
+	renderMaxSide = 2400
+
+	renderPadding    = 8
+	renderLineHeight = 16
+	renderSwatchSize = 11
+)
+
+var (
+	renderInk   = color.RGBA{0x22, 0x22, 0x22, 0xff}
+	renderPaper = color.RGBA{0xff, 0xff, 0xff, 0xff}
+
+	// The error map follows the external comparison overlay: red for ink
+	// only the result has, blue for ink only the reference has.
+	renderFalseInk  = color.RGBA{0xe0, 0x20, 0x20, 0xff}
+	renderMissedInk = color.RGBA{0x20, 0x40, 0xe0, 0xff}
+)
+
+// RenderedVisual is one image of RenderVisuals, named for its file.
+type RenderedVisual struct {
+	Name  string
+	Image image.Image
+}
+
+type renderLegendEntry struct {
+	color color.Color
+	label string
+}
+
+// RenderVisuals composes the images the app shows for the latest run
+// without a window: the original and result side by side with the metrics,
+// the decision overlay with its legend, a heatmap of ink density and, when
+// groundTruth is not nil, the error map against it. The decision overlay is
+// left out for methods without a 2D threshold.
+func (pe *ProcessingEngine) RenderVisuals(groundTruth *ImageData) ([]RenderedVisual, error) {
+	original, processed := pe.originalImage, pe.processedImage
+	if original == nil || processed == nil {
+		return nil, fmt.Errorf("no result to render: process the image first")
+	}
+
+	var visuals []RenderedVisual
+	header := []string{"Method: " + pe.renderedMethod()}
+	if report := NewMetricsReport(pe.GetProcessedMetrics()); report != nil {
+		header = append(header, fmt.Sprintf("F-measure %.4f  pseudo F-measure %.4f  NRM %.4f  DRD %.4f  MPM %.4f",
+			report.FMeasure, report.PseudoFMeasure, report.NRM, report.DRD, report.MPM))
+	}
+	visuals = append(visuals, RenderedVisual{"comparison", renderComparison(original.Image, processed.Image, header)})
+
+	if decisions, err := pe.DecisionMap(); err != nil {
+		GetDebugSystem().logger.Debug("decision overlay not rendered", "error", err.Error())
+	} else {
+		total := float64(max(1, len(decisions.Decisions)))
+		var legend []renderLegendEntry
+		for kind, count := range decisions.Counts {
+			legend = append(legend, renderLegendEntry{PixelDecision(kind).Color(),
+				fmt.Sprintf("%s %.1f%%", PixelDecision(kind), 100*float64(count)/total)})
+		}
+		visuals = append(visuals, RenderedVisual{"decisions", renderAnnotated(decisions.Image(), []string{"Decision rules"}, legend)})
+	}
+
+	analysis, err := AnalyzeComponents(processed.Mat)
+	if err != nil {
+		return nil, fmt.Errorf("render density: %w", err)
+	}
+	visuals = append(visuals, RenderedVisual{"density", renderDensityHeatmap(processed.Image, analysis.InkDensity)})
+	analysis.Close()
+
+	if groundTruth != nil {
+		errorMap, err := pe.renderErrorMap(groundTruth)
+		if err != nil {
+			return nil, err
+		}
+		visuals = append(visuals, RenderedVisual{"errors", errorMap})
+	}
+	return visuals, nil
+}
+
+// WriteRenderedVisuals writes each visual as <stem>_<name>.png in dir.
+func WriteRenderedVisuals(dir, stem string, visuals []RenderedVisual) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("create render directory: %w", err)
+	}
+	for _, visual := range visuals {
+		path := filepath.Join(dir, stem+"_"+visual.Name+".png")
+		file, err := os.Create(path)
+		if err != nil {
+			return fmt.Errorf("create %s: %w", path, err)
+		}
+		if err := png.Encode(file, visual.Image); err != nil {
+			file.Close()
+			return fmt.Errorf("write %s: %w", path, err)
+		}
+		if err := file.Close(); err != nil {
+			return fmt.Errorf("close %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+func (pe *ProcessingEngine) renderedMethod() string {
+	if run := pe.currentRun(); run != nil {
+		return methodAlgorithm(run.Params)
+	}
+	return "unknown"
+}
+
+// renderErrorMap shades the result against groundTruth: agreement in black
+// and white, false ink in red and missed ink in blue.
+func (pe *ProcessingEngine) renderErrorMap(groundTruth *ImageData) (image.Image, error) {
+	result := pe.processedImage.Mat
+	truth, err := pe.referenceMask(groundTruth, result, false, "ground truth")
+	if err != nil {
+		return nil, err
+	}
+	defer truth.Close()
+
+	ours, theirs := result.ToBytes(), truth.ToBytes()
+	img := image.NewRGBA(image.Rect(0, 0, result.Cols(), result.Rows()))
+	falseInk, missedInk := 0, 0
+	for i, value := range ours {
+		shade := color.RGBA{value, value, value, 0xff}
+		switch {
+		case value == 0 && theirs[i] != 0:
+			shade = renderFalseInk
+			falseInk++
+		case value != 0 && theirs[i] == 0:
+			shade = renderMissedInk
+			missedInk++
+		}
+		img.SetRGBA(i%result.Cols(), i/result.Cols(), shade)
+	}
+
+	return renderAnnotated(img, []string{"Errors against ground truth"}, []renderLegendEntry{
+		{renderFalseInk, fmt.Sprintf("False ink %d px", falseInk)},
+		{renderMissedInk, fmt.Sprintf("Missed ink %d px", missedInk)},
+	}), nil
+}
+
+// renderDensityHeatmap tints each cell of the ink density grid over the
+// result, darker red for denser ink relative to the densest cell, and
+// prints the cell's percentage.
+func renderDensityHeatmap(result image.Image, density [ComponentDensityGrid][ComponentDensityGrid]float64) image.Image {
+	scaled := renderScaled(result, renderMaxSide)
+	bounds := scaled.Bounds()
+	img := image.NewRGBA(bounds)
+	draw.Draw(img, bounds, scaled, bounds.Min, draw.Src)
+
+	peak := 0.0
+	for _, row := range density {
+		for _, value := range row {
+			peak = math.Max(peak, value)
+		}
+	}
+
+	for gy, row := range density {
+		for gx, value := range row {
+			cell := densityGridRect(bounds.Dx(), bounds.Dy(), gx, gy)
+			alpha := uint8(0)
+			if peak > 0 {
+				alpha = uint8(160 * value / peak)
+			}
+			draw.Draw(img, cell, image.NewUniform(color.NRGBA{0xe0, 0x30, 0x20, alpha}), image.Point{}, draw.Over)
+			label := fmt.Sprintf("%.1f%%", value*100)
+			box := image.Rect(cell.Min.X+renderPadding/2, cell.Min.Y+renderPadding/2, cell.Min.X+renderPadding*3/2+textWidth(label), cell.Min.Y+renderPadding/2+renderLineHeight)
+			draw.Draw(img, box.Intersect(cell), image.NewUniform(renderPaper), image.Point{}, draw.Src)
+			renderText(img, cell.Min.X+renderPadding, cell.Min.Y+renderLineHeight, label, renderInk)
+		}
+	}
+	return renderAnnotated(img, []string{"Ink density by region"}, nil)
+}
+
+// renderComparison puts original and result side by side at the same
+// height under a header, together at most renderMaxSide pixels wide.
+func renderComparison(original, result image.Image, header []string) image.Image {
+	panels := []struct {
+		title string
+		image image.Image
+	}{{"Original", original}, {"Result", result}}
+
+	height, aspect := 0, 0.0
+	for _, panel := range panels {
+		bounds := panel.image.Bounds()
+		height = max(height, bounds.Dy())
+		aspect += float64(bounds.Dx()) / float64(max(1, bounds.Dy()))
+	}
+	height = max(1, min(height, int(float64(renderMaxSide)/aspect)))
+
+	scaled := make([]image.Image, len(panels))
+	width := renderPadding
+	for i, panel := range panels {
+		bounds := panel.image.Bounds()
+		panelWidth := max(1, bounds.Dx()*height/max(1, bounds.Dy()))
+		target := newImageLike(panel.image, image.Rect(0, 0, panelWidth, height))
+		xdraw.ApproxBiLinear.Scale(target, target.Bounds(), panel.image, bounds, xdraw.Src, nil)
+		scaled[i] = target
+		width += panelWidth + renderPadding
+	}
+
+	top := renderPadding + renderLineHeight*(len(header)+1)
+	img := image.NewRGBA(image.Rect(0, 0, width, top+height+renderPadding))
+	draw.Draw(img, img.Bounds(), image.NewUniform(renderPaper), image.Point{}, draw.Src)
+	for i, line := range header {
+		renderText(img, renderPadding, renderPadding+renderLineHeight*(i+1)-4, line, renderInk)
+	}
+
+	x := renderPadding
+	for i, panel := range panels {
+		renderText(img, x, top-4, panel.title, renderInk)
+		draw.Draw(img, scaled[i].Bounds().Add(image.Pt(x, top)), scaled[i], image.Point{}, draw.Src)
+		x += scaled[i].Bounds().Dx() + renderPadding
+	}
+	return img
+}
+
+// renderAnnotated frames content, scaled down to renderMaxSide, with
+// header lines above and a legend of color swatches below.
+func renderAnnotated(content image.Image, header []string, legend []renderLegendEntry) image.Image {
+	scaled := renderScaled(content, renderMaxSide)
+	bounds := scaled.Bounds()
+
+	top := renderPadding + renderLineHeight*len(header)
+	width := bounds.Dx() + 2*renderPadding
+
+	// Legend entries wrap into rows that fit the width.
+	var rows [][]renderLegendEntry
+	rowWidth := 0
+	for _, entry := range legend {
+		entryWidth := renderSwatchSize + renderPadding + textWidth(entry.label) + 2*renderPadding
+		if len(rows) == 0 || rowWidth+entryWidth > width-renderPadding {
+			rows = append(rows, nil)
+			rowWidth = renderPadding
+		}
+		rows[len(rows)-1] = append(rows[len(rows)-1], entry)
+		rowWidth += entryWidth
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, width, top+bounds.Dy()+renderPadding+renderLineHeight*len(rows)+renderPadding))
+	draw.Draw(img, img.Bounds(), image.NewUniform(renderPaper), image.Point{}, draw.Src)
+	for i, line := range header {
+		renderText(img, renderPadding, renderPadding+renderLineHeight*(i+1)-4, line, renderInk)
+	}
+	draw.Draw(img, bounds.Sub(bounds.Min).Add(image.Pt(renderPadding, top)), scaled, bounds.Min, draw.Src)
+
+	y := top + bounds.Dy() + renderPadding
+	for _, row := range rows {
+		x := renderPadding
+		for _, entry := range row {
+			swatch := image.Rect(x, y+2, x+renderSwatchSize, y+2+renderSwatchSize)
+			draw.Draw(img, swatch, image.NewUniform(renderInk), image.Point{}, draw.Src)
+			draw.Draw(img, swatch.Inset(1), image.NewUniform(entry.color), image.Point{}, draw.Src)
+			x += renderSwatchSize + renderPadding
+			renderText(img, x, y+renderLineHeight-4, entry.label, renderInk)
+			x += textWidth(entry.label) + 2*renderPadding
+		}
+		y += renderLineHeight
+	}
+	return img
+}
+
+// renderScaled returns img scaled down to at most side pixels a side.
+func renderScaled(img image.Image, side int) image.Image {
+	bounds := img.Bounds()
+	longest := max(bounds.Dx(), bounds.Dy())
+	if longest <= side {
+		return img
+	}
+	scaled := newImageLike(img, image.Rect(0, 0, max(1, bounds.Dx()*side/longest), max(1, bounds.Dy()*side/longest)))
+	xdraw.ApproxBiLinear.Scale(scaled, scaled.Bounds(), img, bounds, xdraw.Src, nil)
+	return scaled
+}
+
+func renderText(img draw.Image, x, y int, text string, ink color.Color) {
+	drawer := &font.Drawer{Dst: img, Src: image.NewUniform(ink), Face: basicfont.Face7x13, Dot: fixed.P(x, y)}
+	drawer.DrawString(strings.ReplaceAll(text, "→", "->"))
+}
+
+func textWidth(text string) int {
+	return font.MeasureString(basicfont.Face7x13, text).Round()
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"image"
+
+	"gocv.io/x/gocv"
+)
+
+// Binary results hold paper as 255 and ink as 0; the repair stages work on
+// the inverted ink mask so OpenCV treats strokes as the nonzero foreground.
+
+// hasStrokeRepair reports whether params enable hole filling or gap bridging.
+func hasStrokeRepair(params *OtsuParameters) bool {
+	return params.FillHoles || params.BridgeGaps
+}
+
+// applyStrokeRepair runs the hole filling and gap bridging options on a
+// binary result and returns a new Mat the caller closes.
+func (pe *ProcessingEngine) applyStrokeRepair(src gocv.Mat, params *OtsuParameters) gocv.Mat {
+	if err := validateMatForMetrics(src, "stroke repair input"); err != nil {
+		return gocv.NewMat()
+	}
+
+	ink := gocv.NewMat()
+	gocv.BitwiseNot(src, &ink)
+
+	if params.FillHoles {
+		fillSmallHoles(ink, params.MaxHoleArea)
+	}
+
+	if params.BridgeGaps {
+		bridged := bridgeStrokeGaps(ink, params.MaxGapSize)
+		ink.Close()
+		ink = bridged
+	}
+
+	result := gocv.NewMat()
+	gocv.BitwiseNot(ink, &result)
+	ink.Close()
+	return result
+}
+
+// fillSmallHoles turns paper regions fully enclosed by ink and no larger than
+// maxArea pixels into ink, in place. Regions touching the image border are
+// never enclosed and are left alone.
+func fillSmallHoles(ink gocv.Mat, maxArea int) {
+	paper := gocv.NewMat()
+	defer paper.Close()
+	gocv.BitwiseNot(ink, &paper)
+
+	labels := gocv.NewMat()
+	defer labels.Close()
+	stats := gocv.NewMat()
+	defer stats.Close()
+	centroids := gocv.NewMat()
+	defer centroids.Close()
+
+	count := gocv.ConnectedComponentsWithStats(paper, &labels, &stats, &centroids)
+	cols, rows := ink.Cols(), ink.Rows()
+
+	fill := make([]bool, count)
+	found := false
+	for label := 1; label < count; label++ {
+		left := int(stats.GetIntAt(label, int(gocv.CC_STAT_LEFT)))
+		top := int(stats.GetIntAt(label, int(gocv.CC_STAT_TOP)))
+		width := int(stats.GetIntAt(label, int(gocv.CC_STAT_WIDTH)))
+		height := int(stats.GetIntAt(label, int(gocv.CC_STAT_HEIGHT)))
+		area := int(stats.GetIntAt(label, int(gocv.CC_STAT_AREA)))
+
+		touchesBorder := left == 0 || top == 0 || left+width == cols || top+height == rows
+		if area <= maxArea && !touchesBorder {
+			fill[label] = true
+			found = true
+		}
+	}
+	if !found {
+		return
+	}
+
+	labelData, err := labelValues(labels)
+	if err != nil {
+		return
+	}
+	inkData, err := ink.DataPtrUint8()
+	if err != nil {
+		return
+	}
+
+	for i, label := range labelData {
+		if fill[label] {
+			inkData[i] = 255
+		}
+	}
+}
+
+// bridgeStrokeGaps closes breaks of up to maxGap pixels along the local
+// stroke direction. The direction comes from the structure tensor of the ink
+// mask, quantized to four orientations; each orientation's line closing only
+// adds pixels where the strokes run that way, so loops and counters are not
+// filled as a plain closing would.
+func bridgeStrokeGaps(ink gocv.Mat, maxGap int) gocv.Mat {
+	orientation := strokeOrientation(ink, float64(maxGap+2))
+	defer orientation.Close()
+
+	length := maxGap + 2
+	if length%2 == 0 {
+		length++
+	}
+
+	result := ink.Clone()
+
+	for bin, angle := range []float64{0, 45, 90, 135} {
+		kernel := lineKernel(length, bin)
+
+		closed := gocv.NewMat()
+		gocv.MorphologyEx(ink, &closed, gocv.MorphClose, kernel)
+		kernel.Close()
+
+		inBin := orientationBin(orientation, angle)
+
+		added := gocv.NewMat()
+		gocv.BitwiseAnd(closed, inBin, &added)
+		gocv.BitwiseOr(result, added, &result)
+
+		added.Close()
+		inBin.Close()
+		closed.Close()
+	}
+
+	return result
+}
+
+// orientationBin masks the pixels whose orientation lies within 22.5 degrees
+// of center. Orientations are directions, so center+180 matches as well.
+func orientationBin(orientation gocv.Mat, center float64) gocv.Mat {
+	inRange := func(middle float64) gocv.Mat {
+		mask := gocv.NewMat()
+		gocv.InRangeWithScalar(orientation,
+			gocv.NewScalar(middle-22.5, 0, 0, 0),
+			gocv.NewScalar(middle+22.5, 0, 0, 0),
+			&mask)
+		return mask
+	}
+
+	mask := inRange(center)
+	opposite := inRange(center + 180)
+	defer opposite.Close()
+	gocv.BitwiseOr(mask, opposite, &mask)
+	return mask
+}
+
+// strokeOrientation returns, per pixel, the direction strokes run in as
+// degrees in [90, 270), from the structure tensor smoothed over sigma. Image
+// rows grow downward, so 45 degrees runs toward the bottom right.
+func strokeOrientation(ink gocv.Mat, sigma float64) gocv.Mat {
+	source := gocv.NewMat()
+	defer source.Close()
+	ink.ConvertTo(&source, gocv.MatTypeCV32F)
+
+	gx := gocv.NewMat()
+	defer gx.Close()
+	gy := gocv.NewMat()
+	defer gy.Close()
+	gocv.Sobel(source, &gx, gocv.MatTypeCV32F, 1, 0, 3, 1, 0, gocv.BorderReplicate)
+	gocv.Sobel(source, &gy, gocv.MatTypeCV32F, 0, 1, 3, 1, 0, gocv.BorderReplicate)
+
+	tensor := func(a, b gocv.Mat) gocv.Mat {
+		product := gocv.NewMat()
+		gocv.Multiply(a, b, &product)
+		smoothed := gocv.NewMat()
+		gocv.GaussianBlur(product, &smoothed, image.Pt(0, 0), sigma, sigma, gocv.BorderReplicate)
+		product.Close()
+		return smoothed
+	}
+
+	jxx := tensor(gx, gx)
+	defer jxx.Close()
+	jyy := tensor(gy, gy)
+	defer jyy.Close()
+	jxy := tensor(gx, gy)
+	defer jxy.Close()
+
+	difference := gocv.NewMat()
+	defer difference.Close()
+	gocv.Subtract(jxx, jyy, &difference)
+
+	twice := gocv.NewMat()
+	defer twice.Close()
+	jxy.ConvertToWithParams(&twice, gocv.MatTypeCV32F, 2, 0)
+
+	// Phase gives twice the dominant gradient angle in [0, 360); halving it
+	// and turning by 90 degrees gives the stroke direction.
+	doubled := gocv.NewMat()
+	defer doubled.Close()
+	gocv.Phase(difference, twice, &doubled, true)
+
+	orientation := gocv.NewMat()
+	doubled.ConvertToWithParams(&orientation, gocv.MatTypeCV32F, 0.5, 90)
+	return orientation
+}
+
+// lineKernel returns a length x length structuring element holding a line
+// at bin * 45 degrees.
+func lineKernel(length, bin int) gocv.Mat {
+	kernel := gocv.Zeros(length, length, gocv.MatTypeCV8U)
+	center := length / 2
+
+	for i := 0; i < length; i++ {
+		switch bin {
+		case 0:
+			kernel.SetUCharAt(center, i, 1)
+		case 1:
+			kernel.SetUCharAt(i, i, 1)
+		case 2:
+			kernel.SetUCharAt(i, center, 1)
+		default:
+			kernel.SetUCharAt(length-1-i, i, 1)
+		}
+	}
+
+	return kernel
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"image"
+
+	"gocv.io/x/gocv"
+)
+
+const (
+	ShadowMethodGaussian      = "Gaussian"
+	ShadowMethodMorphological = "Morphological"
+
+	// The illumination surface is estimated on a copy this many times
+	// smaller than the page, since it only holds low frequencies.
+	shadowEstimateDownscale = 8
+
+	// Fractions of the longer page side used for the blur sigma and the
+	// closing kernel; both must comfortably exceed the stroke width.
+	shadowSigmaFraction  = 1.0 / 25
+	shadowKernelFraction = 1.0 / 20
+)
+
+// applyShadowRemoval flattens uneven lighting such as hand and phone shadows
+// by dividing the page by an estimate of its illumination surface. strength
+// blends between the input (0) and the fully flattened page (1).
+func (pe *ProcessingEngine) applyShadowRemoval(src gocv.Mat, method string, strength float64) gocv.Mat {
+	if err := validateMatForMetrics(src, "shadow removal input"); err != nil {
+		return gocv.NewMat()
+	}
+
+	background := pe.estimateIllumination(src, method)
+	defer background.Close()
+
+	// Both sides are offset by one so black illumination does not divide
+	// by zero; the quotient is scaled back to 8-bit, saturating at white.
+	source := gocv.NewMat()
+	defer source.Close()
+	src.ConvertToWithParams(&source, gocv.MatTypeCV32F, 1, 1)
+
+	illumination := gocv.NewMat()
+	defer illumination.Close()
+	background.ConvertToWithParams(&illumination, gocv.MatTypeCV32F, 1, 1)
+
+	ratio := gocv.NewMat()
+	defer ratio.Close()
+	gocv.Divide(source, illumination, &ratio)
+
+	flattened := gocv.NewMat()
+	defer flattened.Close()
+	ratio.ConvertToWithParams(&flattened, gocv.MatTypeCV8U, 255, 0)
+
+	dst := gocv.NewMat()
+	if strength >= 1 {
+		flattened.CopyTo(&dst)
+	} else {
+		gocv.AddWeighted(flattened, strength, src, 1-strength, 0, &dst)
+	}
+
+	if err := validateMatForMetrics(dst, "shadow removal output"); err != nil {
+		dst.Close()
+		return gocv.NewMat()
+	}
+
+	return dst
+}
+
+// estimateIllumination returns the paper brightness at every pixel. The
+// Gaussian estimate averages text into the background, which is fine for
+// sparse pages; closing first removes dark strokes and suits dense text.
+func (pe *ProcessingEngine) estimateIllumination(src gocv.Mat, method string) gocv.Mat {
+	cols, rows := src.Cols(), src.Rows()
+	smallSize := image.Pt(max(1, cols/shadowEstimateDownscale), max(1, rows/shadowEstimateDownscale))
+	longSide := float64(max(smallSize.X, smallSize.Y))
+
+	small := gocv.NewMat()
+	defer small.Close()
+	gocv.Resize(src, &small, smallSize, 0, 0, gocv.InterpolationArea)
+
+	estimate := gocv.NewMat()
+	defer estimate.Close()
+
+	if method == ShadowMethodMorphological {
+		kernelSize := max(3, int(longSide*shadowKernelFraction)|1)
+		kernel := gocv.GetStructuringElement(gocv.MorphEllipse, image.Pt(kernelSize, kernelSize))
+		defer kernel.Close()
+
+		closed := gocv.NewMat()
+		defer closed.Close()
+		gocv.MorphologyEx(small, &closed, gocv.MorphClose, kernel)
+		gocv.MedianBlur(closed, &estimate, 5)
+	} else {
+		sigma := max(1, int(longSide*shadowSigmaFraction))
+		gocv.GaussianBlur(small, &estimate, image.Pt(0, 0), float64(sigma), float64(sigma), gocv.BorderReplicate)
+	}
+
+	background := gocv.NewMat()
+	gocv.Resize(estimate, &background, image.Pt(cols, rows), 0, 0, gocv.InterpolationLinear)
+	return background
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gocv.io/x/gocv"
+)
+
+// ErrSpreadNotDetected is returned when an image does not look like a
+// two-page spread and no split line was placed by hand.
+var ErrSpreadNotDetected = errors.New("no book gutter found, place the split line by hand")
+
+// Spread split modes. Before binarization each page is thresholded on its
+// own; after, the result of the whole spread is cut in two.
+const (
+	SplitBefore = "before"
+	SplitAfter  = "after"
+)
+
+// The gutter is looked for in this central band of the spread.
+const (
+	gutterBandStart = 0.35
+	gutterBandEnd   = 0.65
+)
+
+func validateSplitMode(mode string) error {
+	switch mode {
+	case SplitBefore, SplitAfter:
+		return nil
+	}
+	return fmt.Errorf("unknown split mode %q: expected %s or %s", mode, SplitBefore, SplitAfter)
+}
+
+// DetectSpreadGutter looks for the gutter between the pages of the loaded
+// image.
+func (pe *ProcessingEngine) DetectSpreadGutter() (int, error) {
+	if pe.originalImage == nil {
+		return 0, fmt.Errorf("no original image loaded")
+	}
+
+	gray := pe.convertToGrayscale(pe.originalImage.Mat)
+	defer gray.Close()
+
+	return detectSpreadGutter(gray)
+}
+
+// detectSpreadGutter returns the column between the two pages of a
+// landscape spread from the vertical projection of its ink. gray may be a
+// binary result, which thresholds to itself.
+func detectSpreadGutter(gray gocv.Mat) (int, error) {
+	if gray.Cols() <= gray.Rows() {
+		return 0, ErrSpreadNotDetected
+	}
+
+	binary := gocv.NewMat()
+	defer binary.Close()
+	gocv.Threshold(gray, &binary, 0, 255, gocv.ThresholdBinary|gocv.ThresholdOtsu)
+
+	columns := gocv.NewMat()
+	defer columns.Close()
+	if err := gocv.Reduce(binary, &columns, 0, gocv.ReduceAvg, gocv.MatTypeCV32F); err != nil {
+		return 0, fmt.Errorf("spread projection: %w", err)
+	}
+
+	ink := make([]float64, columns.Cols())
+	for x := range ink {
+		ink[x] = 1 - float64(columns.GetFloatAt(0, x))/255
+	}
+
+	gutter, ok := findGutter(ink)
+	if !ok {
+		return 0, ErrSpreadNotDetected
+	}
+	GetDebugSystem().logger.Debug("spread gutter detected", "x", gutter, "width", gray.Cols())
+	return gutter, nil
+}
+
+// findGutter picks the widest run of quiet columns in the central band of
+// an ink projection, where quiet means nearly blank paper or the solid
+// shadow of the binding, and requires ink on both sides of it. It returns the middle of
+// the run.
+func findGutter(ink []float64) (int, bool) {
+	n := len(ink)
+	if n < 20 {
+		return 0, false
+	}
+
+	radius := max(1, n/400)
+	quiet := make([]float64, n)
+	var textColumns []float64
+	for x := range ink {
+		sum := 0.0
+		from, to := max(0, x-radius), min(n-1, x+radius)
+		for i := from; i <= to; i++ {
+			sum += ink[i]
+		}
+		if mean := sum / float64(to-from+1); mean < 0.8 {
+			quiet[x] = mean
+		}
+		if quiet[x] > 0.005 {
+			textColumns = append(textColumns, quiet[x])
+		}
+	}
+	if len(textColumns) == 0 {
+		return 0, false
+	}
+	sort.Float64s(textColumns)
+	limit := 0.2 * textColumns[len(textColumns)/2]
+
+	bandStart, bandEnd := int(gutterBandStart*float64(n)), int(gutterBandEnd*float64(n))
+	center := n / 2
+	bestStart, bestEnd := -1, -1
+	for x := bandStart; x < bandEnd; {
+		if quiet[x] > limit {
+			x++
+			continue
+		}
+		start := x
+		for x < bandEnd && quiet[x] <= limit {
+			x++
+		}
+		width, bestWidth := x-start, bestEnd-bestStart
+		if width > bestWidth || (width == bestWidth && absInt((start+x)/2-center) < absInt((bestStart+bestEnd)/2-center)) {
+			bestStart, bestEnd = start, x
+		}
+	}
+	if bestStart < 0 || bestEnd-bestStart < max(2, n/200) {
+		return 0, false
+	}
+
+	if !inkedPage(ink[:bestStart]) || !inkedPage(ink[bestEnd:]) {
+		return 0, false
+	}
+	return (bestStart + bestEnd) / 2, true
+}
+
+// inkedPage reports whether the columns of one side of a spread carry
+// text rather than only margin or shadow.
+func inkedPage(ink []float64) bool {
+	sum, count := 0.0, 0
+	for _, value := range ink {
+		if value < 0.8 {
+			sum += value
+			count++
+		}
+	}
+	return count > 0 && sum/float64(count) > 0.01
+}
+
+func absInt(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// SplitSpread cuts data at column x into the left and right pages, each
+// with the crop that locates it in the spread. The caller closes the Mats
+// of both pages.
+func SplitSpread(data *ImageData, x int) (left, right *ImageData, leftCrop, rightCrop *OutputCrop, err error) {
+	if x <= 0 || x >= data.Width {
+		return nil, nil, nil, nil, fmt.Errorf("split line %d is outside the image, 1 to %d", x, data.Width-1)
+	}
+
+	rects := [2]image.Rectangle{image.Rect(0, 0, x, data.Height), image.Rect(x, 0, data.Width, data.Height)}
+	var crops [2]*OutputCrop
+	for i, rect := range rects {
+		crops[i] = &OutputCrop{
+			X:               rect.Min.X,
+			Y:               rect.Min.Y,
+			Width:           rect.Dx(),
+			Height:          rect.Dy(),
+			UncroppedWidth:  data.Width,
+			UncroppedHeight: data.Height,
+		}
+	}
+	return cropImageData(data, rects[0]), cropImageData(data, rects[1]), crops[0], crops[1], nil
+}
+
+// SpreadPagePath names a page of a split spread after the output path,
+// as <name>_left.png and <name>_right.png.
+func SpreadPagePath(outputPath, side string) string {
+	extension := filepath.Ext(outputPath)
+	return strings.TrimSuffix(outputPath, extension) + "_" + side + extension
+}
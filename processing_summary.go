@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gocv.io/x/gocv"
+)
+
+// summarizeRun describes a run in a sentence a reviewer without knowledge
+// of the methods can follow, such as "Used 2D Otsu with 128 bins;
+// region-adaptive with 42 regions, 3 fell back to global; foreground 11.2%;
+// F-measure 0.93". It is stored with the latest run in the history; metrics
+// may be nil when they were deferred.
+func (pe *ProcessingEngine) summarizeRun(result gocv.Mat, params *OtsuParameters, metrics *BinaryImageMetrics) string {
+	pe.historyMu.Lock()
+	bins := [2]int{params.HistogramBins, params.NeighborhoodBins}
+	if pe.inspection != nil {
+		bins = pe.inspection.bins
+	}
+	audit := pe.regionAudit
+	photoZones := len(pe.photoZones)
+	strips := pe.strips
+	pe.historyMu.Unlock()
+
+	parts := []string{describeMethod(params, bins)}
+	if strips > 0 {
+		parts = append(parts, fmt.Sprintf("in %d strips", strips))
+	}
+	if audit != nil {
+		parts = append(parts, describeRegionAudit(audit))
+	}
+	if stages := describeStages(params); len(stages) > 0 {
+		parts = append(parts, "with "+joinWords(stages))
+	}
+	if photoZones > 0 {
+		parts = append(parts, fmt.Sprintf("%d photo zones", photoZones))
+	}
+	if !result.Empty() {
+		total := result.Rows() * result.Cols()
+		ink := total - gocv.CountNonZero(result)
+		parts = append(parts, fmt.Sprintf("foreground %.1f%%", 100*float64(ink)/float64(total)))
+	}
+	if metrics != nil {
+		parts = append(parts, fmt.Sprintf("F-measure %.2f", metrics.FMeasure()))
+	}
+	summary := strings.Join(parts, "; ")
+
+	pe.historyMu.Lock()
+	if len(pe.runHistory) > 0 {
+		latest := pe.runHistory[len(pe.runHistory)-1]
+		latest.Summary = summary
+		latest.metrics = metrics
+		latest.warnings = pe.qualityWarnings
+	}
+	pe.historyMu.Unlock()
+	return summary
+}
+
+// addMetricsToSummary completes the summary of the latest run with metrics
+// computed after it was written.
+func (pe *ProcessingEngine) addMetricsToSummary(metrics *BinaryImageMetrics) {
+	pe.historyMu.Lock()
+	defer pe.historyMu.Unlock()
+	if len(pe.runHistory) > 0 {
+		latest := pe.runHistory[len(pe.runHistory)-1]
+		latest.Summary += fmt.Sprintf("; F-measure %.2f", metrics.FMeasure())
+		latest.metrics = metrics
+	}
+}
+
+func describeMethod(params *OtsuParameters, bins [2]int) string {
+	histBins := strconv.Itoa(bins[0])
+	if bins[1] != bins[0] && bins[1] > 0 {
+		histBins = fmt.Sprintf("%dx%d", bins[0], bins[1])
+	}
+
+	switch {
+	case params.NeuralBinarization:
+		return fmt.Sprintf("Used the %s neural model", neuralModelName(params.NeuralModel))
+	case params.Otsu3D:
+		return fmt.Sprintf("Used 3D Otsu with %d bins", params.Otsu3DBins)
+	case params.EntropyMethod == EntropyMethodKapur:
+		return "Used Kapur maximum entropy"
+	case params.EntropyMethod == EntropyMethodTsallis:
+		return fmt.Sprintf("Used Tsallis maximum entropy with q %.2f", params.TsallisQ)
+	case params.MultiScaleProcessing:
+		return fmt.Sprintf("Used 2D Otsu with %s bins over a %d-level pyramid", histBins, params.PyramidLevels)
+	}
+	return fmt.Sprintf("Used 2D Otsu with %s bins", histBins)
+}
+
+func describeRegionAudit(audit *RegionAudit) string {
+	switch audit.Fallback {
+	case RegionFallbackSingleScale:
+		return "region-adaptive fell back to single scale, the grid being too large for the image"
+	case RegionFallbackGlobalOtsu:
+		return "region-adaptive fell back to global Otsu, its output being uniform"
+	}
+
+	fellBack, skipped := 0, 0
+	for _, region := range audit.Regions {
+		switch {
+		case region.FallbackLevel == RegionLevelGlobal:
+			fellBack++
+		case region.Decision == RegionLowContrast || region.Decision == RegionTooSmall:
+			skipped++
+		}
+	}
+	description := fmt.Sprintf("region-adaptive with %d regions, %d fell back to global", len(audit.Regions), fellBack)
+	if skipped > 0 {
+		description += fmt.Sprintf(", %d left as paper", skipped)
+	}
+	return description
+}
+
+// describeStages names the optional stages params turn on, in pipeline
+// order.
+func describeStages(params *OtsuParameters) []string {
+	var stages []string
+	if params.PerspectiveCorrection {
+		stages = append(stages, "perspective correction")
+	}
+	if params.CylindricalDewarp {
+		stages = append(stages, "dewarping")
+	}
+	if params.ShadowRemoval {
+		stages = append(stages, "shadow removal")
+	}
+	if params.HomomorphicFiltering {
+		stages = append(stages, "illumination correction")
+	}
+	if params.AnisotropicDiffusion {
+		stages = append(stages, "edge-preserving smoothing")
+	}
+	if params.MorphologicalPostProcess {
+		stages = append(stages, "speck cleanup")
+	}
+	if hasStrokeRepair(params) {
+		stages = append(stages, "stroke repair")
+	}
+	switch params.PhotoZones {
+	case PhotoZonesDither:
+		stages = append(stages, "dithered photos")
+	case PhotoZonesRemove:
+		stages = append(stages, "photos left blank")
+	}
+	return stages
+}
+
+// joinWords joins words as a list in prose: "a", "a and b", "a, b and c".
+func joinWords(words []string) string {
+	if len(words) <= 1 {
+		return strings.Join(words, "")
+	}
+	return strings.Join(words[:len(words)-1], ", ") + " and " + words[len(words)-1]
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"image"
+
+	"gocv.io/x/gocv"
+
+	"otsu-obliterator/progress"
+)
+
+const (
+	// Working images over tiledMinMegapixels are preprocessed and binarized
+	// in full-width strips of about tiledStripMegapixels, so the float
+	// buffers of the methods stay the size of a strip rather than of the
+	// page. Strips reach tiledMinOverlap rows, or two windows, into their
+	// neighbors and only their own rows are kept, so window-based features
+	// see the same neighborhood on either side of a seam.
+	tiledMinMegapixels   = 100.0
+	tiledStripMegapixels = 32.0
+	tiledMinOverlap      = 64
+)
+
+// stripBounds is one strip of a tiled run: outer is processed and the rows
+// of inner are kept.
+type stripBounds struct {
+	outer, inner image.Rectangle
+}
+
+// usesStrips reports whether an image of pixels is processed in strips.
+// The neural and region-adaptive methods tile the image on their own.
+func usesStrips(pixels float64, params *OtsuParameters) bool {
+	if params.NeuralBinarization || params.RegionAdaptiveThresholding {
+		return false
+	}
+	return pixels/1e6 > tiledMinMegapixels
+}
+
+func stripOverlap(params *OtsuParameters) int {
+	return max(tiledMinOverlap, 2*params.WindowSize)
+}
+
+// imageStrips cuts a rows by cols image into full-width strips of about
+// tiledStripMegapixels each, top to bottom.
+func imageStrips(rows, cols, overlap int) []stripBounds {
+	core := max(1, int(tiledStripMegapixels*1e6)/max(1, cols))
+	var strips []stripBounds
+	for y := 0; y < rows; y += core {
+		inner := image.Rect(0, y, cols, min(rows, y+core))
+		outer := image.Rect(0, max(0, inner.Min.Y-overlap), cols, min(rows, inner.Max.Y+overlap))
+		strips = append(strips, stripBounds{outer: outer, inner: inner})
+	}
+	return strips
+}
+
+// processStrips runs process on each strip of src in turn and stitches the
+// kept rows of the outputs into a new Mat the caller closes. process gets
+// its share of reporter and returns a Mat of its strip's size, which
+// processStrips closes.
+func processStrips(reporter progress.Reporter, src gocv.Mat, overlap int,
+	process func(reporter progress.Reporter, strip gocv.Mat) (gocv.Mat, error)) (gocv.Mat, error) {
+	strips := imageStrips(src.Rows(), src.Cols(), overlap)
+	stitched := gocv.NewMat()
+	fail := func(err error) (gocv.Mat, error) {
+		stitched.Close()
+		return gocv.NewMat(), err
+	}
+
+	for i, strip := range strips {
+		if err := reporter.Err(); err != nil {
+			return fail(err)
+		}
+
+		view := src.Region(strip.outer)
+		input := view.Clone()
+		view.Close()
+		output, err := process(progress.Span(reporter, float64(i)/float64(len(strips)), float64(i+1)/float64(len(strips))), input)
+		input.Close()
+		if err != nil {
+			output.Close()
+			return fail(err)
+		}
+		if output.Rows() != strip.outer.Dy() || output.Cols() != strip.outer.Dx() {
+			output.Close()
+			return fail(fmt.Errorf("strip %d of %d came out %dx%d, expected %dx%d",
+				i+1, len(strips), output.Cols(), output.Rows(), strip.outer.Dx(), strip.outer.Dy()))
+		}
+
+		if i == 0 {
+			stitched.Close()
+			stitched = gocv.NewMatWithSize(src.Rows(), src.Cols(), output.Type())
+		}
+		kept := output.Region(strip.inner.Sub(strip.outer.Min))
+		target := stitched.Region(strip.inner)
+		kept.CopyTo(&target)
+		target.Close()
+		kept.Close()
+		output.Close()
+	}
+
+	GetDebugSystem().logger.Debug("strips stitched",
+		"strips", len(strips),
+		"width", src.Cols(),
+		"height", src.Rows(),
+		"overlap", overlap,
+	)
+	return stitched, nil
+}
+
+// preprocessStrips runs the preprocessing chain strip by strip. It falls
+// back to the whole image if stitching fails, and returns an empty Mat once
+// reporter is cancelled.
+func (pe *ProcessingEngine) preprocessStrips(reporter progress.Reporter, gray gocv.Mat, params *OtsuParameters) gocv.Mat {
+	working, err := processStrips(reporter, gray, stripOverlap(params),
+		func(reporter progress.Reporter, strip gocv.Mat) (gocv.Mat, error) {
+			return pe.preprocessImage(reporter, strip, params), nil
+		})
+	if err != nil {
+		if reporter.Err() != nil {
+			return gocv.NewMat()
+		}
+		GetDebugSystem().logger.Warn("preprocessing in strips failed, preprocessing the whole image", "error", err.Error())
+		return pe.preprocessImage(reporter, gray, params)
+	}
+	return working
+}
+
+// binarizeStrips thresholds working strip by strip with the method params
+// select. Each strip chooses its own threshold, so the run has no single
+// threshold to inspect.
+func (pe *ProcessingEngine) binarizeStrips(reporter progress.Reporter, working gocv.Mat, params *OtsuParameters) (gocv.Mat, int, error) {
+	strips := len(imageStrips(working.Rows(), working.Cols(), stripOverlap(params)))
+	result, err := processStrips(reporter, working, stripOverlap(params),
+		func(reporter progress.Reporter, strip gocv.Mat) (gocv.Mat, error) {
+			if keepsLocalStatistics(params) {
+				pe.localStatistics(strip, true)
+			}
+			result, _, _, _, err := pe.binarizeMethod(reporter, strip, params)
+			return result, err
+		})
+	pe.releaseLocalStatistics()
+	return result, strips, err
+}
+
+func (pe *ProcessingEngine) setStrips(strips int) {
+	pe.historyMu.Lock()
+	defer pe.historyMu.Unlock()
+	pe.strips = strips
+}
@@ -3,13 +3,19 @@ package main
 import (
 	"context"
 	"fmt"
+	"runtime/debug"
+	"sync"
 	"time"
+
+	"otsu-obliterator/progress"
 )
 
 type TimeoutConfig struct {
 	SingleScale    time.Duration
 	MultiScale     time.Duration
 	RegionAdaptive time.Duration
+	Neural         time.Duration
+	Otsu3D         time.Duration
 	Preprocessing  time.Duration
 	Histogram      time.Duration
 }
@@ -18,6 +24,8 @@ var DefaultTimeouts = TimeoutConfig{
 	SingleScale:    30 * time.Second,
 	MultiScale:     120 * time.Second,
 	RegionAdaptive: 60 * time.Second,
+	Neural:         120 * time.Second,
+	Otsu3D:         60 * time.Second,
 	Preprocessing:  15 * time.Second,
 	Histogram:      10 * time.Second,
 }
@@ -32,30 +40,55 @@ func (te *TimeoutError) Error() string {
 	return fmt.Sprintf("%s operation timed out after %v in %s", te.Operation, te.Duration, te.Context)
 }
 
+// processingWorkers counts runs still working, including those whose caller
+// already gave up on them after a timeout or cancellation.
+var processingWorkers sync.WaitGroup
+
+// waitForProcessingWorkers waits up to timeout for every run to finish and
+// release its Mats, and reports whether they did.
+func waitForProcessingWorkers(timeout time.Duration) bool {
+	drained := make(chan struct{})
+	go func() {
+		processingWorkers.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
 type ProcessingResult struct {
 	Data    *ImageData
 	Metrics *BinaryImageMetrics
 	Error   error
 }
 
-func withProcessingTimeout(ctx context.Context, timeout time.Duration, operation string, fn func() (*ImageData, *BinaryImageMetrics, error)) (*ImageData, *BinaryImageMetrics, error) {
-	ctx, cancel := context.WithTimeout(ctx, timeout)
+// withProcessingTimeout runs fn with a reporter that is also cancelled after
+// timeout.
+func withProcessingTimeout(reporter progress.Reporter, timeout time.Duration, operation string, fn func(reporter progress.Reporter) (*ImageData, *BinaryImageMetrics, error)) (*ImageData, *BinaryImageMetrics, error) {
+	ctx, cancel := context.WithTimeout(reporter.Context(), timeout)
 	defer cancel()
 
 	done := make(chan ProcessingResult, 1)
 
+	processingWorkers.Add(1)
 	go func() {
+		defer processingWorkers.Done()
 		defer func() {
 			if r := recover(); r != nil {
 				done <- ProcessingResult{
 					Data:    nil,
 					Metrics: nil,
-					Error:   fmt.Errorf("operation panicked: %v", r),
+					Error:   &PanicError{Value: r, Stack: debug.Stack()},
 				}
 			}
 		}()
 
-		data, metrics, err := fn()
+		data, metrics, err := fn(progress.WithContext(reporter, ctx))
 		done <- ProcessingResult{
 			Data:    data,
 			Metrics: metrics,
@@ -76,22 +109,30 @@ func withProcessingTimeout(ctx context.Context, timeout time.Duration, operation
 }
 
 func (pe *ProcessingEngine) ProcessImageWithTimeout(ctx context.Context, params *OtsuParameters) (*ImageData, *BinaryImageMetrics, error) {
-	if pe.originalImage == nil {
+	return pe.ProcessImageWithProgress(progress.Discard(ctx), params)
+}
+
+// ProcessImageWithProgress processes the image under the method's time
+// limit, reporting each stage to reporter and stopping once it is
+// cancelled.
+func (pe *ProcessingEngine) ProcessImageWithProgress(reporter progress.Reporter, params *OtsuParameters) (*ImageData, *BinaryImageMetrics, error) {
+	original, _ := pe.imageForRun()
+	if original == nil {
 		return nil, nil, fmt.Errorf("no original image loaded")
 	}
 
-	if err := validateMatForMetrics(pe.originalImage.Mat, "original image"); err != nil {
+	if err := validateMatForMetrics(original.Mat, "original image"); err != nil {
 		return nil, nil, fmt.Errorf("image validation failed: %w", err)
 	}
 
-	imageSize := [2]int{pe.originalImage.Width, pe.originalImage.Height}
+	imageSize := [2]int{original.Width, original.Height}
 	if err := validateOtsuParameters(params, imageSize); err != nil {
 		return nil, nil, fmt.Errorf("parameter validation failed: %w", err)
 	}
 
-	timeout := pe.calculateTimeout(params)
+	timeout := calculateTimeout(original, params)
 
-	return withProcessingTimeout(ctx, timeout, "image processing", func() (*ImageData, *BinaryImageMetrics, error) {
-		return pe.processImageSafely(ctx, params)
+	return withProcessingTimeout(reporter, timeout, "image processing", func(reporter progress.Reporter) (*ImageData, *BinaryImageMetrics, error) {
+		return pe.processImageSafely(reporter, params)
 	})
 }
@@ -1,23 +1,28 @@
 package main
 
 import (
-	"context"
+	"errors"
 	"fmt"
 	"time"
 
-	"gocv.io/x/gocv"
+	"otsu-obliterator/progress"
 )
 
-func (pe *ProcessingEngine) calculateTimeout(params *OtsuParameters) time.Duration {
+func calculateTimeout(original *ImageData, params *OtsuParameters) time.Duration {
 	baseTimeout := DefaultTimeouts.SingleScale
 
-	if params.MultiScaleProcessing {
+	if params.NeuralBinarization {
+		baseTimeout = DefaultTimeouts.Neural
+		baseTimeout += time.Duration(original.Width*original.Height/1_000_000) * 5 * time.Second
+	} else if params.MultiScaleProcessing {
 		baseTimeout = DefaultTimeouts.MultiScale
 		baseTimeout += time.Duration(params.PyramidLevels) * 15 * time.Second
 	} else if params.RegionAdaptiveThresholding {
 		baseTimeout = DefaultTimeouts.RegionAdaptive
-		gridComplexity := (pe.originalImage.Width * pe.originalImage.Height) / (params.RegionGridSize * params.RegionGridSize)
+		gridComplexity := (original.Width * original.Height) / (params.RegionGridSize * params.RegionGridSize)
 		baseTimeout += time.Duration(gridComplexity/1000) * time.Second
+	} else if params.Otsu3D {
+		baseTimeout = DefaultTimeouts.Otsu3D
 	}
 
 	if params.HomomorphicFiltering {
@@ -30,67 +35,81 @@ func (pe *ProcessingEngine) calculateTimeout(params *OtsuParameters) time.Durati
 	return baseTimeout
 }
 
-func (pe *ProcessingEngine) processImageSafely(ctx context.Context, params *OtsuParameters) (*ImageData, *BinaryImageMetrics, error) {
-	if err := validateProcessingInputs(pe.originalImage, params); err != nil {
+// ErrImageChanged is returned by a run whose image, or the result it would
+// replace, was replaced while it ran; its result and metrics belong to the
+// previous state and are discarded.
+var ErrImageChanged = errors.New("the image changed during processing, result discarded")
+
+func (pe *ProcessingEngine) processImageSafely(reporter progress.Reporter, params *OtsuParameters) (*ImageData, *BinaryImageMetrics, error) {
+	original, generation := pe.imageForRun()
+	if err := validateProcessingInputs(original, params); err != nil {
 		return nil, nil, fmt.Errorf("input validation: %w", err)
 	}
 
-	gray := pe.convertToGrayscale(pe.originalImage.Mat)
+	stageProgress(reporter, StagePage)
+	gray, err := pe.pageGrayscale(original, params)
+	if err != nil {
+		return nil, nil, err
+	}
 	defer gray.Close()
 
-	working := gray.Clone()
-	defer working.Close()
-
-	if params.HomomorphicFiltering {
-		homomorphic := pe.applyHomomorphicFiltering(working)
-		working.Close()
-		working = homomorphic
+	if hasExternalStages(params, ExternalStagePre) {
+		staged, err := pe.applyExternalStages(reporter, gray, params, ExternalStagePre)
+		if err != nil {
+			return nil, nil, err
+		}
+		gray.Close()
+		gray = staged
+	}
+	if err := reporter.Err(); err != nil {
+		return nil, nil, err
 	}
 
-	if params.AnisotropicDiffusion {
-		diffused := pe.applyAnisotropicDiffusion(working, params.DiffusionIterations, params.DiffusionKappa)
-		working.Close()
-		working = diffused
+	working := pe.preprocess(stageProgress(reporter, StagePreprocess), gray, params)
+	defer working.Close()
+
+	if err := reporter.Err(); err != nil {
+		return nil, nil, err
 	}
 
-	if params.GaussianPreprocessing {
-		blurred := pe.applyGaussianBlur(working, params.SmoothingStrength)
-		working.Close()
-		working = blurred
+	result, err := pe.binarize(stageProgress(reporter, StageBinarize), working, params)
+	if err != nil {
+		return nil, nil, err
 	}
+	defer result.Close()
 
-	if params.ApplyContrastEnhancement {
-		enhanced := pe.applyAdaptiveContrastEnhancement(working)
-		working.Close()
-		working = enhanced
+	postReporter := stageProgress(reporter, StagePostprocess)
+	if params.MorphologicalPostProcess {
+		morphed := pe.applyMorphologicalPostProcessing(result, morphOperations(params))
+		result.Close()
+		result = morphed
 	}
 
-	select {
-	case <-ctx.Done():
-		return nil, nil, ctx.Err()
-	default:
+	if hasStrokeRepair(params) {
+		repaired := pe.applyStrokeRepair(result, params)
+		result.Close()
+		result = repaired
 	}
 
-	var result gocv.Mat
-	if params.MultiScaleProcessing {
-		result = pe.processMultiScale(working, params)
-	} else if params.RegionAdaptiveThresholding {
-		result = pe.processRegionAdaptive(working, params)
+	if hasPhotoZones(params) {
+		zoned := pe.applyPhotoZones(working, result, params)
+		result.Close()
+		result = zoned
 	} else {
-		result = pe.processSingleScale(working, params)
+		pe.setPhotoZones(nil)
 	}
-	defer result.Close()
 
-	if params.MorphologicalPostProcess {
-		morphed := pe.applyMorphologicalPostProcessing(result, params.MorphologicalKernelSize)
+	if hasExternalStages(params, ExternalStagePost) {
+		staged, err := pe.applyExternalStages(postReporter, result, params, ExternalStagePost)
+		if err != nil {
+			return nil, nil, err
+		}
 		result.Close()
-		result = morphed
+		result = staged
 	}
 
-	select {
-	case <-ctx.Done():
-		return nil, nil, ctx.Err()
-	default:
+	if err := reporter.Err(); err != nil {
+		return nil, nil, err
 	}
 
 	resultImage := pe.matToImage(result)
@@ -101,15 +120,52 @@ func (pe *ProcessingEngine) processImageSafely(ctx context.Context, params *Otsu
 		Width:    resultImage.Bounds().Dx(),
 		Height:   resultImage.Bounds().Dy(),
 		Channels: 1,
-		Format:   pe.originalImage.Format,
+		Format:   original.Format,
 	}
 
+	// The generation is compared and the result installed in one critical
+	// section, so an image loaded in between cannot receive it.
+	pe.metricsMu.Lock()
+	if pe.resultGeneration != generation {
+		pe.metricsMu.Unlock()
+		processedData.Mat.Close()
+		pe.discardStaleRun()
+		return nil, nil, ErrImageChanged
+	}
+	pe.releaseMetricsSource()
+	pe.resultGeneration++
+	generation = pe.resultGeneration
+	pe.processedMetrics = nil
 	pe.processedImage = processedData
-
-	metrics, err := CalculateBinaryMetrics(gray, result)
-	if err != nil {
-		return processedData, nil, fmt.Errorf("metrics calculation: %w", err)
+	pe.metricsMu.Unlock()
+	pe.resetTouchUp()
+	pe.recordRun(result, params)
+	pe.setQualityWarnings(assessQuality(working, result, params, pe.RegionAudit()))
+
+	stageProgress(reporter, StageMetrics)
+	var metrics *BinaryImageMetrics
+	pe.metricsMu.Lock()
+	if pe.resultGeneration != generation {
+		pe.metricsMu.Unlock()
+		GetDebugSystem().logger.Debug("metrics of a replaced result discarded",
+			"generation", generation)
+		return nil, nil, ErrImageChanged
 	}
+	reference, referenceKind := pe.metricsReference(gray, result)
+	if pe.DeferMetrics {
+		pe.deferMetrics(reference, referenceKind)
+	} else {
+		metrics, err = CalculateBinaryMetrics(reference, result)
+		if err != nil {
+			pe.metricsMu.Unlock()
+			return processedData, nil, fmt.Errorf("metrics calculation: %w", err)
+		}
+		metrics.Reference = referenceKind
+		pe.processedMetrics = metrics
+	}
+	pe.metricsMu.Unlock()
+	pe.summarizeRun(result, params, metrics)
+	reporter.Report(StageMetrics, 1, "")
 
 	if err := validateProcessingResult(processedData, metrics); err != nil {
 		return processedData, metrics, fmt.Errorf("result validation: %w", err)
@@ -117,3 +173,13 @@ func (pe *ProcessingEngine) processImageSafely(ctx context.Context, params *Otsu
 
 	return processedData, metrics, nil
 }
+
+// discardStaleRun forgets what a run of a replaced image recorded about it
+// while binarizing.
+func (pe *ProcessingEngine) discardStaleRun() {
+	pe.setInspection(nil)
+	pe.setRegionAudit(nil)
+	pe.setPhotoZones(nil)
+	pe.setStrips(0)
+	GetDebugSystem().logger.Debug("result of a replaced image discarded")
+}
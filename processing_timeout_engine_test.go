@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"otsu-obliterator/progress"
+)
+
+// testPageImage returns a small page with dark strokes on light paper.
+func testPageImage(t *testing.T) *ImageData {
+	t.Helper()
+	page := image.NewGray(image.Rect(0, 0, 96, 96))
+	for y := 0; y < 96; y++ {
+		for x := 0; x < 96; x++ {
+			value := uint8(220 - x/4)
+			if (x/8)%3 == 1 && (y/6)%2 == 1 {
+				value = 30
+			}
+			page.SetGray(x, y, color.Gray{Y: value})
+		}
+	}
+
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, page); err != nil {
+		t.Fatal(err)
+	}
+	data, err := DecodeImageData(encoded.Bytes(), ".png")
+	if err != nil {
+		t.Fatalf("DecodeImageData: %v", err)
+	}
+	return data
+}
+
+// processSwappingAt runs the engine on first and loads second when the run
+// reaches stage, as a user opening another file mid-run would.
+func processSwappingAt(t *testing.T, pe *ProcessingEngine, stage string) error {
+	t.Helper()
+	first, second := testPageImage(t), testPageImage(t)
+	t.Cleanup(func() { first.Mat.Close() })
+	pe.SetOriginalImage(first)
+
+	swapped := false
+	reporter := progress.New(context.Background(), func(update progress.Update) {
+		if update.Stage == stage && !swapped {
+			swapped = true
+			pe.SetOriginalImage(second)
+		}
+	})
+
+	_, _, err := pe.ProcessImageWithProgress(reporter, DefaultOtsuParameters())
+	if !swapped {
+		t.Fatalf("the run never reported stage %q", stage)
+	}
+	return err
+}
+
+func TestProcessImageKeepsResultOfUnchangedImage(t *testing.T) {
+	pe := NewProcessingEngine()
+	defer pe.Close()
+	pe.SetOriginalImage(testPageImage(t))
+
+	result, metrics, err := pe.ProcessImageWithProgress(progress.Discard(context.Background()), DefaultOtsuParameters())
+	if err != nil {
+		t.Fatalf("ProcessImageWithProgress: %v", err)
+	}
+	if result == nil || metrics == nil {
+		t.Fatalf("result %v, metrics %v; want both", result, metrics)
+	}
+	if pe.GetProcessedImage() != result || pe.GetProcessedMetrics() != metrics {
+		t.Error("the run's result and metrics were not installed")
+	}
+}
+
+func TestProcessImageDiscardsRunWhenImageReplacedBeforeInstall(t *testing.T) {
+	pe := NewProcessingEngine()
+	defer pe.Close()
+
+	err := processSwappingAt(t, pe, StageBinarize)
+	if !errors.Is(err, ErrImageChanged) {
+		t.Fatalf("error = %v, want ErrImageChanged", err)
+	}
+	if pe.GetProcessedImage() != nil {
+		t.Error("the result of the replaced image was installed")
+	}
+	if metrics := pe.GetProcessedMetrics(); metrics != nil {
+		t.Errorf("metrics of the replaced image were kept: %+v", metrics)
+	}
+	if runs := pe.RunHistory(); len(runs) != 0 {
+		t.Errorf("run history of the new image has %d runs of the old one", len(runs))
+	}
+}
+
+func TestProcessImageDropsMetricsWhenImageReplacedAfterInstall(t *testing.T) {
+	for _, deferred := range []bool{false, true} {
+		name := "computed"
+		if deferred {
+			name = "deferred"
+		}
+		t.Run(name, func(t *testing.T) {
+			pe := NewProcessingEngine()
+			defer pe.Close()
+			pe.DeferMetrics = deferred
+
+			err := processSwappingAt(t, pe, StageMetrics)
+			if !errors.Is(err, ErrImageChanged) {
+				t.Fatalf("error = %v, want ErrImageChanged", err)
+			}
+			if pe.MetricsDeferred() {
+				t.Error("metrics of the replaced result are still deferred")
+			}
+			if metrics := pe.GetProcessedMetrics(); metrics != nil {
+				t.Errorf("metrics of the replaced result were kept: %+v", metrics)
+			}
+		})
+	}
+}
+
+func TestDeferredMetricsDroppedWhenImageReplaced(t *testing.T) {
+	pe := NewProcessingEngine()
+	defer pe.Close()
+	pe.DeferMetrics = true
+
+	first := testPageImage(t)
+	defer first.Mat.Close()
+	pe.SetOriginalImage(first)
+
+	if _, _, err := pe.ProcessImageWithProgress(progress.Discard(context.Background()), DefaultOtsuParameters()); err != nil {
+		t.Fatalf("ProcessImageWithProgress: %v", err)
+	}
+	if !pe.MetricsDeferred() {
+		t.Fatal("metrics were not deferred")
+	}
+	generation := pe.ResultGeneration()
+
+	pe.SetOriginalImage(testPageImage(t))
+
+	if pe.ResultGeneration() == generation {
+		t.Error("loading an image kept the result generation")
+	}
+	if pe.MetricsDeferred() {
+		t.Error("deferred metrics of the previous image are still pending")
+	}
+	if metrics := pe.GetProcessedMetrics(); metrics != nil {
+		t.Errorf("GetProcessedMetrics returned metrics of the previous image: %+v", metrics)
+	}
+}
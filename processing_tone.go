@@ -0,0 +1,124 @@
+package main
+
+import (
+	"image"
+	"math"
+
+	"gocv.io/x/gocv"
+)
+
+// TonePreviewMaxWidth bounds the grayscale preview shown while tone controls
+// are dragged, keeping the update instant on large scans. It is the width of
+// the balanced preview quality; see PreviewSettings.
+const TonePreviewMaxWidth = 1600
+
+// hasToneAdjustment reports whether params change the grayscale tones at all,
+// so the identity case can skip the extra pass.
+func hasToneAdjustment(params *OtsuParameters) bool {
+	return params.EqualizeHistogram ||
+		params.Brightness != 0 ||
+		params.Contrast != 1.0 ||
+		params.Gamma != 1.0
+}
+
+// buildToneLUT maps each gray level through contrast around mid-gray, then
+// brightness, then gamma. Gamma above 1 brightens the midtones, which is what
+// under-exposed scans usually need.
+func buildToneLUT(brightness int, contrast, gamma float64) gocv.Mat {
+	lut := gocv.NewMatWithSize(1, 256, gocv.MatTypeCV8U)
+
+	if contrast <= 0 {
+		contrast = 1.0
+	}
+	if gamma <= 0 {
+		gamma = 1.0
+	}
+
+	for level := 0; level < 256; level++ {
+		value := contrast*(float64(level)-128) + 128 + float64(brightness)
+		value = math.Max(0, math.Min(255, value))
+		value = 255 * math.Pow(value/255, 1/gamma)
+		lut.SetUCharAt(0, level, uint8(math.Round(math.Max(0, math.Min(255, value)))))
+	}
+
+	return lut
+}
+
+// applyToneAdjustment applies the optional histogram equalization followed
+// by the brightness, contrast and gamma curve to a grayscale Mat.
+func (pe *ProcessingEngine) applyToneAdjustment(src gocv.Mat, params *OtsuParameters) gocv.Mat {
+	if err := validateMatForMetrics(src, "tone adjustment input"); err != nil {
+		return gocv.NewMat()
+	}
+
+	working := src
+	if params.EqualizeHistogram {
+		equalized := gocv.NewMat()
+		defer equalized.Close()
+		gocv.EqualizeHist(src, &equalized)
+		working = equalized
+	}
+
+	lut := buildToneLUT(params.Brightness, params.Contrast, params.Gamma)
+	defer lut.Close()
+
+	dst := gocv.NewMat()
+	gocv.LUT(working, lut, &dst)
+
+	if err := validateMatForMetrics(dst, "tone adjustment output"); err != nil {
+		dst.Close()
+		return gocv.NewMat()
+	}
+
+	return dst
+}
+
+// ToneAdjustedPreview renders the loaded image as grayscale with the tone
+// stage of params applied, downscaled to the ToneWidth of the preview
+// settings.
+func (pe *ProcessingEngine) ToneAdjustedPreview(params *OtsuParameters) image.Image {
+	if pe.originalImage == nil {
+		return nil
+	}
+
+	width := CurrentPreviewSettings().ToneWidth
+	if pe.tonePreviewBase != nil && (pe.tonePreviewChannel != params.GrayscaleChannel || pe.tonePreviewWidth != width) {
+		pe.releaseTonePreviewBase()
+	}
+	if pe.tonePreviewBase == nil {
+		base := pe.buildTonePreviewBase(params.GrayscaleChannel, width)
+		pe.tonePreviewBase = &base
+		pe.tonePreviewChannel = params.GrayscaleChannel
+		pe.tonePreviewWidth = width
+	}
+
+	toned := pe.applyToneAdjustment(*pe.tonePreviewBase, params)
+	defer toned.Close()
+	if toned.Empty() {
+		return nil
+	}
+
+	img := image.NewGray(image.Rect(0, 0, toned.Cols(), toned.Rows()))
+	copy(img.Pix, toned.ToBytes())
+	return img
+}
+
+func (pe *ProcessingEngine) buildTonePreviewBase(channel string, width int) gocv.Mat {
+	gray := pe.extractGrayChannel(pe.originalImage.Mat, channel)
+	if gray.Cols() <= width {
+		return gray
+	}
+	defer gray.Close()
+
+	scaled := gocv.NewMat()
+	height := max(1, gray.Rows()*width/gray.Cols())
+	gocv.Resize(gray, &scaled, image.Pt(width, height), 0, 0, gocv.InterpolationArea)
+	return scaled
+}
+
+func (pe *ProcessingEngine) releaseTonePreviewBase() {
+	if pe.tonePreviewBase != nil {
+		pe.tonePreviewBase.Close()
+		pe.tonePreviewBase = nil
+	}
+}
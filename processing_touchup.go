@@ -0,0 +1,234 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+
+	"gocv.io/x/gocv"
+)
+
+// maxTouchUpUndo bounds the strokes kept for undo; each holds a copy of the
+// result and of the touch-up mask.
+const maxTouchUpUndo = 20
+
+var (
+	touchUpInk   = color.RGBA{R: 0, G: 0, B: 0, A: 255}
+	touchUpPaper = color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	touchUpMark  = color.RGBA{R: 255, G: 255, B: 255, A: 255}
+)
+
+// TouchUpRecord flags the pixels of a result painted by hand, in working
+// image pixels, so a saved output can be told apart from a pure run.
+type TouchUpRecord struct {
+	Pixels  int             `json:"pixels"`
+	Regions []TouchUpRegion `json:"regions"`
+}
+
+// TouchUpRegion is the bounding box of one connected touched-up area.
+type TouchUpRegion struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+type touchUpSnapshot struct {
+	result gocv.Mat
+	mask   gocv.Mat
+}
+
+// BeginTouchUp saves the current result for undo. Call it once before each
+// brush stroke.
+func (pe *ProcessingEngine) BeginTouchUp() error {
+	if pe.processedImage == nil {
+		return fmt.Errorf("no processed image to touch up")
+	}
+
+	if pe.touchUpMask == nil {
+		mask := gocv.Zeros(pe.processedImage.Mat.Rows(), pe.processedImage.Mat.Cols(), gocv.MatTypeCV8U)
+		pe.touchUpMask = &mask
+	}
+
+	pe.touchUpUndo = append(pe.touchUpUndo, touchUpSnapshot{
+		result: pe.processedImage.Mat.Clone(),
+		mask:   pe.touchUpMask.Clone(),
+	})
+	if len(pe.touchUpUndo) > maxTouchUpUndo {
+		pe.touchUpUndo[0].result.Close()
+		pe.touchUpUndo[0].mask.Close()
+		pe.touchUpUndo = pe.touchUpUndo[1:]
+	}
+	return nil
+}
+
+// PaintTouchUp draws a brush segment of the given radius from one point to
+// another on the result, as ink or as paper, and marks it in the touch-up
+// mask. The displayed image is updated in place.
+func (pe *ProcessingEngine) PaintTouchUp(from, to image.Point, radius int, ink bool) {
+	if pe.processedImage == nil || pe.touchUpMask == nil {
+		return
+	}
+
+	brush := touchUpPaper
+	if ink {
+		brush = touchUpInk
+	}
+
+	if from == to {
+		gocv.Circle(&pe.processedImage.Mat, to, radius, brush, -1)
+		gocv.Circle(pe.touchUpMask, to, radius, touchUpMark, -1)
+	} else {
+		gocv.Line(&pe.processedImage.Mat, from, to, brush, 2*radius+1)
+		gocv.Line(pe.touchUpMask, from, to, touchUpMark, 2*radius+1)
+	}
+
+	stroke := image.Rect(from.X, from.Y, to.X, to.Y).Canon().Inset(-radius - 1)
+	pe.syncProcessedImage(stroke)
+}
+
+// FillTouchUp sets every pixel under mask to ink or paper as one undoable
+// touch-up step.
+func (pe *ProcessingEngine) FillTouchUp(mask gocv.Mat, ink bool) error {
+	if err := pe.BeginTouchUp(); err != nil {
+		return err
+	}
+
+	value := 255.0
+	if ink {
+		value = 0
+	}
+
+	result := &pe.processedImage.Mat
+	fill := gocv.NewMatWithSizeFromScalar(gocv.NewScalar(value, 0, 0, 0), result.Rows(), result.Cols(), gocv.MatTypeCV8U)
+	defer fill.Close()
+	fill.CopyToWithMask(result, mask)
+
+	marked := gocv.NewMatWithSizeFromScalar(gocv.NewScalar(255, 0, 0, 0), result.Rows(), result.Cols(), gocv.MatTypeCV8U)
+	defer marked.Close()
+	marked.CopyToWithMask(pe.touchUpMask, mask)
+
+	pe.processedImage.Image = pe.matToImage(*result)
+	return nil
+}
+
+// UndoTouchUp restores the result as it was before the last stroke and
+// reports whether there was one.
+func (pe *ProcessingEngine) UndoTouchUp() bool {
+	if pe.processedImage == nil || len(pe.touchUpUndo) == 0 {
+		return false
+	}
+
+	last := pe.touchUpUndo[len(pe.touchUpUndo)-1]
+	pe.touchUpUndo = pe.touchUpUndo[:len(pe.touchUpUndo)-1]
+
+	pe.processedImage.Mat.Close()
+	pe.processedImage.Mat = last.result
+	pe.touchUpMask.Close()
+	*pe.touchUpMask = last.mask
+
+	pe.processedImage.Image = pe.matToImage(pe.processedImage.Mat)
+	return true
+}
+
+func (pe *ProcessingEngine) CanUndoTouchUp() bool {
+	return len(pe.touchUpUndo) > 0
+}
+
+// TouchUpRecord describes the touched-up pixels of the current result, or
+// nil when it has not been touched up.
+func (pe *ProcessingEngine) TouchUpRecord() *TouchUpRecord {
+	if pe.touchUpMask == nil {
+		return nil
+	}
+
+	pixels := gocv.CountNonZero(*pe.touchUpMask)
+	if pixels == 0 {
+		return nil
+	}
+
+	labels := gocv.NewMat()
+	defer labels.Close()
+	stats := gocv.NewMat()
+	defer stats.Close()
+	centroids := gocv.NewMat()
+	defer centroids.Close()
+	count := gocv.ConnectedComponentsWithStats(*pe.touchUpMask, &labels, &stats, &centroids)
+
+	record := &TouchUpRecord{Pixels: pixels}
+	for label := 1; label < count; label++ {
+		record.Regions = append(record.Regions, TouchUpRegion{
+			X:      int(stats.GetIntAt(label, int(gocv.CC_STAT_LEFT))),
+			Y:      int(stats.GetIntAt(label, int(gocv.CC_STAT_TOP))),
+			Width:  int(stats.GetIntAt(label, int(gocv.CC_STAT_WIDTH))),
+			Height: int(stats.GetIntAt(label, int(gocv.CC_STAT_HEIGHT))),
+		})
+	}
+	return record
+}
+
+// OutputSidecar returns the sidecar to write next to the saved result: how
+// it maps to its source, its touch-ups and notes, and the parameters and
+// metrics of the run shown. It is nil before an image is loaded.
+func (pe *ProcessingEngine) OutputSidecar(sourcePath string) *OutputSidecar {
+	sidecar := NewOutputSidecar(sourcePath, pe.originalImage)
+	if pe.originalImage == nil {
+		return sidecar
+	}
+
+	if sidecar == nil {
+		sidecar = &OutputSidecar{
+			Source:        sourcePath,
+			SourceWidth:   pe.originalImage.Width,
+			SourceHeight:  pe.originalImage.Height,
+			WorkingWidth:  pe.originalImage.Width,
+			WorkingHeight: pe.originalImage.Height,
+			ScaleFactor:   1,
+			AppVersion:    AppVersion,
+		}
+	}
+	sidecar.TouchUp = pe.TouchUpRecord()
+	sidecar.Notes = pe.Annotations()
+
+	// Metrics come first: computing deferred ones completes the summary.
+	sidecar.Metrics = NewMetricsReport(pe.GetProcessedMetrics())
+	pe.historyMu.Lock()
+	if shown := pe.shownRunEntry(); shown != nil {
+		sidecar.Parameters = shown.Params
+		sidecar.Summary = shown.Summary
+	}
+	pe.historyMu.Unlock()
+	return sidecar
+}
+
+// syncProcessedImage copies rect of the processed Mat into the displayed
+// image, which matToImage created as *image.Gray.
+func (pe *ProcessingEngine) syncProcessedImage(rect image.Rectangle) {
+	gray, ok := pe.processedImage.Image.(*image.Gray)
+	if !ok {
+		pe.processedImage.Image = pe.matToImage(pe.processedImage.Mat)
+		return
+	}
+
+	rect = rect.Intersect(gray.Bounds())
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			gray.SetGray(x, y, color.Gray{Y: pe.processedImage.Mat.GetUCharAt(y, x)})
+		}
+	}
+}
+
+// resetTouchUp drops the touch-up mask and undo history when the result
+// they belong to is replaced.
+func (pe *ProcessingEngine) resetTouchUp() {
+	for _, snapshot := range pe.touchUpUndo {
+		snapshot.result.Close()
+		snapshot.mask.Close()
+	}
+	pe.touchUpUndo = nil
+
+	if pe.touchUpMask != nil {
+		pe.touchUpMask.Close()
+		pe.touchUpMask = nil
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+
+	"gocv.io/x/gocv"
+)
+
+// TraceOutlines returns the boundaries of the ink of binary, where ink is 0
+// and paper 255, and of the holes in the ink. Boundaries are simplified to
+// within options.Tolerance pixels, and those enclosing less than
+// options.MinArea pixels are dropped as speckles.
+func TraceOutlines(binary gocv.Mat, options TraceOptions) (*VectorOutlines, error) {
+	if err := validateMatForMetrics(binary, "vector tracing"); err != nil {
+		return nil, err
+	}
+
+	ink := gocv.NewMat()
+	defer ink.Close()
+	gocv.BitwiseNot(binary, &ink)
+
+	hierarchy := gocv.NewMat()
+	defer hierarchy.Close()
+	contours := gocv.FindContoursWithParams(ink, &hierarchy, gocv.RetrievalCComp, gocv.ChainApproxSimple)
+	defer contours.Close()
+
+	outlines := &VectorOutlines{
+		Width:      binary.Cols(),
+		Height:     binary.Rows(),
+		Smoothness: options.Smoothness,
+	}
+	for i := 0; i < contours.Size(); i++ {
+		contour := contours.At(i)
+		if gocv.ContourArea(contour) < options.MinArea {
+			continue
+		}
+
+		points := contour.ToPoints()
+		if options.Tolerance > 0 {
+			simplified := gocv.ApproxPolyDP(contour, options.Tolerance, true)
+			points = simplified.ToPoints()
+			simplified.Close()
+		}
+		if len(points) < 3 {
+			continue
+		}
+		outlines.Paths = append(outlines.Paths, points)
+	}
+
+	GetDebugSystem().logger.Debug("vector tracing complete",
+		"contours", contours.Size(),
+		"paths", len(outlines.Paths),
+		"tolerance", options.Tolerance,
+		"smoothness", options.Smoothness,
+	)
+	if len(outlines.Paths) == 0 {
+		return nil, fmt.Errorf("vector tracing: no ink outlines above %.0f pixels", options.MinArea)
+	}
+	return outlines, nil
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"image"
+	"sort"
+
+	"gocv.io/x/gocv"
+)
+
+// Photo zone modes say what becomes of the photographs and halftone
+// pictures of a page: thresholded like the text, dithered so their tones
+// survive in black and white, or left blank.
+const (
+	PhotoZonesBinarize = "Binarize"
+	PhotoZonesDither   = "Dither"
+	PhotoZonesRemove   = "Remove"
+)
+
+var PhotoZoneModes = []string{PhotoZonesBinarize, PhotoZonesDither, PhotoZonesRemove}
+
+const (
+	// Pages are classified in square cells of 1/photoZoneCellsAcross of
+	// the short side, at least photoZoneMinCell pixels.
+	photoZoneCellsAcross = 48
+	photoZoneMinCell     = 12
+
+	// photoZoneBlur merges halftone dots into the tone they print.
+	photoZoneBlur = 5
+
+	// A cell is pictorial when photoZoneMidtoneShare of its pixels lie in
+	// the middle half between the ink and paper levels on a gentle slope,
+	// or when ink covers photoZoneDarkShare of it, more than text ever
+	// does. The midtones of text sit on the steep edges of strokes, where
+	// the gray level changes by more than 1/photoZoneSteepDivisor of the
+	// ink to paper range per pixel.
+	photoZoneMidtoneShare = 0.3
+	photoZoneDarkShare    = 0.6
+	photoZoneSteepDivisor = 12
+
+	// A zone spans at least photoZoneMinCells cells each way and its
+	// pictorial cells fill photoZoneMinFill of its box, so headings and
+	// rules stay text.
+	photoZoneMinCells = 3
+	photoZoneMinFill  = 0.5
+)
+
+func hasPhotoZones(params *OtsuParameters) bool {
+	return params.PhotoZones != "" && params.PhotoZones != PhotoZonesBinarize
+}
+
+// applyPhotoZones finds the photo zones of working, the binarization input,
+// and composes them into result as params.PhotoZones says, keeping the text
+// zones of result. It returns a new Mat the caller closes.
+func (pe *ProcessingEngine) applyPhotoZones(working, result gocv.Mat, params *OtsuParameters) gocv.Mat {
+	debugSystem := GetDebugSystem()
+	if working.Channels() != 1 || working.Rows() != result.Rows() || working.Cols() != result.Cols() {
+		debugSystem.logger.Warn("photo zones skipped, the binarization input does not match the result")
+		pe.setPhotoZones(nil)
+		return result.Clone()
+	}
+
+	blurred := gocv.NewMat()
+	defer blurred.Close()
+	gocv.Blur(working, &blurred, image.Pt(photoZoneBlur, photoZoneBlur))
+
+	width, height := working.Cols(), working.Rows()
+	zones := detectPhotoZones(blurred.ToBytes(), width, height)
+	pe.setPhotoZones(zones)
+
+	pixels := result.ToBytes()
+	source := working.ToBytes()
+	for _, zone := range zones {
+		switch params.PhotoZones {
+		case PhotoZonesDither:
+			ditherZone(source, pixels, width, zone)
+		case PhotoZonesRemove:
+			for y := zone.Min.Y; y < zone.Max.Y; y++ {
+				row := pixels[y*width+zone.Min.X : y*width+zone.Max.X]
+				for i := range row {
+					row[i] = 255
+				}
+			}
+		}
+	}
+
+	composed, err := gocv.NewMatFromBytes(height, width, gocv.MatTypeCV8UC1, pixels)
+	if err != nil {
+		debugSystem.logger.Warn("photo zones skipped", "error", err.Error())
+		return result.Clone()
+	}
+	debugSystem.logger.Debug("photo zones composed", "mode", params.PhotoZones, "zones", len(zones))
+	return composed
+}
+
+// detectPhotoZones returns the boxes of the photographs and halftone
+// pictures of a grayscale page, top to bottom. pixels should be blurred so
+// halftone dots read as the tone they print.
+func detectPhotoZones(pixels []byte, width, height int) []image.Rectangle {
+	if width <= 0 || height <= 0 || len(pixels) < width*height {
+		return nil
+	}
+
+	var histogram [256]int
+	for _, value := range pixels[:width*height] {
+		histogram[value]++
+	}
+	ink := histogramPercentile(histogram, width*height, 0.02)
+	paper := histogramPercentile(histogram, width*height, 0.95)
+	if paper-ink < 32 {
+		return nil
+	}
+	darkEnd, lightStart := ink+(paper-ink)/4, paper-(paper-ink)/4
+
+	cell := max(photoZoneMinCell, min(width, height)/photoZoneCellsAcross)
+	cols, rows := (width+cell-1)/cell, (height+cell-1)/cell
+	midtones := make([]int, cols*rows)
+	dark := make([]int, cols*rows)
+	steep := max(1, (paper-ink)/photoZoneSteepDivisor)
+	for y := range height {
+		row := pixels[y*width : (y+1)*width]
+		above := pixels[max(0, y-1)*width:]
+		below := pixels[min(height-1, y+1)*width:]
+		base := (y / cell) * cols
+		for x, value := range row {
+			switch v := int(value); {
+			case v <= darkEnd:
+				dark[base+x/cell]++
+			case v < lightStart:
+				left, right := row[max(0, x-1)], row[min(width-1, x+1)]
+				if absInt(int(right)-int(left)) < 2*steep && absInt(int(below[x])-int(above[x])) < 2*steep {
+					midtones[base+x/cell]++
+				}
+			}
+		}
+	}
+
+	pictorial := make([]bool, cols*rows)
+	for i := range pictorial {
+		cx, cy := i%cols, i/cols
+		area := float64((min(width, (cx+1)*cell) - cx*cell) * (min(height, (cy+1)*cell) - cy*cell))
+		pictorial[i] = float64(midtones[i]) >= photoZoneMidtoneShare*area ||
+			float64(dark[i]) >= photoZoneDarkShare*area
+	}
+	pictorial = fillPhotoCellHoles(pictorial, cols, rows)
+
+	var zones []image.Rectangle
+	seen := make([]bool, len(pictorial))
+	for start := range pictorial {
+		if !pictorial[start] || seen[start] {
+			continue
+		}
+		bounds := image.Rect(start%cols, start/cols, start%cols+1, start/cols+1)
+		count := 0
+		stack := []int{start}
+		seen[start] = true
+		for len(stack) > 0 {
+			i := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			count++
+			cx, cy := i%cols, i/cols
+			bounds = bounds.Union(image.Rect(cx, cy, cx+1, cy+1))
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					nx, ny := cx+dx, cy+dy
+					if nx < 0 || ny < 0 || nx >= cols || ny >= rows {
+						continue
+					}
+					if n := ny*cols + nx; pictorial[n] && !seen[n] {
+						seen[n] = true
+						stack = append(stack, n)
+					}
+				}
+			}
+		}
+		if bounds.Dx() < photoZoneMinCells || bounds.Dy() < photoZoneMinCells ||
+			float64(count) < photoZoneMinFill*float64(bounds.Dx()*bounds.Dy()) {
+			continue
+		}
+		zone := image.Rect(bounds.Min.X*cell, bounds.Min.Y*cell,
+			bounds.Max.X*cell, bounds.Max.Y*cell).Intersect(image.Rect(0, 0, width, height))
+		if zone = trimPaperMargins(pixels, width, zone, lightStart); !zone.Empty() {
+			zones = append(zones, zone)
+		}
+	}
+
+	zones = mergeOverlappingZones(zones)
+	sort.Slice(zones, func(i, j int) bool {
+		if zones[i].Min.Y != zones[j].Min.Y {
+			return zones[i].Min.Y < zones[j].Min.Y
+		}
+		return zones[i].Min.X < zones[j].Min.X
+	})
+	return zones
+}
+
+// trimPaperMargins shrinks zone to the rows and columns holding a pixel
+// darker than paper, so a zone ends at its picture rather than at the cell
+// grid.
+func trimPaperMargins(pixels []byte, width int, zone image.Rectangle, paper int) image.Rectangle {
+	trimmed := image.Rectangle{}
+	for y := zone.Min.Y; y < zone.Max.Y; y++ {
+		for x := zone.Min.X; x < zone.Max.X; x++ {
+			if int(pixels[y*width+x]) < paper {
+				trimmed = trimmed.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	return trimmed
+}
+
+// histogramPercentile is the smallest gray level at or below which share
+// of the total pixels lie.
+func histogramPercentile(histogram [256]int, total int, share float64) int {
+	target := int(share * float64(total))
+	seen := 0
+	for level, count := range histogram {
+		seen += count
+		if seen > target {
+			return level
+		}
+	}
+	return 255
+}
+
+// fillPhotoCellHoles marks cells with at least five pictorial neighbors as
+// pictorial, closing the gaps flat areas of a photo such as sky leave.
+func fillPhotoCellHoles(pictorial []bool, cols, rows int) []bool {
+	filled := make([]bool, len(pictorial))
+	copy(filled, pictorial)
+	for i, isPictorial := range pictorial {
+		if isPictorial {
+			continue
+		}
+		cx, cy := i%cols, i/cols
+		neighbors := 0
+		for dy := -1; dy <= 1; dy++ {
+			for dx := -1; dx <= 1; dx++ {
+				nx, ny := cx+dx, cy+dy
+				if (dx != 0 || dy != 0) && nx >= 0 && ny >= 0 && nx < cols && ny < rows && pictorial[ny*cols+nx] {
+					neighbors++
+				}
+			}
+		}
+		filled[i] = neighbors >= 5
+	}
+	return filled
+}
+
+// mergeOverlappingZones joins overlapping boxes until none overlap.
+func mergeOverlappingZones(zones []image.Rectangle) []image.Rectangle {
+	for merged := true; merged; {
+		merged = false
+		for i := 0; i < len(zones) && !merged; i++ {
+			for j := i + 1; j < len(zones); j++ {
+				if zones[i].Overlaps(zones[j]) {
+					zones[i] = zones[i].Union(zones[j])
+					zones = append(zones[:j], zones[j+1:]...)
+					merged = true
+					break
+				}
+			}
+		}
+	}
+	return zones
+}
+
+// ditherZone writes zone of source, a grayscale page width pixels wide, to
+// target in black and white with serpentine Floyd-Steinberg error
+// diffusion.
+func ditherZone(source, target []byte, width int, zone image.Rectangle) {
+	w := zone.Dx()
+	current := make([]float32, w+2)
+	next := make([]float32, w+2)
+	for y := zone.Min.Y; y < zone.Max.Y; y++ {
+		direction := 1
+		if (y-zone.Min.Y)%2 == 1 {
+			direction = -1
+		}
+		for i := range w {
+			x := i
+			if direction < 0 {
+				x = w - 1 - i
+			}
+			offset := y*width + zone.Min.X + x
+			value := float32(source[offset]) + current[x+1]
+			var out float32
+			if value >= 128 {
+				out = 255
+			}
+			target[offset] = byte(out)
+
+			diffused := value - out
+			current[x+1+direction] += diffused * 7 / 16
+			next[x+1-direction] += diffused * 3 / 16
+			next[x+1] += diffused * 5 / 16
+			next[x+1+direction] += diffused / 16
+		}
+		current, next = next, current
+		clear(next)
+	}
+}
+
+// setPhotoZones keeps the photo zones of the latest run; runs without
+// them clear it.
+func (pe *ProcessingEngine) setPhotoZones(zones []image.Rectangle) {
+	pe.historyMu.Lock()
+	defer pe.historyMu.Unlock()
+	pe.photoZones = zones
+}
+
+// PhotoZones returns the photo zones of the latest run in working image
+// pixels, nil when it kept every zone binarized.
+func (pe *ProcessingEngine) PhotoZones() []image.Rectangle {
+	pe.historyMu.Lock()
+	defer pe.historyMu.Unlock()
+	return pe.photoZones
+}
@@ -0,0 +1,159 @@
+// Package progress carries progress reports and cancellation through long
+// running work, from the processing pipeline down to the loops of each
+// method.
+package progress
+
+import (
+	"context"
+	"math"
+	"sync"
+)
+
+// Reporter receives the progress of work and tells the work when to stop.
+// Implementations are safe for concurrent use.
+type Reporter interface {
+	// Report records that stage is fraction done, from 0 to 1. message is
+	// optional detail such as the tile being processed.
+	Report(stage string, fraction float64, message string)
+
+	// Err is non-nil once the work is cancelled. Work checks it between
+	// steps and returns it.
+	Err() error
+
+	// Context carries the cancellation to calls that take a context, such
+	// as external commands.
+	Context() context.Context
+}
+
+// Update is one report, with Overall the fraction of the whole job.
+type Update struct {
+	Stage    string
+	Fraction float64
+	Overall  float64
+	Message  string
+}
+
+// updater is implemented by the reporters of this package, so spans keep
+// the stage fraction apart from the overall one.
+type updater interface {
+	update(u Update)
+}
+
+type reporter struct {
+	ctx      context.Context
+	mu       sync.Mutex
+	onUpdate func(Update)
+}
+
+// New returns a Reporter cancelled with ctx that passes each report to
+// onUpdate. onUpdate may be nil and is called on the reporting goroutine,
+// one call at a time.
+func New(ctx context.Context, onUpdate func(Update)) Reporter {
+	return &reporter{ctx: ctx, onUpdate: onUpdate}
+}
+
+// Discard returns a Reporter that only carries the cancellation of ctx.
+func Discard(ctx context.Context) Reporter {
+	return New(ctx, nil)
+}
+
+func (r *reporter) Report(stage string, fraction float64, message string) {
+	fraction = clamp(fraction)
+	r.update(Update{Stage: stage, Fraction: fraction, Overall: fraction, Message: message})
+}
+
+func (r *reporter) update(u Update) {
+	if r.onUpdate == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.onUpdate(u)
+}
+
+func (r *reporter) Err() error {
+	return r.ctx.Err()
+}
+
+func (r *reporter) Context() context.Context {
+	return r.ctx
+}
+
+// span maps the fractions of a part of a job onto its share of the whole.
+type span struct {
+	parent   Reporter
+	from, to float64
+}
+
+// Span returns a Reporter for a part of the job covering from to to of the
+// parent's progress, so nested work reports without knowing its share.
+func Span(parent Reporter, from, to float64) Reporter {
+	return &span{parent: parent, from: clamp(from), to: clamp(to)}
+}
+
+func (s *span) Report(stage string, fraction float64, message string) {
+	fraction = clamp(fraction)
+	s.update(Update{Stage: stage, Fraction: fraction, Overall: fraction, Message: message})
+}
+
+func (s *span) update(u Update) {
+	u.Overall = s.from + u.Overall*(s.to-s.from)
+	if parent, ok := s.parent.(updater); ok {
+		parent.update(u)
+		return
+	}
+	s.parent.Report(u.Stage, u.Overall, u.Message)
+}
+
+func (s *span) Err() error {
+	return s.parent.Err()
+}
+
+func (s *span) Context() context.Context {
+	return s.parent.Context()
+}
+
+// scoped reports through its parent but is cancelled with its own context.
+type scoped struct {
+	Reporter
+	ctx context.Context
+}
+
+// WithContext returns a Reporter that reports to parent and is cancelled
+// with ctx, which should derive from the parent's context, e.g. to add a
+// deadline.
+func WithContext(parent Reporter, ctx context.Context) Reporter {
+	return &scoped{Reporter: parent, ctx: ctx}
+}
+
+func (s *scoped) update(u Update) {
+	if parent, ok := s.Reporter.(updater); ok {
+		parent.update(u)
+		return
+	}
+	s.Reporter.Report(u.Stage, u.Overall, u.Message)
+}
+
+func (s *scoped) Err() error {
+	return s.ctx.Err()
+}
+
+func (s *scoped) Context() context.Context {
+	return s.ctx
+}
+
+// Steps reports step of total done in stage and returns the cancellation
+// error, for loops that check once per step.
+func Steps(r Reporter, stage string, step, total int, message string) error {
+	if total > 0 {
+		r.Report(stage, float64(step)/float64(total), message)
+	}
+	return r.Err()
+}
+
+func clamp(fraction float64) float64 {
+	if math.IsNaN(fraction) {
+		return 0
+	}
+	return math.Min(1, math.Max(0, fraction))
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	// gpLengthScale is the RBF kernel length in unit cube coordinates; a
+	// quarter of a range is about the distance over which the score of a
+	// binarization parameter changes noticeably.
+	gpLengthScale = 0.25
+	// gpNoise is the observation variance on standardized scores. It keeps
+	// the kernel matrix positive definite when snapped integer parameters
+	// repeat a point.
+	gpNoise = 1e-3
+	// eiExploration is the improvement, in standard deviations, a candidate
+	// must promise before expected improvement credits it.
+	eiExploration = 0.01
+)
+
+// gaussianProcess is a zero-mean Gaussian process regression over the unit
+// cube with an RBF kernel, fitted to standardized scores.
+type gaussianProcess struct {
+	points []tuningPoint
+	alpha  []float64
+	chol   [][]float64
+	mean   float64
+	scale  float64
+}
+
+func fitGaussianProcess(points []tuningPoint, scores []float64) (*gaussianProcess, error) {
+	n := len(points)
+	if n == 0 {
+		return nil, fmt.Errorf("gaussian process: no observations")
+	}
+
+	gp := &gaussianProcess{points: points}
+	for _, score := range scores {
+		gp.mean += score
+	}
+	gp.mean /= float64(n)
+	for _, score := range scores {
+		gp.scale += (score - gp.mean) * (score - gp.mean)
+	}
+	gp.scale = math.Sqrt(gp.scale / float64(n))
+	if gp.scale == 0 {
+		gp.scale = 1
+	}
+
+	kernel := make([][]float64, n)
+	for i := range kernel {
+		kernel[i] = make([]float64, n)
+		for j := range kernel[i] {
+			kernel[i][j] = rbfKernel(points[i], points[j])
+		}
+		kernel[i][i] += gpNoise
+	}
+
+	chol, err := cholesky(kernel)
+	if err != nil {
+		return nil, err
+	}
+	gp.chol = chol
+
+	standardized := make([]float64, n)
+	for i, score := range scores {
+		standardized[i] = (score - gp.mean) / gp.scale
+	}
+	gp.alpha = choleskySolve(chol, standardized)
+	return gp, nil
+}
+
+// predict returns the posterior mean and standard deviation at x, in the
+// units of the fitted scores.
+func (gp *gaussianProcess) predict(x tuningPoint) (float64, float64) {
+	k := make([]float64, len(gp.points))
+	mean := 0.0
+	for i, point := range gp.points {
+		k[i] = rbfKernel(x, point)
+		mean += k[i] * gp.alpha[i]
+	}
+
+	v := forwardSubstitute(gp.chol, k)
+	variance := 1.0
+	for _, value := range v {
+		variance -= value * value
+	}
+	variance = math.Max(variance, 1e-12)
+
+	return gp.mean + mean*gp.scale, math.Sqrt(variance) * gp.scale
+}
+
+// expectedImprovement of x over the best score observed so far, assuming
+// higher scores are better.
+func (gp *gaussianProcess) expectedImprovement(x tuningPoint, best float64) float64 {
+	mean, stdDev := gp.predict(x)
+	improvement := mean - best - eiExploration*gp.scale
+	z := improvement / stdDev
+	return improvement*normalCDF(z) + stdDev*math.Exp(-z*z/2)/math.Sqrt(2*math.Pi)
+}
+
+func rbfKernel(a, b tuningPoint) float64 {
+	distance := 0.0
+	for i := range a {
+		d := a[i] - b[i]
+		distance += d * d
+	}
+	return math.Exp(-distance / (2 * gpLengthScale * gpLengthScale))
+}
+
+// cholesky returns the lower triangular L with L·Lᵀ = matrix.
+func cholesky(matrix [][]float64) ([][]float64, error) {
+	n := len(matrix)
+	lower := make([][]float64, n)
+	for i := range lower {
+		lower[i] = make([]float64, n)
+		for j := 0; j <= i; j++ {
+			sum := matrix[i][j]
+			for k := 0; k < j; k++ {
+				sum -= lower[i][k] * lower[j][k]
+			}
+			if i == j {
+				if sum <= 0 {
+					return nil, fmt.Errorf("gaussian process: kernel matrix is not positive definite")
+				}
+				lower[i][i] = math.Sqrt(sum)
+			} else {
+				lower[i][j] = sum / lower[j][j]
+			}
+		}
+	}
+	return lower, nil
+}
+
+// forwardSubstitute solves L·x = b.
+func forwardSubstitute(lower [][]float64, b []float64) []float64 {
+	x := make([]float64, len(b))
+	for i := range b {
+		sum := b[i]
+		for k := 0; k < i; k++ {
+			sum -= lower[i][k] * x[k]
+		}
+		x[i] = sum / lower[i][i]
+	}
+	return x
+}
+
+// choleskySolve solves L·Lᵀ·x = b.
+func choleskySolve(lower [][]float64, b []float64) []float64 {
+	y := forwardSubstitute(lower, b)
+	n := len(y)
+	x := make([]float64, n)
+	for i := n - 1; i >= 0; i-- {
+		sum := y[i]
+		for k := i + 1; k < n; k++ {
+			sum -= lower[k][i] * x[k]
+		}
+		x[i] = sum / lower[i][i]
+	}
+	return x
+}
+
+func normalCDF(z float64) float64 {
+	return 0.5 * math.Erfc(-z/math.Sqrt2)
+}
@@ -0,0 +1,524 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+const (
+	TuningStrategyGrid    = "grid"
+	TuningStrategyHalving = "halving"
+	TuningStrategyBayes   = "bayes"
+)
+
+// TuningStrategies lists the search strategies in the order shown to users.
+var TuningStrategies = []string{TuningStrategyHalving, TuningStrategyBayes, TuningStrategyGrid}
+
+// halvingScales are the downscale rungs of successive halving; each rung
+// keeps the best third of the configurations for the next.
+var halvingScales = []float64{0.25, 0.5, 1}
+
+const (
+	halvingKeepFraction = 3
+	// bayesFinalists are re-scored at full resolution after the Gaussian
+	// process search on downscaled images.
+	bayesFinalists = 3
+	// bayesCandidates is the number of points expected improvement is
+	// evaluated on per step; a quarter are perturbations of the incumbent.
+	bayesCandidates = 2000
+)
+
+// TuningConfig describes a parameter search over a set of image and ground
+// truth pairs. Params from the processing config is the starting point and
+// supplies every parameter that is not tuned.
+type TuningConfig struct {
+	ProcessingConfig
+	Pairs      []BenchmarkPair
+	Dimensions []TuningDimension
+	Strategy   string
+	Metric     benchmarkMetric
+
+	// Trials is the number of configurations sampled: the first rung of
+	// successive halving, the Gaussian process evaluations, or the upper
+	// bound on grid points.
+	Trials int
+	// Scale is the downscale factor of the Bayesian search.
+	Scale float64
+	Seed  uint64
+	Jobs  int
+}
+
+// TuningTrial is one configuration scored at one scale. Score is the mean
+// metric over the pairs; failed pairs are counted but left out.
+type TuningTrial struct {
+	Values     map[string]float64 `json:"values"`
+	Scale      float64            `json:"scale"`
+	Score      float64            `json:"score"`
+	Failures   int                `json:"failures,omitempty"`
+	DurationMS int64              `json:"duration_ms"`
+}
+
+type TuningReport struct {
+	CreatedAt     time.Time          `json:"created_at"`
+	Strategy      string             `json:"strategy"`
+	Metric        string             `json:"metric"`
+	Seed          uint64             `json:"seed"`
+	Dimensions    []string           `json:"dimensions"`
+	Pairs         []BenchmarkPair    `json:"pairs"`
+	Trials        []TuningTrial      `json:"trials"`
+	BaselineScore float64            `json:"baseline_score"`
+	BestScore     float64            `json:"best_score"`
+	Best          map[string]float64 `json:"best"`
+	Params        *OtsuParameters    `json:"params"`
+	DurationMS    int64              `json:"duration_ms"`
+}
+
+// tuningSample is one pair prepared at one scale: an engine holding the
+// downscaled image and the ground truth mask of the same size.
+type tuningSample struct {
+	engine *ProcessingEngine
+	mask   gocv.Mat
+}
+
+// tuner runs a search. Encoded images and full resolution ground truth are
+// read once; the samples of each scale are decoded on first use.
+type tuner struct {
+	config  *TuningConfig
+	rng     *rand.Rand
+	onTrial func(trial TuningTrial)
+
+	images  [][]byte
+	truths  []*ImageData
+	samples map[float64][]*tuningSample
+	scores  map[string]float64
+	report  *TuningReport
+}
+
+// runTuning searches config.Dimensions for the parameters that score best
+// on config.Metric. onTrial, when set, is called after every evaluation.
+func runTuning(ctx context.Context, config *TuningConfig, onTrial func(trial TuningTrial)) (*TuningReport, error) {
+	startTime := time.Now()
+	t := &tuner{
+		config:  config,
+		rng:     rand.New(rand.NewPCG(config.Seed, config.Seed^0x9e3779b97f4a7c15)),
+		onTrial: onTrial,
+		samples: make(map[float64][]*tuningSample),
+		scores:  make(map[string]float64),
+		report: &TuningReport{
+			CreatedAt:  time.Now().UTC(),
+			Strategy:   config.Strategy,
+			Metric:     config.Metric.Name,
+			Seed:       config.Seed,
+			Dimensions: tuningDimensionNames(config.Dimensions),
+			Pairs:      config.Pairs,
+		},
+	}
+	defer t.close()
+
+	if err := t.load(); err != nil {
+		return nil, err
+	}
+
+	baseline := baselinePoint(config.Params, config.Dimensions)
+	baselineScore, err := t.evaluate(ctx, baseline, 1)
+	if err != nil {
+		return nil, err
+	}
+	t.report.BaselineScore = t.natural(baselineScore)
+
+	var finalists []tuningPoint
+	switch config.Strategy {
+	case TuningStrategyGrid:
+		finalists, err = t.grid(ctx)
+	case TuningStrategyHalving:
+		finalists, err = t.halving(ctx, baseline)
+	case TuningStrategyBayes:
+		finalists, err = t.bayes(ctx, baseline)
+	default:
+		err = fmt.Errorf("unknown tuning strategy %q", config.Strategy)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Every finalist has a full resolution score; the baseline competes so
+	// tuning never reports parameters worse than the ones it started from.
+	best, bestScore := baseline, baselineScore
+	for _, point := range finalists {
+		score, err := t.evaluate(ctx, point, 1)
+		if err != nil {
+			return nil, err
+		}
+		if score > bestScore {
+			best, bestScore = point, score
+		}
+	}
+
+	t.report.BestScore = t.natural(bestScore)
+	t.report.Best = tuningValues(config.Dimensions, best)
+	t.report.Params, _ = applyTuningPoint(config.Params, config.Dimensions, best)
+	t.report.DurationMS = time.Since(startTime).Milliseconds()
+	return t.report, nil
+}
+
+func (t *tuner) load() error {
+	for _, pair := range t.config.Pairs {
+		data, err := os.ReadFile(pair.Image)
+		if err != nil {
+			return fmt.Errorf("read %s: %w", pair.Image, err)
+		}
+		t.images = append(t.images, data)
+
+		truth, err := LoadImageFile(pair.GroundTruth, 0)
+		if err != nil {
+			return fmt.Errorf("load ground truth %s: %w", pair.GroundTruth, err)
+		}
+		t.truths = append(t.truths, truth)
+	}
+	return nil
+}
+
+func (t *tuner) close() {
+	for _, samples := range t.samples {
+		for _, sample := range samples {
+			sample.engine.Close()
+			sample.mask.Close()
+		}
+	}
+	for _, truth := range t.truths {
+		truth.Mat.Close()
+	}
+}
+
+// samplesAt decodes every pair at scale times its working scale.
+func (t *tuner) samplesAt(scale float64) ([]*tuningSample, error) {
+	if samples, ok := t.samples[scale]; ok {
+		return samples, nil
+	}
+
+	var samples []*tuningSample
+	fail := func(err error) ([]*tuningSample, error) {
+		for _, sample := range samples {
+			sample.engine.Close()
+			sample.mask.Close()
+		}
+		return nil, err
+	}
+	for i, pair := range t.config.Pairs {
+		header, err := InspectImageData(t.images[i])
+		if err != nil {
+			return fail(fmt.Errorf("%s: %w", pair.Image, err))
+		}
+		working := WorkingScale(header.Width, header.Height, t.config.MaxMegapixels)
+		imageData, err := DecodeImageDataScaled(t.images[i], strings.ToLower(filepath.Ext(pair.Image)), scale*working)
+		if err != nil {
+			return fail(fmt.Errorf("decode %s at scale %g: %w", pair.Image, scale, err))
+		}
+
+		engine := NewProcessingEngine()
+		engine.SetOriginalImage(imageData)
+		mask, err := engine.referenceMask(t.truths[i], imageData.Mat, false, "ground truth of "+pair.Image)
+		if err != nil {
+			engine.Close()
+			return fail(err)
+		}
+		samples = append(samples, &tuningSample{engine: engine, mask: mask})
+	}
+
+	t.samples[scale] = samples
+	return samples, nil
+}
+
+// evaluate scores point at scale, higher is better whatever the metric.
+// Scores are cached, so integer dimensions that snap two points together
+// cost one evaluation.
+func (t *tuner) evaluate(ctx context.Context, point tuningPoint, scale float64) (float64, error) {
+	params, snapped := applyTuningPoint(t.config.Params, t.config.Dimensions, point)
+	key := strconv.FormatFloat(scale, 'g', -1, 64)
+	for _, value := range snapped {
+		key += "," + strconv.FormatFloat(value, 'g', 8, 64)
+	}
+	if score, ok := t.scores[key]; ok {
+		return score, nil
+	}
+
+	samples, err := t.samplesAt(scale)
+	if err != nil {
+		return 0, err
+	}
+
+	startTime := time.Now()
+	values := make([]float64, len(samples))
+	failed := make([]bool, len(samples))
+	jobs := workerLimit(t.config.Jobs)
+	semaphore := make(chan struct{}, jobs)
+	var wg sync.WaitGroup
+	for i, sample := range samples {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+			value, err := t.score(ctx, sample, params)
+			if err != nil {
+				failed[i] = true
+				GetDebugSystem().logger.Debug("tuning run failed",
+					"image", t.config.Pairs[i].Image, "scale", scale, "error", err.Error())
+				return
+			}
+			values[i] = value
+		}()
+	}
+	wg.Wait()
+	if ctx.Err() != nil {
+		return 0, ctx.Err()
+	}
+
+	trial := TuningTrial{
+		Values:     tuningValues(t.config.Dimensions, snapped),
+		Scale:      scale,
+		DurationMS: time.Since(startTime).Milliseconds(),
+	}
+	sum, scored := 0.0, 0
+	for i, value := range values {
+		if failed[i] {
+			trial.Failures++
+			continue
+		}
+		sum += value
+		scored++
+	}
+
+	// A configuration that fails on some images must not win on the rest.
+	score := math.Inf(-1)
+	if trial.Failures == 0 {
+		score = sum / float64(scored)
+		if !t.config.Metric.HigherBetter {
+			score = -score
+		}
+	}
+	if scored > 0 {
+		trial.Score = sum / float64(scored)
+	}
+
+	t.scores[key] = score
+	t.report.Trials = append(t.report.Trials, trial)
+	if t.onTrial != nil {
+		t.onTrial(trial)
+	}
+	return score, nil
+}
+
+func (t *tuner) score(ctx context.Context, sample *tuningSample, params *OtsuParameters) (float64, error) {
+	if t.config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.config.Timeout)
+		defer cancel()
+	}
+
+	processed, _, err := sample.engine.ProcessImageWithTimeout(ctx, params)
+	if err != nil {
+		return 0, err
+	}
+	defer processed.Mat.Close()
+
+	metrics, err := CalculateBinaryMetrics(sample.mask, processed.Mat)
+	if err != nil {
+		return 0, fmt.Errorf("metrics against ground truth: %w", err)
+	}
+	return t.config.Metric.Value(NewMetricsReport(metrics)), nil
+}
+
+// natural converts an internal score back to the metric's own sign.
+func (t *tuner) natural(score float64) float64 {
+	if t.config.Metric.HigherBetter {
+		return score
+	}
+	return -score
+}
+
+func (t *tuner) randomPoint() tuningPoint {
+	point := make(tuningPoint, len(t.config.Dimensions))
+	for i := range point {
+		point[i] = t.rng.Float64()
+	}
+	return point
+}
+
+// grid evaluates an even grid at full resolution with as many levels per
+// dimension as Trials allows, at least two.
+func (t *tuner) grid(ctx context.Context) ([]tuningPoint, error) {
+	dimensions := len(t.config.Dimensions)
+	levels := max(2, int(math.Floor(math.Pow(float64(t.config.Trials), 1/float64(dimensions))+1e-9)))
+
+	best, bestScore := tuningPoint(nil), math.Inf(-1)
+	index := make([]int, dimensions)
+	for {
+		point := make(tuningPoint, dimensions)
+		for i, level := range index {
+			point[i] = float64(level) / float64(levels-1)
+		}
+		score, err := t.evaluate(ctx, point, 1)
+		if err != nil {
+			return nil, err
+		}
+		if score > bestScore {
+			best, bestScore = point, score
+		}
+
+		i := 0
+		for ; i < dimensions; i++ {
+			index[i]++
+			if index[i] < levels {
+				break
+			}
+			index[i] = 0
+		}
+		if i == dimensions {
+			break
+		}
+	}
+	return []tuningPoint{best}, nil
+}
+
+// halving scores Trials random configurations on the smallest rung and
+// promotes the best third to each larger one.
+func (t *tuner) halving(ctx context.Context, baseline tuningPoint) ([]tuningPoint, error) {
+	points := []tuningPoint{baseline}
+	for len(points) < t.config.Trials {
+		points = append(points, t.randomPoint())
+	}
+
+	for rung, scale := range halvingScales {
+		scores := make([]float64, len(points))
+		for i, point := range points {
+			score, err := t.evaluate(ctx, point, scale)
+			if err != nil {
+				return nil, err
+			}
+			scores[i] = score
+		}
+		if rung == len(halvingScales)-1 {
+			break
+		}
+		points = bestPoints(points, scores, (len(points)+halvingKeepFraction-1)/halvingKeepFraction)
+	}
+	return points, nil
+}
+
+// bayes fits a Gaussian process to the scores seen so far and evaluates the
+// point of largest expected improvement, on images downscaled by Scale.
+// The best few are then scored at full resolution.
+func (t *tuner) bayes(ctx context.Context, baseline tuningPoint) ([]tuningPoint, error) {
+	var points []tuningPoint
+	var scores []float64
+	observe := func(point tuningPoint) error {
+		score, err := t.evaluate(ctx, point, t.config.Scale)
+		if err != nil {
+			return err
+		}
+		_, snapped := applyTuningPoint(t.config.Params, t.config.Dimensions, point)
+		if math.IsInf(score, -1) {
+			// Failures would wreck the standardization; record them just
+			// below the worst score instead.
+			score = math.Inf(1)
+			for _, s := range scores {
+				score = math.Min(score, s)
+			}
+			if math.IsInf(score, 1) {
+				score = 0
+			}
+			score -= 1
+		}
+		points = append(points, snapped)
+		scores = append(scores, score)
+		return nil
+	}
+
+	initial := min(t.config.Trials, 2*len(t.config.Dimensions)+1)
+	if err := observe(baseline); err != nil {
+		return nil, err
+	}
+	for len(points) < initial {
+		if err := observe(t.randomPoint()); err != nil {
+			return nil, err
+		}
+	}
+
+	for len(points) < t.config.Trials {
+		gp, err := fitGaussianProcess(points, scores)
+		if err != nil {
+			return nil, err
+		}
+		incumbent := points[slices.Index(scores, slices.Max(scores))]
+		best := slices.Max(scores)
+
+		var next tuningPoint
+		nextImprovement := math.Inf(-1)
+		for i := 0; i < bayesCandidates; i++ {
+			candidate := t.randomPoint()
+			if i < bayesCandidates/4 {
+				for d := range candidate {
+					candidate[d] = math.Max(0, math.Min(1, incumbent[d]+t.rng.NormFloat64()*0.1))
+				}
+			}
+			if improvement := gp.expectedImprovement(candidate, best); improvement > nextImprovement {
+				next, nextImprovement = candidate, improvement
+			}
+		}
+		if err := observe(next); err != nil {
+			return nil, err
+		}
+	}
+
+	return bestPoints(points, scores, bayesFinalists), nil
+}
+
+// bestPoints returns up to count points in order of descending score.
+func bestPoints(points []tuningPoint, scores []float64, count int) []tuningPoint {
+	order := make([]int, len(points))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int { return cmpDescending(scores[a], scores[b]) })
+
+	best := make([]tuningPoint, 0, count)
+	for _, i := range order[:min(count, len(order))] {
+		best = append(best, points[i])
+	}
+	return best
+}
+
+func cmpDescending(a, b float64) int {
+	switch {
+	case a > b:
+		return -1
+	case a < b:
+		return 1
+	}
+	return 0
+}
+
+// Write stores the report as indented JSON.
+func (tr *TuningReport) Write(path string) error {
+	data, err := json.MarshalIndent(tr, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode tuning report: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// DefaultTuningDimensions are tuned when no list is given; they are used by
+// every method without enabling further stages.
+var DefaultTuningDimensions = []string{"WindowSize", "SmoothingStrength", "Brightness", "Contrast", "Gamma"}
+
+// TuningDimension is one numeric parameter the tuner searches, named like
+// its OtsuParameters field. Step quantizes values above Min, 0 for
+// continuous; Log searches the range on a logarithmic scale. Active
+// reports whether the stage that reads the parameter is enabled.
+type TuningDimension struct {
+	Name   string
+	Min    float64
+	Max    float64
+	Step   float64
+	Log    bool
+	Active func(params *OtsuParameters) bool
+	set    func(params *OtsuParameters, value float64)
+	get    func(params *OtsuParameters) float64
+}
+
+func alwaysActive(*OtsuParameters) bool { return true }
+
+var tuningDimensions = []TuningDimension{
+	{Name: "WindowSize", Min: 3, Max: 21, Step: 2, Active: alwaysActive,
+		set: func(p *OtsuParameters, v float64) { p.WindowSize = int(v) },
+		get: func(p *OtsuParameters) float64 { return float64(p.WindowSize) }},
+	{Name: "SmoothingStrength", Min: 0, Max: 10, Active: alwaysActive,
+		set: func(p *OtsuParameters, v float64) { p.SmoothingStrength = v },
+		get: func(p *OtsuParameters) float64 { return p.SmoothingStrength }},
+	{Name: "Brightness", Min: -100, Max: 100, Step: 1, Active: alwaysActive,
+		set: func(p *OtsuParameters, v float64) { p.Brightness = int(v) },
+		get: func(p *OtsuParameters) float64 { return float64(p.Brightness) }},
+	{Name: "Contrast", Min: 0.25, Max: 4, Log: true, Active: alwaysActive,
+		set: func(p *OtsuParameters, v float64) { p.Contrast = v },
+		get: func(p *OtsuParameters) float64 { return p.Contrast }},
+	{Name: "Gamma", Min: 0.2, Max: 5, Log: true, Active: alwaysActive,
+		set: func(p *OtsuParameters, v float64) { p.Gamma = v },
+		get: func(p *OtsuParameters) float64 { return p.Gamma }},
+	{Name: "HistogramBins", Min: 16, Max: 256, Step: 16, Active: alwaysActive,
+		set: func(p *OtsuParameters, v float64) { p.HistogramBins = int(v) },
+		get: func(p *OtsuParameters) float64 { return float64(p.HistogramBins) }},
+	{Name: "PyramidLevels", Min: 1, Max: 8, Step: 1,
+		Active: func(p *OtsuParameters) bool { return p.MultiScaleProcessing },
+		set:    func(p *OtsuParameters, v float64) { p.PyramidLevels = int(v) },
+		get:    func(p *OtsuParameters) float64 { return float64(p.PyramidLevels) }},
+	{Name: "RegionGridSize", Min: 16, Max: 512, Step: 16,
+		Active: func(p *OtsuParameters) bool { return p.RegionAdaptiveThresholding },
+		set:    func(p *OtsuParameters, v float64) { p.RegionGridSize = int(v) },
+		get:    func(p *OtsuParameters) float64 { return float64(p.RegionGridSize) }},
+	{Name: "MorphologicalKernelSize", Min: 1, Max: 15, Step: 2,
+		Active: func(p *OtsuParameters) bool { return p.MorphologicalPostProcess },
+		set:    func(p *OtsuParameters, v float64) { p.MorphologicalKernelSize = int(v) },
+		get:    func(p *OtsuParameters) float64 { return float64(p.MorphologicalKernelSize) }},
+	{Name: "DiffusionIterations", Min: 1, Max: 50, Step: 1,
+		Active: func(p *OtsuParameters) bool { return p.AnisotropicDiffusion },
+		set:    func(p *OtsuParameters, v float64) { p.DiffusionIterations = int(v) },
+		get:    func(p *OtsuParameters) float64 { return float64(p.DiffusionIterations) }},
+	{Name: "DiffusionKappa", Min: 1, Max: 200, Log: true,
+		Active: func(p *OtsuParameters) bool { return p.AnisotropicDiffusion },
+		set:    func(p *OtsuParameters, v float64) { p.DiffusionKappa = v },
+		get:    func(p *OtsuParameters) float64 { return p.DiffusionKappa }},
+	{Name: "ShadowRemovalStrength", Min: 0, Max: 1,
+		Active: func(p *OtsuParameters) bool { return p.ShadowRemoval },
+		set:    func(p *OtsuParameters, v float64) { p.ShadowRemovalStrength = v },
+		get:    func(p *OtsuParameters) float64 { return p.ShadowRemovalStrength }},
+	{Name: "MaxHoleArea", Min: 1, Max: 10000, Step: 1, Log: true,
+		Active: func(p *OtsuParameters) bool { return p.FillHoles },
+		set:    func(p *OtsuParameters, v float64) { p.MaxHoleArea = int(v) },
+		get:    func(p *OtsuParameters) float64 { return float64(p.MaxHoleArea) }},
+	{Name: "MaxGapSize", Min: 1, Max: 5, Step: 1,
+		Active: func(p *OtsuParameters) bool { return p.BridgeGaps },
+		set:    func(p *OtsuParameters, v float64) { p.MaxGapSize = int(v) },
+		get:    func(p *OtsuParameters) float64 { return float64(p.MaxGapSize) }},
+	{Name: "NeuralThreshold", Min: 0.05, Max: 0.95,
+		Active: func(p *OtsuParameters) bool { return p.NeuralBinarization },
+		set:    func(p *OtsuParameters, v float64) { p.NeuralThreshold = v },
+		get:    func(p *OtsuParameters) float64 { return p.NeuralThreshold }},
+	{Name: "Otsu3DBins", Min: minOtsu3DBins, Max: maxOtsu3DBins, Step: 8,
+		Active: func(p *OtsuParameters) bool { return p.Otsu3D },
+		set:    func(p *OtsuParameters, v float64) { p.Otsu3DBins = int(v) },
+		get:    func(p *OtsuParameters) float64 { return float64(p.Otsu3DBins) }},
+	{Name: "TsallisQ", Min: 0.1, Max: maxTsallisQ, Log: true,
+		Active: func(p *OtsuParameters) bool { return p.EntropyMethod == EntropyMethodTsallis },
+		set:    func(p *OtsuParameters, v float64) { p.TsallisQ = v },
+		get:    func(p *OtsuParameters) float64 { return p.TsallisQ }},
+}
+
+// ParseTuningDimensions looks up a comma-separated list of dimension names.
+func ParseTuningDimensions(list string) ([]TuningDimension, error) {
+	names := DefaultTuningDimensions
+	if strings.TrimSpace(list) != "" {
+		names = strings.Split(list, ",")
+	}
+
+	var dimensions []TuningDimension
+	seen := make(map[string]bool)
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		dimension, ok := findTuningDimension(name)
+		if !ok {
+			return nil, fmt.Errorf("cannot tune %q: expected one of %s", name, strings.Join(tuningDimensionNames(tuningDimensions), ", "))
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("%s listed twice", name)
+		}
+		seen[name] = true
+		dimensions = append(dimensions, dimension)
+	}
+	return dimensions, nil
+}
+
+func findTuningDimension(name string) (TuningDimension, bool) {
+	for _, dimension := range tuningDimensions {
+		if dimension.Name == name {
+			return dimension, true
+		}
+	}
+	return TuningDimension{}, false
+}
+
+func tuningDimensionNames(dimensions []TuningDimension) []string {
+	names := make([]string, len(dimensions))
+	for i, dimension := range dimensions {
+		names[i] = dimension.Name
+	}
+	return names
+}
+
+// value maps a unit interval position to a quantized parameter value.
+func (td TuningDimension) value(unit float64) float64 {
+	unit = math.Max(0, math.Min(1, unit))
+
+	var value float64
+	if td.Log {
+		value = math.Exp(math.Log(td.Min) + unit*(math.Log(td.Max)-math.Log(td.Min)))
+	} else {
+		value = td.Min + unit*(td.Max-td.Min)
+	}
+
+	if td.Step > 0 {
+		value = td.Min + math.Round((value-td.Min)/td.Step)*td.Step
+	} else {
+		// Continuous values keep three significant decimals so reports and
+		// parameter files stay readable.
+		value = math.Round(value*1000) / 1000
+	}
+	return math.Max(td.Min, math.Min(td.Max, value))
+}
+
+// unit is the inverse of value.
+func (td TuningDimension) unit(value float64) float64 {
+	if td.Log {
+		return (math.Log(value) - math.Log(td.Min)) / (math.Log(td.Max) - math.Log(td.Min))
+	}
+	return (value - td.Min) / (td.Max - td.Min)
+}
+
+// tuningPoint is a position in the unit cube spanned by the dimensions.
+type tuningPoint []float64
+
+// applyTuningPoint returns a copy of base with the dimensions set from point, and the
+// point snapped to the values actually used.
+func applyTuningPoint(base *OtsuParameters, dimensions []TuningDimension, point tuningPoint) (*OtsuParameters, tuningPoint) {
+	params := *base
+	snapped := make(tuningPoint, len(point))
+	for i, dimension := range dimensions {
+		value := dimension.value(point[i])
+		dimension.set(&params, value)
+		snapped[i] = dimension.unit(value)
+	}
+	return &params, snapped
+}
+
+// tuningValues names the parameter values of point for reports.
+func tuningValues(dimensions []TuningDimension, point tuningPoint) map[string]float64 {
+	values := make(map[string]float64, len(dimensions))
+	for i, dimension := range dimensions {
+		values[dimension.Name] = dimension.value(point[i])
+	}
+	return values
+}
+
+// baselinePoint is the position of the values already in params, so a
+// search can start from the parameters the user passed.
+func baselinePoint(params *OtsuParameters, dimensions []TuningDimension) tuningPoint {
+	point := make(tuningPoint, len(dimensions))
+	for i, dimension := range dimensions {
+		value := math.Max(dimension.Min, math.Min(dimension.Max, dimension.get(params)))
+		point[i] = dimension.unit(value)
+	}
+	return point
+}
@@ -0,0 +1,103 @@
+//go:build !nogui
+
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2/widget"
+)
+
+const (
+	prefBatterySaver = "power.battery_saver"
+
+	// batterySaverPollInterval is how often automatic mode checks whether
+	// the machine was plugged in or unplugged.
+	batterySaverPollInterval = 30 * time.Second
+)
+
+var batterySaverLabels = []struct {
+	mode  string
+	label string
+}{
+	{BatterySaverAuto, "On battery"},
+	{BatterySaverOn, "Always"},
+	{BatterySaverOff, "Never"},
+}
+
+// batterySaverWatch stops the power source polling of automatic mode.
+var (
+	batterySaverWatchMu sync.Mutex
+	batterySaverWatch   context.CancelFunc
+)
+
+// applyBatterySaverPreference turns the battery saver on or off from the
+// preferences. Automatic mode keeps polling the power source for as long as
+// the application runs.
+func (a *Application) applyBatterySaverPreference() {
+	mode := a.fyneApp.Preferences().StringWithFallback(prefBatterySaver, BatterySaverAuto)
+	if validateBatterySaverMode(mode) != nil {
+		mode = BatterySaverAuto
+	}
+
+	batterySaverWatchMu.Lock()
+	defer batterySaverWatchMu.Unlock()
+	if batterySaverWatch != nil {
+		batterySaverWatch()
+		batterySaverWatch = nil
+	}
+
+	active, _ := ResolveBatterySaver(mode)
+	SetBatterySaver(active)
+	if mode != BatterySaverAuto {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	batterySaverWatch = cancel
+	go func() {
+		ticker := time.NewTicker(batterySaverPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				active, _ := ResolveBatterySaver(BatterySaverAuto)
+				SetBatterySaver(active)
+			}
+		}
+	}()
+}
+
+func (a *Application) batterySaverPreferenceItem() (*widget.FormItem, func()) {
+	labels := make([]string, len(batterySaverLabels))
+	current := a.fyneApp.Preferences().StringWithFallback(prefBatterySaver, BatterySaverAuto)
+	selected := batterySaverLabels[0].label
+	for i, entry := range batterySaverLabels {
+		labels[i] = entry.label
+		if entry.mode == current {
+			selected = entry.label
+		}
+	}
+	modeSelect := widget.NewSelect(labels, nil)
+	modeSelect.SetSelected(selected)
+
+	item := widget.NewFormItem("Battery saver", modeSelect)
+	item.HintText = "Fewer threads, metrics on request, no live previews"
+	if BatterySaverActive() {
+		item.HintText += "; active now"
+	}
+
+	save := func() {
+		for _, entry := range batterySaverLabels {
+			if entry.label == modeSelect.Selected && entry.mode != current {
+				a.fyneApp.Preferences().SetString(prefBatterySaver, entry.mode)
+				a.applyBatterySaverPreference()
+			}
+		}
+	}
+	return item, save
+}
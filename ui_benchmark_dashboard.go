@@ -0,0 +1,121 @@
+//go:build !nogui
+
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// showBenchmarkDashboard opens a report written by the benchmark command
+// and charts it.
+func (a *Application) showBenchmarkDashboard() {
+	dialog.ShowFileOpen(func(reader fyne.URIReadCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, a.window)
+			return
+		}
+		if reader == nil {
+			return
+		}
+		reader.Close()
+
+		if reader.URI().Scheme() != "file" {
+			dialog.ShowError(fmt.Errorf("benchmark reports can only be opened from a local file"), a.window)
+			return
+		}
+
+		report, err := LoadBenchmarkReport(reader.URI().Path())
+		if err != nil {
+			dialog.ShowError(err, a.window)
+			return
+		}
+		a.showBenchmarkCharts(report, reader.URI().Name())
+	}, a.window)
+}
+
+func (a *Application) showBenchmarkCharts(report *BenchmarkReport, title string) {
+	names, charts := BenchmarkCharts(report)
+
+	chartImage := canvas.NewImageFromImage(nil)
+	chartImage.FillMode = canvas.ImageFillContain
+	chartImage.SetMinSize(fyne.NewSize(chartWidth, chartHeight))
+
+	chartSelect := widget.NewSelect(names, func(name string) {
+		chartImage.Image = charts[name].Image()
+		chartImage.Refresh()
+	})
+
+	export := func(extension string) {
+		name := chartSelect.Selected
+		save := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+			if err != nil {
+				dialog.ShowError(err, a.window)
+				return
+			}
+			if writer == nil {
+				return
+			}
+			writer.Close()
+
+			if writer.URI().Scheme() != "file" {
+				dialog.ShowError(fmt.Errorf("charts can only be exported to a local file"), a.window)
+				return
+			}
+			path := writer.URI().Path()
+			if filepath.Ext(path) == "" {
+				path += extension
+			}
+			if err := charts[name].WriteFile(path); err != nil {
+				dialog.ShowError(err, a.window)
+				return
+			}
+			a.statusBar.SetStatus(fmt.Sprintf("Exported chart %s", filepath.Base(path)))
+		}, a.window)
+		save.SetFileName(name + extension)
+		save.Show()
+	}
+
+	exportAll := widget.NewButton("Export All as SVG...", func() {
+		dialog.ShowFolderOpen(func(folder fyne.ListableURI, err error) {
+			if err != nil {
+				dialog.ShowError(err, a.window)
+				return
+			}
+			if folder == nil {
+				return
+			}
+			if err := WriteBenchmarkCharts(report, folder.Path()); err != nil {
+				dialog.ShowError(err, a.window)
+				return
+			}
+			a.statusBar.SetStatus(fmt.Sprintf("Exported %d charts to %s", len(names), folder.Path()))
+		}, a.window)
+	})
+
+	chartSelect.SetSelected(names[0])
+
+	summary := widget.NewLabel(fmt.Sprintf("%d candidates, %d images, %d runs, alpha %g",
+		len(report.Candidates), len(report.Pairs), len(report.Runs), report.Alpha))
+
+	content := container.NewBorder(
+		container.NewVBox(summary, widget.NewForm(widget.NewFormItem("Chart", chartSelect))),
+		container.NewHBox(
+			widget.NewButton("Export PNG...", func() { export(".png") }),
+			widget.NewButton("Export SVG...", func() { export(".svg") }),
+			exportAll,
+		),
+		nil, nil,
+		chartImage,
+	)
+
+	d := dialog.NewCustom("Benchmark: "+title, "Close", content, a.window)
+	d.Resize(fyne.NewSize(chartWidth+60, chartHeight+200))
+	d.Show()
+}
@@ -0,0 +1,79 @@
+//go:build !nogui
+
+package main
+
+import (
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// showProcessingError shows err like dialog.ShowError, with a button that
+// turns it into a bug report for the run of params on the loaded image.
+func (a *Application) showProcessingError(err error, params *OtsuParameters) {
+	message := widget.NewLabel(err.Error())
+	message.Wrapping = fyne.TextWrapWord
+
+	var d dialog.Dialog
+	report := widget.NewButton("Create Report", func() {
+		d.Hide()
+		a.showBugReport(NewBugReport(err, a.processing.GetOriginalImage(), params))
+	})
+
+	content := container.NewBorder(nil, container.NewHBox(report), nil, nil, message)
+	d = dialog.NewCustom("Processing Error", "Close", content, a.window)
+	d.Resize(fyne.NewSize(480, 200))
+	d.Show()
+}
+
+// showBugReport shows the text of report to copy into an issue, and can
+// save it as a diagnostics bundle.
+func (a *Application) showBugReport(report *BugReport) {
+	text := widget.NewMultiLineEntry()
+	text.SetText(report.Text())
+	text.Wrapping = fyne.TextWrapOff
+
+	copyButton := widget.NewButton("Copy", func() {
+		a.fyneApp.Clipboard().SetContent(text.Text)
+		a.statusBar.SetStatus("Bug report copied")
+	})
+	saveButton := widget.NewButton("Save Bundle...", func() {
+		a.saveBugReportBundle(report)
+	})
+
+	hint := widget.NewLabel("Edit the steps to reproduce, then paste the report into a new issue. The image itself is not included.")
+	hint.Wrapping = fyne.TextWrapWord
+
+	content := container.NewBorder(hint, container.NewHBox(copyButton, saveButton), nil, nil, text)
+	d := dialog.NewCustom("Bug Report", "Close", content, a.window)
+	d.Resize(fyne.NewSize(720, 560))
+	d.Show()
+}
+
+func (a *Application) saveBugReportBundle(report *BugReport) {
+	save := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, a.window)
+			return
+		}
+		if writer == nil {
+			return
+		}
+		writer.Close()
+
+		if writer.URI().Scheme() != "file" {
+			dialog.ShowError(fmt.Errorf("the bug report can only be saved to a local file"), a.window)
+			return
+		}
+		if err := report.WriteBundle(writer.URI().Path()); err != nil {
+			dialog.ShowError(err, a.window)
+			return
+		}
+		a.statusBar.SetStatus("Saved bug report to " + writer.URI().Name())
+	}, a.window)
+	save.SetFileName(fmt.Sprintf("otsu-obliterator-report-%s.zip", report.CreatedAt.Format("20060102-150405")))
+	save.Show()
+}
@@ -0,0 +1,161 @@
+//go:build !nogui
+
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+const (
+	channelCardWidth       = 240
+	channelHistogramHeight = 64
+)
+
+// showChannelView lays the grayscale conversions of the loaded image side
+// by side so the one that separates ink from paper best can be chosen.
+func (a *Application) showChannelView() {
+	if a.processing.GetOriginalImage() == nil {
+		dialog.ShowInformation("Inspect Channels", "Load an image first.", a.window)
+		return
+	}
+
+	a.statusBar.SetStatus("Splitting channels...")
+	go func() {
+		views, err := a.processing.ChannelViews()
+		// Ink colors need a result; without one the cards stand alone.
+		inks, _ := a.processing.InkColors()
+		fyne.Do(func() {
+			if err != nil {
+				a.statusBar.SetStatus("Channel split failed")
+				dialog.ShowError(err, a.window)
+				return
+			}
+			a.statusBar.SetStatus(fmt.Sprintf("Split %d channels", len(views)))
+			a.showChannelCards(views, inks)
+		})
+	}()
+}
+
+func (a *Application) showChannelCards(views []ChannelView, inks *InkColorStats) {
+	best := 0
+	for i, view := range views {
+		if view.Separability > views[best].Separability {
+			best = i
+		}
+	}
+
+	var d dialog.Dialog
+	cards := container.NewGridWithColumns(3)
+	for i, view := range views {
+		preview := canvas.NewImageFromImage(view.Preview)
+		preview.FillMode = canvas.ImageFillContain
+		preview.SetMinSize(fyne.NewSize(channelCardWidth, channelCardWidth*3/4))
+
+		histogram := canvas.NewImageFromImage(channelHistogramImage(view))
+		histogram.FillMode = canvas.ImageFillStretch
+		histogram.ScaleMode = canvas.ImageScalePixels
+		histogram.SetMinSize(fyne.NewSize(channelCardWidth, channelHistogramHeight))
+
+		title := view.Channel
+		if i == best {
+			title += " (best)"
+		}
+		if inks != nil && view.Channel == inks.SuggestedChannel {
+			title += " (keeps every ink)"
+		}
+		header := widget.NewLabelWithStyle(title, fyne.TextAlignLeading, fyne.TextStyle{Bold: i == best})
+
+		channel := view.Channel
+		use := widget.NewButton("Use", func() {
+			a.parameters.widgets.grayChannelSelect.SetSelected(channel)
+			a.statusBar.SetStatus(fmt.Sprintf("Grayscale channel set to %s", channel))
+			d.Hide()
+		})
+		if a.parameters.widgets.grayChannelSelect.Selected == channel {
+			use.Disable()
+		}
+
+		cards.Add(container.NewVBox(
+			container.NewBorder(nil, nil, nil, use, header),
+			preview,
+			histogram,
+			widget.NewLabel(fmt.Sprintf("Otsu threshold %d, separability %.3f", view.Threshold, view.Separability)),
+		))
+	}
+
+	content := fyne.CanvasObject(cards)
+	if inks != nil {
+		content = container.NewVBox(inkColorSummary(inks), widget.NewSeparator(), cards)
+	}
+
+	d = dialog.NewCustom("Inspect Channels", "Close", container.NewVScroll(content), a.window)
+	d.Resize(fyne.NewSize(3*channelCardWidth+80, 2*channelCardWidth+200))
+	d.Show()
+}
+
+// inkColorSummary shows the ink color clusters of the latest result as
+// swatches with their share of the ink.
+func inkColorSummary(inks *InkColorStats) fyne.CanvasObject {
+	swatches := container.NewHBox()
+	for _, cluster := range inks.Clusters {
+		swatch := canvas.NewRectangle(color.NRGBA{
+			R: uint8(math.Round(cluster.RGB[0])), G: uint8(math.Round(cluster.RGB[1])), B: uint8(math.Round(cluster.RGB[2])), A: 255,
+		})
+		swatch.SetMinSize(fyne.NewSize(24, 24))
+		swatch.StrokeColor = theme.Color(theme.ColorNameForeground)
+		swatch.StrokeWidth = 1
+		swatches.Add(swatch)
+		swatches.Add(widget.NewLabel(fmt.Sprintf("%.0f%%", cluster.Share*100)))
+	}
+
+	verdict := "One ink"
+	if inks.MultiInk {
+		verdict = "Several inks"
+	}
+	return container.NewVBox(
+		createSectionHeader("Ink Colors of the Latest Result"),
+		swatches,
+		widget.NewLabel(fmt.Sprintf("%s; %s keeps every ink clearest from the paper (contrast %.0f)",
+			verdict, inks.SuggestedChannel, inks.ChannelContrast[inks.SuggestedChannel])),
+	)
+}
+
+// channelHistogramImage draws the histogram of view with its Otsu
+// threshold marked, one pixel column per gray level.
+func channelHistogramImage(view ChannelView) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 256, channelHistogramHeight))
+
+	background := color.RGBA{R: 245, G: 245, B: 245, A: 255}
+	bar := color.RGBA{R: 80, G: 80, B: 80, A: 255}
+	marker := theme.Color(theme.ColorNameError)
+
+	largest := 1
+	for _, count := range view.Histogram {
+		largest = max(largest, count)
+	}
+
+	for x, count := range view.Histogram {
+		height := count * channelHistogramHeight / largest
+		for y := range channelHistogramHeight {
+			switch {
+			case x == view.Threshold:
+				img.Set(x, y, marker)
+			case y >= channelHistogramHeight-height:
+				img.Set(x, y, bar)
+			default:
+				img.Set(x, y, background)
+			}
+		}
+	}
+	return img
+}
@@ -0,0 +1,206 @@
+//go:build !nogui
+
+package main
+
+import (
+	"image"
+	"math"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/driver/desktop"
+)
+
+const (
+	// blinkInterval is how long each image shows while blinking.
+	blinkInterval = 400 * time.Millisecond
+
+	// maxCompareZoom is the largest magnification of the fitted image and
+	// zoomNotch the magnification of one scroll wheel notch, which Fyne
+	// reports as 10 units.
+	maxCompareZoom = 32
+	zoomNotch      = 1.25
+)
+
+var blinkShortcut = &desktop.CustomShortcut{KeyName: fyne.KeyB, Modifier: fyne.KeyModifierShortcutDefault}
+
+// buildCompareMenu returns the Compare Mode and Blink items of the View
+// menu and registers the blink shortcut on the window.
+func (a *Application) buildCompareMenu() []*fyne.MenuItem {
+	compare := fyne.NewMenuItem("Compare Mode", nil)
+	compare.Action = func() {
+		compare.Checked = !compare.Checked
+		a.imageViewer.SetComparing(compare.Checked)
+		if compare.Checked {
+			a.statusBar.SetStatus("Scroll over a preview to zoom both, drag to pan them")
+		}
+		a.refreshMainMenu()
+	}
+
+	blink := fyne.NewMenuItem("Blink Original and Processed", nil)
+	blink.Shortcut = blinkShortcut
+	blink.Action = func() {
+		blink.Checked = !blink.Checked
+		a.imageViewer.SetBlinking(blink.Checked)
+		a.refreshMainMenu()
+	}
+	a.window.Canvas().AddShortcut(blinkShortcut, func(fyne.Shortcut) { blink.Action() })
+	return []*fyne.MenuItem{compare, blink}
+}
+
+func (a *Application) refreshMainMenu() {
+	if mainMenu := a.window.MainMenu(); mainMenu != nil {
+		mainMenu.Refresh()
+	}
+}
+
+// viewRect is the part of the source the view shows: the strip rows, or
+// the part of them zoomed into.
+func (pv *previewView) viewRect() image.Rectangle {
+	rect := pv.stripRect()
+	if pv.zoom <= 1 {
+		return rect
+	}
+
+	width := max(1, int(float32(rect.Dx())/pv.zoom+0.5))
+	height := max(1, int(float32(rect.Dy())/pv.zoom+0.5))
+	left := rect.Min.X + int(pv.zoomCenter.X*float32(rect.Dx())+0.5) - width/2
+	top := rect.Min.Y + int(pv.zoomCenter.Y*float32(rect.Dy())+0.5) - height/2
+	left = max(rect.Min.X, min(rect.Max.X-width, left))
+	top = max(rect.Min.Y, min(rect.Max.Y-height, top))
+	return image.Rect(left, top, left+width, top+height)
+}
+
+// zoomFraction is where p lies in the strip rows, as a fraction of their
+// width and height.
+func (pv *previewView) zoomFraction(p image.Point) fyne.Position {
+	rect := pv.stripRect()
+	return fyne.NewPos(float32(p.X-rect.Min.X)/float32(rect.Dx()), float32(p.Y-rect.Min.Y)/float32(rect.Dy()))
+}
+
+// scaleRect maps rect from the coordinates of from to those of to.
+func scaleRect(rect, from, to image.Rectangle) image.Rectangle {
+	if from == to {
+		return rect
+	}
+	scaled := image.Rect(
+		to.Min.X+(rect.Min.X-from.Min.X)*to.Dx()/from.Dx(),
+		to.Min.Y+(rect.Min.Y-from.Min.Y)*to.Dy()/from.Dy(),
+		to.Min.X+(rect.Max.X-from.Min.X)*to.Dx()/from.Dx(),
+		to.Min.Y+(rect.Max.Y-from.Min.Y)*to.Dy()/from.Dy(),
+	)
+	scaled.Max.X = max(scaled.Max.X, scaled.Min.X+1)
+	scaled.Max.Y = max(scaled.Max.Y, scaled.Min.Y+1)
+	return scaled.Intersect(to)
+}
+
+// previews are the views that zoom and pan together.
+func (iv *ImageViewer) previews() []*previewView {
+	return []*previewView{iv.originalImage, iv.processedImage, iv.blinkImage}
+}
+
+// SetComparing turns comparison mode on or off. While it is on, scrolling
+// over a preview zooms all of them around the pointer and dragging pans
+// them; turning it off fits the images again.
+func (iv *ImageViewer) SetComparing(comparing bool) {
+	iv.comparing = comparing
+	for _, pv := range iv.previews() {
+		pv.onZoomed = nil
+		if comparing {
+			pv.onZoomed = iv.zoomAround(pv)
+		}
+	}
+	if !comparing {
+		iv.setZoom(1, fyne.NewPos(0.5, 0.5))
+	}
+}
+
+func (iv *ImageViewer) Comparing() bool {
+	return iv.comparing
+}
+
+// zoomAround zooms by scrolling over pv, keeping the pixel under the
+// pointer in place.
+func (iv *ImageViewer) zoomAround(pv *previewView) func(pos fyne.Position, dy float32) {
+	return func(pos fyne.Position, dy float32) {
+		p, _, ok := pv.imagePoint(pos)
+		if !ok {
+			return
+		}
+		at := pv.zoomFraction(p)
+		zoom := iv.zoom * float32(math.Pow(zoomNotch, float64(dy)/10))
+		zoom = float32(math.Max(1, math.Min(maxCompareZoom, float64(zoom))))
+
+		ratio := iv.zoom / zoom
+		iv.setZoom(zoom, fyne.NewPos(at.X-(at.X-iv.zoomCenter.X)*ratio, at.Y-(at.Y-iv.zoomCenter.Y)*ratio))
+	}
+}
+
+// pan moves the zoomed previews so the pixel at from in pv comes to lie
+// under to.
+func (iv *ImageViewer) pan(pv *previewView, from, to image.Point) {
+	if iv.zoom <= 1 || pv.source == nil {
+		return
+	}
+	rect := pv.stripRect()
+	iv.setZoom(iv.zoom, fyne.NewPos(
+		iv.zoomCenter.X+float32(from.X-to.X)/float32(rect.Dx()),
+		iv.zoomCenter.Y+float32(from.Y-to.Y)/float32(rect.Dy()),
+	))
+}
+
+// setZoom zooms every preview to zoom around center, kept far enough from
+// the edges that the view stays inside the image.
+func (iv *ImageViewer) setZoom(zoom float32, center fyne.Position) {
+	margin := 1 / (2 * zoom)
+	iv.zoom = zoom
+	iv.zoomCenter = fyne.NewPos(clampZoomCenter(center.X, margin), clampZoomCenter(center.Y, margin))
+	for _, pv := range iv.previews() {
+		pv.zoom, pv.zoomCenter = iv.zoom, iv.zoomCenter
+		pv.rasterize()
+	}
+	iv.processedView.layoutOverlays()
+}
+
+func clampZoomCenter(value, margin float32) float32 {
+	if value < margin {
+		return margin
+	}
+	if value > 1-margin {
+		return 1 - margin
+	}
+	return value
+}
+
+// SetBlinking makes the processed preview alternate with the original
+// every blinkInterval, so changes near strokes flicker, or stops it.
+func (iv *ImageViewer) SetBlinking(blinking bool) {
+	iv.blinking.Store(blinking)
+	iv.showBlinkOriginal(false)
+	if blinking {
+		iv.blinkImage.SetImage(iv.originalImage.source)
+		iv.blinkImage.setStrip(iv.originalImage.strip, iv.stripOffset)
+	} else {
+		iv.blinkImage.SetImage(nil)
+	}
+}
+
+func (iv *ImageViewer) Blinking() bool {
+	return iv.blinking.Load()
+}
+
+func (iv *ImageViewer) blink() {
+	if iv.blinking.Load() {
+		iv.showBlinkOriginal(!iv.blinkImage.Visible())
+	}
+}
+
+func (iv *ImageViewer) showBlinkOriginal(show bool) {
+	if show {
+		iv.blinkImage.Show()
+		iv.processedHeader.SetText("Original")
+	} else {
+		iv.blinkImage.Hide()
+		iv.processedHeader.SetText("Processed")
+	}
+}
@@ -0,0 +1,183 @@
+//go:build !nogui
+
+package main
+
+import (
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+const componentHistogramBarWidth = 160
+
+// ComponentPanel shows connected component statistics for the latest
+// result and the details of a component tapped in the processed image.
+type ComponentPanel struct {
+	app       *Application
+	container *fyne.Container
+
+	summaryLabel  *widget.Label
+	selectedLabel *widget.Label
+	histogram     *fyne.Container
+	density       *fyne.Container
+
+	analysis *ComponentAnalysis
+	pending  bool
+
+	// generation discards analyses that finish after a newer result arrived.
+	generation int
+}
+
+func NewComponentPanel(app *Application) *ComponentPanel {
+	cp := &ComponentPanel{
+		app:           app,
+		summaryLabel:  widget.NewLabel("No result yet"),
+		selectedLabel: widget.NewLabel("Tap a component in the processed image"),
+		histogram:     container.NewVBox(),
+		density:       container.NewGridWithColumns(ComponentDensityGrid),
+	}
+
+	cp.container = container.NewVBox(
+		createSectionHeader("Components"),
+		cp.summaryLabel,
+		widget.NewLabel("Size distribution (pixels)"),
+		cp.histogram,
+		widget.NewLabel("Ink density by region"),
+		cp.density,
+		cp.selectedLabel,
+	)
+
+	return cp
+}
+
+// Analyze measures the components of result in the background and shows
+// the statistics once done.
+func (cp *ComponentPanel) Analyze(result *ImageData) {
+	cp.generation++
+	generation := cp.generation
+	cp.pending = true
+	cp.summaryLabel.SetText("Analyzing components...")
+
+	mat := result.Mat.Clone()
+	go func() {
+		defer mat.Close()
+
+		analysis, err := AnalyzeComponents(mat)
+		fyne.Do(func() {
+			if generation != cp.generation {
+				if analysis != nil {
+					analysis.Close()
+				}
+				return
+			}
+
+			cp.pending = false
+			if err != nil {
+				cp.summaryLabel.SetText(fmt.Sprintf("Component analysis failed: %v", err))
+				return
+			}
+			cp.setAnalysis(analysis)
+		})
+	}()
+}
+
+// Clear drops the statistics, for example when a new image is loaded.
+func (cp *ComponentPanel) Clear() {
+	cp.generation++
+	cp.pending = false
+	cp.setAnalysis(nil)
+	cp.summaryLabel.SetText("No result yet")
+}
+
+func (cp *ComponentPanel) setAnalysis(analysis *ComponentAnalysis) {
+	if cp.analysis != nil {
+		cp.analysis.Close()
+	}
+	cp.analysis = analysis
+	cp.selectedLabel.SetText("Tap a component in the processed image")
+
+	cp.histogram.Objects = nil
+	cp.density.Objects = nil
+	defer cp.histogram.Refresh()
+	defer cp.density.Refresh()
+
+	if analysis == nil {
+		return
+	}
+
+	cp.summaryLabel.SetText(fmt.Sprintf("%d components | median area %d px | stroke width %.1f px",
+		len(analysis.Components), analysis.MedianArea, analysis.AverageStrokeWidth))
+
+	largest := 0
+	last := 0
+	for i, bucket := range analysis.SizeHistogram {
+		if bucket.Count > largest {
+			largest = bucket.Count
+		}
+		if bucket.Count > 0 {
+			last = i
+		}
+	}
+
+	for _, bucket := range analysis.SizeHistogram[:last+1] {
+		rangeText := fmt.Sprintf("%d-%d", bucket.MinArea, bucket.MaxArea)
+		if bucket.MaxArea == 0 {
+			rangeText = fmt.Sprintf("%d+", bucket.MinArea)
+		}
+
+		bar := canvas.NewRectangle(theme.Color(theme.ColorNamePrimary))
+		width := float32(0)
+		if largest > 0 {
+			width = componentHistogramBarWidth * float32(bucket.Count) / float32(largest)
+		}
+		bar.SetMinSize(fyne.NewSize(width, 10))
+
+		cp.histogram.Add(container.NewHBox(
+			widget.NewLabel(rangeText),
+			container.NewCenter(bar),
+			widget.NewLabel(fmt.Sprintf("%d", bucket.Count)),
+		))
+	}
+
+	for _, row := range analysis.InkDensity {
+		for _, value := range row {
+			cp.density.Add(widget.NewLabel(fmt.Sprintf("%.1f%%", value*100)))
+		}
+	}
+}
+
+// CurrentAnalysis returns the analysis of the result on screen, or nil
+// while it is still being computed.
+func (cp *ComponentPanel) CurrentAnalysis() *ComponentAnalysis {
+	if cp.pending {
+		return nil
+	}
+	return cp.analysis
+}
+
+// ShowComponentAt reports the component under pixel (x, y) of the result.
+func (cp *ComponentPanel) ShowComponentAt(x, y int) {
+	if cp.analysis == nil {
+		return
+	}
+
+	component, ok := cp.analysis.ComponentAt(x, y)
+	if !ok {
+		cp.selectedLabel.SetText(fmt.Sprintf("(%d, %d) is background", x, y))
+		return
+	}
+
+	cp.selectedLabel.SetText(fmt.Sprintf("Component %d: %d px, %dx%d at (%d, %d), stroke width %.1f px",
+		component.Label, component.Area,
+		component.Bounds.Dx(), component.Bounds.Dy(),
+		component.Bounds.Min.X, component.Bounds.Min.Y,
+		component.StrokeWidth))
+}
+
+func (cp *ComponentPanel) GetContainer() *fyne.Container {
+	return cp.container
+}
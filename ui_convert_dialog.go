@@ -0,0 +1,117 @@
+//go:build !nogui
+
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+var convertFormats = map[string]string{
+	"PNG":  ".png",
+	"JPEG": ".jpg",
+	"TIFF": ".tif",
+}
+
+const (
+	convertDepth8 = "8-bit"
+	convertDepth1 = "1-bit (PNG or Group 4 TIFF, black and white images only)"
+)
+
+// showConvertDialog re-encodes an image file in another format without
+// loading it into the window or thresholding it.
+func (a *Application) showConvertDialog() {
+	dialog.ShowFileOpen(func(reader fyne.URIReadCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, a.window)
+			return
+		}
+		if reader == nil {
+			return
+		}
+		defer reader.Close()
+
+		data, extension, err := ReadImageBytes(reader)
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("read %s: %w", reader.URI().Name(), err), a.window)
+			return
+		}
+		a.showConvertOptions(reader.URI().Name(), data, extension)
+	}, a.window)
+}
+
+func (a *Application) showConvertOptions(name string, data []byte, extension string) {
+	formatSelect := widget.NewSelect([]string{"PNG", "JPEG", "TIFF"}, nil)
+	formatSelect.SetSelected("TIFF")
+	dpiEntry := widget.NewEntry()
+	if dpi := detectImageDPI(data); dpi > 0 {
+		dpiEntry.SetPlaceHolder(fmt.Sprintf("Keep %.0f", dpi))
+	} else {
+		dpiEntry.SetPlaceHolder("None recorded")
+	}
+	depthSelect := widget.NewSelect([]string{convertDepth8, convertDepth1}, nil)
+	depthSelect.SetSelected(convertDepth8)
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("Format", formatSelect),
+		widget.NewFormItem("DPI", dpiEntry),
+		widget.NewFormItem("Bit Depth", depthSelect),
+	}
+	dialog.ShowForm("Convert "+name, "Convert...", "Cancel", items, func(confirmed bool) {
+		if !confirmed {
+			return
+		}
+
+		options := ConvertOptions{BitDepth: 8}
+		if depthSelect.Selected == convertDepth1 {
+			options.BitDepth = 1
+		}
+		if text := strings.TrimSpace(dpiEntry.Text); text != "" {
+			dpi, err := strconv.ParseFloat(text, 64)
+			if err != nil {
+				dialog.ShowError(fmt.Errorf("DPI %q is not a number", text), a.window)
+				return
+			}
+			options.DPI = dpi
+		}
+
+		outputExtension := convertFormats[formatSelect.Selected]
+		converted, err := ConvertImage(data, extension, outputExtension, options)
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("convert %s: %w", name, err), a.window)
+			return
+		}
+		a.saveConvertedImage(strings.TrimSuffix(name, filepath.Ext(name))+outputExtension, converted)
+	}, a.window)
+}
+
+func (a *Application) saveConvertedImage(name string, converted []byte) {
+	save := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, a.window)
+			return
+		}
+		if writer == nil {
+			return
+		}
+
+		if _, err := writer.Write(converted); err != nil {
+			writer.Close()
+			dialog.ShowError(fmt.Errorf("write %s: %w", writer.URI().Name(), err), a.window)
+			return
+		}
+		if err := writer.Close(); err != nil {
+			dialog.ShowError(fmt.Errorf("close %s: %w", writer.URI().Name(), err), a.window)
+			return
+		}
+		a.statusBar.SetStatus("Converted " + writer.URI().Name())
+	}, a.window)
+	save.SetFileName(name)
+	save.Show()
+}
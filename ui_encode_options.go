@@ -0,0 +1,115 @@
+//go:build !nogui
+
+package main
+
+import (
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+const (
+	prefPNGCompression  = "output.png_compression"
+	prefJPEGQuality     = "output.jpeg_quality"
+	prefTIFFCompression = "output.tiff_compression"
+	prefBilevel         = "output.bilevel"
+)
+
+// applyEncodePreference sets the encoder defaults of saved images from the
+// preferences, falling back to the built-in defaults when they are invalid.
+func (a *Application) applyEncodePreference() {
+	defaults := DefaultEncodeOptions()
+	preferences := a.fyneApp.Preferences()
+	options := EncodeOptions{
+		PNGCompression:  preferences.StringWithFallback(prefPNGCompression, defaults.PNGCompression),
+		JPEGQuality:     preferences.IntWithFallback(prefJPEGQuality, defaults.JPEGQuality),
+		TIFFCompression: preferences.StringWithFallback(prefTIFFCompression, defaults.TIFFCompression),
+		Bilevel:         preferences.BoolWithFallback(prefBilevel, defaults.Bilevel),
+	}
+	if err := options.Validate(); err != nil {
+		a.debugSystem.logger.Warn("encoder preferences ignored", "error", err.Error())
+		options = defaults
+	}
+	SetEncodeOptions(options)
+}
+
+// encodeOptionsEditor edits EncodeOptions; the preferences and the save
+// dialog share it.
+type encodeOptionsEditor struct {
+	pngCompression  *widget.Select
+	bilevel         *widget.Check
+	jpegQuality     *widget.Slider
+	tiffCompression *widget.Select
+
+	png  *fyne.Container
+	jpeg *fyne.Container
+	tiff *fyne.Container
+}
+
+func newEncodeOptionsEditor(options EncodeOptions) *encodeOptionsEditor {
+	editor := &encodeOptionsEditor{
+		pngCompression:  widget.NewSelect(PNGCompressionLevels, nil),
+		bilevel:         widget.NewCheck("1-bit black and white PNG", nil),
+		jpegQuality:     widget.NewSlider(1, 100),
+		tiffCompression: widget.NewSelect(TIFFCompressions, nil),
+	}
+	editor.pngCompression.SetSelected(options.PNGCompression)
+	editor.bilevel.SetChecked(options.Bilevel)
+	editor.jpegQuality.SetValue(float64(options.JPEGQuality))
+	editor.tiffCompression.SetSelected(options.TIFFCompression)
+
+	qualityLabel := widget.NewLabel(fmt.Sprintf("%d", options.JPEGQuality))
+	editor.jpegQuality.OnChanged = func(value float64) {
+		qualityLabel.SetText(fmt.Sprintf("%.0f", value))
+	}
+
+	editor.png = container.NewVBox(widget.NewLabel("PNG compression:"), editor.pngCompression, editor.bilevel)
+	editor.jpeg = container.NewVBox(widget.NewLabel("JPEG quality:"), container.NewBorder(nil, nil, nil, qualityLabel, editor.jpegQuality))
+	editor.tiff = container.NewVBox(widget.NewLabel("TIFF compression:"), editor.tiffCompression)
+	return editor
+}
+
+func (e *encodeOptionsEditor) Options() EncodeOptions {
+	return EncodeOptions{
+		PNGCompression:  e.pngCompression.Selected,
+		JPEGQuality:     int(e.jpegQuality.Value),
+		TIFFCompression: e.tiffCompression.Selected,
+		Bilevel:         e.bilevel.Checked,
+	}
+}
+
+// ShowFormat shows only the settings of format, one of the save dialog's
+// format names.
+func (e *encodeOptionsEditor) ShowFormat(format string) {
+	for name, settings := range map[string]*fyne.Container{"PNG": e.png, "JPEG": e.jpeg, "TIFF": e.tiff} {
+		if name == format {
+			settings.Show()
+		} else {
+			settings.Hide()
+		}
+	}
+}
+
+func (e *encodeOptionsEditor) Container() *fyne.Container {
+	return container.NewVBox(e.png, e.jpeg, e.tiff)
+}
+
+// encodePreferenceItem is the preferences entry for the encoder defaults and
+// a func that stores and applies them.
+func (a *Application) encodePreferenceItem() (*widget.FormItem, func()) {
+	editor := newEncodeOptionsEditor(CurrentEncodeOptions())
+	item := widget.NewFormItem("Saved images", editor.Container())
+	item.HintText = "Defaults of the save dialog, also used for split pages"
+
+	return item, func() {
+		options := editor.Options()
+		preferences := a.fyneApp.Preferences()
+		preferences.SetString(prefPNGCompression, options.PNGCompression)
+		preferences.SetInt(prefJPEGQuality, options.JPEGQuality)
+		preferences.SetString(prefTIFFCompression, options.TIFFCompression)
+		preferences.SetBool(prefBilevel, options.Bilevel)
+		SetEncodeOptions(options)
+	}
+}
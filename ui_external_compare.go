@@ -0,0 +1,166 @@
+//go:build !nogui
+
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// showImportExternalResult loads a binarization another tool produced for
+// the current image and compares the current result with it.
+func (a *Application) showImportExternalResult() {
+	if a.processing.GetProcessedImage() == nil {
+		dialog.ShowInformation("Import External Result", "Process the image before importing another tool's result.", a.window)
+		return
+	}
+
+	a.openReferenceImage(func(external *ImageData, name string) {
+		a.showExternalComparisonDialog(external, strings.TrimSuffix(name, filepath.Ext(name)))
+	})
+}
+
+// openReferenceImage decodes a chosen image at full resolution; references
+// are scaled to the working image by the comparison itself.
+func (a *Application) openReferenceImage(onLoaded func(data *ImageData, name string)) {
+	dialog.ShowFileOpen(func(reader fyne.URIReadCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, a.window)
+			return
+		}
+		if reader == nil {
+			return
+		}
+		defer reader.Close()
+
+		data, err := LoadImageFromReader(reader)
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("load %s: %w", reader.URI().Name(), err), a.window)
+			return
+		}
+		onLoaded(data, reader.URI().Name())
+	}, a.window)
+}
+
+func (a *Application) showExternalComparisonDialog(external *ImageData, tool string) {
+	var groundTruth *ImageData
+
+	toolEntry := widget.NewEntry()
+	toolEntry.SetText(tool)
+	invertCheck := widget.NewCheck("External result draws ink in white", nil)
+	truthLabel := widget.NewLabel("None")
+	summary := widget.NewLabel("")
+	summary.Wrapping = fyne.TextWrapWord
+	exportButton := widget.NewButton("Export Overlay...", nil)
+
+	update := func() {
+		comparison, err := a.processing.CompareExternalResult(toolEntry.Text, external, groundTruth, invertCheck.Checked)
+		if err != nil {
+			summary.SetText(err.Error())
+			exportButton.Disable()
+			return
+		}
+		defer comparison.Close()
+
+		summary.SetText(describeExternalComparison(comparison))
+		exportButton.Enable()
+	}
+	invertCheck.OnChanged = func(bool) { update() }
+
+	truthButton := widget.NewButton("Choose Ground Truth...", func() {
+		a.openReferenceImage(func(data *ImageData, name string) {
+			if groundTruth != nil {
+				groundTruth.Mat.Close()
+			}
+			groundTruth = data
+			truthLabel.SetText(name)
+			update()
+		})
+	})
+
+	exportButton.OnTapped = func() {
+		a.exportExternalComparison(toolEntry.Text, external, groundTruth, invertCheck.Checked)
+	}
+
+	update()
+
+	content := container.NewVBox(
+		widget.NewForm(
+			widget.NewFormItem("Tool", toolEntry),
+			widget.NewFormItem("Polarity", invertCheck),
+			widget.NewFormItem("Ground truth", container.NewHBox(truthLabel, truthButton)),
+		),
+		summary,
+		exportButton,
+	)
+
+	d := dialog.NewCustom("Compare with External Result", "Close", content, a.window)
+	d.SetOnClosed(func() {
+		external.Mat.Close()
+		if groundTruth != nil {
+			groundTruth.Mat.Close()
+		}
+	})
+	d.Resize(fyne.NewSize(620, 0))
+	d.Show()
+}
+
+func describeExternalComparison(comparison *ExternalComparison) string {
+	total := comparison.Width * comparison.Height
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d of %d pixels disagree (%.3f%%): %d ink only here (red), %d ink only in %s (blue)\n",
+		comparison.DisagreementPixels, total, 100*float64(comparison.DisagreementPixels)/float64(max(1, total)),
+		comparison.OursOnlyInk, comparison.TheirsOnlyInk, comparison.Tool)
+
+	writeRow := func(label string, report *MetricsReport) {
+		fmt.Fprintf(&b, "\n%s\tF %.4f\tpF %.4f\tNRM %.4f\tDRD %.4f\tMPM %.4f\tBFC %.4f\tSkel %.4f",
+			label, report.FMeasure, report.PseudoFMeasure, report.NRM, report.DRD,
+			report.MPM, report.BFC, report.Skeleton)
+	}
+
+	writeRow("Against "+comparison.Tool, comparison.Agreement)
+	if comparison.Ours != nil {
+		writeRow("This result vs ground truth", comparison.Ours)
+		writeRow(comparison.Tool+" vs ground truth", comparison.Theirs)
+	}
+	return b.String()
+}
+
+func (a *Application) exportExternalComparison(tool string, external, groundTruth *ImageData, invert bool) {
+	save := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, a.window)
+			return
+		}
+		if writer == nil {
+			return
+		}
+		writer.Close()
+
+		if writer.URI().Scheme() != "file" {
+			dialog.ShowError(fmt.Errorf("the comparison can only be exported to a local file"), a.window)
+			return
+		}
+
+		comparison, err := a.processing.CompareExternalResult(tool, external, groundTruth, invert)
+		if err != nil {
+			dialog.ShowError(err, a.window)
+			return
+		}
+		defer comparison.Close()
+
+		if err := comparison.Export(writer.URI().Path()); err != nil {
+			dialog.ShowError(err, a.window)
+			return
+		}
+		a.statusBar.SetStatus(fmt.Sprintf("Exported comparison with %s", tool))
+	}, a.window)
+	save.SetFileName(fmt.Sprintf("comparison_%s.png", tool))
+	save.Show()
+}
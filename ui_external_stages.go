@@ -0,0 +1,156 @@
+//go:build !nogui
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+const prefAllowExternalStages = "processing.allow_external_stages"
+
+var externalStagePositions = []string{ExternalStagePre, ExternalStagePost}
+
+// describeExternalStages summarizes the stages for the panel label.
+func describeExternalStages(stages []ExternalStage) string {
+	if len(stages) == 0 {
+		return "External stages: none"
+	}
+
+	names := make([]string, len(stages))
+	for i, stage := range stages {
+		names[i] = stage.String()
+	}
+	return "External stages: " + strings.Join(names, ", ")
+}
+
+// showExternalStagesEditor edits the external command stages. Commands are
+// split on whitespace into arguments; no shell is involved.
+func (pp *ParameterPanel) showExternalStagesEditor() {
+	stages := make([]ExternalStage, len(pp.externalStages))
+	for i, stage := range pp.externalStages {
+		stages[i] = stage
+		stages[i].Command = append([]string(nil), stage.Command...)
+	}
+
+	list := container.NewVBox()
+	var rebuild func()
+	rebuild = func() {
+		list.Objects = nil
+
+		if len(stages) == 0 {
+			list.Add(widget.NewLabel("No stages."))
+		}
+
+		for i := range stages {
+			index := i
+
+			nameEntry := widget.NewEntry()
+			nameEntry.SetPlaceHolder("Name")
+			nameEntry.SetText(stages[index].Name)
+			nameEntry.OnChanged = func(value string) {
+				stages[index].Name = value
+			}
+
+			positionSelect := widget.NewSelect(externalStagePositions, func(value string) {
+				stages[index].Position = value
+			})
+			positionSelect.SetSelected(stages[index].Position)
+
+			formatSelect := widget.NewSelect(ExternalStageFormats, func(value string) {
+				stages[index].Format = value
+			})
+			formatSelect.SetSelected(stages[index].Format)
+
+			timeoutEntry := widget.NewEntry()
+			timeoutEntry.SetPlaceHolder("Timeout s")
+			if stages[index].TimeoutSeconds > 0 {
+				timeoutEntry.SetText(strconv.Itoa(stages[index].TimeoutSeconds))
+			}
+			timeoutEntry.OnChanged = func(value string) {
+				if seconds, err := strconv.Atoi(strings.TrimSpace(value)); err == nil {
+					stages[index].TimeoutSeconds = seconds
+				} else if strings.TrimSpace(value) == "" {
+					stages[index].TimeoutSeconds = 0
+				}
+			}
+
+			commandEntry := widget.NewEntry()
+			commandEntry.SetPlaceHolder("magick - -despeckle png:-")
+			commandEntry.SetText(strings.Join(stages[index].Command, " "))
+			commandEntry.OnChanged = func(value string) {
+				stages[index].Command = strings.Fields(value)
+			}
+
+			removeButton := widget.NewButtonWithIcon("", theme.DeleteIcon(), func() {
+				stages = append(stages[:index], stages[index+1:]...)
+				rebuild()
+			})
+
+			list.Add(container.NewVBox(
+				container.NewHBox(
+					widget.NewLabel(fmt.Sprintf("%d.", index+1)),
+					positionSelect,
+					formatSelect,
+					removeButton,
+				),
+				container.NewGridWithColumns(2, nameEntry, timeoutEntry),
+				commandEntry,
+				widget.NewSeparator(),
+			))
+		}
+
+		list.Refresh()
+	}
+	rebuild()
+
+	addButton := widget.NewButtonWithIcon("Add Stage", theme.ContentAddIcon(), func() {
+		if len(stages) >= maxExternalStages {
+			return
+		}
+		stages = append(stages, ExternalStage{Position: ExternalStagePost, Format: "png"})
+		rebuild()
+	})
+
+	allowCheck := widget.NewCheck("Allow running external commands", nil)
+	allowCheck.SetChecked(ExternalStagesAllowed())
+
+	content := container.NewBorder(
+		widget.NewLabel("Each command reads an image on stdin and writes an image of the same size to stdout."),
+		container.NewVBox(addButton, allowCheck), nil, nil,
+		container.NewVScroll(list))
+
+	editor := dialog.NewCustomConfirm("External Stages", "Apply", "Cancel", content, func(apply bool) {
+		if !apply {
+			return
+		}
+
+		fieldErrors := validateParameterFields(&OtsuParameters{ExternalStages: stages})
+		for _, fieldError := range fieldErrors {
+			if strings.HasPrefix(fieldError.Field, "ExternalStages") {
+				dialog.ShowError(fieldError, pp.app.window)
+				return
+			}
+		}
+
+		SetExternalStagesAllowed(allowCheck.Checked)
+		pp.app.fyneApp.Preferences().SetBool(prefAllowExternalStages, allowCheck.Checked)
+
+		pp.setExternalStages(stages)
+		pp.triggerParameterChange()
+	}, pp.app.window)
+	editor.Resize(fyne.NewSize(640, 460))
+	editor.Show()
+}
+
+func (pp *ParameterPanel) setExternalStages(stages []ExternalStage) {
+	pp.externalStages = append([]ExternalStage(nil), stages...)
+	pp.widgets.externalStagesLabel.SetText(describeExternalStages(pp.externalStages))
+}
@@ -1,8 +1,9 @@
+//go:build !nogui
+
 package main
 
 import (
 	"fmt"
-	"image/jpeg"
 	"strings"
 
 	"fyne.io/fyne/v2"
@@ -14,6 +15,11 @@ import (
 
 type FileSaveMenu struct {
 	window fyne.Window
+
+	// metadata supplies what is embedded in every saved image; fileName
+	// the suggested name without extension.
+	metadata func() ImageMetadata
+	fileName func() string
 }
 
 type ImageFormat struct {
@@ -25,11 +31,14 @@ type ImageFormat struct {
 var SupportedFormats = []ImageFormat{
 	{"PNG", ".png", "image/png"},
 	{"JPEG", ".jpg", "image/jpeg"},
+	{"TIFF", ".tif", "image/tiff"},
 }
 
-func NewFileSaveMenu(window fyne.Window) *FileSaveMenu {
+func NewFileSaveMenu(window fyne.Window, metadata func() ImageMetadata, fileName func() string) *FileSaveMenu {
 	return &FileSaveMenu{
-		window: window,
+		window:   window,
+		metadata: metadata,
+		fileName: fileName,
 	}
 }
 
@@ -39,36 +48,19 @@ func (fsm *FileSaveMenu) ShowSaveDialog(imageData *ImageData, callback func(fyne
 		return
 	}
 
-	formatOptions := []string{"PNG", "JPEG"}
+	formatOptions := []string{"PNG", "JPEG", "TIFF"}
 	formatSelect := widget.NewSelect(formatOptions, nil)
-	formatSelect.SetSelected("PNG")
-
-	qualitySlider := widget.NewSlider(1, 100)
-	qualitySlider.SetValue(95)
-	qualitySlider.Hide()
-
-	qualityLabel := widget.NewLabel("Quality: 95")
-	qualityLabel.Hide()
 
-	formatSelect.OnChanged = func(format string) {
-		if format == "JPEG" {
-			qualitySlider.Show()
-			qualityLabel.Show()
-		} else {
-			qualitySlider.Hide()
-			qualityLabel.Hide()
-		}
-	}
-
-	qualitySlider.OnChanged = func(value float64) {
-		qualityLabel.SetText(fmt.Sprintf("Quality: %.0f", value))
-	}
+	// The encoder settings start from the preferences and apply to this
+	// save only.
+	encodeEditor := newEncodeOptionsEditor(CurrentEncodeOptions())
+	formatSelect.OnChanged = encodeEditor.ShowFormat
+	formatSelect.SetSelected("PNG")
 
 	formatContainer := container.NewVBox(
 		widget.NewLabel("Format:"),
 		formatSelect,
-		qualityLabel,
-		qualitySlider,
+		encodeEditor.Container(),
 	)
 
 	customDialog := dialog.NewCustomConfirm(
@@ -78,7 +70,9 @@ func (fsm *FileSaveMenu) ShowSaveDialog(imageData *ImageData, callback func(fyne
 		formatContainer,
 		func(save bool) {
 			if save {
-				fsm.showFileSaveDialogWithFormat(imageData, formatSelect.Selected, int(qualitySlider.Value), callback)
+				fsm.showFileSaveDialogWithFormat(imageData, formatSelect.Selected, encodeEditor.Options(), callback)
+			} else {
+				callback(nil, nil)
 			}
 		},
 		fsm.window,
@@ -87,13 +81,15 @@ func (fsm *FileSaveMenu) ShowSaveDialog(imageData *ImageData, callback func(fyne
 	customDialog.Show()
 }
 
-func (fsm *FileSaveMenu) showFileSaveDialogWithFormat(imageData *ImageData, format string, quality int, callback func(fyne.URIWriteCloser, error)) {
+func (fsm *FileSaveMenu) showFileSaveDialogWithFormat(imageData *ImageData, format string, options EncodeOptions, callback func(fyne.URIWriteCloser, error)) {
 	var extension string
 	switch format {
 	case "JPEG":
 		extension = ".jpg"
 	case "PNG":
 		extension = ".png"
+	case "TIFF":
+		extension = ".tif"
 	default:
 		extension = ".png"
 	}
@@ -114,32 +110,19 @@ func (fsm *FileSaveMenu) showFileSaveDialogWithFormat(imageData *ImageData, form
 			}
 		}
 
-		if format == "JPEG" {
-			fsm.saveAsJPEG(writer, imageData, quality, callback)
-		} else {
-			SaveImageToWriter(writer, imageData)
-			callback(writer, nil)
+		err = saveImageWithOptions(writer, imageData, options, fsm.metadata())
+		writer.Close()
+		if err != nil {
+			callback(nil, err)
+			return
 		}
+		callback(writer, nil)
 	}, fsm.window)
 
-	saveDialog.SetFileName("processed_image" + extension)
+	saveDialog.SetFileName(fsm.fileName() + extension)
 	saveDialog.Show()
 }
 
-func (fsm *FileSaveMenu) saveAsJPEG(writer fyne.URIWriteCloser, imageData *ImageData, quality int, callback func(fyne.URIWriteCloser, error)) {
-	defer writer.Close()
-
-	img := imageData.Image
-	jpegOptions := &jpeg.Options{Quality: quality}
-
-	if err := jpeg.Encode(writer, img, jpegOptions); err != nil {
-		callback(nil, fmt.Errorf("encode JPEG: %w", err))
-		return
-	}
-
-	callback(writer, nil)
-}
-
 func (fsm *FileSaveMenu) GetSupportedFormats() []ImageFormat {
 	return SupportedFormats
 }
@@ -0,0 +1,123 @@
+//go:build !nogui
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+func (a *Application) showFrameSequenceDialog() {
+	inputEntry := widget.NewEntry()
+	inputEntry.SetPlaceHolder("video file, multi-page TIFF, image folder or pattern such as scans/*.png")
+	outputEntry := widget.NewEntry()
+	outputEntry.SetPlaceHolder("output.mp4, output.avi, archive.tif or a folder for PNG frames")
+
+	browseVideo := widget.NewButton("Video...", func() {
+		dialog.ShowFileOpen(func(reader fyne.URIReadCloser, err error) {
+			if err != nil || reader == nil {
+				return
+			}
+			reader.Close()
+			inputEntry.SetText(reader.URI().Path())
+		}, a.window)
+	})
+	browseInputFolder := widget.NewButton("Folder...", func() {
+		dialog.ShowFolderOpen(func(uri fyne.ListableURI, err error) {
+			if err == nil && uri != nil {
+				inputEntry.SetText(uri.Path())
+			}
+		}, a.window)
+	})
+	browseOutputFolder := widget.NewButton("Folder...", func() {
+		dialog.ShowFolderOpen(func(uri fyne.ListableURI, err error) {
+			if err == nil && uri != nil {
+				outputEntry.SetText(uri.Path())
+			}
+		}, a.window)
+	})
+
+	inputRow := container.NewBorder(nil, nil, nil, container.NewHBox(browseVideo, browseInputFolder), inputEntry)
+	outputRow := container.NewBorder(nil, nil, nil, browseOutputFolder, outputEntry)
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("Input", inputRow),
+		widget.NewFormItem("Output", outputRow),
+	}
+
+	form := dialog.NewForm("Binarize Video or Sequence", "Start", "Cancel", items, func(confirmed bool) {
+		if !confirmed {
+			return
+		}
+		if inputEntry.Text == "" || outputEntry.Text == "" {
+			dialog.ShowError(fmt.Errorf("choose both an input and an output"), a.window)
+			return
+		}
+
+		a.runFrameSequence(&FrameSequenceConfig{
+			Input:  inputEntry.Text,
+			Output: outputEntry.Text,
+			Params: a.parameters.GetCurrentParameters(),
+		})
+	}, a.window)
+	form.Resize(fyne.NewSize(640, 0))
+	form.Show()
+}
+
+// runFrameSequence processes config in the background behind a progress
+// dialog whose Cancel button stops the job after the current frame.
+func (a *Application) runFrameSequence(config *FrameSequenceConfig) {
+	ctx, cancel := context.WithCancel(a.ctx)
+
+	progressBar := widget.NewProgressBar()
+	progressLabel := widget.NewLabel("Starting...")
+	progress := dialog.NewCustom("Binarizing Frames", "Cancel",
+		container.NewVBox(progressLabel, progressBar), a.window)
+	progress.SetOnClosed(cancel)
+	progress.Resize(fyne.NewSize(400, 0))
+	progress.Show()
+
+	a.statusBar.StartJob()
+	startTime := time.Now()
+
+	go func() {
+		defer cancel()
+
+		written, err := ProcessFrameSequence(ctx, config, func(done, total int) {
+			fyne.Do(func() {
+				if total > 0 {
+					progressBar.SetValue(float64(done) / float64(total))
+					progressLabel.SetText(fmt.Sprintf("Frame %d of %d", done, total))
+				} else {
+					progressLabel.SetText(fmt.Sprintf("Frame %d", done))
+				}
+			})
+		})
+		duration := time.Since(startTime)
+
+		fyne.Do(func() {
+			cancelled := ctx.Err() == context.Canceled
+			progress.Hide()
+			a.statusBar.StopJob()
+
+			switch {
+			case cancelled:
+				a.statusBar.SetStatus(fmt.Sprintf("Frame sequence cancelled after %d frames", written))
+			case err != nil:
+				dialog.ShowError(err, a.window)
+				a.statusBar.SetStatus("Frame sequence failed")
+			default:
+				message := fmt.Sprintf("%d frames written to %s", written, config.Output)
+				a.statusBar.FinishJob(duration)
+				a.statusBar.SetStatus(message)
+				a.toaster.NotifyJobComplete(message, duration, nil)
+			}
+		})
+	}()
+}
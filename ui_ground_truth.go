@@ -0,0 +1,44 @@
+//go:build !nogui
+
+package main
+
+import (
+	"fmt"
+
+	"fyne.io/fyne/v2/dialog"
+)
+
+// showLoadGroundTruth scores the runs of the loaded image against a chosen
+// reference binarization instead of its grayscale page, reprocessing so the
+// metrics shown follow.
+func (a *Application) showLoadGroundTruth() {
+	if a.processing.GetOriginalImage() == nil {
+		dialog.ShowInformation("Load Ground Truth", "Open the image the ground truth belongs to first.", a.window)
+		return
+	}
+
+	a.openReferenceImage(func(truth *ImageData, name string) {
+		defer truth.Mat.Close()
+		if err := a.processing.SetGroundTruth(truth); err != nil {
+			dialog.ShowError(err, a.window)
+			return
+		}
+		a.statusBar.SetStatus(fmt.Sprintf("Metrics scored against %s", name))
+		a.refreshGroundTruthMetrics()
+	})
+}
+
+func (a *Application) clearGroundTruth() {
+	if !a.processing.HasGroundTruth() {
+		return
+	}
+	a.processing.ClearGroundTruth()
+	a.statusBar.SetStatus("Metrics scored against the grayscale page")
+	a.refreshGroundTruthMetrics()
+}
+
+func (a *Application) refreshGroundTruthMetrics() {
+	if a.processing.GetProcessedImage() != nil {
+		a.toolbar.handleProcessImage()
+	}
+}
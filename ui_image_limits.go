@@ -0,0 +1,129 @@
+//go:build !nogui
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+const prefMaxWorkingMegapixels = "processing.max_megapixels"
+
+func (a *Application) maxWorkingMegapixels() float64 {
+	return a.fyneApp.Preferences().FloatWithFallback(prefMaxWorkingMegapixels, DefaultMaxWorkingMegapixels)
+}
+
+// decodeImage decodes data in the background and calls done on the UI
+// thread. Multi-page TIFF files load the page the user picks. Images over
+// the working limits are only decoded after the user accepts the proposed
+// downscale; done receives nil, nil if they decline.
+func (a *Application) decodeImage(data []byte, extension string, done func(*ImageData, error)) {
+	decode := func(scale float64) {
+		go func() {
+			imageData, err := DecodeImageDataScaled(data, extension, scale)
+			fyne.Do(func() {
+				done(imageData, err)
+			})
+		}()
+	}
+
+	header, err := InspectImageData(data)
+	if err != nil {
+		done(nil, err)
+		return
+	}
+	if header.Pages > 1 {
+		a.choosePage(header.Pages, func(page int) {
+			selected, err := SelectTIFFPage(data, page)
+			if err != nil {
+				done(nil, err)
+				return
+			}
+			a.decodeImage(selected, extension, done)
+		}, func() {
+			a.statusBar.SetStatus("Load cancelled")
+			done(nil, nil)
+		})
+		return
+	}
+
+	scale := WorkingScale(header.Width, header.Height, a.maxWorkingMegapixels())
+	if scale == 1 {
+		decode(1)
+		return
+	}
+
+	message := fmt.Sprintf("This image is %dx%d pixels (%.0f MP), above the working limit.\n\n"+
+		"Downscale it to %dx%d (scale %.3f) for processing?\n"+
+		"The scale factor is recorded in a sidecar file next to saved results.",
+		header.Width, header.Height, float64(header.Width)*float64(header.Height)/1e6,
+		scaledDimension(header.Width, scale), scaledDimension(header.Height, scale), scale)
+
+	dialog.ShowConfirm("Large Image", message, func(accepted bool) {
+		if !accepted {
+			a.statusBar.SetStatus("Load cancelled")
+			done(nil, nil)
+			return
+		}
+		decode(scale)
+	}, a.window)
+}
+
+// choosePage asks which of the pages of a multi-page TIFF file to load.
+func (a *Application) choosePage(pages int, chosen func(page int), cancelled func()) {
+	entry := widget.NewEntry()
+	entry.SetText("1")
+	entry.Validator = func(text string) error {
+		page, err := strconv.Atoi(text)
+		if err != nil || page < 1 || page > pages {
+			return fmt.Errorf("enter a page from 1 to %d", pages)
+		}
+		return nil
+	}
+
+	item := widget.NewFormItem("Page", entry)
+	item.HintText = fmt.Sprintf("The file has %d pages; the frames tool processes all of them", pages)
+	dialog.ShowForm("Multi-Page TIFF", "Load", "Cancel", []*widget.FormItem{item}, func(confirmed bool) {
+		if !confirmed {
+			cancelled()
+			return
+		}
+		page, _ := strconv.Atoi(entry.Text)
+		chosen(page)
+	}, a.window)
+}
+
+// decodeImageAutomatically applies the working limits without asking, for
+// callers such as automation that have no user to prompt.
+func (a *Application) decodeImageAutomatically(data []byte, extension string) (*ImageData, error) {
+	return DecodeImageWithinLimits(data, extension, a.maxWorkingMegapixels())
+}
+
+func (a *Application) showWorkingLimitDialog() {
+	entry := widget.NewEntry()
+	entry.SetText(strconv.FormatFloat(a.maxWorkingMegapixels(), 'f', -1, 64))
+	entry.Validator = func(text string) error {
+		value, err := strconv.ParseFloat(text, 64)
+		if err != nil || value < 0 {
+			return fmt.Errorf("enter a number of megapixels, 0 for no limit")
+		}
+		return nil
+	}
+
+	item := widget.NewFormItem("Max megapixels", entry)
+	item.HintText = "0 disables the megapixel limit; sides are always capped at 32768"
+	items := []*widget.FormItem{item}
+
+	dialog.ShowForm("Working Size Limit", "Save", "Cancel", items, func(confirmed bool) {
+		if !confirmed {
+			return
+		}
+		value, _ := strconv.ParseFloat(entry.Text, 64)
+		a.fyneApp.Preferences().SetFloat(prefMaxWorkingMegapixels, value)
+		a.statusBar.SetStatus(fmt.Sprintf("Working size limit set to %s MP", entry.Text))
+	}, a.window)
+}
@@ -1,11 +1,15 @@
+//go:build !nogui
+
 package main
 
 import (
 	"image"
+	"image/color"
+	"sync/atomic"
 
 	"fyne.io/fyne/v2"
-	"fyne.io/fyne/v2/canvas"
 	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/driver/desktop"
 	"fyne.io/fyne/v2/widget"
 )
 
@@ -17,40 +21,274 @@ func createSectionHeader(text string) *widget.Label {
 
 type ImageViewer struct {
 	splitContainer *container.Split
-	originalImage  *canvas.Image
-	processedImage *canvas.Image
+	originalImage  *previewView
+	processedImage *previewView
+	processedView  *imageTapTarget
+	originalView   *imageTapTarget
+
+	// In comparison mode the previews zoom and pan together. While
+	// blinking, blinkImage shows the original over the processed image
+	// every other tick.
+	processedHeader *widget.Label
+	blinkImage      *previewView
+	comparing       bool
+	blinking        atomic.Bool
+	zoom            float32
+	zoomCenter      fyne.Position
+
+	// minimap and stripOffset navigate tall strips such as receipts;
+	// stripSize is the size of the page they belong to.
+	minimap     *stripMinimap
+	stripOffset float32
+	stripSize   image.Point
+
+	// OnProcessedTapped and OnProcessedDragged receive pointer input on the
+	// processed image in image pixel coordinates; OnProcessedDragEnd ends a
+	// drag. OnProcessedHovered follows the mouse over the image.
+	OnProcessedTapped  func(x, y int)
+	OnProcessedDragged func(from, to image.Point)
+	OnProcessedDragEnd func()
+	OnProcessedHovered func(x, y int)
+}
+
+// imageTapTarget reports taps, drags and hovering on a contained
+// previewView in the pixel coordinates of the image it shows, and draws
+// overlay layers over it. Figures of the overlays take the pointer input
+// that hits them before the callbacks do.
+type imageTapTarget struct {
+	widget.BaseWidget
+	image     *previewView
+	onTapped  func(p image.Point)
+	onDragged func(from, to image.Point)
+	onDragEnd func()
+	onHovered func(p image.Point)
+
+	// overlays stack by z, bottom first. dragItem is the handle being
+	// dragged and dragging says a drag is under way, so a drag that
+	// started off the handles stays with onDragged.
+	overlays []*overlayLayer
+	dragItem *overlayItem
+	dragging bool
+}
+
+func newImageTapTarget(img *previewView) *imageTapTarget {
+	t := &imageTapTarget{image: img}
+	t.ExtendBaseWidget(t)
+	return t
+}
+
+func (t *imageTapTarget) CreateRenderer() fyne.WidgetRenderer {
+	return &imageTapTargetRenderer{target: t}
+}
+
+type imageTapTargetRenderer struct {
+	target *imageTapTarget
+}
+
+func (r *imageTapTargetRenderer) Layout(size fyne.Size) {
+	r.target.image.Move(fyne.NewPos(0, 0))
+	r.target.image.Resize(size)
+	r.target.layoutOverlays()
+}
+
+func (r *imageTapTargetRenderer) MinSize() fyne.Size {
+	return r.target.image.MinSize()
+}
+
+func (r *imageTapTargetRenderer) Refresh() {
+	r.target.layoutOverlays()
+	r.target.image.Refresh()
+}
+
+func (r *imageTapTargetRenderer) Objects() []fyne.CanvasObject {
+	return append([]fyne.CanvasObject{r.target.image}, r.target.overlayObjects()...)
+}
+
+func (r *imageTapTargetRenderer) Destroy() {}
+
+func (t *imageTapTarget) MouseIn(event *desktop.MouseEvent) {
+	t.MouseMoved(event)
+}
+
+func (t *imageTapTarget) MouseMoved(event *desktop.MouseEvent) {
+	p, bounds, ok := t.imagePoint(event.Position)
+	if ok && p.In(bounds) && t.onHovered != nil {
+		t.onHovered(p)
+	}
+}
+
+func (t *imageTapTarget) MouseOut() {}
+
+func (t *imageTapTarget) Tapped(event *fyne.PointEvent) {
+	if item := t.overlayAt(event.Position, func(item *overlayItem) bool { return item.onTapped != nil }); item != nil {
+		item.onTapped()
+		return
+	}
+
+	p, bounds, ok := t.imagePoint(event.Position)
+	if ok && p.In(bounds) && t.onTapped != nil {
+		t.onTapped(p)
+	}
+}
+
+// Dragged moves the handle the drag started on, or reports the segment
+// moved since the previous drag event, clamped to the image so strokes can
+// run off its edges.
+func (t *imageTapTarget) Dragged(event *fyne.DragEvent) {
+	start := event.Position.SubtractXY(event.Dragged.DX, event.Dragged.DY)
+	if !t.dragging {
+		t.dragging = true
+		t.dragItem = t.overlayAt(start, func(item *overlayItem) bool { return item.onDragged != nil })
+	}
+
+	to, bounds, ok := t.imagePoint(event.Position)
+	if !ok {
+		return
+	}
+	if t.dragItem != nil {
+		t.dragItem.from = clampPoint(to, bounds)
+		t.dragItem.onDragged(t.dragItem.from)
+		t.layoutOverlays()
+		return
+	}
+	if t.onDragged == nil {
+		return
+	}
+	from, _, _ := t.imagePoint(start)
+
+	t.onDragged(clampPoint(from, bounds), clampPoint(to, bounds))
+}
+
+func (t *imageTapTarget) DragEnd() {
+	item := t.dragItem
+	t.dragging, t.dragItem = false, nil
+	if item != nil {
+		if item.onDragEnd != nil {
+			item.onDragEnd()
+		}
+		return
+	}
+	if t.onDragEnd != nil {
+		t.onDragEnd()
+	}
+}
+
+func (t *imageTapTarget) imageTransform() (offset fyne.Position, scale float32, bounds image.Rectangle, ok bool) {
+	return t.image.imageTransform()
+}
+
+// imageTransform describes the ImageFillContain scaling and centering: the
+// shown image pixels of bounds appear scaled by scale from offset.
+func (pv *previewView) imageTransform() (offset fyne.Position, scale float32, bounds image.Rectangle, ok bool) {
+	if pv.Image() == nil {
+		return fyne.Position{}, 0, image.Rectangle{}, false
+	}
+
+	bounds = pv.Image().Bounds()
+	size := pv.Size()
+	imageWidth, imageHeight := float32(bounds.Dx()), float32(bounds.Dy())
+	if imageWidth == 0 || imageHeight == 0 || size.Width == 0 || size.Height == 0 {
+		return fyne.Position{}, 0, image.Rectangle{}, false
+	}
+
+	scale = min32(size.Width/imageWidth, size.Height/imageHeight)
+	offset = fyne.NewPos((size.Width-imageWidth*scale)/2, (size.Height-imageHeight*scale)/2)
+	return offset, scale, bounds, true
+}
+
+// imagePoint undoes the ImageFillContain scaling and centering. The point
+// may lie outside the returned image bounds.
+func (t *imageTapTarget) imagePoint(pos fyne.Position) (image.Point, image.Rectangle, bool) {
+	return t.image.imagePoint(pos)
+}
+
+func (pv *previewView) imagePoint(pos fyne.Position) (image.Point, image.Rectangle, bool) {
+	offset, scale, bounds, ok := pv.imageTransform()
+	if !ok {
+		return image.Point{}, image.Rectangle{}, false
+	}
+
+	p := image.Pt(int((pos.X-offset.X)/scale), int((pos.Y-offset.Y)/scale))
+	return p.Add(bounds.Min), bounds, true
+}
+
+func clampPoint(p image.Point, bounds image.Rectangle) image.Point {
+	return image.Pt(
+		max(bounds.Min.X, min(bounds.Max.X-1, p.X)),
+		max(bounds.Min.Y, min(bounds.Max.Y-1, p.Y)),
+	)
 }
 
 func NewImageViewer() *ImageViewer {
-	iv := &ImageViewer{}
+	iv := &ImageViewer{zoom: 1, zoomCenter: fyne.NewPos(0.5, 0.5)}
 	iv.createImages()
 	iv.buildLayout()
 	return iv
 }
 
 func (iv *ImageViewer) createImages() {
-	iv.originalImage = canvas.NewImageFromImage(nil)
-	iv.originalImage.FillMode = canvas.ImageFillContain
-	iv.originalImage.ScaleMode = canvas.ImageScaleSmooth
+	iv.originalImage = newPreviewView()
 	iv.originalImage.SetMinSize(fyne.NewSize(400, 400))
 
-	iv.processedImage = canvas.NewImageFromImage(nil)
-	iv.processedImage.FillMode = canvas.ImageFillContain
-	iv.processedImage.ScaleMode = canvas.ImageScaleSmooth
+	iv.processedImage = newPreviewView()
 	iv.processedImage.SetMinSize(fyne.NewSize(400, 400))
+
+	iv.processedView = newImageTapTarget(iv.processedImage)
+	iv.processedView.onTapped = func(p image.Point) {
+		if iv.OnProcessedTapped != nil {
+			iv.OnProcessedTapped(p.X, p.Y)
+		}
+	}
+	iv.processedView.onDragged = func(from, to image.Point) {
+		if iv.comparing {
+			iv.pan(iv.processedImage, from, to)
+			return
+		}
+		if iv.OnProcessedDragged != nil {
+			iv.OnProcessedDragged(from, to)
+		}
+	}
+	iv.processedView.onDragEnd = func() {
+		if !iv.comparing && iv.OnProcessedDragEnd != nil {
+			iv.OnProcessedDragEnd()
+		}
+	}
+	iv.processedView.onHovered = func(p image.Point) {
+		if iv.OnProcessedHovered != nil {
+			iv.OnProcessedHovered(p.X, p.Y)
+		}
+	}
+
+	iv.originalView = newImageTapTarget(iv.originalImage)
+	iv.originalView.onDragged = func(from, to image.Point) {
+		if iv.comparing {
+			iv.pan(iv.originalImage, from, to)
+		}
+	}
+	iv.blinkImage = newPreviewView()
+	iv.blinkImage.Hide()
+
+	iv.minimap = newStripMinimap()
+	iv.minimap.onMoved = iv.setStripOffset
+	iv.minimap.onScrolled = iv.scrollStrip
+	iv.minimap.Hide()
+	iv.originalImage.onScrolled = iv.scrollStrip
+	iv.processedImage.onScrolled = iv.scrollStrip
+	iv.originalImage.onStripMoved = iv.refreshMinimap
 }
 
 func (iv *ImageViewer) buildLayout() {
 	originalContainer := container.NewBorder(
 		createSectionHeader("Original"),
 		nil, nil, nil,
-		iv.originalImage,
+		iv.originalView,
 	)
 
+	iv.processedHeader = createSectionHeader("Processed")
 	processedContainer := container.NewBorder(
-		createSectionHeader("Processed"),
+		iv.processedHeader,
 		nil, nil, nil,
-		iv.processedImage,
+		container.NewStack(iv.processedView, iv.blinkImage),
 	)
 
 	// Split container handles its own sizing - no wrapper needed
@@ -59,29 +297,56 @@ func (iv *ImageViewer) buildLayout() {
 
 	debugSystem := GetDebugSystem()
 	DebugLogUILayout(debugSystem.logger, "split_container", iv.splitContainer)
-	DebugLogImageSizing(debugSystem.logger, "original_image", iv.originalImage)
-	DebugLogImageSizing(debugSystem.logger, "processed_image", iv.processedImage)
+	DebugLogImageSizing(debugSystem.logger, "original_image", iv.originalImage.display)
+	DebugLogImageSizing(debugSystem.logger, "processed_image", iv.processedImage.display)
 }
 
 func (iv *ImageViewer) SetOriginalImage(img image.Image) {
-	iv.originalImage.Image = img
-	iv.originalImage.Refresh()
+	if imageSize(img) != imageSize(iv.originalImage.source) {
+		iv.setZoom(1, fyne.NewPos(0.5, 0.5))
+	}
+	iv.originalImage.SetImage(img)
+	if iv.Blinking() {
+		iv.blinkImage.SetImage(img)
+	}
+	iv.updateStripMode()
 
 	debugSystem := GetDebugSystem()
-	DebugLogImageSizing(debugSystem.logger, "original_after_set", iv.originalImage)
+	DebugLogImageSizing(debugSystem.logger, "original_after_set", iv.originalImage.display)
 	DebugLogLayoutRefresh(debugSystem.logger, "image_viewer", iv.splitContainer, "original_image_set")
 }
 
 func (iv *ImageViewer) SetProcessedImage(img image.Image) {
-	iv.processedImage.Image = img
-	iv.processedImage.Refresh()
+	iv.processedImage.SetImage(img)
+	iv.updateStripMode()
+	iv.processedView.layoutOverlays()
 
 	debugSystem := GetDebugSystem()
-	DebugLogImageSizing(debugSystem.logger, "processed_after_set", iv.processedImage)
+	DebugLogImageSizing(debugSystem.logger, "processed_after_set", iv.processedImage.display)
 	DebugLogLayoutRefresh(debugSystem.logger, "image_viewer", iv.splitContainer, "processed_image_set")
 }
 
+// ShowProcessedLine draws a line between two pixels of the processed image.
+func (iv *ImageViewer) ShowProcessedLine(from, to image.Point) {
+	iv.SetProcessedOverlay(overlayRuler, overlayZRuler, newOverlayLine(from, to, color.NRGBA{R: 230, G: 40, B: 40, A: 255}))
+}
+
+func (iv *ImageViewer) HideProcessedLine() {
+	iv.ClearProcessedOverlay(overlayRuler)
+}
+
+// SetProcessedOverlay draws items over the processed image as the layer
+// name at z, replacing what that layer drew before. Tools each use their
+// own layer.
+func (iv *ImageViewer) SetProcessedOverlay(name string, z int, items ...*overlayItem) {
+	iv.processedView.setOverlay(name, z, items...)
+}
+
+func (iv *ImageViewer) ClearProcessedOverlay(name string) {
+	iv.processedView.clearOverlay(name)
+}
+
 func (iv *ImageViewer) GetContainer() *fyne.Container {
 	// Use border layout to ensure split container fills available space
-	return container.NewBorder(nil, nil, nil, nil, iv.splitContainer)
+	return container.NewBorder(nil, nil, nil, iv.minimap, iv.splitContainer)
 }
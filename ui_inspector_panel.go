@@ -0,0 +1,269 @@
+//go:build !nogui
+
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+const (
+	loupeRadius = 7
+	loupeZoom   = 10
+)
+
+// InspectorPanel explains the pixel under the mouse in the processed image,
+// can shade the whole result by the rule that labeled each pixel or outline
+// the regions of a region-adaptive run, and shows what the Ruler tool
+// measures.
+type InspectorPanel struct {
+	app       *Application
+	container *fyne.Container
+
+	loupe        *canvas.Image
+	pixelLabel   *widget.Label
+	inputLabel   *widget.Label
+	binsLabel    *widget.Label
+	decisionText *widget.Label
+	rulerLabel   *widget.Label
+
+	decisionCheck  *widget.Check
+	decisionLegend *fyne.Container
+	regionCheck    *widget.Check
+	regionLabel    *widget.Label
+
+	// generation discards decision maps that finish after the result changed.
+	generation int
+}
+
+func NewInspectorPanel(app *Application) *InspectorPanel {
+	ip := &InspectorPanel{
+		app:          app,
+		loupe:        canvas.NewImageFromImage(nil),
+		pixelLabel:   widget.NewLabel("Hover over the processed image"),
+		inputLabel:   widget.NewLabel(""),
+		binsLabel:    widget.NewLabel(""),
+		decisionText: widget.NewLabel(""),
+		rulerLabel:   widget.NewLabel("Drag with the Ruler tool to measure"),
+		regionLabel:  widget.NewLabel(""),
+
+		decisionLegend: container.NewGridWithColumns(2),
+	}
+	ip.decisionCheck = widget.NewCheck("Shade pixels by decision rule", ip.showDecisions)
+	ip.regionCheck = widget.NewCheck("Outline adaptive regions", ip.showRegions)
+	ip.regionLabel.Wrapping = fyne.TextWrapWord
+
+	side := float32((2*loupeRadius + 1) * loupeZoom)
+	ip.loupe.FillMode = canvas.ImageFillOriginal
+	ip.loupe.ScaleMode = canvas.ImageScalePixels
+	ip.loupe.SetMinSize(fyne.NewSize(side, side))
+
+	ip.container = container.NewVBox(
+		createSectionHeader("Inspector"),
+		container.NewHBox(ip.loupe, container.NewVBox(ip.pixelLabel, ip.inputLabel, ip.binsLabel)),
+		ip.decisionText,
+		ip.decisionCheck,
+		ip.decisionLegend,
+		ip.regionCheck,
+		ip.regionLabel,
+		ip.rulerLabel,
+	)
+
+	return ip
+}
+
+// Inspect shows pixel (x, y) of the processed image.
+func (ip *InspectorPanel) Inspect(x, y int) {
+	processed := ip.app.processing.GetProcessedImage()
+	if processed == nil {
+		return
+	}
+	ip.loupe.Image = loupeImage(processed.Image, image.Pt(x, y))
+	ip.loupe.Refresh()
+
+	inspection, err := ip.app.processing.InspectPixel(x, y)
+	if err != nil {
+		ip.pixelLabel.SetText(fmt.Sprintf("(%d, %d)", x, y))
+		ip.inputLabel.SetText("")
+		ip.binsLabel.SetText("")
+		ip.decisionText.SetText(err.Error())
+		return
+	}
+
+	ip.pixelLabel.SetText(fmt.Sprintf("(%d, %d) %s", x, y, pixelLabelName(inspection.Ink)))
+	ip.inputLabel.SetText(fmt.Sprintf("Gray %d, %s %d over %d px", inspection.Gray, strings.ToLower(inspection.FeaturePairing), inspection.Feature, inspection.WindowSize))
+	ip.binsLabel.SetText(fmt.Sprintf("Bins (%d, %d) of %dx%d", inspection.Bins[0], inspection.Bins[1], inspection.HistogramBins[0], inspection.HistogramBins[1]))
+	ip.decisionText.SetText(explainInspection(inspection))
+}
+
+// showDecisions swaps the processed image for the decision overlay, which
+// is computed in the background.
+func (ip *InspectorPanel) showDecisions(show bool) {
+	ip.generation++
+	ip.decisionLegend.Objects = nil
+	ip.decisionLegend.Refresh()
+
+	processed := ip.app.processing.GetProcessedImage()
+	if !show {
+		if processed != nil {
+			ip.app.imageViewer.SetProcessedImage(processed.Image)
+		}
+		return
+	}
+	if processed == nil {
+		ip.app.statusBar.SetStatus("Process an image to see its decisions")
+		ip.decisionCheck.SetChecked(false)
+		return
+	}
+
+	generation := ip.generation
+	ip.app.statusBar.SetStatus("Classifying pixels...")
+	go func() {
+		decisions, err := ip.app.processing.DecisionMap()
+		fyne.Do(func() {
+			if generation != ip.generation {
+				return
+			}
+			if err != nil {
+				ip.app.statusBar.SetStatus(fmt.Sprintf("Decision overlay: %v", err))
+				ip.decisionCheck.SetChecked(false)
+				return
+			}
+			ip.app.imageViewer.SetProcessedImage(decisions.Image())
+			ip.app.statusBar.SetStatus("Showing decision rules")
+			ip.setLegend(decisions)
+		})
+	}()
+}
+
+func (ip *InspectorPanel) setLegend(decisions *DecisionMap) {
+	total := max(1, len(decisions.Decisions))
+	for kind, count := range decisions.Counts {
+		swatch := canvas.NewRectangle(PixelDecision(kind).Color())
+		swatch.SetMinSize(fyne.NewSize(14, 14))
+		ip.decisionLegend.Add(container.NewHBox(container.NewCenter(swatch), widget.NewLabel(PixelDecision(kind).String())))
+		ip.decisionLegend.Add(widget.NewLabel(fmt.Sprintf("%.1f%%", 100*float64(count)/float64(total))))
+	}
+	ip.decisionLegend.Refresh()
+}
+
+// ResultChanged turns the decision overlay off once the result it explains
+// has been replaced or touched up, and redraws the regions of the run that
+// made it.
+func (ip *InspectorPanel) ResultChanged() {
+	if ip.decisionCheck.Checked {
+		ip.decisionCheck.SetChecked(false)
+	} else {
+		ip.generation++
+	}
+	if ip.regionCheck.Checked {
+		ip.showRegions(true)
+	}
+}
+
+// Measure shows the distance between two pixels and draws it.
+func (ip *InspectorPanel) Measure(from, to image.Point) {
+	ip.app.imageViewer.ShowProcessedLine(from, to)
+
+	dx, dy := to.X-from.X, to.Y-from.Y
+	distance := math.Hypot(float64(dx), float64(dy))
+	text := fmt.Sprintf("Ruler: %.1f px (%d x %d)", distance, max(dx, -dx), max(dy, -dy))
+	if perMillimeter, ok := ip.app.processing.PixelsPerMillimeter(); ok {
+		text += fmt.Sprintf(", %.2f mm", distance/perMillimeter)
+	} else {
+		text += ", no resolution for mm"
+	}
+	ip.rulerLabel.SetText(text)
+}
+
+func (ip *InspectorPanel) GetContainer() *fyne.Container {
+	return ip.container
+}
+
+func pixelLabelName(ink bool) string {
+	if ink {
+		return "ink"
+	}
+	return "paper"
+}
+
+// explainInspection states the rule that labeled the pixel. The 2D Otsu
+// method makes a pixel paper only when both its bins exceed the threshold.
+func explainInspection(inspection *PixelInspection) string {
+	var lines []string
+	if inspection.Region != "" {
+		lines = append(lines, "Region: "+strings.ReplaceAll(inspection.Region, "_", " "))
+	}
+
+	threshold := inspection.Threshold
+	if threshold == nil && inspection.Region != "" {
+		lines = append(lines, "Region fallback: no 2D threshold applied")
+	} else if threshold == nil {
+		lines = append(lines, fmt.Sprintf("No single 2D threshold here (%s)", inspection.Method))
+	} else {
+		grayAbove := inspection.Bins[0] > threshold[0]
+		meanAbove := inspection.Bins[1] > threshold[1]
+		switch {
+		case grayAbove && meanAbove:
+			lines = append(lines, fmt.Sprintf("Paper: both bins above threshold (%d, %d)", threshold[0], threshold[1]))
+		case !grayAbove && !meanAbove:
+			lines = append(lines, fmt.Sprintf("Ink: both bins at or below threshold (%d, %d)", threshold[0], threshold[1]))
+		case !grayAbove:
+			lines = append(lines, fmt.Sprintf("Ink: gray bin %d at or below %d", inspection.Bins[0], threshold[0]))
+		default:
+			lines = append(lines, fmt.Sprintf("Ink: %s bin %d at or below %d", strings.ToLower(inspection.FeaturePairing), inspection.Bins[1], threshold[1]))
+		}
+		if inspection.Decision == DecisionUncertain {
+			lines = append(lines, fmt.Sprintf("Uncertain: within %d bin of the threshold", decisionUncertaintyBins))
+		}
+
+		if thresholdInk := !(grayAbove && meanAbove); thresholdInk != inspection.Ink && !inspection.TouchedUp {
+			lines = append(lines, "Flipped by post-processing to "+pixelLabelName(inspection.Ink))
+		}
+	}
+
+	if inspection.TouchedUp {
+		lines = append(lines, "Painted by hand as "+pixelLabelName(inspection.Ink))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// loupeImage magnifies the pixels around center with the center outlined.
+func loupeImage(src image.Image, center image.Point) image.Image {
+	side := (2*loupeRadius + 1) * loupeZoom
+	loupe := image.NewRGBA(image.Rect(0, 0, side, side))
+	draw.Draw(loupe, loupe.Bounds(), image.NewUniform(color.Gray{Y: 128}), image.Point{}, draw.Src)
+
+	bounds := src.Bounds()
+	for dy := -loupeRadius; dy <= loupeRadius; dy++ {
+		for dx := -loupeRadius; dx <= loupeRadius; dx++ {
+			p := center.Add(image.Pt(dx, dy))
+			if !p.In(bounds) {
+				continue
+			}
+			cell := image.Rect(0, 0, loupeZoom, loupeZoom).Add(image.Pt((dx+loupeRadius)*loupeZoom, (dy+loupeRadius)*loupeZoom))
+			draw.Draw(loupe, cell, image.NewUniform(src.At(p.X, p.Y)), image.Point{}, draw.Src)
+		}
+	}
+
+	marker := color.RGBA{R: 230, G: 40, B: 40, A: 255}
+	cell := image.Rect(0, 0, loupeZoom, loupeZoom).Add(image.Pt(loupeRadius*loupeZoom, loupeRadius*loupeZoom))
+	for i := cell.Min.X; i < cell.Max.X; i++ {
+		loupe.Set(i, cell.Min.Y, marker)
+		loupe.Set(i, cell.Max.Y-1, marker)
+	}
+	for i := cell.Min.Y; i < cell.Max.Y; i++ {
+		loupe.Set(cell.Min.X, i, marker)
+		loupe.Set(cell.Max.X-1, i, marker)
+	}
+	return loupe
+}
@@ -0,0 +1,86 @@
+//go:build !nogui
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+var layoutFormats = map[string]string{
+	"PAGE-XML": LayoutPAGE,
+	"ALTO":     LayoutALTO,
+}
+
+// showExportLayout saves the text regions of the current result as a
+// PAGE-XML or ALTO skeleton for OCR.
+func (a *Application) showExportLayout() {
+	processed := a.processing.GetProcessedImage()
+	if processed == nil {
+		dialog.ShowInformation("Export Text Regions", "Process an image first.", a.window)
+		return
+	}
+
+	formatSelect := widget.NewSelect([]string{"PAGE-XML", "ALTO"}, nil)
+	formatSelect.SetSelected("PAGE-XML")
+	imageEntry := widget.NewEntry()
+	imageEntry.SetText(a.outputFileName() + ".png")
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("Format", formatSelect),
+		widget.NewFormItem("Image File", imageEntry),
+	}
+	dialog.ShowForm("Export Text Regions", "Export...", "Cancel", items, func(confirmed bool) {
+		if !confirmed {
+			return
+		}
+
+		imageFile := strings.TrimSpace(imageEntry.Text)
+		format := layoutFormats[formatSelect.Selected]
+		mat := processed.Mat.Clone()
+		result := *processed
+		result.Mat = mat
+		a.statusBar.SetStatus("Finding text regions...")
+		go func() {
+			defer mat.Close()
+			layout, err := DetectTextLayout(&result, imageFile)
+			fyne.Do(func() {
+				if err != nil {
+					dialog.ShowError(err, a.window)
+					a.statusBar.SetStatus("Text region export failed")
+					return
+				}
+				a.saveTextLayout(layout, format)
+			})
+		}()
+	}, a.window)
+}
+
+func (a *Application) saveTextLayout(layout *TextLayout, format string) {
+	save := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, a.window)
+			return
+		}
+		if writer == nil {
+			return
+		}
+		defer writer.Close()
+
+		data, err := layout.Encode(format)
+		if err == nil {
+			_, err = writer.Write(data)
+		}
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("export text regions: %w", err), a.window)
+			return
+		}
+		a.statusBar.SetStatus(fmt.Sprintf("Exported %d text regions to %s", len(layout.Regions), writer.URI().Name()))
+	}, a.window)
+	save.SetFileName(LayoutPath(layout.ImageFile, format))
+	save.Show()
+}
@@ -0,0 +1,278 @@
+//go:build !nogui
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"gocv.io/x/gocv"
+)
+
+const (
+	liveFrameInterval = time.Second / 15
+	// liveSaverFrameInterval paces the plain camera view shown while the
+	// battery saver is on.
+	liveSaverFrameInterval = time.Second / 2
+	liveParamsRefresh      = time.Second
+	liveMaxFailedReads     = 30
+	liveCaptureDeviceCount = 4
+
+	// Requested capture size; drivers fall back to the nearest mode they
+	// support, and the shutter keeps whatever resolution they deliver.
+	liveCaptureWidth  = 3840
+	liveCaptureHeight = 2160
+)
+
+// LiveCapture shows a continuously binarized camera feed in its own window
+// and hands a full-resolution frame to the document window on shutter.
+type LiveCapture struct {
+	app    *Application
+	window fyne.Window
+
+	preview       *canvas.Image
+	deviceSelect  *widget.Select
+	startButton   *widget.Button
+	shutterButton *widget.Button
+	infoLabel     *widget.Label
+
+	frameMutex sync.Mutex
+	lastFrame  gocv.Mat
+	hasFrame   bool
+	closed     bool
+
+	cancel context.CancelFunc
+}
+
+func (a *Application) showLiveCapture() {
+	lc := &LiveCapture{
+		app:       a,
+		window:    a.fyneApp.NewWindow(fmt.Sprintf("%s - Live Capture", AppName)),
+		lastFrame: gocv.NewMat(),
+		infoLabel: widget.NewLabel("Stopped"),
+	}
+
+	lc.preview = canvas.NewImageFromImage(nil)
+	lc.preview.FillMode = canvas.ImageFillContain
+	lc.preview.SetMinSize(fyne.NewSize(640, 480))
+
+	devices := make([]string, liveCaptureDeviceCount)
+	for i := range devices {
+		devices[i] = fmt.Sprintf("Camera %d", i)
+	}
+	lc.deviceSelect = widget.NewSelect(devices, nil)
+	lc.deviceSelect.SetSelectedIndex(0)
+
+	lc.startButton = widget.NewButton("Start", lc.toggle)
+	lc.shutterButton = widget.NewButton("Shutter", lc.shutter)
+	lc.shutterButton.Importance = widget.HighImportance
+	lc.shutterButton.Disable()
+
+	controls := container.NewHBox(lc.deviceSelect, lc.startButton, lc.shutterButton, lc.infoLabel)
+	lc.window.SetContent(container.NewBorder(nil, controls, nil, nil, lc.preview))
+
+	lc.window.SetOnClosed(func() {
+		lc.stop()
+		lc.frameMutex.Lock()
+		lc.closed = true
+		lc.lastFrame.Close()
+		lc.frameMutex.Unlock()
+	})
+
+	lc.window.Show()
+}
+
+func (lc *LiveCapture) toggle() {
+	if lc.cancel != nil {
+		lc.stop()
+		return
+	}
+
+	ctx, cancel := context.WithCancel(lc.app.ctx)
+	lc.cancel = cancel
+	lc.startButton.SetText("Stop")
+	lc.deviceSelect.Disable()
+	lc.infoLabel.SetText("Opening camera...")
+
+	go lc.run(ctx, lc.deviceSelect.SelectedIndex())
+}
+
+func (lc *LiveCapture) stop() {
+	if lc.cancel == nil {
+		return
+	}
+
+	lc.cancel()
+	lc.cancel = nil
+	lc.startButton.SetText("Start")
+	lc.shutterButton.Disable()
+	lc.deviceSelect.Enable()
+	lc.infoLabel.SetText("Stopped")
+}
+
+func (lc *LiveCapture) run(ctx context.Context, device int) {
+	debugSystem := GetDebugSystem()
+
+	webcam, err := gocv.OpenVideoCapture(device)
+	if err != nil || !webcam.IsOpened() {
+		if err == nil {
+			webcam.Close()
+			err = fmt.Errorf("camera %d is not available", device)
+		}
+		fyne.Do(func() {
+			if ctx.Err() == nil {
+				lc.stop()
+				dialog.ShowError(fmt.Errorf("open camera: %w", err), lc.window)
+			}
+		})
+		return
+	}
+	defer webcam.Close()
+
+	webcam.Set(gocv.VideoCaptureFrameWidth, liveCaptureWidth)
+	webcam.Set(gocv.VideoCaptureFrameHeight, liveCaptureHeight)
+
+	frame := gocv.NewMat()
+	defer frame.Close()
+
+	engine := NewProcessingEngine()
+
+	var params *OtsuParameters
+	var paramsLoaded time.Time
+	failedReads := 0
+
+	debugSystem.logger.Info("live capture started", "device", device)
+	defer debugSystem.logger.Info("live capture stopped", "device", device)
+
+	for ctx.Err() == nil {
+		frameStart := time.Now()
+
+		if time.Since(paramsLoaded) > liveParamsRefresh {
+			fyne.DoAndWait(func() {
+				params = lc.app.parameters.GetCurrentParameters()
+			})
+			paramsLoaded = time.Now()
+		}
+
+		if ok := webcam.Read(&frame); !ok || frame.Empty() {
+			failedReads++
+			if failedReads >= liveMaxFailedReads {
+				fyne.Do(func() {
+					if ctx.Err() == nil {
+						lc.stop()
+						dialog.ShowError(fmt.Errorf("camera %d stopped delivering frames", device), lc.window)
+					}
+				})
+				return
+			}
+			time.Sleep(liveFrameInterval)
+			continue
+		}
+		failedReads = 0
+
+		lc.frameMutex.Lock()
+		if !lc.closed {
+			frame.CopyTo(&lc.lastFrame)
+			lc.hasFrame = true
+		}
+		lc.frameMutex.Unlock()
+
+		// The battery saver turns the binarized preview off and shows the
+		// camera as it is, so the page can still be framed.
+		saving := BatterySaverActive()
+		var preview gocv.Mat
+		var err error
+		if saving {
+			preview = cameraView(frame, CurrentPreviewSettings().LiveWidth)
+		} else {
+			preview, err = engine.ProcessPreview(frame, params, CurrentPreviewSettings().LiveWidth)
+		}
+		if err != nil {
+			preview.Close()
+			continue
+		}
+		img, err := preview.ToImage()
+		preview.Close()
+		if err != nil {
+			continue
+		}
+
+		frameWidth, frameHeight := frame.Cols(), frame.Rows()
+		elapsed := time.Since(frameStart)
+		fyne.Do(func() {
+			if ctx.Err() != nil {
+				return
+			}
+			lc.preview.Image = img
+			lc.preview.Refresh()
+			lc.shutterButton.Enable()
+			if saving {
+				lc.infoLabel.SetText(fmt.Sprintf("%dx%d, battery saver: preview off", frameWidth, frameHeight))
+			} else {
+				lc.infoLabel.SetText(fmt.Sprintf("%dx%d, preview %dms", frameWidth, frameHeight, elapsed.Milliseconds()))
+			}
+		})
+
+		interval := liveFrameInterval
+		if saving {
+			interval = liveSaverFrameInterval
+		}
+		if remaining := interval - time.Since(frameStart); remaining > 0 {
+			time.Sleep(remaining)
+		}
+	}
+}
+
+// cameraView scales frame down to maxWidth without processing it.
+func cameraView(frame gocv.Mat, maxWidth int) gocv.Mat {
+	view := gocv.NewMat()
+	if frame.Cols() <= maxWidth {
+		frame.CopyTo(&view)
+		return view
+	}
+	height := frame.Rows() * maxWidth / frame.Cols()
+	gocv.Resize(frame, &view, image.Pt(maxWidth, height), 0, 0, gocv.InterpolationArea)
+	return view
+}
+
+// shutter loads the latest full-resolution frame into the document window
+// and runs the full pipeline on it.
+func (lc *LiveCapture) shutter() {
+	lc.frameMutex.Lock()
+	if !lc.hasFrame || lc.closed {
+		lc.frameMutex.Unlock()
+		return
+	}
+	buffer, err := gocv.IMEncode(gocv.PNGFileExt, lc.lastFrame)
+	lc.frameMutex.Unlock()
+
+	if err != nil {
+		dialog.ShowError(fmt.Errorf("capture frame: %w", err), lc.window)
+		return
+	}
+	data := append([]byte(nil), buffer.GetBytes()...)
+	buffer.Close()
+
+	app := lc.app
+	app.decodeImage(data, ".png", func(imageData *ImageData, err error) {
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("load frame: %w", err), lc.window)
+			return
+		}
+		if imageData == nil {
+			return
+		}
+
+		app.toolbar.applyLoadedImage("capture", imageData)
+		app.statusBar.SetStatus("Frame captured")
+		app.toolbar.handleProcessImage()
+	})
+}
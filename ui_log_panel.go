@@ -0,0 +1,171 @@
+//go:build !nogui
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+const logPanelRefreshInterval = 500 * time.Millisecond
+
+var logPanelLevels = []string{"Debug", "Info", "Warn", "Error"}
+
+// LogPanel tails the captured log, so failures such as a uniform output
+// fallback can be diagnosed without a terminal.
+type LogPanel struct {
+	container *fyne.Container
+
+	levelSelect *widget.Select
+	searchEntry *widget.Entry
+	countLabel  *widget.Label
+	list        *widget.List
+
+	minLevel slog.Level
+	query    string
+	visible  []LogEntry
+	version  uint64
+}
+
+func NewLogPanel() *LogPanel {
+	lp := &LogPanel{
+		searchEntry: widget.NewEntry(),
+		countLabel:  widget.NewLabel(""),
+		minLevel:    slog.LevelInfo,
+	}
+
+	lp.list = widget.NewList(
+		func() int { return len(lp.visible) },
+		func() fyne.CanvasObject {
+			label := widget.NewLabel("")
+			label.Truncation = fyne.TextTruncateEllipsis
+			return label
+		},
+		func(id widget.ListItemID, object fyne.CanvasObject) {
+			label := object.(*widget.Label)
+			entry := lp.visible[id]
+			label.SetText(entry.Text())
+			label.Importance = logLevelImportance(entry.Level)
+			label.Refresh()
+		},
+	)
+	lp.list.OnSelected = func(id widget.ListItemID) {
+		fyne.CurrentApp().Clipboard().SetContent(lp.visible[id].Text())
+		lp.list.UnselectAll()
+	}
+
+	lp.levelSelect = widget.NewSelect(logPanelLevels, func(level string) {
+		lp.minLevel = logLevelFromName(level)
+		lp.refresh(true)
+	})
+	lp.levelSelect.SetSelected("Info")
+
+	lp.searchEntry.SetPlaceHolder("Search")
+	lp.searchEntry.OnChanged = func(query string) {
+		lp.query = strings.ToLower(query)
+		lp.refresh(true)
+	}
+
+	clearButton := widget.NewButton("Clear", func() {
+		GetLogBuffer().Clear()
+		lp.refresh(true)
+	})
+
+	lp.container = container.NewVBox(
+		createSectionHeader("Log"),
+		container.NewBorder(nil, nil, lp.levelSelect, clearButton, lp.searchEntry),
+		container.NewGridWrap(fyne.NewSize(520, 260), lp.list),
+		lp.countLabel,
+	)
+
+	return lp
+}
+
+// Run refreshes the panel when new records arrive, until ctx is done.
+func (lp *LogPanel) Run(ctx context.Context) {
+	ticker := time.NewTicker(logPanelRefreshInterval)
+	defer ticker.Stop()
+
+	buffer := GetLogBuffer()
+	var seen uint64
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			version := buffer.Version()
+			if version == seen {
+				continue
+			}
+			seen = version
+			fyne.Do(func() { lp.refresh(false) })
+		}
+	}
+}
+
+// refresh filters the buffered records and scrolls to the newest one.
+// force refilters even when no record arrived since the last refresh.
+func (lp *LogPanel) refresh(force bool) {
+	entries, version := GetLogBuffer().Entries()
+	if !force && version == lp.version {
+		return
+	}
+	lp.version = version
+
+	lp.visible = lp.visible[:0]
+	for _, entry := range entries {
+		if entry.Level < lp.minLevel {
+			continue
+		}
+		if lp.query != "" && !strings.Contains(strings.ToLower(entry.Text()), lp.query) {
+			continue
+		}
+		lp.visible = append(lp.visible, entry)
+	}
+
+	lp.countLabel.SetText(formatLogCount(len(lp.visible), len(entries)))
+	lp.list.Refresh()
+	lp.list.ScrollToBottom()
+}
+
+func formatLogCount(shown, total int) string {
+	if shown == total {
+		return fmt.Sprintf("%d records", total)
+	}
+	return fmt.Sprintf("%d of %d records shown", shown, total)
+}
+
+func logLevelFromName(name string) slog.Level {
+	switch name {
+	case "Debug":
+		return slog.LevelDebug
+	case "Warn":
+		return slog.LevelWarn
+	case "Error":
+		return slog.LevelError
+	}
+	return slog.LevelInfo
+}
+
+func logLevelImportance(level slog.Level) widget.Importance {
+	switch {
+	case level >= slog.LevelError:
+		return widget.DangerImportance
+	case level >= slog.LevelWarn:
+		return widget.WarningImportance
+	case level < slog.LevelInfo:
+		return widget.LowImportance
+	}
+	return widget.MediumImportance
+}
+
+func (lp *LogPanel) GetContainer() *fyne.Container {
+	return lp.container
+}
@@ -0,0 +1,83 @@
+//go:build !nogui
+
+package main
+
+import (
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+// Creator and rights usually stay the same for a whole project, so they
+// are remembered across sessions; title and description belong to one image.
+const (
+	prefMetadataCreator = "metadata.creator"
+	prefMetadataRights  = "metadata.rights"
+)
+
+// MetadataPanel edits the descriptive metadata written into saved outputs.
+type MetadataPanel struct {
+	app       *Application
+	container *fyne.Container
+
+	titleEntry       *widget.Entry
+	creatorEntry     *widget.Entry
+	rightsEntry      *widget.Entry
+	descriptionEntry *widget.Entry
+}
+
+func NewMetadataPanel(app *Application) *MetadataPanel {
+	mp := &MetadataPanel{
+		app:              app,
+		titleEntry:       widget.NewEntry(),
+		creatorEntry:     widget.NewEntry(),
+		rightsEntry:      widget.NewEntry(),
+		descriptionEntry: widget.NewMultiLineEntry(),
+	}
+
+	preferences := app.fyneApp.Preferences()
+	mp.creatorEntry.SetText(preferences.String(prefMetadataCreator))
+	mp.creatorEntry.OnChanged = func(text string) {
+		preferences.SetString(prefMetadataCreator, text)
+	}
+	mp.rightsEntry.SetText(preferences.String(prefMetadataRights))
+	mp.rightsEntry.OnChanged = func(text string) {
+		preferences.SetString(prefMetadataRights, text)
+	}
+
+	mp.titleEntry.SetPlaceHolder("Title of the image")
+	mp.creatorEntry.SetPlaceHolder("Person or institution")
+	mp.rightsEntry.SetPlaceHolder("e.g. CC BY 4.0")
+	mp.descriptionEntry.SetPlaceHolder("Description")
+	mp.descriptionEntry.Wrapping = fyne.TextWrapWord
+	mp.descriptionEntry.SetMinRowsVisible(3)
+
+	mp.container = container.NewVBox(
+		createSectionHeader("Metadata"),
+		widget.NewForm(
+			widget.NewFormItem("Title", mp.titleEntry),
+			widget.NewFormItem("Creator", mp.creatorEntry),
+			widget.NewFormItem("Rights", mp.rightsEntry),
+		),
+		mp.descriptionEntry,
+		widget.NewLabel("Written as XMP and IPTC when saving"),
+	)
+
+	return mp
+}
+
+// Metadata returns what saving writes into the output.
+func (mp *MetadataPanel) Metadata() ImageMetadata {
+	return ImageMetadata{
+		Title:       strings.TrimSpace(mp.titleEntry.Text),
+		Creator:     strings.TrimSpace(mp.creatorEntry.Text),
+		Rights:      strings.TrimSpace(mp.rightsEntry.Text),
+		Description: strings.TrimSpace(mp.descriptionEntry.Text),
+	}
+}
+
+func (mp *MetadataPanel) GetContainer() *fyne.Container {
+	return mp.container
+}
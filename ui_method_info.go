@@ -0,0 +1,27 @@
+//go:build !nogui
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// updateMethodInfo shows the formula, parameters and citation of the
+// selected processing method.
+func (pp *ParameterPanel) updateMethodInfo() {
+	pp.widgets.methodInfoText.ParseMarkdown(methodInfoMarkdown(MethodInfoFor(pp.GetCurrentParameters())))
+}
+
+func methodInfoMarkdown(info MethodInfo) string {
+	var text strings.Builder
+	fmt.Fprintf(&text, "**%s**\n\n%s\n\n```\n%s\n```\n\n", info.Name, info.Summary, info.Formula)
+	if len(info.Parameters) > 0 {
+		for _, parameter := range info.Parameters {
+			fmt.Fprintf(&text, "- `%s`: %s\n", parameter.Field, parameter.Meaning)
+		}
+		text.WriteString("\n")
+	}
+	fmt.Fprintf(&text, "*%s*\n", info.Citation)
+	return text.String()
+}
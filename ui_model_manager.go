@@ -0,0 +1,271 @@
+//go:build !nogui
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+const prefModelRegistryURL = "models.registry_url"
+
+// modelListing is one row of the model manager: a downloaded model, a
+// registry model, or both when the registry offers a model already here.
+type modelListing struct {
+	Name   string
+	Local  *LocalModel
+	Remote *ModelRegistryEntry
+}
+
+func (ml modelListing) status(activePath string) string {
+	var parts []string
+	switch {
+	case ml.Local != nil && ml.Local.Path == activePath:
+		parts = append(parts, "active in this window")
+	case ml.Local != nil:
+		parts = append(parts, "downloaded")
+	default:
+		parts = append(parts, "available")
+	}
+	switch {
+	case ml.Local != nil:
+		parts = append(parts, formatBytes(ml.Local.Size))
+	case ml.Remote.Size > 0:
+		parts = append(parts, formatBytes(ml.Remote.Size))
+	}
+	if ml.Remote != nil && ml.Remote.Description != "" {
+		parts = append(parts, ml.Remote.Description)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func mergeModelListings(local []LocalModel, registry *ModelRegistry) []modelListing {
+	byName := make(map[string]*modelListing)
+	for i := range local {
+		byName[local[i].Name] = &modelListing{Name: local[i].Name, Local: &local[i]}
+	}
+	if registry != nil {
+		for i := range registry.Models {
+			entry := &registry.Models[i]
+			if listing, ok := byName[entry.Name]; ok {
+				listing.Remote = entry
+				continue
+			}
+			byName[entry.Name] = &modelListing{Name: entry.Name, Remote: entry}
+		}
+	}
+
+	listings := make([]modelListing, 0, len(byName))
+	for _, listing := range byName {
+		listings = append(listings, *listing)
+	}
+	sort.Slice(listings, func(i, j int) bool { return listings[i].Name < listings[j].Name })
+	return listings
+}
+
+// showModelManager lists the downloaded models and those of the registry,
+// downloads them into the cache and makes one the model of this window's
+// neural method. Every window keeps its own active model.
+func (a *Application) showModelManager() {
+	preferences := a.fyneApp.Preferences()
+
+	registryEntry := widget.NewEntry()
+	registryEntry.SetPlaceHolder("https://example.org/models/index.json")
+	registryEntry.SetText(preferences.StringWithFallback(prefModelRegistryURL, DefaultModelRegistryURL()))
+
+	var (
+		listings []modelListing
+		registry *ModelRegistry
+		selected = -1
+	)
+
+	statusLabel := widget.NewLabel("")
+	statusLabel.Wrapping = fyne.TextWrapWord
+	progressBar := widget.NewProgressBar()
+	progressBar.Hide()
+
+	list := widget.NewList(
+		func() int { return len(listings) },
+		func() fyne.CanvasObject {
+			return container.NewVBox(widget.NewLabelWithStyle("", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}), widget.NewLabel(""))
+		},
+		func(id widget.ListItemID, item fyne.CanvasObject) {
+			rows := item.(*fyne.Container).Objects
+			rows[0].(*widget.Label).SetText(listings[id].Name)
+			rows[1].(*widget.Label).SetText(listings[id].status(a.parameters.neuralModel))
+		},
+	)
+
+	useButton := widget.NewButton("Use in This Window", nil)
+	downloadButton := widget.NewButton("Download", nil)
+	removeButton := widget.NewButton("Remove", nil)
+	refreshButton := widget.NewButton("Refresh", nil)
+
+	updateButtons := func() {
+		useButton.Disable()
+		downloadButton.Disable()
+		removeButton.Disable()
+		if selected < 0 || selected >= len(listings) {
+			return
+		}
+		listing := listings[selected]
+		if listing.Local != nil {
+			useButton.Enable()
+			removeButton.Enable()
+		}
+		if listing.Remote != nil {
+			downloadButton.SetText("Download")
+			if listing.Local != nil {
+				downloadButton.SetText("Download Again")
+			}
+			downloadButton.Enable()
+		}
+	}
+
+	showListings := func() {
+		local, err := LocalModels()
+		if err != nil {
+			statusLabel.SetText(err.Error())
+		}
+		listings = mergeModelListings(local, registry)
+		selected = -1
+		list.UnselectAll()
+		list.Refresh()
+		updateButtons()
+	}
+
+	refresh := func() {
+		location := strings.TrimSpace(registryEntry.Text)
+		if location == DefaultModelRegistryURL() {
+			preferences.RemoveValue(prefModelRegistryURL)
+		} else {
+			preferences.SetString(prefModelRegistryURL, location)
+		}
+		registry = nil
+		if location == "" {
+			statusLabel.SetText("No registry set; showing downloaded models")
+			showListings()
+			return
+		}
+
+		refreshButton.Disable()
+		statusLabel.SetText("Reading registry...")
+		go func() {
+			fetched, err := FetchModelRegistry(a.ctx, location)
+			fyne.Do(func() {
+				refreshButton.Enable()
+				if err != nil {
+					statusLabel.SetText(err.Error())
+					a.debugSystem.logger.Warn("model registry unavailable", "url", location, "error", err.Error())
+				} else {
+					registry = fetched
+					statusLabel.SetText(fmt.Sprintf("%d models in the registry", len(fetched.Models)))
+				}
+				showListings()
+			})
+		}()
+	}
+
+	var cancelDownload context.CancelFunc
+	downloadButton.OnTapped = func() {
+		entry := *listings[selected].Remote
+		ctx, cancel := context.WithCancel(a.ctx)
+		cancelDownload = cancel
+
+		downloadButton.Disable()
+		refreshButton.Disable()
+		progressBar.SetValue(0)
+		progressBar.Show()
+		statusLabel.SetText(fmt.Sprintf("Downloading %s...", entry.Name))
+
+		go func() {
+			shownPercent := 0
+			path, err := registry.Download(ctx, entry, func(read, size int64) {
+				if size <= 0 || int(100*read/size) == shownPercent {
+					return
+				}
+				shownPercent = int(100 * read / size)
+				fyne.Do(func() { progressBar.SetValue(float64(read) / float64(size)) })
+			})
+			cancel()
+			fyne.Do(func() {
+				cancelDownload = nil
+				progressBar.Hide()
+				refreshButton.Enable()
+				if err != nil {
+					statusLabel.SetText(err.Error())
+					dialog.ShowError(err, a.window)
+				} else {
+					statusLabel.SetText(fmt.Sprintf("Downloaded %s to %s", entry.Name, path))
+				}
+				showListings()
+			})
+		}()
+	}
+
+	useButton.OnTapped = func() {
+		listing := listings[selected]
+		if _, err := LoadNeuralModelConfig(listing.Local.Path); err != nil {
+			dialog.ShowError(err, a.window)
+			return
+		}
+		a.parameters.setNeuralModel(listing.Local.Path)
+		a.parameters.widgets.processingMethodSelect.SetSelected(neuralMethodName)
+		a.parameters.triggerParameterChange()
+		a.statusBar.SetStatus(fmt.Sprintf("Neural model %s selected", listing.Name))
+		list.Refresh()
+	}
+
+	removeButton.OnTapped = func() {
+		listing := listings[selected]
+		dialog.ShowConfirm("Remove Model", fmt.Sprintf("Delete the downloaded model %s?", listing.Name), func(remove bool) {
+			if !remove {
+				return
+			}
+			if err := RemoveModel(listing.Name); err != nil {
+				dialog.ShowError(err, a.window)
+				return
+			}
+			statusLabel.SetText(fmt.Sprintf("Removed %s", listing.Name))
+			showListings()
+		}, a.window)
+	}
+
+	refreshButton.OnTapped = refresh
+	list.OnSelected = func(id widget.ListItemID) {
+		selected = id
+		updateButtons()
+	}
+
+	folderLabel := widget.NewLabel(ModelDir())
+	folderLabel.Wrapping = fyne.TextWrapBreak
+
+	content := container.NewBorder(
+		container.NewVBox(
+			widget.NewForm(
+				widget.NewFormItem("Registry", container.NewBorder(nil, nil, nil, refreshButton, registryEntry)),
+				widget.NewFormItem("Model folder", folderLabel),
+			),
+		),
+		container.NewVBox(progressBar, statusLabel, container.NewHBox(useButton, downloadButton, removeButton)),
+		nil, nil,
+		list,
+	)
+
+	manager := dialog.NewCustom("Models", "Close", content, a.window)
+	manager.SetOnClosed(func() {
+		if cancelDownload != nil {
+			cancelDownload()
+		}
+	})
+	manager.Resize(fyne.NewSize(600, 480))
+	manager.Show()
+	refresh()
+}
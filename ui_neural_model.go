@@ -0,0 +1,70 @@
+//go:build !nogui
+
+package main
+
+import (
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/widget"
+)
+
+// neuralMethodName is the processing method entry of the neural backend.
+const neuralMethodName = "Neural Network (ONNX)"
+
+func describeNeuralModel(path string) string {
+	if path == "" {
+		return "Model: none"
+	}
+	return "Model: " + neuralModelName(path)
+}
+
+func (pp *ParameterPanel) buildNeuralModelControls() fyne.CanvasObject {
+	pp.widgets.chooseModelButton = widget.NewButton("Choose Model...", pp.showNeuralModelPicker)
+	manageButton := widget.NewButton("Manage Models...", pp.app.showModelManager)
+	return container.NewVBox(
+		pp.widgets.neuralModelLabel,
+		container.NewGridWithColumns(2, pp.widgets.chooseModelButton, manageButton),
+		container.NewVBox(pp.widgets.neuralThresholdLabel, pp.widgets.neuralThresholdSlider),
+	)
+}
+
+func (pp *ParameterPanel) setNeuralModel(path string) {
+	pp.neuralModel = path
+	pp.widgets.neuralModelLabel.SetText(describeNeuralModel(path))
+}
+
+// showNeuralModelPicker selects an ONNX model and switches to the neural
+// method so the choice takes effect right away.
+func (pp *ParameterPanel) showNeuralModelPicker() {
+	picker := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, pp.app.window)
+			return
+		}
+		if reader == nil {
+			return
+		}
+		reader.Close()
+
+		if reader.URI().Scheme() != "file" {
+			dialog.ShowError(fmt.Errorf("models can only be loaded from a local file"), pp.app.window)
+			return
+		}
+		path := reader.URI().Path()
+		if _, err := LoadNeuralModelConfig(path); err != nil {
+			dialog.ShowError(err, pp.app.window)
+			return
+		}
+
+		pp.setNeuralModel(path)
+		pp.widgets.processingMethodSelect.SetSelected(neuralMethodName)
+		pp.app.statusBar.SetStatus(fmt.Sprintf("Neural model %s selected", neuralModelName(path)))
+		pp.triggerParameterChange()
+	}, pp.app.window)
+	picker.SetFilter(storage.NewExtensionFileFilter([]string{".onnx"}))
+	picker.Show()
+}
@@ -0,0 +1,209 @@
+//go:build !nogui
+
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/widget"
+)
+
+// Operators and reviewers sign their notes with the name they last used;
+// whether reports show notes is remembered too.
+const (
+	prefNotesAuthor    = "notes.author"
+	prefNotesOnReports = "notes.on_reports"
+)
+
+var notePinColor = color.NRGBA{R: 240, G: 160, B: 0, A: 255}
+
+// NotesPanel lists the notes pinned to the loaded image. The Note tool of
+// the Touch-Up panel pins new ones; the pins on the processed image can be
+// tapped to edit a note and dragged to move it.
+type NotesPanel struct {
+	app       *Application
+	container *fyne.Container
+
+	authorEntry *widget.Entry
+	reportCheck *widget.Check
+	list        *fyne.Container
+	emptyLabel  *widget.Label
+}
+
+func NewNotesPanel(app *Application) *NotesPanel {
+	np := &NotesPanel{
+		app:         app,
+		authorEntry: widget.NewEntry(),
+		list:        container.NewVBox(),
+		emptyLabel:  widget.NewLabel("Pick the Note tool and tap the result to pin a note"),
+	}
+	np.emptyLabel.Wrapping = fyne.TextWrapWord
+
+	preferences := app.fyneApp.Preferences()
+	np.authorEntry.SetPlaceHolder("Your name")
+	np.authorEntry.SetText(preferences.String(prefNotesAuthor))
+	np.authorEntry.OnChanged = func(text string) {
+		preferences.SetString(prefNotesAuthor, text)
+	}
+	np.reportCheck = widget.NewCheck("Show notes on reports", func(checked bool) {
+		preferences.SetBool(prefNotesOnReports, checked)
+	})
+	np.reportCheck.SetChecked(preferences.BoolWithFallback(prefNotesOnReports, true))
+
+	np.container = container.NewVBox(
+		createSectionHeader("Notes"),
+		widget.NewForm(widget.NewFormItem("Author", np.authorEntry)),
+		np.list,
+		np.emptyLabel,
+		np.reportCheck,
+		widget.NewButton("Import from Sidecar...", np.importNotes),
+	)
+	return np
+}
+
+// OnReports reports whether QA reports should list and pin the notes.
+func (np *NotesPanel) OnReports() bool {
+	return np.reportCheck.Checked
+}
+
+// AddNoteAt asks for the text of a note and pins it to pixel (x, y).
+func (np *NotesPanel) AddNoteAt(x, y int) {
+	entry := widget.NewMultiLineEntry()
+	entry.Wrapping = fyne.TextWrapWord
+	entry.SetPlaceHolder("e.g. faint stamp here, check after tuning")
+	entry.SetMinRowsVisible(3)
+
+	title := fmt.Sprintf("Note at (%d, %d)", x, y)
+	dialog.ShowForm(title, "Pin", "Cancel", []*widget.FormItem{widget.NewFormItem("Text", entry)}, func(confirmed bool) {
+		if !confirmed {
+			return
+		}
+		if _, err := np.app.processing.AddAnnotation(image.Pt(x, y), entry.Text, np.authorEntry.Text); err != nil {
+			dialog.ShowError(err, np.app.window)
+			return
+		}
+		np.Refresh()
+	}, np.app.window)
+}
+
+// editNote shows note with buttons to change its text or delete it.
+func (np *NotesPanel) editNote(note Annotation) {
+	entry := widget.NewMultiLineEntry()
+	entry.Wrapping = fyne.TextWrapWord
+	entry.SetText(note.Text)
+	entry.SetMinRowsVisible(3)
+
+	caption := fmt.Sprintf("At (%d, %d), %s", note.X, note.Y, note.Created.Local().Format("2006-01-02 15:04"))
+	if note.Author != "" {
+		caption += " by " + note.Author
+	}
+
+	var d *dialog.CustomDialog
+	save := widget.NewButton("Save", func() {
+		if err := np.app.processing.UpdateAnnotation(note.ID, entry.Text); err != nil {
+			dialog.ShowError(err, np.app.window)
+			return
+		}
+		d.Hide()
+		np.Refresh()
+	})
+	save.Importance = widget.HighImportance
+	remove := widget.NewButton("Delete", func() {
+		np.app.processing.RemoveAnnotation(note.ID)
+		d.Hide()
+		np.Refresh()
+	})
+	remove.Importance = widget.DangerImportance
+
+	d = dialog.NewCustomWithoutButtons(fmt.Sprintf("Note %d", note.ID),
+		container.NewBorder(widget.NewLabel(caption), nil, nil, nil, entry), np.app.window)
+	d.SetButtons([]fyne.CanvasObject{remove, widget.NewButton("Cancel", func() { d.Hide() }), save})
+	d.Resize(fyne.NewSize(420, 240))
+	d.Show()
+}
+
+// importNotes adds the notes of a saved output's sidecar, so a reviewer
+// who opens the source sees what the operator pinned.
+func (np *NotesPanel) importNotes() {
+	original := np.app.processing.GetOriginalImage()
+	if original == nil {
+		dialog.ShowInformation("Import Notes", "Load the image the notes belong to first.", np.app.window)
+		return
+	}
+
+	picker := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, np.app.window)
+			return
+		}
+		if reader == nil {
+			return
+		}
+		reader.Close()
+		if reader.URI().Scheme() != "file" {
+			dialog.ShowError(fmt.Errorf("notes can only be imported from a local file"), np.app.window)
+			return
+		}
+
+		notes, err := ReadSidecarAnnotations(reader.URI().Path(), original.Width, original.Height)
+		if err == nil {
+			var added int
+			if added, err = np.app.processing.ImportAnnotations(notes); err == nil {
+				np.app.statusBar.SetStatus(fmt.Sprintf("Imported %d notes from %s", added, reader.URI().Name()))
+			}
+		}
+		if err != nil {
+			dialog.ShowError(err, np.app.window)
+		}
+		np.Refresh()
+	}, np.app.window)
+	picker.SetFilter(storage.NewExtensionFileFilter([]string{".json"}))
+	picker.Show()
+}
+
+// Refresh lists the notes of the loaded image and redraws their pins.
+func (np *NotesPanel) Refresh() {
+	notes := np.app.processing.Annotations()
+
+	np.list.Objects = nil
+	var pins []*overlayItem
+	for _, note := range notes {
+		label := fmt.Sprintf("%d. %s", note.ID, note.Text)
+		if len([]rune(label)) > 40 {
+			label = string([]rune(label)[:39]) + "…"
+		}
+		button := widget.NewButton(label, func() { np.editNote(note) })
+		button.Alignment = widget.ButtonAlignLeading
+		np.list.Add(button)
+
+		moved := image.Pt(note.X, note.Y)
+		pin := newOverlayHandle(moved, notePinColor, func(p image.Point) { moved = p })
+		pin.onTapped = func() { np.editNote(note) }
+		pin.onDragEnd = func() {
+			if err := np.app.processing.MoveAnnotation(note.ID, moved); err != nil {
+				np.app.statusBar.SetStatus(err.Error())
+			}
+			np.Refresh()
+		}
+		pins = append(pins, pin)
+	}
+	np.list.Refresh()
+
+	if len(notes) == 0 {
+		np.emptyLabel.Show()
+		np.app.imageViewer.ClearProcessedOverlay(overlayNotes)
+	} else {
+		np.emptyLabel.Hide()
+		np.app.imageViewer.SetProcessedOverlay(overlayNotes, overlayZNotes, pins...)
+	}
+}
+
+func (np *NotesPanel) GetContainer() *fyne.Container {
+	return np.container
+}
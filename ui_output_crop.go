@@ -0,0 +1,60 @@
+//go:build !nogui
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"fyne.io/fyne/v2/widget"
+)
+
+const (
+	prefAutoCrop   = "output.auto_crop"
+	prefCropMargin = "output.crop_margin"
+)
+
+// croppedForSave returns the result to save, trimmed to its content when
+// the preferences ask for it, with the crop applied and a release func to
+// call once the result has been written.
+func (a *Application) croppedForSave(processed *ImageData) (*ImageData, *OutputCrop, func(), error) {
+	preferences := a.fyneApp.Preferences()
+	if !preferences.BoolWithFallback(prefAutoCrop, false) {
+		return processed, nil, func() {}, nil
+	}
+
+	options := CropOptions{Margin: preferences.IntWithFallback(prefCropMargin, DefaultCropMargin)}
+	cropped, crop, err := CropToContent(processed, options)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if crop == nil {
+		return processed, nil, func() {}, nil
+	}
+	return cropped, crop, func() { cropped.Mat.Close() }, nil
+}
+
+// outputSidecar is the sidecar of a saved result, with crop recorded.
+func (a *Application) outputSidecar(crop *OutputCrop) *OutputSidecar {
+	return withCrop(a.processing.OutputSidecar(""), "", a.processing.GetOriginalImage(), crop)
+}
+
+func (a *Application) autoCropPreferenceItems() (*widget.Check, *widget.FormItem, *widget.Entry) {
+	preferences := a.fyneApp.Preferences()
+	check := widget.NewCheck("Crop saved results to their content", nil)
+	check.SetChecked(preferences.BoolWithFallback(prefAutoCrop, false))
+
+	entry := widget.NewEntry()
+	entry.SetText(strconv.Itoa(preferences.IntWithFallback(prefCropMargin, DefaultCropMargin)))
+	entry.Validator = func(text string) error {
+		value, err := strconv.Atoi(text)
+		if err != nil || value < 0 {
+			return fmt.Errorf("enter a margin in pixels of at least 0")
+		}
+		return nil
+	}
+
+	item := widget.NewFormItem("Crop margin (px)", entry)
+	item.HintText = "Kept around the content when saved results are cropped"
+	return check, item, entry
+}
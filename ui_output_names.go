@@ -0,0 +1,62 @@
+//go:build !nogui
+
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2/widget"
+)
+
+const prefOutputNameTemplate = "output.name_template"
+
+// outputFileName renders the output name template of the preferences for
+// the loaded image and its latest result.
+func (a *Application) outputFileName() string {
+	template := a.fyneApp.Preferences().StringWithFallback(prefOutputNameTemplate, DefaultOutputNameTemplate)
+	if ValidateOutputNameTemplate(template) != nil {
+		template = DefaultOutputNameTemplate
+	}
+
+	name := strings.TrimSuffix(filepath.Base(a.sourceName), filepath.Ext(a.sourceName))
+	if a.sourceName == "" {
+		name = "processed_image"
+	}
+
+	params := a.parameters.GetCurrentParameters()
+	fields := OutputNameFields{
+		Name:      name,
+		Algorithm: methodAlgorithm(params),
+		Preset:    a.outputPreset(params),
+		Date:      time.Now(),
+	}
+	if metrics := a.processing.GetProcessedMetrics(); metrics != nil {
+		fmeasure := metrics.FMeasure()
+		fields.FMeasure = &fmeasure
+	}
+	return RenderOutputName(template, fields)
+}
+
+// outputPreset names params for {preset}: the applied profile while its
+// parameters are unchanged, otherwise "default" or "custom".
+func (a *Application) outputPreset(params *OtsuParameters) string {
+	switch {
+	case a.appliedProfile != nil && len(diffParameters(a.appliedProfile.Params, params)) == 0:
+		return a.appliedProfile.Name
+	case len(diffParameters(DefaultOtsuParameters(), params)) == 0:
+		return "default"
+	}
+	return "custom"
+}
+
+func (a *Application) outputNameTemplateItem() (*widget.FormItem, *widget.Entry) {
+	entry := widget.NewEntry()
+	entry.SetText(a.fyneApp.Preferences().StringWithFallback(prefOutputNameTemplate, DefaultOutputNameTemplate))
+	entry.Validator = ValidateOutputNameTemplate
+
+	item := widget.NewFormItem("Output file name", entry)
+	item.HintText = "Tokens: {name} {algorithm} {preset} {date} {fmeasure}"
+	return item, entry
+}
@@ -0,0 +1,239 @@
+//go:build !nogui
+
+package main
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"sort"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+)
+
+const (
+	// overlayHitSlop is how far, in Fyne units, the pointer may miss a
+	// thin figure and still hit it; overlayHandleSize is the diameter of
+	// drag handles.
+	overlayHitSlop    = 4
+	overlayHandleSize = 12
+)
+
+// Overlay layer names and their z order; higher layers draw on top and
+// take pointer input first.
+const (
+	overlayRegions  = "regions"
+	overlayZRegions = 10
+	overlayTouchUp  = "touch_up"
+	overlayZTouchUp = 20
+	overlayNotes    = "notes"
+	overlayZNotes   = 50
+	overlayRuler    = "ruler"
+	overlayZRuler   = 100
+)
+
+type overlayKind int
+
+const (
+	overlayKindRect overlayKind = iota
+	overlayKindLine
+	overlayKindHandle
+)
+
+// overlayItem is one figure drawn over a preview. It is placed in image
+// pixel coordinates, so it follows resizing and strip scrolling. A rect
+// covers the pixels of bounds, a line joins the centers of pixels from and
+// to, and a handle is a dot on the center of pixel from. onTapped, when
+// set, takes the taps that hit the figure; a handle with onDragged set can
+// be dragged and reports each pixel it moves to, and onDragEnd the release.
+type overlayItem struct {
+	kind     overlayKind
+	bounds   image.Rectangle
+	from, to image.Point
+
+	onTapped  func()
+	onDragged func(p image.Point)
+	onDragEnd func()
+
+	object fyne.CanvasObject
+	filled bool
+	shown  bool
+}
+
+func newOverlayRect(bounds image.Rectangle, stroke, fill color.Color) *overlayItem {
+	rect := canvas.NewRectangle(fill)
+	rect.StrokeColor = stroke
+	rect.StrokeWidth = 1
+	_, _, _, alpha := fill.RGBA()
+	return &overlayItem{kind: overlayKindRect, bounds: bounds, object: rect, filled: alpha > 0}
+}
+
+func newOverlayLine(from, to image.Point, stroke color.Color) *overlayItem {
+	line := canvas.NewLine(stroke)
+	line.StrokeWidth = 2
+	return &overlayItem{kind: overlayKindLine, from: from, to: to, object: line}
+}
+
+func newOverlayHandle(p image.Point, stroke color.Color, onDragged func(p image.Point)) *overlayItem {
+	circle := canvas.NewCircle(color.NRGBA{R: 255, G: 255, B: 255, A: 200})
+	circle.StrokeColor = stroke
+	circle.StrokeWidth = 2
+	return &overlayItem{kind: overlayKindHandle, from: p, onDragged: onDragged, object: circle}
+}
+
+// layout places the figure for a view that shows the image pixels of
+// bounds scaled by scale from offset, hiding it when it lies outside them.
+func (oi *overlayItem) layout(offset fyne.Position, scale float32, bounds image.Rectangle) {
+	at := func(x, y float32) fyne.Position {
+		return fyne.NewPos(offset.X+(x-float32(bounds.Min.X))*scale, offset.Y+(y-float32(bounds.Min.Y))*scale)
+	}
+	center := func(p image.Point) fyne.Position {
+		return at(float32(p.X)+0.5, float32(p.Y)+0.5)
+	}
+
+	switch oi.kind {
+	case overlayKindRect:
+		shown := oi.bounds.Intersect(bounds)
+		oi.shown = !shown.Empty()
+		if oi.shown {
+			topLeft := at(float32(shown.Min.X), float32(shown.Min.Y))
+			bottomRight := at(float32(shown.Max.X), float32(shown.Max.Y))
+			oi.object.Move(topLeft)
+			oi.object.Resize(fyne.NewSize(bottomRight.X-topLeft.X, bottomRight.Y-topLeft.Y))
+		}
+	case overlayKindLine:
+		line := oi.object.(*canvas.Line)
+		oi.shown = oi.from.In(bounds) || oi.to.In(bounds)
+		line.Position1, line.Position2 = center(oi.from), center(oi.to)
+	case overlayKindHandle:
+		oi.shown = oi.from.In(bounds)
+		oi.object.Resize(fyne.NewSize(overlayHandleSize, overlayHandleSize))
+		oi.object.Move(center(oi.from).SubtractXY(overlayHandleSize/2, overlayHandleSize/2))
+	}
+
+	if oi.shown {
+		oi.object.Show()
+	} else {
+		oi.object.Hide()
+	}
+	oi.object.Refresh()
+}
+
+// hit reports whether the widget position pos falls on the figure. Unfilled
+// rects are hit on their outline only, so the pixels inside stay reachable.
+func (oi *overlayItem) hit(pos fyne.Position) bool {
+	if !oi.shown {
+		return false
+	}
+
+	switch oi.kind {
+	case overlayKindRect:
+		topLeft, size := oi.object.Position(), oi.object.Size()
+		inside := func(inset float32) bool {
+			return pos.X >= topLeft.X+inset && pos.X <= topLeft.X+size.Width-inset &&
+				pos.Y >= topLeft.Y+inset && pos.Y <= topLeft.Y+size.Height-inset
+		}
+		return inside(-overlayHitSlop) && (oi.filled || !inside(overlayHitSlop))
+	case overlayKindLine:
+		line := oi.object.(*canvas.Line)
+		return segmentDistance(pos, line.Position1, line.Position2) <= overlayHitSlop+line.StrokeWidth/2
+	case overlayKindHandle:
+		center := oi.object.Position().AddXY(overlayHandleSize/2, overlayHandleSize/2)
+		return segmentDistance(pos, center, center) <= overlayHandleSize/2+overlayHitSlop/2
+	}
+	return false
+}
+
+// segmentDistance is the distance from p to the segment from a to b.
+func segmentDistance(p, a, b fyne.Position) float32 {
+	dx, dy := b.X-a.X, b.Y-a.Y
+	t := float32(0)
+	if length := dx*dx + dy*dy; length > 0 {
+		t = clampUnit(((p.X-a.X)*dx + (p.Y-a.Y)*dy) / length)
+	}
+	return float32(math.Hypot(float64(p.X-(a.X+t*dx)), float64(p.Y-(a.Y+t*dy))))
+}
+
+// overlayLayer groups the figures one tool draws over a preview.
+type overlayLayer struct {
+	name  string
+	z     int
+	items []*overlayItem
+}
+
+// setOverlay shows items as the layer name at z, replacing what that layer
+// showed before.
+func (t *imageTapTarget) setOverlay(name string, z int, items ...*overlayItem) {
+	t.removeOverlay(name)
+	t.overlays = append(t.overlays, &overlayLayer{name: name, z: z, items: items})
+	sort.SliceStable(t.overlays, func(i, j int) bool { return t.overlays[i].z < t.overlays[j].z })
+	t.Refresh()
+}
+
+func (t *imageTapTarget) clearOverlay(name string) {
+	if t.removeOverlay(name) {
+		t.Refresh()
+	}
+}
+
+func (t *imageTapTarget) removeOverlay(name string) bool {
+	for i, layer := range t.overlays {
+		if layer.name == name {
+			if t.dragItem != nil && containsOverlayItem(layer.items, t.dragItem) {
+				t.dragItem = nil
+			}
+			t.overlays = append(t.overlays[:i], t.overlays[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func containsOverlayItem(items []*overlayItem, item *overlayItem) bool {
+	for _, candidate := range items {
+		if candidate == item {
+			return true
+		}
+	}
+	return false
+}
+
+// overlayObjects lists the canvas objects of every layer, bottom first.
+func (t *imageTapTarget) overlayObjects() []fyne.CanvasObject {
+	var objects []fyne.CanvasObject
+	for _, layer := range t.overlays {
+		for _, item := range layer.items {
+			objects = append(objects, item.object)
+		}
+	}
+	return objects
+}
+
+func (t *imageTapTarget) layoutOverlays() {
+	offset, scale, bounds, ok := t.imageTransform()
+	for _, layer := range t.overlays {
+		for _, item := range layer.items {
+			if ok {
+				item.layout(offset, scale, bounds)
+			} else {
+				item.shown = false
+				item.object.Hide()
+			}
+		}
+	}
+}
+
+// overlayAt returns the topmost figure at pos that takes input of the kind
+// accepts, nil when there is none.
+func (t *imageTapTarget) overlayAt(pos fyne.Position, accepts func(*overlayItem) bool) *overlayItem {
+	for i := len(t.overlays) - 1; i >= 0; i-- {
+		items := t.overlays[i].items
+		for j := len(items) - 1; j >= 0; j-- {
+			if accepts(items[j]) && items[j].hit(pos) {
+				return items[j]
+			}
+		}
+	}
+	return nil
+}
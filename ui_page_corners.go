@@ -0,0 +1,222 @@
+//go:build !nogui
+
+package main
+
+import (
+	"image"
+	"image/color"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+const (
+	cornerEditorWidth  = 800
+	cornerEditorHeight = 600
+	cornerHandleSize   = 16
+
+	// Corners start this far inside the image edges when detection fails.
+	cornerDefaultInset = 0.05
+)
+
+var cornerOutlineColor = color.NRGBA{R: 0, G: 160, B: 255, A: 255}
+
+// cornerHandle is a draggable marker over one page corner.
+type cornerHandle struct {
+	widget.BaseWidget
+	circle *canvas.Circle
+	onDrag func(delta fyne.Delta)
+}
+
+func newCornerHandle(onDrag func(fyne.Delta)) *cornerHandle {
+	circle := canvas.NewCircle(color.NRGBA{R: 255, G: 255, B: 255, A: 200})
+	circle.StrokeColor = cornerOutlineColor
+	circle.StrokeWidth = 2
+
+	h := &cornerHandle{circle: circle, onDrag: onDrag}
+	h.ExtendBaseWidget(h)
+	h.Resize(fyne.NewSize(cornerHandleSize, cornerHandleSize))
+	return h
+}
+
+func (h *cornerHandle) CreateRenderer() fyne.WidgetRenderer {
+	return widget.NewSimpleRenderer(h.circle)
+}
+
+func (h *cornerHandle) Dragged(event *fyne.DragEvent) {
+	h.onDrag(event.Dragged)
+}
+
+func (h *cornerHandle) DragEnd() {}
+
+// pageCornerEditor overlays the page outline on a scaled copy of the image
+// and keeps the corners in image pixels while the handles are dragged.
+type pageCornerEditor struct {
+	corners   PageCorners
+	imageSize image.Point
+	scale     float32
+
+	handles [4]*cornerHandle
+	lines   [4]*canvas.Line
+	content *fyne.Container
+}
+
+func newPageCornerEditor(img image.Image, corners PageCorners) *pageCornerEditor {
+	bounds := img.Bounds()
+	editor := &pageCornerEditor{
+		corners:   corners,
+		imageSize: image.Pt(bounds.Dx(), bounds.Dy()),
+		scale: min32(
+			float32(cornerEditorWidth)/float32(bounds.Dx()),
+			float32(cornerEditorHeight)/float32(bounds.Dy()),
+		),
+	}
+
+	displaySize := fyne.NewSize(float32(bounds.Dx())*editor.scale, float32(bounds.Dy())*editor.scale)
+	preview := canvas.NewImageFromImage(img)
+	preview.FillMode = canvas.ImageFillStretch
+	preview.ScaleMode = canvas.ImageScaleFastest
+	preview.SetMinSize(displaySize)
+	preview.Resize(displaySize)
+
+	objects := []fyne.CanvasObject{preview}
+	for i := range editor.lines {
+		editor.lines[i] = canvas.NewLine(cornerOutlineColor)
+		editor.lines[i].StrokeWidth = 2
+		objects = append(objects, editor.lines[i])
+	}
+	for i := range editor.handles {
+		index := i
+		editor.handles[i] = newCornerHandle(func(delta fyne.Delta) {
+			editor.moveCorner(index, delta)
+		})
+		objects = append(objects, editor.handles[i])
+	}
+
+	editor.content = container.NewWithoutLayout(objects...)
+	editor.layoutOverlay()
+	return editor
+}
+
+func min32(a, b float32) float32 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func (e *pageCornerEditor) toDisplay(p image.Point) fyne.Position {
+	return fyne.NewPos(float32(p.X)*e.scale, float32(p.Y)*e.scale)
+}
+
+func (e *pageCornerEditor) moveCorner(index int, delta fyne.Delta) {
+	pos := e.toDisplay(e.corners[index]).Add(delta)
+	x := int(pos.X / e.scale)
+	y := int(pos.Y / e.scale)
+
+	e.corners[index] = image.Pt(
+		max(0, min(e.imageSize.X-1, x)),
+		max(0, min(e.imageSize.Y-1, y)),
+	)
+	e.layoutOverlay()
+}
+
+func (e *pageCornerEditor) setCorners(corners PageCorners) {
+	e.corners = corners
+	e.layoutOverlay()
+}
+
+func (e *pageCornerEditor) layoutOverlay() {
+	for i, corner := range e.corners {
+		pos := e.toDisplay(corner)
+		e.handles[i].Move(pos.SubtractXY(cornerHandleSize/2, cornerHandleSize/2))
+
+		next := e.toDisplay(e.corners[(i+1)%len(e.corners)])
+		e.lines[i].Position1 = pos
+		e.lines[i].Position2 = next
+		e.lines[i].Refresh()
+	}
+	e.content.Refresh()
+}
+
+// defaultPageCorners insets the corners slightly from the image edges so
+// the handles are easy to grab.
+func defaultPageCorners(width, height int) PageCorners {
+	insetX := int(float64(width) * cornerDefaultInset)
+	insetY := int(float64(height) * cornerDefaultInset)
+	return PageCorners{
+		image.Pt(insetX, insetY),
+		image.Pt(width-1-insetX, insetY),
+		image.Pt(width-1-insetX, height-1-insetY),
+		image.Pt(insetX, height-1-insetY),
+	}
+}
+
+// showPageCornerEditor lets the user place the page corners used by
+// perspective correction, starting from the current or detected outline.
+func (a *Application) showPageCornerEditor() {
+	original := a.processing.GetOriginalImage()
+	if original == nil {
+		dialog.ShowInformation("Adjust Page Corners", "Load an image first.", a.window)
+		return
+	}
+
+	var corners PageCorners
+	detected := false
+	if current := a.processing.GetPageCorners(); current != nil {
+		corners = *current
+	} else if found, err := a.processing.DetectPageCorners(); err == nil {
+		corners, detected = found, true
+	} else {
+		corners = defaultPageCorners(original.Width, original.Height)
+	}
+
+	editor := newPageCornerEditor(original.Image, corners)
+
+	hint := "Drag the corners onto the page outline."
+	if !detected && a.processing.GetPageCorners() == nil {
+		hint = "The page outline was not found. Drag the corners onto the page."
+	}
+	status := widget.NewLabel(hint)
+
+	detectButton := widget.NewButton("Detect", func() {
+		found, err := a.processing.DetectPageCorners()
+		if err != nil {
+			status.SetText("The page outline was not found. Drag the corners onto the page.")
+			return
+		}
+		editor.setCorners(found)
+		status.SetText("Page outline detected.")
+	})
+	resetButton := widget.NewButton("Reset", func() {
+		editor.setCorners(defaultPageCorners(original.Width, original.Height))
+	})
+
+	content := container.NewBorder(
+		status,
+		container.NewHBox(detectButton, resetButton),
+		nil, nil,
+		container.NewCenter(editor.content),
+	)
+
+	confirm := dialog.NewCustomConfirm("Adjust Page Corners", "Apply", "Cancel", content, func(apply bool) {
+		if !apply {
+			return
+		}
+
+		corners := editor.corners
+		a.processing.SetPageCorners(&corners)
+
+		params := a.parameters.GetCurrentParameters()
+		if !params.PerspectiveCorrection {
+			params.PerspectiveCorrection = true
+			a.parameters.SetParameters(params)
+			return
+		}
+		a.toolbar.handleProcessImageWithParams(params)
+	}, a.window)
+	confirm.Show()
+}
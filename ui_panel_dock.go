@@ -0,0 +1,196 @@
+//go:build !nogui
+
+package main
+
+import (
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+// Fyne exposes no API for reading or setting window positions, so only the
+// detached state and window size of each panel are persisted.
+const (
+	panelPrefDetached = "panel.%s.detached"
+	panelPrefWidth    = "panel.%s.width"
+	panelPrefHeight   = "panel.%s.height"
+)
+
+type DockablePanel struct {
+	name     string
+	title    string
+	content  fyne.CanvasObject
+	slot     *fyne.Container
+	window   fyne.Window
+	menuItem *fyne.MenuItem
+}
+
+type PanelDock struct {
+	app    *Application
+	panels []*DockablePanel
+}
+
+func NewPanelDock(app *Application) *PanelDock {
+	return &PanelDock{
+		app: app,
+	}
+}
+
+// AddPanel registers content under name and returns the slot that holds it
+// while docked in the main window.
+func (pd *PanelDock) AddPanel(name, title string, content fyne.CanvasObject) *fyne.Container {
+	panel := &DockablePanel{
+		name:    name,
+		title:   title,
+		content: content,
+		slot:    container.NewStack(content),
+	}
+
+	panel.menuItem = fyne.NewMenuItem("Float "+title, func() {
+		pd.Toggle(name)
+	})
+
+	pd.panels = append(pd.panels, panel)
+	return panel.slot
+}
+
+func (pd *PanelDock) findPanel(name string) *DockablePanel {
+	for _, panel := range pd.panels {
+		if panel.name == name {
+			return panel
+		}
+	}
+	return nil
+}
+
+func (pd *PanelDock) Toggle(name string) {
+	panel := pd.findPanel(name)
+	if panel == nil {
+		return
+	}
+
+	if panel.window != nil {
+		pd.Dock(name)
+	} else {
+		pd.Detach(name)
+	}
+}
+
+func (pd *PanelDock) Detach(name string) {
+	panel := pd.findPanel(name)
+	if panel == nil || panel.window != nil {
+		return
+	}
+
+	prefs := pd.app.fyneApp.Preferences()
+
+	panel.slot.Objects = nil
+	panel.slot.Refresh()
+
+	window := pd.app.fyneApp.NewWindow(fmt.Sprintf("%s - %s", AppName, panel.title))
+	window.SetContent(panel.content)
+
+	width := prefs.FloatWithFallback(fmt.Sprintf(panelPrefWidth, name), 0)
+	height := prefs.FloatWithFallback(fmt.Sprintf(panelPrefHeight, name), 0)
+	if width > 0 && height > 0 {
+		window.Resize(fyne.NewSize(float32(width), float32(height)))
+	}
+
+	window.SetCloseIntercept(func() {
+		pd.Dock(name)
+	})
+
+	panel.window = window
+	panel.menuItem.Checked = true
+	prefs.SetBool(fmt.Sprintf(panelPrefDetached, name), true)
+	pd.refreshMenu()
+
+	debugSystem := GetDebugSystem()
+	debugSystem.logger.Debug("panel detached", "panel", name)
+
+	window.Show()
+}
+
+func (pd *PanelDock) Dock(name string) {
+	panel := pd.findPanel(name)
+	if panel == nil || panel.window == nil {
+		return
+	}
+
+	pd.saveWindowSize(panel)
+
+	// Swap in a placeholder so the content is no longer owned by the
+	// floating window's canvas before it is placed back in the slot.
+	panel.window.SetContent(widget.NewLabel(""))
+	panel.window.Close()
+	panel.window = nil
+
+	panel.slot.Objects = []fyne.CanvasObject{panel.content}
+	panel.slot.Refresh()
+
+	panel.menuItem.Checked = false
+	pd.app.fyneApp.Preferences().SetBool(fmt.Sprintf(panelPrefDetached, name), false)
+	pd.refreshMenu()
+
+	debugSystem := GetDebugSystem()
+	debugSystem.logger.Debug("panel docked", "panel", name)
+}
+
+// RestoreLayout floats the panels that were detached in the previous session.
+func (pd *PanelDock) RestoreLayout() {
+	prefs := pd.app.fyneApp.Preferences()
+	for _, panel := range pd.panels {
+		if prefs.BoolWithFallback(fmt.Sprintf(panelPrefDetached, panel.name), false) {
+			pd.Detach(panel.name)
+		}
+	}
+}
+
+// SaveLayout records the size of every floating panel without changing its
+// detached state, so the layout comes back as it was on next launch.
+func (pd *PanelDock) SaveLayout() {
+	for _, panel := range pd.panels {
+		if panel.window != nil {
+			pd.saveWindowSize(panel)
+		}
+	}
+}
+
+func (pd *PanelDock) saveWindowSize(panel *DockablePanel) {
+	size := panel.window.Canvas().Size()
+	if size.Width <= 0 || size.Height <= 0 {
+		return
+	}
+
+	prefs := pd.app.fyneApp.Preferences()
+	prefs.SetFloat(fmt.Sprintf(panelPrefWidth, panel.name), float64(size.Width))
+	prefs.SetFloat(fmt.Sprintf(panelPrefHeight, panel.name), float64(size.Height))
+}
+
+// CloseFloating closes floating windows on shutdown while keeping their
+// detached preference intact.
+func (pd *PanelDock) CloseFloating() {
+	for _, panel := range pd.panels {
+		if panel.window != nil {
+			panel.window.SetCloseIntercept(nil)
+			panel.window.Close()
+			panel.window = nil
+		}
+	}
+}
+
+func (pd *PanelDock) buildViewMenu() *fyne.Menu {
+	items := make([]*fyne.MenuItem, len(pd.panels))
+	for i, panel := range pd.panels {
+		items[i] = panel.menuItem
+	}
+	return fyne.NewMenu("View", items...)
+}
+
+func (pd *PanelDock) refreshMenu() {
+	if mainMenu := pd.app.window.MainMenu(); mainMenu != nil {
+		mainMenu.Refresh()
+	}
+}
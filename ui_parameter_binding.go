@@ -0,0 +1,113 @@
+//go:build !nogui
+
+package main
+
+import "fyne.io/fyne/v2/widget"
+
+// parameterBinding ties a panel widget to an OtsuParameters field. The panel
+// reads and writes parameters through one table of bindings, so each field
+// is read from and shown in the same widget.
+type parameterBinding struct {
+	read  func(params *OtsuParameters)
+	write func(params *OtsuParameters)
+}
+
+func bindField[T any](field func(*OtsuParameters) *T, get func() T, set func(T)) parameterBinding {
+	return parameterBinding{
+		read:  func(params *OtsuParameters) { *field(params) = get() },
+		write: func(params *OtsuParameters) { set(*field(params)) },
+	}
+}
+
+func bindCheck(check *widget.Check, field func(*OtsuParameters) *bool) parameterBinding {
+	return bindField(field, func() bool { return check.Checked }, check.SetChecked)
+}
+
+func bindSlider(slider *widget.Slider, field func(*OtsuParameters) *float64) parameterBinding {
+	return bindField(field, func() float64 { return slider.Value }, slider.SetValue)
+}
+
+func bindIntSlider(slider *widget.Slider, field func(*OtsuParameters) *int) parameterBinding {
+	return bindField(field,
+		func() int { return int(slider.Value) },
+		func(value int) { slider.SetValue(float64(value)) })
+}
+
+// bindSelect shows fallback for an empty field.
+func bindSelect(sel *widget.Select, field func(*OtsuParameters) *string, fallback string) parameterBinding {
+	return bindField(field,
+		func() string { return sel.Selected },
+		func(value string) {
+			if value == "" {
+				value = fallback
+			}
+			sel.SetSelected(value)
+		})
+}
+
+func (w *ParameterWidgets) parameterBindings() []parameterBinding {
+	return []parameterBinding{
+		// Window sizes are odd; an even slider value rounds up.
+		bindField(func(p *OtsuParameters) *int { return &p.WindowSize },
+			func() int { return int(w.windowSizeSlider.Value) | 1 },
+			func(value int) { w.windowSizeSlider.SetValue(float64(value)) }),
+		bindIntSlider(w.histBinsSlider, func(p *OtsuParameters) *int { return &p.HistogramBins }),
+		bindIntSlider(w.neighborhoodBinsSlider, func(p *OtsuParameters) *int { return &p.NeighborhoodBins }),
+		bindSlider(w.smoothingSlider, func(p *OtsuParameters) *float64 { return &p.SmoothingStrength }),
+		bindIntSlider(w.pyramidLevelsSlider, func(p *OtsuParameters) *int { return &p.PyramidLevels }),
+		bindIntSlider(w.regionGridSlider, func(p *OtsuParameters) *int { return &p.RegionGridSize }),
+		bindIntSlider(w.morphKernelSlider, func(p *OtsuParameters) *int { return &p.MorphologicalKernelSize }),
+		bindIntSlider(w.diffusionIterSlider, func(p *OtsuParameters) *int { return &p.DiffusionIterations }),
+		bindSlider(w.diffusionKappaSlider, func(p *OtsuParameters) *float64 { return &p.DiffusionKappa }),
+		bindIntSlider(w.brightnessSlider, func(p *OtsuParameters) *int { return &p.Brightness }),
+		bindSlider(w.contrastSlider, func(p *OtsuParameters) *float64 { return &p.Contrast }),
+		bindSlider(w.gammaSlider, func(p *OtsuParameters) *float64 { return &p.Gamma }),
+		bindSlider(w.shadowStrengthSlider, func(p *OtsuParameters) *float64 { return &p.ShadowRemovalStrength }),
+		bindSlider(w.dewarpCurvatureSlider, func(p *OtsuParameters) *float64 { return &p.DewarpCurvature }),
+		bindSlider(w.stainHueMinSlider, func(p *OtsuParameters) *float64 { return &p.StainHueMin }),
+		bindSlider(w.stainHueMaxSlider, func(p *OtsuParameters) *float64 { return &p.StainHueMax }),
+		bindSlider(w.stainStrengthSlider, func(p *OtsuParameters) *float64 { return &p.StainStrength }),
+		bindSlider(w.dropoutToleranceSlider, func(p *OtsuParameters) *float64 { return &p.DropoutTolerance }),
+		bindIntSlider(w.maxHoleAreaSlider, func(p *OtsuParameters) *int { return &p.MaxHoleArea }),
+		bindIntSlider(w.maxGapSizeSlider, func(p *OtsuParameters) *int { return &p.MaxGapSize }),
+		bindSlider(w.neuralThresholdSlider, func(p *OtsuParameters) *float64 { return &p.NeuralThreshold }),
+		// Parameter files from before 3D Otsu have no bin count.
+		bindField(func(p *OtsuParameters) *int { return &p.Otsu3DBins },
+			func() int { return int(w.otsu3DBinsSlider.Value) },
+			func(value int) {
+				if value != 0 {
+					w.otsu3DBinsSlider.SetValue(float64(value))
+				}
+			}),
+		bindSlider(w.tsallisQSlider, func(p *OtsuParameters) *float64 { return &p.TsallisQ }),
+
+		bindSelect(w.neighborhoodSelect, func(p *OtsuParameters) *string { return &p.NeighborhoodType }, ""),
+		bindSelect(w.featurePairingSelect, func(p *OtsuParameters) *string { return &p.FeaturePairing }, FeaturePairingMean),
+		bindSelect(w.interpolationSelect, func(p *OtsuParameters) *string { return &p.InterpolationMethod }, ""),
+		bindSelect(w.shadowMethodSelect, func(p *OtsuParameters) *string { return &p.ShadowRemovalMethod }, ""),
+		bindSelect(w.grayChannelSelect, func(p *OtsuParameters) *string { return &p.GrayscaleChannel }, GrayChannelLuminance),
+		bindSelect(w.photoZonesSelect, func(p *OtsuParameters) *string { return &p.PhotoZones }, PhotoZonesBinarize),
+
+		bindCheck(w.legacyNeighborhoodCheck, func(p *OtsuParameters) *bool { return &p.LegacyNeighborhoods }),
+		bindCheck(w.edgePreservationCheck, func(p *OtsuParameters) *bool { return &p.EdgePreservation }),
+		bindCheck(w.noiseRobustnessCheck, func(p *OtsuParameters) *bool { return &p.NoiseRobustness }),
+		bindCheck(w.gaussianPreprocessCheck, func(p *OtsuParameters) *bool { return &p.GaussianPreprocessing }),
+		bindCheck(w.useLogCheck, func(p *OtsuParameters) *bool { return &p.UseLogHistogram }),
+		bindCheck(w.normalizeCheck, func(p *OtsuParameters) *bool { return &p.NormalizeHistogram }),
+		bindCheck(w.contrastCheck, func(p *OtsuParameters) *bool { return &p.ApplyContrastEnhancement }),
+		bindCheck(w.adaptiveWindowCheck, func(p *OtsuParameters) *bool { return &p.AdaptiveWindowSizing }),
+		bindCheck(w.morphPostProcessCheck, func(p *OtsuParameters) *bool { return &p.MorphologicalPostProcess }),
+		bindCheck(w.homomorphicCheck, func(p *OtsuParameters) *bool { return &p.HomomorphicFiltering }),
+		bindCheck(w.anisotropicCheck, func(p *OtsuParameters) *bool { return &p.AnisotropicDiffusion }),
+		bindCheck(w.equalizeCheck, func(p *OtsuParameters) *bool { return &p.EqualizeHistogram }),
+		bindCheck(w.shadowRemovalCheck, func(p *OtsuParameters) *bool { return &p.ShadowRemoval }),
+		bindCheck(w.perspectiveCheck, func(p *OtsuParameters) *bool { return &p.PerspectiveCorrection }),
+		bindCheck(w.dewarpCheck, func(p *OtsuParameters) *bool { return &p.CylindricalDewarp }),
+		bindCheck(w.stainCheck, func(p *OtsuParameters) *bool { return &p.StainSuppression }),
+		bindCheck(w.dropoutRedCheck, func(p *OtsuParameters) *bool { return &p.DropoutRed }),
+		bindCheck(w.dropoutGreenCheck, func(p *OtsuParameters) *bool { return &p.DropoutGreen }),
+		bindCheck(w.dropoutBlueCheck, func(p *OtsuParameters) *bool { return &p.DropoutBlue }),
+		bindCheck(w.fillHolesCheck, func(p *OtsuParameters) *bool { return &p.FillHoles }),
+		bindCheck(w.bridgeGapsCheck, func(p *OtsuParameters) *bool { return &p.BridgeGaps }),
+	}
+}
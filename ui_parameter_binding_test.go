@@ -0,0 +1,171 @@
+//go:build !nogui
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"fyne.io/fyne/v2/test"
+)
+
+// boundParameters sets every field the panel binds to a value inside its
+// widget's range that differs from the widget's initial value, and leaves
+// every other field zero.
+func boundParameters() *OtsuParameters {
+	return &OtsuParameters{
+		WindowSize:              9,
+		HistogramBins:           128,
+		NeighborhoodBins:        64,
+		SmoothingStrength:       2.5,
+		PyramidLevels:           4,
+		RegionGridSize:          128,
+		MorphologicalKernelSize: 5,
+		DiffusionIterations:     10,
+		DiffusionKappa:          45,
+		Brightness:              -20,
+		Contrast:                1.5,
+		Gamma:                   0.8,
+		ShadowRemovalStrength:   0.6,
+		DewarpCurvature:         0.3,
+		StainHueMin:             20,
+		StainHueMax:             80,
+		StainStrength:           0.5,
+		DropoutTolerance:        30,
+		MaxHoleArea:             40,
+		MaxGapSize:              3,
+		NeuralThreshold:         0.7,
+		Otsu3DBins:              48,
+		TsallisQ:                1.5,
+
+		NeighborhoodType:    NeighborhoodCircular,
+		FeaturePairing:      FeaturePairingGradient,
+		InterpolationMethod: "Bicubic",
+		ShadowRemovalMethod: ShadowMethodMorphological,
+		GrayscaleChannel:    GrayChannelRed,
+		PhotoZones:          PhotoZonesDither,
+
+		LegacyNeighborhoods:      true,
+		EdgePreservation:         true,
+		NoiseRobustness:          true,
+		GaussianPreprocessing:    true,
+		UseLogHistogram:          true,
+		NormalizeHistogram:       true,
+		ApplyContrastEnhancement: true,
+		AdaptiveWindowSizing:     true,
+		MorphologicalPostProcess: true,
+		HomomorphicFiltering:     true,
+		AnisotropicDiffusion:     true,
+		EqualizeHistogram:        true,
+		ShadowRemoval:            true,
+		PerspectiveCorrection:    true,
+		CylindricalDewarp:        true,
+		StainSuppression:         true,
+		DropoutRed:               true,
+		DropoutGreen:             true,
+		DropoutBlue:              true,
+		FillHoles:                true,
+		BridgeGaps:               true,
+	}
+}
+
+func TestParameterBindingsRoundTripEachField(t *testing.T) {
+	test.NewTempApp(t)
+
+	want := boundParameters()
+	wantValue := reflect.ValueOf(want).Elem()
+	fields := wantValue.Type()
+	seen := make(map[string]int)
+
+	for i, binding := range NewParameterWidgets().parameterBindings() {
+		binding.write(want)
+		got := &OtsuParameters{}
+		binding.read(got)
+
+		gotValue := reflect.ValueOf(got).Elem()
+		var bound []string
+		for f := 0; f < gotValue.NumField(); f++ {
+			if gotValue.Field(f).IsZero() {
+				continue
+			}
+			name := fields.Field(f).Name
+			bound = append(bound, name)
+			if !reflect.DeepEqual(gotValue.Field(f).Interface(), wantValue.Field(f).Interface()) {
+				t.Errorf("binding %d: %s read back %v, want %v", i, name,
+					gotValue.Field(f).Interface(), wantValue.Field(f).Interface())
+			}
+		}
+
+		if len(bound) != 1 {
+			t.Errorf("binding %d reads fields %v, want exactly one", i, bound)
+			continue
+		}
+		if previous, ok := seen[bound[0]]; ok {
+			t.Errorf("bindings %d and %d both edit %s", previous, i, bound[0])
+		}
+		seen[bound[0]] = i
+	}
+}
+
+func TestParameterBindingsRoundTripWholeSet(t *testing.T) {
+	test.NewTempApp(t)
+
+	want := boundParameters()
+	bindings := NewParameterWidgets().parameterBindings()
+	for _, binding := range bindings {
+		binding.write(want)
+	}
+
+	got := &OtsuParameters{}
+	for _, binding := range bindings {
+		binding.read(got)
+	}
+
+	// The method select, editor lists and model are bound apart, so the
+	// bindings alone must reproduce exactly the fields set above.
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("bindings read back\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestParameterBindingsWindowSizeRoundsUpToOdd(t *testing.T) {
+	test.NewTempApp(t)
+
+	widgets := NewParameterWidgets()
+	widgets.windowSizeSlider.SetValue(10)
+
+	got := &OtsuParameters{}
+	for _, binding := range widgets.parameterBindings() {
+		binding.read(got)
+	}
+	if got.WindowSize != 11 {
+		t.Errorf("WindowSize = %d for slider value 10, want 11", got.WindowSize)
+	}
+}
+
+func TestParameterBindingsEmptySelectFallsBack(t *testing.T) {
+	test.NewTempApp(t)
+
+	widgets := NewParameterWidgets()
+	widgets.featurePairingSelect.SetSelected(FeaturePairingGradient)
+	widgets.grayChannelSelect.SetSelected(GrayChannelRed)
+	widgets.photoZonesSelect.SetSelected(PhotoZonesDither)
+
+	params := boundParameters()
+	params.FeaturePairing = ""
+	params.GrayscaleChannel = ""
+	params.PhotoZones = ""
+	for _, binding := range widgets.parameterBindings() {
+		binding.write(params)
+	}
+
+	if widgets.featurePairingSelect.Selected != FeaturePairingMean {
+		t.Errorf("feature pairing shows %q, want %q", widgets.featurePairingSelect.Selected, FeaturePairingMean)
+	}
+	if widgets.grayChannelSelect.Selected != GrayChannelLuminance {
+		t.Errorf("grayscale channel shows %q, want %q", widgets.grayChannelSelect.Selected, GrayChannelLuminance)
+	}
+	if widgets.photoZonesSelect.Selected != PhotoZonesBinarize {
+		t.Errorf("photo zones shows %q, want %q", widgets.photoZonesSelect.Selected, PhotoZonesBinarize)
+	}
+}
@@ -1,3 +1,5 @@
+//go:build !nogui
+
 package main
 
 import (
@@ -11,40 +13,105 @@ import (
 )
 
 type ParameterPanel struct {
-	app       *Application
-	container *fyne.Container
-	widgets   *ParameterWidgets
+	app              *Application
+	container        *fyne.Container
+	metricsContainer *fyne.Container
+	widgets          *ParameterWidgets
 
-	// Status and metrics widgets
-	statusLabel  *widget.Label
+	// Metrics widgets
 	metricsLabel *widget.Label
 	detailsLabel *widget.Label
 
+	// computeMetricsButton computes metrics the battery saver deferred.
+	computeMetricsButton *widget.Button
+
+	// postProcessing holds the steps chosen in the post-processing editor;
+	// empty selects the default open/close pair.
+	postProcessing []MorphOperation
+
+	// externalStages holds the stages chosen in the external stages editor.
+	externalStages []ExternalStage
+
+	// neuralModel is the ONNX model file of the neural method.
+	neuralModel string
+
+	// bindings map the widgets to the parameter fields they edit; the
+	// method select, the editors' lists and the model are handled apart.
+	bindings []parameterBinding
+
+	// applyingParameters is set while SetParameters writes a whole
+	// parameter set, so the widgets' change listeners do not each start
+	// a run.
+	applyingParameters bool
+
 	lastProcessTime  time.Time
 	processingCtx    context.Context
 	processingCancel context.CancelFunc
 }
 
 type ParameterWidgets struct {
-	processingMethodSelect *widget.Select
-	windowSizeSlider       *widget.Slider
-	windowSizeLabel        *widget.Label
-	histBinsSlider         *widget.Slider
-	histBinsLabel          *widget.Label
-	smoothingSlider        *widget.Slider
-	smoothingLabel         *widget.Label
-	pyramidLevelsSlider    *widget.Slider
-	pyramidLevelsLabel     *widget.Label
-	regionGridSlider       *widget.Slider
-	regionGridLabel        *widget.Label
-	neighborhoodSelect     *widget.Select
-	interpolationSelect    *widget.Select
-	morphKernelSlider      *widget.Slider
-	morphKernelLabel       *widget.Label
-	diffusionIterSlider    *widget.Slider
-	diffusionIterLabel     *widget.Label
-	diffusionKappaSlider   *widget.Slider
-	diffusionKappaLabel    *widget.Label
+	processingMethodSelect  *widget.Select
+	windowSizeSlider        *widget.Slider
+	windowSizeLabel         *widget.Label
+	histBinsSlider          *widget.Slider
+	histBinsLabel           *widget.Label
+	neighborhoodBinsSlider  *widget.Slider
+	neighborhoodBinsLabel   *widget.Label
+	smoothingSlider         *widget.Slider
+	smoothingLabel          *widget.Label
+	pyramidLevelsSlider     *widget.Slider
+	pyramidLevelsLabel      *widget.Label
+	regionGridSlider        *widget.Slider
+	regionGridLabel         *widget.Label
+	neighborhoodSelect      *widget.Select
+	legacyNeighborhoodCheck *widget.Check
+	featurePairingSelect    *widget.Select
+	interpolationSelect     *widget.Select
+	morphKernelSlider       *widget.Slider
+	morphKernelLabel        *widget.Label
+	diffusionIterSlider     *widget.Slider
+	diffusionIterLabel      *widget.Label
+	diffusionKappaSlider    *widget.Slider
+	diffusionKappaLabel     *widget.Label
+	brightnessSlider        *widget.Slider
+	brightnessLabel         *widget.Label
+	contrastSlider          *widget.Slider
+	contrastLabel           *widget.Label
+	gammaSlider             *widget.Slider
+	gammaLabel              *widget.Label
+	shadowMethodSelect      *widget.Select
+	grayChannelSelect       *widget.Select
+	photoZonesSelect        *widget.Select
+	shadowStrengthSlider    *widget.Slider
+	shadowStrengthLabel     *widget.Label
+	dewarpCurvatureSlider   *widget.Slider
+	dewarpCurvatureLabel    *widget.Label
+	adjustCornersButton     *widget.Button
+	splitSpreadButton       *widget.Button
+	stainHueMinSlider       *widget.Slider
+	stainHueMaxSlider       *widget.Slider
+	stainHueLabel           *widget.Label
+	stainStrengthSlider     *widget.Slider
+	stainStrengthLabel      *widget.Label
+	dropoutToleranceSlider  *widget.Slider
+	dropoutToleranceLabel   *widget.Label
+	postProcessingLabel     *widget.Label
+	editPostProcessButton   *widget.Button
+	externalStagesLabel     *widget.Label
+	editExternalButton      *widget.Button
+	maxHoleAreaSlider       *widget.Slider
+	maxHoleAreaLabel        *widget.Label
+	maxGapSizeSlider        *widget.Slider
+	maxGapSizeLabel         *widget.Label
+	neuralModelLabel        *widget.Label
+	chooseModelButton       *widget.Button
+	neuralThresholdSlider   *widget.Slider
+	neuralThresholdLabel    *widget.Label
+	otsu3DBinsSlider        *widget.Slider
+	otsu3DBinsLabel         *widget.Label
+	tsallisQSlider          *widget.Slider
+	tsallisQLabel           *widget.Label
+	methodInfoText          *widget.RichText
 
 	edgePreservationCheck   *widget.Check
 	noiseRobustnessCheck    *widget.Check
@@ -56,6 +123,16 @@ type ParameterWidgets struct {
 	morphPostProcessCheck   *widget.Check
 	homomorphicCheck        *widget.Check
 	anisotropicCheck        *widget.Check
+	equalizeCheck           *widget.Check
+	shadowRemovalCheck      *widget.Check
+	perspectiveCheck        *widget.Check
+	dewarpCheck             *widget.Check
+	stainCheck              *widget.Check
+	dropoutRedCheck         *widget.Check
+	dropoutGreenCheck       *widget.Check
+	dropoutBlueCheck        *widget.Check
+	fillHolesCheck          *widget.Check
+	bridgeGapsCheck         *widget.Check
 }
 
 func NewParameterPanel(app *Application) *ParameterPanel {
@@ -64,13 +141,22 @@ func NewParameterPanel(app *Application) *ParameterPanel {
 	}
 
 	pp.widgets = NewParameterWidgets()
-	pp.createStatusMetricsWidgets()
+	pp.bindings = pp.widgets.parameterBindings()
+	pp.createMetricsWidgets()
 	pp.buildLayout()
 	pp.setupParameterListener()
 
 	return pp
 }
 
+// otsu3DMethodName is the processing method entry of the 3D Otsu method.
+const otsu3DMethodName = "3D Otsu (Experimental)"
+
+// entropyMethodEntry is the processing method entry of an entropy method.
+func entropyMethodEntry(method string) string {
+	return method + " Entropy"
+}
+
 func NewParameterWidgets() *ParameterWidgets {
 	w := &ParameterWidgets{}
 
@@ -78,6 +164,10 @@ func NewParameterWidgets() *ParameterWidgets {
 		"Single Scale",
 		"Multi-Scale Pyramid",
 		"Region Adaptive",
+		neuralMethodName,
+		otsu3DMethodName,
+		entropyMethodEntry(EntropyMethodKapur),
+		entropyMethodEntry(EntropyMethodTsallis),
 	}, nil)
 
 	w.windowSizeSlider = widget.NewSlider(3, 21)
@@ -89,6 +179,10 @@ func NewParameterWidgets() *ParameterWidgets {
 	w.histBinsSlider.SetValue(0)
 	w.histBinsLabel = widget.NewLabel("Histogram Bins: Auto")
 
+	w.neighborhoodBinsSlider = widget.NewSlider(0, 256)
+	w.neighborhoodBinsSlider.SetValue(0)
+	w.neighborhoodBinsLabel = widget.NewLabel("Neighborhood Bins: Same")
+
 	w.smoothingSlider = widget.NewSlider(0.0, 5.0)
 	w.smoothingSlider.SetValue(1.0)
 	w.smoothingLabel = widget.NewLabel("Smoothing Strength: 1.0")
@@ -101,12 +195,11 @@ func NewParameterWidgets() *ParameterWidgets {
 	w.regionGridSlider.SetValue(64)
 	w.regionGridLabel = widget.NewLabel("Region Grid Size: 64")
 
-	w.neighborhoodSelect = widget.NewSelect([]string{
-		"Rectangular",
-		"Circular",
-		"Distance Weighted",
-	}, nil)
-	w.neighborhoodSelect.SetSelected("Rectangular")
+	w.neighborhoodSelect = widget.NewSelect(NeighborhoodTypes, nil)
+	w.neighborhoodSelect.SetSelected(NeighborhoodRectangular)
+
+	w.featurePairingSelect = widget.NewSelect(FeaturePairings, nil)
+	w.featurePairingSelect.SetSelected(FeaturePairingMean)
 
 	w.interpolationSelect = widget.NewSelect([]string{
 		"Nearest",
@@ -119,6 +212,10 @@ func NewParameterWidgets() *ParameterWidgets {
 	w.morphKernelSlider.Step = 2
 	w.morphKernelSlider.SetValue(3)
 	w.morphKernelLabel = widget.NewLabel("Morphological Kernel: 3")
+	w.postProcessingLabel = widget.NewLabel(describeMorphOperations(nil))
+	w.postProcessingLabel.Wrapping = fyne.TextWrapWord
+	w.externalStagesLabel = widget.NewLabel(describeExternalStages(nil))
+	w.externalStagesLabel.Wrapping = fyne.TextWrapWord
 
 	w.diffusionIterSlider = widget.NewSlider(1, 20)
 	w.diffusionIterSlider.SetValue(5)
@@ -128,6 +225,85 @@ func NewParameterWidgets() *ParameterWidgets {
 	w.diffusionKappaSlider.SetValue(30)
 	w.diffusionKappaLabel = widget.NewLabel("Diffusion Kappa: 30.0")
 
+	w.brightnessSlider = widget.NewSlider(-100, 100)
+	w.brightnessSlider.SetValue(0)
+	w.brightnessLabel = widget.NewLabel("Brightness: 0")
+
+	w.contrastSlider = widget.NewSlider(0.25, 4.0)
+	w.contrastSlider.Step = 0.05
+	w.contrastSlider.SetValue(1.0)
+	w.contrastLabel = widget.NewLabel("Contrast: 1.00")
+
+	w.gammaSlider = widget.NewSlider(0.2, 5.0)
+	w.gammaSlider.Step = 0.05
+	w.gammaSlider.SetValue(1.0)
+	w.gammaLabel = widget.NewLabel("Gamma: 1.00")
+
+	w.shadowMethodSelect = widget.NewSelect([]string{
+		ShadowMethodGaussian,
+		ShadowMethodMorphological,
+	}, nil)
+	w.shadowMethodSelect.SetSelected(ShadowMethodGaussian)
+
+	w.grayChannelSelect = widget.NewSelect(GrayscaleChannels, nil)
+	w.grayChannelSelect.SetSelected(GrayChannelLuminance)
+
+	w.photoZonesSelect = widget.NewSelect(PhotoZoneModes, nil)
+	w.photoZonesSelect.SetSelected(PhotoZonesBinarize)
+
+	w.shadowStrengthSlider = widget.NewSlider(0.0, 1.0)
+	w.shadowStrengthSlider.Step = 0.05
+	w.shadowStrengthSlider.SetValue(1.0)
+	w.shadowStrengthLabel = widget.NewLabel("Shadow Strength: 1.00")
+
+	w.dewarpCurvatureSlider = widget.NewSlider(0.05, 1.0)
+	w.dewarpCurvatureSlider.Step = 0.05
+	w.dewarpCurvatureSlider.SetValue(0.5)
+	w.dewarpCurvatureLabel = widget.NewLabel("Spine Curvature: 0.50")
+
+	w.stainHueMinSlider = widget.NewSlider(0, 360)
+	w.stainHueMinSlider.SetValue(10)
+	w.stainHueMaxSlider = widget.NewSlider(0, 360)
+	w.stainHueMaxSlider.SetValue(60)
+	w.stainHueLabel = widget.NewLabel("Stain Hue: 10-60°")
+
+	w.stainStrengthSlider = widget.NewSlider(0.0, 1.0)
+	w.stainStrengthSlider.Step = 0.05
+	w.stainStrengthSlider.SetValue(0.8)
+	w.stainStrengthLabel = widget.NewLabel("Stain Strength: 0.80")
+
+	w.dropoutToleranceSlider = widget.NewSlider(5, 60)
+	w.dropoutToleranceSlider.SetValue(25)
+	w.dropoutToleranceLabel = widget.NewLabel("Dropout Tolerance: ±25°")
+
+	w.maxHoleAreaSlider = widget.NewSlider(1, 400)
+	w.maxHoleAreaSlider.SetValue(16)
+	w.maxHoleAreaLabel = widget.NewLabel("Max Hole Area: 16 px")
+
+	w.maxGapSizeSlider = widget.NewSlider(1, 5)
+	w.maxGapSizeSlider.SetValue(2)
+	w.maxGapSizeLabel = widget.NewLabel("Max Gap: 2 px")
+
+	w.neuralModelLabel = widget.NewLabel(describeNeuralModel(""))
+	w.neuralModelLabel.Wrapping = fyne.TextWrapWord
+	w.neuralThresholdSlider = widget.NewSlider(0.05, 0.95)
+	w.neuralThresholdSlider.Step = 0.05
+	w.neuralThresholdSlider.SetValue(0.5)
+	w.neuralThresholdLabel = widget.NewLabel("Ink Probability Threshold: 0.50")
+
+	w.otsu3DBinsSlider = widget.NewSlider(minOtsu3DBins, maxOtsu3DBins)
+	w.otsu3DBinsSlider.Step = 8
+	w.otsu3DBinsSlider.SetValue(defaultOtsu3DBins)
+	w.otsu3DBinsLabel = widget.NewLabel(fmt.Sprintf("3D Histogram Bins: %d", defaultOtsu3DBins))
+
+	w.tsallisQSlider = widget.NewSlider(0.1, 3.0)
+	w.tsallisQSlider.Step = 0.1
+	w.tsallisQSlider.SetValue(defaultTsallisQ)
+	w.tsallisQLabel = widget.NewLabel(fmt.Sprintf("Tsallis q: %.1f", defaultTsallisQ))
+
+	w.methodInfoText = widget.NewRichText()
+	w.methodInfoText.Wrapping = fyne.TextWrapWord
+
 	w.edgePreservationCheck = widget.NewCheck("Edge Preservation", nil)
 	w.noiseRobustnessCheck = widget.NewCheck("Noise Robustness", nil)
 	w.gaussianPreprocessCheck = widget.NewCheck("Gaussian Preprocessing", nil)
@@ -136,18 +312,31 @@ func NewParameterWidgets() *ParameterWidgets {
 	w.normalizeCheck = widget.NewCheck("Normalize Histogram", nil)
 	w.normalizeCheck.SetChecked(true)
 	w.contrastCheck = widget.NewCheck("Adaptive Contrast Enhancement", nil)
+	w.legacyNeighborhoodCheck = widget.NewCheck("Legacy Neighborhood Loops", nil)
 	w.adaptiveWindowCheck = widget.NewCheck("Adaptive Window Sizing", nil)
 	w.morphPostProcessCheck = widget.NewCheck("Morphological Post-Processing", nil)
 	w.homomorphicCheck = widget.NewCheck("Homomorphic Filtering", nil)
 	w.anisotropicCheck = widget.NewCheck("Anisotropic Diffusion", nil)
+	w.equalizeCheck = widget.NewCheck("Equalize Histogram", nil)
+	w.shadowRemovalCheck = widget.NewCheck("Shadow Removal", nil)
+	w.perspectiveCheck = widget.NewCheck("Perspective Correction", nil)
+	w.dewarpCheck = widget.NewCheck("Book Spine Dewarp", nil)
+	w.stainCheck = widget.NewCheck("Stain Suppression", nil)
+	w.dropoutRedCheck = widget.NewCheck("Red", nil)
+	w.dropoutGreenCheck = widget.NewCheck("Green", nil)
+	w.dropoutBlueCheck = widget.NewCheck("Blue", nil)
+	w.fillHolesCheck = widget.NewCheck("Fill Small Holes", nil)
+	w.bridgeGapsCheck = widget.NewCheck("Bridge Stroke Gaps", nil)
 
 	return w
 }
 
-func (pp *ParameterPanel) createStatusMetricsWidgets() {
-	pp.statusLabel = widget.NewLabel("Ready")
+func (pp *ParameterPanel) createMetricsWidgets() {
 	pp.metricsLabel = widget.NewLabel("No metrics available")
 	pp.detailsLabel = widget.NewLabel("Load an image to begin processing")
+	pp.detailsLabel.Wrapping = fyne.TextWrapWord
+	pp.computeMetricsButton = widget.NewButton("Compute Metrics", pp.computeDeferredMetrics)
+	pp.computeMetricsButton.Hide()
 }
 
 func (pp *ParameterPanel) buildLayout() {
@@ -155,14 +344,73 @@ func (pp *ParameterPanel) buildLayout() {
 		createSectionHeader("Basic Parameters"),
 		container.NewVBox(pp.widgets.windowSizeLabel, pp.widgets.windowSizeSlider),
 		container.NewVBox(pp.widgets.histBinsLabel, pp.widgets.histBinsSlider),
+		container.NewVBox(pp.widgets.neighborhoodBinsLabel, pp.widgets.neighborhoodBinsSlider),
 		container.NewVBox(pp.widgets.smoothingLabel, pp.widgets.smoothingSlider),
 	)
 
+	toneSection := container.NewVBox(
+		createSectionHeader("Tone and Lighting"),
+		container.NewVBox(pp.widgets.brightnessLabel, pp.widgets.brightnessSlider),
+		container.NewVBox(pp.widgets.contrastLabel, pp.widgets.contrastSlider),
+		container.NewVBox(pp.widgets.gammaLabel, pp.widgets.gammaSlider),
+		pp.widgets.equalizeCheck,
+		pp.widgets.shadowRemovalCheck,
+		pp.widgets.shadowMethodSelect,
+		container.NewVBox(pp.widgets.shadowStrengthLabel, pp.widgets.shadowStrengthSlider),
+	)
+
+	pp.widgets.adjustCornersButton = widget.NewButton("Adjust Corners...", pp.app.showPageCornerEditor)
+	pp.widgets.splitSpreadButton = widget.NewButton("Split Double Page...", pp.app.showSpreadSplit)
+
+	pageSection := container.NewVBox(
+		createSectionHeader("Page Geometry"),
+		pp.widgets.perspectiveCheck,
+		pp.widgets.adjustCornersButton,
+		pp.widgets.dewarpCheck,
+		container.NewVBox(pp.widgets.dewarpCurvatureLabel, pp.widgets.dewarpCurvatureSlider),
+		pp.widgets.splitSpreadButton,
+	)
+
+	colorSection := container.NewVBox(
+		createSectionHeader("Color"),
+		widget.NewLabel("Grayscale Channel"),
+		pp.widgets.grayChannelSelect,
+		pp.widgets.stainCheck,
+		container.NewVBox(pp.widgets.stainHueLabel, pp.widgets.stainHueMinSlider, pp.widgets.stainHueMaxSlider),
+		container.NewVBox(pp.widgets.stainStrengthLabel, pp.widgets.stainStrengthSlider),
+		widget.NewLabel("Drop Out Ink"),
+		container.NewHBox(pp.widgets.dropoutRedCheck, pp.widgets.dropoutGreenCheck, pp.widgets.dropoutBlueCheck),
+		container.NewVBox(pp.widgets.dropoutToleranceLabel, pp.widgets.dropoutToleranceSlider),
+	)
+
+	pp.widgets.editPostProcessButton = widget.NewButton("Edit Steps...", pp.showPostProcessingEditor)
+	pp.widgets.editExternalButton = widget.NewButton("External Stages...", pp.showExternalStagesEditor)
+
+	postSection := container.NewVBox(
+		createSectionHeader("Post-Processing"),
+		pp.widgets.morphPostProcessCheck,
+		container.NewVBox(pp.widgets.morphKernelLabel, pp.widgets.morphKernelSlider),
+		pp.widgets.postProcessingLabel,
+		pp.widgets.editPostProcessButton,
+		pp.widgets.fillHolesCheck,
+		container.NewVBox(pp.widgets.maxHoleAreaLabel, pp.widgets.maxHoleAreaSlider),
+		pp.widgets.bridgeGapsCheck,
+		container.NewVBox(pp.widgets.maxGapSizeLabel, pp.widgets.maxGapSizeSlider),
+		widget.NewLabel("Photos and Halftones"),
+		pp.widgets.photoZonesSelect,
+		pp.widgets.externalStagesLabel,
+		pp.widgets.editExternalButton,
+	)
+
 	methodSection := container.NewVBox(
 		createSectionHeader("Processing Method"),
 		pp.widgets.processingMethodSelect,
 		container.NewVBox(pp.widgets.pyramidLevelsLabel, pp.widgets.pyramidLevelsSlider),
 		container.NewVBox(pp.widgets.regionGridLabel, pp.widgets.regionGridSlider),
+		pp.buildNeuralModelControls(),
+		container.NewVBox(pp.widgets.otsu3DBinsLabel, pp.widgets.otsu3DBinsSlider),
+		container.NewVBox(pp.widgets.tsallisQLabel, pp.widgets.tsallisQSlider),
+		widget.NewAccordion(widget.NewAccordionItem("About this method", pp.widgets.methodInfoText)),
 	)
 
 	algorithmSection := container.NewVBox(
@@ -173,53 +421,44 @@ func (pp *ParameterPanel) buildLayout() {
 		pp.widgets.useLogCheck,
 		pp.widgets.normalizeCheck,
 		pp.widgets.contrastCheck,
+		widget.NewLabel("Feature Pairing"),
+		pp.widgets.featurePairingSelect,
+		widget.NewLabel("Neighborhood"),
+		pp.widgets.neighborhoodSelect,
+		pp.widgets.legacyNeighborhoodCheck,
 	)
 
-	statusMetricsSection := container.NewVBox(
-		createSectionHeader("Status & Metrics"),
-		pp.statusLabel,
+	metricsSection := container.NewVBox(
+		createSectionHeader("Metrics"),
 		pp.metricsLabel,
+		pp.computeMetricsButton,
 		pp.detailsLabel,
 	)
 
-	allSections := container.NewHBox(
+	parameterSections := container.NewHBox(
 		basicSection,
+		colorSection,
+		toneSection,
+		pageSection,
 		methodSection,
 		algorithmSection,
-		statusMetricsSection,
+		postSection,
 	)
 
-	pp.container = allSections
+	pp.container = parameterSections
+	pp.metricsContainer = metricsSection
 	pp.widgets.processingMethodSelect.SetSelected("Single Scale")
 }
 
 func (pp *ParameterPanel) resetToDefaults() {
-	pp.widgets.windowSizeSlider.SetValue(7)
-	pp.widgets.histBinsSlider.SetValue(0)
-	pp.widgets.smoothingSlider.SetValue(1.0)
-	pp.widgets.pyramidLevelsSlider.SetValue(3)
-	pp.widgets.regionGridSlider.SetValue(64)
-	pp.widgets.morphKernelSlider.SetValue(3)
-	pp.widgets.diffusionIterSlider.SetValue(5)
-	pp.widgets.diffusionKappaSlider.SetValue(30)
-
-	pp.widgets.processingMethodSelect.SetSelected("Single Scale")
-	pp.widgets.neighborhoodSelect.SetSelected("Rectangular")
-	pp.widgets.interpolationSelect.SetSelected("Bilinear")
-
-	pp.widgets.edgePreservationCheck.SetChecked(false)
-	pp.widgets.noiseRobustnessCheck.SetChecked(false)
-	pp.widgets.gaussianPreprocessCheck.SetChecked(true)
-	pp.widgets.useLogCheck.SetChecked(false)
-	pp.widgets.normalizeCheck.SetChecked(true)
-	pp.widgets.contrastCheck.SetChecked(false)
-	pp.widgets.adaptiveWindowCheck.SetChecked(false)
-	pp.widgets.morphPostProcessCheck.SetChecked(false)
-	pp.widgets.homomorphicCheck.SetChecked(false)
-	pp.widgets.anisotropicCheck.SetChecked(false)
+	pp.SetParameters(DefaultOtsuParameters())
+}
 
-	pp.updateLabels()
-	pp.triggerParameterChange()
+func neighborhoodBinsText(value float64) string {
+	if value == 0 {
+		return "Neighborhood Bins: Same"
+	}
+	return fmt.Sprintf("Neighborhood Bins: %.0f", value)
 }
 
 func (pp *ParameterPanel) updateLabels() {
@@ -229,15 +468,39 @@ func (pp *ParameterPanel) updateLabels() {
 	} else {
 		pp.widgets.histBinsLabel.SetText(fmt.Sprintf("Histogram Bins: %.0f", pp.widgets.histBinsSlider.Value))
 	}
+	pp.widgets.neighborhoodBinsLabel.SetText(neighborhoodBinsText(pp.widgets.neighborhoodBinsSlider.Value))
 	pp.widgets.smoothingLabel.SetText(fmt.Sprintf("Smoothing Strength: %.1f", pp.widgets.smoothingSlider.Value))
 	pp.widgets.pyramidLevelsLabel.SetText(fmt.Sprintf("Pyramid Levels: %.0f", pp.widgets.pyramidLevelsSlider.Value))
 	pp.widgets.regionGridLabel.SetText(fmt.Sprintf("Region Grid Size: %.0f", pp.widgets.regionGridSlider.Value))
 	pp.widgets.morphKernelLabel.SetText(fmt.Sprintf("Morphological Kernel: %.0f", pp.widgets.morphKernelSlider.Value))
 	pp.widgets.diffusionIterLabel.SetText(fmt.Sprintf("Diffusion Iterations: %.0f", pp.widgets.diffusionIterSlider.Value))
 	pp.widgets.diffusionKappaLabel.SetText(fmt.Sprintf("Diffusion Kappa: %.1f", pp.widgets.diffusionKappaSlider.Value))
+	pp.widgets.brightnessLabel.SetText(fmt.Sprintf("Brightness: %.0f", pp.widgets.brightnessSlider.Value))
+	pp.widgets.contrastLabel.SetText(fmt.Sprintf("Contrast: %.2f", pp.widgets.contrastSlider.Value))
+	pp.widgets.gammaLabel.SetText(fmt.Sprintf("Gamma: %.2f", pp.widgets.gammaSlider.Value))
+	pp.widgets.shadowStrengthLabel.SetText(fmt.Sprintf("Shadow Strength: %.2f", pp.widgets.shadowStrengthSlider.Value))
+	pp.widgets.dewarpCurvatureLabel.SetText(fmt.Sprintf("Spine Curvature: %.2f", pp.widgets.dewarpCurvatureSlider.Value))
+	pp.updateStainHueLabel()
+	pp.widgets.stainStrengthLabel.SetText(fmt.Sprintf("Stain Strength: %.2f", pp.widgets.stainStrengthSlider.Value))
+	pp.widgets.dropoutToleranceLabel.SetText(fmt.Sprintf("Dropout Tolerance: ±%.0f°", pp.widgets.dropoutToleranceSlider.Value))
+	pp.widgets.maxHoleAreaLabel.SetText(fmt.Sprintf("Max Hole Area: %.0f px", pp.widgets.maxHoleAreaSlider.Value))
+	pp.widgets.maxGapSizeLabel.SetText(fmt.Sprintf("Max Gap: %.0f px", pp.widgets.maxGapSizeSlider.Value))
+	pp.widgets.neuralThresholdLabel.SetText(fmt.Sprintf("Ink Probability Threshold: %.2f", pp.widgets.neuralThresholdSlider.Value))
+	pp.widgets.otsu3DBinsLabel.SetText(fmt.Sprintf("3D Histogram Bins: %.0f", pp.widgets.otsu3DBinsSlider.Value))
+	pp.widgets.tsallisQLabel.SetText(fmt.Sprintf("Tsallis q: %.1f", pp.widgets.tsallisQSlider.Value))
+}
+
+func (pp *ParameterPanel) updateStainHueLabel() {
+	pp.widgets.stainHueLabel.SetText(fmt.Sprintf("Stain Hue: %.0f-%.0f°",
+		pp.widgets.stainHueMinSlider.Value, pp.widgets.stainHueMaxSlider.Value))
 }
 
 func (pp *ParameterPanel) setupParameterListener() {
+	pp.widgets.processingMethodSelect.OnChanged = func(string) {
+		pp.updateMethodInfo()
+	}
+	pp.updateMethodInfo()
+
 	pp.widgets.windowSizeSlider.OnChanged = func(value float64) {
 		intVal := int(value)
 		if intVal%2 == 0 {
@@ -256,30 +519,204 @@ func (pp *ParameterPanel) setupParameterListener() {
 		pp.triggerParameterChange()
 	}
 
+	pp.widgets.neighborhoodBinsSlider.OnChanged = func(value float64) {
+		pp.widgets.neighborhoodBinsLabel.SetText(neighborhoodBinsText(value))
+		pp.triggerParameterChange()
+	}
+
 	pp.widgets.smoothingSlider.OnChanged = func(value float64) {
 		pp.widgets.smoothingLabel.SetText(fmt.Sprintf("Smoothing Strength: %.1f", value))
 		pp.triggerParameterChange()
 	}
+
+	// Tone controls update the grayscale preview on every change; the full
+	// run still goes through the debounced trigger.
+	pp.widgets.brightnessSlider.OnChanged = func(value float64) {
+		pp.widgets.brightnessLabel.SetText(fmt.Sprintf("Brightness: %.0f", value))
+		pp.RefreshTonePreview()
+		pp.triggerParameterChange()
+	}
+
+	pp.widgets.contrastSlider.OnChanged = func(value float64) {
+		pp.widgets.contrastLabel.SetText(fmt.Sprintf("Contrast: %.2f", value))
+		pp.RefreshTonePreview()
+		pp.triggerParameterChange()
+	}
+
+	pp.widgets.gammaSlider.OnChanged = func(value float64) {
+		pp.widgets.gammaLabel.SetText(fmt.Sprintf("Gamma: %.2f", value))
+		pp.RefreshTonePreview()
+		pp.triggerParameterChange()
+	}
+
+	pp.widgets.equalizeCheck.OnChanged = func(bool) {
+		pp.RefreshTonePreview()
+		pp.triggerParameterChange()
+	}
+
+	pp.widgets.shadowRemovalCheck.OnChanged = func(bool) {
+		pp.triggerParameterChange()
+	}
+
+	pp.widgets.shadowMethodSelect.OnChanged = func(string) {
+		pp.triggerParameterChange()
+	}
+
+	pp.widgets.photoZonesSelect.OnChanged = func(string) {
+		pp.triggerParameterChange()
+	}
+
+	pp.widgets.grayChannelSelect.OnChanged = func(string) {
+		pp.RefreshTonePreview()
+		pp.triggerParameterChange()
+	}
+
+	pp.widgets.shadowStrengthSlider.OnChanged = func(value float64) {
+		pp.widgets.shadowStrengthLabel.SetText(fmt.Sprintf("Shadow Strength: %.2f", value))
+		pp.triggerParameterChange()
+	}
+
+	pp.widgets.perspectiveCheck.OnChanged = func(bool) {
+		pp.triggerParameterChange()
+	}
+
+	pp.widgets.dewarpCheck.OnChanged = func(bool) {
+		pp.triggerParameterChange()
+	}
+
+	pp.widgets.dewarpCurvatureSlider.OnChanged = func(value float64) {
+		pp.widgets.dewarpCurvatureLabel.SetText(fmt.Sprintf("Spine Curvature: %.2f", value))
+		pp.triggerParameterChange()
+	}
+
+	pp.widgets.stainCheck.OnChanged = func(bool) {
+		pp.triggerParameterChange()
+	}
+
+	pp.widgets.stainHueMinSlider.OnChanged = func(float64) {
+		pp.updateStainHueLabel()
+		pp.triggerParameterChange()
+	}
+
+	pp.widgets.stainHueMaxSlider.OnChanged = func(float64) {
+		pp.updateStainHueLabel()
+		pp.triggerParameterChange()
+	}
+
+	pp.widgets.stainStrengthSlider.OnChanged = func(value float64) {
+		pp.widgets.stainStrengthLabel.SetText(fmt.Sprintf("Stain Strength: %.2f", value))
+		pp.triggerParameterChange()
+	}
+
+	pp.widgets.morphPostProcessCheck.OnChanged = func(bool) {
+		pp.triggerParameterChange()
+	}
+
+	pp.widgets.morphKernelSlider.OnChanged = func(value float64) {
+		pp.widgets.morphKernelLabel.SetText(fmt.Sprintf("Morphological Kernel: %.0f", value))
+		pp.triggerParameterChange()
+	}
+
+	pp.widgets.maxHoleAreaSlider.OnChanged = func(value float64) {
+		pp.widgets.maxHoleAreaLabel.SetText(fmt.Sprintf("Max Hole Area: %.0f px", value))
+		pp.triggerParameterChange()
+	}
+
+	pp.widgets.maxGapSizeSlider.OnChanged = func(value float64) {
+		pp.widgets.maxGapSizeLabel.SetText(fmt.Sprintf("Max Gap: %.0f px", value))
+		pp.triggerParameterChange()
+	}
+
+	for _, check := range []*widget.Check{
+		pp.widgets.dropoutRedCheck,
+		pp.widgets.dropoutGreenCheck,
+		pp.widgets.dropoutBlueCheck,
+		pp.widgets.fillHolesCheck,
+		pp.widgets.bridgeGapsCheck,
+	} {
+		check.OnChanged = func(bool) {
+			pp.triggerParameterChange()
+		}
+	}
+
+	pp.widgets.dropoutToleranceSlider.OnChanged = func(value float64) {
+		pp.widgets.dropoutToleranceLabel.SetText(fmt.Sprintf("Dropout Tolerance: ±%.0f°", value))
+		pp.triggerParameterChange()
+	}
+
+	pp.widgets.neuralThresholdSlider.OnChanged = func(value float64) {
+		pp.widgets.neuralThresholdLabel.SetText(fmt.Sprintf("Ink Probability Threshold: %.2f", value))
+		pp.triggerParameterChange()
+	}
+
+	pp.widgets.otsu3DBinsSlider.OnChanged = func(value float64) {
+		pp.widgets.otsu3DBinsLabel.SetText(fmt.Sprintf("3D Histogram Bins: %.0f", value))
+		pp.triggerParameterChange()
+	}
+
+	pp.widgets.tsallisQSlider.OnChanged = func(value float64) {
+		pp.widgets.tsallisQLabel.SetText(fmt.Sprintf("Tsallis q: %.1f", value))
+		pp.triggerParameterChange()
+	}
+}
+
+// RefreshTonePreview shows the tone-adjusted grayscale image in the original
+// pane, or the untouched original once every tone control is neutral.
+func (pp *ParameterPanel) RefreshTonePreview() {
+	if pp.applyingParameters {
+		return
+	}
+
+	original := pp.app.processing.GetOriginalImage()
+	if original == nil {
+		return
+	}
+
+	params := pp.GetCurrentParameters()
+	if !hasToneAdjustment(params) {
+		pp.app.imageViewer.SetOriginalImage(original.Image)
+		return
+	}
+
+	if preview := pp.app.processing.ToneAdjustedPreview(params); preview != nil {
+		pp.app.imageViewer.SetOriginalImage(preview)
+	}
 }
 
 func (pp *ParameterPanel) triggerParameterChange() {
+	if pp.applyingParameters {
+		return
+	}
+
 	now := time.Now()
 	if now.Sub(pp.lastProcessTime) < 100*time.Millisecond {
 		return
 	}
 	pp.lastProcessTime = now
 
+	if pp.app.processing.GetOriginalImage() != nil {
+		pp.app.statusBar.SetChanges("Changed since last run: ", pp.app.processing.ChangesSinceLastRun(pp.GetCurrentParameters()))
+	}
+
 	if pp.processingCancel != nil {
 		pp.processingCancel()
 	}
 
-	pp.processingCtx, pp.processingCancel = context.WithCancel(context.Background())
+	pp.processingCtx, pp.processingCancel = context.WithCancel(pp.app.ctx)
 	go pp.delayedProcessing(pp.processingCtx)
 }
 
+// cancelPendingProcessing drops a debounced run queued by a parameter change.
+func (pp *ParameterPanel) cancelPendingProcessing() {
+	if pp.processingCancel != nil {
+		pp.processingCancel()
+		pp.processingCancel = nil
+	}
+}
+
 func (pp *ParameterPanel) delayedProcessing(ctx context.Context) {
 	select {
-	case <-time.After(200 * time.Millisecond):
+	case <-time.After(CurrentPreviewSettings().Debounce):
 	case <-ctx.Done():
 		return
 	}
@@ -287,6 +724,12 @@ func (pp *ParameterPanel) delayedProcessing(ctx context.Context) {
 	if pp.app.processing.GetOriginalImage() == nil {
 		return
 	}
+	if BatterySaverActive() {
+		fyne.Do(func() {
+			pp.app.statusBar.SetStatus("Battery saver: press Process to apply the changes")
+		})
+		return
+	}
 
 	params := pp.GetCurrentParameters()
 	fyne.Do(func() {
@@ -295,46 +738,100 @@ func (pp *ParameterPanel) delayedProcessing(ctx context.Context) {
 }
 
 func (pp *ParameterPanel) GetCurrentParameters() *OtsuParameters {
-	windowSize := int(pp.widgets.windowSizeSlider.Value)
-	if windowSize%2 == 0 {
-		windowSize++
-	}
-
-	return &OtsuParameters{
-		WindowSize:                 windowSize,
-		HistogramBins:              int(pp.widgets.histBinsSlider.Value),
-		SmoothingStrength:          pp.widgets.smoothingSlider.Value,
-		EdgePreservation:           pp.widgets.edgePreservationCheck.Checked,
-		NoiseRobustness:            pp.widgets.noiseRobustnessCheck.Checked,
-		GaussianPreprocessing:      pp.widgets.gaussianPreprocessCheck.Checked,
-		UseLogHistogram:            pp.widgets.useLogCheck.Checked,
-		NormalizeHistogram:         pp.widgets.normalizeCheck.Checked,
-		ApplyContrastEnhancement:   pp.widgets.contrastCheck.Checked,
-		AdaptiveWindowSizing:       pp.widgets.adaptiveWindowCheck.Checked,
-		MultiScaleProcessing:       pp.widgets.processingMethodSelect.Selected == "Multi-Scale Pyramid",
-		PyramidLevels:              int(pp.widgets.pyramidLevelsSlider.Value),
-		NeighborhoodType:           pp.widgets.neighborhoodSelect.Selected,
-		InterpolationMethod:        pp.widgets.interpolationSelect.Selected,
-		MorphologicalPostProcess:   pp.widgets.morphPostProcessCheck.Checked,
-		MorphologicalKernelSize:    int(pp.widgets.morphKernelSlider.Value),
-		HomomorphicFiltering:       pp.widgets.homomorphicCheck.Checked,
-		AnisotropicDiffusion:       pp.widgets.anisotropicCheck.Checked,
-		DiffusionIterations:        int(pp.widgets.diffusionIterSlider.Value),
-		DiffusionKappa:             pp.widgets.diffusionKappaSlider.Value,
-		RegionAdaptiveThresholding: pp.widgets.processingMethodSelect.Selected == "Region Adaptive",
-		RegionGridSize:             int(pp.widgets.regionGridSlider.Value),
+	params := &OtsuParameters{}
+	for _, binding := range pp.bindings {
+		binding.read(params)
 	}
+
+	method := pp.widgets.processingMethodSelect.Selected
+	params.MultiScaleProcessing = method == "Multi-Scale Pyramid"
+	params.RegionAdaptiveThresholding = method == "Region Adaptive"
+	params.NeuralBinarization = method == neuralMethodName
+	params.Otsu3D = method == otsu3DMethodName
+	for _, entropyMethod := range EntropyMethods {
+		if method == entropyMethodEntry(entropyMethod) {
+			params.EntropyMethod = entropyMethod
+		}
+	}
+
+	params.PostProcessing = append([]MorphOperation(nil), pp.postProcessing...)
+	params.ExternalStages = append([]ExternalStage(nil), pp.externalStages...)
+	params.NeuralModel = pp.neuralModel
+	return params
 }
 
-func (pp *ParameterPanel) SetStatus(status string) {
-	pp.statusLabel.SetText("Status: " + status)
+func (pp *ParameterPanel) SetParameters(params *OtsuParameters) {
+	if params == nil {
+		return
+	}
+
+	pp.applyingParameters = true
+	for _, binding := range pp.bindings {
+		binding.write(params)
+	}
+	pp.setPostProcessing(params.PostProcessing)
+	pp.setExternalStages(params.ExternalStages)
+	pp.setNeuralModel(params.NeuralModel)
+
+	switch {
+	case params.NeuralBinarization:
+		pp.widgets.processingMethodSelect.SetSelected(neuralMethodName)
+	case params.MultiScaleProcessing:
+		pp.widgets.processingMethodSelect.SetSelected("Multi-Scale Pyramid")
+	case params.RegionAdaptiveThresholding:
+		pp.widgets.processingMethodSelect.SetSelected("Region Adaptive")
+	case params.Otsu3D:
+		pp.widgets.processingMethodSelect.SetSelected(otsu3DMethodName)
+	case params.EntropyMethod != "":
+		pp.widgets.processingMethodSelect.SetSelected(entropyMethodEntry(params.EntropyMethod))
+	default:
+		pp.widgets.processingMethodSelect.SetSelected("Single Scale")
+	}
+	pp.applyingParameters = false
+
+	pp.updateLabels()
+	pp.RefreshTonePreview()
+	pp.triggerParameterChange()
 }
 
 func (pp *ParameterPanel) SetDetails(details string) {
 	pp.detailsLabel.SetText(details)
 }
 
+// SetMetricsDeferred shows that the battery saver left the metrics of the
+// latest run to be computed on request.
+func (pp *ParameterPanel) SetMetricsDeferred(summary string) {
+	pp.metricsLabel.SetText("Metrics deferred by the battery saver")
+	pp.SetProcessingDetails(summary, nil)
+	pp.computeMetricsButton.Enable()
+	pp.computeMetricsButton.Show()
+}
+
+// computeDeferredMetrics computes the deferred metrics in the background.
+// They are dropped if the result or image was replaced in the meantime,
+// which then shows its own metrics.
+func (pp *ParameterPanel) computeDeferredMetrics() {
+	pp.computeMetricsButton.Disable()
+	pp.metricsLabel.SetText("Computing metrics...")
+	generation := pp.app.processing.ResultGeneration()
+	go func() {
+		metrics := pp.app.processing.GetProcessedMetrics()
+		summary := ""
+		if runs := pp.app.processing.RunHistory(); len(runs) > 0 {
+			summary = runs[len(runs)-1].Summary
+		}
+		fyne.Do(func() {
+			if generation != pp.app.processing.ResultGeneration() {
+				return
+			}
+			pp.SetMetrics(metrics)
+			pp.SetProcessingDetails(summary, metrics)
+		})
+	}()
+}
+
 func (pp *ParameterPanel) SetMetrics(metrics *BinaryImageMetrics) {
+	pp.computeMetricsButton.Hide()
 	if metrics == nil {
 		pp.metricsLabel.SetText("No metrics available")
 		return
@@ -346,6 +843,9 @@ func (pp *ParameterPanel) SetMetrics(metrics *BinaryImageMetrics) {
 		metrics.NRM(),
 		metrics.DRD(),
 	)
+	if metrics.Reference == MetricsReferenceGroundTruth {
+		basicMetrics += " (vs ground truth)"
+	}
 
 	pp.metricsLabel.SetText(basicMetrics)
 
@@ -358,23 +858,35 @@ func (pp *ParameterPanel) SetMetrics(metrics *BinaryImageMetrics) {
 		"mpm", metrics.MPM(),
 		"bfc", metrics.BackgroundForegroundContrast(),
 		"skeleton", metrics.SkeletonSimilarity(),
+		"reference", metrics.Reference,
 	)
 }
 
-func (pp *ParameterPanel) SetProcessingDetails(params *OtsuParameters, result *ImageData, metrics *BinaryImageMetrics) {
-	if params == nil || result == nil || metrics == nil {
-		return
+// SetProcessingDetails shows the plain-language summary of a run above its
+// remaining metrics; metrics may be nil.
+func (pp *ParameterPanel) SetProcessingDetails(summary string, metrics *BinaryImageMetrics) {
+	details := summary
+	if metrics != nil {
+		if details != "" {
+			details += "\n"
+		}
+		details += metricsDetails(metrics)
 	}
+	pp.SetDetails(details)
+}
 
-	allMetrics := fmt.Sprintf("MPM: %.3f | BFC: %.3f | Skeleton: %.3f",
+func metricsDetails(metrics *BinaryImageMetrics) string {
+	return fmt.Sprintf("MPM: %.3f | BFC: %.3f | Skeleton: %.3f",
 		metrics.MPM(),
 		metrics.BackgroundForegroundContrast(),
 		metrics.SkeletonSimilarity(),
 	)
-
-	pp.SetDetails(allMetrics)
 }
 
 func (pp *ParameterPanel) GetContainer() *fyne.Container {
 	return pp.container
 }
+
+func (pp *ParameterPanel) GetMetricsContainer() *fyne.Container {
+	return pp.metricsContainer
+}
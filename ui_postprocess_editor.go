@@ -0,0 +1,171 @@
+//go:build !nogui
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+var (
+	morphOperationNames = []string{
+		MorphOperationOpen,
+		MorphOperationClose,
+		MorphOperationErode,
+		MorphOperationDilate,
+		MorphOperationMedian,
+	}
+	morphShapeNames = []string{
+		MorphShapeEllipse,
+		MorphShapeRectangle,
+		MorphShapeCross,
+	}
+)
+
+func morphSizeOptions() []string {
+	options := make([]string, 0, maxMorphKernelSize/2+1)
+	for size := 1; size <= maxMorphKernelSize; size += 2 {
+		options = append(options, strconv.Itoa(size))
+	}
+	return options
+}
+
+// describeMorphOperations summarizes the pipeline for the panel label.
+func describeMorphOperations(operations []MorphOperation) string {
+	if len(operations) == 0 {
+		return "Steps: default open/close"
+	}
+
+	names := make([]string, len(operations))
+	for i, op := range operations {
+		names[i] = op.String()
+	}
+	return "Steps: " + strings.Join(names, ", ")
+}
+
+// showPostProcessingEditor edits the ordered list of post-processing steps.
+// Applying an empty list returns to the default open/close pair.
+func (pp *ParameterPanel) showPostProcessingEditor() {
+	steps := append([]MorphOperation(nil), pp.postProcessing...)
+	if len(steps) == 0 {
+		steps = append(steps, morphOperations(pp.GetCurrentParameters())...)
+	}
+
+	list := container.NewVBox()
+	var rebuild func()
+	rebuild = func() {
+		list.Objects = nil
+
+		if len(steps) == 0 {
+			list.Add(widget.NewLabel("No steps. Apply to use the default open/close pair."))
+		}
+
+		for i := range steps {
+			index := i
+
+			shapeSelect := widget.NewSelect(morphShapeNames, nil)
+			shapeSelect.SetSelected(steps[index].Shape)
+			shapeSelect.OnChanged = func(value string) {
+				steps[index].Shape = value
+			}
+			if steps[index].Operation == MorphOperationMedian {
+				shapeSelect.Disable()
+			}
+
+			operationSelect := widget.NewSelect(morphOperationNames, nil)
+			operationSelect.SetSelected(steps[index].Operation)
+			operationSelect.OnChanged = func(value string) {
+				steps[index].Operation = value
+				if value == MorphOperationMedian {
+					shapeSelect.Disable()
+				} else {
+					shapeSelect.Enable()
+				}
+			}
+
+			sizeSelect := widget.NewSelect(morphSizeOptions(), nil)
+			sizeSelect.SetSelected(strconv.Itoa(steps[index].Size))
+			sizeSelect.OnChanged = func(value string) {
+				if size, err := strconv.Atoi(value); err == nil {
+					steps[index].Size = size
+				}
+			}
+
+			upButton := widget.NewButtonWithIcon("", theme.MoveUpIcon(), func() {
+				steps[index-1], steps[index] = steps[index], steps[index-1]
+				rebuild()
+			})
+			if index == 0 {
+				upButton.Disable()
+			}
+
+			downButton := widget.NewButtonWithIcon("", theme.MoveDownIcon(), func() {
+				steps[index], steps[index+1] = steps[index+1], steps[index]
+				rebuild()
+			})
+			if index == len(steps)-1 {
+				downButton.Disable()
+			}
+
+			removeButton := widget.NewButtonWithIcon("", theme.DeleteIcon(), func() {
+				steps = append(steps[:index], steps[index+1:]...)
+				rebuild()
+			})
+
+			list.Add(container.NewHBox(
+				widget.NewLabel(fmt.Sprintf("%d.", index+1)),
+				operationSelect,
+				shapeSelect,
+				sizeSelect,
+				upButton,
+				downButton,
+				removeButton,
+			))
+		}
+
+		list.Refresh()
+	}
+	rebuild()
+
+	addButton := widget.NewButtonWithIcon("Add Step", theme.ContentAddIcon(), func() {
+		if len(steps) >= maxMorphOperations {
+			return
+		}
+		steps = append(steps, MorphOperation{Operation: MorphOperationClose, Shape: MorphShapeEllipse, Size: 3})
+		rebuild()
+	})
+	clearButton := widget.NewButton("Clear", func() {
+		steps = nil
+		rebuild()
+	})
+
+	content := container.NewBorder(nil, container.NewHBox(addButton, clearButton), nil, nil,
+		container.NewVScroll(list))
+
+	editor := dialog.NewCustomConfirm("Post-Processing Steps", "Apply", "Cancel", content, func(apply bool) {
+		if !apply {
+			return
+		}
+
+		pp.setPostProcessing(steps)
+		if !pp.widgets.morphPostProcessCheck.Checked {
+			pp.widgets.morphPostProcessCheck.SetChecked(true)
+			return
+		}
+		pp.triggerParameterChange()
+	}, pp.app.window)
+	editor.Resize(fyne.NewSize(620, 420))
+	editor.Show()
+}
+
+func (pp *ParameterPanel) setPostProcessing(operations []MorphOperation) {
+	pp.postProcessing = append([]MorphOperation(nil), operations...)
+	pp.widgets.postProcessingLabel.SetText(describeMorphOperations(pp.postProcessing))
+}
@@ -0,0 +1,130 @@
+//go:build !nogui
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+const (
+	prefCacheMaxMB      = "cache.max_mb"
+	prefColorManagement = "processing.color_management"
+)
+
+// applyCachePreference sets the cache cap from the preferences, trimming in
+// the background since that walks the cache directory.
+func (a *Application) applyCachePreference() {
+	maxMB := a.fyneApp.Preferences().IntWithFallback(prefCacheMaxMB, int(defaultCacheMaxBytes()>>20))
+	go func() {
+		if _, err := GetArtifactCache().SetMaxBytes(int64(maxMB) << 20); err != nil {
+			a.debugSystem.logger.Warn("cache trim failed", "error", err.Error())
+		}
+	}()
+}
+
+func (a *Application) showPreferences() {
+	cache := GetArtifactCache()
+
+	usageLabel := widget.NewLabel("Measuring...")
+	refreshUsage := func() {
+		go func() {
+			usage, err := cache.Usage()
+			fyne.Do(func() {
+				if err != nil {
+					usageLabel.SetText(err.Error())
+					return
+				}
+				usageLabel.SetText(fmt.Sprintf("%s in %d entries, %d in use", formatBytes(usage.Bytes), usage.Entries, usage.InUse))
+			})
+		}()
+	}
+	refreshUsage()
+
+	maxEntry := widget.NewEntry()
+	maxEntry.SetText(strconv.FormatInt(cache.MaxBytes()>>20, 10))
+	maxEntry.Validator = func(text string) error {
+		value, err := strconv.Atoi(text)
+		if err != nil || value < 1 {
+			return fmt.Errorf("enter a size in MB of at least 1")
+		}
+		return nil
+	}
+
+	clearButton := widget.NewButton("Clear Cache", nil)
+	clearButton.OnTapped = func() {
+		clearButton.Disable()
+		go func() {
+			reclaimed, err := cache.Clear()
+			fyne.Do(func() {
+				clearButton.Enable()
+				if err != nil {
+					dialog.ShowError(fmt.Errorf("clear cache: %w", err), a.window)
+				}
+				a.statusBar.SetStatus(fmt.Sprintf("Cache cleared, %s reclaimed", formatBytes(reclaimed)))
+				refreshUsage()
+			})
+		}()
+	}
+
+	pathLabel := widget.NewLabel(cache.Dir())
+	pathLabel.Wrapping = fyne.TextWrapBreak
+
+	maxItem := widget.NewFormItem("Cache size limit (MB)", maxEntry)
+	maxItem.HintText = "Least recently used entries are evicted beyond this size"
+	templateItem, templateEntry := a.outputNameTemplateItem()
+	cropCheck, marginItem, marginEntry := a.autoCropPreferenceItems()
+	previewItem, savePreviewQuality := a.previewQualityPreferenceItem()
+	batteryItem, saveBatterySaver := a.batterySaverPreferenceItem()
+	encodeItem, saveEncodeOptions := a.encodePreferenceItem()
+	form := widget.NewForm(
+		widget.NewFormItem("Cache folder", pathLabel),
+		widget.NewFormItem("Cache usage", usageLabel),
+		maxItem,
+		templateItem,
+		marginItem,
+		previewItem,
+		batteryItem,
+		encodeItem,
+	)
+
+	suggestCheck := a.profileSuggestionCheck()
+	colorCheck := widget.NewCheck("Convert images with an embedded color profile to sRGB on load", nil)
+	colorCheck.SetChecked(ColorManagementEnabled())
+
+	content := container.NewVBox(form, clearButton, widget.NewSeparator(), suggestCheck, colorCheck, cropCheck)
+	preferences := dialog.NewCustomConfirm("Preferences", "Save", "Close", content, func(save bool) {
+		if !save || maxEntry.Validate() != nil || templateEntry.Validate() != nil || marginEntry.Validate() != nil {
+			return
+		}
+		a.fyneApp.Preferences().SetBool(prefSuggestProfiles, suggestCheck.Checked)
+		a.fyneApp.Preferences().SetBool(prefColorManagement, colorCheck.Checked)
+		SetColorManagement(colorCheck.Checked)
+		a.fyneApp.Preferences().SetString(prefOutputNameTemplate, templateEntry.Text)
+		a.fyneApp.Preferences().SetBool(prefAutoCrop, cropCheck.Checked)
+		margin, _ := strconv.Atoi(marginEntry.Text)
+		a.fyneApp.Preferences().SetInt(prefCropMargin, margin)
+		savePreviewQuality()
+		saveBatterySaver()
+		saveEncodeOptions()
+		maxMB, _ := strconv.Atoi(maxEntry.Text)
+		a.fyneApp.Preferences().SetInt(prefCacheMaxMB, maxMB)
+		go func() {
+			reclaimed, err := cache.SetMaxBytes(int64(maxMB) << 20)
+			fyne.Do(func() {
+				if err != nil {
+					dialog.ShowError(fmt.Errorf("trim cache: %w", err), a.window)
+					return
+				}
+				a.statusBar.SetStatus(fmt.Sprintf("Cache limit set to %d MB, %s reclaimed", maxMB, formatBytes(reclaimed)))
+			})
+		}()
+	}, a.window)
+	preferences.Resize(fyne.NewSize(520, 0))
+	preferences.Show()
+}
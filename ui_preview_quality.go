@@ -0,0 +1,84 @@
+//go:build !nogui
+
+package main
+
+import (
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/widget"
+)
+
+const prefPreviewQuality = "preview.quality"
+
+var previewQualityLabels = []struct {
+	quality string
+	label   string
+}{
+	{PreviewQualityAuto, "Automatic"},
+	{PreviewQualityHigh, "High"},
+	{PreviewQualityBalanced, "Balanced"},
+	{PreviewQualityLow, "Low (older machines)"},
+}
+
+// applyPreviewQualityPreference sets the preview settings from the
+// preferences. Automatic quality times the machine in the background and
+// keeps the balanced preset until the measurement is in.
+func (a *Application) applyPreviewQualityPreference() {
+	quality := a.fyneApp.Preferences().StringWithFallback(prefPreviewQuality, PreviewQualityAuto)
+	if quality != PreviewQualityAuto {
+		if err := SetPreviewQuality(quality); err != nil {
+			a.debugSystem.logger.Warn("preview quality preference ignored", "error", err.Error())
+			quality = PreviewQualityAuto
+		} else {
+			return
+		}
+	}
+
+	SetPreviewQuality(PreviewQualityBalanced)
+	go func() {
+		measured, throughput, err := CalibratePreviewQuality()
+		if err != nil {
+			a.debugSystem.logger.Warn("preview calibration failed", "error", err.Error())
+			return
+		}
+		// The preference may have changed while the calibration ran.
+		if a.fyneApp.Preferences().StringWithFallback(prefPreviewQuality, PreviewQualityAuto) == PreviewQualityAuto {
+			SetPreviewQuality(measured)
+		}
+		a.debugSystem.logger.Info("preview calibrated", "quality", measured, "megapixels_per_second", throughput)
+		fyne.Do(func() {
+			a.previewCalibration = fmt.Sprintf("%s, %.0f megapixels per second", measured, throughput)
+		})
+	}()
+}
+
+func (a *Application) previewQualityPreferenceItem() (*widget.FormItem, func()) {
+	labels := make([]string, len(previewQualityLabels))
+	current := a.fyneApp.Preferences().StringWithFallback(prefPreviewQuality, PreviewQualityAuto)
+	selected := previewQualityLabels[0].label
+	for i, entry := range previewQualityLabels {
+		labels[i] = entry.label
+		if entry.quality == current {
+			selected = entry.label
+		}
+	}
+	qualitySelect := widget.NewSelect(labels, nil)
+	qualitySelect.SetSelected(selected)
+
+	item := widget.NewFormItem("Preview quality", qualitySelect)
+	item.HintText = "Preview resolution and how long changes settle before a run"
+	if a.previewCalibration != "" {
+		item.HintText += "; measured: " + a.previewCalibration
+	}
+
+	save := func() {
+		for _, entry := range previewQualityLabels {
+			if entry.label == qualitySelect.Selected && entry.quality != current {
+				a.fyneApp.Preferences().SetString(prefPreviewQuality, entry.quality)
+				a.applyPreviewQualityPreference()
+			}
+		}
+	}
+	return item, save
+}
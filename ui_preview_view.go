@@ -0,0 +1,236 @@
+//go:build !nogui
+
+package main
+
+import (
+	"context"
+	"image"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/widget"
+	xdraw "golang.org/x/image/draw"
+)
+
+// previewScaleCheckInterval is how often the image viewer looks for a
+// change of display scale, which Fyne does not report.
+const previewScaleCheckInterval = 500 * time.Millisecond
+
+// previewView shows an image rasterized at the device pixel size of the
+// area it fills. Left to itself Fyne uploads the whole image and lets the
+// GPU scale it, which aliases large scans and blurs them at scale factors
+// other than the one the texture was made for. The source is halved into a
+// pyramid once; each rasterization resamples the smallest level that still
+// covers the target, so resizing the window or moving it to a display of
+// another scale stays cheap.
+type previewView struct {
+	widget.BaseWidget
+	display *canvas.Image
+	source  image.Image
+	levels  []image.Image
+
+	// pixels and scale are the size and device scale of the last
+	// rasterization, rect the part of the source it showed.
+	pixels image.Point
+	scale  float32
+	rect   image.Rectangle
+
+	// In strip mode a tall image fills the width of the view and only the
+	// rows at stripOffset, 0 at the top and 1 at the bottom, are shown.
+	strip        bool
+	stripOffset  float32
+	visible      image.Image
+	onScrolled   func(dy float32)
+	onStripMoved func()
+
+	// zoom magnifies the shown rows around zoomCenter, a fraction of
+	// their width and height; 1 fits them. onZoomed, set while comparing,
+	// takes scrolling instead of onScrolled.
+	zoom       float32
+	zoomCenter fyne.Position
+	onZoomed   func(pos fyne.Position, dy float32)
+}
+
+func newPreviewView() *previewView {
+	pv := &previewView{display: canvas.NewImageFromImage(nil), zoom: 1}
+	pv.display.FillMode = canvas.ImageFillContain
+	pv.display.ScaleMode = canvas.ImageScaleSmooth
+	pv.ExtendBaseWidget(pv)
+	return pv
+}
+
+func (pv *previewView) CreateRenderer() fyne.WidgetRenderer {
+	return &previewViewRenderer{view: pv}
+}
+
+// Image is the shown part of the source image, with the source's
+// coordinates; pointer input maps to its pixels.
+func (pv *previewView) Image() image.Image {
+	if pv.visible != nil {
+		return pv.visible
+	}
+	return pv.source
+}
+
+func (pv *previewView) SetImage(img image.Image) {
+	pv.source = img
+	pv.levels = nil
+	pv.pixels = image.Point{}
+	pv.rect = image.Rectangle{}
+	pv.visible = nil
+	pv.rasterize()
+}
+
+func (pv *previewView) SetMinSize(size fyne.Size) {
+	pv.display.SetMinSize(size)
+}
+
+// checkScale rasterizes again when the window has moved to a display of
+// another scale.
+func (pv *previewView) checkScale() {
+	if pv.source != nil && pv.deviceScale() != pv.scale {
+		pv.rasterize()
+	}
+}
+
+// deviceScale is the number of device pixels per Fyne unit where the view
+// is shown, 1 before it is shown.
+func (pv *previewView) deviceScale() float32 {
+	c := fyne.CurrentApp().Driver().CanvasForObject(pv)
+	if c == nil {
+		return 1
+	}
+	x, _ := c.PixelCoordinateForPosition(fyne.NewPos(100, 0))
+	if x <= 0 {
+		return 1
+	}
+	return float32(x) / 100
+}
+
+func (pv *previewView) rasterize() {
+	size := pv.Size()
+	if pv.source == nil || size.Width <= 0 || size.Height <= 0 {
+		pv.display.Image = pv.source
+		pv.display.Refresh()
+		return
+	}
+
+	rect := pv.viewRect()
+	visible := pv.source
+	if rect != pv.source.Bounds() {
+		if sub, ok := pv.source.(interface {
+			SubImage(image.Rectangle) image.Image
+		}); ok {
+			visible = sub.SubImage(rect)
+		}
+	}
+
+	scale := pv.deviceScale()
+	bounds := visible.Bounds()
+	fit := min32(size.Width/float32(bounds.Dx()), size.Height/float32(bounds.Dy())) * scale
+	target := bounds.Size()
+	if fit < 1 {
+		target = image.Pt(max(1, int(float32(bounds.Dx())*fit+0.5)), max(1, int(float32(bounds.Dy())*fit+0.5)))
+	}
+	if target == pv.pixels && scale == pv.scale && rect == pv.rect {
+		return
+	}
+	moved := rect != pv.rect
+	pv.pixels, pv.scale, pv.rect, pv.visible = target, scale, rect, visible
+
+	// Zoomed past one image pixel per device pixel, pixels are drawn as
+	// blocks so stroke edges stay sharp.
+	pv.display.ScaleMode = canvas.ImageScaleSmooth
+	if fit > 1 && pv.zoom > 1 {
+		pv.display.ScaleMode = canvas.ImageScalePixels
+	}
+
+	if visible == pv.source && target == bounds.Size() {
+		pv.display.Image = pv.source
+	} else {
+		// Strips and zoomed views are scaled from their part of the
+		// smallest level that covers them; they are copied even at full
+		// size since canvas images start at the origin.
+		source := pv.source.Bounds()
+		level := pv.level(image.Pt(target.X*source.Dx()/bounds.Dx(), target.Y*source.Dy()/bounds.Dy()))
+		levelRect := scaleRect(rect, source, level.Bounds())
+		rasterized := newImageLike(level, image.Rectangle{Max: target})
+		xdraw.BiLinear.Scale(rasterized, rasterized.Bounds(), level, levelRect, xdraw.Src, nil)
+		pv.display.Image = rasterized
+	}
+	pv.display.Refresh()
+
+	if moved && pv.onStripMoved != nil {
+		pv.onStripMoved()
+	}
+}
+
+// level returns the smallest pyramid level that covers target, halving the
+// source as far as needed.
+func (pv *previewView) level(target image.Point) image.Image {
+	if pv.levels == nil {
+		pv.levels = []image.Image{pv.source}
+	}
+	for i := 0; ; i++ {
+		size := pv.levels[i].Bounds().Size()
+		if size.X/2 < target.X || size.Y/2 < target.Y {
+			return pv.levels[i]
+		}
+		if i+1 == len(pv.levels) {
+			half := newImageLike(pv.levels[i], image.Rect(0, 0, size.X/2, size.Y/2))
+			xdraw.ApproxBiLinear.Scale(half, half.Bounds(), pv.levels[i], pv.levels[i].Bounds(), xdraw.Src, nil)
+			pv.levels = append(pv.levels, half)
+		}
+	}
+}
+
+type previewViewRenderer struct {
+	view *previewView
+}
+
+func (r *previewViewRenderer) Layout(size fyne.Size) {
+	r.view.display.Move(fyne.NewPos(0, 0))
+	r.view.display.Resize(size)
+	r.view.rasterize()
+}
+
+func (r *previewViewRenderer) MinSize() fyne.Size {
+	return r.view.display.MinSize()
+}
+
+func (r *previewViewRenderer) Refresh() {
+	r.view.rasterize()
+}
+
+func (r *previewViewRenderer) Objects() []fyne.CanvasObject {
+	return []fyne.CanvasObject{r.view.display}
+}
+
+func (r *previewViewRenderer) Destroy() {}
+
+// Run rasterizes the previews again whenever the window moves to a display
+// of another scale, and blinks them while asked to, until ctx is cancelled.
+func (iv *ImageViewer) Run(ctx context.Context) {
+	ticker := time.NewTicker(previewScaleCheckInterval)
+	defer ticker.Stop()
+	blinkTicker := time.NewTicker(blinkInterval)
+	defer blinkTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			fyne.Do(func() {
+				for _, pv := range iv.previews() {
+					pv.checkScale()
+				}
+			})
+		case <-blinkTicker.C:
+			if iv.Blinking() {
+				fyne.Do(iv.blink)
+			}
+		}
+	}
+}
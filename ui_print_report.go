@@ -0,0 +1,108 @@
+//go:build !nogui
+
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+)
+
+// qaReport collects the latest run for a printed report, or returns nil
+// before the first run.
+func (a *Application) qaReport() *QAReport {
+	processed := a.processing.GetProcessedImage()
+	original := a.processing.GetOriginalImage()
+	if processed == nil || original == nil {
+		return nil
+	}
+
+	report := &QAReport{
+		Source:    a.sourceName,
+		CreatedAt: time.Now(),
+		Original:  original.Image,
+		Result:    processed.Image,
+		Params:    a.parameters.GetCurrentParameters(),
+		Warnings:  a.processing.QualityWarnings(),
+	}
+	if a.notes.OnReports() {
+		report.Notes = a.processing.Annotations()
+	}
+	if report.Source == "" {
+		report.Source = "untitled"
+	}
+	// Metrics come first: computing deferred ones completes the summary.
+	if metrics := a.processing.GetProcessedMetrics(); metrics != nil {
+		report.Metrics = NewMetricsReport(metrics)
+	}
+	if history := a.processing.RunHistory(); len(history) > 0 {
+		report.Params = history[len(history)-1].Params
+		report.Summary = history[len(history)-1].Summary
+	}
+	return report
+}
+
+// showPrintReport writes the report to a temporary PDF and opens it in the
+// system viewer, whose print dialog sends it to paper.
+func (a *Application) showPrintReport() {
+	report := a.qaReport()
+	if report == nil {
+		dialog.ShowInformation("Print Report", "Process an image first.", a.window)
+		return
+	}
+
+	a.statusBar.SetStatus("Preparing report...")
+	go func() {
+		path := filepath.Join(os.TempDir(), fmt.Sprintf("otsu-report-%s.pdf", report.CreatedAt.Format("20060102-150405")))
+		err := report.WritePDF(path)
+		fyne.Do(func() {
+			if err != nil {
+				dialog.ShowError(err, a.window)
+				a.statusBar.SetStatus("Report failed")
+				return
+			}
+			if err := a.fyneApp.OpenURL(&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}); err != nil {
+				dialog.ShowError(fmt.Errorf("open %s for printing: %w", path, err), a.window)
+				return
+			}
+			a.statusBar.SetStatus("Report opened for printing: " + path)
+		})
+	}()
+}
+
+// showExportReport saves the report as a PDF.
+func (a *Application) showExportReport() {
+	report := a.qaReport()
+	if report == nil {
+		dialog.ShowInformation("Export Report", "Process an image first.", a.window)
+		return
+	}
+
+	save := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, a.window)
+			return
+		}
+		if writer == nil {
+			return
+		}
+		defer writer.Close()
+
+		data, err := report.PDF()
+		if err == nil {
+			_, err = writer.Write(data)
+		}
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("export report: %w", err), a.window)
+			return
+		}
+		a.statusBar.SetStatus("Exported report " + writer.URI().Name())
+	}, a.window)
+	save.SetFileName(a.outputFileName() + "_report.pdf")
+	save.Show()
+}
@@ -0,0 +1,59 @@
+//go:build !nogui
+
+package main
+
+import (
+	"fmt"
+
+	"fyne.io/fyne/v2/widget"
+)
+
+const prefSuggestProfiles = "profiles.suggest"
+
+// suggestProfile offers the tuned profile whose dataset the loaded image
+// resembles. A profile is offered once until a different one matches, so
+// live capture does not repeat the toast on every frame.
+func (a *Application) suggestProfile() {
+	if !a.fyneApp.Preferences().BoolWithFallback(prefSuggestProfiles, true) {
+		return
+	}
+
+	registry, err := LoadProfileRegistry(DefaultProfileRegistryPath())
+	if err != nil {
+		a.debugSystem.logger.Warn("profile registry unavailable", "error", err.Error())
+		return
+	}
+	if len(registry.Profiles) == 0 {
+		return
+	}
+
+	signature, err := a.processing.ImageSignature()
+	if err != nil {
+		a.debugSystem.logger.Warn("image signature failed", "error", err.Error())
+		return
+	}
+
+	profile, distance := registry.Suggest(signature)
+	a.debugSystem.logger.Debug("profile suggestion", "signature", signature, "distance", distance)
+	if profile == nil {
+		a.suggestedProfile = ""
+		return
+	}
+	if profile.Name == a.suggestedProfile {
+		return
+	}
+	a.suggestedProfile = profile.Name
+
+	a.toaster.Show("Matching Profile", fmt.Sprintf("This image resembles the images of profile %s.", profile.Describe()), "Apply Profile", func() {
+		params := *profile.Params
+		a.parameters.SetParameters(&params)
+		a.appliedProfile = profile
+		a.statusBar.SetStatus(fmt.Sprintf("Applied profile %s", profile.Name))
+	})
+}
+
+func (a *Application) profileSuggestionCheck() *widget.Check {
+	check := widget.NewCheck("Suggest tuned profiles for matching images", nil)
+	check.SetChecked(a.fyneApp.Preferences().BoolWithFallback(prefSuggestProfiles, true))
+	return check
+}
@@ -0,0 +1,46 @@
+//go:build !nogui
+
+package main
+
+import (
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+)
+
+// showExportRegionAudit writes the per-region audit of the latest
+// region-adaptive run as JSON.
+func (a *Application) showExportRegionAudit() {
+	audit := a.processing.RegionAudit()
+	if audit == nil {
+		dialog.ShowInformation("Export Region Audit", "Process the image with the Region Adaptive method first.", a.window)
+		return
+	}
+	if original := a.processing.GetOriginalImage(); original != nil && original.ScaleFactor > 0 {
+		audit.ScaleFactor = original.ScaleFactor
+	}
+
+	save := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, a.window)
+			return
+		}
+		if writer == nil {
+			return
+		}
+		writer.Close()
+
+		if writer.URI().Scheme() != "file" {
+			dialog.ShowError(fmt.Errorf("the region audit can only be exported to a local file"), a.window)
+			return
+		}
+		if err := audit.Write(writer.URI().Path()); err != nil {
+			dialog.ShowError(err, a.window)
+			return
+		}
+		a.statusBar.SetStatus(fmt.Sprintf("Exported audit of %d regions", len(audit.Regions)))
+	}, a.window)
+	save.SetFileName("region_audit.json")
+	save.Show()
+}
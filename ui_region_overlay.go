@@ -0,0 +1,108 @@
+//go:build !nogui
+
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+)
+
+// Regions are filled with regionOverlayAlpha of their threshold color, and
+// overlapping windows, which cover each pixel several times, with
+// regionOverlapAlpha.
+const (
+	regionOverlayAlpha = 56
+	regionOverlapAlpha = 20
+)
+
+var regionFallbackColor = color.NRGBA{R: 128, G: 128, B: 128, A: 255}
+
+// showRegions outlines the regions or overlapping windows of the latest
+// region-adaptive run over the processed image, colored from blue for the
+// lowest gray threshold of the run to red for the highest. Regions that fell
+// back to another method are outlined in gray.
+func (ip *InspectorPanel) showRegions(show bool) {
+	ip.app.imageViewer.ClearProcessedOverlay(overlayRegions)
+	ip.regionLabel.SetText("")
+	if !show {
+		return
+	}
+
+	audit := ip.app.processing.RegionAudit()
+	processed := ip.app.processing.GetProcessedImage()
+	switch {
+	case audit == nil || processed == nil:
+		ip.regionLabel.SetText("The latest run did not use Region Adaptive")
+		return
+	case audit.Fallback != "":
+		ip.regionLabel.SetText("No regions: " + describeRegionAudit(audit))
+		return
+	case audit.Width != processed.Width || audit.Height != processed.Height:
+		ip.regionLabel.SetText("The regions do not fit the result")
+		return
+	}
+
+	lowest, highest, thresholded := regionThresholdRange(audit)
+	alpha := uint8(regionOverlayAlpha)
+	if audit.Overlapping {
+		alpha = regionOverlapAlpha
+	}
+
+	items := make([]*overlayItem, 0, len(audit.Regions))
+	for _, region := range audit.Regions {
+		bounds := image.Rect(region.Bounds.X, region.Bounds.Y, region.Bounds.X+region.Bounds.Width, region.Bounds.Y+region.Bounds.Height)
+		if region.Threshold == nil {
+			items = append(items, newOverlayRect(bounds, regionFallbackColor, color.Transparent))
+			continue
+		}
+		stroke := thresholdColor(region.Threshold[0], lowest, highest)
+		fill := stroke
+		fill.A = alpha
+		items = append(items, newOverlayRect(bounds, stroke, fill))
+	}
+	ip.app.imageViewer.SetProcessedOverlay(overlayRegions, overlayZRegions, items...)
+
+	kind := "regions"
+	if audit.Overlapping {
+		kind = "overlapping windows"
+	}
+	text := fmt.Sprintf("%d %s of %d px", len(audit.Regions), kind, audit.GridSize)
+	if thresholded > 0 {
+		text += fmt.Sprintf(", gray threshold bin %d (blue) to %d (red)", lowest, highest)
+	}
+	if fallbacks := len(audit.Regions) - thresholded; fallbacks > 0 {
+		text += fmt.Sprintf(", %d in gray fell back", fallbacks)
+	}
+	ip.regionLabel.SetText(text)
+}
+
+// regionThresholdRange returns the lowest and highest gray threshold bins of
+// the thresholded regions of audit and how many there are.
+func regionThresholdRange(audit *RegionAudit) (lowest, highest, thresholded int) {
+	for _, region := range audit.Regions {
+		if region.Threshold == nil {
+			continue
+		}
+		if thresholded == 0 || region.Threshold[0] < lowest {
+			lowest = region.Threshold[0]
+		}
+		if thresholded == 0 || region.Threshold[0] > highest {
+			highest = region.Threshold[0]
+		}
+		thresholded++
+	}
+	return lowest, highest, thresholded
+}
+
+// thresholdColor places threshold between lowest, blue, and highest, red.
+func thresholdColor(threshold, lowest, highest int) color.NRGBA {
+	t := float32(0.5)
+	if highest > lowest {
+		t = float32(threshold-lowest) / float32(highest-lowest)
+	}
+	blend := func(from, to uint8) uint8 {
+		return uint8(float32(from) + t*(float32(to)-float32(from)))
+	}
+	return color.NRGBA{R: blend(40, 230), G: blend(110, 50), B: blend(230, 40), A: 255}
+}
@@ -0,0 +1,231 @@
+//go:build !nogui
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"path/filepath"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// viewCommand opens saved results read-only, for reviewers who should not
+// change them.
+const viewCommand = "view"
+
+var touchUpOutlineColor = color.NRGBA{R: 40, G: 120, B: 230, A: 255}
+
+// runResultViewer shows each result given on the command line in a window
+// of its own, with what its sidecar and provenance record say about it.
+// The windows cannot process, edit or save anything.
+func runResultViewer(args []string) int {
+	flags := flag.NewFlagSet(viewCommand, flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), `Usage: otsu-obliterator view [result image]...
+
+Shows saved results read-only, with the parameters, metrics, notes and
+touch-ups recorded in their <result>.json sidecar and embedded provenance.
+`)
+	}
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+
+	fyneApp := newFyneApp()
+	paths := openFileArguments(flags.Args())
+	if len(paths) == 0 {
+		paths = []string{""}
+	}
+
+	viewers := make([]*resultViewer, len(paths))
+	for i := range paths {
+		viewers[i] = newResultViewer(fyneApp)
+		viewers[i].window.Show()
+	}
+	fyneApp.Lifecycle().SetOnStarted(func() {
+		for i, path := range paths {
+			if path != "" {
+				viewers[i].load(path)
+			}
+		}
+	})
+	fyneApp.Run()
+	return 0
+}
+
+// resultViewer is a window that shows one saved result with its notes and
+// touch-ups drawn over it, and its record beside it.
+type resultViewer struct {
+	window  fyne.Window
+	preview *previewView
+	view    *imageTapTarget
+	details *fyne.Container
+}
+
+func newResultViewer(fyneApp fyne.App) *resultViewer {
+	rv := &resultViewer{
+		window:  fyneApp.NewWindow(AppName + " Viewer"),
+		preview: newPreviewView(),
+		details: container.NewVBox(widget.NewLabel("Open a saved result to review it.")),
+	}
+	rv.preview.SetMinSize(fyne.NewSize(400, 400))
+	rv.view = newImageTapTarget(rv.preview)
+
+	split := container.NewHSplit(rv.view, container.NewVScroll(rv.details))
+	split.SetOffset(0.65)
+	open := widget.NewButtonWithIcon("Open Result...", theme.FolderOpenIcon(), rv.showOpen)
+	rv.window.SetContent(container.NewBorder(container.NewHBox(open), nil, nil, nil, split))
+	rv.window.Resize(fyne.NewSize(1100, 750))
+	return rv
+}
+
+func (rv *resultViewer) showOpen() {
+	picker := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, rv.window)
+			return
+		}
+		if reader == nil {
+			return
+		}
+		reader.Close()
+		if reader.URI().Scheme() != "file" {
+			dialog.ShowError(fmt.Errorf("results can only be opened from a local file"), rv.window)
+			return
+		}
+		rv.load(reader.URI().Path())
+	}, rv.window)
+	picker.SetFilter(storage.NewExtensionFileFilter([]string{".png", ".jpg", ".jpeg", ".tif", ".tiff"}))
+	picker.Show()
+}
+
+func (rv *resultViewer) load(path string) {
+	rv.window.SetTitle(fmt.Sprintf("%s - %s Viewer", filepath.Base(path), AppName))
+	go func() {
+		pkg, err := LoadResultPackage(path)
+		fyne.Do(func() {
+			if err != nil {
+				GetDebugSystem().logger.Error("open result failed", "path", path, "error", err.Error())
+				dialog.ShowError(err, rv.window)
+				return
+			}
+			rv.show(pkg)
+		})
+	}()
+}
+
+func (rv *resultViewer) show(pkg *ResultPackage) {
+	rv.preview.SetImage(pkg.Image)
+	rv.view.clearOverlay(overlayNotes)
+	rv.view.clearOverlay(overlayTouchUp)
+	rv.details.Objects = nil
+
+	sidecar := pkg.Sidecar
+	if sidecar == nil {
+		sidecar = &OutputSidecar{}
+	}
+
+	bounds := pkg.Image.Bounds()
+	about := []string{filepath.Base(pkg.Path), fmt.Sprintf("%dx%d pixels", bounds.Dx(), bounds.Dy())}
+	if pkg.Sidecar == nil {
+		about = append(about, "No sidecar was saved with this result.")
+	}
+	if sidecar.Source != "" {
+		about = append(about, "Source: "+sidecar.Source)
+	}
+	if sidecar.AppVersion != "" {
+		about = append(about, fmt.Sprintf("Saved by %s %s", AppName, sidecar.AppVersion))
+	}
+	if sidecar.ScaleFactor != 0 && sidecar.ScaleFactor != 1 {
+		about = append(about, fmt.Sprintf("Processed at %.3g times the source's %dx%d", sidecar.ScaleFactor, sidecar.SourceWidth, sidecar.SourceHeight))
+	}
+	if sidecar.ColorTransform != "" {
+		about = append(about, "Color: "+sidecar.ColorTransform)
+	}
+	if crop := sidecar.Crop; crop != nil {
+		about = append(about, fmt.Sprintf("Cropped to content with a %d pixel margin from %dx%d", crop.Margin, crop.UncroppedWidth, crop.UncroppedHeight))
+	}
+	rv.section("Result", about...)
+
+	if sidecar.Summary != "" {
+		rv.section("Summary", sidecar.Summary)
+	}
+
+	var metrics []string
+	if sidecar.Metrics != nil {
+		for _, metric := range sidecar.Metrics.namedValues() {
+			metrics = append(metrics, fmt.Sprintf("%s: %.4f", metric.name, metric.value))
+		}
+	} else {
+		metrics = append(metrics, "No metrics were recorded.")
+	}
+	rv.section("Metrics", metrics...)
+
+	if parameters := listParameters(pkg.Parameters()); len(parameters) > 0 {
+		rv.section("Parameters", parameters...)
+	} else {
+		rv.section("Parameters", "No parameters were recorded.")
+	}
+
+	if len(sidecar.Notes) > 0 {
+		var lines []string
+		var pins []*overlayItem
+		for _, note := range sidecar.Notes {
+			by := ""
+			if note.Author != "" {
+				by = " by " + note.Author
+			}
+			lines = append(lines, fmt.Sprintf("%d. %s%s", note.ID, note.Text, by))
+
+			pin := newOverlayHandle(pkg.ResultPoint(image.Pt(note.X, note.Y)), notePinColor, nil)
+			pin.onTapped = func() {
+				dialog.ShowInformation(fmt.Sprintf("Note %d", note.ID), note.Text+"\n\n"+
+					note.Created.Local().Format("2006-01-02 15:04")+by, rv.window)
+			}
+			pins = append(pins, pin)
+		}
+		rv.section("Notes", lines...)
+		rv.view.setOverlay(overlayNotes, overlayZNotes, pins...)
+	}
+
+	if touchUp := sidecar.TouchUp; touchUp != nil {
+		var outlines []*overlayItem
+		for _, region := range touchUp.Regions {
+			corner := pkg.ResultPoint(image.Pt(region.X, region.Y))
+			outlines = append(outlines, newOverlayRect(image.Rectangle{Min: corner, Max: corner.Add(image.Pt(region.Width, region.Height))},
+				touchUpOutlineColor, color.Transparent))
+		}
+		rv.section("Touch-Up", fmt.Sprintf("%d pixels in %d regions were painted by hand; they are outlined in blue.", touchUp.Pixels, len(touchUp.Regions)))
+		rv.view.setOverlay(overlayTouchUp, overlayZTouchUp, outlines...)
+	}
+
+	if provenance := pkg.Provenance; provenance != nil {
+		rv.section("Provenance",
+			"Signed with key "+ProvenanceKeyFingerprint(provenance.PublicKey),
+			"Algorithm: "+provenance.Record.Algorithm,
+			"Steps: "+strings.Join(provenance.Record.Steps, ", "),
+			"Check it with `otsu-obliterator provenance verify`.")
+	}
+	rv.details.Refresh()
+}
+
+// section adds a titled block of lines to the details.
+func (rv *resultViewer) section(title string, lines ...string) {
+	text := widget.NewLabel(strings.Join(lines, "\n"))
+	text.Wrapping = fyne.TextWrapWord
+	rv.details.Add(createSectionHeader(title))
+	rv.details.Add(text)
+}
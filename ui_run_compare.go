@@ -0,0 +1,150 @@
+//go:build !nogui
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// showRunComparisonDialog compares the results of two runs of the current
+// image and exports their XOR difference mask with a summary.
+func (a *Application) showRunComparisonDialog() {
+	runs := a.processing.RunHistory()
+	if len(runs) < 2 {
+		dialog.ShowInformation("Compare Runs", "Process the image at least twice to compare runs.", a.window)
+		return
+	}
+
+	names := make([]string, len(runs))
+	for i, run := range runs {
+		names[i] = run.String()
+	}
+	runID := func(name string) int {
+		for i, candidate := range names {
+			if candidate == name {
+				return runs[i].ID
+			}
+		}
+		return 0
+	}
+
+	fromSelect := widget.NewSelect(names, nil)
+	toSelect := widget.NewSelect(names, nil)
+	summary := widget.NewLabel("")
+	runSummaries := widget.NewLabel("")
+	runSummaries.Wrapping = fyne.TextWrapWord
+	exportButton := widget.NewButton("Export Difference...", nil)
+
+	update := func(string) {
+		if fromSelect.Selected == "" || toSelect.Selected == "" {
+			return
+		}
+
+		diff, err := a.processing.DiffRuns(runID(fromSelect.Selected), runID(toSelect.Selected))
+		if err != nil {
+			summary.SetText(err.Error())
+			exportButton.Disable()
+			return
+		}
+		defer diff.Close()
+
+		summary.SetText(describeRunDifference(diff))
+		var described []string
+		for _, run := range runs {
+			if run.Summary != "" && (run.ID == diff.FromRun || run.ID == diff.ToRun) {
+				described = append(described, fmt.Sprintf("Run %d: %s", run.ID, run.Summary))
+			}
+		}
+		runSummaries.SetText(strings.Join(described, "\n"))
+		exportButton.Enable()
+	}
+	fromSelect.OnChanged = update
+	toSelect.OnChanged = update
+
+	exportButton.OnTapped = func() {
+		a.exportRunDifference(runID(fromSelect.Selected), runID(toSelect.Selected))
+	}
+
+	fromSelect.SetSelected(names[len(names)-2])
+	toSelect.SetSelected(names[len(names)-1])
+
+	content := container.NewVBox(
+		widget.NewForm(
+			widget.NewFormItem("From", fromSelect),
+			widget.NewFormItem("To", toSelect),
+		),
+		runSummaries,
+		summary,
+		exportButton,
+	)
+
+	d := dialog.NewCustom("Compare Runs", "Close", content, a.window)
+	d.Resize(fyne.NewSize(560, 0))
+	d.Show()
+}
+
+func describeRunDifference(diff *RunDifference) string {
+	total := diff.Width * diff.Height
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d of %d pixels changed (%.3f%%): %d became ink, %d became paper\n",
+		diff.ChangedPixels, total, 100*float64(diff.ChangedPixels)/float64(max(1, total)),
+		diff.InkAdded, diff.InkRemoved)
+
+	if len(diff.ParameterChanges) == 0 {
+		b.WriteString("Same parameters\n")
+	}
+	for _, change := range diff.ParameterChanges {
+		fmt.Fprintf(&b, "%s\n", change)
+	}
+
+	b.WriteString("Changed pixels by region:")
+	for _, row := range diff.Regions {
+		b.WriteString("\n")
+		for gx, count := range row {
+			if gx > 0 {
+				b.WriteString("\t")
+			}
+			fmt.Fprintf(&b, "%d", count)
+		}
+	}
+	return b.String()
+}
+
+func (a *Application) exportRunDifference(fromID, toID int) {
+	save := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, a.window)
+			return
+		}
+		if writer == nil {
+			return
+		}
+		writer.Close()
+
+		if writer.URI().Scheme() != "file" {
+			dialog.ShowError(fmt.Errorf("the difference can only be exported to a local file"), a.window)
+			return
+		}
+
+		diff, err := a.processing.DiffRuns(fromID, toID)
+		if err != nil {
+			dialog.ShowError(err, a.window)
+			return
+		}
+		defer diff.Close()
+
+		if err := diff.Export(writer.URI().Path()); err != nil {
+			dialog.ShowError(err, a.window)
+			return
+		}
+		a.statusBar.SetStatus(fmt.Sprintf("Exported difference of runs %d and %d", fromID, toID))
+	}, a.window)
+	save.SetFileName(fmt.Sprintf("difference_run%d_run%d.png", fromID, toID))
+	save.Show()
+}
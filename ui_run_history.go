@@ -0,0 +1,81 @@
+//go:build !nogui
+
+package main
+
+import (
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/driver/desktop"
+)
+
+// Undo Run and Redo Run step through the kept runs of the current image;
+// the plain shortcut undoes touch-up strokes.
+var (
+	undoRunShortcut = &desktop.CustomShortcut{KeyName: fyne.KeyZ, Modifier: fyne.KeyModifierShortcutDefault | fyne.KeyModifierAlt}
+	redoRunShortcut = &desktop.CustomShortcut{KeyName: fyne.KeyZ, Modifier: fyne.KeyModifierShortcutDefault | fyne.KeyModifierAlt | fyne.KeyModifierShift}
+)
+
+// buildRunMenu returns the Undo Run and Redo Run items and registers their
+// shortcuts on the window.
+func (a *Application) buildRunMenu() []*fyne.MenuItem {
+	undo := fyne.NewMenuItem("Undo Run", func() { a.stepRun(-1) })
+	undo.Shortcut = undoRunShortcut
+	redo := fyne.NewMenuItem("Redo Run", func() { a.stepRun(1) })
+	redo.Shortcut = redoRunShortcut
+
+	a.window.Canvas().AddShortcut(undoRunShortcut, func(fyne.Shortcut) { a.stepRun(-1) })
+	a.window.Canvas().AddShortcut(redoRunShortcut, func(fyne.Shortcut) { a.stepRun(1) })
+	return []*fyne.MenuItem{undo, redo}
+}
+
+// stepRun shows the result and parameters of an earlier or later kept run
+// without reprocessing, asking first when touch-up edits would be lost.
+func (a *Application) stepRun(delta int) {
+	if a.toolbar.processingInProgress {
+		a.statusBar.SetStatus("Wait for processing to finish")
+		return
+	}
+	if !a.processing.CanStepRun(delta) {
+		if delta < 0 {
+			a.statusBar.SetStatus("No earlier run to go back to")
+		} else {
+			a.statusBar.SetStatus("No later run to go forward to")
+		}
+		return
+	}
+
+	if a.processing.CanUndoTouchUp() {
+		dialog.ShowConfirm("Discard Touch-Up", "Switching runs discards the touch-up edits of the current result. Continue?", func(confirmed bool) {
+			if confirmed {
+				a.showRun(delta)
+			}
+		}, a.window)
+		return
+	}
+	a.showRun(delta)
+}
+
+func (a *Application) showRun(delta int) {
+	run, err := a.processing.StepRun(delta)
+	if err != nil {
+		a.statusBar.SetStatus(err.Error())
+		return
+	}
+
+	a.parameters.SetParameters(run.Params)
+	a.parameters.cancelPendingProcessing()
+
+	processed := a.processing.GetProcessedImage()
+	metrics := a.processing.GetProcessedMetrics()
+	a.inspector.ResultChanged()
+	a.imageViewer.SetProcessedImage(processed.Image)
+	a.parameters.SetMetrics(metrics)
+	a.parameters.SetProcessingDetails(run.Summary, metrics)
+	a.components.Analyze(processed)
+	a.warnings.Show(a.processing.QualityWarnings())
+	a.touchUp.Refresh()
+	a.statusBar.SetChanges("This run changed: ", run.Changes)
+	a.statusBar.SetStatus(fmt.Sprintf("Showing %s", run))
+}
@@ -0,0 +1,57 @@
+//go:build !nogui
+
+package main
+
+import (
+	"embed"
+	"fmt"
+	"path"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+)
+
+//go:embed samples
+var sampleFiles embed.FS
+
+// sampleImage is a document bundled for trying the application without
+// images of one's own.
+type sampleImage struct {
+	title string
+	file  string
+}
+
+var sampleImages = []sampleImage{
+	{"Clean Print", "clean_print.png"},
+	{"Stained Manuscript", "stained_manuscript.png"},
+	{"Photographed Page", "photographed_page.jpg"},
+}
+
+func (a *Application) buildSampleMenuItem() *fyne.MenuItem {
+	items := make([]*fyne.MenuItem, len(sampleImages))
+	for i, sample := range sampleImages {
+		items[i] = fyne.NewMenuItem(sample.title, func() {
+			a.openSample(sample)
+		})
+	}
+
+	item := fyne.NewMenuItem("Open Sample", nil)
+	item.ChildMenu = fyne.NewMenu("", items...)
+	return item
+}
+
+// openSample loads a bundled sample like a file, in this window while it is
+// still empty.
+func (a *Application) openSample(sample sampleImage) {
+	data, err := sampleFiles.ReadFile(path.Join("samples", sample.file))
+	if err != nil {
+		dialog.ShowError(fmt.Errorf("open sample %s: %w", sample.title, err), a.window)
+		return
+	}
+
+	target := a
+	if a.processing.GetOriginalImage() != nil {
+		target = a.newDocumentWindow()
+	}
+	target.openImageData(sample.title, data, path.Ext(sample.file))
+}
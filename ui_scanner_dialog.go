@@ -0,0 +1,150 @@
+//go:build !nogui
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+const (
+	scannerListTimeout = 20 * time.Second
+	scanTimeout        = 5 * time.Minute
+
+	prefScanDPI       = "scanner.dpi"
+	prefScanGrayscale = "scanner.grayscale"
+)
+
+func (a *Application) showScannerDialog() {
+	backend, err := DetectScannerBackend()
+	if err != nil {
+		dialog.ShowError(err, a.window)
+		return
+	}
+
+	prefs := a.fyneApp.Preferences()
+
+	deviceSelect := widget.NewSelect(nil, nil)
+	deviceSelect.PlaceHolder = "Searching for scanners..."
+	deviceSelect.Disable()
+
+	resolutionOptions := make([]string, len(ScanResolutions))
+	for i, dpi := range ScanResolutions {
+		resolutionOptions[i] = strconv.Itoa(dpi)
+	}
+	dpiSelect := widget.NewSelect(resolutionOptions, nil)
+	dpiSelect.SetSelected(strconv.Itoa(prefs.IntWithFallback(prefScanDPI, 300)))
+
+	grayCheck := widget.NewCheck("Grayscale", nil)
+	grayCheck.SetChecked(prefs.BoolWithFallback(prefScanGrayscale, true))
+
+	var devices []ScannerDevice
+	go func() {
+		ctx, cancel := context.WithTimeout(a.ctx, scannerListTimeout)
+		defer cancel()
+
+		found, listErr := backend.ListDevices(ctx)
+		fyne.Do(func() {
+			if listErr != nil {
+				deviceSelect.PlaceHolder = "Default scanner"
+				deviceSelect.Refresh()
+				a.debugSystem.logger.Warn("scanner listing failed", "backend", backend.Name(), "error", listErr.Error())
+				return
+			}
+
+			devices = found
+			names := make([]string, len(found))
+			for i, device := range found {
+				names[i] = device.Name
+			}
+			deviceSelect.Options = names
+			deviceSelect.PlaceHolder = "No scanners found"
+			deviceSelect.Enable()
+			if len(names) > 0 {
+				deviceSelect.SetSelectedIndex(0)
+			}
+			deviceSelect.Refresh()
+		})
+	}()
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("Scanner", deviceSelect),
+		widget.NewFormItem("Resolution (DPI)", dpiSelect),
+		widget.NewFormItem("", grayCheck),
+	}
+
+	dialog.ShowForm(fmt.Sprintf("Acquire from Scanner (%s)", backend.Name()), "Scan", "Cancel", items, func(confirmed bool) {
+		if !confirmed {
+			return
+		}
+
+		dpi, _ := strconv.Atoi(dpiSelect.Selected)
+		request := ScanRequest{DPI: dpi, Grayscale: grayCheck.Checked}
+		if index := deviceSelect.SelectedIndex(); index >= 0 && index < len(devices) {
+			request.DeviceID = devices[index].ID
+		}
+
+		prefs.SetInt(prefScanDPI, dpi)
+		prefs.SetBool(prefScanGrayscale, request.Grayscale)
+
+		a.acquireScan(backend, request)
+	}, a.window)
+}
+
+// acquireScan runs the scan in the background behind a cancellable progress
+// dialog and loads the result as if it had been opened from a file.
+func (a *Application) acquireScan(backend ScannerBackend, request ScanRequest) {
+	ctx, cancel := context.WithTimeout(a.ctx, scanTimeout)
+
+	progress := dialog.NewCustom("Scanning", "Cancel", widget.NewProgressBarInfinite(), a.window)
+	progress.SetOnClosed(cancel)
+	progress.Show()
+
+	a.statusBar.SetStatus(fmt.Sprintf("Scanning at %d DPI...", request.DPI))
+
+	go func() {
+		defer cancel()
+		data, extension, err := backend.Scan(ctx, request)
+
+		fyne.Do(func() {
+			// Read before hiding, since closing the dialog cancels ctx.
+			cancelled := ctx.Err() == context.Canceled
+			progress.Hide()
+
+			if err != nil {
+				if cancelled {
+					a.statusBar.SetStatus("Scan cancelled")
+					return
+				}
+				dialog.ShowError(err, a.window)
+				a.statusBar.SetStatus("Scan failed")
+				return
+			}
+
+			a.decodeImage(data, extension, func(imageData *ImageData, err error) {
+				if err != nil {
+					dialog.ShowError(fmt.Errorf("load scan: %w", err), a.window)
+					a.statusBar.SetStatus("Scan failed")
+					return
+				}
+				if imageData == nil {
+					return
+				}
+
+				// Scanner output rarely carries resolution metadata.
+				if imageData.DPI == 0 {
+					imageData.DPI = float64(request.DPI) * imageData.ScaleFactor
+				}
+
+				a.toolbar.applyLoadedImage("scan", imageData)
+				a.statusBar.SetStatus(fmt.Sprintf("Scanned at %d DPI", request.DPI))
+			})
+		})
+	}()
+}
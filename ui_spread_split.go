@@ -0,0 +1,228 @@
+//go:build !nogui
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+const (
+	splitModeBefore = "Binarize each page on its own, the right page in a new window"
+	splitModeAfter  = "Split the current result and save both pages"
+)
+
+// splitLineEditor overlays the split line of a spread on a scaled copy of
+// the image, with a handle at its middle that drags it sideways.
+type splitLineEditor struct {
+	x         int
+	imageSize image.Point
+	scale     float32
+
+	line    *canvas.Line
+	handle  *cornerHandle
+	content *fyne.Container
+}
+
+func newSplitLineEditor(img image.Image, x int) *splitLineEditor {
+	bounds := img.Bounds()
+	editor := &splitLineEditor{
+		x:         x,
+		imageSize: image.Pt(bounds.Dx(), bounds.Dy()),
+		scale: min32(
+			float32(cornerEditorWidth)/float32(bounds.Dx()),
+			float32(cornerEditorHeight)/float32(bounds.Dy()),
+		),
+	}
+
+	displaySize := fyne.NewSize(float32(bounds.Dx())*editor.scale, float32(bounds.Dy())*editor.scale)
+	preview := canvas.NewImageFromImage(img)
+	preview.FillMode = canvas.ImageFillStretch
+	preview.ScaleMode = canvas.ImageScaleFastest
+	preview.SetMinSize(displaySize)
+	preview.Resize(displaySize)
+
+	editor.line = canvas.NewLine(cornerOutlineColor)
+	editor.line.StrokeWidth = 2
+	editor.handle = newCornerHandle(editor.moveLine)
+
+	editor.content = container.NewWithoutLayout(preview, editor.line, editor.handle)
+	editor.layoutOverlay()
+	return editor
+}
+
+func (e *splitLineEditor) moveLine(delta fyne.Delta) {
+	x := int((float32(e.x)*e.scale + delta.DX) / e.scale)
+	e.x = max(1, min(e.imageSize.X-1, x))
+	e.layoutOverlay()
+}
+
+func (e *splitLineEditor) setLine(x int) {
+	e.x = x
+	e.layoutOverlay()
+}
+
+func (e *splitLineEditor) layoutOverlay() {
+	x := float32(e.x) * e.scale
+	height := float32(e.imageSize.Y) * e.scale
+	e.line.Position1 = fyne.NewPos(x, 0)
+	e.line.Position2 = fyne.NewPos(x, height)
+	e.line.Refresh()
+	e.handle.Move(fyne.NewPos(x-cornerHandleSize/2, height/2-cornerHandleSize/2))
+	e.content.Refresh()
+}
+
+// showSpreadSplit lets the user place the line between the pages of a
+// book spread, starting from the detected gutter, and split the spread
+// before or after binarization.
+func (a *Application) showSpreadSplit() {
+	original := a.processing.GetOriginalImage()
+	if original == nil {
+		dialog.ShowInformation("Split Double Page", "Load an image first.", a.window)
+		return
+	}
+
+	hint := "Drag the line onto the gutter between the pages."
+	gutter, err := a.processing.DetectSpreadGutter()
+	if err != nil {
+		gutter = original.Width / 2
+		if errors.Is(err, ErrSpreadNotDetected) {
+			hint = "No gutter was found. Drag the line between the pages."
+		}
+	}
+
+	editor := newSplitLineEditor(original.Image, gutter)
+	status := widget.NewLabel(hint)
+	modeRadio := widget.NewRadioGroup([]string{splitModeBefore, splitModeAfter}, nil)
+	modeRadio.SetSelected(splitModeBefore)
+
+	detectButton := widget.NewButton("Detect", func() {
+		found, err := a.processing.DetectSpreadGutter()
+		if err != nil {
+			status.SetText("No gutter was found. Drag the line between the pages.")
+			return
+		}
+		editor.setLine(found)
+		status.SetText("Gutter detected.")
+	})
+
+	content := container.NewBorder(
+		status,
+		container.NewVBox(container.NewHBox(detectButton), modeRadio),
+		nil, nil,
+		container.NewCenter(editor.content),
+	)
+
+	confirm := dialog.NewCustomConfirm("Split Double Page", "Split", "Cancel", content, func(split bool) {
+		if !split {
+			return
+		}
+		if modeRadio.Selected == splitModeAfter {
+			a.saveSplitResult(original.Width, editor.x)
+			return
+		}
+		a.splitIntoWindows(original, editor.x)
+	}, a.window)
+	confirm.Show()
+}
+
+// splitIntoWindows keeps the left page in this window, opens the right one
+// in a new window, and processes both with the current parameters.
+func (a *Application) splitIntoWindows(original *ImageData, x int) {
+	left, right, _, _, err := SplitSpread(original, x)
+	if err != nil {
+		dialog.ShowError(err, a.window)
+		return
+	}
+
+	params := a.parameters.GetCurrentParameters()
+	name := a.sourceName
+	if name == "" {
+		name = "spread.png"
+	}
+
+	document := a.newDocumentWindow()
+	document.toolbar.applyLoadedImage(SpreadPagePath(name, "right"), right)
+	document.parameters.SetParameters(params)
+	document.toolbar.handleProcessImageWithParams(params)
+
+	a.toolbar.applyLoadedImage(SpreadPagePath(name, "left"), left)
+	a.toolbar.handleProcessImageWithParams(params)
+}
+
+// saveSplitResult cuts the current result at column x of the original,
+// scaled to the result when page geometry stages resized it, and saves the
+// pages to a chosen folder as <name>_left.png and <name>_right.png.
+func (a *Application) saveSplitResult(originalWidth, x int) {
+	processed := a.processing.GetProcessedImage()
+	if processed == nil {
+		dialog.ShowInformation("Split Double Page", "Process the image first.", a.window)
+		return
+	}
+
+	dialog.ShowFolderOpen(func(folder fyne.ListableURI, err error) {
+		if err != nil {
+			dialog.ShowError(err, a.window)
+			return
+		}
+		if folder == nil {
+			return
+		}
+
+		left, right, leftCrop, rightCrop, err := SplitSpread(processed, x*processed.Width/originalWidth)
+		if err != nil {
+			dialog.ShowError(err, a.window)
+			return
+		}
+		defer left.Mat.Close()
+		defer right.Mat.Close()
+
+		base := filepath.Join(folder.Path(), a.outputFileName()+".png")
+		for _, page := range []struct {
+			side string
+			data *ImageData
+			crop *OutputCrop
+		}{{"left", left, leftCrop}, {"right", right, rightCrop}} {
+			path := SpreadPagePath(base, page.side)
+			if err := a.writeSplitPage(path, page.data, page.crop); err != nil {
+				dialog.ShowError(err, a.window)
+				return
+			}
+		}
+		a.statusBar.SetStatus("Saved " + filepath.Base(SpreadPagePath(base, "left")) + " and " + filepath.Base(SpreadPagePath(base, "right")))
+	}, a.window)
+}
+
+func (a *Application) writeSplitPage(path string, page *ImageData, crop *OutputCrop) error {
+	var encoded bytes.Buffer
+	if err := EncodeImage(&encoded, page, filepath.Ext(path)); err != nil {
+		return fmt.Errorf("save %s: %w", path, err)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("create %s: %w", path, err)
+	}
+	if err := writeWithMetadata(file, encoded.Bytes(), filepath.Ext(path), a.metadata.Metadata()); err != nil {
+		file.Close()
+		return fmt.Errorf("save %s: %w", path, err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("close %s: %w", path, err)
+	}
+
+	if sidecar := a.outputSidecar(crop); sidecar != nil {
+		return sidecar.Write(path)
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+//go:build !nogui
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+const statusBarRefreshInterval = time.Second
+
+type StatusBar struct {
+	container *fyne.Container
+
+	messageLabel  *widget.Label
+	imageLabel    *widget.Label
+	durationLabel *widget.Label
+	memoryLabel   *widget.Label
+	changesLabel  *widget.Label
+	spinner       *widget.ProgressBarInfinite
+
+	jobStart     time.Time
+	jobActive    bool
+	lastDuration time.Duration
+}
+
+func NewStatusBar() *StatusBar {
+	sb := &StatusBar{
+		messageLabel:  widget.NewLabel("Ready"),
+		imageLabel:    widget.NewLabel("No image"),
+		durationLabel: widget.NewLabel("Last run: -"),
+		memoryLabel:   widget.NewLabel("Heap: -"),
+		changesLabel:  widget.NewLabel(""),
+		spinner:       widget.NewProgressBarInfinite(),
+	}
+
+	sb.spinner.Stop()
+	sb.spinner.Hide()
+	sb.changesLabel.Truncation = fyne.TextTruncateEllipsis
+	sb.changesLabel.Importance = widget.LowImportance
+	sb.changesLabel.Hide()
+
+	sb.container = container.NewVBox(
+		widget.NewSeparator(),
+		container.NewHBox(
+			sb.spinner,
+			sb.messageLabel,
+			widget.NewSeparator(),
+			sb.imageLabel,
+			widget.NewSeparator(),
+			sb.durationLabel,
+			widget.NewSeparator(),
+			sb.memoryLabel,
+		),
+		sb.changesLabel,
+	)
+
+	return sb
+}
+
+func (sb *StatusBar) SetStatus(status string) {
+	sb.messageLabel.SetText(status)
+}
+
+// SetChanges shows which parameters differ between runs; prefix says
+// which runs. No changes hides the line.
+func (sb *StatusBar) SetChanges(prefix string, changes []ParameterDiff) {
+	if len(changes) == 0 {
+		sb.changesLabel.Hide()
+		return
+	}
+	sb.changesLabel.SetText(prefix + formatParameterChanges(changes))
+	sb.changesLabel.Show()
+}
+
+func (sb *StatusBar) SetImageInfo(imageData *ImageData) {
+	if imageData == nil {
+		sb.imageLabel.SetText("No image")
+		return
+	}
+
+	dpi := "DPI: unknown"
+	if imageData.DPI > 0 {
+		dpi = fmt.Sprintf("%.0f DPI", imageData.DPI)
+	}
+
+	sb.imageLabel.SetText(fmt.Sprintf("%dx%d | %s | %d ch | %s",
+		imageData.Width, imageData.Height, dpi, imageData.Channels, imageData.Format))
+}
+
+func (sb *StatusBar) StartJob() {
+	sb.jobStart = time.Now()
+	sb.jobActive = true
+	sb.spinner.Show()
+	sb.spinner.Start()
+	sb.durationLabel.SetText("Running: 0.0s")
+}
+
+func (sb *StatusBar) FinishJob(duration time.Duration) {
+	sb.jobActive = false
+	sb.lastDuration = duration
+	sb.spinner.Stop()
+	sb.spinner.Hide()
+	sb.durationLabel.SetText(fmt.Sprintf("Last run: %dms", duration.Milliseconds()))
+}
+
+// StopJob clears the busy state of a job that ended without a result.
+func (sb *StatusBar) StopJob() {
+	if !sb.jobActive {
+		return
+	}
+
+	sb.jobActive = false
+	sb.spinner.Stop()
+	sb.spinner.Hide()
+
+	if sb.lastDuration > 0 {
+		sb.durationLabel.SetText(fmt.Sprintf("Last run: %dms", sb.lastDuration.Milliseconds()))
+	} else {
+		sb.durationLabel.SetText("Last run: -")
+	}
+}
+
+// Run refreshes heap usage and the running-job timer until ctx is done.
+func (sb *StatusBar) Run(ctx context.Context) {
+	ticker := time.NewTicker(statusBarRefreshInterval)
+	defer ticker.Stop()
+
+	debugSystem := GetDebugSystem()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			heapMB := debugSystem.HeapUsageMB()
+			fyne.Do(func() {
+				sb.memoryLabel.SetText(fmt.Sprintf("Heap: %.1f MB", heapMB))
+				if sb.jobActive {
+					sb.durationLabel.SetText(fmt.Sprintf("Running: %.1fs", time.Since(sb.jobStart).Seconds()))
+				}
+			})
+		}
+	}
+}
+
+func (sb *StatusBar) GetContainer() *fyne.Container {
+	return sb.container
+}
@@ -0,0 +1,258 @@
+//go:build !nogui
+
+package main
+
+import (
+	"image"
+	"image/color"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/widget"
+	xdraw "golang.org/x/image/draw"
+)
+
+const (
+	// minimapWidth is the width of the strip minimap in Fyne units, and
+	// minimapHeight the height in pixels its thumbnail is scaled to.
+	minimapWidth  = 56
+	minimapHeight = 1024
+)
+
+// isTallStrip reports whether an image is a tall narrow strip, such as a
+// till receipt, that is unreadable when fitted into the view whole.
+func isTallStrip(img image.Image) bool {
+	if img == nil {
+		return false
+	}
+	size := img.Bounds().Size()
+	return size.Y > size.X && isExtremeAspect(size.X, size.Y)
+}
+
+// stripRect is the part of the source the view shows: all of it, or in
+// strip mode the rows that fill its width.
+func (pv *previewView) stripRect() image.Rectangle {
+	bounds := pv.source.Bounds()
+	size := pv.Size()
+	if !pv.strip || size.Width <= 0 || size.Height <= 0 {
+		return bounds
+	}
+
+	rows := int(float32(bounds.Dx()) * size.Height / size.Width)
+	if rows < 1 || rows >= bounds.Dy() {
+		return bounds
+	}
+	top := bounds.Min.Y + int(pv.stripOffset*float32(bounds.Dy()-rows)+0.5)
+	return image.Rect(bounds.Min.X, top, bounds.Max.X, top+rows)
+}
+
+// stripFraction is the share of the source rows the view shows.
+func (pv *previewView) stripFraction() float32 {
+	if pv.source == nil || pv.source.Bounds().Dy() == 0 {
+		return 1
+	}
+	return float32(pv.stripRect().Dy()) / float32(pv.source.Bounds().Dy())
+}
+
+// stripOffsetAfterScroll is the offset that moves the strip by dy units,
+// positive dy towards the top.
+func (pv *previewView) stripOffsetAfterScroll(dy float32) float32 {
+	if pv.source == nil || pv.Size().Width <= 0 {
+		return pv.stripOffset
+	}
+	bounds := pv.source.Bounds()
+	hidden := bounds.Dy() - pv.stripRect().Dy()
+	if hidden <= 0 {
+		return pv.stripOffset
+	}
+	rows := -dy * float32(bounds.Dx()) / pv.Size().Width
+	return clampUnit(pv.stripOffset + rows/float32(hidden))
+}
+
+func (pv *previewView) setStrip(strip bool, offset float32) {
+	pv.strip = strip
+	pv.stripOffset = offset
+	pv.rasterize()
+}
+
+func (pv *previewView) Scrolled(event *fyne.ScrollEvent) {
+	if pv.onZoomed != nil {
+		pv.onZoomed(event.Position, event.Scrolled.DY)
+		return
+	}
+	if pv.strip && pv.onScrolled != nil {
+		pv.onScrolled(event.Scrolled.DY)
+	}
+}
+
+func clampUnit(value float32) float32 {
+	if value < 0 {
+		return 0
+	}
+	return min32(value, 1)
+}
+
+// updateStripMode switches both previews to strip mode when the original is
+// a tall strip. A new page size starts at the top; previews of the same page
+// keep the position.
+func (iv *ImageViewer) updateStripMode() {
+	original := iv.originalImage.source
+	strip := isTallStrip(original)
+	if size := imageSize(original); !strip || size != iv.stripSize {
+		iv.stripOffset = 0
+		iv.stripSize = size
+		if strip {
+			iv.minimap.SetImage(original)
+		}
+	}
+	iv.originalImage.setStrip(strip, iv.stripOffset)
+	iv.processedImage.setStrip(strip && isTallStrip(iv.processedImage.source), iv.stripOffset)
+	iv.blinkImage.setStrip(strip, iv.stripOffset)
+
+	if strip {
+		iv.minimap.Show()
+	} else {
+		iv.minimap.Hide()
+	}
+	iv.refreshMinimap()
+}
+
+func imageSize(img image.Image) image.Point {
+	if img == nil {
+		return image.Point{}
+	}
+	return img.Bounds().Size()
+}
+
+func (iv *ImageViewer) setStripOffset(offset float32) {
+	iv.stripOffset = clampUnit(offset)
+	iv.originalImage.setStrip(iv.originalImage.strip, iv.stripOffset)
+	iv.processedImage.setStrip(iv.processedImage.strip, iv.stripOffset)
+	iv.blinkImage.setStrip(iv.blinkImage.strip, iv.stripOffset)
+	iv.processedView.layoutOverlays()
+	iv.refreshMinimap()
+}
+
+func (iv *ImageViewer) scrollStrip(dy float32) {
+	iv.setStripOffset(iv.originalImage.stripOffsetAfterScroll(dy))
+}
+
+func (iv *ImageViewer) refreshMinimap() {
+	iv.minimap.setWindow(iv.stripOffset, iv.originalImage.stripFraction())
+}
+
+// stripMinimap shows a whole tall image next to the previews with a frame
+// around the rows they show. Tapping, dragging or scrolling it moves the
+// previews.
+type stripMinimap struct {
+	widget.BaseWidget
+	thumbnail *canvas.Image
+	frame     *canvas.Rectangle
+
+	offset, fraction float32
+	onMoved          func(offset float32)
+	onScrolled       func(dy float32)
+}
+
+func newStripMinimap() *stripMinimap {
+	m := &stripMinimap{
+		thumbnail: canvas.NewImageFromImage(nil),
+		frame:     canvas.NewRectangle(color.NRGBA{R: 230, G: 40, B: 40, A: 40}),
+		fraction:  1,
+	}
+	m.thumbnail.FillMode = canvas.ImageFillContain
+	m.thumbnail.ScaleMode = canvas.ImageScaleSmooth
+	m.frame.StrokeColor = color.NRGBA{R: 230, G: 40, B: 40, A: 255}
+	m.frame.StrokeWidth = 1
+	m.ExtendBaseWidget(m)
+	return m
+}
+
+// SetImage shows img scaled down to at most minimapHeight pixels.
+func (m *stripMinimap) SetImage(img image.Image) {
+	bounds := img.Bounds()
+	if bounds.Dy() > minimapHeight {
+		size := image.Pt(max(1, bounds.Dx()*minimapHeight/bounds.Dy()), minimapHeight)
+		scaled := newImageLike(img, image.Rectangle{Max: size})
+		xdraw.ApproxBiLinear.Scale(scaled, scaled.Bounds(), img, bounds, xdraw.Src, nil)
+		img = scaled
+	}
+	m.thumbnail.Image = img
+	m.thumbnail.Refresh()
+	m.Refresh()
+}
+
+func (m *stripMinimap) setWindow(offset, fraction float32) {
+	m.offset, m.fraction = offset, fraction
+	m.Refresh()
+}
+
+// drawnArea is where the contained thumbnail is drawn.
+func (m *stripMinimap) drawnArea() (fyne.Position, fyne.Size) {
+	size := m.Size()
+	if m.thumbnail.Image == nil {
+		return fyne.Position{}, size
+	}
+	bounds := m.thumbnail.Image.Bounds()
+	scale := min32(size.Width/float32(bounds.Dx()), size.Height/float32(bounds.Dy()))
+	drawn := fyne.NewSize(float32(bounds.Dx())*scale, float32(bounds.Dy())*scale)
+	return fyne.NewPos((size.Width-drawn.Width)/2, (size.Height-drawn.Height)/2), drawn
+}
+
+// moveTo centers the frame on y where possible.
+func (m *stripMinimap) moveTo(y float32) {
+	origin, drawn := m.drawnArea()
+	if m.onMoved == nil || drawn.Height <= 0 || m.fraction >= 1 {
+		return
+	}
+	m.onMoved(((y-origin.Y)/drawn.Height - m.fraction/2) / (1 - m.fraction))
+}
+
+func (m *stripMinimap) Tapped(event *fyne.PointEvent) {
+	m.moveTo(event.Position.Y)
+}
+
+func (m *stripMinimap) Dragged(event *fyne.DragEvent) {
+	m.moveTo(event.Position.Y)
+}
+
+func (m *stripMinimap) DragEnd() {}
+
+func (m *stripMinimap) Scrolled(event *fyne.ScrollEvent) {
+	if m.onScrolled != nil {
+		m.onScrolled(event.Scrolled.DY)
+	}
+}
+
+func (m *stripMinimap) CreateRenderer() fyne.WidgetRenderer {
+	return &stripMinimapRenderer{minimap: m}
+}
+
+type stripMinimapRenderer struct {
+	minimap *stripMinimap
+}
+
+func (r *stripMinimapRenderer) Layout(size fyne.Size) {
+	m := r.minimap
+	m.thumbnail.Move(fyne.NewPos(0, 0))
+	m.thumbnail.Resize(size)
+
+	origin, drawn := m.drawnArea()
+	m.frame.Move(fyne.NewPos(origin.X, origin.Y+m.offset*(1-m.fraction)*drawn.Height))
+	m.frame.Resize(fyne.NewSize(drawn.Width, m.fraction*drawn.Height))
+}
+
+func (r *stripMinimapRenderer) MinSize() fyne.Size {
+	return fyne.NewSize(minimapWidth, minimapWidth)
+}
+
+func (r *stripMinimapRenderer) Refresh() {
+	r.Layout(r.minimap.Size())
+	r.minimap.frame.Refresh()
+}
+
+func (r *stripMinimapRenderer) Objects() []fyne.CanvasObject {
+	return []fyne.CanvasObject{r.minimap.thumbnail, r.minimap.frame}
+}
+
+func (r *stripMinimapRenderer) Destroy() {}
@@ -0,0 +1,109 @@
+//go:build !nogui
+
+package main
+
+import (
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+const (
+	toastDisplayDuration = 5 * time.Second
+	toastMargin          = 16
+
+	// Jobs shorter than this finish while the user is still watching, so
+	// they do not warrant a toast.
+	toastJobThreshold = 3 * time.Second
+
+	prefSystemNotifications = "notifications.system"
+)
+
+// Toaster shows short non-modal messages in the corner of a window and can
+// mirror them to the operating system notification center.
+type Toaster struct {
+	app     *Application
+	current *widget.PopUp
+}
+
+func NewToaster(app *Application) *Toaster {
+	return &Toaster{
+		app: app,
+	}
+}
+
+// Show displays message with an optional action button. The toast hides
+// itself after toastDisplayDuration or when the action is clicked.
+func (ts *Toaster) Show(title, message, actionLabel string, action func()) {
+	if ts.current != nil {
+		ts.current.Hide()
+	}
+
+	content := container.NewVBox(createSectionHeader(title), widget.NewLabel(message))
+
+	var popup *widget.PopUp
+	if action != nil && actionLabel != "" {
+		content.Add(widget.NewButton(actionLabel, func() {
+			popup.Hide()
+			action()
+		}))
+	}
+
+	windowCanvas := ts.app.window.Canvas()
+	popup = widget.NewPopUp(content, windowCanvas)
+
+	canvasSize := windowCanvas.Size()
+	toastSize := popup.MinSize()
+	popup.ShowAtPosition(fyne.NewPos(
+		canvasSize.Width-toastSize.Width-toastMargin,
+		canvasSize.Height-toastSize.Height-toastMargin,
+	))
+	ts.current = popup
+
+	time.AfterFunc(toastDisplayDuration, func() {
+		fyne.Do(func() {
+			popup.Hide()
+			if ts.current == popup {
+				ts.current = nil
+			}
+		})
+	})
+
+	if ts.SystemNotificationsEnabled() {
+		ts.app.fyneApp.SendNotification(fyne.NewNotification(title, message))
+	}
+}
+
+// NotifyJobComplete raises a toast once a job that ran longer than
+// toastJobThreshold finishes; the action brings its window to the front.
+func (ts *Toaster) NotifyJobComplete(message string, duration time.Duration, openResults func()) {
+	if duration < toastJobThreshold {
+		return
+	}
+
+	ts.Show("Job finished", message, "Show Results", func() {
+		ts.app.window.RequestFocus()
+		if openResults != nil {
+			openResults()
+		}
+	})
+}
+
+func (ts *Toaster) SystemNotificationsEnabled() bool {
+	return ts.app.fyneApp.Preferences().BoolWithFallback(prefSystemNotifications, false)
+}
+
+func (ts *Toaster) buildMenuItem() *fyne.MenuItem {
+	item := fyne.NewMenuItem("System Notifications", nil)
+	item.Checked = ts.SystemNotificationsEnabled()
+	item.Action = func() {
+		item.Checked = !item.Checked
+		ts.app.fyneApp.Preferences().SetBool(prefSystemNotifications, item.Checked)
+		if mainMenu := ts.app.window.MainMenu(); mainMenu != nil {
+			mainMenu.Refresh()
+		}
+	}
+	return item
+}
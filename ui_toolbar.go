@@ -1,3 +1,5 @@
+//go:build !nogui
+
 package main
 
 import (
@@ -16,6 +18,8 @@ type Toolbar struct {
 	saveButton    *widget.Button
 	processButton *widget.Button
 	resetButton   *widget.Button
+	copyButton    *widget.Button
+	pasteButton   *widget.Button
 	fileSaveMenu  *FileSaveMenu
 
 	processingInProgress bool
@@ -29,7 +33,7 @@ func NewToolbar(app *Application) *Toolbar {
 	}
 
 	t.createButtons()
-	t.fileSaveMenu = NewFileSaveMenu(app.window)
+	t.fileSaveMenu = NewFileSaveMenu(app.window, app.metadata.Metadata, app.outputFileName)
 	t.buildThemedLayout()
 
 	return t
@@ -48,6 +52,9 @@ func (t *Toolbar) createButtons() {
 	t.processButton.Disable()
 
 	t.resetButton = widget.NewButton("Reset", t.handleReset)
+
+	t.copyButton = widget.NewButton("Copy Params", t.handleCopyParameters)
+	t.pasteButton = widget.NewButton("Paste Params", t.handlePasteParameters)
 }
 
 func (t *Toolbar) buildThemedLayout() {
@@ -56,6 +63,9 @@ func (t *Toolbar) buildThemedLayout() {
 		t.saveButton,
 		t.processButton,
 		t.resetButton,
+		widget.NewSeparator(),
+		t.copyButton,
+		t.pasteButton,
 	)
 
 	// Add separators above and below buttons for visual separation
@@ -0,0 +1,51 @@
+//go:build !nogui
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2/dialog"
+)
+
+func (t *Toolbar) handleCopyParameters() {
+	params := t.app.parameters.GetCurrentParameters()
+
+	text, err := EncodeParametersJSON(params)
+	if err != nil {
+		dialog.ShowError(err, t.app.window)
+		t.app.statusBar.SetStatus("Copy failed")
+		return
+	}
+
+	t.app.fyneApp.Clipboard().SetContent(text)
+	t.app.statusBar.SetStatus("Parameters copied to clipboard")
+	DebugTraceParam("ParametersCopied", "none", len(text))
+}
+
+func (t *Toolbar) handlePasteParameters() {
+	text := t.app.fyneApp.Clipboard().Content()
+	current := t.app.parameters.GetCurrentParameters()
+
+	params, err := DecodeParametersJSON(text, current)
+	if err != nil {
+		var fieldErrors ParameterFieldErrors
+		if errors.As(err, &fieldErrors) {
+			lines := make([]string, len(fieldErrors))
+			for i, fieldErr := range fieldErrors {
+				lines[i] = fmt.Sprintf("%s: %v - %s", fieldErr.Field, fieldErr.Value, fieldErr.Reason)
+			}
+			dialog.ShowError(fmt.Errorf("invalid parameters:\n%s", strings.Join(lines, "\n")), t.app.window)
+		} else {
+			dialog.ShowError(err, t.app.window)
+		}
+		t.app.statusBar.SetStatus("Paste failed")
+		return
+	}
+
+	t.app.parameters.SetParameters(params)
+	t.app.statusBar.SetStatus("Parameters pasted from clipboard")
+	DebugTraceParam("ParametersPasted", "none", len(text))
+}
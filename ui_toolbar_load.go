@@ -1,3 +1,5 @@
+//go:build !nogui
+
 package main
 
 import (
@@ -20,32 +22,65 @@ func (t *Toolbar) handleLoadImage() {
 		debugSystem := GetDebugSystem()
 		opID := debugSystem.TraceProcessingStart("image_load", &OtsuParameters{}, [2]int{0, 0})
 
-		t.app.parameters.SetStatus("Loading image...")
+		t.app.statusBar.SetStatus("Loading image...")
 		DebugTraceMemory("before_image_load")
 
-		imageData, loadErr := LoadImageFromReader(reader)
-		loadDuration := time.Since(startTime)
-
-		if loadErr != nil {
-			debugSystem.TraceProcessingEnd(opID, loadDuration, false, loadErr.Error())
-			dialog.ShowError(loadErr, t.app.window)
-			t.app.parameters.SetStatus("Load failed")
+		data, extension, readErr := ReadImageBytes(reader)
+		if readErr != nil {
+			debugSystem.TraceProcessingEnd(opID, time.Since(startTime), false, readErr.Error())
+			dialog.ShowError(readErr, t.app.window)
+			t.app.statusBar.SetStatus("Load failed")
 			return
 		}
 
-		debugSystem.TraceProcessingEnd(opID, loadDuration, true, "")
-		debugSystem.TraceImageOperation(opID, "load", [2]int{0, 0}, [2]int{imageData.Width, imageData.Height}, loadDuration)
-		DebugTraceMemory("after_image_load")
+		t.app.decodeImage(data, extension, func(imageData *ImageData, loadErr error) {
+			loadDuration := time.Since(startTime)
 
-		fyne.Do(func() {
-			t.app.imageViewer.SetOriginalImage(imageData.Image)
-			t.app.processing.SetOriginalImage(imageData)
-			t.processButton.Enable()
-			t.app.parameters.SetStatus("Image loaded")
-			t.app.parameters.SetDetails(fmt.Sprintf("Image: %dx%d pixels, %d channels, %s format",
-				imageData.Width, imageData.Height, imageData.Channels, imageData.Format))
+			if loadErr != nil {
+				debugSystem.TraceProcessingEnd(opID, loadDuration, false, loadErr.Error())
+				dialog.ShowError(loadErr, t.app.window)
+				t.app.statusBar.SetStatus("Load failed")
+				return
+			}
+			if imageData == nil {
+				debugSystem.TraceProcessingEnd(opID, loadDuration, false, "downscale declined")
+				return
+			}
 
-			DebugTraceParam("ImageLoaded", "none", fmt.Sprintf("%dx%d", imageData.Width, imageData.Height))
+			debugSystem.TraceProcessingEnd(opID, loadDuration, true, "")
+			debugSystem.TraceImageOperation(opID, "load", [2]int{0, 0}, [2]int{imageData.Width, imageData.Height}, loadDuration)
+			DebugTraceMemory("after_image_load")
+
+			t.applyLoadedImage(reader.URI().Name(), imageData)
 		})
 	}, t.app.window)
 }
+
+// applyLoadedImage shows imageData, read from the file or device name, as
+// the image to process.
+func (t *Toolbar) applyLoadedImage(name string, imageData *ImageData) {
+	t.app.sourceName = name
+	t.app.imageViewer.SetOriginalImage(imageData.Image)
+	t.app.processing.SetOriginalImage(imageData)
+	t.app.parameters.RefreshTonePreview()
+	t.app.components.Clear()
+	t.app.warnings.Clear()
+	t.app.parameters.SetMetrics(nil)
+	t.app.statusBar.SetChanges("", nil)
+	t.app.touchUp.Refresh()
+	t.app.notes.Refresh()
+	t.processButton.Enable()
+	t.app.statusBar.SetStatus("Image loaded")
+	t.app.statusBar.SetImageInfo(imageData)
+	t.app.parameters.SetDetails(fmt.Sprintf("Image: %dx%d pixels, %d channels, %s format",
+		imageData.Width, imageData.Height, imageData.Channels, imageData.Format))
+
+	if imageData.ScaleFactor < 1 {
+		t.app.statusBar.SetStatus(fmt.Sprintf("Image loaded, downscaled from %dx%d (scale %.3f)",
+			imageData.OriginalWidth, imageData.OriginalHeight, imageData.ScaleFactor))
+	}
+
+	t.app.suggestProfile()
+
+	DebugTraceParam("ImageLoaded", "none", fmt.Sprintf("%dx%d", imageData.Width, imageData.Height))
+}
@@ -1,12 +1,17 @@
+//go:build !nogui
+
 package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/dialog"
+
+	"otsu-obliterator/progress"
 )
 
 func (t *Toolbar) handleProcessImage() {
@@ -15,8 +20,17 @@ func (t *Toolbar) handleProcessImage() {
 }
 
 func (t *Toolbar) handleProcessImageWithParams(params *OtsuParameters) {
+	t.runProcessing(params, nil)
+}
+
+// runProcessing starts a processing job for params. When done is non-nil it
+// is called on the UI thread with the job outcome once the job ends.
+func (t *Toolbar) runProcessing(params *OtsuParameters, done func(error)) {
 	originalData := t.app.processing.GetOriginalImage()
 	if originalData == nil {
+		if done != nil {
+			done(fmt.Errorf("no image loaded"))
+		}
 		return
 	}
 
@@ -28,16 +42,22 @@ func (t *Toolbar) handleProcessImageWithParams(params *OtsuParameters) {
 	}
 
 	t.processingInProgress = true
-	t.app.parameters.SetStatus("Processing...")
+	t.app.statusBar.SetStatus("Processing...")
+	t.app.statusBar.StartJob()
 	t.processButton.SetText("Cancel")
 
-	t.currentProcessingCtx, t.cancelProcessing = context.WithCancel(context.Background())
+	t.currentProcessingCtx, t.cancelProcessing = context.WithCancel(t.app.ctx)
 
 	go func() {
+		var jobErr error
 		defer func() {
 			fyne.Do(func() {
 				t.processingInProgress = false
 				t.processButton.SetText("Process")
+				t.app.statusBar.StopJob()
+				if done != nil {
+					done(jobErr)
+				}
 			})
 		}()
 
@@ -51,31 +71,55 @@ func (t *Toolbar) handleProcessImageWithParams(params *OtsuParameters) {
 		DebugTraceMemory("before_processing")
 
 		if err := validateOtsuParameters(params, imageSize); err != nil {
+			jobErr = err
 			processingDuration := time.Since(startTime)
 			debugSystem.TraceValidationError(err, "parameter_validation")
 			debugSystem.TraceProcessingEnd(opID, processingDuration, false, err.Error())
 
 			fyne.Do(func() {
 				dialog.ShowError(err, t.app.window)
-				t.app.parameters.SetStatus("Parameter validation failed")
+				t.app.statusBar.SetStatus("Parameter validation failed")
 			})
 			return
 		}
 
-		result, metrics, err := t.app.processing.ProcessImageWithTimeout(t.currentProcessingCtx, params)
+		lastStage, lastPercent := "", -1
+		reporter := progress.New(t.currentProcessingCtx, func(update progress.Update) {
+			percent := int(100 * update.Overall)
+			if update.Stage == lastStage && percent == lastPercent {
+				return
+			}
+			lastStage, lastPercent = update.Stage, percent
+			fyne.Do(func() {
+				if t.processingInProgress {
+					t.app.statusBar.SetStatus(fmt.Sprintf("Processing: %s, %d%%", update.Stage, percent))
+				}
+			})
+		})
+
+		t.app.processing.DeferMetrics = BatterySaverActive()
+		result, metrics, err := t.app.processing.ProcessImageWithProgress(reporter, params)
 		processingDuration := time.Since(startTime)
+		generation := t.app.processing.ResultGeneration()
 
 		DebugTraceMemory("after_processing")
 
 		if err != nil {
+			jobErr = err
 			debugSystem.TraceProcessingEnd(opID, processingDuration, false, err.Error())
 
 			fyne.Do(func() {
 				if t.currentProcessingCtx.Err() == context.Canceled {
-					t.app.parameters.SetStatus("Processing cancelled")
+					t.app.statusBar.SetStatus("Processing cancelled")
+				} else if errors.Is(err, ErrImageChanged) {
+					t.app.statusBar.SetStatus("Discarded the result of the previous image")
+				} else if errors.Is(err, ErrPageNotDetected) {
+					t.app.statusBar.SetStatus("Page outline not found")
+					t.app.showPageCornerEditor()
 				} else {
-					dialog.ShowError(err, t.app.window)
-					t.app.parameters.SetStatus("Processing failed")
+					t.app.warnings.Clear()
+					t.app.showProcessingError(err, params)
+					t.app.statusBar.SetStatus("Processing failed")
 				}
 			})
 			return
@@ -89,10 +133,31 @@ func (t *Toolbar) handleProcessImageWithParams(params *OtsuParameters) {
 		}
 
 		fyne.Do(func() {
+			if generation != t.app.processing.ResultGeneration() {
+				t.app.statusBar.SetStatus("Discarded the result of the previous image")
+				return
+			}
+			t.app.inspector.ResultChanged()
 			t.app.imageViewer.SetProcessedImage(result.Image)
-			t.app.parameters.SetStatus("Processing complete")
-			t.app.parameters.SetMetrics(metrics)
-			t.app.parameters.SetProcessingDetails(params, result, metrics)
+			t.app.statusBar.SetStatus("Processing complete")
+			t.app.statusBar.FinishJob(processingDuration)
+			summary := ""
+			if runs := t.app.processing.RunHistory(); len(runs) > 0 {
+				t.app.statusBar.SetChanges("This run changed: ", runs[len(runs)-1].Changes)
+				summary = runs[len(runs)-1].Summary
+			}
+			t.app.toaster.NotifyJobComplete(
+				fmt.Sprintf("%s finished in %.1fs", method, processingDuration.Seconds()),
+				processingDuration, nil)
+			if metrics == nil && t.app.processing.MetricsDeferred() {
+				t.app.parameters.SetMetricsDeferred(summary)
+			} else {
+				t.app.parameters.SetMetrics(metrics)
+				t.app.parameters.SetProcessingDetails(summary, metrics)
+			}
+			t.app.components.Analyze(result)
+			t.app.warnings.Show(t.app.processing.QualityWarnings())
+			t.app.touchUp.Refresh()
 			t.saveButton.Enable()
 
 			DebugTraceParam("ProcessingComplete", method, fmt.Sprintf("duration=%dms", processingDuration.Milliseconds()))
@@ -101,17 +166,12 @@ func (t *Toolbar) handleProcessImageWithParams(params *OtsuParameters) {
 }
 
 func (t *Toolbar) getProcessingMethodName(params *OtsuParameters) string {
-	if params.MultiScaleProcessing {
-		return fmt.Sprintf("multi_scale_%d_levels", params.PyramidLevels)
-	} else if params.RegionAdaptiveThresholding {
-		return fmt.Sprintf("region_adaptive_%d_grid", params.RegionGridSize)
-	}
-	return "single_scale"
+	return processingMethodName(params)
 }
 
 func (t *Toolbar) CancelCurrentProcessing() {
 	if t.processingInProgress && t.cancelProcessing != nil {
 		t.cancelProcessing()
-		t.app.parameters.SetStatus("Processing cancelled")
+		t.app.statusBar.SetStatus("Processing cancelled")
 	}
 }
@@ -1,3 +1,5 @@
+//go:build !nogui
+
 package main
 
 import (
@@ -11,18 +13,33 @@ func (t *Toolbar) handleSaveImage() {
 		return
 	}
 
-	t.app.parameters.SetStatus("Preparing save...")
+	t.app.statusBar.SetStatus("Preparing save...")
+
+	processedData, crop, release, err := t.app.croppedForSave(processedData)
+	if err != nil {
+		dialog.ShowError(err, t.app.window)
+		t.app.statusBar.SetStatus("Save failed")
+		return
+	}
 
 	t.fileSaveMenu.ShowSaveDialog(processedData, func(writer fyne.URIWriteCloser, err error) {
+		defer release()
 		if err != nil {
 			dialog.ShowError(err, t.app.window)
-			t.app.parameters.SetStatus("Save failed")
+			t.app.statusBar.SetStatus("Save failed")
 			return
 		}
 
 		if writer != nil {
-			t.app.parameters.SetStatus("Image saved")
+			t.app.statusBar.SetStatus("Image saved")
 			DebugTraceParam("ImageSaved", "none", writer.URI().String())
+
+			sidecar := t.app.outputSidecar(crop)
+			if sidecar != nil && writer.URI().Scheme() == "file" {
+				if err := sidecar.Write(writer.URI().Path()); err != nil {
+					dialog.ShowError(err, t.app.window)
+				}
+			}
 		}
 	})
 }
@@ -0,0 +1,214 @@
+//go:build !nogui
+
+package main
+
+import (
+	"fmt"
+	"image"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/driver/desktop"
+	"fyne.io/fyne/v2/widget"
+)
+
+const (
+	touchUpToolInspect    = "Inspect"
+	touchUpToolInkBrush   = "Ink Brush"
+	touchUpToolPaperBrush = "Paper Brush"
+	touchUpToolMagicWand  = "Magic Wand"
+	touchUpToolRuler      = "Ruler"
+	touchUpToolNote       = "Note"
+
+	defaultBrushRadius = 3
+	maxBrushRadius     = 50
+)
+
+// TouchUpPanel selects what pointer input on the processed image does:
+// inspecting components, painting ink or paper over the result, flipping
+// a whole connected region, measuring with the ruler, or pinning a note.
+type TouchUpPanel struct {
+	app       *Application
+	container *fyne.Container
+
+	toolSelect   *widget.RadioGroup
+	brushSlider  *widget.Slider
+	brushLabel   *widget.Label
+	undoButton   *widget.Button
+	summaryLabel *widget.Label
+
+	stroking bool
+
+	// measuring is set while a ruler drag that started at rulerStart lasts.
+	measuring  bool
+	rulerStart image.Point
+}
+
+func NewTouchUpPanel(app *Application) *TouchUpPanel {
+	tp := &TouchUpPanel{app: app}
+
+	tp.toolSelect = widget.NewRadioGroup([]string{touchUpToolInspect, touchUpToolInkBrush, touchUpToolPaperBrush, touchUpToolMagicWand, touchUpToolRuler, touchUpToolNote}, nil)
+	tp.toolSelect.SetSelected(touchUpToolInspect)
+	tp.toolSelect.Required = true
+
+	tp.brushLabel = widget.NewLabel("")
+	tp.brushSlider = widget.NewSlider(1, maxBrushRadius)
+	tp.brushSlider.Step = 1
+	tp.brushSlider.OnChanged = func(value float64) {
+		tp.brushLabel.SetText(fmt.Sprintf("Brush radius: %d px", int(value)))
+	}
+	tp.brushSlider.SetValue(defaultBrushRadius)
+
+	tp.undoButton = widget.NewButton("Undo", tp.undo)
+	tp.undoButton.Disable()
+	tp.summaryLabel = widget.NewLabel("No touch-ups")
+
+	tp.container = container.NewVBox(
+		createSectionHeader("Touch-Up"),
+		tp.toolSelect,
+		tp.brushLabel,
+		tp.brushSlider,
+		tp.undoButton,
+		tp.summaryLabel,
+	)
+
+	app.window.Canvas().AddShortcut(&desktop.CustomShortcut{
+		KeyName:  fyne.KeyZ,
+		Modifier: fyne.KeyModifierShortcutDefault,
+	}, func(fyne.Shortcut) {
+		tp.undo()
+	})
+
+	return tp
+}
+
+// HandleTap inspects the component under the pointer, flips its region,
+// paints a single brush dab or pins a note, depending on the selected tool.
+func (tp *TouchUpPanel) HandleTap(x, y int) {
+	switch tp.toolSelect.Selected {
+	case touchUpToolInspect:
+		tp.app.components.ShowComponentAt(x, y)
+		return
+	case touchUpToolMagicWand:
+		tp.flipRegion(x, y)
+		return
+	case touchUpToolRuler:
+		return
+	case touchUpToolNote:
+		tp.app.notes.AddNoteAt(x, y)
+		return
+	}
+
+	p := image.Pt(x, y)
+	tp.HandleDrag(p, p)
+	tp.HandleDragEnd()
+}
+
+func (tp *TouchUpPanel) HandleDrag(from, to image.Point) {
+	if tp.toolSelect.Selected == touchUpToolRuler {
+		if !tp.measuring {
+			tp.measuring = true
+			tp.rulerStart = from
+		}
+		tp.app.inspector.Measure(tp.rulerStart, to)
+		return
+	}
+	if tp.toolSelect.Selected == touchUpToolInspect || tp.toolSelect.Selected == touchUpToolMagicWand ||
+		tp.toolSelect.Selected == touchUpToolNote {
+		return
+	}
+
+	if !tp.stroking {
+		if err := tp.app.processing.BeginTouchUp(); err != nil {
+			tp.app.statusBar.SetStatus("Process an image before touching it up")
+			return
+		}
+		tp.stroking = true
+	}
+
+	ink := tp.toolSelect.Selected == touchUpToolInkBrush
+	tp.app.processing.PaintTouchUp(from, to, int(tp.brushSlider.Value), ink)
+	tp.app.imageViewer.SetProcessedImage(tp.app.processing.GetProcessedImage().Image)
+}
+
+func (tp *TouchUpPanel) HandleDragEnd() {
+	tp.measuring = false
+	if !tp.stroking {
+		return
+	}
+	tp.stroking = false
+	tp.resultEdited()
+}
+
+// flipRegion turns the ink component under (x, y) into paper, or the
+// enclosed paper region under it into ink.
+func (tp *TouchUpPanel) flipRegion(x, y int) {
+	if tp.stroking {
+		return
+	}
+
+	analysis := tp.app.components.CurrentAnalysis()
+	if analysis == nil {
+		tp.app.statusBar.SetStatus("Wait for the component analysis to finish")
+		return
+	}
+
+	mask, ink, err := analysis.RegionMaskAt(x, y)
+	if err != nil {
+		tp.app.statusBar.SetStatus(fmt.Sprintf("Magic wand: %v", err))
+		return
+	}
+	defer mask.Close()
+
+	if err := tp.app.processing.FillTouchUp(mask, !ink); err != nil {
+		tp.app.statusBar.SetStatus(fmt.Sprintf("Magic wand: %v", err))
+		return
+	}
+
+	if ink {
+		tp.app.statusBar.SetStatus("Component removed")
+	} else {
+		tp.app.statusBar.SetStatus("Region filled with ink")
+	}
+	tp.app.imageViewer.SetProcessedImage(tp.app.processing.GetProcessedImage().Image)
+	tp.resultEdited()
+}
+
+func (tp *TouchUpPanel) undo() {
+	if tp.stroking || !tp.app.processing.UndoTouchUp() {
+		return
+	}
+
+	tp.app.imageViewer.SetProcessedImage(tp.app.processing.GetProcessedImage().Image)
+	tp.resultEdited()
+}
+
+// resultEdited refreshes what depends on the result after a stroke or undo.
+func (tp *TouchUpPanel) resultEdited() {
+	if processed := tp.app.processing.GetProcessedImage(); processed != nil {
+		tp.app.components.Analyze(processed)
+	}
+	tp.app.inspector.ResultChanged()
+	tp.Refresh()
+}
+
+// Refresh updates the undo button and touch-up summary, for example after
+// a new result replaced the touched-up one.
+func (tp *TouchUpPanel) Refresh() {
+	if tp.app.processing.CanUndoTouchUp() {
+		tp.undoButton.Enable()
+	} else {
+		tp.undoButton.Disable()
+	}
+
+	record := tp.app.processing.TouchUpRecord()
+	if record == nil {
+		tp.summaryLabel.SetText("No touch-ups")
+		return
+	}
+	tp.summaryLabel.SetText(fmt.Sprintf("%d pixels touched up in %d regions", record.Pixels, len(record.Regions)))
+}
+
+func (tp *TouchUpPanel) GetContainer() *fyne.Container {
+	return tp.container
+}
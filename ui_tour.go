@@ -0,0 +1,142 @@
+//go:build !nogui
+
+package main
+
+import (
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+const prefTourShown = "tour.shown"
+
+const (
+	tourCardWidth  = 380
+	tourCardHeight = 210
+)
+
+// tourStep explains one part of the workflow in a card placed under its
+// target; steps without a target are centered.
+type tourStep struct {
+	title   string
+	text    string
+	target  func(a *Application) fyne.CanvasObject
+	samples bool
+}
+
+var tourSteps = []tourStep{
+	{
+		title:   "Welcome",
+		text:    "Otsu Obliterator turns scans and photographs of documents into black and white. This tour walks through the main workflow. Open a sample to follow along, or load an image of your own.",
+		samples: true,
+	},
+	{
+		title:  "Load an image",
+		text:   "Load opens PNG, JPEG or TIFF images. File also offers scanners, cameras and video. Help > Open Sample brings back the samples.",
+		target: func(a *Application) fyne.CanvasObject { return a.toolbar.loadButton },
+	},
+	{
+		title:  "Choose a method",
+		text:   "The processing method decides how ink is told from paper. Open \"About this method\" for its formula and parameters. The other sections clean up the image before and after thresholding.",
+		target: func(a *Application) fyne.CanvasObject { return a.parameters.widgets.processingMethodSelect },
+	},
+	{
+		title:  "Process",
+		text:   "Process runs the pipeline and shows the result next to the original. The Metrics panel reports how well it separated ink from paper.",
+		target: func(a *Application) fyne.CanvasObject { return a.toolbar.processButton },
+	},
+	{
+		title:  "Inspect the result",
+		text:   "Hover over the result to see why a pixel became ink or paper in the Inspector. Touch-Up fixes pixels by hand. The panels can be rearranged from the View menu.",
+		target: func(a *Application) fyne.CanvasObject { return a.inspector.GetContainer() },
+	},
+	{
+		title:  "Save",
+		text:   "Save writes the result as PNG, JPEG or TIFF. Copy Params copies the settings as JSON, which the command line accepts with -params to process whole folders. Help > Take the Tour shows this again.",
+		target: func(a *Application) fyne.CanvasObject { return a.toolbar.saveButton },
+	},
+}
+
+// showFirstRunTour starts the tour the first time the application runs.
+func (a *Application) showFirstRunTour() {
+	prefs := a.fyneApp.Preferences()
+	if prefs.Bool(prefTourShown) {
+		return
+	}
+	prefs.SetBool(prefTourShown, true)
+	a.showTour()
+}
+
+func (a *Application) showTour() {
+	a.showTourStep(0)
+}
+
+func (a *Application) showTourStep(index int) {
+	step := tourSteps[index]
+	var card *widget.PopUp
+	goTo := func(next int) {
+		card.Hide()
+		if next >= 0 && next < len(tourSteps) {
+			a.showTourStep(next)
+		}
+	}
+
+	title := widget.NewLabelWithStyle(fmt.Sprintf("%s (%d of %d)", step.title, index+1, len(tourSteps)),
+		fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+	text := widget.NewLabel(step.text)
+	text.Wrapping = fyne.TextWrapWord
+
+	back := widget.NewButton("Back", func() { goTo(index - 1) })
+	if index == 0 {
+		back.Disable()
+	}
+	next := widget.NewButton("Next", func() { goTo(index + 1) })
+	if index == len(tourSteps)-1 {
+		next.SetText("Done")
+	}
+	next.Importance = widget.HighImportance
+	skip := widget.NewButton("Skip Tour", func() { goTo(-1) })
+
+	content := container.NewVBox(title, text)
+	if step.samples {
+		samples := container.NewHBox()
+		for _, sample := range sampleImages {
+			samples.Add(widget.NewButton(sample.title, func() {
+				a.openSample(sample)
+				goTo(index + 1)
+			}))
+		}
+		content.Add(samples)
+	}
+	content.Add(layout.NewSpacer())
+	content.Add(container.NewHBox(skip, layout.NewSpacer(), back, next))
+
+	card = widget.NewPopUp(container.NewPadded(content), a.window.Canvas())
+	size := fyne.NewSize(tourCardWidth, tourCardHeight)
+	card.Resize(size)
+	card.ShowAtPosition(a.tourCardPosition(step, size))
+}
+
+// tourCardPosition places the card under the step's target, kept inside the
+// window, or centers it when the target is not on screen.
+func (a *Application) tourCardPosition(step tourStep, size fyne.Size) fyne.Position {
+	canvasSize := a.window.Canvas().Size()
+	center := fyne.NewPos((canvasSize.Width-size.Width)/2, (canvasSize.Height-size.Height)/2)
+	if step.target == nil {
+		return center
+	}
+
+	target := step.target(a)
+	if target == nil || !target.Visible() || target.Size().IsZero() {
+		return center
+	}
+	position := fyne.CurrentApp().Driver().AbsolutePositionForObject(target)
+	position = position.AddXY(0, target.Size().Height+theme.Padding())
+	position.X = max(0, min(position.X, canvasSize.Width-size.Width))
+	position.Y = max(0, min(position.Y, canvasSize.Height-size.Height))
+	return position
+}
@@ -0,0 +1,95 @@
+//go:build !nogui
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+var vectorFormats = map[string]string{
+	"SVG": ".svg",
+	"PDF": ".pdf",
+}
+
+// showExportVector traces the current result into vector outlines and saves
+// them as SVG or PDF.
+func (a *Application) showExportVector() {
+	processed := a.processing.GetProcessedImage()
+	if processed == nil {
+		dialog.ShowInformation("Export Vector Outlines", "Process an image first.", a.window)
+		return
+	}
+
+	defaults := DefaultTraceOptions()
+	toleranceEntry := widget.NewEntry()
+	toleranceEntry.SetText(strconv.FormatFloat(defaults.Tolerance, 'f', -1, 64))
+	minAreaEntry := widget.NewEntry()
+	minAreaEntry.SetText(strconv.FormatFloat(defaults.MinArea, 'f', -1, 64))
+	smoothnessSlider := widget.NewSlider(0, 1)
+	smoothnessSlider.Step = 0.05
+	smoothnessSlider.SetValue(defaults.Smoothness)
+	formatSelect := widget.NewSelect([]string{"SVG", "PDF"}, nil)
+	formatSelect.SetSelected("SVG")
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("Tolerance (px)", toleranceEntry),
+		widget.NewFormItem("Smoothness", smoothnessSlider),
+		widget.NewFormItem("Minimum Area (px)", minAreaEntry),
+		widget.NewFormItem("Format", formatSelect),
+	}
+	dialog.ShowForm("Export Vector Outlines", "Export...", "Cancel", items, func(confirmed bool) {
+		if !confirmed {
+			return
+		}
+
+		options, err := ParseTraceOptions(fmt.Sprintf("tolerance=%s,smoothness=%g,min-area=%s",
+			strings.TrimSpace(toleranceEntry.Text), smoothnessSlider.Value, strings.TrimSpace(minAreaEntry.Text)))
+		if err != nil {
+			dialog.ShowError(err, a.window)
+			return
+		}
+		outlines, err := TraceOutlines(processed.Mat, options)
+		if err != nil {
+			dialog.ShowError(err, a.window)
+			return
+		}
+		if original := a.processing.GetOriginalImage(); original != nil {
+			outlines.DPI = original.DPI
+		}
+		a.saveVectorOutlines(outlines, vectorFormats[formatSelect.Selected])
+	}, a.window)
+}
+
+func (a *Application) saveVectorOutlines(outlines *VectorOutlines, extension string) {
+	save := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, a.window)
+			return
+		}
+		if writer == nil {
+			return
+		}
+		defer writer.Close()
+
+		data := outlines.SVG()
+		if extension == ".pdf" {
+			data, err = outlines.PDF()
+		}
+		if err == nil {
+			_, err = writer.Write(data)
+		}
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("export vector outlines: %w", err), a.window)
+			return
+		}
+		a.statusBar.SetStatus(fmt.Sprintf("Exported %d outlines to %s", len(outlines.Paths), writer.URI().Name()))
+	}, a.window)
+	save.SetFileName(a.outputFileName() + "_outlines" + extension)
+	save.Show()
+}
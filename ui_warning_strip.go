@@ -0,0 +1,61 @@
+//go:build !nogui
+
+package main
+
+import (
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// WarningStrip lists the quality warnings of the latest run below the
+// toolbar. It stays out of the way until a run finds an anomaly.
+type WarningStrip struct {
+	container *fyne.Container
+	rows      *fyne.Container
+}
+
+func NewWarningStrip() *WarningStrip {
+	ws := &WarningStrip{rows: container.NewVBox()}
+
+	dismissButton := widget.NewButtonWithIcon("", theme.CancelIcon(), ws.Clear)
+	dismissButton.Importance = widget.LowImportance
+
+	ws.container = container.NewBorder(nil, nil, nil, container.NewVBox(dismissButton), ws.rows)
+	ws.container.Hide()
+	return ws
+}
+
+// Show replaces the listed warnings; no warnings hides the strip.
+func (ws *WarningStrip) Show(warnings []QualityWarning) {
+	ws.rows.RemoveAll()
+	for _, warning := range warnings {
+		message := widget.NewLabel(warning.Message)
+		message.Importance = widget.WarningImportance
+		message.TextStyle = fyne.TextStyle{Bold: true}
+		message.Wrapping = fyne.TextWrapWord
+
+		suggestion := widget.NewLabel(warning.Suggestion)
+		suggestion.Wrapping = fyne.TextWrapWord
+
+		ws.rows.Add(container.NewBorder(nil, nil,
+			widget.NewIcon(theme.WarningIcon()), nil,
+			container.NewVBox(message, suggestion)))
+	}
+
+	if len(warnings) == 0 {
+		ws.container.Hide()
+	} else {
+		ws.container.Show()
+	}
+	ws.container.Refresh()
+}
+
+func (ws *WarningStrip) Clear() {
+	ws.Show(nil)
+}
+
+func (ws *WarningStrip) GetContainer() *fyne.Container {
+	return ws.container
+}